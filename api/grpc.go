@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	apiv1 "github.com/syepes/network_exporter/api/v1"
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/http"
+	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/pkg/ping"
+	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/results"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer exposes the Service over gRPC
+type GRPCServer struct {
+	apiv1.UnimplementedAdminServiceServer
+	logger *slog.Logger
+	svc    *Service
+}
+
+// NewGRPCServer creates the gRPC admin server
+func NewGRPCServer(logger *slog.Logger, svc *Service) *GRPCServer {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &GRPCServer{logger: logger, svc: svc}
+}
+
+// ListenAndServe serves the admin API on addr, TLS is enabled with a certificate and mTLS with a client CA
+func (s *GRPCServer) ListenAndServe(addr string, certFile string, keyFile string, clientCAFile string) error {
+	opts := []grpc.ServerOption{}
+	if certFile != "" {
+		tlsConfig, err := newServerTLSConfig(certFile, keyFile, clientCAFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if clientCAFile != "" {
+		return fmt.Errorf("a client CA requires a TLS certificate")
+	} else {
+		s.logger.Warn("TLS is disabled on the admin API", "type", "API", "func", "ListenAndServe", "address", addr)
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(opts...)
+	apiv1.RegisterAdminServiceServer(server, s)
+	s.logger.Info("Listening for the admin API", "type", "API", "func", "ListenAndServe", "address", addr, "tls", certFile != "", "mtls", clientCAFile != "")
+	return server.Serve(l)
+}
+
+// ListTargets returns the active targets
+func (s *GRPCServer) ListTargets(ctx context.Context, req *apiv1.ListTargetsRequest) (*apiv1.ListTargetsResponse, error) {
+	resp := &apiv1.ListTargetsResponse{}
+	for _, t := range s.svc.ListTargets(req.GetType()) {
+		resp.Targets = append(resp.Targets, targetToProto(t))
+	}
+	return resp, nil
+}
+
+// AddTarget adds or replaces a runtime target
+func (s *GRPCServer) AddTarget(ctx context.Context, req *apiv1.AddTargetRequest) (*apiv1.AddTargetResponse, error) {
+	if req.GetTarget() == nil {
+		return nil, status.Error(codes.InvalidArgument, "target must be set")
+	}
+	t := targetFromProto(req.GetTarget())
+	if err := s.svc.AddTarget(t); err != nil {
+		return nil, toStatus(err)
+	}
+	return &apiv1.AddTargetResponse{Target: targetToProto(Target{Target: t, Origin: config.Origin{Source: Source, Kind: Source}})}, nil
+}
+
+// RemoveTarget removes a runtime target
+func (s *GRPCServer) RemoveTarget(ctx context.Context, req *apiv1.RemoveTargetRequest) (*apiv1.RemoveTargetResponse, error) {
+	if err := s.svc.RemoveTarget(req.GetName(), req.GetType()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &apiv1.RemoveTargetResponse{}, nil
+}
+
+// GetResult returns the last results of a target
+func (s *GRPCServer) GetResult(ctx context.Context, req *apiv1.GetResultRequest) (*apiv1.GetResultResponse, error) {
+	rs, err := s.svc.GetResult(req.GetName(), req.GetType())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &apiv1.GetResultResponse{}
+	for _, r := range rs {
+		resp.Results = append(resp.Results, resultToProto(r))
+	}
+	return resp, nil
+}
+
+// WatchResults streams the results until the client cancels, the results are dropped when the client does not keep up
+func (s *GRPCServer) WatchResults(req *apiv1.WatchResultsRequest, stream apiv1.AdminService_WatchResultsServer) error {
+	names := toSet(req.GetNames())
+	types := toSet(req.GetTypes())
+	if types["ICMP+MTR"] {
+		types["ICMP"], types["MTR"] = true, true
+	}
+
+	sub := s.svc.Subscribe()
+	defer sub.Close()
+	s.logger.Debug("Watching results", "type", "API", "func", "WatchResults", "names", req.GetNames(), "types", req.GetTypes())
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			if sub.Dropped() > 0 {
+				s.logger.Warn("Results dropped on a slow watcher", "type", "API", "func", "WatchResults", "dropped", sub.Dropped())
+			}
+			return nil
+		case r := <-sub.C:
+			if (len(names) > 0 && !names[r.Name]) || (len(types) > 0 && !types[r.Type]) {
+				continue
+			}
+			if err := stream.Send(&apiv1.WatchResultsResponse{Result: resultToProto(r), Dropped: sub.Dropped()}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newServerTLSConfig loads the server certificate and the CA used to verify the clients
+func newServerTLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %s", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		ca, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in client CA file: %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// toStatus maps the service errors to the gRPC status codes
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, i := range items {
+		set[i] = true
+	}
+	return set
+}
+
+func targetFromProto(p *apiv1.Target) config.Target {
+	t := config.Target{
+		Name:     p.GetName(),
+		Host:     p.GetHost(),
+		Type:     config.TargetType(p.GetType()),
+		Proxy:    p.GetProxy(),
+		Probe:    p.GetProbe(),
+		SourceIp: p.GetSourceIp(),
+	}
+	t.Labels.Kv = p.GetLabels()
+	return t
+}
+
+func targetToProto(t Target) *apiv1.Target {
+	p := &apiv1.Target{
+		Name:       t.Name,
+		Host:       t.Host,
+		Type:       string(t.Type),
+		Proxy:      common.RedactURL(t.Proxy),
+		Probe:      t.Probe,
+		SourceIp:   t.SourceIp,
+		Labels:     t.Labels.Kv,
+		Source:     t.Origin.Source,
+		SourceKind: t.Origin.Kind,
+	}
+	if !t.Origin.FirstSeen.IsZero() {
+		p.FirstSeen = timestamppb.New(t.Origin.FirstSeen)
+		p.LastSeen = timestamppb.New(t.Origin.LastSeen)
+	}
+	return p
+}
+
+func resultToProto(r results.Result) *apiv1.Result {
+	p := &apiv1.Result{
+		Name:    r.Name,
+		Type:    r.Type,
+		Host:    r.Host,
+		Ip:      r.IP,
+		Labels:  r.Labels,
+		Time:    timestamppb.New(r.Time),
+		Success: r.Success,
+	}
+
+	switch d := r.Data.(type) {
+	case *ping.PingResult:
+		p.Data = &apiv1.Result_Ping{Ping: &apiv1.PingResult{
+			DropRate:       d.DropRate,
+			Best:           durationpb.New(d.BestTime),
+			Avg:            durationpb.New(d.AvgTime),
+			Worst:          durationpb.New(d.WorstTime),
+			Sd:             durationpb.New(d.SquaredDeviationTime),
+			Usd:            durationpb.New(d.UncorrectedSDTime),
+			Csd:            durationpb.New(d.CorrectedSDTime),
+			Range:          durationpb.New(d.RangeTime),
+			SntSummary:     int64(d.SntSummary),
+			SntFailSummary: int64(d.SntFailSummary),
+			SntTimeSummary: durationpb.New(d.SntTimeSummary),
+		}}
+	case *mtr.MtrResult:
+		m := &apiv1.MtrResult{}
+		for _, h := range d.Hops {
+			m.Hops = append(m.Hops, &apiv1.MtrHop{
+				Ttl:     int32(h.TTL),
+				Address: h.AddressTo,
+				Success: h.Success,
+				Last:    durationpb.New(h.LastTime),
+				Best:    durationpb.New(h.BestTime),
+				Avg:     durationpb.New(h.AvgTime),
+				Worst:   durationpb.New(h.WorstTime),
+				Sd:      durationpb.New(h.SquaredDeviationTime),
+				Loss:    h.Loss,
+				Snt:     int64(h.Snt),
+				SntFail: int64(h.SntFail),
+			})
+		}
+		p.Data = &apiv1.Result_Mtr{Mtr: m}
+	case *tcp.TCPPortReturn:
+		p.Data = &apiv1.Result_Tcp{Tcp: &apiv1.TcpResult{
+			Port:           d.DestPort,
+			SrcIp:          d.SrcIp,
+			ConnectionTime: durationpb.New(d.ConTime),
+		}}
+	case *http.HTTPReturn:
+		h := &apiv1.HttpResult{
+			Status:           int32(d.Status),
+			ContentLength:    d.ContentLength,
+			DnsLookup:        durationpb.New(d.DNSLookup),
+			TcpConnection:    durationpb.New(d.TCPConnection),
+			TlsHandshake:     durationpb.New(d.TLSHandshake),
+			ServerProcessing: durationpb.New(d.ServerProcessing),
+			ContentTransfer:  durationpb.New(d.ContentTransfer),
+			Total:            durationpb.New(d.Total),
+			TlsVersion:       d.TLSVersion,
+		}
+		if !d.TLSEarliestCertExpiry.IsZero() {
+			h.TlsEarliestCertExpiry = timestamppb.New(d.TLSEarliestCertExpiry)
+		}
+		p.Data = &apiv1.Result_Http{Http: h}
+	}
+	return p
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/target"
+)
+
+// targetJSON target inventory entry of /api/v1/targets
+type targetJSON struct {
+	Name     string            `json:"name"`
+	Host     string            `json:"host"`
+	Type     string            `json:"type"`
+	Proxy    string            `json:"proxy,omitempty"`
+	Probe    []string          `json:"probe,omitempty"`
+	SourceIp string            `json:"source_ip,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Interval string            `json:"interval,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"`
+	Count    int               `json:"count,omitempty"`
+	MaxHops  int               `json:"max_hops,omitempty"`
+	MtrCount int               `json:"mtr_count,omitempty"`
+	Origin   config.Origin     `json:"origin"`
+	// Disabled target of the config file with enabled false, it is not probed
+	Disabled bool `json:"disabled,omitempty"`
+	// Quarantined check types of the target after repeated probe panics
+	Quarantined []string `json:"quarantined,omitempty"`
+}
+
+// ServeTargets lists the active and disabled targets with their origin as JSON, the optional type query parameter filters by type
+func (s *Service) ServeTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	quarantined := map[string][]string{}
+	for _, p := range target.PanicStats() {
+		if p.Quarantined {
+			quarantined[p.Target] = common.AppendIfMissing(quarantined[p.Target], p.Type)
+		}
+	}
+
+	targets := []targetJSON{}
+	for _, t := range s.ListTargets(r.URL.Query().Get("type")) {
+		e := targetJSON{
+			Name:     t.Name,
+			Host:     t.Host,
+			Type:     string(t.Type),
+			Proxy:    common.RedactURL(t.Proxy),
+			Probe:    t.Probe,
+			SourceIp: t.SourceIp,
+			Labels:   t.Labels.Kv,
+			Count:    t.Count,
+			MaxHops:  t.MaxHops,
+			MtrCount: t.MtrCount,
+			Origin:   t.Origin,
+		}
+		for _, checkType := range quarantined[t.Name] {
+			if matchType(t.Type, checkType) {
+				e.Quarantined = append(e.Quarantined, checkType)
+			}
+		}
+		if d := t.Interval.Duration(); d > 0 {
+			e.Interval = d.String()
+		}
+		if d := t.Timeout.Duration(); d > 0 {
+			e.Timeout = d.String()
+		}
+		targets = append(targets, e)
+	}
+	for _, t := range s.sc.Disabled() {
+		if checkType := r.URL.Query().Get("type"); checkType != "" && !matchType(t.Type, checkType) {
+			continue
+		}
+		targets = append(targets, targetJSON{Name: t.Name, Host: t.Host, Type: string(t.Type), Probe: t.Probe, Labels: t.Labels.Kv, Origin: config.Origin{Source: "config", Kind: "config"}, Disabled: true})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"targets": targets}); err != nil {
+		s.logger.Error("Encoding targets", "type", "API", "func", "ServeTargets", "err", err)
+	}
+}
+
+// ServeSRV lists the members of the expanded SRV records as JSON
+func (s *Service) ServeSRV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"records": s.sc.SRVRecords()}); err != nil {
+		s.logger.Error("Encoding SRV records", "type", "API", "func", "ServeSRV", "err", err)
+	}
+}
+
+// ServeHistory returns the result history of a target as JSON, the name and type query parameters are required
+func (s *Service) ServeHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, checkType := r.URL.Query().Get("name"), r.URL.Query().Get("type")
+	if name == "" || checkType == "" {
+		http.Error(w, "name and type are required", http.StatusBadRequest)
+		return
+	}
+
+	series, err := s.GetHistory(name, checkType)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"series": series}); err != nil {
+		s.logger.Error("Encoding history", "type", "API", "func", "ServeHistory", "err", err)
+	}
+}
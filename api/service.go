@@ -0,0 +1,237 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/history"
+	"github.com/syepes/network_exporter/results"
+)
+
+// Source of the targets added at runtime
+const Source = "api"
+
+var (
+	// ErrNotFound the target does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid the target definition is not valid
+	ErrInvalid = errors.New("invalid target")
+	// ErrExists the target is already defined by the configuration file or a discovery source
+	ErrExists = errors.New("already exists")
+)
+
+// Target active target with its origin
+type Target struct {
+	config.Target
+	Origin config.Origin
+}
+
+// Service implements the target management and results operations on top of the monitors
+// The runtime targets are merged into the active configuration like the targets of a discovery source
+type Service struct {
+	logger      *slog.Logger
+	sc          *config.SafeConfig
+	hub         *results.Hub
+	history     *history.Store
+	watchBuffer int
+	notify      func()
+	targets     config.Targets
+	mtx         sync.Mutex
+}
+
+// NewService creates the API service, notify is called after the runtime targets changed
+// The history is optional, nil when the result history is disabled
+func NewService(logger *slog.Logger, sc *config.SafeConfig, hub *results.Hub, hist *history.Store, watchBuffer int, notify func()) *Service {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &Service{
+		logger:      logger,
+		sc:          sc,
+		hub:         hub,
+		history:     hist,
+		watchBuffer: watchBuffer,
+		notify:      notify,
+	}
+}
+
+// ListTargets returns the active targets, optionally filtered by type
+func (s *Service) ListTargets(checkType string) []Target {
+	s.sc.RLock()
+	active := s.sc.Cfg.Targets
+	s.sc.RUnlock()
+
+	targets := []Target{}
+	for _, t := range active {
+		if checkType != "" && !matchType(t.Type, checkType) {
+			continue
+		}
+		origin, _ := s.sc.TargetOrigin(t)
+		targets = append(targets, Target{Target: t, Origin: origin})
+	}
+	return targets
+}
+
+// AddTarget adds or replaces a runtime target
+func (s *Service) AddTarget(t config.Target) error {
+	if err := validateTarget(t); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sc.RLock()
+	active := s.sc.Cfg.Targets
+	s.sc.RUnlock()
+	for _, a := range active {
+		if overlaps(a, t) {
+			if origin, _ := s.sc.TargetOrigin(a); origin.Source != Source {
+				return fmt.Errorf("%w: %s (%s) is defined by %s", ErrExists, t.Name, t.Type, origin.Source)
+			}
+		}
+	}
+
+	targets := config.Targets{}
+	for _, a := range s.targets {
+		if !overlaps(a, t) {
+			targets = append(targets, a)
+		}
+	}
+	targets = append(targets, t)
+
+	if err := s.apply(targets); err != nil {
+		return err
+	}
+	s.logger.Info("Added runtime target", "type", "API", "func", "AddTarget", "name", t.Name, "host", t.Host, "check_type", t.Type)
+	return nil
+}
+
+// RemoveTarget removes a runtime target, an empty type removes the target of every type
+func (s *Service) RemoveTarget(name string, checkType string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	targets := config.Targets{}
+	for _, a := range s.targets {
+		if a.Name != name || (checkType != "" && string(a.Type) != checkType) {
+			targets = append(targets, a)
+		}
+	}
+	if len(targets) == len(s.targets) {
+		return fmt.Errorf("%w: no runtime target %s", ErrNotFound, name)
+	}
+
+	if err := s.apply(targets); err != nil {
+		return err
+	}
+	s.logger.Info("Removed runtime target", "type", "API", "func", "RemoveTarget", "name", name, "check_type", checkType)
+	return nil
+}
+
+// GetResult returns the last results of an active target (one per resolved IP), empty until the first probe completed
+func (s *Service) GetResult(name string, checkType string) ([]results.Result, error) {
+	found := false
+	for _, t := range s.ListTargets(checkType) {
+		if t.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no active target %s", ErrNotFound, name)
+	}
+
+	if checkType == "ICMP+MTR" {
+		return append(s.hub.Last("ICMP", name), s.hub.Last("MTR", name)...), nil
+	}
+	return s.hub.Last(checkType, name), nil
+}
+
+// GetHistory returns the result history of an active target (one series per resolved IP)
+func (s *Service) GetHistory(name string, checkType string) ([]history.Series, error) {
+	if s.history == nil {
+		return nil, fmt.Errorf("%w: result history disabled", ErrNotFound)
+	}
+	if _, err := s.GetResult(name, checkType); err != nil {
+		return nil, err
+	}
+
+	if checkType == "ICMP+MTR" {
+		return append(s.history.Series("ICMP", name), s.history.Series("MTR", name)...), nil
+	}
+	return s.history.Series(checkType, name), nil
+}
+
+// Subscribe returns a subscription to the probe results, the caller must close it
+func (s *Service) Subscribe() *results.Subscription {
+	return s.hub.Subscribe(s.watchBuffer)
+}
+
+// apply merges the runtime targets into the active configuration and updates the monitors
+// The caller must hold the lock
+func (s *Service) apply(targets config.Targets) error {
+	if err := s.sc.SetDiscovered(s.logger, Source, targets); err != nil {
+		return err
+	}
+	s.targets = targets
+	if s.notify != nil {
+		s.notify()
+	}
+	return nil
+}
+
+// validateTarget checks a runtime target the same way the monitors parse it
+func validateTarget(t config.Target) error {
+	if t.Name == "" {
+		return fmt.Errorf("%w: name must be set", ErrInvalid)
+	}
+	if t.Host == "" {
+		return fmt.Errorf("%w: host must be set", ErrInvalid)
+	}
+
+	checkType, err := config.ParseTargetType(string(t.Type))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	switch checkType {
+	case config.TypeICMP, config.TypeMTR, config.TypeICMPMTR:
+	case config.TypeTCP:
+		if len(strings.Split(t.Host, ":")) != 2 {
+			return fmt.Errorf("%w: TCP host must be host:port", ErrInvalid)
+		}
+	case config.TypeUDP:
+		if _, _, err := net.SplitHostPort(t.Host); err != nil {
+			return fmt.Errorf("%w: UDP host must be host:port", ErrInvalid)
+		}
+	case config.TypeHTTPGet:
+		u, err := url.Parse(t.Host)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("%w: HTTPGet host must be an http(s) URL", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// overlaps reports if two targets use the same name on a monitor
+func overlaps(a config.Target, b config.Target) bool {
+	if a.Name != b.Name {
+		return false
+	}
+	return matchType(a.Type, string(b.Type)) || matchType(b.Type, string(a.Type))
+}
+
+// matchType reports if a target of type t is probed by the monitor type m
+func matchType(t config.TargetType, m string) bool {
+	if string(t) == m {
+		return true
+	}
+	return t == config.TypeICMPMTR && (m == "ICMP" || m == "MTR")
+}
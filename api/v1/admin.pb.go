@@ -0,0 +1,1500 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: network_exporter/v1/admin.proto
+
+// Admin API of the network_exporter, the messages of a version are only extended in a backward compatible way
+
+package apiv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Target struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Host  string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// ICMP, MTR, ICMP+MTR, TCP or HTTPGet
+	Type     string            `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Proxy    string            `protobuf:"bytes,4,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	Probe    []string          `protobuf:"bytes,5,rep,name=probe,proto3" json:"probe,omitempty"`
+	SourceIp string            `protobuf:"bytes,6,opt,name=source_ip,json=sourceIp,proto3" json:"source_ip,omitempty"`
+	Labels   map[string]string `protobuf:"bytes,7,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Origin of the target: config, api or the discovery source identifier (read only)
+	Source string `protobuf:"bytes,8,opt,name=source,proto3" json:"source,omitempty"`
+	// Kind of source: config, api, file_sd, dns, consul, kubernetes, http, netbox, kv, ec2 or docker (read only)
+	SourceKind string `protobuf:"bytes,9,opt,name=source_kind,json=sourceKind,proto3" json:"source_kind,omitempty"`
+	// Time the target was first merged from its source (read only)
+	FirstSeen *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	// Time the source last reported the target (read only)
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Target) Reset() {
+	*x = Target{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Target) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Target) ProtoMessage() {}
+
+func (x *Target) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Target.ProtoReflect.Descriptor instead.
+func (*Target) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Target) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Target) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Target) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Target) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+func (x *Target) GetProbe() []string {
+	if x != nil {
+		return x.Probe
+	}
+	return nil
+}
+
+func (x *Target) GetSourceIp() string {
+	if x != nil {
+		return x.SourceIp
+	}
+	return ""
+}
+
+func (x *Target) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Target) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Target) GetSourceKind() string {
+	if x != nil {
+		return x.SourceKind
+	}
+	return ""
+}
+
+func (x *Target) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Target) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+type ListTargetsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional type filter
+	Type          string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTargetsRequest) Reset() {
+	*x = ListTargetsRequest{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTargetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTargetsRequest) ProtoMessage() {}
+
+func (x *ListTargetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTargetsRequest.ProtoReflect.Descriptor instead.
+func (*ListTargetsRequest) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListTargetsRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type ListTargetsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Targets       []*Target              `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTargetsResponse) Reset() {
+	*x = ListTargetsResponse{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTargetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTargetsResponse) ProtoMessage() {}
+
+func (x *ListTargetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTargetsResponse.ProtoReflect.Descriptor instead.
+func (*ListTargetsResponse) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListTargetsResponse) GetTargets() []*Target {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type AddTargetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        *Target                `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddTargetRequest) Reset() {
+	*x = AddTargetRequest{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddTargetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddTargetRequest) ProtoMessage() {}
+
+func (x *AddTargetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddTargetRequest.ProtoReflect.Descriptor instead.
+func (*AddTargetRequest) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddTargetRequest) GetTarget() *Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+type AddTargetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        *Target                `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddTargetResponse) Reset() {
+	*x = AddTargetResponse{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddTargetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddTargetResponse) ProtoMessage() {}
+
+func (x *AddTargetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddTargetResponse.ProtoReflect.Descriptor instead.
+func (*AddTargetResponse) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AddTargetResponse) GetTarget() *Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+type RemoveTargetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveTargetRequest) Reset() {
+	*x = RemoveTargetRequest{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveTargetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTargetRequest) ProtoMessage() {}
+
+func (x *RemoveTargetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTargetRequest.ProtoReflect.Descriptor instead.
+func (*RemoveTargetRequest) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RemoveTargetRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RemoveTargetRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type RemoveTargetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveTargetResponse) Reset() {
+	*x = RemoveTargetResponse{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveTargetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTargetResponse) ProtoMessage() {}
+
+func (x *RemoveTargetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTargetResponse.ProtoReflect.Descriptor instead.
+func (*RemoveTargetResponse) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{6}
+}
+
+type GetResultRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResultRequest) Reset() {
+	*x = GetResultRequest{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResultRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResultRequest) ProtoMessage() {}
+
+func (x *GetResultRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResultRequest.ProtoReflect.Descriptor instead.
+func (*GetResultRequest) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetResultRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GetResultRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type GetResultResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*Result              `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResultResponse) Reset() {
+	*x = GetResultResponse{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResultResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResultResponse) ProtoMessage() {}
+
+func (x *GetResultResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResultResponse.ProtoReflect.Descriptor instead.
+func (*GetResultResponse) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetResultResponse) GetResults() []*Result {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type WatchResultsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional filters
+	Names         []string `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	Types         []string `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResultsRequest) Reset() {
+	*x = WatchResultsRequest{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResultsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResultsRequest) ProtoMessage() {}
+
+func (x *WatchResultsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResultsRequest.ProtoReflect.Descriptor instead.
+func (*WatchResultsRequest) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchResultsRequest) GetNames() []string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+func (x *WatchResultsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+type WatchResultsResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Result *Result                `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// Results dropped on this stream so far because the client did not keep up
+	Dropped       uint64 `protobuf:"varint,2,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResultsResponse) Reset() {
+	*x = WatchResultsResponse{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResultsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResultsResponse) ProtoMessage() {}
+
+func (x *WatchResultsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResultsResponse.ProtoReflect.Descriptor instead.
+func (*WatchResultsResponse) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchResultsResponse) GetResult() *Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *WatchResultsResponse) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+type Result struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// ICMP, MTR, TCP or HTTPGet
+	Type    string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Host    string                 `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Ip      string                 `protobuf:"bytes,4,opt,name=ip,proto3" json:"ip,omitempty"`
+	Labels  map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Time    *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	Success bool                   `protobuf:"varint,7,opt,name=success,proto3" json:"success,omitempty"`
+	// Types that are valid to be assigned to Data:
+	//
+	//	*Result_Ping
+	//	*Result_Mtr
+	//	*Result_Tcp
+	//	*Result_Http
+	Data          isResult_Data `protobuf_oneof:"data"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Result) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Result) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Result) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Result) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Result) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Result) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Result) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Result) GetData() isResult_Data {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Result) GetPing() *PingResult {
+	if x != nil {
+		if x, ok := x.Data.(*Result_Ping); ok {
+			return x.Ping
+		}
+	}
+	return nil
+}
+
+func (x *Result) GetMtr() *MtrResult {
+	if x != nil {
+		if x, ok := x.Data.(*Result_Mtr); ok {
+			return x.Mtr
+		}
+	}
+	return nil
+}
+
+func (x *Result) GetTcp() *TcpResult {
+	if x != nil {
+		if x, ok := x.Data.(*Result_Tcp); ok {
+			return x.Tcp
+		}
+	}
+	return nil
+}
+
+func (x *Result) GetHttp() *HttpResult {
+	if x != nil {
+		if x, ok := x.Data.(*Result_Http); ok {
+			return x.Http
+		}
+	}
+	return nil
+}
+
+type isResult_Data interface {
+	isResult_Data()
+}
+
+type Result_Ping struct {
+	Ping *PingResult `protobuf:"bytes,10,opt,name=ping,proto3,oneof"`
+}
+
+type Result_Mtr struct {
+	Mtr *MtrResult `protobuf:"bytes,11,opt,name=mtr,proto3,oneof"`
+}
+
+type Result_Tcp struct {
+	Tcp *TcpResult `protobuf:"bytes,12,opt,name=tcp,proto3,oneof"`
+}
+
+type Result_Http struct {
+	Http *HttpResult `protobuf:"bytes,13,opt,name=http,proto3,oneof"`
+}
+
+func (*Result_Ping) isResult_Data() {}
+
+func (*Result_Mtr) isResult_Data() {}
+
+func (*Result_Tcp) isResult_Data() {}
+
+func (*Result_Http) isResult_Data() {}
+
+type PingResult struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	DropRate       float64                `protobuf:"fixed64,1,opt,name=drop_rate,json=dropRate,proto3" json:"drop_rate,omitempty"`
+	Best           *durationpb.Duration   `protobuf:"bytes,2,opt,name=best,proto3" json:"best,omitempty"`
+	Avg            *durationpb.Duration   `protobuf:"bytes,3,opt,name=avg,proto3" json:"avg,omitempty"`
+	Worst          *durationpb.Duration   `protobuf:"bytes,4,opt,name=worst,proto3" json:"worst,omitempty"`
+	Sd             *durationpb.Duration   `protobuf:"bytes,5,opt,name=sd,proto3" json:"sd,omitempty"`
+	Usd            *durationpb.Duration   `protobuf:"bytes,6,opt,name=usd,proto3" json:"usd,omitempty"`
+	Csd            *durationpb.Duration   `protobuf:"bytes,7,opt,name=csd,proto3" json:"csd,omitempty"`
+	Range          *durationpb.Duration   `protobuf:"bytes,8,opt,name=range,proto3" json:"range,omitempty"`
+	SntSummary     int64                  `protobuf:"varint,9,opt,name=snt_summary,json=sntSummary,proto3" json:"snt_summary,omitempty"`
+	SntFailSummary int64                  `protobuf:"varint,10,opt,name=snt_fail_summary,json=sntFailSummary,proto3" json:"snt_fail_summary,omitempty"`
+	SntTimeSummary *durationpb.Duration   `protobuf:"bytes,11,opt,name=snt_time_summary,json=sntTimeSummary,proto3" json:"snt_time_summary,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PingResult) Reset() {
+	*x = PingResult{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResult) ProtoMessage() {}
+
+func (x *PingResult) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResult.ProtoReflect.Descriptor instead.
+func (*PingResult) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PingResult) GetDropRate() float64 {
+	if x != nil {
+		return x.DropRate
+	}
+	return 0
+}
+
+func (x *PingResult) GetBest() *durationpb.Duration {
+	if x != nil {
+		return x.Best
+	}
+	return nil
+}
+
+func (x *PingResult) GetAvg() *durationpb.Duration {
+	if x != nil {
+		return x.Avg
+	}
+	return nil
+}
+
+func (x *PingResult) GetWorst() *durationpb.Duration {
+	if x != nil {
+		return x.Worst
+	}
+	return nil
+}
+
+func (x *PingResult) GetSd() *durationpb.Duration {
+	if x != nil {
+		return x.Sd
+	}
+	return nil
+}
+
+func (x *PingResult) GetUsd() *durationpb.Duration {
+	if x != nil {
+		return x.Usd
+	}
+	return nil
+}
+
+func (x *PingResult) GetCsd() *durationpb.Duration {
+	if x != nil {
+		return x.Csd
+	}
+	return nil
+}
+
+func (x *PingResult) GetRange() *durationpb.Duration {
+	if x != nil {
+		return x.Range
+	}
+	return nil
+}
+
+func (x *PingResult) GetSntSummary() int64 {
+	if x != nil {
+		return x.SntSummary
+	}
+	return 0
+}
+
+func (x *PingResult) GetSntFailSummary() int64 {
+	if x != nil {
+		return x.SntFailSummary
+	}
+	return 0
+}
+
+func (x *PingResult) GetSntTimeSummary() *durationpb.Duration {
+	if x != nil {
+		return x.SntTimeSummary
+	}
+	return nil
+}
+
+type MtrHop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ttl           int32                  `protobuf:"varint,1,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Last          *durationpb.Duration   `protobuf:"bytes,4,opt,name=last,proto3" json:"last,omitempty"`
+	Best          *durationpb.Duration   `protobuf:"bytes,5,opt,name=best,proto3" json:"best,omitempty"`
+	Avg           *durationpb.Duration   `protobuf:"bytes,6,opt,name=avg,proto3" json:"avg,omitempty"`
+	Worst         *durationpb.Duration   `protobuf:"bytes,7,opt,name=worst,proto3" json:"worst,omitempty"`
+	Sd            *durationpb.Duration   `protobuf:"bytes,8,opt,name=sd,proto3" json:"sd,omitempty"`
+	Loss          float64                `protobuf:"fixed64,9,opt,name=loss,proto3" json:"loss,omitempty"`
+	Snt           int64                  `protobuf:"varint,10,opt,name=snt,proto3" json:"snt,omitempty"`
+	SntFail       int64                  `protobuf:"varint,11,opt,name=snt_fail,json=sntFail,proto3" json:"snt_fail,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MtrHop) Reset() {
+	*x = MtrHop{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MtrHop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MtrHop) ProtoMessage() {}
+
+func (x *MtrHop) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MtrHop.ProtoReflect.Descriptor instead.
+func (*MtrHop) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *MtrHop) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *MtrHop) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *MtrHop) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MtrHop) GetLast() *durationpb.Duration {
+	if x != nil {
+		return x.Last
+	}
+	return nil
+}
+
+func (x *MtrHop) GetBest() *durationpb.Duration {
+	if x != nil {
+		return x.Best
+	}
+	return nil
+}
+
+func (x *MtrHop) GetAvg() *durationpb.Duration {
+	if x != nil {
+		return x.Avg
+	}
+	return nil
+}
+
+func (x *MtrHop) GetWorst() *durationpb.Duration {
+	if x != nil {
+		return x.Worst
+	}
+	return nil
+}
+
+func (x *MtrHop) GetSd() *durationpb.Duration {
+	if x != nil {
+		return x.Sd
+	}
+	return nil
+}
+
+func (x *MtrHop) GetLoss() float64 {
+	if x != nil {
+		return x.Loss
+	}
+	return 0
+}
+
+func (x *MtrHop) GetSnt() int64 {
+	if x != nil {
+		return x.Snt
+	}
+	return 0
+}
+
+func (x *MtrHop) GetSntFail() int64 {
+	if x != nil {
+		return x.SntFail
+	}
+	return 0
+}
+
+type MtrResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hops          []*MtrHop              `protobuf:"bytes,1,rep,name=hops,proto3" json:"hops,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MtrResult) Reset() {
+	*x = MtrResult{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MtrResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MtrResult) ProtoMessage() {}
+
+func (x *MtrResult) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MtrResult.ProtoReflect.Descriptor instead.
+func (*MtrResult) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MtrResult) GetHops() []*MtrHop {
+	if x != nil {
+		return x.Hops
+	}
+	return nil
+}
+
+type TcpResult struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Port           string                 `protobuf:"bytes,1,opt,name=port,proto3" json:"port,omitempty"`
+	SrcIp          string                 `protobuf:"bytes,2,opt,name=src_ip,json=srcIp,proto3" json:"src_ip,omitempty"`
+	ConnectionTime *durationpb.Duration   `protobuf:"bytes,3,opt,name=connection_time,json=connectionTime,proto3" json:"connection_time,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *TcpResult) Reset() {
+	*x = TcpResult{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TcpResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TcpResult) ProtoMessage() {}
+
+func (x *TcpResult) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TcpResult.ProtoReflect.Descriptor instead.
+func (*TcpResult) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *TcpResult) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
+func (x *TcpResult) GetSrcIp() string {
+	if x != nil {
+		return x.SrcIp
+	}
+	return ""
+}
+
+func (x *TcpResult) GetConnectionTime() *durationpb.Duration {
+	if x != nil {
+		return x.ConnectionTime
+	}
+	return nil
+}
+
+type HttpResult struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Status                int32                  `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	ContentLength         int64                  `protobuf:"varint,2,opt,name=content_length,json=contentLength,proto3" json:"content_length,omitempty"`
+	DnsLookup             *durationpb.Duration   `protobuf:"bytes,3,opt,name=dns_lookup,json=dnsLookup,proto3" json:"dns_lookup,omitempty"`
+	TcpConnection         *durationpb.Duration   `protobuf:"bytes,4,opt,name=tcp_connection,json=tcpConnection,proto3" json:"tcp_connection,omitempty"`
+	TlsHandshake          *durationpb.Duration   `protobuf:"bytes,5,opt,name=tls_handshake,json=tlsHandshake,proto3" json:"tls_handshake,omitempty"`
+	ServerProcessing      *durationpb.Duration   `protobuf:"bytes,6,opt,name=server_processing,json=serverProcessing,proto3" json:"server_processing,omitempty"`
+	ContentTransfer       *durationpb.Duration   `protobuf:"bytes,7,opt,name=content_transfer,json=contentTransfer,proto3" json:"content_transfer,omitempty"`
+	Total                 *durationpb.Duration   `protobuf:"bytes,8,opt,name=total,proto3" json:"total,omitempty"`
+	TlsVersion            string                 `protobuf:"bytes,9,opt,name=tls_version,json=tlsVersion,proto3" json:"tls_version,omitempty"`
+	TlsEarliestCertExpiry *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=tls_earliest_cert_expiry,json=tlsEarliestCertExpiry,proto3" json:"tls_earliest_cert_expiry,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *HttpResult) Reset() {
+	*x = HttpResult{}
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HttpResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HttpResult) ProtoMessage() {}
+
+func (x *HttpResult) ProtoReflect() protoreflect.Message {
+	mi := &file_network_exporter_v1_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HttpResult.ProtoReflect.Descriptor instead.
+func (*HttpResult) Descriptor() ([]byte, []int) {
+	return file_network_exporter_v1_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *HttpResult) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *HttpResult) GetContentLength() int64 {
+	if x != nil {
+		return x.ContentLength
+	}
+	return 0
+}
+
+func (x *HttpResult) GetDnsLookup() *durationpb.Duration {
+	if x != nil {
+		return x.DnsLookup
+	}
+	return nil
+}
+
+func (x *HttpResult) GetTcpConnection() *durationpb.Duration {
+	if x != nil {
+		return x.TcpConnection
+	}
+	return nil
+}
+
+func (x *HttpResult) GetTlsHandshake() *durationpb.Duration {
+	if x != nil {
+		return x.TlsHandshake
+	}
+	return nil
+}
+
+func (x *HttpResult) GetServerProcessing() *durationpb.Duration {
+	if x != nil {
+		return x.ServerProcessing
+	}
+	return nil
+}
+
+func (x *HttpResult) GetContentTransfer() *durationpb.Duration {
+	if x != nil {
+		return x.ContentTransfer
+	}
+	return nil
+}
+
+func (x *HttpResult) GetTotal() *durationpb.Duration {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *HttpResult) GetTlsVersion() string {
+	if x != nil {
+		return x.TlsVersion
+	}
+	return ""
+}
+
+func (x *HttpResult) GetTlsEarliestCertExpiry() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TlsEarliestCertExpiry
+	}
+	return nil
+}
+
+var File_network_exporter_v1_admin_proto protoreflect.FileDescriptor
+
+const file_network_exporter_v1_admin_proto_rawDesc = "" +
+	"\n" +
+	"\x1fnetwork_exporter/v1/admin.proto\x12\x13network_exporter.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb6\x03\n" +
+	"\x06Target\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x14\n" +
+	"\x05proxy\x18\x04 \x01(\tR\x05proxy\x12\x14\n" +
+	"\x05probe\x18\x05 \x03(\tR\x05probe\x12\x1b\n" +
+	"\tsource_ip\x18\x06 \x01(\tR\bsourceIp\x12?\n" +
+	"\x06labels\x18\a \x03(\v2'.network_exporter.v1.Target.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06source\x18\b \x01(\tR\x06source\x12\x1f\n" +
+	"\vsource_kind\x18\t \x01(\tR\n" +
+	"sourceKind\x129\n" +
+	"\n" +
+	"first_seen\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x127\n" +
+	"\tlast_seen\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"(\n" +
+	"\x12ListTargetsRequest\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\"L\n" +
+	"\x13ListTargetsResponse\x125\n" +
+	"\atargets\x18\x01 \x03(\v2\x1b.network_exporter.v1.TargetR\atargets\"G\n" +
+	"\x10AddTargetRequest\x123\n" +
+	"\x06target\x18\x01 \x01(\v2\x1b.network_exporter.v1.TargetR\x06target\"H\n" +
+	"\x11AddTargetResponse\x123\n" +
+	"\x06target\x18\x01 \x01(\v2\x1b.network_exporter.v1.TargetR\x06target\"=\n" +
+	"\x13RemoveTargetRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"\x16\n" +
+	"\x14RemoveTargetResponse\":\n" +
+	"\x10GetResultRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"J\n" +
+	"\x11GetResultResponse\x125\n" +
+	"\aresults\x18\x01 \x03(\v2\x1b.network_exporter.v1.ResultR\aresults\"A\n" +
+	"\x13WatchResultsRequest\x12\x14\n" +
+	"\x05names\x18\x01 \x03(\tR\x05names\x12\x14\n" +
+	"\x05types\x18\x02 \x03(\tR\x05types\"e\n" +
+	"\x14WatchResultsResponse\x123\n" +
+	"\x06result\x18\x01 \x01(\v2\x1b.network_exporter.v1.ResultR\x06result\x12\x18\n" +
+	"\adropped\x18\x02 \x01(\x04R\adropped\"\xf8\x03\n" +
+	"\x06Result\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
+	"\x04host\x18\x03 \x01(\tR\x04host\x12\x0e\n" +
+	"\x02ip\x18\x04 \x01(\tR\x02ip\x12?\n" +
+	"\x06labels\x18\x05 \x03(\v2'.network_exporter.v1.Result.LabelsEntryR\x06labels\x12.\n" +
+	"\x04time\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x18\n" +
+	"\asuccess\x18\a \x01(\bR\asuccess\x125\n" +
+	"\x04ping\x18\n" +
+	" \x01(\v2\x1f.network_exporter.v1.PingResultH\x00R\x04ping\x122\n" +
+	"\x03mtr\x18\v \x01(\v2\x1e.network_exporter.v1.MtrResultH\x00R\x03mtr\x122\n" +
+	"\x03tcp\x18\f \x01(\v2\x1e.network_exporter.v1.TcpResultH\x00R\x03tcp\x125\n" +
+	"\x04http\x18\r \x01(\v2\x1f.network_exporter.v1.HttpResultH\x00R\x04http\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x06\n" +
+	"\x04data\"\xfc\x03\n" +
+	"\n" +
+	"PingResult\x12\x1b\n" +
+	"\tdrop_rate\x18\x01 \x01(\x01R\bdropRate\x12-\n" +
+	"\x04best\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x04best\x12+\n" +
+	"\x03avg\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x03avg\x12/\n" +
+	"\x05worst\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x05worst\x12)\n" +
+	"\x02sd\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x02sd\x12+\n" +
+	"\x03usd\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03usd\x12+\n" +
+	"\x03csd\x18\a \x01(\v2\x19.google.protobuf.DurationR\x03csd\x12/\n" +
+	"\x05range\x18\b \x01(\v2\x19.google.protobuf.DurationR\x05range\x12\x1f\n" +
+	"\vsnt_summary\x18\t \x01(\x03R\n" +
+	"sntSummary\x12(\n" +
+	"\x10snt_fail_summary\x18\n" +
+	" \x01(\x03R\x0esntFailSummary\x12C\n" +
+	"\x10snt_time_summary\x18\v \x01(\v2\x19.google.protobuf.DurationR\x0esntTimeSummary\"\xf6\x02\n" +
+	"\x06MtrHop\x12\x10\n" +
+	"\x03ttl\x18\x01 \x01(\x05R\x03ttl\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12-\n" +
+	"\x04last\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x04last\x12-\n" +
+	"\x04best\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x04best\x12+\n" +
+	"\x03avg\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03avg\x12/\n" +
+	"\x05worst\x18\a \x01(\v2\x19.google.protobuf.DurationR\x05worst\x12)\n" +
+	"\x02sd\x18\b \x01(\v2\x19.google.protobuf.DurationR\x02sd\x12\x12\n" +
+	"\x04loss\x18\t \x01(\x01R\x04loss\x12\x10\n" +
+	"\x03snt\x18\n" +
+	" \x01(\x03R\x03snt\x12\x19\n" +
+	"\bsnt_fail\x18\v \x01(\x03R\asntFail\"<\n" +
+	"\tMtrResult\x12/\n" +
+	"\x04hops\x18\x01 \x03(\v2\x1b.network_exporter.v1.MtrHopR\x04hops\"z\n" +
+	"\tTcpResult\x12\x12\n" +
+	"\x04port\x18\x01 \x01(\tR\x04port\x12\x15\n" +
+	"\x06src_ip\x18\x02 \x01(\tR\x05srcIp\x12B\n" +
+	"\x0fconnection_time\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x0econnectionTime\"\xbc\x04\n" +
+	"\n" +
+	"HttpResult\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\x05R\x06status\x12%\n" +
+	"\x0econtent_length\x18\x02 \x01(\x03R\rcontentLength\x128\n" +
+	"\n" +
+	"dns_lookup\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\tdnsLookup\x12@\n" +
+	"\x0etcp_connection\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\rtcpConnection\x12>\n" +
+	"\rtls_handshake\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\ftlsHandshake\x12F\n" +
+	"\x11server_processing\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x10serverProcessing\x12D\n" +
+	"\x10content_transfer\x18\a \x01(\v2\x19.google.protobuf.DurationR\x0fcontentTransfer\x12/\n" +
+	"\x05total\x18\b \x01(\v2\x19.google.protobuf.DurationR\x05total\x12\x1f\n" +
+	"\vtls_version\x18\t \x01(\tR\n" +
+	"tlsVersion\x12S\n" +
+	"\x18tls_earliest_cert_expiry\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\x15tlsEarliestCertExpiry2\xf4\x03\n" +
+	"\fAdminService\x12`\n" +
+	"\vListTargets\x12'.network_exporter.v1.ListTargetsRequest\x1a(.network_exporter.v1.ListTargetsResponse\x12Z\n" +
+	"\tAddTarget\x12%.network_exporter.v1.AddTargetRequest\x1a&.network_exporter.v1.AddTargetResponse\x12c\n" +
+	"\fRemoveTarget\x12(.network_exporter.v1.RemoveTargetRequest\x1a).network_exporter.v1.RemoveTargetResponse\x12Z\n" +
+	"\tGetResult\x12%.network_exporter.v1.GetResultRequest\x1a&.network_exporter.v1.GetResultResponse\x12e\n" +
+	"\fWatchResults\x12(.network_exporter.v1.WatchResultsRequest\x1a).network_exporter.v1.WatchResultsResponse0\x01B1Z/github.com/syepes/network_exporter/api/v1;apiv1b\x06proto3"
+
+var (
+	file_network_exporter_v1_admin_proto_rawDescOnce sync.Once
+	file_network_exporter_v1_admin_proto_rawDescData []byte
+)
+
+func file_network_exporter_v1_admin_proto_rawDescGZIP() []byte {
+	file_network_exporter_v1_admin_proto_rawDescOnce.Do(func() {
+		file_network_exporter_v1_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_network_exporter_v1_admin_proto_rawDesc), len(file_network_exporter_v1_admin_proto_rawDesc)))
+	})
+	return file_network_exporter_v1_admin_proto_rawDescData
+}
+
+var file_network_exporter_v1_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_network_exporter_v1_admin_proto_goTypes = []any{
+	(*Target)(nil),                // 0: network_exporter.v1.Target
+	(*ListTargetsRequest)(nil),    // 1: network_exporter.v1.ListTargetsRequest
+	(*ListTargetsResponse)(nil),   // 2: network_exporter.v1.ListTargetsResponse
+	(*AddTargetRequest)(nil),      // 3: network_exporter.v1.AddTargetRequest
+	(*AddTargetResponse)(nil),     // 4: network_exporter.v1.AddTargetResponse
+	(*RemoveTargetRequest)(nil),   // 5: network_exporter.v1.RemoveTargetRequest
+	(*RemoveTargetResponse)(nil),  // 6: network_exporter.v1.RemoveTargetResponse
+	(*GetResultRequest)(nil),      // 7: network_exporter.v1.GetResultRequest
+	(*GetResultResponse)(nil),     // 8: network_exporter.v1.GetResultResponse
+	(*WatchResultsRequest)(nil),   // 9: network_exporter.v1.WatchResultsRequest
+	(*WatchResultsResponse)(nil),  // 10: network_exporter.v1.WatchResultsResponse
+	(*Result)(nil),                // 11: network_exporter.v1.Result
+	(*PingResult)(nil),            // 12: network_exporter.v1.PingResult
+	(*MtrHop)(nil),                // 13: network_exporter.v1.MtrHop
+	(*MtrResult)(nil),             // 14: network_exporter.v1.MtrResult
+	(*TcpResult)(nil),             // 15: network_exporter.v1.TcpResult
+	(*HttpResult)(nil),            // 16: network_exporter.v1.HttpResult
+	nil,                           // 17: network_exporter.v1.Target.LabelsEntry
+	nil,                           // 18: network_exporter.v1.Result.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 19: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 20: google.protobuf.Duration
+}
+var file_network_exporter_v1_admin_proto_depIdxs = []int32{
+	17, // 0: network_exporter.v1.Target.labels:type_name -> network_exporter.v1.Target.LabelsEntry
+	19, // 1: network_exporter.v1.Target.first_seen:type_name -> google.protobuf.Timestamp
+	19, // 2: network_exporter.v1.Target.last_seen:type_name -> google.protobuf.Timestamp
+	0,  // 3: network_exporter.v1.ListTargetsResponse.targets:type_name -> network_exporter.v1.Target
+	0,  // 4: network_exporter.v1.AddTargetRequest.target:type_name -> network_exporter.v1.Target
+	0,  // 5: network_exporter.v1.AddTargetResponse.target:type_name -> network_exporter.v1.Target
+	11, // 6: network_exporter.v1.GetResultResponse.results:type_name -> network_exporter.v1.Result
+	11, // 7: network_exporter.v1.WatchResultsResponse.result:type_name -> network_exporter.v1.Result
+	18, // 8: network_exporter.v1.Result.labels:type_name -> network_exporter.v1.Result.LabelsEntry
+	19, // 9: network_exporter.v1.Result.time:type_name -> google.protobuf.Timestamp
+	12, // 10: network_exporter.v1.Result.ping:type_name -> network_exporter.v1.PingResult
+	14, // 11: network_exporter.v1.Result.mtr:type_name -> network_exporter.v1.MtrResult
+	15, // 12: network_exporter.v1.Result.tcp:type_name -> network_exporter.v1.TcpResult
+	16, // 13: network_exporter.v1.Result.http:type_name -> network_exporter.v1.HttpResult
+	20, // 14: network_exporter.v1.PingResult.best:type_name -> google.protobuf.Duration
+	20, // 15: network_exporter.v1.PingResult.avg:type_name -> google.protobuf.Duration
+	20, // 16: network_exporter.v1.PingResult.worst:type_name -> google.protobuf.Duration
+	20, // 17: network_exporter.v1.PingResult.sd:type_name -> google.protobuf.Duration
+	20, // 18: network_exporter.v1.PingResult.usd:type_name -> google.protobuf.Duration
+	20, // 19: network_exporter.v1.PingResult.csd:type_name -> google.protobuf.Duration
+	20, // 20: network_exporter.v1.PingResult.range:type_name -> google.protobuf.Duration
+	20, // 21: network_exporter.v1.PingResult.snt_time_summary:type_name -> google.protobuf.Duration
+	20, // 22: network_exporter.v1.MtrHop.last:type_name -> google.protobuf.Duration
+	20, // 23: network_exporter.v1.MtrHop.best:type_name -> google.protobuf.Duration
+	20, // 24: network_exporter.v1.MtrHop.avg:type_name -> google.protobuf.Duration
+	20, // 25: network_exporter.v1.MtrHop.worst:type_name -> google.protobuf.Duration
+	20, // 26: network_exporter.v1.MtrHop.sd:type_name -> google.protobuf.Duration
+	13, // 27: network_exporter.v1.MtrResult.hops:type_name -> network_exporter.v1.MtrHop
+	20, // 28: network_exporter.v1.TcpResult.connection_time:type_name -> google.protobuf.Duration
+	20, // 29: network_exporter.v1.HttpResult.dns_lookup:type_name -> google.protobuf.Duration
+	20, // 30: network_exporter.v1.HttpResult.tcp_connection:type_name -> google.protobuf.Duration
+	20, // 31: network_exporter.v1.HttpResult.tls_handshake:type_name -> google.protobuf.Duration
+	20, // 32: network_exporter.v1.HttpResult.server_processing:type_name -> google.protobuf.Duration
+	20, // 33: network_exporter.v1.HttpResult.content_transfer:type_name -> google.protobuf.Duration
+	20, // 34: network_exporter.v1.HttpResult.total:type_name -> google.protobuf.Duration
+	19, // 35: network_exporter.v1.HttpResult.tls_earliest_cert_expiry:type_name -> google.protobuf.Timestamp
+	1,  // 36: network_exporter.v1.AdminService.ListTargets:input_type -> network_exporter.v1.ListTargetsRequest
+	3,  // 37: network_exporter.v1.AdminService.AddTarget:input_type -> network_exporter.v1.AddTargetRequest
+	5,  // 38: network_exporter.v1.AdminService.RemoveTarget:input_type -> network_exporter.v1.RemoveTargetRequest
+	7,  // 39: network_exporter.v1.AdminService.GetResult:input_type -> network_exporter.v1.GetResultRequest
+	9,  // 40: network_exporter.v1.AdminService.WatchResults:input_type -> network_exporter.v1.WatchResultsRequest
+	2,  // 41: network_exporter.v1.AdminService.ListTargets:output_type -> network_exporter.v1.ListTargetsResponse
+	4,  // 42: network_exporter.v1.AdminService.AddTarget:output_type -> network_exporter.v1.AddTargetResponse
+	6,  // 43: network_exporter.v1.AdminService.RemoveTarget:output_type -> network_exporter.v1.RemoveTargetResponse
+	8,  // 44: network_exporter.v1.AdminService.GetResult:output_type -> network_exporter.v1.GetResultResponse
+	10, // 45: network_exporter.v1.AdminService.WatchResults:output_type -> network_exporter.v1.WatchResultsResponse
+	41, // [41:46] is the sub-list for method output_type
+	36, // [36:41] is the sub-list for method input_type
+	36, // [36:36] is the sub-list for extension type_name
+	36, // [36:36] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
+}
+
+func init() { file_network_exporter_v1_admin_proto_init() }
+func file_network_exporter_v1_admin_proto_init() {
+	if File_network_exporter_v1_admin_proto != nil {
+		return
+	}
+	file_network_exporter_v1_admin_proto_msgTypes[11].OneofWrappers = []any{
+		(*Result_Ping)(nil),
+		(*Result_Mtr)(nil),
+		(*Result_Tcp)(nil),
+		(*Result_Http)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_network_exporter_v1_admin_proto_rawDesc), len(file_network_exporter_v1_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_network_exporter_v1_admin_proto_goTypes,
+		DependencyIndexes: file_network_exporter_v1_admin_proto_depIdxs,
+		MessageInfos:      file_network_exporter_v1_admin_proto_msgTypes,
+	}.Build()
+	File_network_exporter_v1_admin_proto = out.File
+	file_network_exporter_v1_admin_proto_goTypes = nil
+	file_network_exporter_v1_admin_proto_depIdxs = nil
+}
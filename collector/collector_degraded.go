@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/target"
+)
+
+var targetDegradedDesc = prometheus.NewDesc("network_target_degraded", "Target probed on the adaptive degraded interval after a round above the trigger loss", []string{"type", "name"}, nil)
+
+// Degraded prom
+type Degraded struct{}
+
+// Describe prom
+func (p *Degraded) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetDegradedDesc
+}
+
+// Collect prom
+func (p *Degraded) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range target.DegradedStats() {
+		degraded := 0.0
+		if s.Degraded {
+			degraded = 1
+		}
+		ch <- prometheus.MustNewConstMetric(targetDegradedDesc, prometheus.GaugeValue, degraded, s.Type, s.Name)
+	}
+}
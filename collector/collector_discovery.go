@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/discovery"
+)
+
+var (
+	discoveryLabelNames  = []string{"source"}
+	discoveryTargetsDesc = prometheus.NewDesc("discovery_targets", "Number of discovered targets", discoveryLabelNames, nil)
+	discoveryAddedDesc   = prometheus.NewDesc("discovery_targets_added_total", "Discovered targets added total", discoveryLabelNames, nil)
+	discoveryRemovedDesc = prometheus.NewDesc("discovery_targets_removed_total", "Discovered targets removed total", discoveryLabelNames, nil)
+	discoveryDroppedDesc = prometheus.NewDesc("discovery_targets_dropped", "Discovered targets dropped by max_targets", discoveryLabelNames, nil)
+	discoveryUpDesc      = prometheus.NewDesc("discovery_up", "Discovery source last refresh status", discoveryLabelNames, nil)
+	discoveryLastDesc    = prometheus.NewDesc("discovery_last_success_timestamp_seconds", "Discovery source last successful refresh", discoveryLabelNames, nil)
+	discoveryKeyErrDesc  = prometheus.NewDesc("discovery_key_error", "Discovery source key holding an invalid target", []string{"source", "key"}, nil)
+	discoveryStaleDesc   = prometheus.NewDesc("discovery_staleness_seconds", "Seconds since the last successful refresh of the discovery source", discoveryLabelNames, nil)
+	discoveryFailedDesc  = prometheus.NewDesc("network_sd_refresh_failures_total", "Discovery source failed refreshes total", discoveryLabelNames, nil)
+)
+
+// Discovery prom
+type Discovery struct {
+	Manager *discovery.Manager
+}
+
+// Describe prom
+func (p *Discovery) Describe(ch chan<- *prometheus.Desc) {
+	ch <- discoveryTargetsDesc
+	ch <- discoveryAddedDesc
+	ch <- discoveryRemovedDesc
+	ch <- discoveryDroppedDesc
+	ch <- discoveryUpDesc
+	ch <- discoveryLastDesc
+	ch <- discoveryStaleDesc
+	ch <- discoveryFailedDesc
+	ch <- discoveryKeyErrDesc
+}
+
+// Collect prom
+func (p *Discovery) Collect(ch chan<- prometheus.Metric) {
+	for source, stats := range p.Manager.ExportStats() {
+		ch <- prometheus.MustNewConstMetric(discoveryTargetsDesc, prometheus.GaugeValue, float64(stats.Targets), source)
+		ch <- prometheus.MustNewConstMetric(discoveryAddedDesc, prometheus.CounterValue, float64(stats.Added), source)
+		ch <- prometheus.MustNewConstMetric(discoveryRemovedDesc, prometheus.CounterValue, float64(stats.Removed), source)
+		ch <- prometheus.MustNewConstMetric(discoveryDroppedDesc, prometheus.GaugeValue, float64(stats.Dropped), source)
+
+		up := 0.0
+		if stats.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(discoveryUpDesc, prometheus.GaugeValue, up, source)
+		ch <- prometheus.MustNewConstMetric(discoveryFailedDesc, prometheus.CounterValue, float64(stats.Failures), source)
+		if !stats.LastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(discoveryLastDesc, prometheus.GaugeValue, float64(stats.LastSuccess.Unix()), source)
+			ch <- prometheus.MustNewConstMetric(discoveryStaleDesc, prometheus.GaugeValue, time.Since(stats.LastSuccess).Seconds(), source)
+		}
+		for _, key := range stats.KeyErrors {
+			ch <- prometheus.MustNewConstMetric(discoveryKeyErrDesc, prometheus.GaugeValue, 1, source, key)
+		}
+	}
+}
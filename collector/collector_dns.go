@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
+)
+
+var (
+	dnsFallbacksDesc = prometheus.NewDesc("network_exporter_dns_fallbacks_total", "Queries sent to the system nameservers after a failure of all the conf.nameserver servers", nil, nil)
+	dnsAnswersDesc   = prometheus.NewDesc("network_exporter_dns_answers_total", "Queries answered by the conf.nameserver server", []string{"nameserver"}, nil)
+)
+
+// DNS prom
+type DNS struct {
+	Resolver *config.Resolver
+}
+
+// Describe prom
+func (p *DNS) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dnsFallbacksDesc
+	ch <- dnsAnswersDesc
+}
+
+// Collect prom
+func (p *DNS) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(dnsFallbacksDesc, prometheus.CounterValue, float64(p.Resolver.Fallbacks()))
+	for nameserver, n := range p.Resolver.Answers() {
+		ch <- prometheus.MustNewConstMetric(dnsAnswersDesc, prometheus.CounterValue, float64(n), nameserver)
+	}
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/pkg/dns"
+)
+
+var (
+	dnsLabelNames        = []string{"name", "target", "target_ip", "source_ip", "port", "query_name", "query_type"}
+	dnsTimeDesc          = prometheus.NewDesc("dns_query_seconds", "Query time in seconds", dnsLabelNames, nil)
+	dnsSuccessDesc       = prometheus.NewDesc("dns_query_success", "Query answered with NOERROR and the expected answers", dnsLabelNames, nil)
+	dnsRcodeDesc         = prometheus.NewDesc("dns_query_rcode", "Response code of the response, -1 without response", dnsLabelNames, nil)
+	dnsAnswerRecordsDesc = prometheus.NewDesc("dns_query_answers", "Records of the answer section of the response", dnsLabelNames, nil)
+	dnsTruncatedDesc     = prometheus.NewDesc("dns_query_truncated", "Response truncated", dnsLabelNames, nil)
+	dnsErrorDesc         = prometheus.NewDesc("dns_query_error_reason", "Query phase of the failure of the last probe", append(dnsLabelNames, "reason"), nil)
+	dnsTargetsDesc       = prometheus.NewDesc("dns_targets", "Number of active targets", nil, nil)
+	dnsStateDesc         = prometheus.NewDesc("dns_up", "Exporter state", nil, nil)
+	dnsMutex             = &sync.Mutex{}
+	// Descriptor cache for custom labels
+	dnsDescCache      = make(map[string]*dnsDescriptorSet)
+	dnsDescCacheMutex sync.RWMutex
+)
+
+// dnsDescriptorSet holds all descriptors for a specific label set
+type dnsDescriptorSet struct {
+	time      *prometheus.Desc
+	success   *prometheus.Desc
+	rcode     *prometheus.Desc
+	answers   *prometheus.Desc
+	truncated *prometheus.Desc
+	error     *prometheus.Desc
+}
+
+// getDNSDescriptors returns cached or creates new descriptors for a label set
+func getDNSDescriptors(labels prometheus.Labels) *dnsDescriptorSet {
+	cacheKey := fmt.Sprintf("%v", labels)
+
+	dnsDescCacheMutex.RLock()
+	if descSet, exists := dnsDescCache[cacheKey]; exists {
+		dnsDescCacheMutex.RUnlock()
+		return descSet
+	}
+	dnsDescCacheMutex.RUnlock()
+
+	dnsDescCacheMutex.Lock()
+	defer dnsDescCacheMutex.Unlock()
+
+	if descSet, exists := dnsDescCache[cacheKey]; exists {
+		return descSet
+	}
+
+	descSet := &dnsDescriptorSet{
+		time:      prometheus.NewDesc("dns_query_seconds", "Query time in seconds", dnsLabelNames, labels),
+		success:   prometheus.NewDesc("dns_query_success", "Query answered with NOERROR and the expected answers", dnsLabelNames, labels),
+		rcode:     prometheus.NewDesc("dns_query_rcode", "Response code of the response, -1 without response", dnsLabelNames, labels),
+		answers:   prometheus.NewDesc("dns_query_answers", "Records of the answer section of the response", dnsLabelNames, labels),
+		truncated: prometheus.NewDesc("dns_query_truncated", "Response truncated", dnsLabelNames, labels),
+		error:     prometheus.NewDesc("dns_query_error_reason", "Query phase of the failure of the last probe", append(dnsLabelNames, "reason"), labels),
+	}
+	dnsDescCache[cacheKey] = descSet
+	return descSet
+}
+
+// DNSQuery prom
+type DNSQuery struct {
+	Monitor *monitor.DNS
+	metrics map[string]*dns.DNSReturn
+	labels  map[string]map[string]string
+}
+
+// Describe prom
+func (p *DNSQuery) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dnsTimeDesc
+	ch <- dnsSuccessDesc
+	ch <- dnsRcodeDesc
+	ch <- dnsAnswerRecordsDesc
+	ch <- dnsTruncatedDesc
+	ch <- dnsErrorDesc
+	ch <- dnsTargetsDesc
+	ch <- dnsStateDesc
+}
+
+// Collect prom
+func (p *DNSQuery) Collect(ch chan<- prometheus.Metric) {
+	dnsMutex.Lock()
+	defer dnsMutex.Unlock()
+
+	if m := p.Monitor.ExportMetrics(); len(m) > 0 {
+		p.metrics = m
+	}
+
+	if l := p.Monitor.ExportLabels(); len(l) > 0 {
+		p.labels = l
+	}
+
+	if len(p.metrics) > 0 {
+		ch <- prometheus.MustNewConstMetric(dnsStateDesc, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(dnsStateDesc, prometheus.GaugeValue, 0)
+	}
+
+	targets := []string{}
+	for target, metric := range p.metrics {
+		targets = append(targets, target)
+		l := []string{strings.TrimSuffix(target, " "+metric.DestIp), metric.DestAddr, metric.DestIp, metric.SrcIp, metric.DestPort, metric.QueryName, metric.QueryType}
+		l2 := prometheus.Labels(p.labels[target])
+
+		// Get cached descriptors for this label set
+		descs := getDNSDescriptors(l2)
+
+		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.QueryTime.Seconds(), l...)
+		if metric.Success {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.rcode, prometheus.GaugeValue, float64(metric.Rcode), l...)
+		ch <- prometheus.MustNewConstMetric(descs.answers, prometheus.GaugeValue, float64(metric.Answers), l...)
+		if metric.Truncated {
+			ch <- prometheus.MustNewConstMetric(descs.truncated, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.truncated, prometheus.GaugeValue, 0, l...)
+		}
+		if metric.ErrorReason != "" {
+			ch <- prometheus.MustNewConstMetric(descs.error, prometheus.GaugeValue, 1, append(l, metric.ErrorReason)...)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(dnsTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
+}
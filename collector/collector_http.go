@@ -1,23 +1,41 @@
 package collector
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
 	"github.com/syepes/network_exporter/monitor"
 	"github.com/syepes/network_exporter/pkg/http"
+	"github.com/syepes/network_exporter/results"
 )
 
 var (
-	httpLabelNames  = []string{"name", "target"}
-	httpTimeDesc    = prometheus.NewDesc("http_get_seconds", "HTTP Get Drill Down time in seconds", append(httpLabelNames, "type"), nil)
-	httpSizeDesc    = prometheus.NewDesc("http_get_content_bytes", "HTTP Get Content Size in bytes", httpLabelNames, nil)
-	httpStatusDesc  = prometheus.NewDesc("http_get_status", "HTTP Get Status", httpLabelNames, nil)
-	httpTargetsDesc = prometheus.NewDesc("http_get_targets", "Number of active targets", nil, nil)
-	httpStateDesc   = prometheus.NewDesc("http_get_up", "Exporter state", nil, nil)
-	httpMutex       = &sync.Mutex{}
+	httpLabelNames   = []string{"name", "target", "http_version"}
+	httpTimeDesc     = prometheus.NewDesc("http_get_seconds", "HTTP Get Drill Down time in seconds", append(httpLabelNames, "type"), nil)
+	httpSizeDesc     = prometheus.NewDesc("http_get_content_bytes", "HTTP Get bytes of the body read, decompressed", httpLabelNames, nil)
+	httpWireDesc     = prometheus.NewDesc("http_get_content_wire_bytes", "HTTP Get bytes of the compressed body read on the wire", append(httpLabelNames, "encoding"), nil)
+	httpTruncDesc    = prometheus.NewDesc("http_get_content_truncated", "HTTP Get body larger than the read limit", httpLabelNames, nil)
+	httpXferDesc     = prometheus.NewDesc("http_get_transfer_seconds", "HTTP Get time to the last byte of the body read in seconds", httpLabelNames, nil)
+	httpMatchDesc    = prometheus.NewDesc("http_get_content_match", "HTTP Get body read has the expected SHA-256 digest", httpLabelNames, nil)
+	httpStatusDesc   = prometheus.NewDesc("http_get_status", "HTTP Get Status", httpLabelNames, nil)
+	httpSuccessDesc  = prometheus.NewDesc("http_get_success", "HTTP Get final status is one of the valid status codes and the body passed the patterns", httpLabelNames, nil)
+	httpBodyDesc     = prometheus.NewDesc("http_get_body_matched_bytes", "HTTP Get bytes of the body evaluated by the patterns", httpLabelNames, nil)
+	httpErrorDesc    = prometheus.NewDesc("http_get_error_reason", "HTTP Get phase of the failure of the last probe", append(httpLabelNames, "reason"), nil)
+	httpExpiryDesc   = prometheus.NewDesc("http_get_ssl_earliest_cert_expiry", "HTTP Get earliest expiry of the certificates of the server in unix seconds", httpLabelNames, nil)
+	httpVerifyDesc   = prometheus.NewDesc("http_get_ssl_verified", "HTTP Get certificate chain of the server verified", httpLabelNames, nil)
+	httpTLSDesc      = prometheus.NewDesc("http_get_tls_info", "HTTP Get negotiated TLS version and cipher", append(httpLabelNames, "version", "cipher"), nil)
+	httpReusedDesc   = prometheus.NewDesc("http_get_connection_reused", "HTTP Get request sent on a connection kept open by a previous probe", httpLabelNames, nil)
+	httpRedirectDesc = prometheus.NewDesc("http_get_redirects", "HTTP Get redirects followed by the last probe", httpLabelNames, nil)
+	httpFinalDesc    = prometheus.NewDesc("http_get_final_url_info", "HTTP Get scheme and host of the URL of the final response", append(httpLabelNames, "final_scheme", "final_host"), nil)
+	httpTargetsDesc  = prometheus.NewDesc("http_get_targets", "Number of active targets", nil, nil)
+	httpStateDesc    = prometheus.NewDesc("http_get_up", "Exporter state", nil, nil)
+	httpPhaseDesc    = prometheus.NewDesc("http_get_duration_seconds", "HTTP Get drill down time of every probe in seconds", append(httpLabelNames, "type"), nil)
+	httpMutex        = &sync.Mutex{}
 	// Descriptor cache for custom labels
 	httpDescCache      = make(map[string]*httpDescriptorSet)
 	httpDescCacheMutex sync.RWMutex
@@ -25,9 +43,22 @@ var (
 
 // httpDescriptorSet holds all descriptors for a specific label set
 type httpDescriptorSet struct {
-	time   *prometheus.Desc
-	size   *prometheus.Desc
-	status *prometheus.Desc
+	time     *prometheus.Desc
+	size     *prometheus.Desc
+	wire     *prometheus.Desc
+	trunc    *prometheus.Desc
+	xfer     *prometheus.Desc
+	match    *prometheus.Desc
+	status   *prometheus.Desc
+	success  *prometheus.Desc
+	body     *prometheus.Desc
+	error    *prometheus.Desc
+	expiry   *prometheus.Desc
+	verify   *prometheus.Desc
+	tls      *prometheus.Desc
+	reused   *prometheus.Desc
+	redirect *prometheus.Desc
+	final    *prometheus.Desc
 }
 
 // getHTTPDescriptors returns cached or creates new descriptors for a label set
@@ -49,9 +80,22 @@ func getHTTPDescriptors(labels prometheus.Labels) *httpDescriptorSet {
 	}
 
 	descSet := &httpDescriptorSet{
-		time:   prometheus.NewDesc("http_get_seconds", "HTTP Get Drill Down time in seconds", append(httpLabelNames, "type"), labels),
-		size:   prometheus.NewDesc("http_get_content_bytes", "HTTP Get Content Size in bytes", httpLabelNames, labels),
-		status: prometheus.NewDesc("http_get_status", "HTTP Get Status", httpLabelNames, labels),
+		time:     prometheus.NewDesc("http_get_seconds", "HTTP Get Drill Down time in seconds", append(httpLabelNames, "type"), labels),
+		size:     prometheus.NewDesc("http_get_content_bytes", "HTTP Get bytes of the body read, decompressed", httpLabelNames, labels),
+		wire:     prometheus.NewDesc("http_get_content_wire_bytes", "HTTP Get bytes of the compressed body read on the wire", append(httpLabelNames, "encoding"), labels),
+		trunc:    prometheus.NewDesc("http_get_content_truncated", "HTTP Get body larger than the read limit", httpLabelNames, labels),
+		xfer:     prometheus.NewDesc("http_get_transfer_seconds", "HTTP Get time to the last byte of the body read in seconds", httpLabelNames, labels),
+		match:    prometheus.NewDesc("http_get_content_match", "HTTP Get body read has the expected SHA-256 digest", httpLabelNames, labels),
+		status:   prometheus.NewDesc("http_get_status", "HTTP Get Status", httpLabelNames, labels),
+		success:  prometheus.NewDesc("http_get_success", "HTTP Get final status is one of the valid status codes and the body passed the patterns", httpLabelNames, labels),
+		body:     prometheus.NewDesc("http_get_body_matched_bytes", "HTTP Get bytes of the body evaluated by the patterns", httpLabelNames, labels),
+		error:    prometheus.NewDesc("http_get_error_reason", "HTTP Get phase of the failure of the last probe", append(httpLabelNames, "reason"), labels),
+		expiry:   prometheus.NewDesc("http_get_ssl_earliest_cert_expiry", "HTTP Get earliest expiry of the certificates of the server in unix seconds", httpLabelNames, labels),
+		verify:   prometheus.NewDesc("http_get_ssl_verified", "HTTP Get certificate chain of the server verified", httpLabelNames, labels),
+		tls:      prometheus.NewDesc("http_get_tls_info", "HTTP Get negotiated TLS version and cipher", append(httpLabelNames, "version", "cipher"), labels),
+		reused:   prometheus.NewDesc("http_get_connection_reused", "HTTP Get request sent on a connection kept open by a previous probe", httpLabelNames, labels),
+		redirect: prometheus.NewDesc("http_get_redirects", "HTTP Get redirects followed by the last probe", httpLabelNames, labels),
+		final:    prometheus.NewDesc("http_get_final_url_info", "HTTP Get scheme and host of the URL of the final response", append(httpLabelNames, "final_scheme", "final_host"), labels),
 	}
 	httpDescCache[cacheKey] = descSet
 	return descSet
@@ -60,17 +104,156 @@ func getHTTPDescriptors(labels prometheus.Labels) *httpDescriptorSet {
 // HTTPGet prom
 type HTTPGet struct {
 	Monitor *monitor.HTTPGet
+	// SC and Hub feed the phase histograms of http_get.histograms, see Run
+	SC      *config.SafeConfig
+	Hub     *results.Hub
 	metrics map[string]*http.HTTPReturn
 	labels  map[string]map[string]string
+
+	// hist histograms of each label set, series the label values observed for each target
+	hist      map[string]*prometheus.HistogramVec
+	series    map[string]httpSeries
+	buckets   []float64
+	histMutex sync.Mutex
+}
+
+// httpSeries label set and label values of the histograms of a target
+type httpSeries struct {
+	key     string
+	target  string
+	version string
 }
 
 // Describe prom
 func (p *HTTPGet) Describe(ch chan<- *prometheus.Desc) {
 	ch <- httpTimeDesc
 	ch <- httpSizeDesc
+	ch <- httpWireDesc
+	ch <- httpTruncDesc
+	ch <- httpXferDesc
+	ch <- httpMatchDesc
 	ch <- httpStatusDesc
+	ch <- httpSuccessDesc
+	ch <- httpBodyDesc
+	ch <- httpErrorDesc
+	ch <- httpExpiryDesc
+	ch <- httpVerifyDesc
+	ch <- httpTLSDesc
+	ch <- httpReusedDesc
+	ch <- httpRedirectDesc
+	ch <- httpFinalDesc
 	ch <- httpTargetsDesc
 	ch <- httpStateDesc
+	ch <- httpPhaseDesc
+}
+
+// Run observes the phases of every published probe in the histograms while http_get.histograms is set, until the context is canceled
+func (p *HTTPGet) Run(ctx context.Context) {
+	sub := p.Hub.Subscribe(results.DefaultBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-sub.C:
+			if m, ok := r.Data.(*http.HTTPReturn); ok && r.Type == "HTTPGet" {
+				p.observe(r, m)
+			}
+		}
+	}
+}
+
+// histograms returns the buckets of the histograms, nil when they are disabled
+func (p *HTTPGet) histograms() []float64 {
+	p.SC.RLock()
+	defer p.SC.RUnlock()
+
+	if !p.SC.Cfg.HTTPGet.Histograms {
+		return nil
+	}
+	if b := p.SC.Cfg.HistogramBuckets(p.SC.Cfg.HTTPGet.Buckets); b != nil {
+		return b
+	}
+	return prometheus.DefBuckets
+}
+
+// observe adds the phases of a probe to the histograms of its target
+func (p *HTTPGet) observe(r results.Result, m *http.HTTPReturn) {
+	buckets := p.histograms()
+
+	p.histMutex.Lock()
+	defer p.histMutex.Unlock()
+
+	p.resetHistograms(buckets)
+	if buckets == nil {
+		return
+	}
+
+	labels := prometheus.Labels(r.Labels)
+	s := httpSeries{key: fmt.Sprintf("%v", labels), target: m.DestAddr, version: m.HTTPVersion}
+	if old, ok := p.series[r.Name]; ok && old != s {
+		p.hist[old.key].DeletePartialMatch(prometheus.Labels{"name": r.Name})
+	}
+	p.series[r.Name] = s
+
+	h, ok := p.hist[s.key]
+	if !ok {
+		h = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "http_get_duration_seconds",
+			Help:        "HTTP Get drill down time of every probe in seconds",
+			Buckets:     buckets,
+			ConstLabels: labels,
+		}, append(httpLabelNames, "type"))
+		p.hist[s.key] = h
+	}
+
+	l := []string{r.Name, m.DestAddr, m.HTTPVersion}
+	// The reused connections skip the DNS lookup, connection and TLS handshake
+	if m.DNSLookup > 0 {
+		h.WithLabelValues(append(l, "DNSLookup")...).Observe(m.DNSLookup.Seconds())
+	}
+	if m.TCPConnection > 0 {
+		h.WithLabelValues(append(l, "TCPConnection")...).Observe(m.TCPConnection.Seconds())
+	}
+	if m.TLSHandshake > 0 {
+		h.WithLabelValues(append(l, "TLSHandshake")...).Observe(m.TLSHandshake.Seconds())
+	}
+	// Only the probes that got a response have a first byte
+	if m.Success {
+		h.WithLabelValues(append(l, "TTFB")...).Observe((m.Total - m.ContentTransfer).Seconds())
+	}
+	h.WithLabelValues(append(l, "Total")...).Observe(m.Total.Seconds())
+}
+
+// resetHistograms recreates the histograms when they are disabled or their buckets change on reload, the caller holds histMutex
+// The vectors are collected by the collector instead of being registered, replacing them never conflicts with the registry
+func (p *HTTPGet) resetHistograms(buckets []float64) {
+	if buckets == nil || !slices.Equal(buckets, p.buckets) {
+		p.hist = make(map[string]*prometheus.HistogramVec)
+		p.series = make(map[string]httpSeries)
+		p.buckets = buckets
+	}
+}
+
+// collectHistograms exports the histograms of the active targets, the series of the removed targets are deleted
+func (p *HTTPGet) collectHistograms(ch chan<- prometheus.Metric, active map[string]*http.HTTPReturn) {
+	buckets := p.histograms()
+
+	p.histMutex.Lock()
+	defer p.histMutex.Unlock()
+
+	// The histograms of the previous buckets are dropped right after the reload instead of on the next probe
+	p.resetHistograms(buckets)
+	for name, s := range p.series {
+		if _, ok := active[name]; !ok {
+			p.hist[s.key].DeletePartialMatch(prometheus.Labels{"name": name})
+			delete(p.series, name)
+		}
+	}
+	for _, h := range p.hist {
+		h.Collect(ch)
+	}
 }
 
 // Collect prom
@@ -78,9 +261,11 @@ func (p *HTTPGet) Collect(ch chan<- prometheus.Metric) {
 	httpMutex.Lock()
 	defer httpMutex.Unlock()
 
-	if m := p.Monitor.ExportMetrics(); len(m) > 0 {
+	m := p.Monitor.ExportMetrics()
+	if len(m) > 0 {
 		p.metrics = m
 	}
+	p.collectHistograms(ch, m)
 
 	if l := p.Monitor.ExportLabels(); len(l) > 0 {
 		p.labels = l
@@ -96,7 +281,7 @@ func (p *HTTPGet) Collect(ch chan<- prometheus.Metric) {
 	for target, metric := range p.metrics {
 		targets = append(targets, target)
 		l := strings.SplitN(target, " ", 2)
-		l = append(l, metric.DestAddr)
+		l = append(l, metric.DestAddr, metric.HTTPVersion)
 		l2 := prometheus.Labels(p.labels[target])
 
 		// Get cached descriptors for this label set
@@ -107,17 +292,76 @@ func (p *HTTPGet) Collect(ch chan<- prometheus.Metric) {
 		} else {
 			ch <- prometheus.MustNewConstMetric(descs.status, prometheus.GaugeValue, 0, l...)
 		}
+		if metric.Succeeded() {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		if metric.ErrorReason != "" {
+			ch <- prometheus.MustNewConstMetric(descs.error, prometheus.GaugeValue, 1, append(l, metric.ErrorReason)...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.redirect, prometheus.GaugeValue, float64(metric.Redirects), l...)
+		if metric.FinalHost != "" {
+			ch <- prometheus.MustNewConstMetric(descs.final, prometheus.GaugeValue, 1, append(l, metric.FinalScheme, metric.FinalHost)...)
+		}
+		if metric.ConnectionReused {
+			ch <- prometheus.MustNewConstMetric(descs.reused, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.reused, prometheus.GaugeValue, 0, l...)
+		}
+
+		// The certificates of the rejected handshakes are reported without TLS version
+		if metric.TLSVersion != "" {
+			ch <- prometheus.MustNewConstMetric(descs.tls, prometheus.GaugeValue, 1, append(l, metric.TLSVersion, metric.TLSCipher)...)
+		}
+		if metric.TLSVersion != "" || !metric.TLSEarliestCertExpiry.IsZero() {
+			if metric.TLSVerified {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 1, l...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 0, l...)
+			}
+		}
+		if !metric.TLSEarliestCertExpiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(descs.expiry, prometheus.GaugeValue, float64(metric.TLSEarliestCertExpiry.Unix()), l...)
+		}
 
-		ch <- prometheus.MustNewConstMetric(descs.size, prometheus.GaugeValue, float64(metric.ContentLength), l...)
+		ch <- prometheus.MustNewConstMetric(descs.size, prometheus.GaugeValue, float64(metric.BodyBytes), l...)
+		if metric.ContentEncoding != "" {
+			ch <- prometheus.MustNewConstMetric(descs.wire, prometheus.GaugeValue, float64(metric.WireBytes), append(l, metric.ContentEncoding)...)
+		}
+		if metric.BodyTruncated {
+			ch <- prometheus.MustNewConstMetric(descs.trunc, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.trunc, prometheus.GaugeValue, 0, l...)
+		}
+		// Only the targets with a body_sha256 hash the body
+		if metric.ContentSHA256 != "" {
+			if metric.ContentMatch {
+				ch <- prometheus.MustNewConstMetric(descs.match, prometheus.GaugeValue, 1, l...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(descs.match, prometheus.GaugeValue, 0, l...)
+			}
+		}
+		// Only the probes that got a response read a body
+		if metric.Success || metric.ErrorReason == "transfer" {
+			ch <- prometheus.MustNewConstMetric(descs.xfer, prometheus.GaugeValue, metric.TransferTime.Seconds(), l...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.body, prometheus.GaugeValue, float64(metric.BodyMatchedBytes), l...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.DNSLookup.Seconds(), append(l, "DNSLookup")...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.TCPConnection.Seconds(), append(l, "TCPConnection")...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.TLSHandshake.Seconds(), append(l, "TLSHandshake")...)
+		// Only the targets with a proxy export the TLS handshake and CONNECT exchange with the proxy
+		if metric.Proxy {
+			ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ProxyTLSHandshake.Seconds(), append(l, "ProxyTLSHandshake")...)
+			ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ProxyConnect.Seconds(), append(l, "ProxyConnect")...)
+		}
 		if !metric.TLSEarliestCertExpiry.IsZero() {
 			ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, float64(metric.TLSEarliestCertExpiry.Unix()), append(l, "TLSEarliestCertExpiry")...)
 		}
 		if !metric.TLSLastChainExpiry.IsZero() {
 			ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, float64(metric.TLSLastChainExpiry.Unix()), append(l, "TLSLastChainExpiry")...)
 		}
+		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.RequestTransfer.Seconds(), append(l, "RequestTransfer")...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ServerProcessing.Seconds(), append(l, "ServerProcessing")...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ContentTransfer.Seconds(), append(l, "ContentTransfer")...)
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.Total.Seconds(), append(l, "Total")...)
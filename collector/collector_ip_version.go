@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+)
+
+var (
+	targetIPVersionDesc            = prometheus.NewDesc("target_ip_version_info", "IP version of the address probed by the target", []string{"type", "name", "target_ip", "ip_version"}, nil)
+	targetIPVersionUnavailableDesc = prometheus.NewDesc("target_ip_version_unavailable", "Target whose host has no address of its ip_version", []string{"type", "name", "target", "ip_version"}, nil)
+)
+
+// IPVersion prom
+type IPVersion struct {
+	PING *monitor.PING
+	MTR  *monitor.MTR
+	TCP  *monitor.TCPPort
+	DNS  *monitor.DNS
+	UDP  *monitor.UDPPort
+	NTP  *monitor.NTP
+	SMTP *monitor.SMTP
+}
+
+// Describe prom
+func (p *IPVersion) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetIPVersionDesc
+	ch <- targetIPVersionUnavailableDesc
+}
+
+// Collect prom
+func (p *IPVersion) Collect(ch chan<- prometheus.Metric) {
+	for checkType, families := range map[string][]monitor.AddressFamily{"ICMP": p.PING.AddressFamilies(), "MTR": p.MTR.AddressFamilies(), "TCP": p.TCP.AddressFamilies(), "DNS": p.DNS.AddressFamilies(), "UDP": p.UDP.AddressFamilies(), "NTP": p.NTP.AddressFamilies(), "SMTP": p.SMTP.AddressFamilies()} {
+		for _, f := range families {
+			if f.IP == "" {
+				ch <- prometheus.MustNewConstMetric(targetIPVersionUnavailableDesc, prometheus.GaugeValue, 1, checkType, f.Name, f.Host, strconv.Itoa(f.IPVersion))
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(targetIPVersionDesc, prometheus.GaugeValue, 1, checkType, f.Name, f.IP, strconv.Itoa(f.IPVersion))
+		}
+	}
+}
@@ -12,15 +12,21 @@ import (
 )
 
 var (
-	mtrLabelNames  = []string{"name", "target", "ttl", "path"}
-	mtrDesc        = prometheus.NewDesc("mtr_rtt_seconds", "Round Trip Time in seconds", append(mtrLabelNames, "type"), nil)
-	mtrSntDesc     = prometheus.NewDesc("mtr_rtt_snt_count", "Round Trip Send Package Total", append(mtrLabelNames, "type"), nil)
-	mtrSntFailDesc = prometheus.NewDesc("mtr_rtt_snt_fail_count", "Round Trip Send Package Fail Total", append(mtrLabelNames, "type"), nil)
-	mtrSntTimeDesc = prometheus.NewDesc("mtr_rtt_snt_seconds", "Round Trip Send Package Time Total", append(mtrLabelNames, "type"), nil)
-	mtrHopsDesc    = prometheus.NewDesc("mtr_hops", "Number of route hops", []string{"name", "target"}, nil)
-	mtrTargetsDesc = prometheus.NewDesc("mtr_targets", "Number of active targets", nil, nil)
-	mtrStateDesc   = prometheus.NewDesc("mtr_up", "Exporter state", nil, nil)
-	mtrMutex       = &sync.Mutex{}
+	mtrLabelNames      = []string{"name", "target", "ttl", "path", "protocol"}
+	mtrDesc            = prometheus.NewDesc("mtr_rtt_seconds", "Round Trip Time in seconds", append(mtrLabelNames, "type"), nil)
+	mtrSntDesc         = prometheus.NewDesc("mtr_rtt_snt_count", "Round Trip Send Package Total", append(mtrLabelNames, "type"), nil)
+	mtrSntFailDesc     = prometheus.NewDesc("mtr_rtt_snt_fail_count", "Round Trip Send Package Fail Total", append(mtrLabelNames, "type"), nil)
+	mtrSntTimeDesc     = prometheus.NewDesc("mtr_rtt_snt_seconds", "Round Trip Send Package Time Total", append(mtrLabelNames, "type"), nil)
+	mtrStdDevDesc      = prometheus.NewDesc("mtr_hop_rtt_stddev_seconds", "Standard deviation of the round trip times of the hop in the probe round in seconds", mtrLabelNames, nil)
+	mtrJitterDesc      = prometheus.NewDesc("mtr_hop_rtt_jitter_seconds", "RFC 3550 smoothed jitter of the round trip times of the hop in the probe round in seconds", mtrLabelNames, nil)
+	mtrHopsDesc        = prometheus.NewDesc("mtr_hops", "Number of route hops", []string{"name", "target"}, nil)
+	mtrPayloadDesc     = prometheus.NewDesc("mtr_payload_size_bytes", "Payload size of the ICMP echo requests or UDP datagrams in bytes", []string{"name", "target"}, nil)
+	mtrPathIDDesc      = prometheus.NewDesc("mtr_path_id", "Fingerprint of the hop addresses of the current path", []string{"name", "target"}, nil)
+	mtrPathChangesDesc = prometheus.NewDesc("mtr_path_changes_total", "Changes of the path since the target was started", []string{"name", "target"}, nil)
+	mtrHopInfoDesc     = prometheus.NewDesc("mtr_hop_info", "Reverse DNS name, AS number and country of the hop address", []string{"name", "target", "ttl", "path", "hop_name", "asn", "country"}, nil)
+	mtrTargetsDesc     = prometheus.NewDesc("mtr_targets", "Number of active targets", nil, nil)
+	mtrStateDesc       = prometheus.NewDesc("mtr_up", "Exporter state", nil, nil)
+	mtrMutex           = &sync.Mutex{}
 	// Descriptor cache for custom labels
 	mtrDescCache      = make(map[string]*mtrDescriptorSet)
 	mtrDescCacheMutex sync.RWMutex
@@ -28,11 +34,17 @@ var (
 
 // mtrDescriptorSet holds all descriptors for a specific label set
 type mtrDescriptorSet struct {
-	rtt     *prometheus.Desc
-	hops    *prometheus.Desc
-	snt     *prometheus.Desc
-	sntFail *prometheus.Desc
-	sntTime *prometheus.Desc
+	rtt         *prometheus.Desc
+	stdDev      *prometheus.Desc
+	jitter      *prometheus.Desc
+	hops        *prometheus.Desc
+	snt         *prometheus.Desc
+	sntFail     *prometheus.Desc
+	sntTime     *prometheus.Desc
+	payload     *prometheus.Desc
+	hopInfo     *prometheus.Desc
+	pathID      *prometheus.Desc
+	pathChanges *prometheus.Desc
 }
 
 // getMTRDescriptors returns cached or creates new descriptors for a label set
@@ -54,11 +66,17 @@ func getMTRDescriptors(labels prometheus.Labels) *mtrDescriptorSet {
 	}
 
 	descSet := &mtrDescriptorSet{
-		rtt:     prometheus.NewDesc("mtr_rtt_seconds", "Round Trip Time in seconds", append(mtrLabelNames, "type"), labels),
-		hops:    prometheus.NewDesc("mtr_hops", "Number of route hops", []string{"name", "target"}, labels),
-		snt:     prometheus.NewDesc("mtr_rtt_snt_count", "Round Trip Send Package Total", mtrLabelNames, labels),
-		sntFail: prometheus.NewDesc("mtr_rtt_snt_fail_count", "Round Trip Send Package Fail Total", mtrLabelNames, labels),
-		sntTime: prometheus.NewDesc("mtr_rtt_snt_seconds", "Round Trip Send Package Time Total", mtrLabelNames, labels),
+		rtt:         prometheus.NewDesc("mtr_rtt_seconds", "Round Trip Time in seconds", append(mtrLabelNames, "type"), labels),
+		stdDev:      prometheus.NewDesc("mtr_hop_rtt_stddev_seconds", "Standard deviation of the round trip times of the hop in the probe round in seconds", mtrLabelNames, labels),
+		jitter:      prometheus.NewDesc("mtr_hop_rtt_jitter_seconds", "RFC 3550 smoothed jitter of the round trip times of the hop in the probe round in seconds", mtrLabelNames, labels),
+		hops:        prometheus.NewDesc("mtr_hops", "Number of route hops", []string{"name", "target"}, labels),
+		snt:         prometheus.NewDesc("mtr_rtt_snt_count", "Round Trip Send Package Total", mtrLabelNames, labels),
+		sntFail:     prometheus.NewDesc("mtr_rtt_snt_fail_count", "Round Trip Send Package Fail Total", mtrLabelNames, labels),
+		sntTime:     prometheus.NewDesc("mtr_rtt_snt_seconds", "Round Trip Send Package Time Total", mtrLabelNames, labels),
+		payload:     prometheus.NewDesc("mtr_payload_size_bytes", "Payload size of the ICMP echo requests or UDP datagrams in bytes", []string{"name", "target"}, labels),
+		pathID:      prometheus.NewDesc("mtr_path_id", "Fingerprint of the hop addresses of the current path", []string{"name", "target"}, labels),
+		pathChanges: prometheus.NewDesc("mtr_path_changes_total", "Changes of the path since the target was started", []string{"name", "target"}, labels),
+		hopInfo:     prometheus.NewDesc("mtr_hop_info", "Reverse DNS name, AS number and country of the hop address", []string{"name", "target", "ttl", "path", "hop_name", "asn", "country"}, labels),
 	}
 	mtrDescCache[cacheKey] = descSet
 	return descSet
@@ -74,7 +92,13 @@ type MTR struct {
 // Describe prom
 func (p *MTR) Describe(ch chan<- *prometheus.Desc) {
 	ch <- mtrDesc
+	ch <- mtrStdDevDesc
+	ch <- mtrJitterDesc
 	ch <- mtrHopsDesc
+	ch <- mtrPayloadDesc
+	ch <- mtrHopInfoDesc
+	ch <- mtrPathIDDesc
+	ch <- mtrPathChangesDesc
 	ch <- mtrTargetsDesc
 	ch <- mtrStateDesc
 }
@@ -101,16 +125,24 @@ func (p *MTR) Collect(ch chan<- prometheus.Metric) {
 	targets := []string{}
 	for target, metric := range p.metrics {
 		targets = append(targets, target)
-		l := []string{target, metric.DestAddr}
+		// The targets resolving all their addresses are keyed by name and IP
+		l := []string{strings.TrimSuffix(target, " "+metric.DestAddr), metric.DestAddr}
 		l2 := prometheus.Labels(p.labels[target])
 
 		// Get cached descriptors for this label set
 		descs := getMTRDescriptors(l2)
 
 		ch <- prometheus.MustNewConstMetric(descs.hops, prometheus.GaugeValue, float64(len(metric.Hops)), l...)
+		if metric.Protocol != "tcp" {
+			ch <- prometheus.MustNewConstMetric(descs.payload, prometheus.GaugeValue, float64(metric.PayloadSize), l...)
+		}
+		if metric.PathID != 0 {
+			ch <- prometheus.MustNewConstMetric(descs.pathID, prometheus.GaugeValue, float64(metric.PathID), l...)
+			ch <- prometheus.MustNewConstMetric(descs.pathChanges, prometheus.CounterValue, float64(metric.PathChanges), l...)
+		}
 		for _, hop := range metric.Hops {
 			ll := append(l, strconv.Itoa(hop.TTL))
-			ll = append(ll, hop.AddressTo)
+			ll = append(ll, hop.AddressTo, metric.Protocol)
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, hop.LastTime.Seconds(), append(ll, "last")...)
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, hop.SumTime.Seconds(), append(ll, "sum")...)
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, hop.BestTime.Seconds(), append(ll, "best")...)
@@ -121,11 +153,19 @@ func (p *MTR) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, hop.CorrectedSDTime.Seconds(), append(ll, "csd")...)
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, hop.RangeTime.Seconds(), append(ll, "range")...)
 			ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, float64(hop.Loss), append(ll, "loss")...)
+			// The hops with less than two replies have no variation
+			if hop.StdDevTime != nil && hop.JitterTime != nil {
+				ch <- prometheus.MustNewConstMetric(descs.stdDev, prometheus.GaugeValue, hop.StdDevTime.Seconds(), ll...)
+				ch <- prometheus.MustNewConstMetric(descs.jitter, prometheus.GaugeValue, hop.JitterTime.Seconds(), ll...)
+			}
+			if info, ok := p.Monitor.HopInfo(hop.AddressTo); ok {
+				ch <- prometheus.MustNewConstMetric(descs.hopInfo, prometheus.GaugeValue, 1, append(l, strconv.Itoa(hop.TTL), hop.AddressTo, info.Name, info.ASN, info.Country)...)
+			}
 		}
 
 		for ttl, summary := range metric.HopSummaryMap {
 			ll := append(l, strings.Split(ttl, "_")[0])
-			ll = append(ll, summary.AddressTo)
+			ll = append(ll, summary.AddressTo, metric.Protocol)
 			ch <- prometheus.MustNewConstMetric(descs.snt, prometheus.CounterValue, float64(summary.Snt), ll...)
 			ch <- prometheus.MustNewConstMetric(descs.sntFail, prometheus.CounterValue, float64(summary.SntFail), ll...)
 			ch <- prometheus.MustNewConstMetric(descs.sntTime, prometheus.CounterValue, summary.SntTime.Seconds(), ll...)
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/notify"
+)
+
+var (
+	notificationsSentDesc    = prometheus.NewDesc("notifications_sent_total", "State change notifications delivered total", nil, nil)
+	notificationsFailedDesc  = prometheus.NewDesc("notifications_failed_total", "State change notifications failed after all the retries total", nil, nil)
+	notificationsDroppedDesc = prometheus.NewDesc("notifications_dropped_total", "State change notifications dropped on a full queue total", nil, nil)
+)
+
+// Notify prom
+type Notify struct {
+	Webhook *notify.Webhook
+}
+
+// Describe prom
+func (p *Notify) Describe(ch chan<- *prometheus.Desc) {
+	ch <- notificationsSentDesc
+	ch <- notificationsFailedDesc
+	ch <- notificationsDroppedDesc
+}
+
+// Collect prom
+func (p *Notify) Collect(ch chan<- prometheus.Metric) {
+	sent, failed, dropped := p.Webhook.Stats()
+	ch <- prometheus.MustNewConstMetric(notificationsSentDesc, prometheus.CounterValue, float64(sent))
+	ch <- prometheus.MustNewConstMetric(notificationsFailedDesc, prometheus.CounterValue, float64(failed))
+	ch <- prometheus.MustNewConstMetric(notificationsDroppedDesc, prometheus.CounterValue, float64(dropped))
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/pkg/ntp"
+)
+
+var (
+	ntpLabelNames  = []string{"name", "target", "target_ip", "source_ip", "port"}
+	ntpOffsetDesc  = prometheus.NewDesc("ntp_offset_seconds", "Offset of the local clock to the server in seconds", ntpLabelNames, nil)
+	ntpRTTDesc     = prometheus.NewDesc("ntp_rtt_seconds", "Round trip delay of the query without the processing time of the server in seconds", ntpLabelNames, nil)
+	ntpStratumDesc = prometheus.NewDesc("ntp_stratum", "Stratum of the server, -1 without response", ntpLabelNames, nil)
+	ntpLeapDesc    = prometheus.NewDesc("ntp_leap", "Leap indicator of the server, -1 without response", ntpLabelNames, nil)
+	ntpSuccessDesc = prometheus.NewDesc("ntp_success", "Query answered by a synchronized server", ntpLabelNames, nil)
+	ntpErrorDesc   = prometheus.NewDesc("ntp_error_reason", "Query phase of the failure of the last probe", append(ntpLabelNames, "reason"), nil)
+	ntpTargetsDesc = prometheus.NewDesc("ntp_targets", "Number of active targets", nil, nil)
+	ntpStateDesc   = prometheus.NewDesc("ntp_up", "Exporter state", nil, nil)
+	ntpMutex       = &sync.Mutex{}
+	// Descriptor cache for custom labels
+	ntpDescCache      = make(map[string]*ntpDescriptorSet)
+	ntpDescCacheMutex sync.RWMutex
+)
+
+// ntpDescriptorSet holds all descriptors for a specific label set
+type ntpDescriptorSet struct {
+	offset  *prometheus.Desc
+	rtt     *prometheus.Desc
+	stratum *prometheus.Desc
+	leap    *prometheus.Desc
+	success *prometheus.Desc
+	error   *prometheus.Desc
+}
+
+// getNTPDescriptors returns cached or creates new descriptors for a label set
+func getNTPDescriptors(labels prometheus.Labels) *ntpDescriptorSet {
+	cacheKey := fmt.Sprintf("%v", labels)
+
+	ntpDescCacheMutex.RLock()
+	if descSet, exists := ntpDescCache[cacheKey]; exists {
+		ntpDescCacheMutex.RUnlock()
+		return descSet
+	}
+	ntpDescCacheMutex.RUnlock()
+
+	ntpDescCacheMutex.Lock()
+	defer ntpDescCacheMutex.Unlock()
+
+	if descSet, exists := ntpDescCache[cacheKey]; exists {
+		return descSet
+	}
+
+	descSet := &ntpDescriptorSet{
+		offset:  prometheus.NewDesc("ntp_offset_seconds", "Offset of the local clock to the server in seconds", ntpLabelNames, labels),
+		rtt:     prometheus.NewDesc("ntp_rtt_seconds", "Round trip delay of the query without the processing time of the server in seconds", ntpLabelNames, labels),
+		stratum: prometheus.NewDesc("ntp_stratum", "Stratum of the server, -1 without response", ntpLabelNames, labels),
+		leap:    prometheus.NewDesc("ntp_leap", "Leap indicator of the server, -1 without response", ntpLabelNames, labels),
+		success: prometheus.NewDesc("ntp_success", "Query answered by a synchronized server", ntpLabelNames, labels),
+		error:   prometheus.NewDesc("ntp_error_reason", "Query phase of the failure of the last probe", append(ntpLabelNames, "reason"), labels),
+	}
+	ntpDescCache[cacheKey] = descSet
+	return descSet
+}
+
+// NTP prom
+type NTP struct {
+	Monitor *monitor.NTP
+	metrics map[string]*ntp.NTPReturn
+	labels  map[string]map[string]string
+}
+
+// Describe prom
+func (p *NTP) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ntpOffsetDesc
+	ch <- ntpRTTDesc
+	ch <- ntpStratumDesc
+	ch <- ntpLeapDesc
+	ch <- ntpSuccessDesc
+	ch <- ntpErrorDesc
+	ch <- ntpTargetsDesc
+	ch <- ntpStateDesc
+}
+
+// Collect prom
+func (p *NTP) Collect(ch chan<- prometheus.Metric) {
+	ntpMutex.Lock()
+	defer ntpMutex.Unlock()
+
+	if m := p.Monitor.ExportMetrics(); len(m) > 0 {
+		p.metrics = m
+	}
+
+	if l := p.Monitor.ExportLabels(); len(l) > 0 {
+		p.labels = l
+	}
+
+	if len(p.metrics) > 0 {
+		ch <- prometheus.MustNewConstMetric(ntpStateDesc, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(ntpStateDesc, prometheus.GaugeValue, 0)
+	}
+
+	targets := []string{}
+	for target, metric := range p.metrics {
+		targets = append(targets, target)
+		l := []string{strings.TrimSuffix(target, " "+metric.DestIp), metric.DestAddr, metric.DestIp, metric.SrcIp, metric.DestPort}
+		l2 := prometheus.Labels(p.labels[target])
+
+		// Get cached descriptors for this label set
+		descs := getNTPDescriptors(l2)
+
+		// The offset of the failed queries is not the one of the clock of the server
+		if metric.Success {
+			ch <- prometheus.MustNewConstMetric(descs.offset, prometheus.GaugeValue, metric.Offset.Seconds(), l...)
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, metric.RTT.Seconds(), l...)
+		ch <- prometheus.MustNewConstMetric(descs.stratum, prometheus.GaugeValue, float64(metric.Stratum), l...)
+		ch <- prometheus.MustNewConstMetric(descs.leap, prometheus.GaugeValue, float64(metric.Leap), l...)
+		if metric.ErrorReason != "" {
+			ch <- prometheus.MustNewConstMetric(descs.error, prometheus.GaugeValue, 1, append(l, metric.ErrorReason)...)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(ntpTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
+}
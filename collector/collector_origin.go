@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
+)
+
+var (
+	targetOriginDesc    = prometheus.NewDesc("network_exporter_target_origin", "Origin of the active targets", []string{"name", "type", "source", "kind"}, nil)
+	targetConflictsDesc = prometheus.NewDesc("network_exporter_target_conflicts", "Targets of a source shadowed by a source with a higher precedence", []string{"source"}, nil)
+	targetDisabledDesc  = prometheus.NewDesc("network_exporter_target_disabled", "Targets of the config file disabled with enabled false", []string{"name", "type"}, nil)
+)
+
+// Origin prom
+type Origin struct {
+	SC *config.SafeConfig
+}
+
+// Describe prom
+func (p *Origin) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetOriginDesc
+	ch <- targetConflictsDesc
+	ch <- targetDisabledDesc
+}
+
+// Collect prom
+func (p *Origin) Collect(ch chan<- prometheus.Metric) {
+	p.SC.RLock()
+	targets := p.SC.Cfg.Targets
+	p.SC.RUnlock()
+
+	for _, t := range targets {
+		if o, ok := p.SC.TargetOrigin(t); ok {
+			ch <- prometheus.MustNewConstMetric(targetOriginDesc, prometheus.GaugeValue, 1, t.Name, string(t.Type), o.Source, o.Kind)
+		}
+	}
+	for source, n := range p.SC.Conflicts() {
+		ch <- prometheus.MustNewConstMetric(targetConflictsDesc, prometheus.GaugeValue, float64(n), source)
+	}
+	for _, t := range p.SC.Disabled() {
+		ch <- prometheus.MustNewConstMetric(targetDisabledDesc, prometheus.GaugeValue, 1, t.Name, string(t.Type))
+	}
+}
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/target"
+)
+
+var (
+	probePanicsDesc      = prometheus.NewDesc("network_exporter_probe_panics_total", "Panics recovered from the probes of the target total", []string{"type", "name"}, nil)
+	probeQuarantinedDesc = prometheus.NewDesc("network_exporter_probe_quarantined", "Target quarantined after repeated panics, its probes are not run anymore", []string{"type", "name"}, nil)
+)
+
+// Panics prom
+type Panics struct{}
+
+// Describe prom
+func (p *Panics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probePanicsDesc
+	ch <- probeQuarantinedDesc
+}
+
+// Collect prom
+func (p *Panics) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range target.PanicStats() {
+		quarantined := 0.0
+		if s.Quarantined {
+			quarantined = 1
+		}
+		ch <- prometheus.MustNewConstMetric(probePanicsDesc, prometheus.CounterValue, float64(s.Panics), s.Type, s.Name)
+		ch <- prometheus.MustNewConstMetric(probeQuarantinedDesc, prometheus.GaugeValue, quarantined, s.Type, s.Name)
+	}
+}
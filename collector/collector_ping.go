@@ -14,10 +14,22 @@ var (
 	icmpLabelNames         = []string{"name", "target", "target_ip"}
 	icmpStatusDesc         = prometheus.NewDesc("ping_status", "Ping Status", icmpLabelNames, nil)
 	icmpRttDesc            = prometheus.NewDesc("ping_rtt_seconds", "Round Trip Time in seconds", append(icmpLabelNames, "type"), nil)
+	icmpStdDevDesc         = prometheus.NewDesc("ping_rtt_stddev_seconds", "Standard deviation of the round trip times of the probe round in seconds", icmpLabelNames, nil)
+	icmpJitterDesc         = prometheus.NewDesc("ping_rtt_jitter_seconds", "Mean absolute difference between the round trip times of the consecutive replies of the probe round in seconds", icmpLabelNames, nil)
 	icmpSntSummaryDesc     = prometheus.NewDesc("ping_rtt_snt_count", "Packet sent count", icmpLabelNames, nil)
 	icmpSntFailSummaryDesc = prometheus.NewDesc("ping_rtt_snt_fail_count", "Packet sent fail count", icmpLabelNames, nil)
 	icmpSntTimeSummaryDesc = prometheus.NewDesc("ping_rtt_snt_seconds", "Packet sent time total", icmpLabelNames, nil)
 	icmpLossDesc           = prometheus.NewDesc("ping_loss_percent", "Packet loss in percent", icmpLabelNames, nil)
+	icmpPayloadDesc        = prometheus.NewDesc("ping_payload_size_bytes", "Payload size of the echo requests in bytes", icmpLabelNames, nil)
+	icmpReplyTTLDesc       = prometheus.NewDesc("ping_reply_ttl", "IP TTL or hop limit of the last echo reply", icmpLabelNames, nil)
+	icmpReplyTTLMinDesc    = prometheus.NewDesc("ping_reply_ttl_min", "Lowest IP TTL or hop limit of the echo replies of the probe round", icmpLabelNames, nil)
+	icmpReplyTTLMaxDesc    = prometheus.NewDesc("ping_reply_ttl_max", "Highest IP TTL or hop limit of the echo replies of the probe round", icmpLabelNames, nil)
+	icmpDuplicatesDesc     = prometheus.NewDesc("ping_duplicates_total", "Echo replies received for an already answered sequence number total", icmpLabelNames, nil)
+	icmpLateRepliesDesc    = prometheus.NewDesc("ping_late_replies_total", "Echo replies received after the timeout of their request total", icmpLabelNames, nil)
+	icmpLateDepthDesc      = prometheus.NewDesc("ping_late_reply_depth", "Largest distance in sequence numbers between the late replies of the probe round and the awaited request", icmpLabelNames, nil)
+	icmpOwdForwardDesc     = prometheus.NewDesc("ping_owd_forward_seconds", "Mean one-way delay from the prober to the target of the timestamp replies of the probe round in seconds, includes the clock offset", icmpLabelNames, nil)
+	icmpOwdReturnDesc      = prometheus.NewDesc("ping_owd_return_seconds", "Mean one-way delay from the target to the prober of the timestamp replies of the probe round in seconds, includes the clock offset", icmpLabelNames, nil)
+	icmpFallbackDesc       = prometheus.NewDesc("ping_timestamp_fallback", "Timestamp probe round without one-way delays by reason", append(icmpLabelNames, "reason"), nil)
 	icmpTargetsDesc        = prometheus.NewDesc("ping_targets", "Number of active targets", nil, nil)
 	icmpStateDesc          = prometheus.NewDesc("ping_up", "Exporter state", nil, nil)
 	icmpMutex              = &sync.Mutex{}
@@ -30,10 +42,22 @@ var (
 type descriptorSet struct {
 	status         *prometheus.Desc
 	rtt            *prometheus.Desc
+	stdDev         *prometheus.Desc
+	jitter         *prometheus.Desc
 	sntSummary     *prometheus.Desc
 	sntFailSummary *prometheus.Desc
 	sntTimeSummary *prometheus.Desc
 	loss           *prometheus.Desc
+	payload        *prometheus.Desc
+	replyTTL       *prometheus.Desc
+	replyTTLMin    *prometheus.Desc
+	replyTTLMax    *prometheus.Desc
+	duplicates     *prometheus.Desc
+	lateReplies    *prometheus.Desc
+	lateDepth      *prometheus.Desc
+	owdForward     *prometheus.Desc
+	owdReturn      *prometheus.Desc
+	fallback       *prometheus.Desc
 }
 
 // getDescriptors returns cached or creates new descriptors for a label set
@@ -61,10 +85,22 @@ func getDescriptors(labels prometheus.Labels) *descriptorSet {
 	descSet := &descriptorSet{
 		status:         prometheus.NewDesc("ping_status", "Ping Status", icmpLabelNames, labels),
 		rtt:            prometheus.NewDesc("ping_rtt_seconds", "Round Trip Time in seconds", append(icmpLabelNames, "type"), labels),
+		stdDev:         prometheus.NewDesc("ping_rtt_stddev_seconds", "Standard deviation of the round trip times of the probe round in seconds", icmpLabelNames, labels),
+		jitter:         prometheus.NewDesc("ping_rtt_jitter_seconds", "Mean absolute difference between the round trip times of the consecutive replies of the probe round in seconds", icmpLabelNames, labels),
 		sntSummary:     prometheus.NewDesc("ping_rtt_snt_count", "Packet sent count", icmpLabelNames, labels),
 		sntFailSummary: prometheus.NewDesc("ping_rtt_snt_fail_count", "Packet sent fail count", icmpLabelNames, labels),
 		sntTimeSummary: prometheus.NewDesc("ping_rtt_snt_seconds", "Packet sent time total", icmpLabelNames, labels),
 		loss:           prometheus.NewDesc("ping_loss_percent", "Packet loss in percent", icmpLabelNames, labels),
+		payload:        prometheus.NewDesc("ping_payload_size_bytes", "Payload size of the echo requests in bytes", icmpLabelNames, labels),
+		replyTTL:       prometheus.NewDesc("ping_reply_ttl", "IP TTL or hop limit of the last echo reply", icmpLabelNames, labels),
+		replyTTLMin:    prometheus.NewDesc("ping_reply_ttl_min", "Lowest IP TTL or hop limit of the echo replies of the probe round", icmpLabelNames, labels),
+		replyTTLMax:    prometheus.NewDesc("ping_reply_ttl_max", "Highest IP TTL or hop limit of the echo replies of the probe round", icmpLabelNames, labels),
+		duplicates:     prometheus.NewDesc("ping_duplicates_total", "Echo replies received for an already answered sequence number total", icmpLabelNames, labels),
+		lateReplies:    prometheus.NewDesc("ping_late_replies_total", "Echo replies received after the timeout of their request total", icmpLabelNames, labels),
+		lateDepth:      prometheus.NewDesc("ping_late_reply_depth", "Largest distance in sequence numbers between the late replies of the probe round and the awaited request", icmpLabelNames, labels),
+		owdForward:     prometheus.NewDesc("ping_owd_forward_seconds", "Mean one-way delay from the prober to the target of the timestamp replies of the probe round in seconds, includes the clock offset", icmpLabelNames, labels),
+		owdReturn:      prometheus.NewDesc("ping_owd_return_seconds", "Mean one-way delay from the target to the prober of the timestamp replies of the probe round in seconds, includes the clock offset", icmpLabelNames, labels),
+		fallback:       prometheus.NewDesc("ping_timestamp_fallback", "Timestamp probe round without one-way delays by reason", append(icmpLabelNames, "reason"), labels),
 	}
 	icmpDescCache[cacheKey] = descSet
 	return descSet
@@ -81,7 +117,19 @@ type PING struct {
 func (p *PING) Describe(ch chan<- *prometheus.Desc) {
 	ch <- icmpStatusDesc
 	ch <- icmpRttDesc
+	ch <- icmpStdDevDesc
+	ch <- icmpJitterDesc
 	ch <- icmpLossDesc
+	ch <- icmpPayloadDesc
+	ch <- icmpReplyTTLDesc
+	ch <- icmpReplyTTLMinDesc
+	ch <- icmpReplyTTLMaxDesc
+	ch <- icmpDuplicatesDesc
+	ch <- icmpLateRepliesDesc
+	ch <- icmpLateDepthDesc
+	ch <- icmpOwdForwardDesc
+	ch <- icmpOwdReturnDesc
+	ch <- icmpFallbackDesc
 	ch <- icmpTargetsDesc
 	ch <- icmpStateDesc
 }
@@ -130,10 +178,33 @@ func (p *PING) Collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, metric.UncorrectedSDTime.Seconds(), append(l, "usd")...)
 		ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, metric.CorrectedSDTime.Seconds(), append(l, "csd")...)
 		ch <- prometheus.MustNewConstMetric(descs.rtt, prometheus.GaugeValue, metric.RangeTime.Seconds(), append(l, "range")...)
+		// The rounds with less than two replies have no variation
+		if metric.StdDevTime != nil && metric.JitterTime != nil {
+			ch <- prometheus.MustNewConstMetric(descs.stdDev, prometheus.GaugeValue, metric.StdDevTime.Seconds(), l...)
+			ch <- prometheus.MustNewConstMetric(descs.jitter, prometheus.GaugeValue, metric.JitterTime.Seconds(), l...)
+		}
 		ch <- prometheus.MustNewConstMetric(descs.sntSummary, prometheus.GaugeValue, float64(metric.SntSummary), l...)
 		ch <- prometheus.MustNewConstMetric(descs.sntFailSummary, prometheus.GaugeValue, float64(metric.SntFailSummary), l...)
 		ch <- prometheus.MustNewConstMetric(descs.sntTimeSummary, prometheus.GaugeValue, metric.SntTimeSummary.Seconds(), l...)
 		ch <- prometheus.MustNewConstMetric(descs.loss, prometheus.GaugeValue, metric.DropRate, l...)
+		ch <- prometheus.MustNewConstMetric(descs.payload, prometheus.GaugeValue, float64(metric.PayloadSize), l...)
+		ch <- prometheus.MustNewConstMetric(descs.duplicates, prometheus.CounterValue, float64(metric.DuplicatesSummary), l...)
+		ch <- prometheus.MustNewConstMetric(descs.lateReplies, prometheus.CounterValue, float64(metric.LateRepliesSummary), l...)
+		ch <- prometheus.MustNewConstMetric(descs.lateDepth, prometheus.GaugeValue, float64(metric.LateDepth), l...)
+		// Only the TTLs read from the replies are exported
+		if metric.ReplyTTL > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.replyTTL, prometheus.GaugeValue, float64(metric.ReplyTTL), l...)
+			ch <- prometheus.MustNewConstMetric(descs.replyTTLMin, prometheus.GaugeValue, float64(metric.ReplyTTLMin), l...)
+			ch <- prometheus.MustNewConstMetric(descs.replyTTLMax, prometheus.GaugeValue, float64(metric.ReplyTTLMax), l...)
+		}
+		// Only the timestamp rounds with replies have one-way delays
+		if metric.OwdForwardTime != nil && metric.OwdReturnTime != nil {
+			ch <- prometheus.MustNewConstMetric(descs.owdForward, prometheus.GaugeValue, metric.OwdForwardTime.Seconds(), l...)
+			ch <- prometheus.MustNewConstMetric(descs.owdReturn, prometheus.GaugeValue, metric.OwdReturnTime.Seconds(), l...)
+		}
+		if metric.TimestampFallback != "" {
+			ch <- prometheus.MustNewConstMetric(descs.fallback, prometheus.GaugeValue, 1, append(l, metric.TimestampFallback)...)
+		}
 	}
 	ch <- prometheus.MustNewConstMetric(icmpTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
 }
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/results"
+)
+
+var (
+	probeLabelNames  = []string{"type", "name", "target", "target_ip"}
+	probeSuccessDesc = prometheus.NewDesc("network_probe_success", "Last completed round of the target succeeded, the same check for every type of probe", probeLabelNames, nil)
+	probeLastRunDesc = prometheus.NewDesc("network_probe_last_run_timestamp_seconds", "Completion time of the last round of the target in unix seconds", probeLabelNames, nil)
+	// Descriptor cache for custom labels
+	probeDescCache      = make(map[string]*probeDescriptorSet)
+	probeDescCacheMutex sync.RWMutex
+)
+
+// probeDescriptorSet holds all descriptors for a specific label set
+type probeDescriptorSet struct {
+	success *prometheus.Desc
+	lastRun *prometheus.Desc
+}
+
+// getProbeDescriptors returns cached or creates new descriptors for a label set
+func getProbeDescriptors(labels prometheus.Labels) *probeDescriptorSet {
+	cacheKey := fmt.Sprintf("%v", labels)
+
+	probeDescCacheMutex.RLock()
+	if descSet, exists := probeDescCache[cacheKey]; exists {
+		probeDescCacheMutex.RUnlock()
+		return descSet
+	}
+	probeDescCacheMutex.RUnlock()
+
+	probeDescCacheMutex.Lock()
+	defer probeDescCacheMutex.Unlock()
+
+	if descSet, exists := probeDescCache[cacheKey]; exists {
+		return descSet
+	}
+
+	descSet := &probeDescriptorSet{
+		success: prometheus.NewDesc("network_probe_success", "Last completed round of the target succeeded, the same check for every type of probe", probeLabelNames, labels),
+		lastRun: prometheus.NewDesc("network_probe_last_run_timestamp_seconds", "Completion time of the last round of the target in unix seconds", probeLabelNames, labels),
+	}
+	probeDescCache[cacheKey] = descSet
+	return descSet
+}
+
+// Probe prom, the success of the last round of every target from the results of the hub
+type Probe struct {
+	Hub *results.Hub
+}
+
+// Describe prom
+func (p *Probe) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeSuccessDesc
+	ch <- probeLastRunDesc
+}
+
+// Collect prom
+func (p *Probe) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range p.Hub.All() {
+		l := []string{r.Type, r.Name, r.Host, r.IP}
+		descs := getProbeDescriptors(prometheus.Labels(r.Labels))
+
+		if r.Success {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.lastRun, prometheus.GaugeValue, float64(r.Time.UnixNano())/1e9, l...)
+	}
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+)
+
+var resolutionPendingDesc = prometheus.NewDesc("targets_pending_resolution", "Target hosts and SRV records waiting for a successful resolution", nil, nil)
+
+// Resolution prom
+type Resolution struct {
+	Resolution *monitor.Resolution
+}
+
+// Describe prom
+func (p *Resolution) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resolutionPendingDesc
+}
+
+// Collect prom
+func (p *Resolution) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(resolutionPendingDesc, prometheus.GaugeValue, float64(p.Resolution.Pending()))
+}
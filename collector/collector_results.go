@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/results"
+)
+
+var (
+	resultsWatchersDesc = prometheus.NewDesc("results_watchers", "Number of active result watchers", nil, nil)
+	resultsDroppedDesc  = prometheus.NewDesc("results_dropped_total", "Results dropped on slow watchers total", nil, nil)
+)
+
+// Results prom
+type Results struct {
+	Hub *results.Hub
+}
+
+// Describe prom
+func (p *Results) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resultsWatchersDesc
+	ch <- resultsDroppedDesc
+}
+
+// Collect prom
+func (p *Results) Collect(ch chan<- prometheus.Metric) {
+	watchers, dropped := p.Hub.Stats()
+	ch <- prometheus.MustNewConstMetric(resultsWatchersDesc, prometheus.GaugeValue, float64(watchers))
+	ch <- prometheus.MustNewConstMetric(resultsDroppedDesc, prometheus.CounterValue, float64(dropped))
+}
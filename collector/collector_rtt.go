@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/pkg/ping"
+)
+
+var probeInvalidRTTDesc = prometheus.NewDesc("probe_invalid_rtt_total", "Negative round trip times clamped to 0 instead of being exported total", []string{"type"}, nil)
+
+// RTT prom
+type RTT struct{}
+
+// Describe prom
+func (r *RTT) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeInvalidRTTDesc
+}
+
+// Collect prom
+func (r *RTT) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(probeInvalidRTTDesc, prometheus.CounterValue, float64(ping.InvalidRTT()), "ICMP")
+	ch <- prometheus.MustNewConstMetric(probeInvalidRTTDesc, prometheus.CounterValue, float64(mtr.InvalidRTT()), "MTR")
+}
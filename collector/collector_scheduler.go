@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/scheduler"
+)
+
+var (
+	schedulerJobsDesc    = prometheus.NewDesc("scheduler_jobs", "Probes scheduled on the worker pool", []string{"type"}, nil)
+	schedulerSkippedDesc = prometheus.NewDesc("scheduler_skipped_total", "Probes skipped because the previous probe of the target was still running total", []string{"type"}, nil)
+)
+
+// Scheduler prom
+type Scheduler struct {
+	Schedulers map[string]*scheduler.Scheduler
+}
+
+// Describe prom
+func (p *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- schedulerJobsDesc
+	ch <- schedulerSkippedDesc
+}
+
+// Collect prom
+func (p *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	for name, s := range p.Schedulers {
+		jobs, skipped := s.Stats()
+		ch <- prometheus.MustNewConstMetric(schedulerJobsDesc, prometheus.GaugeValue, float64(jobs), name)
+		ch <- prometheus.MustNewConstMetric(schedulerSkippedDesc, prometheus.CounterValue, float64(skipped), name)
+	}
+}
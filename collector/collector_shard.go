@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
+)
+
+var shardTargetsDesc = prometheus.NewDesc("network_shard_targets", "Active targets assigned to the shard of this instance by conf.sharding", []string{"index"}, nil)
+
+// Shard prom
+type Shard struct {
+	SC *config.SafeConfig
+}
+
+// Describe prom
+func (p *Shard) Describe(ch chan<- *prometheus.Desc) {
+	ch <- shardTargetsDesc
+}
+
+// Collect prom
+func (p *Shard) Collect(ch chan<- prometheus.Metric) {
+	if index, targets, ok := p.SC.ShardTargets(); ok {
+		ch <- prometheus.MustNewConstMetric(shardTargetsDesc, prometheus.GaugeValue, float64(targets), strconv.Itoa(index))
+	}
+}
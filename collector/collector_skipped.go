@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/target"
+)
+
+var probeSkippedDesc = prometheus.NewDesc("network_probe_skipped_total", "Probes of the target skipped because conf.max_concurrent_probes probes were running total", []string{"type", "name"}, nil)
+
+// Skipped prom
+type Skipped struct{}
+
+// Describe prom
+func (p *Skipped) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeSkippedDesc
+}
+
+// Collect prom
+func (p *Skipped) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range target.SkipStats() {
+		ch <- prometheus.MustNewConstMetric(probeSkippedDesc, prometheus.CounterValue, float64(s.Skipped), s.Type, s.Name)
+	}
+}
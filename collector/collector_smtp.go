@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/pkg/smtp"
+)
+
+var (
+	smtpLabelNames  = []string{"name", "target", "target_ip", "source_ip", "port"}
+	smtpTimeDesc    = prometheus.NewDesc("smtp_connection_seconds", "Connection time in seconds", smtpLabelNames, nil)
+	smtpBannerDesc  = prometheus.NewDesc("smtp_banner_seconds", "Time from the connection to the end of the banner in seconds", smtpLabelNames, nil)
+	smtpEHLODesc    = prometheus.NewDesc("smtp_ehlo_seconds", "EHLO exchange time in seconds", smtpLabelNames, nil)
+	smtpTLSTimeDesc = prometheus.NewDesc("smtp_tls_handshake_seconds", "TLS handshake time after STARTTLS in seconds", smtpLabelNames, nil)
+	smtpTLSDesc     = prometheus.NewDesc("smtp_tls_info", "Negotiated TLS version and cipher", append(smtpLabelNames, "version", "cipher"), nil)
+	smtpVerifyDesc  = prometheus.NewDesc("smtp_ssl_verified", "Certificate chain of the server verified", smtpLabelNames, nil)
+	smtpExpiryDesc  = prometheus.NewDesc("smtp_ssl_earliest_cert_expiry", "Earliest expiry of the certificates of the server in unix seconds", smtpLabelNames, nil)
+	smtpCodeDesc    = prometheus.NewDesc("smtp_reply_code", "Last reply code of the server, 0 without reply", smtpLabelNames, nil)
+	smtpSuccessDesc = prometheus.NewDesc("smtp_success", "Banner received and EHLO and STARTTLS accepted when set", smtpLabelNames, nil)
+	smtpErrorDesc   = prometheus.NewDesc("smtp_error_reason", "Phase of the failure of the last probe", append(smtpLabelNames, "reason"), nil)
+	smtpTargetsDesc = prometheus.NewDesc("smtp_targets", "Number of active targets", nil, nil)
+	smtpStateDesc   = prometheus.NewDesc("smtp_up", "Exporter state", nil, nil)
+	smtpMutex       = &sync.Mutex{}
+	// Descriptor cache for custom labels
+	smtpDescCache      = make(map[string]*smtpDescriptorSet)
+	smtpDescCacheMutex sync.RWMutex
+)
+
+// smtpDescriptorSet holds all descriptors for a specific label set
+type smtpDescriptorSet struct {
+	time    *prometheus.Desc
+	banner  *prometheus.Desc
+	ehlo    *prometheus.Desc
+	tlsTime *prometheus.Desc
+	tls     *prometheus.Desc
+	verify  *prometheus.Desc
+	expiry  *prometheus.Desc
+	code    *prometheus.Desc
+	success *prometheus.Desc
+	error   *prometheus.Desc
+}
+
+// getSMTPDescriptors returns cached or creates new descriptors for a label set
+func getSMTPDescriptors(labels prometheus.Labels) *smtpDescriptorSet {
+	cacheKey := fmt.Sprintf("%v", labels)
+
+	smtpDescCacheMutex.RLock()
+	if descSet, exists := smtpDescCache[cacheKey]; exists {
+		smtpDescCacheMutex.RUnlock()
+		return descSet
+	}
+	smtpDescCacheMutex.RUnlock()
+
+	smtpDescCacheMutex.Lock()
+	defer smtpDescCacheMutex.Unlock()
+
+	if descSet, exists := smtpDescCache[cacheKey]; exists {
+		return descSet
+	}
+
+	descSet := &smtpDescriptorSet{
+		time:    prometheus.NewDesc("smtp_connection_seconds", "Connection time in seconds", smtpLabelNames, labels),
+		banner:  prometheus.NewDesc("smtp_banner_seconds", "Time from the connection to the end of the banner in seconds", smtpLabelNames, labels),
+		ehlo:    prometheus.NewDesc("smtp_ehlo_seconds", "EHLO exchange time in seconds", smtpLabelNames, labels),
+		tlsTime: prometheus.NewDesc("smtp_tls_handshake_seconds", "TLS handshake time after STARTTLS in seconds", smtpLabelNames, labels),
+		tls:     prometheus.NewDesc("smtp_tls_info", "Negotiated TLS version and cipher", append(smtpLabelNames, "version", "cipher"), labels),
+		verify:  prometheus.NewDesc("smtp_ssl_verified", "Certificate chain of the server verified", smtpLabelNames, labels),
+		expiry:  prometheus.NewDesc("smtp_ssl_earliest_cert_expiry", "Earliest expiry of the certificates of the server in unix seconds", smtpLabelNames, labels),
+		code:    prometheus.NewDesc("smtp_reply_code", "Last reply code of the server, 0 without reply", smtpLabelNames, labels),
+		success: prometheus.NewDesc("smtp_success", "Banner received and EHLO and STARTTLS accepted when set", smtpLabelNames, labels),
+		error:   prometheus.NewDesc("smtp_error_reason", "Phase of the failure of the last probe", append(smtpLabelNames, "reason"), labels),
+	}
+	smtpDescCache[cacheKey] = descSet
+	return descSet
+}
+
+// SMTP prom
+type SMTP struct {
+	Monitor *monitor.SMTP
+	metrics map[string]*smtp.SMTPReturn
+	labels  map[string]map[string]string
+}
+
+// Describe prom
+func (p *SMTP) Describe(ch chan<- *prometheus.Desc) {
+	ch <- smtpTimeDesc
+	ch <- smtpBannerDesc
+	ch <- smtpEHLODesc
+	ch <- smtpTLSTimeDesc
+	ch <- smtpTLSDesc
+	ch <- smtpVerifyDesc
+	ch <- smtpExpiryDesc
+	ch <- smtpCodeDesc
+	ch <- smtpSuccessDesc
+	ch <- smtpErrorDesc
+	ch <- smtpTargetsDesc
+	ch <- smtpStateDesc
+}
+
+// Collect prom
+func (p *SMTP) Collect(ch chan<- prometheus.Metric) {
+	smtpMutex.Lock()
+	defer smtpMutex.Unlock()
+
+	if m := p.Monitor.ExportMetrics(); len(m) > 0 {
+		p.metrics = m
+	}
+
+	if l := p.Monitor.ExportLabels(); len(l) > 0 {
+		p.labels = l
+	}
+
+	if len(p.metrics) > 0 {
+		ch <- prometheus.MustNewConstMetric(smtpStateDesc, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(smtpStateDesc, prometheus.GaugeValue, 0)
+	}
+
+	targets := []string{}
+	for target, metric := range p.metrics {
+		targets = append(targets, target)
+		l := []string{strings.TrimSuffix(target, " "+metric.DestIp), metric.DestAddr, metric.DestIp, metric.SrcIp, metric.DestPort}
+		l2 := prometheus.Labels(p.labels[target])
+
+		// Get cached descriptors for this label set
+		descs := getSMTPDescriptors(l2)
+
+		if metric.Success {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		if metric.ErrorReason != "" {
+			ch <- prometheus.MustNewConstMetric(descs.error, prometheus.GaugeValue, 1, append(l, metric.ErrorReason)...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ConTime.Seconds(), l...)
+		ch <- prometheus.MustNewConstMetric(descs.code, prometheus.GaugeValue, float64(metric.Code), l...)
+
+		// The phases are exported once they started, the failed ones included
+		if metric.BannerTime > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.banner, prometheus.GaugeValue, metric.BannerTime.Seconds(), l...)
+		}
+		if metric.EHLOTime > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.ehlo, prometheus.GaugeValue, metric.EHLOTime.Seconds(), l...)
+		}
+		// The certificates of the rejected handshakes are reported without TLS version
+		if metric.TLSTime > 0 {
+			ch <- prometheus.MustNewConstMetric(descs.tlsTime, prometheus.GaugeValue, metric.TLSTime.Seconds(), l...)
+		}
+		if metric.TLSVersion != "" {
+			ch <- prometheus.MustNewConstMetric(descs.tls, prometheus.GaugeValue, 1, append(l, metric.TLSVersion, metric.TLSCipher)...)
+		}
+		if metric.TLSVersion != "" || !metric.TLSEarliestCertExpiry.IsZero() {
+			if metric.TLSVerified {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 1, l...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 0, l...)
+			}
+		}
+		if !metric.TLSEarliestCertExpiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(descs.expiry, prometheus.GaugeValue, float64(metric.TLSEarliestCertExpiry.Unix()), l...)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(smtpTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
+}
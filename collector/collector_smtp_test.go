@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/results"
+)
+
+// selfSigned returns a certificate of 127.0.0.1 for the STARTTLS handshakes
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// smtpServer serves every connection with serve until the end of the test and returns its port
+func smtpServer(t *testing.T, serve func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				serve(conn)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// startTLS answers the banner, EHLO with the STARTTLS extension and STARTTLS, then QUIT over TLS
+func startTLS(cert tls.Certificate) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 test ESMTP\r\n")
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "EHLO ") {
+			return
+		}
+		fmt.Fprintf(conn, "250-test\r\n250 STARTTLS\r\n")
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "STARTTLS") {
+			return
+		}
+		fmt.Fprintf(conn, "220 ready\r\n")
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if line, _ := bufio.NewReader(tlsConn).ReadString('\n'); strings.HasPrefix(line, "QUIT") {
+			fmt.Fprintf(tlsConn, "221 bye\r\n")
+		}
+	}
+}
+
+const smtpConfig = `
+smtp:
+  interval: 1s
+  timeout: 2s
+targets:
+  - name: relay
+    host: 127.0.0.1:%s
+    type: SMTP
+    smtp_ehlo: probe.example.com
+    smtp_starttls: true
+    tls_config:
+      insecure_skip_verify: true
+    labels:
+      dc: home
+  - name: rejecting
+    host: 127.0.0.1:%s
+    type: SMTP
+    labels:
+      dc: home
+`
+
+func TestSMTPCollectWithLabels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	relay := smtpServer(t, startTLS(selfSigned(t)))
+	rejecting := smtpServer(t, func(conn net.Conn) {
+		fmt.Fprintf(conn, "554 no service\r\n")
+	})
+
+	file := filepath.Join(t.TempDir(), "network_exporter.yml")
+	if err := os.WriteFile(file, []byte(fmt.Sprintf(smtpConfig, relay, rejecting)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{Cfg: &config.Config{}}
+	if err := sc.ReloadConfig(logger, file, nil); err != nil {
+		t.Fatalf("ReloadConfig: %s", err)
+	}
+
+	m := monitor.NewSMTP(logger, sc, &config.Resolver{Resolver: net.DefaultResolver, Timeout: time.Second}, false, 3, results.NewHub(), nil)
+	defer m.Stop()
+	m.AddTargets()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		metrics := m.ExportMetrics()
+		if r := metrics["relay 127.0.0.1"]; r != nil && r.Success && metrics["rejecting 127.0.0.1"] != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for the probes: %v", metrics)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&SMTP{Monitor: m})
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %s", err)
+	}
+
+	series := map[string][]map[string]string{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			l := map[string]string{}
+			for _, p := range metric.GetLabel() {
+				l[p.GetName()] = p.GetValue()
+			}
+			series[f.GetName()] = append(series[f.GetName()], l)
+		}
+	}
+	for _, name := range []string{"smtp_success", "smtp_connection_seconds", "smtp_tls_info", "smtp_error_reason"} {
+		if len(series[name]) == 0 {
+			t.Errorf("%s not exported", name)
+		}
+		for _, l := range series[name] {
+			if l["dc"] != "home" {
+				t.Errorf("%s without the label of the target: %v", name, l)
+			}
+		}
+	}
+	for _, l := range series["smtp_tls_info"] {
+		if l["name"] != "relay" || l["version"] == "" || l["cipher"] == "" {
+			t.Errorf("smtp_tls_info labels %v, want the version and cipher of the relay", l)
+		}
+	}
+	for _, l := range series["smtp_error_reason"] {
+		if l["name"] != "rejecting" || l["reason"] == "" {
+			t.Errorf("smtp_error_reason labels %v, want the reason of the rejecting target", l)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+var probeSourceInterfaceFailuresDesc = prometheus.NewDesc("probe_source_interface_failures_total", "Probes failed as their source interface had no usable address total", []string{"type", "interface"}, nil)
+
+// SourceInterface prom
+type SourceInterface struct{}
+
+// Describe prom
+func (s *SourceInterface) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeSourceInterfaceFailuresDesc
+}
+
+// Collect prom
+func (s *SourceInterface) Collect(ch chan<- prometheus.Metric) {
+	for k, v := range common.SourceInterfaceFailures.Failures() {
+		ch <- prometheus.MustNewConstMetric(probeSourceInterfaceFailuresDesc, prometheus.CounterValue, float64(v), k[0], k[1])
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/config"
+)
+
+var (
+	srvMemberDesc  = prometheus.NewDesc("network_exporter_srv_member", "Member of an expanded SRV record", []string{"record", "host"}, nil)
+	srvMembersDesc = prometheus.NewDesc("network_exporter_srv_members", "Number of members of an expanded SRV record", []string{"record"}, nil)
+)
+
+// SRV prom
+type SRV struct {
+	SC *config.SafeConfig
+}
+
+// Describe prom
+func (p *SRV) Describe(ch chan<- *prometheus.Desc) {
+	ch <- srvMemberDesc
+	ch <- srvMembersDesc
+}
+
+// Collect prom
+func (p *SRV) Collect(ch chan<- prometheus.Metric) {
+	// A record can be used by several targets (types or sources)
+	members := map[string]map[string]bool{}
+	for _, r := range p.SC.SRVRecords() {
+		if members[r.Record] == nil {
+			members[r.Record] = map[string]bool{}
+		}
+		for _, m := range r.Members {
+			members[r.Record][m] = true
+		}
+	}
+
+	for record, hosts := range members {
+		for host := range hosts {
+			ch <- prometheus.MustNewConstMetric(srvMemberDesc, prometheus.GaugeValue, 1, record, host)
+		}
+		ch <- prometheus.MustNewConstMetric(srvMembersDesc, prometheus.GaugeValue, float64(len(hosts)), record)
+	}
+}
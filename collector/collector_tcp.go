@@ -13,7 +13,17 @@ import (
 var (
 	tcpLabelNames  = []string{"name", "target", "target_ip", "source_ip", "port"}
 	tcpTimeDesc    = prometheus.NewDesc("tcp_connection_seconds", "Connection time in seconds", tcpLabelNames, nil)
+	tcpDNSDesc     = prometheus.NewDesc("tcp_dns_lookup_seconds", "Resolution time of the host by the probe in seconds", tcpLabelNames, nil)
+	tcpTotalDesc   = prometheus.NewDesc("tcp_probe_duration_seconds", "Duration of all the phases of the probe in seconds", tcpLabelNames, nil)
 	tcpStatusDesc  = prometheus.NewDesc("tcp_connection_status", "Connection Status", tcpLabelNames, nil)
+	tcpProxyDesc   = prometheus.NewDesc("tcp_proxy_connection_seconds", "Connect time to the SOCKS5 proxy in seconds", tcpLabelNames, nil)
+	tcpBannerDesc  = prometheus.NewDesc("tcp_banner_match", "Expect regex matched the response read after the connection", tcpLabelNames, nil)
+	tcpBannerTime  = prometheus.NewDesc("tcp_banner_seconds", "Send and response read time after the connection in seconds", tcpLabelNames, nil)
+	tcpTLSTimeDesc = prometheus.NewDesc("tcp_tls_handshake_seconds", "TLS handshake time after the connection in seconds", tcpLabelNames, nil)
+	tcpTLSDesc     = prometheus.NewDesc("tcp_tls_info", "Negotiated TLS version and cipher", append(tcpLabelNames, "version", "cipher"), nil)
+	tcpVerifyDesc  = prometheus.NewDesc("tcp_ssl_verified", "Certificate chain of the server verified", tcpLabelNames, nil)
+	tcpExpiryDesc  = prometheus.NewDesc("tcp_ssl_earliest_cert_expiry", "Earliest expiry of the certificates of the server in unix seconds", tcpLabelNames, nil)
+	tcpErrorDesc   = prometheus.NewDesc("tcp_error_reason", "Phase of the failure of the last probe", append(tcpLabelNames, "reason"), nil)
 	tcpTargetsDesc = prometheus.NewDesc("tcp_targets", "Number of active targets", nil, nil)
 	tcpStateDesc   = prometheus.NewDesc("tcp_up", "Exporter state", nil, nil)
 	tcpMutex       = &sync.Mutex{}
@@ -24,8 +34,18 @@ var (
 
 // tcpDescriptorSet holds all descriptors for a specific label set
 type tcpDescriptorSet struct {
-	time   *prometheus.Desc
-	status *prometheus.Desc
+	time       *prometheus.Desc
+	dns        *prometheus.Desc
+	total      *prometheus.Desc
+	status     *prometheus.Desc
+	proxy      *prometheus.Desc
+	banner     *prometheus.Desc
+	bannerTime *prometheus.Desc
+	tlsTime    *prometheus.Desc
+	tls        *prometheus.Desc
+	verify     *prometheus.Desc
+	expiry     *prometheus.Desc
+	error      *prometheus.Desc
 }
 
 // getTCPDescriptors returns cached or creates new descriptors for a label set
@@ -47,8 +67,18 @@ func getTCPDescriptors(labels prometheus.Labels) *tcpDescriptorSet {
 	}
 
 	descSet := &tcpDescriptorSet{
-		time:   prometheus.NewDesc("tcp_connection_seconds", "Connection time in seconds", tcpLabelNames, labels),
-		status: prometheus.NewDesc("tcp_connection_status", "Connection Status", tcpLabelNames, labels),
+		time:       prometheus.NewDesc("tcp_connection_seconds", "Connection time in seconds", tcpLabelNames, labels),
+		dns:        prometheus.NewDesc("tcp_dns_lookup_seconds", "Resolution time of the host by the probe in seconds", tcpLabelNames, labels),
+		total:      prometheus.NewDesc("tcp_probe_duration_seconds", "Duration of all the phases of the probe in seconds", tcpLabelNames, labels),
+		status:     prometheus.NewDesc("tcp_connection_status", "Connection Status", tcpLabelNames, labels),
+		proxy:      prometheus.NewDesc("tcp_proxy_connection_seconds", "Connect time to the SOCKS5 proxy in seconds", tcpLabelNames, labels),
+		banner:     prometheus.NewDesc("tcp_banner_match", "Expect regex matched the response read after the connection", tcpLabelNames, labels),
+		bannerTime: prometheus.NewDesc("tcp_banner_seconds", "Send and response read time after the connection in seconds", tcpLabelNames, labels),
+		tlsTime:    prometheus.NewDesc("tcp_tls_handshake_seconds", "TLS handshake time after the connection in seconds", tcpLabelNames, labels),
+		tls:        prometheus.NewDesc("tcp_tls_info", "Negotiated TLS version and cipher", append(tcpLabelNames, "version", "cipher"), labels),
+		verify:     prometheus.NewDesc("tcp_ssl_verified", "Certificate chain of the server verified", tcpLabelNames, labels),
+		expiry:     prometheus.NewDesc("tcp_ssl_earliest_cert_expiry", "Earliest expiry of the certificates of the server in unix seconds", tcpLabelNames, labels),
+		error:      prometheus.NewDesc("tcp_error_reason", "Phase of the failure of the last probe", append(tcpLabelNames, "reason"), labels),
 	}
 	tcpDescCache[cacheKey] = descSet
 	return descSet
@@ -64,7 +94,17 @@ type TCP struct {
 // Describe prom
 func (p *TCP) Describe(ch chan<- *prometheus.Desc) {
 	ch <- tcpTimeDesc
+	ch <- tcpDNSDesc
+	ch <- tcpTotalDesc
 	ch <- tcpStatusDesc
+	ch <- tcpProxyDesc
+	ch <- tcpBannerDesc
+	ch <- tcpBannerTime
+	ch <- tcpTLSTimeDesc
+	ch <- tcpTLSDesc
+	ch <- tcpVerifyDesc
+	ch <- tcpExpiryDesc
+	ch <- tcpErrorDesc
 	ch <- tcpTargetsDesc
 	ch <- tcpStateDesc
 }
@@ -102,12 +142,53 @@ func (p *TCP) Collect(ch chan<- prometheus.Metric) {
 		descs := getTCPDescriptors(l2)
 
 		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.ConTime.Seconds(), l...)
+		ch <- prometheus.MustNewConstMetric(descs.total, prometheus.GaugeValue, metric.TotalTime.Seconds(), l...)
+		// The IPs resolved by the monitor have no resolution time
+		if metric.DNS {
+			ch <- prometheus.MustNewConstMetric(descs.dns, prometheus.GaugeValue, metric.DNSTime.Seconds(), l...)
+		}
 
 		if metric.Success {
 			ch <- prometheus.MustNewConstMetric(descs.status, prometheus.GaugeValue, 1, l...)
 		} else {
 			ch <- prometheus.MustNewConstMetric(descs.status, prometheus.GaugeValue, 0, l...)
 		}
+
+		// Only the targets with a proxy export the connect time to the proxy
+		if metric.Proxy {
+			ch <- prometheus.MustNewConstMetric(descs.proxy, prometheus.GaugeValue, metric.ProxyConTime.Seconds(), l...)
+		}
+
+		// Only the targets with tls export the handshake, the certificates of the rejected handshakes are reported without TLS version
+		if metric.TLS {
+			ch <- prometheus.MustNewConstMetric(descs.tlsTime, prometheus.GaugeValue, metric.TLSTime.Seconds(), l...)
+		}
+		if metric.TLSVersion != "" {
+			ch <- prometheus.MustNewConstMetric(descs.tls, prometheus.GaugeValue, 1, append(l, metric.TLSVersion, metric.TLSCipher)...)
+		}
+		if metric.TLSVersion != "" || !metric.TLSEarliestCertExpiry.IsZero() {
+			if metric.TLSVerified {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 1, l...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(descs.verify, prometheus.GaugeValue, 0, l...)
+			}
+		}
+		if !metric.TLSEarliestCertExpiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(descs.expiry, prometheus.GaugeValue, float64(metric.TLSEarliestCertExpiry.Unix()), l...)
+		}
+
+		// Only the targets with an expect regex export the banner check
+		if metric.Banner {
+			ch <- prometheus.MustNewConstMetric(descs.bannerTime, prometheus.GaugeValue, metric.BannerTime.Seconds(), l...)
+			if metric.BannerMatch {
+				ch <- prometheus.MustNewConstMetric(descs.banner, prometheus.GaugeValue, 1, l...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(descs.banner, prometheus.GaugeValue, 0, l...)
+			}
+		}
+		if metric.ErrorReason != "" {
+			ch <- prometheus.MustNewConstMetric(descs.error, prometheus.GaugeValue, 1, append(l, metric.ErrorReason)...)
+		}
 	}
 	ch <- prometheus.MustNewConstMetric(tcpTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
 }
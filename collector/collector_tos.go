@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+var probeTOSFailuresDesc = prometheus.NewDesc("probe_tos_failures_total", "Probes sent unmarked as the TOS of their socket could not be set total", []string{"type"}, nil)
+
+// TOS prom
+type TOS struct{}
+
+// Describe prom
+func (t *TOS) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeTOSFailuresDesc
+}
+
+// Collect prom
+func (t *TOS) Collect(ch chan<- prometheus.Metric) {
+	failures := common.TOSFailures.Failures()
+	for _, socket := range []string{"ICMP", "TCP"} {
+		ch <- prometheus.MustNewConstMetric(probeTOSFailuresDesc, prometheus.CounterValue, float64(failures[socket]), socket)
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/pkg/udp"
+)
+
+var (
+	udpLabelNames  = []string{"name", "target", "target_ip", "source_ip", "port"}
+	udpTimeDesc    = prometheus.NewDesc("udp_reply_seconds", "Round trip time of the reply or port unreachable in seconds", udpLabelNames, nil)
+	udpSuccessDesc = prometheus.NewDesc("udp_success", "Probe replied, or not refused without expect_reply", udpLabelNames, nil)
+	udpOutcomeDesc = prometheus.NewDesc("udp_outcome", "Outcome of the last probe", append(udpLabelNames, "outcome"), nil)
+	udpTargetsDesc = prometheus.NewDesc("udp_targets", "Number of active targets", nil, nil)
+	udpStateDesc   = prometheus.NewDesc("udp_up", "Exporter state", nil, nil)
+	udpMutex       = &sync.Mutex{}
+	// Descriptor cache for custom labels
+	udpDescCache      = make(map[string]*udpDescriptorSet)
+	udpDescCacheMutex sync.RWMutex
+)
+
+// udpDescriptorSet holds all descriptors for a specific label set
+type udpDescriptorSet struct {
+	time    *prometheus.Desc
+	success *prometheus.Desc
+	outcome *prometheus.Desc
+}
+
+// getUDPDescriptors returns cached or creates new descriptors for a label set
+func getUDPDescriptors(labels prometheus.Labels) *udpDescriptorSet {
+	cacheKey := fmt.Sprintf("%v", labels)
+
+	udpDescCacheMutex.RLock()
+	if descSet, exists := udpDescCache[cacheKey]; exists {
+		udpDescCacheMutex.RUnlock()
+		return descSet
+	}
+	udpDescCacheMutex.RUnlock()
+
+	udpDescCacheMutex.Lock()
+	defer udpDescCacheMutex.Unlock()
+
+	if descSet, exists := udpDescCache[cacheKey]; exists {
+		return descSet
+	}
+
+	descSet := &udpDescriptorSet{
+		time:    prometheus.NewDesc("udp_reply_seconds", "Round trip time of the reply or port unreachable in seconds", udpLabelNames, labels),
+		success: prometheus.NewDesc("udp_success", "Probe replied, or not refused without expect_reply", udpLabelNames, labels),
+		outcome: prometheus.NewDesc("udp_outcome", "Outcome of the last probe", append(udpLabelNames, "outcome"), labels),
+	}
+	udpDescCache[cacheKey] = descSet
+	return descSet
+}
+
+// UDP prom
+type UDP struct {
+	Monitor *monitor.UDPPort
+	metrics map[string]*udp.UDPPortReturn
+	labels  map[string]map[string]string
+}
+
+// Describe prom
+func (p *UDP) Describe(ch chan<- *prometheus.Desc) {
+	ch <- udpTimeDesc
+	ch <- udpSuccessDesc
+	ch <- udpOutcomeDesc
+	ch <- udpTargetsDesc
+	ch <- udpStateDesc
+}
+
+// Collect prom
+func (p *UDP) Collect(ch chan<- prometheus.Metric) {
+	udpMutex.Lock()
+	defer udpMutex.Unlock()
+
+	if m := p.Monitor.ExportMetrics(); len(m) > 0 {
+		p.metrics = m
+	}
+
+	if l := p.Monitor.ExportLabels(); len(l) > 0 {
+		p.labels = l
+	}
+
+	if len(p.metrics) > 0 {
+		ch <- prometheus.MustNewConstMetric(udpStateDesc, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(udpStateDesc, prometheus.GaugeValue, 0)
+	}
+
+	targets := []string{}
+	for target, metric := range p.metrics {
+		targets = append(targets, target)
+		l := []string{strings.TrimSuffix(target, " "+metric.DestIp), metric.DestAddr, metric.DestIp, metric.SrcIp, metric.DestPort}
+		l2 := prometheus.Labels(p.labels[target])
+
+		// Get cached descriptors for this label set
+		descs := getUDPDescriptors(l2)
+
+		ch <- prometheus.MustNewConstMetric(descs.time, prometheus.GaugeValue, metric.RTT.Seconds(), l...)
+		if metric.Success {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 1, l...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(descs.success, prometheus.GaugeValue, 0, l...)
+		}
+		ch <- prometheus.MustNewConstMetric(descs.outcome, prometheus.GaugeValue, 1, append(l, metric.Outcome)...)
+	}
+	ch <- prometheus.MustNewConstMetric(udpTargetsDesc, prometheus.GaugeValue, float64(len(targets)))
+}
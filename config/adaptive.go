@@ -0,0 +1,24 @@
+package config
+
+import "fmt"
+
+// Adaptive probes the targets of a check type on the degraded interval while their rounds lose more than the trigger loss (0-1, a failed probe is a loss of 1), until they are clean for the recovery rounds in a row
+type Adaptive struct {
+	DegradedInterval duration `yaml:"degraded_interval" json:"degraded_interval"`
+	TriggerLoss      float64  `yaml:"trigger_loss" json:"trigger_loss"`
+	RecoveryRounds   int      `yaml:"recovery_rounds" json:"recovery_rounds" default:"5"`
+}
+
+// check validates the adaptive probing of a check type probed on the interval
+func (a Adaptive) check(checkType string, interval duration) error {
+	if a.DegradedInterval.Duration() < 0 || (a.DegradedInterval.Duration() > 0 && a.DegradedInterval.Duration() >= interval.Duration()) {
+		return fmt.Errorf("%s.adaptive.degraded_interval must be shorter than the interval", checkType)
+	}
+	if a.TriggerLoss < 0 || a.TriggerLoss >= 1 {
+		return fmt.Errorf("%s.adaptive.trigger_loss must be between 0 and 1 (excluded)", checkType)
+	}
+	if a.RecoveryRounds < 0 {
+		return fmt.Errorf("%s.adaptive.recovery_rounds must be >=0", checkType)
+	}
+	return nil
+}
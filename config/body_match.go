@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compileBodyPatterns compiles the fail_if_body_matches and fail_if_body_not_matches regexes of a target and checks its body_sha256 digest
+func compileBodyPatterns(t Target) (Target, error) {
+	var err error
+	if t.BodySHA256 != "" {
+		if digest, err := hex.DecodeString(t.BodySHA256); err != nil || len(digest) != 32 {
+			return t, fmt.Errorf("body_sha256 %q is not a SHA-256 hex digest", t.BodySHA256)
+		}
+	}
+	if t.bodyMatches, err = compilePatterns("fail_if_body_matches", t.FailIfBodyMatches); err != nil {
+		return t, err
+	}
+	if t.bodyNotMatches, err = compilePatterns("fail_if_body_not_matches", t.FailIfBodyNotMatches); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func compilePatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %s", field, p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// BodyPatterns returns the compiled fail_if_body_matches and fail_if_body_not_matches of the target
+func (t Target) BodyPatterns() (matches []*regexp.Regexp, notMatches []*regexp.Regexp) {
+	return t.bodyMatches, t.bodyNotMatches
+}
+
+// BodyDigest returns the body_sha256 of the target in lower case, empty when the body is not hashed
+func (t Target) BodyDigest() string {
+	return strings.ToLower(t.BodySHA256)
+}
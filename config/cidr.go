@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// expandCIDR replaces the ICMP and MTR targets whose host is a CIDR range with a target per usable address named name-<ip>
+// The network and broadcast addresses of the IPv4 ranges larger than /31 are skipped, the ranges above max addresses are rejected
+func expandCIDR(targets Targets, max int) (Targets, error) {
+	expanded := make(Targets, 0, len(targets))
+	for _, t := range targets {
+		prefix, err := netip.ParsePrefix(t.Host)
+		if err != nil || (!t.Type.ICMP() && !t.Type.MTR()) {
+			expanded = append(expanded, t)
+			continue
+		}
+		prefix = prefix.Masked()
+
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		skipEdges := prefix.Addr().Is4() && hostBits > 1
+		if hostBits >= 31 || usableAddresses(hostBits, skipEdges) > max {
+			return nil, fmt.Errorf("target %s: %s has more than %d addresses (conf.max_cidr_addresses)", t.Name, prefix, max)
+		}
+
+		last := lastAddr(prefix)
+		for ip := prefix.Addr(); prefix.Contains(ip); ip = ip.Next() {
+			if skipEdges && (ip == prefix.Addr() || ip == last) {
+				continue
+			}
+			e := t
+			e.Name = t.Name + "-" + ip.String()
+			e.Host = ip.String()
+			expanded = append(expanded, e)
+		}
+	}
+	return expanded, nil
+}
+
+// usableAddresses returns the number of addresses of a range with the given host bits
+func usableAddresses(hostBits int, skipEdges bool) int {
+	n := 1 << hostBits
+	if skipEdges {
+		n -= 2
+	}
+	return n
+}
+
+// lastAddr returns the last address of a range
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -8,9 +11,15 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/creasty/defaults"
@@ -21,26 +30,264 @@ import (
 
 // Config represents configuration for the exporter
 
-type Targets []struct {
-	Name     string   `yaml:"name" json:"name"`
-	Host     string   `yaml:"host" json:"host"`
-	Type     string   `yaml:"type" json:"type"`
-	Proxy    string   `yaml:"proxy" json:"proxy"`
-	Probe    []string `yaml:"probe" json:"probe"`
-	SourceIp string   `yaml:"source_ip" json:"source_ip"`
-	Labels   extraKV  `yaml:"labels,omitempty" json:"labels,omitempty"`
+// Target a single probe definition
+type Target struct {
+	Name     string     `yaml:"name" json:"name"`
+	Host     string     `yaml:"host" json:"host"`
+	Type     TargetType `yaml:"type" json:"type"`
+	Proxy    string     `yaml:"proxy,omitempty" json:"proxy"`
+	Probe    []string   `yaml:"probe,omitempty" json:"probe"`
+	SourceIp string     `yaml:"source_ip,omitempty" json:"source_ip"`
+	Labels   extraKV    `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// Interval and Timeout override the interval and timeout of the check type when set
+	Interval duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout  duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Count overrides icmp.count when set
+	Count int `yaml:"count,omitempty" json:"count,omitempty"`
+	// MaxHops and MtrCount override mtr.max-hops and mtr.count when set
+	MaxHops  int `yaml:"max_hops,omitempty" json:"max_hops,omitempty"`
+	MtrCount int `yaml:"mtr_count,omitempty" json:"mtr_count,omitempty"`
+	// MtrProtocol overrides mtr.protocol when set
+	MtrProtocol string `yaml:"mtr_protocol,omitempty" json:"mtr_protocol,omitempty"`
+	// PayloadSize overrides icmp.payload_size and mtr.payload_size when set, 0 sends empty echo requests
+	PayloadSize *int `yaml:"payload_size,omitempty" json:"payload_size,omitempty"`
+	// Tos overrides the tos of the ICMP, MTR and TCP check types when set
+	Tos *int `yaml:"tos,omitempty" json:"tos,omitempty"`
+	// IPv6HopLimit overrides icmp.ipv6_hop_limit when set
+	IPv6HopLimit *int `yaml:"ipv6_hop_limit,omitempty" json:"ipv6_hop_limit,omitempty"`
+	// IcmpMode overrides icmp.mode when set
+	IcmpMode string `yaml:"icmp_mode,omitempty" json:"icmp_mode,omitempty"`
+	// Resolve all monitors every address of the MTR host as a separate target instead of the first one
+	Resolve string `yaml:"resolve,omitempty" json:"resolve,omitempty"`
+	// SrvDistribution weighted shards the members of the SRV record across the probes by their weight instead of every probe monitoring all of them
+	SrvDistribution string `yaml:"srv_distribution,omitempty" json:"srv_distribution,omitempty"`
+	// ProxyFromEnv overrides conf.proxy_from_environment when set
+	ProxyFromEnv *bool `yaml:"proxy_from_environment,omitempty" json:"proxy_from_environment,omitempty"`
+	// IPVersion overrides conf.ip_version when set, 4 or 6 probes only the addresses of the family and 0 both
+	IPVersion *int `yaml:"ip_version,omitempty" json:"ip_version,omitempty"`
+	// SourceInterface overrides conf.source_interface when set, the probes use the current address of the interface instead of source_ip
+	SourceInterface string `yaml:"source_interface,omitempty" json:"source_interface,omitempty"`
+	// Enabled false keeps the target in the config without probing it
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// ProxyCredentialsFile user:password of the proxy (or ProxyCredentialsEnv the environment variable holding it), AuthorizationCredentialsFile credentials of the Authorization header (AuthorizationType, Bearer by default) of the HTTPGet requests
+	ProxyCredentialsFile         string `yaml:"proxy_credentials_file,omitempty" json:"proxy_credentials_file,omitempty"`
+	ProxyCredentialsEnv          string `yaml:"proxy_credentials_env,omitempty" json:"proxy_credentials_env,omitempty"`
+	AuthorizationType            string `yaml:"authorization_type,omitempty" json:"authorization_type,omitempty"`
+	AuthorizationCredentialsFile string `yaml:"authorization_credentials_file,omitempty" json:"authorization_credentials_file,omitempty"`
+	// HTTPHeaders headers of the HTTPGet requests, Host overrides the host of the request
+	HTTPHeaders map[string]HeaderValue `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Method, Body (or BodyFile) and ContentType of the HTTPGet requests, GET without body by default
+	Method      string `yaml:"method,omitempty" json:"method,omitempty"`
+	Body        string `yaml:"body,omitempty" json:"body,omitempty"`
+	BodyFile    string `yaml:"body_file,omitempty" json:"body_file,omitempty"`
+	ContentType string `yaml:"content_type,omitempty" json:"content_type,omitempty"`
+	// TLS client settings of the HTTPS requests of an HTTPGet target
+	TLS TLSConfig `yaml:"tls_config,omitempty" json:"tls_config,omitempty"`
+	// ProxyTLS TLS client settings of the connection to the https proxy of an HTTPGet target
+	ProxyTLS TLSConfig `yaml:"proxy_tls_config,omitempty" json:"proxy_tls_config,omitempty"`
+	// ValidStatusCodes status codes of a successful HTTPGet probe, 2xx when empty
+	ValidStatusCodes []int `yaml:"valid_status_codes,omitempty" json:"valid_status_codes,omitempty"`
+	// FailIfBodyMatches and FailIfBodyNotMatches regexes failing an HTTPGet probe when one matches, or does not match, the body
+	FailIfBodyMatches    []string `yaml:"fail_if_body_matches,omitempty" json:"fail_if_body_matches,omitempty"`
+	FailIfBodyNotMatches []string `yaml:"fail_if_body_not_matches,omitempty" json:"fail_if_body_not_matches,omitempty"`
+	// BodySHA256 hex SHA-256 digest failing an HTTPGet probe when the body read has another one
+	BodySHA256 string `yaml:"body_sha256,omitempty" json:"body_sha256,omitempty"`
+	// Connection new (default) opens a new connection for every HTTPGet probe, reuse keeps the connection of the target open between its probes
+	Connection string `yaml:"connection,omitempty" json:"connection,omitempty"`
+	// FollowRedirects false fails the HTTPGet probes redirected, MaxRedirects redirects followed at most (10 when not set)
+	FollowRedirects *bool `yaml:"follow_redirects,omitempty" json:"follow_redirects,omitempty"`
+	MaxRedirects    int   `yaml:"max_redirects,omitempty" json:"max_redirects,omitempty"`
+	// HTTPVersion 1.1 (default) sends the HTTPGet requests over TCP, 3 over QUIC
+	HTTPVersion string `yaml:"http_version,omitempty" json:"http_version,omitempty"`
+	// QueryName, QueryType (A by default), Transport (udp by default) and ExpectedAnswers of the queries of a DNS target
+	QueryName       string   `yaml:"query_name,omitempty" json:"query_name,omitempty"`
+	QueryType       string   `yaml:"query_type,omitempty" json:"query_type,omitempty"`
+	Transport       string   `yaml:"transport,omitempty" json:"transport,omitempty"`
+	ExpectedAnswers []string `yaml:"expected_answers,omitempty" json:"expected_answers,omitempty"`
+	// UDPPayload sent by the probes of a UDP target (empty by default), ExpectReply fails the probes without reply within the timeout
+	UDPPayload  string `yaml:"payload,omitempty" json:"payload,omitempty"`
+	ExpectReply bool   `yaml:"expect_reply,omitempty" json:"expect_reply,omitempty"`
+	// Send bytes written by the probes of a TCP target after the connection, Expect regex matching the response read (ExpectBytes bytes at most, 4096 when not set)
+	Send        string `yaml:"send,omitempty" json:"send,omitempty"`
+	Expect      string `yaml:"expect,omitempty" json:"expect,omitempty"`
+	ExpectBytes int    `yaml:"expect_bytes,omitempty" json:"expect_bytes,omitempty"`
+	// TCPTLS runs a TLS handshake with the tls_config after the connection of a TCP target
+	TCPTLS bool `yaml:"tls,omitempty" json:"tls,omitempty"`
+	// SMTPEhlo name sent with EHLO after the banner of an SMTP target (no EHLO when empty), SMTPStartTLS sends STARTTLS after it and runs a TLS handshake with the tls_config
+	SMTPEhlo     string `yaml:"smtp_ehlo,omitempty" json:"smtp_ehlo,omitempty"`
+	SMTPStartTLS bool   `yaml:"smtp_starttls,omitempty" json:"smtp_starttls,omitempty"`
+	// record SRV record the target was expanded from
+	record string
+	// proxyURL, authorization and headers credentials read from the files and environment at reload time
+	proxyURL      string
+	authorization string
+	headers       map[string]string
+	// requestBody body or content of body_file read at reload time
+	requestBody string
+	// tlsConfig built from tls_config at reload time, tlsFingerprint changes with its settings and certificates
+	tlsConfig      *tls.Config
+	tlsFingerprint string
+	// proxyTLSConfig built from proxy_tls_config at reload time, proxyTLSFingerprint changes with its settings and certificates
+	proxyTLSConfig      *tls.Config
+	proxyTLSFingerprint string
+	// bodyMatches and bodyNotMatches compiled fail_if_body_matches and fail_if_body_not_matches
+	bodyMatches    []*regexp.Regexp
+	bodyNotMatches []*regexp.Regexp
+	// send and expect decoded send and compiled expect
+	send   []byte
+	expect *regexp.Regexp
 }
 
+// Payload returns the payload_size override of the target or size when it is not set
+func (t Target) Payload(size int) int {
+	if t.PayloadSize != nil {
+		return *t.PayloadSize
+	}
+	return size
+}
+
+// MTRProtocol returns the mtr_protocol override of the target in lower case or protocol when it is not set
+func (t Target) MTRProtocol(protocol string) string {
+	if t.MtrProtocol != "" {
+		return strings.ToLower(t.MtrProtocol)
+	}
+	return protocol
+}
+
+// ICMPMode returns the icmp_mode override of the target in lower case or mode when it is not set
+func (t Target) ICMPMode(mode string) string {
+	if t.IcmpMode != "" {
+		return strings.ToLower(t.IcmpMode)
+	}
+	return mode
+}
+
+// IPFamily returns the ip_version override of the target or version when it is not set
+func (t Target) IPFamily(version int) int {
+	if t.IPVersion != nil {
+		return *t.IPVersion
+	}
+	return version
+}
+
+// ProxyFromEnvironment returns the proxy_from_environment override of the target or enabled when it is not set, always false with a proxy and over HTTP/3
+func (t Target) ProxyFromEnvironment(enabled bool) bool {
+	if t.Proxy != "" || t.HTTP3() {
+		return false
+	}
+	if t.ProxyFromEnv != nil {
+		return *t.ProxyFromEnv
+	}
+	return enabled
+}
+
+// Interface returns the source_interface override of the target or iface when it is not set, always empty with a source_ip
+func (t Target) Interface(iface string) string {
+	if t.SourceIp != "" {
+		return ""
+	}
+	if t.SourceInterface != "" {
+		return t.SourceInterface
+	}
+	return iface
+}
+
+// TypeOfService returns the tos override of the target or tos when it is not set
+func (t Target) TypeOfService(tos int) int {
+	if t.Tos != nil {
+		return *t.Tos
+	}
+	return tos
+}
+
+// HopLimit returns the ipv6_hop_limit override of the target or hopLimit when it is not set
+func (t Target) HopLimit(hopLimit int) int {
+	if t.IPv6HopLimit != nil {
+		return *t.IPv6HopLimit
+	}
+	return hopLimit
+}
+
+// Disabled reports if the target is kept in the config without being probed
+func (t Target) Disabled() bool {
+	return t.Enabled != nil && !*t.Enabled
+}
+
+type Targets []Target
+
 type HTTPGet struct {
 	Interval duration `yaml:"interval" json:"interval" default:"15s"`
 	Timeout  duration `yaml:"timeout" json:"timeout" default:"14s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
+	// BodySizeLimit bytes of the body evaluated by fail_if_body_matches and fail_if_body_not_matches
+	BodySizeLimit int64 `yaml:"body_size_limit" json:"body_size_limit" default:"1048576"`
+	// BodyReadLimit bytes of the body read by a probe, the rest is not downloaded, 0 reads the whole body
+	BodyReadLimit int64 `yaml:"body_read_limit" json:"body_read_limit" default:"0"`
+	// Histograms observes the phases of every probe in histograms next to the gauges of the last probe
+	Histograms bool `yaml:"histograms" json:"histograms" default:"false"`
+	// Buckets of the histograms, empty uses metrics.buckets
+	Buckets Buckets `yaml:"buckets" json:"buckets"`
+	// HistogramBuckets deprecated name of Buckets, used when Buckets is empty
+	HistogramBuckets Buckets `yaml:"histogram_buckets,omitempty" json:"histogram_buckets,omitempty"`
+}
+
+type DNS struct {
+	Interval duration `yaml:"interval" json:"interval" default:"5s"`
+	Timeout  duration `yaml:"timeout" json:"timeout" default:"4s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
+}
+
+type UDP struct {
+	Interval duration `yaml:"interval" json:"interval" default:"5s"`
+	Timeout  duration `yaml:"timeout" json:"timeout" default:"4s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
+}
+
+// NTP the servers rate limit their clients, the default interval is longer than the one of the other check types
+type NTP struct {
+	Interval duration `yaml:"interval" json:"interval" default:"30s"`
+	Timeout  duration `yaml:"timeout" json:"timeout" default:"4s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
+}
+
+// SMTP the timeout covers the whole exchange with the server: banner, EHLO, STARTTLS and TLS handshake
+type SMTP struct {
+	Interval duration `yaml:"interval" json:"interval" default:"30s"`
+	Timeout  duration `yaml:"timeout" json:"timeout" default:"10s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
 }
 
 type TCP struct {
 	Interval duration `yaml:"interval" json:"interval" default:"5s"`
 	Timeout  duration `yaml:"timeout" json:"timeout" default:"4s"`
+	// Tos TOS / traffic class byte of the probe packets (0 unmarked)
+	Tos            int    `yaml:"tos" json:"tos" default:"0"`
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
 }
 
+// icmpModes requests of the ICMP probes
+var icmpModes = []string{"echo", "timestamp"}
+
+// mtrProtocols protocols of the MTR probes
+var mtrProtocols = []string{"icmp", "tcp", "udp"}
+
 type MTR struct {
 	Interval    duration `yaml:"interval" json:"interval" default:"5s"`
 	Timeout     duration `yaml:"timeout" json:"timeout" default:"4s"`
@@ -49,6 +296,24 @@ type MTR struct {
 	PayloadSize int      `yaml:"payload_size" json:"payload_size" default:"56"`
 	Protocol    string   `yaml:"protocol" json:"protocol" default:"icmp"`
 	TcpPort     string   `yaml:"tcp_port" json:"tcp_port" default:"80"`
+	UdpPort     string   `yaml:"udp_port" json:"udp_port" default:"33434"`
+	Tos         int      `yaml:"tos" json:"tos" default:"0"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
+	// LookupHopNames resolves the names of the hop addresses with the conf.nameserver resolver, cached for HopNamesTTL
+	LookupHopNames bool     `yaml:"lookup_hop_names" json:"lookup_hop_names"`
+	HopNamesTTL    duration `yaml:"hop_names_ttl" json:"hop_names_ttl" default:"1h"`
+	// GeoIPASNDB and GeoIPCityDB MaxMind GeoLite2-ASN and GeoLite2-City (or Country) databases of the AS numbers and countries of the hops, opened again on reload
+	GeoIPASNDB  string `yaml:"geoip_asn_db" json:"geoip_asn_db"`
+	GeoIPCityDB string `yaml:"geoip_city_db" json:"geoip_city_db"`
+	// PathChangeRounds rounds a new path has to be seen in a row before it is counted as a path change
+	PathChangeRounds int `yaml:"path_change_rounds" json:"path_change_rounds" default:"1"`
+	// PacketInterval delay between the probes of a round, 0 sends them back to back
+	PacketInterval duration `yaml:"packet_interval" json:"packet_interval" default:"0s"`
+	// ParallelHops TTLs of a round probed concurrently, 0 all of them and 1 one after the other
+	ParallelHops int `yaml:"parallel_hops" json:"parallel_hops" default:"0"`
 }
 
 type ICMP struct {
@@ -56,12 +321,63 @@ type ICMP struct {
 	Timeout     duration `yaml:"timeout" json:"timeout" default:"4s"`
 	Count       int      `yaml:"count" json:"count" default:"10"`
 	PayloadSize int      `yaml:"payload_size" json:"payload_size" default:"56"`
+	Tos         int      `yaml:"tos" json:"tos" default:"0"`
+	// IPv6HopLimit hop limit of the IPv6 echo requests (0 the default of 128)
+	IPv6HopLimit int `yaml:"ipv6_hop_limit" json:"ipv6_hop_limit" default:"0"`
+	// Mode echo sends echo requests, timestamp sends timestamp requests measuring the one-way delays as well (IPv4 only)
+	Mode string `yaml:"mode" json:"mode" default:"echo"`
+	// PacketInterval delay between the requests of a round, 0 sends them back to back
+	PacketInterval duration `yaml:"packet_interval" json:"packet_interval" default:"0s"`
+	// IntervalJitter shifts every probe by a random offset within ± the jitter, a percentage of the interval or a duration
+	IntervalJitter Jitter `yaml:"interval_jitter" json:"interval_jitter"`
+	// Adaptive probes the failing targets on a shorter interval
+	Adaptive Adaptive `yaml:"adaptive" json:"adaptive"`
 }
 
 type Conf struct {
-	Refresh           duration `yaml:"refresh" json:"refresh" default:"0s"`
-	Nameserver        string   `yaml:"nameserver" json:"nameserver"`
-	NameserverTimeout duration `yaml:"nameserver_timeout" json:"nameserver_timeout" default:"250ms"`
+	Refresh           duration    `yaml:"refresh" json:"refresh" default:"0s"`
+	Nameserver        nameservers `yaml:"nameserver" json:"nameserver"`
+	NameserverTimeout duration    `yaml:"nameserver_timeout" json:"nameserver_timeout" default:"250ms"`
+	// NameserverTLS client settings of the tls:// and https:// nameservers
+	NameserverTLS TLSConfig `yaml:"nameserver_tls" json:"nameserver_tls"`
+	// NameserverFallback sends the queries failing on all the nameservers to the system nameservers
+	NameserverFallback bool `yaml:"nameserver_fallback" json:"nameserver_fallback"`
+	MaxTargets         int  `yaml:"max_targets" json:"max_targets" default:"0"`
+	// MaxConcurrentProbes probes running at once across all the targets, the probes over the limit are skipped (0 unlimited)
+	MaxConcurrentProbes int `yaml:"max_concurrent_probes" json:"max_concurrent_probes" default:"0"`
+	// ProxyFromEnvironment sends the requests of the HTTPGet targets without proxy through the HTTP_PROXY, HTTPS_PROXY and NO_PROXY proxies
+	ProxyFromEnvironment bool `yaml:"proxy_from_environment" json:"proxy_from_environment" default:"false"`
+	// SpreadProbes spreads the first probes of the targets uniformly over their interval instead of a random delay within 10% of the interval
+	SpreadProbes bool `yaml:"spread_probes" json:"spread_probes" default:"false"`
+	// SourceInterface interface whose current address is the source of the probes of the targets without source_ip
+	SourceInterface string `yaml:"source_interface" json:"source_interface"`
+	// IPVersion family of the addresses probed by the ICMP, MTR, TCP, UDP, DNS, NTP and SMTP targets, 4 or 6 (0 both)
+	IPVersion int `yaml:"ip_version" json:"ip_version" default:"0"`
+	// MaxCIDRAddresses addresses allowed in the CIDR range of a target
+	MaxCIDRAddresses int `yaml:"max_cidr_addresses" json:"max_cidr_addresses" default:"1024"`
+	// OnDuplicate targets of the config file with the name of a previous target fail the config reload (error), are skipped (skip) or renamed (suffix)
+	OnDuplicate string `yaml:"on_duplicate" json:"on_duplicate" default:"error"`
+	// InvalidLabels targets with invalid extra labels are skipped (skip) or fail the config reload (fail)
+	InvalidLabels string `yaml:"invalid_labels" json:"invalid_labels" default:"skip"`
+	// SrvRefresh interval of the expansion of the SRV records between the config reloads, only read at startup
+	SrvRefresh duration `yaml:"srv_refresh" json:"srv_refresh" default:"0s"`
+	// Scheduler of the probes (goroutine or pool), only read at startup
+	Scheduler        string           `yaml:"scheduler" json:"scheduler" default:"goroutine"`
+	SchedulerWorkers SchedulerWorkers `yaml:"scheduler_workers" json:"scheduler_workers"`
+	// Sharding splits the targets between identical exporter instances
+	Sharding Sharding `yaml:"sharding" json:"sharding"`
+}
+
+// SchedulerWorkers probes run concurrently by each check type with the pool scheduler
+type SchedulerWorkers struct {
+	ICMP    int `yaml:"icmp" json:"icmp" default:"256"`
+	MTR     int `yaml:"mtr" json:"mtr" default:"64"`
+	TCP     int `yaml:"tcp" json:"tcp" default:"256"`
+	HTTPGet int `yaml:"http_get" json:"http_get" default:"128"`
+	DNS     int `yaml:"dns" json:"dns" default:"128"`
+	UDP     int `yaml:"udp" json:"udp" default:"256"`
+	NTP     int `yaml:"ntp" json:"ntp" default:"64"`
+	SMTP    int `yaml:"smtp" json:"smtp" default:"64"`
 }
 
 type Config struct {
@@ -70,7 +386,52 @@ type Config struct {
 	MTR     `yaml:"mtr" json:"mtr"`
 	TCP     `yaml:"tcp" json:"tcp"`
 	HTTPGet `yaml:"http_get" json:"http_get"`
+	DNS     `yaml:"dns" json:"dns"`
+	UDP     `yaml:"udp" json:"udp"`
+	NTP     `yaml:"ntp" json:"ntp"`
+	SMTP    `yaml:"smtp" json:"smtp"`
 	Targets `yaml:"targets" json:"targets"`
+	// TargetGroups are expanded into Targets
+	TargetGroups `yaml:"target_groups" json:"target_groups"`
+	// TargetDefaults are merged into Targets
+	TargetDefaults `yaml:"target_defaults" json:"target_defaults"`
+	// TargetFiles glob patterns of the files holding a targets list, they are watched and merged like a discovery source
+	TargetFiles []string `yaml:"target_files" json:"target_files"`
+	Discovery   `yaml:"discovery" json:"discovery"`
+	// Notifications of the target state changes
+	Notifications `yaml:"notifications" json:"notifications"`
+	Metrics       `yaml:"metrics" json:"metrics"`
+}
+
+// Metrics settings shared by the collectors
+type Metrics struct {
+	// Buckets of the histograms of the check types without their own buckets, empty uses the default buckets of the Prometheus client
+	Buckets Buckets `yaml:"buckets" json:"buckets"`
+}
+
+// Buckets upper bounds in seconds of the buckets of a histogram, strictly increasing
+type Buckets []float64
+
+// check returns an error when the bounds are not >0 and strictly increasing
+func (b Buckets) check(name string) error {
+	for i, v := range b {
+		// The negated comparisons reject NaN as well
+		if !(v > 0) || (i > 0 && !(v > b[i-1])) {
+			return fmt.Errorf("%s must be >0 and strictly increasing", name)
+		}
+	}
+	return nil
+}
+
+// HistogramBuckets returns the buckets of the histograms of a check type, its own buckets or metrics.buckets, nil when both are empty
+func (c *Config) HistogramBuckets(own Buckets) []float64 {
+	if len(own) > 0 {
+		return own
+	}
+	if len(c.Metrics.Buckets) > 0 {
+		return c.Metrics.Buckets
+	}
+	return nil
 }
 
 type duration time.Duration
@@ -84,16 +445,108 @@ func (b *extraKV) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return unmarshal(&b.Kv)
 }
 
+// UnmarshalJSON reads the labels of a JSON config file as a map[string]string
+func (b *extraKV) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &b.Kv)
+}
+
+// MarshalYAML writes the labels as a map[string]string
+func (b extraKV) MarshalYAML() (interface{}, error) {
+	return b.Kv, nil
+}
+
 // SafeConfig Safe configuration reload
 type Resolver struct {
 	Resolver *net.Resolver
 	Timeout  time.Duration
+	// fallbacks queries sent to the system nameservers after a failure of all the conf.nameserver servers
+	fallbacks atomic.Uint64
+	// answers queries answered by each conf.nameserver server
+	answers map[string]*atomic.Uint64
 }
 
 // SafeConfig Safe configuration reload
 type SafeConfig struct {
 	Cfg *Config
 	sync.RWMutex
+	// static holds the validated targets of the last loaded config file
+	static Targets
+	// discovered holds the validated targets of each discovery source
+	discovered map[string]Targets
+	// dropped holds the number of discovered targets of each source exceeding max_targets
+	dropped map[string]int
+	// origins holds the origin of each active target key
+	origins map[string]*Origin
+	// conflicts holds the number of targets of each source shadowed by a source with a higher precedence
+	conflicts map[string]int
+	// srv holds the members of the SRV records expanded by each source
+	srv map[string][]SRVRecord
+	// srvSources holds the unexpanded targets of the sources defining SRV records
+	srvSources map[string]Targets
+	// skipped holds the targets of the last loaded config file skipped by the validation
+	skipped []SkippedTarget
+	// disabled holds the targets of the last loaded config file with enabled false
+	disabled Targets
+	// ExpandEnv expands the ${VAR} and $VAR references of the config file before decoding it, $$ is a literal $
+	ExpandEnv bool
+	// ExpandEnvStrict fails the reload on an undefined variable instead of expanding it to an empty string
+	ExpandEnvStrict bool
+	// Strict fails the reload on the keys of the config file not matching any setting instead of logging them
+	Strict bool
+	// ProbeName is matched against the probe lists of the targets instead of the hostname when set
+	ProbeName string
+	// HTTPClient fetches the config file of an http(s) URL, a client with a 30s timeout is used when nil
+	HTTPClient *http.Client
+	// HTTPAuth adds the credentials of the config URL to the requests when set
+	HTTPAuth func(req *http.Request) error
+	// fetched holds the last config file fetched from the URL, reused when the server answers it is not modified
+	fetched    fetchedConfig
+	fetchedMtx sync.Mutex
+}
+
+// fetchedConfig config file fetched from an URL with its validators
+type fetchedConfig struct {
+	url          string
+	etag         string
+	lastModified string
+	data         []byte
+}
+
+// SkippedTarget target of the config file skipped by the validation
+type SkippedTarget struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+}
+
+// SRVRecord members of an SRV record target at its last expansion
+type SRVRecord struct {
+	// Source of the target defining the record: config or the discovery source
+	Source string `json:"source"`
+	// Record SRV record name (_service._proto.domain)
+	Record string `json:"record"`
+	// Name and Type of the target defining the record
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Members targets expanded from the record (host or host:port)
+	Members []string `json:"members"`
+	// LastRefresh time of the last expansion
+	LastRefresh time.Time `json:"last_refresh"`
+	// LastError resolution error of the last expansion
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Origin of an active target
+type Origin struct {
+	// Source identifier: config, api or the discovery source (consul/0)
+	Source string `json:"source"`
+	// Kind of source: config, api, file_sd, dns, consul, kubernetes, http, netbox, kv, ec2 or docker
+	Kind string `json:"kind"`
+	// FirstSeen time the target was first merged from this source
+	FirstSeen time.Time `json:"first_seen"`
+	// LastSeen time the source last reported the target
+	LastSeen time.Time `json:"last_seen"`
 }
 
 func isHTTPURL(s string) bool {
@@ -104,8 +557,8 @@ func isHTTPURL(s string) bool {
 	return u.Scheme == "http" || u.Scheme == "https"
 }
 
-func parse(data []byte, c *Config) error {
-	err := yaml.Unmarshal(data, &c)
+func parse(file string, data []byte, c *Config) error {
+	err := unmarshal(file, data, c)
 
 	if err != nil {
 		return fmt.Errorf("unmarshaling config: %s", err)
@@ -114,166 +567,1067 @@ func parse(data []byte, c *Config) error {
 	return nil
 }
 
-// ReloadConfig Safe configuration reload
-func (sc *SafeConfig) ReloadConfig(logger *slog.Logger, confFile string, confFileHeaders http.Header) (err error) {
-	hostname, err := os.Hostname()
-	if err != nil {
-		return fmt.Errorf("getting hostname: %s", err)
+// isJSON reports if a config file is JSON, by its .json extension or its first non-whitespace byte
+func isJSON(file string, data []byte) bool {
+	if isHTTPURL(file) {
+		if u, err := url.Parse(file); err == nil {
+			file = u.Path
+		}
+	}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// unmarshal decodes a YAML or JSON config file, both use the same structs and go through the same defaults and validation
+func unmarshal(file string, data []byte, v interface{}) error {
+	if isJSON(file, data) {
+		return json.Unmarshal(data, v)
 	}
+	return yaml.Unmarshal(data, v)
+}
+
+// groupRefRe references to the capture groups of a regex ($1, ${1}) preceded by their run of $
+var groupRefRe = regexp.MustCompile(`\$+(?:\d|\{\d+\})`)
 
-	var data []byte
+// escapeGroupRefs escapes the capture group references of the relabel replacements so they are not read as environment variables, an even run of $ is already escaped
+func escapeGroupRefs(s string) string {
+	return groupRefRe.ReplaceAllStringFunc(s, func(m string) string {
+		if n := strings.LastIndex(m, "$") + 1; n%2 == 1 {
+			return "$" + m
+		}
+		return m
+	})
+}
+
+// expandEnv replaces the environment variable references of the config file, the capture group references ($1, ${1}) are kept as is
+func expandEnv(data []byte, strict bool) ([]byte, error) {
+	undefined := []string{}
+	expanded := os.Expand(escapeGroupRefs(string(data)), func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			undefined = common.AppendIfMissing(undefined, name)
+		}
+		return value
+	})
+
+	if strict && len(undefined) > 0 {
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(undefined, ", "))
+	}
+	return []byte(expanded), nil
+}
 
+// readConfig reads a config file or URL and expands its environment variables
+func (sc *SafeConfig) readConfig(logger *slog.Logger, confFile string, confFileHeaders http.Header) (data []byte, err error) {
 	if isHTTPURL(confFile) {
 		logger.Debug("Loading config from HTTP")
 
-		req, err := http.NewRequest("GET", confFile, nil)
+		if data, err = sc.fetchConfig(logger, confFile, confFileHeaders); err != nil {
+			return nil, err
+		}
+	} else {
+		logger.Debug("Loading config from file")
+
+		f, err := os.Open(confFile)
 		if err != nil {
-			return fmt.Errorf("creating request: %s", err)
+			return nil, fmt.Errorf("reading config file: %s", err)
 		}
+		defer f.Close()
 
-		for key, values := range confFileHeaders {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
+		data, err = io.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %s", err)
 		}
+	}
 
-		// Configure transport with connection pooling for better scalability
-		transport := &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-			MaxConnsPerHost:     0,
+	if sc.ExpandEnv {
+		if data, err = expandEnv(data, sc.ExpandEnvStrict); err != nil {
+			return nil, fmt.Errorf("expanding config file: %s", err)
 		}
-		client := &http.Client{
-			Timeout:   30 * time.Second,
-			Transport: transport,
+	}
+	return data, nil
+}
+
+// fetchConfig downloads the config file of an URL, the last one is reused when it did not change (ETag and Last-Modified)
+func (sc *SafeConfig) fetchConfig(logger *slog.Logger, confFile string, confFileHeaders http.Header) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, confFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %s", err)
+	}
+
+	for key, values := range confFileHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("fetching config file: %s", err)
+	}
+	if sc.HTTPAuth != nil {
+		if err := sc.HTTPAuth(req); err != nil {
+			return nil, fmt.Errorf("fetching config file: %s", err)
 		}
-		defer resp.Body.Close()
+	}
 
-		data, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("reading config file: %s", err)
+	sc.fetchedMtx.Lock()
+	defer sc.fetchedMtx.Unlock()
+
+	if sc.fetched.url == confFile {
+		if sc.fetched.etag != "" {
+			req.Header.Set("If-None-Match", sc.fetched.etag)
 		}
-	} else {
-		logger.Debug("Loading config from file")
+		if sc.fetched.lastModified != "" {
+			req.Header.Set("If-Modified-Since", sc.fetched.lastModified)
+		}
+	}
 
-		f, err := os.Open(confFile)
+	client := sc.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching config file: %s", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if sc.fetched.url == confFile && sc.fetched.data != nil {
+			logger.Debug("Config file not modified", "type", "Config", "func", "fetchConfig", "url", confFile)
+			return sc.fetched.data, nil
+		}
+		return nil, fmt.Errorf("fetching config file: unexpected %s", resp.Status)
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fetching config file: %s", strings.TrimSpace(resp.Status+" "+string(body)))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %s", err)
+	}
+
+	sc.fetched = fetchedConfig{url: confFile, etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified"), data: data}
+	return data, nil
+}
+
+// configFiles returns the files of a config directory (*.yml and *.yaml) or glob pattern sorted by name, nil for a single file or URL
+func configFiles(confFile string) ([]string, error) {
+	if isHTTPURL(confFile) {
+		return nil, nil
+	}
+
+	var files []string
+	fi, err := os.Stat(confFile)
+	switch {
+	case err == nil && fi.IsDir():
+		entries, err := os.ReadDir(confFile)
 		if err != nil {
-			return fmt.Errorf("reading config file: %s", err)
+			return nil, fmt.Errorf("reading config directory: %s", err)
 		}
-		defer f.Close()
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yml" || ext == ".yaml" || ext == ".json") {
+				files = append(files, filepath.Join(confFile, e.Name()))
+			}
+		}
+	case err != nil && strings.ContainsAny(confFile, "*?["):
+		matches, err := filepath.Glob(confFile)
+		if err != nil {
+			return nil, fmt.Errorf("matching config files: %s", err)
+		}
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err == nil && !fi.IsDir() {
+				files = append(files, m)
+			}
+		}
+	default:
+		return nil, nil
+	}
 
-		data, err = io.ReadAll(f)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("reading config file: no config files in %s", confFile)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// mergeConfigFiles loads the config files in order, the first one is the base file defining the global sections and the targets of every file are concatenated
+func (sc *SafeConfig) mergeConfigFiles(logger *slog.Logger, files []string, c *Config) error {
+	base := files[0]
+	baseSections := map[string]bool{}
+
+	for i, file := range files {
+		data, err := sc.readConfig(logger, file, nil)
 		if err != nil {
-			return fmt.Errorf("reading config file: %s", err)
+			return fmt.Errorf("%s: %s", file, err)
+		}
+
+		sections := map[string]interface{}{}
+		if err := unmarshal(file, data, &sections); err != nil {
+			return fmt.Errorf("parsing config file %s: %s", file, err)
+		}
+
+		if i == 0 {
+			if err := parse(file, data, c); err != nil {
+				return fmt.Errorf("parsing config file %s: %s", file, err)
+			}
+			if err := sc.checkFields(logger, file, data, c); err != nil {
+				return fmt.Errorf("parsing config file %s: %s", file, err)
+			}
+			for name := range sections {
+				baseSections[name] = true
+			}
+			continue
+		}
+
+		names := make([]string, 0, len(sections))
+		for name := range sections {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == "targets" || name == "target_groups" {
+				continue
+			}
+			if baseSections[name] {
+				return fmt.Errorf("parsing config file: section %s of %s conflicts with the base file %s", name, file, base)
+			}
+			return fmt.Errorf("parsing config file: section %s of %s is only allowed in the base file %s", name, file, base)
 		}
+
+		t := struct {
+			Targets      Targets      `yaml:"targets" json:"targets"`
+			TargetGroups TargetGroups `yaml:"target_groups" json:"target_groups"`
+		}{}
+		if err := unmarshal(file, data, &t); err != nil {
+			return fmt.Errorf("parsing config file %s: unmarshaling config: %s", file, err)
+		}
+		if err := sc.checkFields(logger, file, data, &t); err != nil {
+			return fmt.Errorf("parsing config file %s: %s", file, err)
+		}
+		c.Targets = append(c.Targets, t.Targets...)
+		c.TargetGroups = append(c.TargetGroups, t.TargetGroups...)
+	}
+
+	logger.Debug("Merged config files", "type", "Config", "func", "mergeConfigFiles", "files", len(files), "base", base, "targets", len(c.Targets))
+	return nil
+}
+
+// ReloadConfig Safe configuration reload
+func (sc *SafeConfig) ReloadConfig(logger *slog.Logger, confFile string, confFileHeaders http.Header) (err error) {
+	hostname, err := sc.probeName()
+	if err != nil {
+		return err
 	}
 
 	var c = &Config{}
 
-	err = parse(data, c)
+	files, err := configFiles(confFile)
 	if err != nil {
-		return fmt.Errorf("parsing config file: %s", err)
+		return err
+	}
+	if files != nil {
+		if err := sc.mergeConfigFiles(logger, files, c); err != nil {
+			return err
+		}
+	} else {
+		data, err := sc.readConfig(logger, confFile, confFileHeaders)
+		if err != nil {
+			return err
+		}
+		if err := parse(confFile, data, c); err != nil {
+			return fmt.Errorf("parsing config file: %s", err)
+		}
+		if err := sc.checkFields(logger, confFile, data, c); err != nil {
+			return fmt.Errorf("parsing config file: %s", err)
+		}
 	}
 
 	if err := defaults.Set(c); err != nil {
 		return fmt.Errorf("setting defaults: %s", err)
 	}
 
+	grouped, err := c.TargetGroups.expand()
+	if err != nil {
+		return fmt.Errorf("parsing config file: %s", err)
+	}
+	c.Targets = append(c.Targets, grouped...)
+
+	if c.Targets, err = c.TargetDefaults.apply(c.Targets); err != nil {
+		return fmt.Errorf("parsing config file: %s", err)
+	}
+
+	if c.Targets, err = expandCIDR(c.Targets, c.MaxCIDRAddresses); err != nil {
+		return fmt.Errorf("parsing config file: %s", err)
+	}
+
+	if c.OnDuplicate != "error" && c.OnDuplicate != "skip" && c.OnDuplicate != "suffix" {
+		return fmt.Errorf("conf.on_duplicate must be 'error', 'skip' or 'suffix'")
+	}
+	if c.InvalidLabels != "skip" && c.InvalidLabels != "fail" {
+		return fmt.Errorf("conf.invalid_labels must be 'skip' or 'fail'")
+	}
+	for _, t := range c.Targets {
+		if err := checkProbe(t); err != nil {
+			return fmt.Errorf("parsing config file: %s", err)
+		}
+		if err := checkSrvDistribution(t); err != nil {
+			return fmt.Errorf("parsing config file: %s", err)
+		}
+		if _, err := compileBodyPatterns(t); err != nil {
+			return fmt.Errorf("parsing config file: target %s: %s", t.Name, err)
+		}
+		if _, err := compileTCPBanner(t); err != nil {
+			return fmt.Errorf("parsing config file: target %s: %s", t.Name, err)
+		}
+		if err := checkLabels(t); err != nil && c.InvalidLabels == "fail" {
+			return fmt.Errorf("parsing config file: target %s: %s", t.Name, err)
+		}
+	}
+
+	disabled := Targets{}
+	for _, t := range c.Targets {
+		if t.Disabled() {
+			disabled = append(disabled, t)
+		}
+	}
+
+	if err := c.Sharding.resolve(hostname); err != nil {
+		return err
+	}
+
 	// Validate and Filter config
-	targets := Targets{}
-	re := regexp.MustCompile("^ICMP|MTR|ICMP+MTR|TCP|HTTPGet$")
+	unexpanded := c.Targets
+	targets, records, skipped := filterTargets(logger, hostname, c.Sharding, c.Targets)
+
+	// Remap the filtered targets
+	targets, duplicated, err := dedupTargets(logger, targets, c.OnDuplicate)
+	if err != nil {
+		return fmt.Errorf("parsing config file: %s", err)
+	}
+	c.Targets = targets
+	skipped = append(skipped, duplicated...)
+
+	// Config precheck
+	if c.ICMP.Interval <= 0 || c.MTR.Interval <= 0 || c.TCP.Interval <= 0 || c.HTTPGet.Interval <= 0 || c.DNS.Interval <= 0 || c.UDP.Interval <= 0 || c.NTP.Interval <= 0 || c.SMTP.Interval <= 0 {
+		return fmt.Errorf("intervals (icmp,mtr,tcp,http_get,dns,udp,ntp,smtp) must be >0")
+	}
+	if c.HTTPGet.BodySizeLimit <= 0 {
+		return fmt.Errorf("http_get.body_size_limit must be >0")
+	}
+	if c.HTTPGet.BodyReadLimit < 0 {
+		return fmt.Errorf("http_get.body_read_limit must be >=0")
+	}
+	if err := c.Metrics.Buckets.check("metrics.buckets"); err != nil {
+		return err
+	}
+	if err := c.HTTPGet.Buckets.check("http_get.buckets"); err != nil {
+		return err
+	}
+	if err := c.HTTPGet.HistogramBuckets.check("http_get.histogram_buckets"); err != nil {
+		return err
+	}
+	if len(c.HTTPGet.HistogramBuckets) > 0 {
+		if len(c.HTTPGet.Buckets) > 0 {
+			return fmt.Errorf("http_get.histogram_buckets and http_get.buckets are exclusive, http_get.histogram_buckets is deprecated")
+		}
+		logger.Warn("http_get.histogram_buckets is deprecated, use http_get.buckets", "type", "Config", "func", "ReloadConfig")
+		c.HTTPGet.Buckets = c.HTTPGet.HistogramBuckets
+	}
+	if c.MaxConcurrentProbes < 0 {
+		return fmt.Errorf("conf.max_concurrent_probes must be >=0")
+	}
+	if c.IPVersion != 0 && c.IPVersion != 4 && c.IPVersion != 6 {
+		return fmt.Errorf("conf.ip_version must be 0, 4 or 6")
+	}
+	if c.ICMP.PacketInterval < 0 || c.MTR.PacketInterval < 0 {
+		return fmt.Errorf("packet_interval (icmp,mtr) must be >=0")
+	}
+	if c.MTR.ParallelHops < 0 {
+		return fmt.Errorf("mtr.parallel_hops must be >=0")
+	}
 	for _, t := range c.Targets {
-		if common.SrvRecordCheck(t.Host) {
-			found := re.MatchString(t.Type)
-			if !found {
-				logger.Error("Unknown check type", "type", "Config", "func", "ReloadConfig", "target", t.Name, "check_type", t.Type, "allowed", "(ICMP|MTR|ICMP+MTR|TCP|HTTPGet)")
+		if err := c.checkTiming(t); err != nil {
+			return err
+		}
+		if err := c.checkPacing(t); err != nil {
+			return err
+		}
+		if t.Count < 0 || t.Count > 65500 {
+			return fmt.Errorf("target %s: count must be between 0 and 65500 (0 = default)", t.Name)
+		}
+		if t.MaxHops < 0 || t.MaxHops > 65500 {
+			return fmt.Errorf("target %s: max_hops must be between 0 and 65500", t.Name)
+		}
+		if t.MtrCount < 0 || t.MtrCount > 65500 {
+			return fmt.Errorf("target %s: mtr_count must be between 0 and 65500", t.Name)
+		}
+		if t.MtrProtocol != "" && !slices.Contains(mtrProtocols, t.MTRProtocol("")) {
+			return fmt.Errorf("target %s: mtr_protocol must be 'icmp', 'tcp' or 'udp'", t.Name)
+		}
+		if t.PayloadSize != nil && (*t.PayloadSize < 0 || *t.PayloadSize > 65500) {
+			return fmt.Errorf("target %s: payload_size must be between 0 and 65500", t.Name)
+		}
+		if t.Tos != nil && (*t.Tos < 0 || *t.Tos > 255) {
+			return fmt.Errorf("target %s: tos must be between 0 and 255", t.Name)
+		}
+		if t.IPv6HopLimit != nil && (*t.IPv6HopLimit < 1 || *t.IPv6HopLimit > 255) {
+			return fmt.Errorf("target %s: ipv6_hop_limit must be between 1 and 255", t.Name)
+		}
+		if t.IPVersion != nil && *t.IPVersion != 0 && *t.IPVersion != 4 && *t.IPVersion != 6 {
+			return fmt.Errorf("target %s: ip_version must be 0, 4 or 6", t.Name)
+		}
+		if t.IcmpMode != "" && !slices.Contains(icmpModes, t.ICMPMode("")) {
+			return fmt.Errorf("target %s: icmp_mode must be 'echo' or 'timestamp'", t.Name)
+		}
+		// ICMPv6 has no timestamp message
+		if t.Type.ICMP() && t.ICMPMode(c.ICMP.Mode) == "timestamp" && (common.IPVersion(t.Host) == 6 || t.IPFamily(c.IPVersion) == 6) {
+			return fmt.Errorf("target %s: icmp mode timestamp is not supported by the IPv6 targets", t.Name)
+		}
+		if t.SourceIp != "" && t.SourceInterface != "" {
+			return fmt.Errorf("target %s: source_ip and source_interface are mutually exclusive", t.Name)
+		}
+		if t.Resolve != "" && t.Resolve != "first" && t.Resolve != "all" {
+			return fmt.Errorf("target %s: resolve must be 'first' or 'all'", t.Name)
+		}
+		if len(t.ValidStatusCodes) > 0 && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: valid_status_codes is only supported by the HTTPGet targets", t.Name)
+		}
+		if (len(t.FailIfBodyMatches) > 0 || len(t.FailIfBodyNotMatches) > 0) && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: fail_if_body_matches and fail_if_body_not_matches are only supported by the HTTPGet targets", t.Name)
+		}
+		if t.BodySHA256 != "" && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: body_sha256 is only supported by the HTTPGet targets", t.Name)
+		}
+		if t.Connection != "" && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: connection is only supported by the HTTPGet targets", t.Name)
+		}
+		if t.Connection != "" && t.Connection != "new" && t.Connection != "reuse" {
+			return fmt.Errorf("target %s: connection must be 'new' or 'reuse'", t.Name)
+		}
+		if (t.FollowRedirects != nil || t.MaxRedirects != 0) && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: follow_redirects and max_redirects are only supported by the HTTPGet targets", t.Name)
+		}
+		if t.MaxRedirects < 0 {
+			return fmt.Errorf("target %s: max_redirects must be >=0", t.Name)
+		}
+		if t.HTTPVersion != "" && t.Type != TypeHTTPGet {
+			return fmt.Errorf("target %s: http_version is only supported by the HTTPGet targets", t.Name)
+		}
+		if t.HTTPVersion != "" && t.HTTPVersion != "1.1" && t.HTTPVersion != "3" {
+			return fmt.Errorf("target %s: http_version must be '1.1' or '3'", t.Name)
+		}
+		if t.HTTP3() && !strings.HasPrefix(strings.ToLower(t.Host), "https://") {
+			return fmt.Errorf("target %s: http_version 3 requires an https URL", t.Name)
+		}
+		if t.HTTP3() && (t.Proxy != "" || (t.ProxyFromEnv != nil && *t.ProxyFromEnv)) {
+			return fmt.Errorf("target %s: http_version 3 can not be sent through a proxy", t.Name)
+		}
+		if (t.QueryName != "" || t.QueryType != "" || t.Transport != "" || len(t.ExpectedAnswers) > 0) && t.Type != TypeDNS {
+			return fmt.Errorf("target %s: query_name, query_type, transport and expected_answers are only supported by the DNS targets", t.Name)
+		}
+		if (t.UDPPayload != "" || t.ExpectReply) && t.Type != TypeUDP {
+			return fmt.Errorf("target %s: payload and expect_reply are only supported by the UDP targets", t.Name)
+		}
+		if (t.Send != "" || t.Expect != "" || t.ExpectBytes != 0) && t.Type != TypeTCP {
+			return fmt.Errorf("target %s: send, expect and expect_bytes are only supported by the TCP targets", t.Name)
+		}
+		if t.TCPTLS && t.Type != TypeTCP {
+			return fmt.Errorf("target %s: tls is only supported by the TCP targets", t.Name)
+		}
+		if (t.SMTPEhlo != "" || t.SMTPStartTLS) && t.Type != TypeSMTP {
+			return fmt.Errorf("target %s: smtp_ehlo and smtp_starttls are only supported by the SMTP targets", t.Name)
+		}
+		if t.SMTPStartTLS && t.SMTPEhlo == "" {
+			return fmt.Errorf("target %s: smtp_starttls requires smtp_ehlo", t.Name)
+		}
+		if strings.ContainsAny(t.SMTPEhlo, " \t\r\n") {
+			return fmt.Errorf("target %s: smtp_ehlo must be a single name", t.Name)
+		}
+		if t.Proxy != "" && t.Type == TypeTCP {
+			if u, err := url.Parse(t.Proxy); err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+				return fmt.Errorf("target %s: proxy of the TCP targets must be a socks5://host:port URL", t.Name)
+			}
+		}
+		if (t.Send != "" || t.ExpectBytes != 0) && t.Expect == "" {
+			return fmt.Errorf("target %s: send and expect_bytes require expect", t.Name)
+		}
+		if t.ExpectBytes < 0 || t.ExpectBytes > 65536 {
+			return fmt.Errorf("target %s: expect_bytes must be between 0 and 65536", t.Name)
+		}
+		if sc.Strict && t.RequestBody() != "" && !methodWithBody(t.HTTPMethod()) {
+			return fmt.Errorf("target %s: method %s can not carry a body", t.Name, t.HTTPMethod())
+		}
+		for _, code := range t.ValidStatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("target %s: valid_status_codes must be between 100 and 599", t.Name)
+			}
+		}
+	}
+	if c.MTR.MaxHops < 0 || c.MTR.MaxHops > 65500 {
+		return fmt.Errorf("mtr.max-hops must be between 0 and 65500")
+	}
+	if c.MTR.Count < 0 || c.MTR.Count > 65500 {
+		return fmt.Errorf("mtr.count must be between 0 and 65500")
+	}
+	if c.ICMP.PacketInterval > 0 && time.Duration(c.ICMP.Count)*c.ICMP.PacketInterval.Duration() >= c.ICMP.Timeout.Duration() {
+		return fmt.Errorf("icmp.count * icmp.packet_interval must be shorter than icmp.timeout")
+	}
+	if c.MTR.PacketInterval > 0 && time.Duration(c.MTR.Count)*c.MTR.PacketInterval.Duration() >= c.MTR.Timeout.Duration() {
+		return fmt.Errorf("mtr.count * mtr.packet_interval must be shorter than mtr.timeout")
+	}
+	if c.MTR.PathChangeRounds < 0 {
+		return fmt.Errorf("mtr.path_change_rounds must be >=0")
+	}
+	if c.MTR.LookupHopNames && c.MTR.HopNamesTTL.Duration() <= 0 {
+		return fmt.Errorf("mtr.hop_names_ttl must be >0")
+	}
+	if c.ICMP.PayloadSize < 0 || c.ICMP.PayloadSize > 65500 {
+		return fmt.Errorf("icmp.payload_size must be between 0 and 65500")
+	}
+	if c.MTR.PayloadSize < 0 || c.MTR.PayloadSize > 65500 {
+		return fmt.Errorf("mtr.payload_size must be between 0 and 65500")
+	}
+	for checkType, j := range map[string]struct {
+		jitter   Jitter
+		interval duration
+		adaptive Adaptive
+	}{"icmp": {c.ICMP.IntervalJitter, c.ICMP.Interval, c.ICMP.Adaptive}, "mtr": {c.MTR.IntervalJitter, c.MTR.Interval, c.MTR.Adaptive}, "tcp": {c.TCP.IntervalJitter, c.TCP.Interval, c.TCP.Adaptive}, "http_get": {c.HTTPGet.IntervalJitter, c.HTTPGet.Interval, c.HTTPGet.Adaptive}, "dns": {c.DNS.IntervalJitter, c.DNS.Interval, c.DNS.Adaptive}, "udp": {c.UDP.IntervalJitter, c.UDP.Interval, c.UDP.Adaptive}, "ntp": {c.NTP.IntervalJitter, c.NTP.Interval, c.NTP.Adaptive}, "smtp": {c.SMTP.IntervalJitter, c.SMTP.Interval, c.SMTP.Adaptive}} {
+		if j.jitter.duration > j.interval.Duration()/2 {
+			return fmt.Errorf("%s.interval_jitter must be at most half of the interval", checkType)
+		}
+		if err := j.adaptive.check(checkType, j.interval); err != nil {
+			return err
+		}
+	}
+	if c.ICMP.Tos < 0 || c.ICMP.Tos > 255 || c.MTR.Tos < 0 || c.MTR.Tos > 255 || c.TCP.Tos < 0 || c.TCP.Tos > 255 {
+		return fmt.Errorf("tos (icmp,mtr,tcp) must be between 0 and 255")
+	}
+	if c.ICMP.IPv6HopLimit < 0 || c.ICMP.IPv6HopLimit > 255 {
+		return fmt.Errorf("icmp.ipv6_hop_limit must be between 0 and 255")
+	}
+	if !slices.Contains(icmpModes, c.ICMP.Mode) {
+		return fmt.Errorf("icmp.mode must be 'echo' or 'timestamp'")
+	}
+	if !slices.Contains(mtrProtocols, c.MTR.Protocol) {
+		return fmt.Errorf("mtr.protocol must be 'icmp', 'tcp' or 'udp'")
+	}
+	if port, err := strconv.Atoi(c.MTR.UdpPort); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("mtr.udp_port must be between 1 and 65535")
+	}
+	if c.MaxTargets < 0 {
+		return fmt.Errorf("conf.max_targets must be >=0")
+	}
+	for _, n := range c.Nameserver {
+		if _, err := parseNameserver(n); err != nil {
+			return err
+		}
+	}
+	if c.Scheduler != "goroutine" && c.Scheduler != "pool" {
+		return fmt.Errorf("conf.scheduler must be 'goroutine' or 'pool'")
+	}
+	if w := c.SchedulerWorkers; w.ICMP < 1 || w.MTR < 1 || w.TCP < 1 || w.HTTPGet < 1 || w.DNS < 1 || w.UDP < 1 || w.NTP < 1 || w.SMTP < 1 {
+		return fmt.Errorf("conf.scheduler_workers (icmp,mtr,tcp,http_get,dns,udp,ntp,smtp) must be >0")
+	}
+	for i, p := range c.TargetFiles {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("target_files[%d]: %s", i, err)
+		}
+	}
+	if err := c.Discovery.validate(); err != nil {
+		return err
+	}
+	if err := c.Notifications.validate(); err != nil {
+		return err
+	}
+
+	sc.Lock()
+	sc.static = c.Targets
+	sc.skipped = skipped
+	sc.disabled = disabled
+	sc.setSRVRecords("config", records)
+	sc.setSRVSource("config", unexpanded)
+	c.Targets = sc.mergeTargets(logger, c.MaxTargets, "config")
+	sc.Cfg = c
+	sc.Unlock()
+
+	return nil
+}
+
+// SetDiscovered replaces the targets of a discovery source and rebuilds the active target list
+func (sc *SafeConfig) SetDiscovered(logger *slog.Logger, source string, t Targets) error {
+	hostname, err := sc.probeName()
+	if err != nil {
+		return err
+	}
+
+	targets, records, _ := filterTargets(logger, hostname, sc.sharding(), t)
+
+	sc.Lock()
+	defer sc.Unlock()
+	sc.setSRVRecords(source, records)
+	sc.setSRVSource(source, t)
+
+	if sc.discovered == nil {
+		sc.discovered = make(map[string]Targets)
+	}
+	if len(targets) == 0 {
+		delete(sc.discovered, source)
+	} else {
+		sc.discovered[source] = targets
+	}
+
+	// Swap in a copy so readers of the previous config are not affected
+	c := *sc.Cfg
+	c.Targets = sc.mergeTargets(logger, c.MaxTargets, source)
+	sc.Cfg = &c
+	return nil
+}
+
+// Skipped returns the targets of the config file skipped by the last reload
+func (sc *SafeConfig) Skipped() []SkippedTarget {
+	sc.RLock()
+	defer sc.RUnlock()
+	return append([]SkippedTarget{}, sc.skipped...)
+}
+
+// Disabled returns the targets of the config file with enabled false of the last reload
+func (sc *SafeConfig) Disabled() Targets {
+	sc.RLock()
+	defer sc.RUnlock()
+	return append(Targets{}, sc.disabled...)
+}
+
+// SRVRecords returns the members of the expanded SRV records of every source
+func (sc *SafeConfig) SRVRecords() []SRVRecord {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	sources := make([]string, 0, len(sc.srv))
+	for source := range sc.srv {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	records := []SRVRecord{}
+	for _, source := range sources {
+		for _, r := range sc.srv[source] {
+			r.Members = append([]string{}, r.Members...)
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// setSRVRecords replaces the SRV records of a source, the caller must hold the lock
+func (sc *SafeConfig) setSRVRecords(source string, records []SRVRecord) {
+	if sc.srv == nil {
+		sc.srv = make(map[string][]SRVRecord)
+	}
+	if len(records) == 0 {
+		delete(sc.srv, source)
+		return
+	}
+	for i := range records {
+		records[i].Source = source
+	}
+	sc.srv[source] = records
+}
+
+// DroppedTargets returns the number of discovered targets of each source that exceeded max_targets
+func (sc *SafeConfig) DroppedTargets() map[string]int {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	d := make(map[string]int, len(sc.dropped))
+	for source, n := range sc.dropped {
+		d[source] = n
+	}
+	return d
+}
+
+// TargetOrigin returns the origin of an active target
+func (sc *SafeConfig) TargetOrigin(t Target) (Origin, bool) {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	o, ok := sc.origins[targetKeys(t)[0]]
+	if !ok {
+		return Origin{}, false
+	}
+	return *o, true
+}
+
+// Conflicts returns the number of targets of each source shadowed by a source with a higher precedence
+func (sc *SafeConfig) Conflicts() map[string]int {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	c := make(map[string]int, len(sc.conflicts))
+	for source, n := range sc.conflicts {
+		c[source] = n
+	}
+	return c
+}
+
+// sourceKind returns the type of a source identifier (consul/0 -> consul)
+func sourceKind(source string) string {
+	return strings.SplitN(source, "/", 2)[0]
+}
+
+// mergeTargets combines the static and discovered targets, when several sources define the same name and type the target
+// of the source with the highest precedence is kept: the configuration file, the api and then the discovery sources ordered by identifier
+// Once maxTargets (0 unlimited) is reached the remaining discovered targets are dropped
+// updated is the source whose targets were just loaded, it refreshes their last seen time
+// The caller must hold the lock
+func (sc *SafeConfig) mergeTargets(logger *slog.Logger, maxTargets int, updated string) Targets {
+	now := time.Now()
+	previous := sc.origins
+	sc.origins = make(map[string]*Origin)
+	sc.dropped = make(map[string]int)
+	sc.conflicts = make(map[string]int)
+
+	// track records the origin of the keys of a kept target
+	track := func(t Target, source string) {
+		for _, k := range targetKeys(t) {
+			o := previous[k]
+			if o == nil || o.Source != source {
+				o = &Origin{Source: source, Kind: sourceKind(source), FirstSeen: now, LastSeen: now}
+				if prev := previous[k]; prev != nil {
+					logger.Info("Target origin changed", "type", "Config", "func", "mergeTargets", "target", k, "origin", source, "previous_origin", prev.Source)
+				} else {
+					logger.Info("Target added", "type", "Config", "func", "mergeTargets", "target", k, "origin", source)
+				}
+			} else if source == updated {
+				o = &Origin{Source: o.Source, Kind: o.Kind, FirstSeen: o.FirstSeen, LastSeen: now}
+			}
+			sc.origins[k] = o
+		}
+	}
+
+	targets := append(Targets{}, sc.static...)
+	for _, t := range targets {
+		track(t, "config")
+	}
+
+	sources := make([]string, 0, len(sc.discovered))
+	for source := range sc.discovered {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if (sources[i] == "api") != (sources[j] == "api") {
+			return sources[i] == "api"
+		}
+		return sources[i] < sources[j]
+	})
+
+	for _, source := range sources {
+	next:
+		for _, t := range sc.discovered[source] {
+			keys := targetKeys(t)
+			for _, k := range keys {
+				if o := sc.origins[k]; o != nil {
+					logger.Debug("Skipping duplicated discovered target", "type", "Config", "func", "mergeTargets", "source", source, "target", t.Name, "check_type", t.Type, "kept_origin", o.Source)
+					sc.conflicts[source]++
+					continue next
+				}
+			}
+			if maxTargets > 0 && len(targets) >= maxTargets {
+				sc.dropped[source]++
 				continue
 			}
-			// Check that SRV record's type is TCP, if config's type is TCP
-			if t.Type == "TCP" {
-				if !strings.EqualFold(t.Type, strings.Split(t.Host, ".")[1][1:]) {
+			track(t, source)
+			targets = append(targets, t)
+		}
+		if sc.conflicts[source] > 0 {
+			logger.Warn("Discovered targets shadowed by a source with a higher precedence", "type", "Config", "func", "mergeTargets", "source", source, "conflicts", sc.conflicts[source])
+		}
+		if sc.dropped[source] > 0 {
+			logger.Warn("Discovered targets exceed max_targets", "type", "Config", "func", "mergeTargets", "source", source, "max_targets", maxTargets, "dropped", sc.dropped[source])
+		}
+	}
+
+	for k, o := range previous {
+		if _, ok := sc.origins[k]; !ok {
+			logger.Info("Target removed", "type", "Config", "func", "mergeTargets", "target", k, "origin", o.Source)
+		}
+	}
+	return targets
+}
+
+// targetKeys returns the per monitor type keys used by a target
+func targetKeys(t Target) []string {
+	keys := []string{}
+	for _, m := range t.Type.Monitors() {
+		keys = append(keys, string(m)+"/"+t.Name)
+	}
+	return keys
+}
+
+// filterTargets validates the target types, expands the SRV records and filters out the targets not assigned to the running host or shard
+// The skipped targets are returned with the reason
+func filterTargets(logger *slog.Logger, hostname string, sharding Sharding, in Targets) (Targets, []SRVRecord, []SkippedTarget) {
+	targets := Targets{}
+	records := []SRVRecord{}
+	skipped := []SkippedTarget{}
+	skip := func(t Target, reason string) {
+		skipped = append(skipped, SkippedTarget{Name: t.Name, Type: string(t.Type), Host: t.Host, Reason: reason})
+	}
+	for _, t := range in {
+		if t.Disabled() {
+			skip(t, "disabled")
+			continue
+		}
+		if err := checkLabels(t); err != nil {
+			logger.Error("Invalid target labels", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		var err error
+		if t, err = readCredentials(t); err != nil {
+			logger.Error("Invalid target credentials", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		if t, err = readTLS(t); err != nil {
+			logger.Error("Invalid target TLS config", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		if t, err = readProxyTLS(t); err != nil {
+			logger.Error("Invalid target TLS config", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		if t, err = readRequest(t); err != nil {
+			logger.Error("Invalid target request", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		if t, err = compileBodyPatterns(t); err != nil {
+			logger.Error("Invalid target body patterns", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		if t, err = compileTCPBanner(t); err != nil {
+			logger.Error("Invalid target banner check", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+		checkType, err := ParseTargetType(string(t.Type))
+		if err != nil {
+			logger.Error("Unknown check type", "type", "Config", "func", "ReloadConfig", "target", t.Name, "check_type", t.Type, "allowed", "(ICMP|MTR|ICMP+MTR|TCP|HTTPGet|DNS|UDP|NTP|SMTP)")
+			skip(t, "unknown check type")
+			continue
+		}
+		t.Type = checkType
+		if err := checkDNSQuery(t); err != nil {
+			logger.Error("Invalid target DNS query", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+			skip(t, err.Error())
+			continue
+		}
+
+		if common.SrvRecordCheck(t.Host) {
+			// Check that SRV record's type is TCP or UDP, if config's type is TCP or UDP
+			if t.Type == TypeTCP || t.Type == TypeUDP {
+				if !strings.EqualFold(string(t.Type), strings.Split(t.Host, ".")[1][1:]) {
 					logger.Error("Target type doesn't match SRV record protocol", "type", "Config", "func", "ReloadConfig", "target", t.Name, "check_type", t.Type, "srv_proto", strings.Split(t.Host, ".")[1][1:])
+					skip(t, "check type doesn't match the SRV record protocol")
 					continue
 				}
 			}
 
+			// Filter out the targets that are not assigned to the running host, if the `probe` is not specified don't filter
+			assigned, err := assignedProbe(t.Probe, hostname)
+			if err != nil {
+				logger.Error("Invalid probe pattern", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+				skip(t, "invalid probe pattern")
+				continue
+			}
+			if !assigned {
+				skip(t, "not assigned to this probe")
+				continue
+			}
+			if err := checkSrvDistribution(t); err != nil {
+				logger.Error("Invalid SRV distribution", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+				skip(t, "invalid srv_distribution")
+				continue
+			}
+
+			record := SRVRecord{Record: t.Host, Name: t.Name, Type: string(t.Type), Members: []string{}, LastRefresh: time.Now()}
 			srv_record_hosts, err := common.SrvRecordHosts(t.Host)
 			if err != nil {
 				logger.Error("Error processing SRV record", "type", "Config", "func", "ReloadConfig", "target", t.Host, "err", err)
+				record.LastError = err.Error()
+				records = append(records, record)
+				skip(t, "SRV record resolution failed: "+err.Error())
 				continue
 			}
 
+			if t.SrvDistribution == "weighted" {
+				total := len(srv_record_hosts)
+				srv_record_hosts = srvShard(srv_record_hosts, t.Probe, hostname)
+				logger.Info("SRV record members assigned to this probe", "type", "Config", "func", "ReloadConfig", "target", t.Name, "record", t.Host, "probe", hostname, "members", len(srv_record_hosts), "total", total, "hosts", srvHostNames(srv_record_hosts))
+			}
+
 			for _, srvTarget := range srv_record_hosts {
 				sub_target := t
-				sub_target.Name = srvTarget
-				sub_target.Host = srvTarget
-
-				// Filter out the targets that are not assigned to the running host, if the `probe` is not specified don't filter
-				if sub_target.Probe == nil {
-					targets = append(targets, sub_target)
-				} else {
-					for _, p := range sub_target.Probe {
-						if p == hostname {
-							targets = append(targets, sub_target)
-							break
-						}
-					}
+				sub_target.Name = srvTarget.Host
+				sub_target.Host = srvTarget.Host
+				sub_target.record = t.Host
+				if !sharding.assigned(sub_target) {
+					skip(sub_target, "not assigned to this shard")
+					continue
 				}
+				targets = append(targets, sub_target)
+				record.Members = append(record.Members, srvTarget.Host)
 			}
+			records = append(records, record)
 		} else {
-			found := re.MatchString(t.Type)
-			if !found {
-				logger.Error("Unknown check type", "type", "Config", "func", "ReloadConfig", "target", t.Name, "check_type", t.Type, "allowed", "(ICMP|MTR|ICMP+MTR|TCP|HTTPGet)")
-				continue
-			}
-
 			// Filter out the targets that are not assigned to the running host, if the `probe` is not specified don't filter
-			if t.Probe == nil {
-				targets = append(targets, t)
+			assigned, err := assignedProbe(t.Probe, hostname)
+			if err != nil {
+				logger.Error("Invalid probe pattern", "type", "Config", "func", "ReloadConfig", "target", t.Name, "err", err)
+				skip(t, "invalid probe pattern")
+			} else if !assigned {
+				skip(t, "not assigned to this probe")
+			} else if !sharding.assigned(t) {
+				skip(t, "not assigned to this shard")
 			} else {
-				for _, p := range t.Probe {
-					if p == hostname {
-						targets = append(targets, t)
-						break
-					}
-				}
+				targets = append(targets, t)
 			}
 		}
 	}
+	return targets, records, skipped
+}
 
-	// Remap the filtered targets
-	c.Targets = targets
-
-	if _, err = HasDuplicateTargets(c.Targets); err != nil {
-		return fmt.Errorf("parsing config file: %s", err)
+// checkTiming validates the interval and timeout overrides of a target, the timeout must be shorter than the interval of every check type of the target
+// An interval override alone caps the timeout of the check type
+func (c *Config) checkTiming(t Target) error {
+	if t.Interval < 0 || t.Timeout < 0 {
+		return fmt.Errorf("target %s: interval and timeout must be >0", t.Name)
+	}
+	if t.Timeout == 0 {
+		return nil
 	}
 
-	// Config precheck
-	if c.ICMP.Interval <= 0 || c.MTR.Interval <= 0 || c.TCP.Interval <= 0 || c.HTTPGet.Interval <= 0 {
-		return fmt.Errorf("intervals (icmp,mtr,tcp,http_get) must be >0")
+	intervals := map[string]time.Duration{}
+	switch t.Type {
+	case TypeICMP:
+		intervals["icmp"] = c.ICMP.Interval.Duration()
+	case TypeMTR:
+		intervals["mtr"] = c.MTR.Interval.Duration()
+	case TypeICMPMTR:
+		intervals["icmp"] = c.ICMP.Interval.Duration()
+		intervals["mtr"] = c.MTR.Interval.Duration()
+	case TypeTCP:
+		intervals["tcp"] = c.TCP.Interval.Duration()
+	case TypeHTTPGet:
+		intervals["http_get"] = c.HTTPGet.Interval.Duration()
+	case TypeDNS:
+		intervals["dns"] = c.DNS.Interval.Duration()
+	case TypeUDP:
+		intervals["udp"] = c.UDP.Interval.Duration()
+	case TypeNTP:
+		intervals["ntp"] = c.NTP.Interval.Duration()
+	case TypeSMTP:
+		intervals["smtp"] = c.SMTP.Interval.Duration()
 	}
-	if c.MTR.MaxHops < 0 || c.MTR.MaxHops > 65500 {
-		return fmt.Errorf("mtr.max-hops must be between 0 and 65500")
+	for section, interval := range intervals {
+		if t.Interval > 0 {
+			interval, section = t.Interval.Duration(), "target"
+		}
+		if t.Timeout.Duration() >= interval {
+			return fmt.Errorf("target %s: timeout %s must be shorter than the %s interval %s", t.Name, t.Timeout.Duration(), section, interval)
+		}
 	}
-	if c.MTR.Count < 0 || c.MTR.Count > 65500 {
-		return fmt.Errorf("mtr.count must be between 0 and 65500")
+	return nil
+}
+
+// checkPacing verifies that the packets of a round of the target are paced within its timeout, with its count and timeout overrides
+func (c *Config) checkPacing(t Target) error {
+	check := func(section string, count int, targetCount int, packetInterval duration, timeout duration) error {
+		if targetCount > 0 {
+			count = targetCount
+		}
+		if t.Timeout > 0 {
+			timeout = t.Timeout
+		}
+		if packetInterval > 0 && time.Duration(count)*packetInterval.Duration() >= timeout.Duration() {
+			return fmt.Errorf("target %s: count %d * %s.packet_interval %s must be shorter than the timeout %s", t.Name, count, section, packetInterval.Duration(), timeout.Duration())
+		}
+		return nil
 	}
-	if c.MTR.Protocol != "icmp" && c.MTR.Protocol != "tcp" {
-		return fmt.Errorf("mtr.protocol must be 'icmp' or 'tcp'")
+	if t.Type.ICMP() {
+		if err := check("icmp", c.ICMP.Count, t.Count, c.ICMP.PacketInterval, c.ICMP.Timeout); err != nil {
+			return err
+		}
+	}
+	if t.Type.MTR() {
+		return check("mtr", c.MTR.Count, t.MtrCount, c.MTR.PacketInterval, c.MTR.Timeout)
 	}
+	return nil
+}
 
-	sc.Lock()
-	sc.Cfg = c
-	sc.Unlock()
+// probeName returns the name matched against the probe lists of the targets, the hostname unless ProbeName is set
+func (sc *SafeConfig) probeName() (string, error) {
+	if sc.ProbeName != "" {
+		return sc.ProbeName, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("getting hostname: %s", err)
+	}
+	return hostname, nil
+}
 
+// checkProbe validates the patterns of the probe list of a target
+func checkProbe(t Target) error {
+	for _, p := range t.Probe {
+		if _, err := matchProbe(p, ""); err != nil {
+			return fmt.Errorf("target %s: probe %q: %s", t.Name, p, err)
+		}
+	}
 	return nil
 }
 
+// assignedProbe reports if a target with the probe list runs on the host, all the hosts run the targets without probe list
+func assignedProbe(probe []string, hostname string) (bool, error) {
+	if len(probe) == 0 {
+		return true, nil
+	}
+	for _, p := range probe {
+		matched, err := matchProbe(p, hostname)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchProbe matches an entry of a probe list against the host, re: prefixes a regex matching the whole name and glob: a shell pattern, the other entries are exact names
+func matchProbe(p string, hostname string) (bool, error) {
+	switch {
+	case strings.HasPrefix(p, "re:"):
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(p, "re:") + ")$")
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(hostname), nil
+	case strings.HasPrefix(p, "glob:"):
+		return path.Match(strings.TrimPrefix(p, "glob:"), hostname)
+	default:
+		return p == hostname, nil
+	}
+}
+
 // UnmarshalYAML implements yaml.Unmarshaler interface.
 func (d *duration) UnmarshalYAML(unmashal func(interface{}) error) error {
 	var s string
@@ -288,6 +1642,32 @@ func (d *duration) UnmarshalYAML(unmashal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalJSON reads a duration string (5s) of a JSON config file, or a number of nanoseconds like the JSON encoding of the duration
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case string:
+		dur, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = duration(dur)
+	case float64:
+		*d = duration(value)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// MarshalYAML writes the duration in the format read by UnmarshalYAML
+func (d duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
 // Duration is a convenience getter.
 func (d duration) Duration() time.Duration {
 	return time.Duration(d)
@@ -298,30 +1678,23 @@ func (d *duration) Set(dur time.Duration) {
 	*d = duration(dur)
 }
 
-// HasDuplicateTargets Find duplicates with same type
+// HasDuplicateTargets Find duplicates with same type, ICMP+MTR targets are checked against both the ICMP and MTR ones
 func HasDuplicateTargets(m Targets) (bool, error) {
-	tmp := map[string]map[string]bool{
-		"TCP":     make(map[string]bool),
-		"ICMP":    make(map[string]bool),
-		"MTR":     make(map[string]bool),
-		"HTTPGet": make(map[string]bool),
+	tmp := map[TargetType]map[string]bool{}
+	for _, t := range targetTypes {
+		tmp[t] = make(map[string]bool)
 	}
 
 	for _, t := range m {
-		if t.Type == "ICMP+MTR" {
-			if tmp["MTR"][t.Name] {
-				return true, fmt.Errorf("found duplicated record: %s", t.Name)
-			}
-			tmp["MTR"][t.Name] = true
-			if tmp["ICMP"][t.Name] {
-				return true, fmt.Errorf("found duplicated record: %s", t.Name)
-			}
-			tmp["ICMP"][t.Name] = true
-		} else {
-			if tmp[t.Type][t.Name] {
+		checkType, err := ParseTargetType(string(t.Type))
+		if err != nil {
+			return false, fmt.Errorf("target %s: %s", t.Name, err)
+		}
+		for _, monitor := range checkType.Monitors() {
+			if tmp[monitor][t.Name] {
 				return true, fmt.Errorf("found duplicated record: %s", t.Name)
 			}
-			tmp[t.Type][t.Name] = true
+			tmp[monitor][t.Name] = true
 		}
 	}
 	return false, nil
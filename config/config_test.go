@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reload loads a config file into a new SafeConfig with the unknown fields rejected
+func reload(t *testing.T, file string) (*SafeConfig, error) {
+	t.Helper()
+	sc := &SafeConfig{Cfg: &Config{}, Strict: true, ProbeName: "probe-1"}
+	err := sc.ReloadConfig(slog.New(slog.NewTextHandler(io.Discard, nil)), file, nil)
+	return sc, err
+}
+
+// writeConfig writes a config file in a temporary directory and returns its path
+func writeConfig(t *testing.T, name string, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("NE_HOST", "192.168.0.1")
+	t.Setenv("NE_EMPTY", "")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "braces", in: "host: ${NE_HOST}", want: "host: 192.168.0.1"},
+		{name: "bare", in: "host: $NE_HOST", want: "host: 192.168.0.1"},
+		{name: "empty", in: "host: ${NE_EMPTY}", want: "host: "},
+		{name: "escaped", in: "password: $$NE_HOST", want: "password: $NE_HOST"},
+		{name: "escaped braces", in: "password: $${NE_HOST}", want: "password: ${NE_HOST}"},
+		{name: "escaped dollar", in: "price: 5$$", want: "price: 5$"},
+		{name: "escaped before variable", in: "v: $$$NE_HOST", want: "v: $192.168.0.1"},
+		{name: "double escaped", in: "v: $$$$", want: "v: $$"},
+		{name: "group reference", in: "replacement: $1", want: "replacement: $1"},
+		{name: "group reference braces", in: "replacement: ${1}-x", want: "replacement: ${1}-x"},
+		{name: "group references", in: "replacement: $1.$2", want: "replacement: $1.$2"},
+		{name: "escaped group reference", in: "replacement: $$1", want: "replacement: $1"},
+		{name: "group reference after escape", in: "replacement: $$$1", want: "replacement: $$1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv([]byte(tt.in), true)
+			if err != nil {
+				t.Fatalf("expandEnv(%q): %s", tt.in, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEnvUndefined(t *testing.T) {
+	in := []byte("host: ${NE_UNDEFINED_VAR}\nreplacement: $1")
+
+	if _, err := expandEnv(in, true); err == nil {
+		t.Errorf("expandEnv strict: undefined variable accepted")
+	}
+
+	got, err := expandEnv(in, false)
+	if err != nil {
+		t.Fatalf("expandEnv: %s", err)
+	}
+	if want := "host: \nreplacement: $1"; string(got) != want {
+		t.Errorf("expandEnv = %q, want %q", got, want)
+	}
+}
+
+func TestReloadConfigYAMLJSONEquivalent(t *testing.T) {
+	fromYAML, err := reload(t, "testdata/equivalent.yml")
+	if err != nil {
+		t.Fatalf("loading the YAML config: %s", err)
+	}
+	fromJSON, err := reload(t, "testdata/equivalent.json")
+	if err != nil {
+		t.Fatalf("loading the JSON config: %s", err)
+	}
+
+	if len(fromYAML.Cfg.Targets) != 3 {
+		t.Errorf("YAML config: %d targets, want 3 enabled targets", len(fromYAML.Cfg.Targets))
+	}
+	if !reflect.DeepEqual(fromYAML.Cfg, fromJSON.Cfg) {
+		t.Errorf("YAML and JSON configs differ:\nyaml: %+v\njson: %+v", *fromYAML.Cfg, *fromJSON.Cfg)
+	}
+	if !reflect.DeepEqual(fromYAML.Disabled(), fromJSON.Disabled()) {
+		t.Errorf("YAML and JSON disabled targets differ:\nyaml: %+v\njson: %+v", fromYAML.Disabled(), fromJSON.Disabled())
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	tests := []struct {
+		file string
+		data string
+		want bool
+	}{
+		{file: "network_exporter.json", data: "", want: true},
+		{file: "network_exporter.JSON", data: "", want: true},
+		{file: "network_exporter.yml", data: "conf:\n  refresh: 15m", want: false},
+		{file: "network_exporter.conf", data: "  \n{\"conf\": {}}", want: true},
+		{file: "https://config.internal/network_exporter.json?version=2", data: "", want: true},
+		{file: "https://config.internal/config", data: "targets: []", want: false},
+	}
+	for _, tt := range tests {
+		if got := isJSON(tt.file, []byte(tt.data)); got != tt.want {
+			t.Errorf("isJSON(%q, %q) = %v, want %v", tt.file, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestReloadConfigHistogramBucketsAlias(t *testing.T) {
+	sc, err := reload(t, writeConfig(t, "network_exporter.yml", "http_get:\n  histograms: true\n  histogram_buckets: [0.1, 0.5, 1]\n"))
+	if err != nil {
+		t.Fatalf("ReloadConfig http_get.histogram_buckets: %s", err)
+	}
+	if want := (Buckets{0.1, 0.5, 1}); !reflect.DeepEqual(sc.Cfg.HTTPGet.Buckets, want) {
+		t.Errorf("http_get.buckets %v, want the histogram_buckets %v", sc.Cfg.HTTPGet.Buckets, want)
+	}
+	if got := sc.Cfg.HistogramBuckets(sc.Cfg.HTTPGet.Buckets); !reflect.DeepEqual(got, []float64{0.1, 0.5, 1}) {
+		t.Errorf("HistogramBuckets %v, want the histogram_buckets", got)
+	}
+
+	if _, err := reload(t, writeConfig(t, "network_exporter.yml", "http_get:\n  histogram_buckets: [1, 0.5]\n")); err == nil {
+		t.Errorf("decreasing http_get.histogram_buckets accepted")
+	}
+	if _, err := reload(t, writeConfig(t, "network_exporter.yml", "http_get:\n  buckets: [0.1, 1]\n  histogram_buckets: [0.5, 1]\n")); err == nil {
+		t.Errorf("both http_get.buckets and http_get.histogram_buckets accepted")
+	}
+}
+
+func TestReloadConfigTargetCount(t *testing.T) {
+	config := func(count int) string {
+		return writeConfig(t, "network_exporter.yml", fmt.Sprintf("icmp:\n  count: 6\ntargets:\n  - name: t\n    host: 192.168.0.1\n    type: ICMP\n    count: %d\n", count))
+	}
+	for _, count := range []int{0, 1, 65500} {
+		if _, err := reload(t, config(count)); err != nil {
+			t.Errorf("count %d: %s", count, err)
+		}
+	}
+	for _, count := range []int{-1, 65501} {
+		_, err := reload(t, config(count))
+		if err == nil || !strings.Contains(err.Error(), "count must be between 0 and 65500 (0 = default)") {
+			t.Errorf("count %d: %v, want the count range", count, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// readCredentials reads the proxy_credentials_file (user:password injected into the proxy URL) and authorization_credentials_file (Authorization header of the HTTPGet requests) of a target
+// The files are read on every config reload so the rotated credentials are picked up
+func readCredentials(t Target) (Target, error) {
+	t.proxyURL, t.authorization, t.headers = "", "", nil
+
+	if t.ProxyCredentialsFile != "" || t.ProxyCredentialsEnv != "" {
+		if t.Proxy == "" {
+			return t, fmt.Errorf("proxy_credentials_file and proxy_credentials_env require a proxy")
+		}
+		var creds string
+		var err error
+		switch {
+		case t.ProxyCredentialsFile != "" && t.ProxyCredentialsEnv != "":
+			return t, fmt.Errorf("proxy_credentials_file and proxy_credentials_env are mutually exclusive")
+		case t.ProxyCredentialsFile != "":
+			if creds, err = readCredentialsFile(t.ProxyCredentialsFile); err != nil {
+				return t, fmt.Errorf("proxy_credentials_file: %s", err)
+			}
+		default:
+			if creds = strings.TrimSpace(os.Getenv(t.ProxyCredentialsEnv)); creds == "" {
+				return t, fmt.Errorf("proxy_credentials_env: %s is not set", t.ProxyCredentialsEnv)
+			}
+		}
+		u, err := url.Parse(t.Proxy)
+		if err != nil {
+			return t, fmt.Errorf("invalid proxy URL")
+		}
+		if user, password, ok := strings.Cut(creds, ":"); ok {
+			u.User = url.UserPassword(user, password)
+		} else {
+			u.User = url.User(creds)
+		}
+		t.proxyURL = u.String()
+	}
+
+	if t.AuthorizationCredentialsFile != "" {
+		if t.Type != TypeHTTPGet {
+			return t, fmt.Errorf("authorization_credentials_file is only supported by the HTTPGet targets")
+		}
+		creds, err := readCredentialsFile(t.AuthorizationCredentialsFile)
+		if err != nil {
+			return t, fmt.Errorf("authorization_credentials_file: %s", err)
+		}
+		authType := t.AuthorizationType
+		if authType == "" {
+			authType = "Bearer"
+		}
+		t.authorization = authType + " " + creds
+	}
+
+	if len(t.HTTPHeaders) > 0 {
+		if t.Type != TypeHTTPGet {
+			return t, fmt.Errorf("headers are only supported by the HTTPGet targets")
+		}
+		headers, err := readHeaders(t.HTTPHeaders)
+		if err != nil {
+			return t, fmt.Errorf("headers: %s", err)
+		}
+		t.headers = headers
+	}
+	return t, nil
+}
+
+// readCredentialsFile returns the trimmed content of a credentials file, an empty file is rejected
+func readCredentialsFile(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	creds := strings.TrimSpace(string(b))
+	if creds == "" {
+		return "", fmt.Errorf("%s is empty", file)
+	}
+	return creds, nil
+}
+
+// ProxyURL returns the proxy URL with the credentials of proxy_credentials_file or proxy_credentials_env
+func (t Target) ProxyURL() string {
+	if t.proxyURL != "" {
+		return t.proxyURL
+	}
+	return t.Proxy
+}
+
+// Headers returns the headers of the HTTPGet requests with their values read from the environment and files
+func (t Target) Headers() map[string]string {
+	return t.headers
+}
+
+// Authorization returns the Authorization header built from authorization_credentials_file
+func (t Target) Authorization() string {
+	return t.authorization
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"text/template"
+)
+
+// Discovery dynamic target sources
+type Discovery struct {
+	FileSD     []FileSD       `yaml:"file_sd" json:"file_sd"`
+	DNS        []DNSSD        `yaml:"dns" json:"dns"`
+	Consul     []ConsulSD     `yaml:"consul" json:"consul"`
+	Kubernetes []KubernetesSD `yaml:"kubernetes" json:"kubernetes"`
+	HTTP       []HTTPSD       `yaml:"http" json:"http"`
+	NetBox     []NetBoxSD     `yaml:"netbox" json:"netbox"`
+	KV         []KVSD         `yaml:"kv" json:"kv"`
+	EC2        []EC2SD        `yaml:"ec2" json:"ec2"`
+	Docker     []DockerSD     `yaml:"docker" json:"docker"`
+}
+
+// HTTPAuth credentials used to reach an HTTP endpoint, the files are re-read on every request
+type HTTPAuth struct {
+	Username        string `yaml:"username" json:"username"`
+	Password        string `yaml:"password" json:"password"`
+	PasswordFile    string `yaml:"password_file" json:"password_file"`
+	BearerToken     string `yaml:"bearer_token" json:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file" json:"bearer_token_file"`
+}
+
+// TLSConfig client TLS settings used to reach an HTTPS endpoint
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file" json:"ca_file"`
+	CertFile           string `yaml:"cert_file" json:"cert_file"`
+	KeyFile            string `yaml:"key_file" json:"key_file"`
+	ServerName         string `yaml:"server_name" json:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+}
+
+// RelabelConfig rewrites the labels of the discovered targets
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels" json:"source_labels"`
+	Separator    string   `yaml:"separator" json:"separator" default:";"`
+	Regex        string   `yaml:"regex" json:"regex" default:"(.*)"`
+	TargetLabel  string   `yaml:"target_label" json:"target_label"`
+	Replacement  string   `yaml:"replacement" json:"replacement" default:"$1"`
+	// Action replace, keep, drop or labelmap
+	Action string `yaml:"action" json:"action" default:"replace"`
+}
+
+// FileSD Prometheus file_sd compatible target files
+type FileSD struct {
+	Files           []string        `yaml:"files" json:"files"`
+	RefreshInterval duration        `yaml:"refresh_interval" json:"refresh_interval" default:"5m"`
+	RelabelConfigs  []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// DNSSD targets discovered from DNS records
+type DNSSD struct {
+	NameTemplate string     `yaml:"name_template" json:"name_template" default:"{{.Target}}"`
+	Record       string     `yaml:"record" json:"record" default:"A"`
+	Query        string     `yaml:"query" json:"query"`
+	Type         TargetType `yaml:"type" json:"type" default:"ICMP"`
+	Port         string     `yaml:"port" json:"port"`
+	Refresh      duration   `yaml:"refresh" json:"refresh" default:"30s"`
+	Labels       extraKV    `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// ConsulSD targets discovered from the healthy instances of the Consul catalog services
+type ConsulSD struct {
+	Server         string          `yaml:"server" json:"server" default:"http://localhost:8500"`
+	TokenFile      string          `yaml:"token_file" json:"token_file"`
+	TokenEnv       string          `yaml:"token_env" json:"token_env" default:"CONSUL_HTTP_TOKEN"`
+	Datacenters    []string        `yaml:"datacenters" json:"datacenters"`
+	Services       []string        `yaml:"services" json:"services"`
+	Tags           []string        `yaml:"tags" json:"tags"`
+	Types          []string        `yaml:"types" json:"types" default:"[\"ICMP\",\"TCP\"]"`
+	Refresh        duration        `yaml:"refresh" json:"refresh" default:"30s"`
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// KubernetesSD targets discovered from the Kubernetes API objects
+type KubernetesSD struct {
+	Role          string   `yaml:"role" json:"role" default:"pod"`
+	Kubeconfig    string   `yaml:"kubeconfig" json:"kubeconfig"`
+	Namespaces    []string `yaml:"namespaces" json:"namespaces"`
+	LabelSelector string   `yaml:"label_selector" json:"label_selector"`
+	FieldSelector string   `yaml:"field_selector" json:"field_selector"`
+	Types         []string `yaml:"types" json:"types" default:"[\"ICMP\"]"`
+	Resync        duration `yaml:"resync" json:"resync" default:"10m"`
+	// NodeAddressType address of the nodes preferred as host: InternalIP, ExternalIP or Hostname
+	NodeAddressType string `yaml:"node_address_type" json:"node_address_type" default:"InternalIP"`
+	// TargetLabels adds the node, zone and namespace labels to the targets
+	TargetLabels   bool            `yaml:"target_labels" json:"target_labels"`
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// HTTPSD targets polled from a Prometheus http_sd compatible endpoint
+type HTTPSD struct {
+	URL            string          `yaml:"url" json:"url"`
+	Refresh        duration        `yaml:"refresh" json:"refresh" default:"60s"`
+	Auth           HTTPAuth        `yaml:"auth" json:"auth"`
+	TLS            TLSConfig       `yaml:"tls" json:"tls"`
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// NetBoxSD targets discovered from the NetBox REST API
+type NetBoxSD struct {
+	URL            string          `yaml:"url" json:"url"`
+	TokenFile      string          `yaml:"token_file" json:"token_file"`
+	Refresh        duration        `yaml:"refresh" json:"refresh" default:"10m"`
+	PageSize       int             `yaml:"page_size" json:"page_size" default:"100"`
+	Types          []string        `yaml:"types" json:"types" default:"[\"ICMP+MTR\"]"`
+	TLS            TLSConfig       `yaml:"tls" json:"tls"`
+	Queries        []NetBoxQuery   `yaml:"queries" json:"queries"`
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// NetBoxQuery objects of an API endpoint providing targets
+type NetBoxQuery struct {
+	Endpoint     string   `yaml:"endpoint" json:"endpoint"`
+	Filter       string   `yaml:"filter" json:"filter"`
+	AddressField string   `yaml:"address_field" json:"address_field" default:"primary_ip"`
+	NameField    string   `yaml:"name_field" json:"name_field"`
+	CustomFields []string `yaml:"custom_fields" json:"custom_fields"`
+}
+
+// KVSD targets watched in an etcd or Redis key space, each value is a single target document
+type KVSD struct {
+	Backend   string    `yaml:"backend" json:"backend" default:"etcd"`
+	Endpoints []string  `yaml:"endpoints" json:"endpoints"`
+	Prefix    string    `yaml:"prefix" json:"prefix"`
+	DB        int       `yaml:"db" json:"db"`
+	Auth      HTTPAuth  `yaml:"auth" json:"auth"`
+	TLS       TLSConfig `yaml:"tls" json:"tls"`
+	Resync    duration  `yaml:"resync" json:"resync" default:"5m"`
+}
+
+// EC2SD targets discovered from the AWS EC2 instances
+type EC2SD struct {
+	Regions         []string           `yaml:"regions" json:"regions"`
+	Filters         []EC2Filter        `yaml:"filters" json:"filters"`
+	Refresh         duration           `yaml:"refresh" json:"refresh" default:"60s"`
+	Address         string             `yaml:"address" json:"address" default:"private"`
+	Types           []string           `yaml:"types" json:"types" default:"[\"ICMP\"]"`
+	Profile         string             `yaml:"profile" json:"profile"`
+	Endpoint        string             `yaml:"endpoint" json:"endpoint"`
+	RoleCredentials EC2RoleCredentials `yaml:"role_credentials" json:"role_credentials"`
+	RelabelConfigs  []RelabelConfig    `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// EC2Filter DescribeInstances filter (tag:<key>, instance-state-name, vpc-id...)
+type EC2Filter struct {
+	Name   string   `yaml:"name" json:"name"`
+	Values []string `yaml:"values" json:"values"`
+}
+
+// EC2RoleCredentials role assumed with the base credentials
+type EC2RoleCredentials struct {
+	RoleARN     string `yaml:"role_arn" json:"role_arn"`
+	ExternalID  string `yaml:"external_id" json:"external_id"`
+	SessionName string `yaml:"session_name" json:"session_name" default:"network_exporter"`
+}
+
+// DockerSD targets discovered from the running containers of a Docker daemon
+type DockerSD struct {
+	Host           string          `yaml:"host" json:"host" default:"unix:///var/run/docker.sock"`
+	Refresh        duration        `yaml:"refresh" json:"refresh" default:"60s"`
+	LabelSelector  string          `yaml:"label_selector" json:"label_selector"`
+	HostAddress    string          `yaml:"host_address" json:"host_address" default:"127.0.0.1"`
+	NetworkICMP    bool            `yaml:"network_icmp" json:"network_icmp"`
+	TLS            TLSConfig       `yaml:"tls" json:"tls"`
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// validate checks the discovery settings
+func (d *Discovery) validate() error {
+	for i, c := range d.FileSD {
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.file_sd[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.DNS {
+		if c.Query == "" {
+			return fmt.Errorf("discovery.dns[%d].query must be set", i)
+		}
+		switch c.Record {
+		case "A", "AAAA", "TXT", "SRV":
+		default:
+			return fmt.Errorf("discovery.dns[%d].record must be one of A, AAAA, TXT or SRV", i)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.dns[%d].refresh must be >0", i)
+		}
+		if _, err := template.New("name").Parse(c.NameTemplate); err != nil {
+			return fmt.Errorf("discovery.dns[%d].name_template: %s", i, err)
+		}
+	}
+	for i, c := range d.Consul {
+		if _, err := url.ParseRequestURI(c.Server); err != nil {
+			return fmt.Errorf("discovery.consul[%d].server: %s", i, err)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.consul[%d].refresh must be >0", i)
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.consul[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.Kubernetes {
+		switch c.Role {
+		case "node", "pod", "service", "endpoints":
+		default:
+			return fmt.Errorf("discovery.kubernetes[%d].role must be one of node, pod, service or endpoints", i)
+		}
+		if c.Resync < 0 {
+			return fmt.Errorf("discovery.kubernetes[%d].resync must be >=0", i)
+		}
+		switch c.NodeAddressType {
+		case "InternalIP", "ExternalIP", "Hostname":
+		default:
+			return fmt.Errorf("discovery.kubernetes[%d].node_address_type must be one of InternalIP, ExternalIP or Hostname", i)
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.kubernetes[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.HTTP {
+		if u, err := url.ParseRequestURI(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("discovery.http[%d].url must be a valid http(s) URL", i)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.http[%d].refresh must be >0", i)
+		}
+		if err := c.Auth.validate(); err != nil {
+			return fmt.Errorf("discovery.http[%d].auth: %s", i, err)
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return fmt.Errorf("discovery.http[%d].tls: cert_file and key_file must be set together", i)
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.http[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.NetBox {
+		if u, err := url.ParseRequestURI(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("discovery.netbox[%d].url must be a valid http(s) URL", i)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.netbox[%d].refresh must be >0", i)
+		}
+		if c.PageSize <= 0 {
+			return fmt.Errorf("discovery.netbox[%d].page_size must be >0", i)
+		}
+		if len(c.Queries) == 0 {
+			return fmt.Errorf("discovery.netbox[%d].queries must be set", i)
+		}
+		for j, q := range c.Queries {
+			if q.Endpoint == "" {
+				return fmt.Errorf("discovery.netbox[%d].queries[%d].endpoint must be set", i, j)
+			}
+			if _, err := url.ParseQuery(q.Filter); err != nil {
+				return fmt.Errorf("discovery.netbox[%d].queries[%d].filter: %s", i, j, err)
+			}
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return fmt.Errorf("discovery.netbox[%d].tls: cert_file and key_file must be set together", i)
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.netbox[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.KV {
+		if c.Backend != "etcd" && c.Backend != "redis" {
+			return fmt.Errorf("discovery.kv[%d].backend must be 'etcd' or 'redis'", i)
+		}
+		if len(c.Endpoints) == 0 {
+			return fmt.Errorf("discovery.kv[%d].endpoints must be set", i)
+		}
+		if c.Resync <= 0 {
+			return fmt.Errorf("discovery.kv[%d].resync must be >0", i)
+		}
+		if c.Auth.BearerToken != "" || c.Auth.BearerTokenFile != "" {
+			return fmt.Errorf("discovery.kv[%d].auth only supports username and password", i)
+		}
+		if err := c.Auth.validate(); err != nil {
+			return fmt.Errorf("discovery.kv[%d].auth: %s", i, err)
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return fmt.Errorf("discovery.kv[%d].tls: cert_file and key_file must be set together", i)
+		}
+	}
+	for i, c := range d.EC2 {
+		if len(c.Regions) == 0 {
+			return fmt.Errorf("discovery.ec2[%d].regions must be set", i)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.ec2[%d].refresh must be >0", i)
+		}
+		if c.Address != "private" && c.Address != "public" {
+			return fmt.Errorf("discovery.ec2[%d].address must be 'private' or 'public'", i)
+		}
+		for j, f := range c.Filters {
+			if f.Name == "" || len(f.Values) == 0 {
+				return fmt.Errorf("discovery.ec2[%d].filters[%d] name and values must be set", i, j)
+			}
+		}
+		if c.Endpoint != "" {
+			if u, err := url.ParseRequestURI(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("discovery.ec2[%d].endpoint must be a valid http(s) URL", i)
+			}
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.ec2[%d].%s", i, err)
+		}
+	}
+	for i, c := range d.Docker {
+		u, err := url.Parse(c.Host)
+		if err != nil || (u.Scheme != "unix" && u.Scheme != "tcp" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("discovery.docker[%d].host must be a unix://, tcp:// or http(s):// address", i)
+		}
+		if c.Refresh <= 0 {
+			return fmt.Errorf("discovery.docker[%d].refresh must be >0", i)
+		}
+		if c.HostAddress == "" {
+			return fmt.Errorf("discovery.docker[%d].host_address must be set", i)
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return fmt.Errorf("discovery.docker[%d].tls: cert_file and key_file must be set together", i)
+		}
+		if err := validateRelabelConfigs(c.RelabelConfigs); err != nil {
+			return fmt.Errorf("discovery.docker[%d].%s", i, err)
+		}
+	}
+	return nil
+}
+
+// validate checks that only one authentication method is used
+func (a HTTPAuth) validate() error {
+	basic := a.Username != "" || a.Password != "" || a.PasswordFile != ""
+	bearer := a.BearerToken != "" || a.BearerTokenFile != ""
+	if basic && bearer {
+		return fmt.Errorf("basic and bearer authentication are mutually exclusive")
+	}
+	if a.Password != "" && a.PasswordFile != "" {
+		return fmt.Errorf("password and password_file are mutually exclusive")
+	}
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("bearer_token and bearer_token_file are mutually exclusive")
+	}
+	return nil
+}
+
+// validateRelabelConfigs checks the relabeling rules
+func validateRelabelConfigs(rules []RelabelConfig) error {
+	for i, r := range rules {
+		if _, err := regexp.Compile("^(?:" + r.Regex + ")$"); err != nil {
+			return fmt.Errorf("relabel_configs[%d].regex: %s", i, err)
+		}
+		switch r.Action {
+		case "replace":
+			if r.TargetLabel == "" {
+				return fmt.Errorf("relabel_configs[%d].target_label must be set", i)
+			}
+		case "keep", "drop", "labelmap":
+		default:
+			return fmt.Errorf("relabel_configs[%d].action must be one of replace, keep, drop or labelmap", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// dnsQueryTypes query types supported by the DNS targets
+var dnsQueryTypes = []string{"A", "AAAA", "SRV", "MX", "TXT"}
+
+// checkDNSQuery validates the query of a DNS target, the expected answers of the A and AAAA queries must be addresses of the family
+func checkDNSQuery(t Target) error {
+	if t.Type != TypeDNS {
+		return nil
+	}
+	if t.QueryName == "" {
+		return fmt.Errorf("query_name is required")
+	}
+	qtype := t.DNSQueryType()
+	if !slices.Contains(dnsQueryTypes, qtype) {
+		return fmt.Errorf("unsupported query_type %s, allowed (%s)", t.QueryType, strings.Join(dnsQueryTypes, "|"))
+	}
+	if transport := t.DNSTransport(); transport != "udp" && transport != "tcp" {
+		return fmt.Errorf("transport must be 'udp' or 'tcp'")
+	}
+	for _, answer := range t.ExpectedAnswers {
+		if qtype != "A" && qtype != "AAAA" {
+			break
+		}
+		ip, err := netip.ParseAddr(strings.TrimSpace(answer))
+		if err != nil || (qtype == "A") != ip.Is4() {
+			return fmt.Errorf("expected_answers %s is not an address of query_type %s", answer, qtype)
+		}
+	}
+	return nil
+}
+
+// DNSQueryType returns the query_type of the target in upper case, A when it is not set
+func (t Target) DNSQueryType() string {
+	if t.QueryType == "" {
+		return "A"
+	}
+	return strings.ToUpper(t.QueryType)
+}
+
+// DNSTransport returns the transport of the target in lower case, udp when it is not set
+func (t Target) DNSTransport() string {
+	if t.Transport == "" {
+		return "udp"
+	}
+	return strings.ToLower(t.Transport)
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// dedupTargets applies conf.on_duplicate to the targets sharing the name of a check type with a previous target
+// error fails like HasDuplicateTargets, skip drops the duplicates and suffix renames them name-<record> (SRV members) or name-<host>
+func dedupTargets(logger *slog.Logger, targets Targets, mode string) (Targets, []SkippedTarget, error) {
+	if mode == "error" {
+		if _, err := HasDuplicateTargets(targets); err != nil {
+			return nil, nil, err
+		}
+		return targets, nil, nil
+	}
+
+	taken := map[string]bool{}
+	isTaken := func(t Target) bool {
+		for _, k := range targetKeys(t) {
+			if taken[k] {
+				return true
+			}
+		}
+		return false
+	}
+
+	kept := make(Targets, 0, len(targets))
+	skipped := []SkippedTarget{}
+	for _, t := range targets {
+		if isTaken(t) {
+			if mode == "skip" {
+				logger.Warn("Skipping duplicated target", "type", "Config", "func", "dedupTargets", "target", t.Name, "check_type", t.Type, "host", t.Host)
+				skipped = append(skipped, SkippedTarget{Name: t.Name, Type: string(t.Type), Host: t.Host, Reason: "duplicated name"})
+				continue
+			}
+
+			suffix := t.Host
+			if t.record != "" {
+				suffix = t.record
+			}
+			name := t.Name
+			t.Name = name + "-" + suffix
+			for i := 2; isTaken(t); i++ {
+				t.Name = fmt.Sprintf("%s-%s-%d", name, suffix, i)
+			}
+			logger.Warn("Renaming duplicated target", "type", "Config", "func", "dedupTargets", "target", name, "check_type", t.Type, "host", t.Host, "renamed", t.Name)
+		}
+
+		for _, k := range targetKeys(t) {
+			taken[k] = true
+		}
+		kept = append(kept, t)
+	}
+	return kept, skipped, nil
+}
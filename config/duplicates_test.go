@@ -0,0 +1,102 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestHasDuplicateTargetsICMPMTR(t *testing.T) {
+	tests := []struct {
+		name      string
+		targets   Targets
+		duplicate bool
+	}{
+		{name: "distinct names", targets: Targets{{Name: "a", Type: TypeICMPMTR}, {Name: "b", Type: TypeICMPMTR}}},
+		{name: "other check type", targets: Targets{{Name: "a", Type: TypeICMPMTR}, {Name: "a", Type: TypeTCP}}},
+		{name: "same composite", targets: Targets{{Name: "a", Type: TypeICMPMTR}, {Name: "a", Type: TypeICMPMTR}}, duplicate: true},
+		{name: "icmp after composite", targets: Targets{{Name: "a", Type: TypeICMPMTR}, {Name: "a", Type: TypeICMP}}, duplicate: true},
+		{name: "composite after mtr", targets: Targets{{Name: "a", Type: TypeMTR}, {Name: "a", Type: TypeICMPMTR}}, duplicate: true},
+		{name: "icmp and mtr", targets: Targets{{Name: "a", Type: TypeICMP}, {Name: "a", Type: TypeMTR}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicate, err := HasDuplicateTargets(tt.targets)
+			if duplicate != tt.duplicate || (err != nil) != tt.duplicate {
+				t.Errorf("HasDuplicateTargets = %v, %v, want %v", duplicate, err, tt.duplicate)
+			}
+		})
+	}
+}
+
+func TestDedupTargetsICMPMTR(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	targets := func() Targets {
+		return Targets{
+			{Name: "edge", Host: "192.168.0.1", Type: TypeICMPMTR},
+			// Shares the ICMP monitor of the composite target
+			{Name: "edge", Host: "192.168.0.2", Type: TypeICMP},
+			// Shares the MTR monitor of the composite target
+			{Name: "edge", Host: "192.168.0.3", Type: TypeMTR},
+			// Shares both monitors, expanded from an SRV record
+			{Name: "edge", Host: "192.168.0.4", Type: TypeICMPMTR, record: "_icmp._udp.example.com"},
+			{Name: "core", Host: "192.168.0.5", Type: TypeICMPMTR},
+		}
+	}
+
+	if _, _, err := dedupTargets(logger, targets(), "error"); err == nil {
+		t.Errorf("on_duplicate error: duplicates accepted")
+	}
+
+	kept, skipped, err := dedupTargets(logger, targets(), "skip")
+	if err != nil {
+		t.Fatalf("on_duplicate skip: %s", err)
+	}
+	if len(kept) != 2 || kept[0].Host != "192.168.0.1" || kept[1].Name != "core" {
+		t.Errorf("on_duplicate skip: kept %v, want the first edge and core", kept)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("on_duplicate skip: skipped %v, want the 3 duplicates", skipped)
+	}
+
+	kept, skipped, err = dedupTargets(logger, targets(), "suffix")
+	if err != nil {
+		t.Fatalf("on_duplicate suffix: %s", err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("on_duplicate suffix: skipped %v", skipped)
+	}
+	want := []string{"edge", "edge-192.168.0.2", "edge-192.168.0.3", "edge-_icmp._udp.example.com", "core"}
+	if len(kept) != len(want) {
+		t.Fatalf("on_duplicate suffix: kept %v, want %v", kept, want)
+	}
+	for i, name := range want {
+		if kept[i].Name != name {
+			t.Errorf("on_duplicate suffix: target %d named %s, want %s", i, kept[i].Name, name)
+		}
+	}
+	// The renamed targets write distinct names in the ICMP and MTR monitors
+	if duplicate, err := HasDuplicateTargets(kept); duplicate {
+		t.Errorf("on_duplicate suffix: renamed targets still duplicated: %s", err)
+	}
+}
+
+func TestDedupTargetsICMPMTRSuffixCollision(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// The suffixed name of the composite target is already taken in the MTR monitor
+	targets := Targets{
+		{Name: "edge", Host: "192.168.0.1", Type: TypeICMP},
+		{Name: "edge-192.168.0.1", Host: "192.168.0.9", Type: TypeMTR},
+		{Name: "edge", Host: "192.168.0.1", Type: TypeICMPMTR},
+	}
+	kept, _, err := dedupTargets(logger, targets, "suffix")
+	if err != nil {
+		t.Fatalf("on_duplicate suffix: %s", err)
+	}
+	if kept[2].Name != "edge-192.168.0.1-2" {
+		t.Errorf("on_duplicate suffix: composite target named %s, want edge-192.168.0.1-2", kept[2].Name)
+	}
+	if duplicate, err := HasDuplicateTargets(kept); duplicate {
+		t.Errorf("on_duplicate suffix: renamed targets still duplicated: %s", err)
+	}
+}
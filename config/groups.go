@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// groupLabel label set on the targets expanded from a target group
+const groupLabel = "group"
+
+// TargetGroup targets sharing their type, labels, probes and source IP, every host is expanded into a target
+type TargetGroup struct {
+	Name     string      `yaml:"name" json:"name"`
+	Type     TargetType  `yaml:"type" json:"type"`
+	Proxy    string      `yaml:"proxy,omitempty" json:"proxy"`
+	Probe    []string    `yaml:"probe,omitempty" json:"probe"`
+	SourceIp string      `yaml:"source_ip,omitempty" json:"source_ip"`
+	Labels   extraKV     `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Interval duration    `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout  duration    `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Hosts    []GroupHost `yaml:"hosts" json:"hosts"`
+}
+
+type TargetGroups []TargetGroup
+
+// GroupHost host of a target group, either a bare host or a target entry whose values override the ones of the group
+type GroupHost Target
+
+// UnmarshalYAML reads a bare host or a target entry
+func (h *GroupHost) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		h.Host = value.Value
+		return nil
+	}
+	return value.Decode((*Target)(h))
+}
+
+// UnmarshalJSON reads a bare host or a target entry of a JSON config file
+func (h *GroupHost) UnmarshalJSON(data []byte) error {
+	var host string
+	if err := json.Unmarshal(data, &host); err == nil {
+		h.Host = host
+		return nil
+	}
+	return json.Unmarshal(data, (*Target)(h))
+}
+
+// expand returns the targets of the groups, the host names default to the host and the group name is added as the group label
+func (g TargetGroups) expand() (Targets, error) {
+	targets := Targets{}
+	for i, group := range g {
+		if group.Name == "" {
+			return nil, fmt.Errorf("target_groups[%d]: name is required", i)
+		}
+
+		for _, h := range group.Hosts {
+			t := Target(h)
+			if t.Host == "" {
+				return nil, fmt.Errorf("target group %s: host is required", group.Name)
+			}
+			if t.Name == "" {
+				t.Name = t.Host
+			}
+			if t.Type == "" {
+				t.Type = group.Type
+			}
+			if t.Proxy == "" {
+				t.Proxy = group.Proxy
+			}
+			if len(t.Probe) == 0 {
+				t.Probe = group.Probe
+			}
+			if t.SourceIp == "" && t.SourceInterface == "" {
+				t.SourceIp = group.SourceIp
+			}
+			if t.Interval == 0 {
+				t.Interval = group.Interval
+			}
+			if t.Timeout == 0 {
+				t.Timeout = group.Timeout
+			}
+
+			labels := map[string]string{groupLabel: group.Name}
+			for k, v := range group.Labels.Kv {
+				labels[k] = v
+			}
+			for k, v := range h.Labels.Kv {
+				labels[k] = v
+			}
+			t.Labels = extraKV{Kv: labels}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// HeaderValue value of a request header, a literal string or read from an environment variable (env) or a file (file) for the secrets
+type HeaderValue struct {
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
+	Env   string `yaml:"env,omitempty" json:"env,omitempty"`
+	File  string `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
+// headerValue decodes the map form of a header value
+type headerValue HeaderValue
+
+// UnmarshalYAML reads a literal value or a map with one of value, env or file
+func (h *HeaderValue) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*h = HeaderValue{Value: value.Value}
+		return nil
+	}
+	return value.Decode((*headerValue)(h))
+}
+
+// UnmarshalJSON reads a literal value or an object of a JSON config file
+func (h *HeaderValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*h = HeaderValue{Value: s}
+		return nil
+	}
+	return json.Unmarshal(data, (*headerValue)(h))
+}
+
+// read returns the value of the header, the environment variables and files are read on every reload so the rotated secrets are picked up
+func (h HeaderValue) read() (string, error) {
+	set := 0
+	for _, s := range []string{h.Value, h.Env, h.File} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("one of value, env or file is required")
+	}
+
+	switch {
+	case h.Env != "":
+		v, ok := os.LookupEnv(h.Env)
+		if !ok || v == "" {
+			return "", fmt.Errorf("environment variable %s is not set", h.Env)
+		}
+		return strings.TrimSpace(v), nil
+	case h.File != "":
+		return readCredentialsFile(h.File)
+	}
+	return h.Value, nil
+}
+
+// readHeaders returns the headers of a target with their values, the values are not part of the errors as they may be secrets
+func readHeaders(in map[string]HeaderValue) (map[string]string, error) {
+	headers := make(map[string]string, len(in))
+	for name, value := range in {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		v, err := value.read()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("%s: invalid value", name)
+		}
+		headers[http.CanonicalHeaderKey(name)] = v
+	}
+	return headers, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jitter random shift of the probe ticks, a percentage of the interval (10%) or a duration (500ms)
+type Jitter struct {
+	percent  float64
+	duration time.Duration
+}
+
+// parseJitter parses a percentage of the interval or a duration
+func parseJitter(s string) (Jitter, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Jitter{}, nil
+	}
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || percent < 0 || percent > 50 {
+			return Jitter{}, fmt.Errorf("invalid jitter %s, the percentage must be between 0%% and 50%%", s)
+		}
+		return Jitter{percent: percent}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return Jitter{}, fmt.Errorf("invalid jitter %s, a percentage (10%%) or a duration (500ms) is expected", s)
+	}
+	return Jitter{duration: d}, nil
+}
+
+// UnmarshalYAML reads a percentage or a duration
+func (j *Jitter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := parseJitter(s)
+	if err != nil {
+		return err
+	}
+	*j = v
+	return nil
+}
+
+// UnmarshalJSON reads a percentage or a duration of a JSON config file
+func (j *Jitter) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid jitter %s", data)
+	}
+	v, err := parseJitter(s)
+	if err != nil {
+		return err
+	}
+	*j = v
+	return nil
+}
+
+// MarshalYAML writes the jitter in the format read by UnmarshalYAML
+func (j Jitter) MarshalYAML() (interface{}, error) {
+	if j.percent > 0 {
+		return strconv.FormatFloat(j.percent, 'f', -1, 64) + "%", nil
+	}
+	return j.duration.String(), nil
+}
+
+// Duration returns the jitter of an interval, capped to half of the interval so the ticks keep their order
+func (j Jitter) Duration(interval time.Duration) time.Duration {
+	d := j.duration
+	if j.percent > 0 {
+		d = time.Duration(float64(interval) * j.percent / 100)
+	}
+	return min(d, interval/2)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// labelNameRe Prometheus label name
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// probeLabels labels set by network_probe_success for every check type
+var probeLabels = []string{"type", "name", "target", "target_ip"}
+
+// reservedLabels labels set by the collectors of each monitor next to probeLabels, the other check types may use them
+var reservedLabels = map[TargetType][]string{
+	// reason: ping_timestamp_fallback
+	TypeICMP: {"reason"},
+	// hop_name, asn and country: mtr_hop_info
+	TypeMTR: {"ttl", "path", "protocol", "hop_name", "asn", "country"},
+	// version and cipher: tcp_tls_info, reason: tcp_error_reason
+	TypeTCP: {"source_ip", "port", "version", "cipher", "reason"},
+	// final_scheme and final_host of the final URL, version and cipher: http_get_tls_info, reason: http_get_error_reason
+	TypeHTTPGet: {"http_version", "encoding", "version", "cipher", "reason", "final_scheme", "final_host"},
+	// reason: dns_query_error_reason
+	TypeDNS: {"source_ip", "port", "query_name", "query_type", "reason"},
+	// outcome: udp_outcome
+	TypeUDP: {"source_ip", "port", "outcome"},
+	// reason: ntp_error_reason
+	TypeNTP: {"source_ip", "port", "reason"},
+	// version and cipher: smtp_tls_info, reason: smtp_error_reason
+	TypeSMTP: {"source_ip", "port", "version", "cipher", "reason"},
+}
+
+// reservedLabel reports if the label name is set by the collectors of the check type
+func reservedLabel(checkType TargetType, name string) bool {
+	if slices.Contains(probeLabels, name) {
+		return true
+	}
+	for _, m := range normalizeTargetType(string(checkType)).Monitors() {
+		if slices.Contains(reservedLabels[m], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLabels validates the names and values of the extra labels of a target, the names reserved by Prometheus (__) or used by the collectors of its check type are rejected
+func checkLabels(t Target) error {
+	keys := make([]string, 0, len(t.Labels.Kv))
+	for k := range t.Labels.Kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := t.Labels.Kv[k]
+		switch {
+		case !labelNameRe.MatchString(k):
+			return fmt.Errorf("invalid label name %q", k)
+		case strings.HasPrefix(k, "__"):
+			return fmt.Errorf("label name %q is reserved", k)
+		case reservedLabel(t.Type, k):
+			return fmt.Errorf("label name %q is set by the %s collectors", k, t.Type)
+		case !utf8.ValidString(v):
+			return fmt.Errorf("label %s value is not valid UTF-8", k)
+		}
+	}
+	return nil
+}
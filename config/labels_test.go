@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCheckLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		valid  bool
+	}{
+		{name: "valid", labels: map[string]string{"dc": "home", "rack_1": "a1", "_private": "x"}, valid: true},
+		{name: "none", labels: nil, valid: true},
+		{name: "utf-8 value", labels: map[string]string{"site": "Zürich ☁"}, valid: true},
+		{name: "dash", labels: map[string]string{"my-label": "x"}},
+		{name: "leading digit", labels: map[string]string{"1dc": "x"}},
+		{name: "empty name", labels: map[string]string{"": "x"}},
+		{name: "dunder", labels: map[string]string{"__name__": "x"}},
+		{name: "dunder prefix", labels: map[string]string{"__meta_dc": "x"}},
+		{name: "invalid utf-8 value", labels: map[string]string{"dc": "ho\xffme"}},
+		{name: "truncated utf-8 value", labels: map[string]string{"dc": "\xe2\x98"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLabels(Target{Name: "t", Labels: extraKV{Kv: tt.labels}})
+			if tt.valid && err != nil {
+				t.Errorf("checkLabels(%v): %s", tt.labels, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("checkLabels(%v): accepted", tt.labels)
+			}
+		})
+	}
+}
+
+func TestCheckLabelsOtherTypes(t *testing.T) {
+	tests := []struct {
+		kind   TargetType
+		labels map[string]string
+	}{
+		{kind: TypeICMP, labels: map[string]string{"country": "fr", "version": "v2", "port": "80"}},
+		{kind: TypeMTR, labels: map[string]string{"reason": "x", "version": "v2"}},
+		{kind: TypeTCP, labels: map[string]string{"country": "fr", "outcome": "x"}},
+		{kind: TypeHTTPGet, labels: map[string]string{"port": "443", "source_ip": "10.0.0.1"}},
+		{kind: TypeUDP, labels: map[string]string{"reason": "x", "version": "v2"}},
+		{kind: TypeNTP, labels: map[string]string{"version": "4"}},
+	}
+	for _, tt := range tests {
+		if err := checkLabels(Target{Name: "t", Type: tt.kind, Labels: extraKV{Kv: tt.labels}}); err != nil {
+			t.Errorf("checkLabels(%s, %v): %s", tt.kind, tt.labels, err)
+		}
+	}
+}
+
+func TestReloadConfigInvalidLabels(t *testing.T) {
+	const targets = `
+targets:
+  - name: valid
+    host: 192.168.0.1
+    type: ICMP
+    labels:
+      dc: home
+  - name: collision
+    host: 192.168.0.2
+    type: ICMP
+    labels:
+      target: other
+  - name: country
+    host: 192.168.0.3
+    type: ICMP
+    labels:
+      country: fr
+      version: v2
+`
+	sc, err := reload(t, writeConfig(t, "network_exporter.yml", targets))
+	if err != nil {
+		t.Fatalf("ReloadConfig invalid_labels skip: %s", err)
+	}
+	if len(sc.Cfg.Targets) != 2 || sc.Cfg.Targets[0].Name != "valid" || sc.Cfg.Targets[1].Name != "country" {
+		t.Errorf("invalid_labels skip: targets %v, want the valid and country ones", sc.Cfg.Targets)
+	}
+	if skipped := sc.Skipped(); len(skipped) != 1 || skipped[0].Name != "collision" {
+		t.Errorf("invalid_labels skip: skipped %v, want the collision one", skipped)
+	}
+
+	if _, err := reload(t, writeConfig(t, "network_exporter.yml", "conf:\n  invalid_labels: fail\n"+targets)); err == nil {
+		t.Errorf("invalid_labels fail: label of the collectors accepted")
+	}
+}
+
+// collectorLabelTargets targets of every check type with the labels set by its collectors, network_probe_success included
+var collectorLabelTargets = []struct {
+	host   string
+	kind   TargetType
+	labels []string
+}{
+	{host: "192.168.0.1", kind: TypeICMP, labels: []string{"type", "name", "target", "target_ip", "reason"}},
+	{host: "192.168.0.1", kind: TypeMTR, labels: []string{"type", "name", "target", "target_ip", "ttl", "path", "protocol", "hop_name", "asn", "country"}},
+	{host: "192.168.0.1", kind: TypeICMPMTR, labels: []string{"type", "name", "target", "target_ip", "reason", "ttl", "path", "protocol", "hop_name", "asn", "country"}},
+	{host: "192.168.0.1:443", kind: TypeTCP, labels: []string{"type", "name", "target", "target_ip", "source_ip", "port", "version", "cipher", "reason"}},
+	{host: "https://example.com", kind: TypeHTTPGet, labels: []string{"type", "name", "target", "target_ip", "http_version", "encoding", "reason", "version", "cipher", "final_scheme", "final_host"}},
+	{host: "192.168.0.1", kind: TypeDNS, labels: []string{"type", "name", "target", "target_ip", "source_ip", "port", "query_name", "query_type", "reason"}},
+	{host: "192.168.0.1:53", kind: TypeUDP, labels: []string{"type", "name", "target", "target_ip", "source_ip", "port", "outcome"}},
+	{host: "192.168.0.1", kind: TypeNTP, labels: []string{"type", "name", "target", "target_ip", "source_ip", "port", "reason"}},
+	{host: "192.168.0.1:25", kind: TypeSMTP, labels: []string{"type", "name", "target", "target_ip", "source_ip", "port", "version", "cipher", "reason"}},
+}
+
+func TestCollectorLabelTargetsExhaustive(t *testing.T) {
+	covered := map[TargetType]bool{}
+	for _, tt := range collectorLabelTargets {
+		covered[tt.kind] = true
+		reserved := slices.Clone(probeLabels)
+		for _, m := range tt.kind.Monitors() {
+			reserved = append(reserved, reservedLabels[m]...)
+		}
+		slices.Sort(reserved)
+		reserved = slices.Compact(reserved)
+		if labels := slices.Sorted(slices.Values(tt.labels)); !slices.Equal(reserved, labels) {
+			t.Errorf("%s: reserved labels %v, want the ones of its collectors %v", tt.kind, reserved, labels)
+		}
+	}
+	for kind := range reservedLabels {
+		if !covered[kind] {
+			t.Errorf("%s: no target in collectorLabelTargets", kind)
+		}
+	}
+}
+
+func TestReloadConfigCollectorLabels(t *testing.T) {
+	for _, tt := range collectorLabelTargets {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			config := func(label string) string {
+				return writeConfig(t, "network_exporter.yml", fmt.Sprintf("conf:\n  invalid_labels: fail\ntargets:\n  - name: t\n    host: %q\n    type: %s\n    labels:\n      %s: x\n", tt.host, tt.kind, label))
+			}
+			// The same target with another label is valid
+			if _, err := reload(t, config("dc")); err != nil {
+				t.Fatalf("%s target with the label dc: %s", tt.kind, err)
+			}
+			for _, label := range tt.labels {
+				if _, err := reload(t, config(label)); err == nil {
+					t.Errorf("%s target with the label %s of the collectors accepted", tt.kind, label)
+				}
+			}
+		})
+	}
+}
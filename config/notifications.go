@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Notifications target state change notifications
+type Notifications struct {
+	Webhook Webhook `yaml:"webhook" json:"webhook"`
+}
+
+// Webhook receiver of the target state changes, disabled when the url is not set
+type Webhook struct {
+	URL            string    `yaml:"url" json:"url"`
+	Auth           HTTPAuth  `yaml:"auth" json:"auth"`
+	TLS            TLSConfig `yaml:"tls" json:"tls"`
+	MinConsecutive int       `yaml:"min_consecutive" json:"min_consecutive" default:"3"`
+	Cooldown       duration  `yaml:"cooldown" json:"cooldown" default:"5m"`
+	Timeout        duration  `yaml:"timeout" json:"timeout" default:"10s"`
+	Retries        int       `yaml:"retries" json:"retries" default:"3"`
+}
+
+// validate checks the notification settings
+func (n *Notifications) validate() error {
+	w := n.Webhook
+	if w.URL == "" {
+		return nil
+	}
+	if u, err := url.ParseRequestURI(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("notifications.webhook.url must be a valid http(s) URL")
+	}
+	if w.MinConsecutive <= 0 {
+		return fmt.Errorf("notifications.webhook.min_consecutive must be >0")
+	}
+	if w.Cooldown < 0 {
+		return fmt.Errorf("notifications.webhook.cooldown must be >=0")
+	}
+	if w.Timeout <= 0 {
+		return fmt.Errorf("notifications.webhook.timeout must be >0")
+	}
+	if w.Retries < 0 {
+		return fmt.Errorf("notifications.webhook.retries must be >=0")
+	}
+	if err := w.Auth.validate(); err != nil {
+		return fmt.Errorf("notifications.webhook.auth: %s", err)
+	}
+	if (w.TLS.CertFile == "") != (w.TLS.KeyFile == "") {
+		return fmt.Errorf("notifications.webhook.tls: cert_file and key_file must be set together")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// httpMethods methods of the HTTPGet requests
+var httpMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// readRequest validates the method of a target and reads its body_file, the file is read on every config reload like the credentials files
+func readRequest(t Target) (Target, error) {
+	t.requestBody = ""
+	if t.Method == "" && t.Body == "" && t.BodyFile == "" && t.ContentType == "" {
+		return t, nil
+	}
+	if t.Type != TypeHTTPGet {
+		return t, fmt.Errorf("method, body, body_file and content_type are only supported by the HTTPGet targets")
+	}
+
+	t.Method = strings.ToUpper(t.Method)
+	if t.Method != "" && !slices.Contains(httpMethods, t.Method) {
+		return t, fmt.Errorf("method must be one of %s", strings.Join(httpMethods, ", "))
+	}
+	if t.Method == http.MethodHead && (len(t.FailIfBodyMatches) > 0 || len(t.FailIfBodyNotMatches) > 0) {
+		return t, fmt.Errorf("the HEAD responses have no body to match")
+	}
+
+	switch {
+	case t.Body != "" && t.BodyFile != "":
+		return t, fmt.Errorf("body and body_file are mutually exclusive")
+	case t.BodyFile != "":
+		b, err := os.ReadFile(t.BodyFile)
+		if err != nil {
+			return t, fmt.Errorf("body_file: %s", err)
+		}
+		t.requestBody = string(b)
+	default:
+		t.requestBody = t.Body
+	}
+	return t, nil
+}
+
+// methodWithBody reports if the requests of a method carry a body, the bodies of the other ones are rejected with Strict
+func methodWithBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// HTTPMethod returns the method of the HTTPGet requests, GET by default
+func (t Target) HTTPMethod() string {
+	if t.Method == "" {
+		return http.MethodGet
+	}
+	return t.Method
+}
+
+// ReuseConnection reports if the HTTPGet probes keep the connection of the target open between them
+func (t Target) ReuseConnection() bool {
+	return t.Connection == "reuse"
+}
+
+// HTTP3 reports if the HTTPGet requests are sent over QUIC
+func (t Target) HTTP3() bool {
+	return t.HTTPVersion == "3"
+}
+
+// defaultMaxRedirects redirects followed by the HTTPGet probes without max_redirects
+const defaultMaxRedirects = 10
+
+// Redirects returns if the HTTPGet probes follow the redirects, by default, and the maximum of redirects they follow
+func (t Target) Redirects() (follow bool, maxRedirects int) {
+	follow, maxRedirects = true, defaultMaxRedirects
+	if t.FollowRedirects != nil {
+		follow = *t.FollowRedirects
+	}
+	if t.MaxRedirects > 0 {
+		maxRedirects = t.MaxRedirects
+	}
+	return follow, maxRedirects
+}
+
+// RequestBody returns the body of the HTTPGet requests read from body or body_file
+func (t Target) RequestBody() string {
+	return t.requestBody
+}
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// dnsMessageType media type of the DNS-over-HTTPS queries
+const dnsMessageType = "application/dns-message"
+
+// nameservers servers of conf.nameserver, a single server or a list tried in order
+type nameservers []string
+
+// UnmarshalYAML reads a single nameserver or a list
+func (n *nameservers) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		if value.Value != "" {
+			*n = nameservers{value.Value}
+		}
+		return nil
+	}
+	return value.Decode((*[]string)(n))
+}
+
+// UnmarshalJSON reads a single nameserver or a list of a JSON config file
+func (n *nameservers) UnmarshalJSON(data []byte) error {
+	var server string
+	if err := json.Unmarshal(data, &server); err == nil {
+		if server != "" {
+			*n = nameservers{server}
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(n))
+}
+
+// nameserver server parsed from conf.nameserver
+type nameserver struct {
+	name string
+	// scheme udp (retried on tcp when truncated), tls or https
+	scheme  string
+	address string
+}
+
+// parseNameserver parses a host[:port] (53), tls://host[:port] (853) or https:// nameserver
+func parseNameserver(server string) (nameserver, error) {
+	ns := nameserver{name: server}
+	switch {
+	case strings.HasPrefix(server, "tls://"):
+		ns.scheme, ns.address = "tls", withPort(strings.TrimPrefix(server, "tls://"), "853")
+	case strings.HasPrefix(server, "https://"):
+		u, err := url.Parse(server)
+		if err != nil || u.Host == "" {
+			return ns, fmt.Errorf("conf.nameserver %s: invalid URL", server)
+		}
+		return nameserver{name: server, scheme: "https", address: server}, nil
+	default:
+		ns.scheme, ns.address = "udp", withPort(server, "53")
+	}
+	if host, _, _ := net.SplitHostPort(ns.address); host == "" {
+		return ns, fmt.Errorf("conf.nameserver %s: host is required", server)
+	}
+	return ns, nil
+}
+
+// withPort appends the port to an address without one
+func withPort(address string, port string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(strings.Trim(address, "[]"), port)
+	}
+	return address
+}
+
+// NewResolver creates the resolver of the conf.nameserver servers: plain (udp retried on tcp when the answer is truncated), tls:// DNS-over-TLS or https:// DNS-over-HTTPS ones
+// The servers are tried in order with the timeout each, with fallback the queries failing on all of them are sent to the system nameservers
+func NewResolver(logger *slog.Logger, servers []string, timeout time.Duration, tlsConfig *tls.Config, fallback bool) (*Resolver, error) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	ns := make([]nameserver, 0, len(servers))
+	for _, s := range servers {
+		n, err := parseNameserver(s)
+		if err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	x := &exchanger{
+		dialer:    &net.Dialer{Timeout: timeout},
+		tlsConfig: tlsConfig,
+		client:    &http.Client{Transport: transport, Timeout: timeout},
+	}
+
+	// The lookups can try every server
+	r := &Resolver{Timeout: timeout * time.Duration(max(len(ns), 1)), answers: map[string]*atomic.Uint64{}}
+	for _, n := range ns {
+		r.answers[n.name] = &atomic.Uint64{}
+	}
+
+	dial := func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return &streamConn{ctx: ctx, exchange: func(ctx context.Context, query []byte) ([]byte, error) {
+			var err error
+			for _, n := range ns {
+				var answer []byte
+				if answer, err = x.exchange(ctx, n, timeout, query); err == nil {
+					r.answers[n.name].Add(1)
+					return answer, nil
+				}
+				logger.Debug("Nameserver failed", "type", "Config", "func", "NewResolver", "nameserver", n.name, "err", err)
+				if ctx.Err() != nil {
+					return nil, err
+				}
+			}
+			if !fallback {
+				return nil, err
+			}
+
+			// addr is the system nameserver picked by the resolver
+			r.fallbacks.Add(1)
+			logger.Warn("Nameservers failed, falling back to the system nameserver", "type", "Config", "func", "NewResolver", "fallback", addr, "err", err)
+			return x.exchange(ctx, nameserver{name: addr, scheme: "udp", address: addr}, timeout, query)
+		}}, nil
+	}
+
+	r.Resolver = &net.Resolver{PreferGo: true, Dial: dial}
+	return r, nil
+}
+
+// Fallbacks returns the number of queries sent to the system nameservers after a failure of all the conf.nameserver servers
+func (r *Resolver) Fallbacks() uint64 {
+	return r.fallbacks.Load()
+}
+
+// Answers returns the number of queries answered by each conf.nameserver server
+func (r *Resolver) Answers() map[string]uint64 {
+	a := make(map[string]uint64, len(r.answers))
+	for name, n := range r.answers {
+		a[name] = n.Load()
+	}
+	return a
+}
+
+// exchanger sends the queries to a nameserver
+type exchanger struct {
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+	client    *http.Client
+}
+
+// exchange sends a query to a nameserver within the timeout, the truncated udp answers are retried on tcp
+func (x *exchanger) exchange(ctx context.Context, n nameserver, timeout time.Duration, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch n.scheme {
+	case "https":
+		return x.post(ctx, n.address, query)
+	case "tls":
+		config := x.tlsConfig
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(n.address)
+		}
+		td := &tls.Dialer{NetDialer: x.dialer, Config: config}
+		conn, err := td.DialContext(ctx, "tcp", n.address)
+		if err != nil {
+			return nil, err
+		}
+		return streamExchange(ctx, conn, query)
+	}
+
+	conn, err := x.dialer.DialContext(ctx, "udp", n.address)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := packetExchange(ctx, conn, query)
+	if err != nil || len(answer) < 3 || answer[2]&0x02 == 0 {
+		return answer, err
+	}
+
+	conn, err = x.dialer.DialContext(ctx, "tcp", n.address)
+	if err != nil {
+		return nil, err
+	}
+	return streamExchange(ctx, conn, query)
+}
+
+// post sends a DNS-over-HTTPS query
+func (x *exchanger) post(ctx context.Context, u string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+// packetExchange sends a query as a single datagram
+func packetExchange(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 65535)
+	n, err := conn.Read(answer)
+	if err != nil {
+		return nil, err
+	}
+	return answer[:n], nil
+}
+
+// streamExchange sends a length prefixed query
+func streamExchange(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// streamConn passes the queries written by the resolver to exchange and returns the answers
+// It is not a net.PacketConn so the resolver frames the messages with their length like on TCP
+type streamConn struct {
+	ctx      context.Context
+	exchange func(ctx context.Context, query []byte) ([]byte, error)
+	deadline time.Time
+	wbuf     bytes.Buffer
+	rbuf     bytes.Buffer
+}
+
+// Write buffers the queries and exchanges each complete one
+func (c *streamConn) Write(b []byte) (int, error) {
+	c.wbuf.Write(b)
+	for c.wbuf.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.wbuf.Bytes()))
+		if c.wbuf.Len() < 2+n {
+			break
+		}
+		query := append([]byte{}, c.wbuf.Bytes()[2:2+n]...)
+		c.wbuf.Next(2 + n)
+
+		answer, err := c.send(query)
+		if err != nil {
+			return 0, err
+		}
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(answer)))
+		c.rbuf.Write(l[:])
+		c.rbuf.Write(answer)
+	}
+	return len(b), nil
+}
+
+// send exchanges a query before the deadline set by the resolver
+func (c *streamConn) send(query []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	return c.exchange(ctx, query)
+}
+
+// Read returns the length prefixed answers
+func (c *streamConn) Read(b []byte) (int, error) {
+	if c.rbuf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.rbuf.Read(b)
+}
+
+func (c *streamConn) Close() error                       { return nil }
+func (c *streamConn) LocalAddr() net.Addr                { return streamAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr               { return streamAddr{} }
+func (c *streamConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *streamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(t time.Time) error { c.deadline = t; return nil }
+
+// streamAddr address of the conf.nameserver servers
+type streamAddr struct{}
+
+func (a streamAddr) Network() string { return "dns" }
+func (a streamAddr) String() string  { return "conf.nameserver" }
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+)
+
+// shardIndexRe trailing number of the probe names of the instances (network-exporter-2)
+var shardIndexRe = regexp.MustCompile(`(\d+)$`)
+
+// Sharding splits the targets between identical exporter instances, a target runs on the instance with the index of the hash of its name and type modulo total
+type Sharding struct {
+	// Total number of instances (0 disables the sharding)
+	Total int `yaml:"total" json:"total" default:"0"`
+	// Index of the instance from 0 to total-1, the trailing number of the probe name when not set
+	Index *int `yaml:"index" json:"index"`
+	// index resolved by resolve
+	index int
+}
+
+// resolve validates the sharding and sets the index of the instance
+func (s *Sharding) resolve(hostname string) error {
+	if s.Total < 0 {
+		return fmt.Errorf("conf.sharding.total must be >=0")
+	}
+	if s.Total == 0 {
+		return nil
+	}
+
+	if s.Index != nil {
+		s.index = *s.Index
+	} else {
+		m := shardIndexRe.FindString(hostname)
+		if m == "" {
+			return fmt.Errorf("conf.sharding.index is required, the probe name %s does not end with a number", hostname)
+		}
+		index, err := strconv.Atoi(m)
+		if err != nil {
+			return fmt.Errorf("conf.sharding.index: %s", err)
+		}
+		s.index = index
+	}
+	if s.index < 0 || s.index >= s.Total {
+		return fmt.Errorf("conf.sharding.index %d must be between 0 and %d", s.index, s.Total-1)
+	}
+	return nil
+}
+
+// enabled reports if the targets are split between the instances
+func (s Sharding) enabled() bool {
+	return s.Total > 0
+}
+
+// shard returns the index of the instance running a target
+func (s Sharding) shard(t Target) int {
+	h := fnv.New64a()
+	h.Write([]byte(t.Name + "\x00" + string(t.Type)))
+	return int(h.Sum64() % uint64(s.Total))
+}
+
+// assigned reports if a target runs on this instance, the targets with a probe list are pinned and not sharded
+func (s Sharding) assigned(t Target) bool {
+	return !s.enabled() || len(t.Probe) > 0 || s.shard(t) == s.index
+}
+
+// sharding returns the sharding of the active config
+func (sc *SafeConfig) sharding() Sharding {
+	sc.RLock()
+	defer sc.RUnlock()
+	if sc.Cfg == nil {
+		return Sharding{}
+	}
+	return sc.Cfg.Sharding
+}
+
+// ShardTargets returns the index of this instance and the number of active targets assigned to it by the sharding, ok is false without sharding
+func (sc *SafeConfig) ShardTargets() (index int, targets int, ok bool) {
+	sc.RLock()
+	defer sc.RUnlock()
+	if sc.Cfg == nil || !sc.Cfg.Sharding.enabled() {
+		return 0, 0, false
+	}
+	for _, t := range sc.Cfg.Targets {
+		if len(t.Probe) == 0 {
+			targets++
+		}
+	}
+	return sc.Cfg.Sharding.index, targets, true
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardingAssigned(t *testing.T) {
+	s := Sharding{Total: 4}
+	for _, probe := range [][]string{nil, {}} {
+		for i := 0; i < 20; i++ {
+			target := Target{Name: fmt.Sprintf("target-%d", i), Type: TypeICMP, Probe: probe}
+			shards := 0
+			for index := 0; index < s.Total; index++ {
+				s.index = index
+				if s.assigned(target) {
+					shards++
+				}
+			}
+			if shards != 1 {
+				t.Errorf("target %s with probe %#v assigned to %d shards, want 1", target.Name, probe, shards)
+			}
+		}
+	}
+
+	pinned := Target{Name: "pinned", Type: TypeICMP, Probe: []string{"probe-1"}}
+	for index := 0; index < s.Total; index++ {
+		s.index = index
+		if !s.assigned(pinned) {
+			t.Errorf("pinned target not assigned to shard %d", index)
+		}
+	}
+}
+
+func TestReloadConfigShardingEmptyProbe(t *testing.T) {
+	const total = 3
+	targets := ""
+	for i := 0; i < 12; i++ {
+		targets += fmt.Sprintf("  - name: unset-%d\n    host: 192.168.0.%d\n    type: ICMP\n", i, i+1)
+		targets += fmt.Sprintf("  - name: empty-%d\n    host: 192.168.1.%d\n    type: ICMP\n    probe: []\n", i, i+1)
+	}
+	targets += "  - name: pinned\n    host: 192.168.2.1\n    type: ICMP\n    probe: [probe-1]\n"
+
+	runs := map[string]int{}
+	for index := 0; index < total; index++ {
+		sc, err := reload(t, writeConfig(t, "network_exporter.yml", fmt.Sprintf("conf:\n  sharding:\n    total: %d\n    index: %d\ntargets:\n%s", total, index, targets)))
+		if err != nil {
+			t.Fatalf("ReloadConfig shard %d: %s", index, err)
+		}
+		for _, target := range sc.Cfg.Targets {
+			runs[target.Name]++
+		}
+		_, sharded, ok := sc.ShardTargets()
+		if !ok || sharded != len(sc.Cfg.Targets)-1 {
+			t.Errorf("shard %d: ShardTargets %d, %v, want %d sharded targets", index, sharded, ok, len(sc.Cfg.Targets)-1)
+		}
+	}
+
+	for i := 0; i < 12; i++ {
+		for _, name := range []string{fmt.Sprintf("unset-%d", i), fmt.Sprintf("empty-%d", i)} {
+			if runs[name] != 1 {
+				t.Errorf("target %s runs on %d shards, want 1", name, runs[name])
+			}
+		}
+	}
+	if runs["pinned"] != total {
+		t.Errorf("pinned target runs on %d shards, want %d", runs["pinned"], total)
+	}
+}
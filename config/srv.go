@@ -0,0 +1,180 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// setSRVSource keeps the unexpanded targets of a source defining SRV records so that RefreshSRV can expand them again
+func (sc *SafeConfig) setSRVSource(source string, in Targets) {
+	if sc.srvSources == nil {
+		sc.srvSources = make(map[string]Targets)
+	}
+	for _, t := range in {
+		if common.SrvRecordCheck(t.Host) {
+			sc.srvSources[source] = in
+			return
+		}
+	}
+	delete(sc.srvSources, source)
+}
+
+// RefreshSRV expands the SRV records of the config file and discovery sources again, the targets of a source are only replaced when the members of its records changed
+// It reports if the active target list changed, the sources reloaded during the resolution are left to their new targets
+func (sc *SafeConfig) RefreshSRV(logger *slog.Logger) (changed bool, err error) {
+	hostname, err := sc.probeName()
+	if err != nil {
+		return false, err
+	}
+
+	sharding := sc.sharding()
+	sc.RLock()
+	sources := make(map[string]Targets, len(sc.srvSources))
+	for source, in := range sc.srvSources {
+		sources[source] = in
+	}
+	sc.RUnlock()
+
+	type expansion struct {
+		targets Targets
+		records []SRVRecord
+	}
+	expanded := make(map[string]expansion, len(sources))
+	for source, in := range sources {
+		targets, records, _ := filterTargets(logger, hostname, sharding, in)
+		expanded[source] = expansion{targets: targets, records: records}
+	}
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	for source, e := range expanded {
+		if !reflect.DeepEqual(sc.srvSources[source], sources[source]) {
+			continue
+		}
+
+		same := srvMembersEqual(sc.srv[source], e.records)
+		sc.setSRVRecords(source, e.records)
+		if same {
+			continue
+		}
+		if source == "config" {
+			targets, _, err := dedupTargets(logger, e.targets, sc.Cfg.OnDuplicate)
+			if err != nil {
+				logger.Error("Refreshing SRV records skipped", "type", "Config", "func", "RefreshSRV", "origin", source, "err", err)
+				continue
+			}
+			sc.static = targets
+		} else if len(e.targets) == 0 {
+			delete(sc.discovered, source)
+		} else {
+			sc.discovered[source] = e.targets
+		}
+		logger.Info("SRV record members changed", "type", "Config", "func", "RefreshSRV", "origin", source, "targets", len(e.targets))
+		changed = true
+	}
+
+	if changed {
+		// Swap in a copy so readers of the previous config are not affected
+		c := *sc.Cfg
+		c.Targets = sc.mergeTargets(logger, c.MaxTargets, "")
+		sc.Cfg = &c
+	}
+	return changed, nil
+}
+
+// srvMembersEqual reports if two expansions of the records of a source have the same members, in any order as the weighted records are shuffled
+func srvMembersEqual(a []SRVRecord, b []SRVRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(members []string) []string {
+		s := append([]string{}, members...)
+		sort.Strings(s)
+		return s
+	}
+	for i := range a {
+		if a[i].Record != b[i].Record || a[i].LastError != b[i].LastError || !reflect.DeepEqual(sorted(a[i].Members), sorted(b[i].Members)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSrvDistribution validates the srv_distribution of a target, the weighted shards are computed over the probe names so the patterns are rejected
+func checkSrvDistribution(t Target) error {
+	switch t.SrvDistribution {
+	case "", "all":
+		return nil
+	case "weighted":
+		for _, p := range t.Probe {
+			if strings.HasPrefix(p, "re:") || strings.HasPrefix(p, "glob:") {
+				return fmt.Errorf("target %s: srv_distribution weighted requires the probe names, not the pattern %q", t.Name, p)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("target %s: srv_distribution must be 'all' or 'weighted'", t.Name)
+	}
+}
+
+// srvShard returns the members of an SRV record monitored by the probe with srv_distribution weighted
+// Each member is monitored by a share of the probes listed in probe proportional to its weight among the members of the same priority (at least one), picked by rendezvous hashing of the member and probe names
+// Without probe list every probe keeps the members whose hash with its name falls within their weight share, the assignment only changes with the members or their weights
+func srvShard(hosts []common.SrvHost, probes []string, hostname string) []common.SrvHost {
+	weights := map[uint16]float64{}
+	for _, h := range hosts {
+		weights[h.Priority] += srvWeight(h)
+	}
+
+	shard := []common.SrvHost{}
+	for _, h := range hosts {
+		share := srvWeight(h) / weights[h.Priority]
+		if len(probes) == 0 {
+			if float64(srvScore(hostname, h.Host))/math.MaxUint64 < share {
+				shard = append(shard, h)
+			}
+			continue
+		}
+
+		ranked := slices.Clone(probes)
+		slices.SortFunc(ranked, func(a, b string) int {
+			return cmp.Compare(srvScore(b, h.Host), srvScore(a, h.Host))
+		})
+		n := min(max(int(math.Round(share*float64(len(ranked)))), 1), len(ranked))
+		if slices.Contains(ranked[:n], hostname) {
+			shard = append(shard, h)
+		}
+	}
+	return shard
+}
+
+// srvWeight returns the weight of an SRV member, the members of weight 0 count as 1 so they are still assigned
+func srvWeight(h common.SrvHost) float64 {
+	return float64(max(h.Weight, 1))
+}
+
+// srvScore rendezvous hash of a probe and an SRV member
+func srvScore(probe string, host string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(probe + "\x00" + host))
+	return h.Sum64()
+}
+
+// srvHostNames returns the hosts of the SRV members
+func srvHostNames(hosts []common.SrvHost) []string {
+	names := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		names = append(names, h.Host)
+	}
+	return names
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// checkFields reports the keys of a config file not matching any setting, they fail the reload with Strict and are logged otherwise
+func (sc *SafeConfig) checkFields(logger *slog.Logger, file string, data []byte, v interface{}) error {
+	unknown, err := unknownFields(file, data, v)
+	if err != nil || len(unknown) == 0 {
+		// The syntax errors are reported by the decoding
+		return nil
+	}
+	if sc.Strict {
+		return fmt.Errorf("unknown fields: %s", formatUnknownFields(unknown))
+	}
+	logger.Warn("Unknown config fields ignored", "type", "Config", "func", "ReloadConfig", "file", file, "fields", formatUnknownFields(unknown))
+	return nil
+}
+
+// unknownField key of a config file not matching any setting
+type unknownField struct {
+	path string
+	line int
+}
+
+func (f unknownField) String() string {
+	return fmt.Sprintf("%s (line %d)", f.path, f.line)
+}
+
+// unknownFields returns the keys of a YAML or JSON config file that are not decoded into v, with their path (targets[3].lables) and line
+// The values decoded by a custom unmarshaler are not looked into, except the target entries of the target groups
+func unknownFields(file string, data []byte, v interface{}) ([]unknownField, error) {
+	// JSON is a subset of YAML, the YAML parser gives the lines of both
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	w := fieldWalker{json: isJSON(file, data)}
+	w.walk(&doc, reflect.TypeOf(v), "")
+	return w.unknown, nil
+}
+
+// fieldWalker looks up the keys of the YAML nodes in the fields of the config structs
+type fieldWalker struct {
+	// json matches the keys against the json tags, case-insensitively like encoding/json
+	json    bool
+	unknown []unknownField
+}
+
+var (
+	groupHostType       = reflect.TypeOf(GroupHost{})
+	nodeUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+func (w *fieldWalker) walk(n *yaml.Node, t reflect.Type, path string) {
+	for n.Kind == yaml.DocumentNode || n.Kind == yaml.AliasNode {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		} else if len(n.Content) > 0 {
+			n = n.Content[0]
+		} else {
+			return
+		}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == groupHostType {
+		t = reflect.TypeOf(Target{})
+	} else if customUnmarshaler(t) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := w.fields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			field, ok := w.lookup(fields, key.Value)
+			if !ok {
+				w.unknown = append(w.unknown, unknownField{path: fieldPath(path, key.Value), line: key.Line})
+				continue
+			}
+			w.walk(value, field, fieldPath(path, key.Value))
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			w.walk(n.Content[i+1], t.Elem(), fieldPath(path, n.Content[i].Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			w.walk(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+// fields returns the types of the struct fields by their key, the inlined structs included
+func (w *fieldWalker) fields(t reflect.Type) map[string]reflect.Type {
+	tag := "yaml"
+	if w.json {
+		tag = "json"
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		inline := strings.Contains(opts, "inline") || (w.json && f.Anonymous && name == "")
+		if ft := f.Type; inline && ft.Kind() == reflect.Struct {
+			for k, v := range w.fields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+			if !w.json {
+				name = strings.ToLower(name)
+			}
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookup returns the field of a key, the JSON keys match case-insensitively
+func (w *fieldWalker) lookup(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok || !w.json {
+		return t, ok
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// customUnmarshaler reports whether the values of a type are decoded by its own unmarshaler
+func customUnmarshaler(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	if p.Implements(nodeUnmarshalerType) {
+		return true
+	}
+	m, ok := p.MethodByName("UnmarshalYAML")
+	return ok && m.Type.NumIn() == 2 && m.Type.In(1).Kind() == reflect.Func
+}
+
+func fieldPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// formatUnknownFields lists the unknown fields of a config file
+func formatUnknownFields(unknown []unknownField) string {
+	s := make([]string, 0, len(unknown))
+	for _, f := range unknown {
+		s = append(s, f.String())
+	}
+	return strings.Join(s, ", ")
+}
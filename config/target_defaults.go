@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+)
+
+// TargetDefault values merged into the targets that do not set them
+type TargetDefault struct {
+	Probe    []string `yaml:"probe,omitempty" json:"probe"`
+	SourceIp string   `yaml:"source_ip,omitempty" json:"source_ip"`
+	Labels   extraKV  `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// TargetDefaults values of all the targets, the ones of Types apply to the targets of a check type and win over the global ones
+type TargetDefaults struct {
+	TargetDefault `yaml:",inline"`
+	Types         map[string]TargetDefault `yaml:"types,omitempty" json:"types,omitempty"`
+}
+
+// apply merges the defaults into the targets, the target values always win and the labels are merged key by key
+func (d TargetDefaults) apply(targets Targets) (Targets, error) {
+	types := make(map[TargetType]TargetDefault, len(d.Types))
+	for name, def := range d.Types {
+		checkType, err := ParseTargetType(name)
+		if err != nil {
+			return nil, fmt.Errorf("target_defaults.types: %s", err)
+		}
+		types[checkType] = def
+	}
+
+	merged := make(Targets, 0, len(targets))
+	for _, t := range targets {
+		typed := types[t.Type]
+		for _, def := range []TargetDefault{typed, d.TargetDefault} {
+			if len(t.Probe) == 0 {
+				t.Probe = def.Probe
+			}
+			if t.SourceIp == "" && t.SourceInterface == "" {
+				t.SourceIp = def.SourceIp
+			}
+		}
+
+		if len(d.Labels.Kv) > 0 || len(typed.Labels.Kv) > 0 {
+			labels := map[string]string{}
+			for _, kv := range []map[string]string{d.Labels.Kv, typed.Labels.Kv, t.Labels.Kv} {
+				for k, v := range kv {
+					labels[k] = v
+				}
+			}
+			t.Labels = extraKV{Kv: labels}
+		}
+		merged = append(merged, t)
+	}
+	return merged, nil
+}
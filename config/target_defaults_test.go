@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargetDefaultsApplyLabels(t *testing.T) {
+	d := TargetDefaults{
+		TargetDefault: TargetDefault{Labels: extraKV{Kv: map[string]string{"env": "prod", "team": "netops", "dc": "default"}}},
+		Types: map[string]TargetDefault{
+			"icmp": {Labels: extraKV{Kv: map[string]string{"team": "icmp", "tier": "edge"}}},
+		},
+	}
+	targets := Targets{
+		{Name: "no-labels", Host: "192.168.0.1", Type: TypeTCP},
+		{Name: "overlapping", Host: "192.168.0.2", Type: TypeTCP, Labels: extraKV{Kv: map[string]string{"env": "staging", "rack": "a1"}}},
+		{Name: "typed", Host: "192.168.0.3", Type: TypeICMP},
+		{Name: "typed-overlapping", Host: "192.168.0.4", Type: TypeICMP, Labels: extraKV{Kv: map[string]string{"team": "sre", "tier": "core"}}},
+	}
+
+	merged, err := d.apply(targets)
+	if err != nil {
+		t.Fatalf("apply: %s", err)
+	}
+
+	want := map[string]map[string]string{
+		"no-labels":         {"env": "prod", "team": "netops", "dc": "default"},
+		"overlapping":       {"env": "staging", "team": "netops", "dc": "default", "rack": "a1"},
+		"typed":             {"env": "prod", "team": "icmp", "dc": "default", "tier": "edge"},
+		"typed-overlapping": {"env": "prod", "team": "sre", "dc": "default", "tier": "core"},
+	}
+	for _, m := range merged {
+		if !reflect.DeepEqual(m.Labels.Kv, want[m.Name]) {
+			t.Errorf("target %s: labels %v, want %v", m.Name, m.Labels.Kv, want[m.Name])
+		}
+	}
+
+	// The labels of the targets and of the defaults are not modified in place
+	if want := map[string]string{"env": "staging", "rack": "a1"}; !reflect.DeepEqual(targets[1].Labels.Kv, want) {
+		t.Errorf("target labels modified: %v, want %v", targets[1].Labels.Kv, want)
+	}
+	if want := map[string]string{"env": "prod", "team": "netops", "dc": "default"}; !reflect.DeepEqual(d.Labels.Kv, want) {
+		t.Errorf("default labels modified: %v, want %v", d.Labels.Kv, want)
+	}
+}
+
+func TestTargetDefaultsApplyTargetWins(t *testing.T) {
+	d := TargetDefaults{TargetDefault: TargetDefault{Probe: []string{"probe-1"}, SourceIp: "10.0.0.1"}}
+	targets := Targets{
+		{Name: "defaults", Host: "192.168.0.1", Type: TypeICMP},
+		{Name: "explicit", Host: "192.168.0.2", Type: TypeICMP, Probe: []string{"probe-2"}, SourceIp: "10.0.0.2"},
+		{Name: "interface", Host: "192.168.0.3", Type: TypeICMP, SourceInterface: "eth0"},
+	}
+
+	merged, err := d.apply(targets)
+	if err != nil {
+		t.Fatalf("apply: %s", err)
+	}
+	tests := []struct {
+		probe    []string
+		sourceIp string
+	}{
+		{probe: []string{"probe-1"}, sourceIp: "10.0.0.1"},
+		{probe: []string{"probe-2"}, sourceIp: "10.0.0.2"},
+		// source_interface excludes the default source_ip
+		{probe: []string{"probe-1"}, sourceIp: ""},
+	}
+	for i, tt := range tests {
+		if !reflect.DeepEqual(merged[i].Probe, tt.probe) || merged[i].SourceIp != tt.sourceIp {
+			t.Errorf("target %s: probe %v source_ip %q, want %v %q", merged[i].Name, merged[i].Probe, merged[i].SourceIp, tt.probe, tt.sourceIp)
+		}
+	}
+}
+
+func TestTargetDefaultsUnknownType(t *testing.T) {
+	d := TargetDefaults{Types: map[string]TargetDefault{"ICMPX": {SourceIp: "10.0.0.1"}}}
+	if _, err := d.apply(Targets{{Name: "t", Host: "192.168.0.1", Type: TypeICMP}}); err == nil {
+		t.Errorf("apply: unknown check type ICMPX accepted")
+	}
+}
+
+func TestReloadConfigTargetDefaultsLabels(t *testing.T) {
+	file := writeConfig(t, "network_exporter.yml", `
+target_defaults:
+  labels:
+    env: prod
+    team: netops
+targets:
+  - name: gateway
+    host: 192.168.0.1
+    type: ICMP
+    labels:
+      team: sre
+`)
+	sc, err := reload(t, file)
+	if err != nil {
+		t.Fatalf("ReloadConfig: %s", err)
+	}
+	if want := map[string]string{"env": "prod", "team": "sre"}; !reflect.DeepEqual(sc.Cfg.Targets[0].Labels.Kv, want) {
+		t.Errorf("labels %v, want %v", sc.Cfg.Targets[0].Labels.Kv, want)
+	}
+}
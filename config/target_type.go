@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TargetType check type of a target
+type TargetType string
+
+const (
+	TypeICMP    TargetType = "ICMP"
+	TypeMTR     TargetType = "MTR"
+	TypeICMPMTR TargetType = "ICMP+MTR"
+	TypeTCP     TargetType = "TCP"
+	TypeHTTPGet TargetType = "HTTPGet"
+	TypeDNS     TargetType = "DNS"
+	TypeUDP     TargetType = "UDP"
+	TypeNTP     TargetType = "NTP"
+	TypeSMTP    TargetType = "SMTP"
+)
+
+// targetTypes supported check types
+var targetTypes = []TargetType{TypeICMP, TypeMTR, TypeICMPMTR, TypeTCP, TypeHTTPGet, TypeDNS, TypeUDP, TypeNTP, TypeSMTP}
+
+// ParseTargetType returns the check type matching s case-insensitively, anything else than exactly one of the supported types is rejected
+func ParseTargetType(s string) (TargetType, error) {
+	for _, t := range targetTypes {
+		if strings.EqualFold(s, string(t)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown check type %s, allowed (ICMP|MTR|ICMP+MTR|TCP|HTTPGet|DNS|UDP|NTP|SMTP)", s)
+}
+
+// UnmarshalYAML reads a check type, the supported ones are normalized and the unknown ones kept as is so the target is reported and skipped
+func (t *TargetType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	*t = normalizeTargetType(s)
+	return nil
+}
+
+// UnmarshalJSON reads a check type of a JSON config file
+func (t *TargetType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = normalizeTargetType(s)
+	return nil
+}
+
+// normalizeTargetType returns the supported check type matching s or s unchanged
+func normalizeTargetType(s string) TargetType {
+	if t, err := ParseTargetType(s); err == nil {
+		return t
+	}
+	return TargetType(s)
+}
+
+// ICMP reports whether the check type runs the ICMP monitor
+func (t TargetType) ICMP() bool {
+	return t == TypeICMP || t == TypeICMPMTR
+}
+
+// MTR reports whether the check type runs the MTR monitor
+func (t TargetType) MTR() bool {
+	return t == TypeMTR || t == TypeICMPMTR
+}
+
+// Monitors returns the monitor types run by the check type, ICMP+MTR runs both ICMP and MTR
+func (t TargetType) Monitors() []TargetType {
+	if t == TypeICMPMTR {
+		return []TargetType{TypeICMP, TypeMTR}
+	}
+	return []TargetType{t}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseTargetType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want TargetType
+		ok   bool
+	}{
+		{in: "ICMP", want: TypeICMP, ok: true},
+		{in: "icmp", want: TypeICMP, ok: true},
+		{in: "MTR", want: TypeMTR, ok: true},
+		{in: "ICMP+MTR", want: TypeICMPMTR, ok: true},
+		{in: "icmp+mtr", want: TypeICMPMTR, ok: true},
+		{in: "TCP", want: TypeTCP, ok: true},
+		{in: "HTTPGet", want: TypeHTTPGet, ok: true},
+		{in: "httpget", want: TypeHTTPGet, ok: true},
+		{in: "DNS", want: TypeDNS, ok: true},
+		{in: "UDP", want: TypeUDP, ok: true},
+		{in: "NTP", want: TypeNTP, ok: true},
+		{in: "SMTP", want: TypeSMTP, ok: true},
+		{in: "ICMPX"},
+		{in: "FOOMTR"},
+		{in: "MTRBAR"},
+		{in: "ICMPMTR"},
+		{in: "ICMP MTR"},
+		{in: "ICMP+"},
+		{in: "+MTR"},
+		{in: "MTR+ICMP"},
+		{in: " ICMP"},
+		{in: "ICMP\n"},
+		{in: "HTTP"},
+		{in: ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseTargetType(tt.in)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("ParseTargetType(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("ParseTargetType(%q) = %q, want an error", tt.in, got)
+		}
+	}
+}
+
+func TestTargetTypeMonitors(t *testing.T) {
+	tests := []struct {
+		in       TargetType
+		icmp     bool
+		mtr      bool
+		monitors []TargetType
+	}{
+		{in: TypeICMP, icmp: true, monitors: []TargetType{TypeICMP}},
+		{in: TypeMTR, mtr: true, monitors: []TargetType{TypeMTR}},
+		{in: TypeICMPMTR, icmp: true, mtr: true, monitors: []TargetType{TypeICMP, TypeMTR}},
+		{in: TypeTCP, monitors: []TargetType{TypeTCP}},
+		{in: TargetType("ICMPX"), monitors: []TargetType{TargetType("ICMPX")}},
+		{in: TargetType("FOOMTR"), monitors: []TargetType{TargetType("FOOMTR")}},
+	}
+	for _, tt := range tests {
+		if tt.in.ICMP() != tt.icmp || tt.in.MTR() != tt.mtr {
+			t.Errorf("%q: ICMP() %v MTR() %v, want %v %v", tt.in, tt.in.ICMP(), tt.in.MTR(), tt.icmp, tt.mtr)
+		}
+		if got := tt.in.Monitors(); !reflect.DeepEqual(got, tt.monitors) {
+			t.Errorf("%q: Monitors() %v, want %v", tt.in, got, tt.monitors)
+		}
+	}
+}
+
+func TestTargetTypeUnmarshal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want TargetType
+	}{
+		{in: "icmp+mtr", want: TypeICMPMTR},
+		{in: "httpget", want: TypeHTTPGet},
+		// The unknown types are kept as is so the target is reported and skipped
+		{in: "ICMPX", want: TargetType("ICMPX")},
+		{in: "FOOMTR", want: TargetType("FOOMTR")},
+		{in: "MTRBAR", want: TargetType("MTRBAR")},
+	}
+	for _, tt := range tests {
+		var fromYAML, fromJSON TargetType
+		if err := yaml.Unmarshal([]byte(tt.in), &fromYAML); err != nil {
+			t.Fatalf("yaml %q: %s", tt.in, err)
+		}
+		data, _ := json.Marshal(tt.in)
+		if err := json.Unmarshal(data, &fromJSON); err != nil {
+			t.Fatalf("json %q: %s", tt.in, err)
+		}
+		if fromYAML != tt.want || fromJSON != tt.want {
+			t.Errorf("%q: yaml %q json %q, want %q", tt.in, fromYAML, fromJSON, tt.want)
+		}
+	}
+}
+
+func TestReloadConfigMalformedTargetTypes(t *testing.T) {
+	sc, err := reload(t, writeConfig(t, "network_exporter.yml", `
+targets:
+  - name: valid
+    host: 192.168.0.1
+    type: icmp+mtr
+  - name: icmpx
+    host: 192.168.0.2
+    type: ICMPX
+  - name: foomtr
+    host: 192.168.0.3
+    type: FOOMTR
+  - name: mtrbar
+    host: 192.168.0.4
+    type: MTRBAR
+`))
+	if err != nil {
+		t.Fatalf("ReloadConfig: %s", err)
+	}
+	if len(sc.Cfg.Targets) != 1 || sc.Cfg.Targets[0].Type != TypeICMPMTR {
+		t.Errorf("targets %v, want only the ICMP+MTR one", sc.Cfg.Targets)
+	}
+	skipped := map[string]bool{}
+	for _, s := range sc.Skipped() {
+		skipped[s.Name] = true
+	}
+	for _, name := range []string{"icmpx", "foomtr", "mtrbar"} {
+		if !skipped[name] {
+			t.Errorf("target %s with a malformed type not skipped: %v", name, sc.Skipped())
+		}
+	}
+}
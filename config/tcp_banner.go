@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// compileTCPBanner decodes the send escapes and compiles the expect regex of a TCP target
+func compileTCPBanner(t Target) (Target, error) {
+	var err error
+	if t.send, err = unescapeBytes(t.Send); err != nil {
+		return t, fmt.Errorf("send %q: %s", t.Send, err)
+	}
+	if t.Expect == "" {
+		t.expect = nil
+		return t, nil
+	}
+	if t.expect, err = regexp.Compile(t.Expect); err != nil {
+		return t, fmt.Errorf("expect %q: %s", t.Expect, err)
+	}
+	return t, nil
+}
+
+// unescapeBytes decodes the Go escapes of a string (\r, \n, \t, \\, \x00 hex bytes, é characters), the other characters are kept as is
+func unescapeBytes(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		v, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape at %q", s)
+		}
+		if v < utf8.RuneSelf || !multibyte {
+			out = append(out, byte(v))
+		} else {
+			out = utf8.AppendRune(out, v)
+		}
+		s = tail
+	}
+	return out, nil
+}
+
+// TCPSend returns the decoded send bytes of the target
+func (t Target) TCPSend() []byte {
+	return t.send
+}
+
+// TCPExpect returns the compiled expect regex of the target, nil without banner check
+func (t Target) TCPExpect() *regexp.Regexp {
+	return t.expect
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// ClientConfig loads the CA and client certificates of a TLS client
+func (c TLSConfig) ClientConfig() (*tls.Config, error) {
+	config, _, err := c.load()
+	return config, err
+}
+
+// load returns the TLS client config and a fingerprint of its settings and files, which changes when a certificate is rotated
+func (c TLSConfig) load() (*tls.Config, string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.ServerName + "\x00" + strconv.FormatBool(c.InsecureSkipVerify)))
+
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, "", fmt.Errorf("no certificates found in CA file: %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+		h.Write(ca)
+	}
+	if c.CertFile != "" {
+		certPEM, err := os.ReadFile(c.CertFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading client certificate: %s", err)
+		}
+		keyPEM, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading client certificate: %s", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		h.Write(certPEM)
+		h.Write(keyPEM)
+	}
+	return tlsConfig, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readTLS loads the tls_config of a target, the certificates are read on every config reload so the rotated ones are picked up
+// The TCP targets with tls get a config without tls_config, the TLS config of a TCP target is nil without tls
+func readTLS(t Target) (Target, error) {
+	t.tlsConfig, t.tlsFingerprint = nil, ""
+	if t.TLS == (TLSConfig{}) && !(t.TCPTLS && t.Type == TypeTCP) && !(t.SMTPStartTLS && t.Type == TypeSMTP) {
+		return t, nil
+	}
+	if t.Type != TypeHTTPGet && t.Type != TypeTCP && t.Type != TypeSMTP {
+		return t, fmt.Errorf("tls_config is only supported by the HTTPGet, TCP and SMTP targets")
+	}
+	if t.Type == TypeTCP && !t.TCPTLS {
+		return t, fmt.Errorf("tls_config requires tls on the TCP targets")
+	}
+	if t.Type == TypeSMTP && !t.SMTPStartTLS {
+		return t, fmt.Errorf("tls_config requires smtp_starttls on the SMTP targets")
+	}
+	if (t.TLS.CertFile == "") != (t.TLS.KeyFile == "") {
+		return t, fmt.Errorf("tls_config: cert_file and key_file must be set together")
+	}
+	config, fingerprint, err := t.TLS.load()
+	if err != nil {
+		return t, fmt.Errorf("tls_config: %s", err)
+	}
+	t.tlsConfig, t.tlsFingerprint = config, fingerprint
+	return t, nil
+}
+
+// readProxyTLS loads the proxy_tls_config of an HTTPGet target with an https proxy, the certificates are read on every config reload like the ones of tls_config
+func readProxyTLS(t Target) (Target, error) {
+	t.proxyTLSConfig, t.proxyTLSFingerprint = nil, ""
+	if t.ProxyTLS == (TLSConfig{}) {
+		return t, nil
+	}
+	if t.Type != TypeHTTPGet {
+		return t, fmt.Errorf("proxy_tls_config is only supported by the HTTPGet targets")
+	}
+	if u, err := url.Parse(t.Proxy); err != nil || u.Scheme != "https" {
+		return t, fmt.Errorf("proxy_tls_config requires an https proxy")
+	}
+	if (t.ProxyTLS.CertFile == "") != (t.ProxyTLS.KeyFile == "") {
+		return t, fmt.Errorf("proxy_tls_config: cert_file and key_file must be set together")
+	}
+	config, fingerprint, err := t.ProxyTLS.load()
+	if err != nil {
+		return t, fmt.Errorf("proxy_tls_config: %s", err)
+	}
+	t.proxyTLSConfig, t.proxyTLSFingerprint = config, fingerprint
+	return t, nil
+}
+
+// ProxyTLSClientConfig returns the TLS config built from the proxy_tls_config of the target and its fingerprint, nil without proxy_tls_config
+func (t Target) ProxyTLSClientConfig() (*tls.Config, string) {
+	return t.proxyTLSConfig, t.proxyTLSFingerprint
+}
+
+// TLSClientConfig returns the TLS config built from the tls_config of the target and its fingerprint, nil without tls_config
+func (t Target) TLSClientConfig() (*tls.Config, string) {
+	return t.tlsConfig, t.tlsFingerprint
+}
@@ -0,0 +1,257 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/syepes/network_exporter/config"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Placeholder hosts of the generated targets, the reserved .example domain never resolves
+const (
+	placeholderHost = "target.example"
+	placeholderPort = "80"
+)
+
+// blackboxConfig blackbox_exporter configuration file
+type blackboxConfig struct {
+	Modules map[string]blackboxModule `yaml:"modules"`
+}
+
+// blackboxModule probe module, the prober settings are kept as maps to report the options without equivalent
+type blackboxModule struct {
+	Prober  string                 `yaml:"prober"`
+	Timeout string                 `yaml:"timeout"`
+	ICMP    map[string]interface{} `yaml:"icmp"`
+	TCP     map[string]interface{} `yaml:"tcp"`
+	HTTP    map[string]interface{} `yaml:"http"`
+}
+
+// Output network_exporter configuration generated from the blackbox modules
+type Output struct {
+	ICMP    *config.ICMP    `yaml:"icmp,omitempty"`
+	TCP     *config.TCP     `yaml:"tcp,omitempty"`
+	HTTPGet *config.HTTPGet `yaml:"http_get,omitempty"`
+	Targets config.Targets  `yaml:"targets"`
+}
+
+// Blackbox converts the icmp, tcp and http modules of a blackbox_exporter configuration
+// Every module becomes a target template named after the module, the warnings report the module options without equivalent
+func Blackbox(data []byte) (*Output, []string, error) {
+	bb := blackboxConfig{}
+	if err := yaml.Unmarshal(data, &bb); err != nil {
+		return nil, nil, fmt.Errorf("parsing blackbox config: %s", err)
+	}
+	if len(bb.Modules) == 0 {
+		return nil, nil, fmt.Errorf("no modules defined")
+	}
+
+	names := make([]string, 0, len(bb.Modules))
+	for name := range bb.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &Output{Targets: config.Targets{}}
+	warnings := []string{}
+	warn := func(module string, format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("module %s: ", module)+fmt.Sprintf(format, args...))
+	}
+	// Longest timeout of each prober, the exporter has a single timeout per check type
+	timeouts := map[string]time.Duration{}
+	payloadSize := 0
+
+	for _, name := range names {
+		m := bb.Modules[name]
+
+		var timeout time.Duration
+		if m.Timeout != "" {
+			d, err := time.ParseDuration(m.Timeout)
+			if err != nil {
+				return nil, nil, fmt.Errorf("module %s: timeout: %s", name, err)
+			}
+			timeout = d
+		}
+
+		t := config.Target{Name: name}
+		t.Labels.Kv = map[string]string{"module": name}
+
+		var opts map[string]interface{}
+		switch m.Prober {
+		case "icmp":
+			t.Type, t.Host, opts = "ICMP", placeholderHost, m.ICMP
+			if v, ok := opts["payload_size"]; ok {
+				size, _ := v.(int)
+				if payloadSize != 0 && payloadSize != size {
+					warn(name, "icmp.payload_size %d differs from the other modules, using %d", size, payloadSize)
+				} else {
+					payloadSize = size
+				}
+			}
+		case "tcp":
+			t.Type, t.Host, opts = "TCP", placeholderHost+":"+placeholderPort, m.TCP
+		case "http":
+			t.Type, t.Host, opts = "HTTPGet", "http://"+placeholderHost+"/", m.HTTP
+			if method, ok := opts["method"].(string); ok && !strings.EqualFold(method, "GET") {
+				warn(name, "http.method %s is not supported, only GET requests are sent", method)
+			}
+			if v, ok := opts["proxy_url"].(string); ok {
+				t.Proxy = v
+			}
+			if v, ok := opts["follow_redirects"].(bool); ok && !v {
+				warn(name, "http.follow_redirects false is not supported, the redirects are always followed")
+			}
+		default:
+			warn(name, "prober %s has no equivalent, module skipped", m.Prober)
+			continue
+		}
+
+		if v, ok := opts["source_ip_address"].(string); ok {
+			t.SourceIp = v
+		}
+		if v, ok := opts["preferred_ip_protocol"].(string); ok && v == "ip6" {
+			warn(name, "%s.preferred_ip_protocol ip6 is not supported per target, every resolved IP is probed when --ipv6 is enabled", m.Prober)
+		}
+		for _, key := range unsupportedOptions(m.Prober, opts) {
+			warn(name, "%s.%s has no equivalent", m.Prober, key)
+		}
+
+		if timeout > timeouts[m.Prober] {
+			if timeouts[m.Prober] != 0 {
+				warn(name, "timeout %s differs from the other %s modules, using the longest", timeout, m.Prober)
+			}
+			timeouts[m.Prober] = timeout
+		}
+		out.Targets = append(out.Targets, t)
+	}
+
+	for _, t := range out.Targets {
+		switch t.Type {
+		case "ICMP":
+			if out.ICMP == nil {
+				out.ICMP = &config.ICMP{}
+				if err := defaults.Set(out.ICMP); err != nil {
+					return nil, nil, err
+				}
+				setTimings(&out.ICMP.Interval, &out.ICMP.Timeout, timeouts["icmp"])
+				if payloadSize > 0 {
+					out.ICMP.PayloadSize = payloadSize
+				}
+			}
+		case "TCP":
+			if out.TCP == nil {
+				out.TCP = &config.TCP{}
+				if err := defaults.Set(out.TCP); err != nil {
+					return nil, nil, err
+				}
+				setTimings(&out.TCP.Interval, &out.TCP.Timeout, timeouts["tcp"])
+			}
+		case "HTTPGet":
+			if out.HTTPGet == nil {
+				out.HTTPGet = &config.HTTPGet{}
+				if err := defaults.Set(out.HTTPGet); err != nil {
+					return nil, nil, err
+				}
+				setTimings(&out.HTTPGet.Interval, &out.HTTPGet.Timeout, timeouts["http"])
+			}
+		}
+	}
+	return out, warnings, nil
+}
+
+// Marshal writes the converted configuration, the warnings are added as comments
+func (o *Output) Marshal(source string, warnings []string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Converted from the blackbox_exporter modules of %s\n", source)
+	fmt.Fprintf(buf, "# Replace the %s hosts of the module targets with the probed hosts\n", placeholderHost)
+	for _, w := range warnings {
+		fmt.Fprintf(buf, "# WARNING: %s\n", w)
+	}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(o); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Validate loads the marshaled configuration like the exporter does and checks that every target is kept
+func (o *Output) Validate(data []byte) error {
+	f, err := os.CreateTemp("", "network_exporter-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The target changes of the temporary config are not relevant
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sc := &config.SafeConfig{Cfg: &config.Config{}}
+	if err := sc.ReloadConfig(logger, f.Name(), nil); err != nil {
+		return fmt.Errorf("validating converted config: %s", err)
+	}
+	if n := len(sc.Cfg.Targets); n != len(o.Targets) {
+		return fmt.Errorf("validating converted config: %d targets loaded out of %d", n, len(o.Targets))
+	}
+	return nil
+}
+
+// durationValue duration setting of the config structs
+type durationValue interface {
+	Duration() time.Duration
+	Set(time.Duration)
+}
+
+// setTimings applies the module timeout and keeps the interval longer than the timeout
+func setTimings(interval durationValue, timeout durationValue, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timeout.Set(d)
+	if interval.Duration() <= d {
+		interval.Set(d + time.Second)
+	}
+}
+
+// unsupportedOptions returns the prober options without equivalent
+func unsupportedOptions(prober string, opts map[string]interface{}) []string {
+	supported := map[string]bool{"preferred_ip_protocol": true, "ip_protocol_fallback": true, "source_ip_address": true}
+	switch prober {
+	case "icmp":
+		supported["payload_size"] = true
+	case "http":
+		// Handled by the caller
+		supported["method"] = true
+		supported["proxy_url"] = true
+		supported["follow_redirects"] = true
+	}
+
+	keys := []string{}
+	for k := range opts {
+		if !supported[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
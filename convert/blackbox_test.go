@@ -0,0 +1,98 @@
+package convert
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the converted configs")
+
+func TestBlackboxGolden(t *testing.T) {
+	for _, name := range []string{"modules", "unsupported"} {
+		t.Run(name, func(t *testing.T) {
+			source := filepath.Join("testdata", name+".yml")
+			data, err := os.ReadFile(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, warnings, err := Blackbox(data)
+			if err != nil {
+				t.Fatalf("Blackbox: %s", err)
+			}
+			got, err := out.Marshal(name+".yml", warnings)
+			if err != nil {
+				t.Fatalf("Marshal: %s", err)
+			}
+			if err := out.Validate(got); err != nil {
+				t.Errorf("Validate: %s", err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden.yml")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("converted config differs from %s, run go test -update to accept:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestBlackboxWarnings(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "modules.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, warnings, err := Blackbox(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"module http_2xx: http.preferred_ip_protocol ip6 is not supported per target",
+		"module http_2xx: http.valid_status_codes has no equivalent",
+		"module http_post: http.method POST is not supported",
+		"module http_post: http.follow_redirects false is not supported",
+		"module icmp_ping: icmp.payload_size 56 differs from the other modules, using 1200",
+		"module icmp_ping: timeout 3s differs from the other icmp modules, using the longest",
+		"module icmp_ping: icmp.dont_fragment has no equivalent",
+		"module tcp_connect: tcp.query_response has no equivalent",
+		"module tcp_connect: tcp.tls has no equivalent",
+	} {
+		found := false
+		for _, w := range warnings {
+			found = found || strings.HasPrefix(w, want)
+		}
+		if !found {
+			t.Errorf("warning %q missing from %q", want, warnings)
+		}
+	}
+}
+
+func TestBlackboxInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{name: "no modules", data: "modules: {}\n", err: "no modules defined"},
+		{name: "not yaml", data: "modules: [\n", err: "parsing blackbox config"},
+		{name: "invalid timeout", data: "modules:\n  icmp:\n    prober: icmp\n    timeout: soon\n", err: "module icmp: timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Blackbox([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Blackbox: %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+// ConsulSD discovers targets from the healthy instances of the Consul services
+type ConsulSD struct {
+	health
+	logger *slog.Logger
+	cfg    config.ConsulSD
+	rules  []relabelRule
+	client *http.Client
+}
+
+// consulServiceEntry subset of the /v1/health/service response
+type consulServiceEntry struct {
+	Node struct {
+		Node       string            `json:"Node"`
+		Address    string            `json:"Address"`
+		Datacenter string            `json:"Datacenter"`
+		Meta       map[string]string `json:"Meta"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Tags    []string          `json:"Tags"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// NewConsulSD creates a new Consul based discoverer
+func NewConsulSD(logger *slog.Logger, cfg config.ConsulSD) *ConsulSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &ConsulSD{
+		logger: logger,
+		cfg:    cfg,
+		rules:  newRelabelRules(cfg.RelabelConfigs),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Run queries the Consul catalog on every refresh interval
+func (d *ConsulSD) Run(ctx context.Context, up chan<- config.Targets) {
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, err := d.refresh(ctx)
+		d.setHealth(err)
+		if err != nil {
+			// Keep the previously discovered targets while Consul is unavailable
+			d.logger.Error("Querying Consul", "type", "ConsulSD", "func", "Run", "server", d.cfg.Server, "err", err)
+		} else {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *ConsulSD) refresh(ctx context.Context) (config.Targets, error) {
+	// The token file wins over the environment variable
+	token := ""
+	if d.cfg.TokenEnv != "" {
+		token = os.Getenv(d.cfg.TokenEnv)
+	}
+	if d.cfg.TokenFile != "" {
+		b, err := os.ReadFile(d.cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token file: %s", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	dcs := d.cfg.Datacenters
+	if len(dcs) == 0 {
+		// Agent's datacenter
+		dcs = []string{""}
+	}
+
+	sets := []map[string]string{}
+	for _, dc := range dcs {
+		services := d.cfg.Services
+		if len(services) == 0 {
+			var err error
+			if services, err = d.services(ctx, token, dc); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, svc := range services {
+			entries := []consulServiceEntry{}
+			params := url.Values{"passing": {"1"}}
+			if dc != "" {
+				params.Set("dc", dc)
+			}
+			for _, tag := range d.cfg.Tags {
+				params.Add("tag", tag)
+			}
+			if err := d.get(ctx, token, "/v1/health/service/"+url.PathEscape(svc), params, &entries); err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				sets = append(sets, consulLabels(e))
+			}
+		}
+	}
+
+	return labelSetsToTargets(relabelSets(sets, d.rules), d.cfg.Types), nil
+}
+
+// services lists the catalog services having all the configured tags
+func (d *ConsulSD) services(ctx context.Context, token string, dc string) ([]string, error) {
+	catalog := map[string][]string{}
+	params := url.Values{}
+	if dc != "" {
+		params.Set("dc", dc)
+	}
+	if err := d.get(ctx, token, "/v1/catalog/services", params, &catalog); err != nil {
+		return nil, err
+	}
+
+	services := []string{}
+	for svc, tags := range catalog {
+		if hasTags(tags, d.cfg.Tags) {
+			services = append(services, svc)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// get decodes the JSON response of a Consul API endpoint
+func (d *ConsulSD) get(ctx context.Context, token string, path string, params url.Values, v interface{}) error {
+	u := strings.TrimSuffix(d.cfg.Server, "/") + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// consulLabels meta labels of a service instance
+func consulLabels(e consulServiceEntry) map[string]string {
+	address := e.Service.Address
+	if address == "" {
+		address = e.Node.Address
+	}
+
+	set := map[string]string{
+		labelAddress:                    address,
+		labelPort:                       strconv.Itoa(e.Service.Port),
+		"__meta_consul_address":         e.Node.Address,
+		"__meta_consul_dc":              e.Node.Datacenter,
+		"__meta_consul_node":            e.Node.Node,
+		"__meta_consul_service":         e.Service.Service,
+		"__meta_consul_service_address": e.Service.Address,
+		"__meta_consul_service_id":      e.Service.ID,
+		"__meta_consul_service_port":    strconv.Itoa(e.Service.Port),
+		"__meta_consul_tags":            "," + strings.Join(e.Service.Tags, ",") + ",",
+	}
+	if e.Service.Port == 0 {
+		delete(set, labelPort)
+	}
+	for k, v := range e.Service.Meta {
+		set["__meta_consul_service_metadata_"+sanitizeLabel(k)] = v
+	}
+	for k, v := range e.Node.Meta {
+		set["__meta_consul_metadata_"+sanitizeLabel(k)] = v
+	}
+	return set
+}
+
+// hasTags checks if all the required tags are present
+func hasTags(tags []string, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, t := range tags {
+			if t == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeLabel replaces the characters that are not valid in a label name
+func sanitizeLabel(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,412 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+const (
+	// Special labels controlling the generated targets, they are not exported as target labels
+	labelAddress   = "__address"
+	labelName      = "__name"
+	labelCheckType = "__check_type"
+	labelPort      = "__port"
+	labelInterval  = "__interval"
+	defaultType    = "ICMP"
+)
+
+// Discoverer provides the targets of a dynamic source
+type Discoverer interface {
+	// Run sends the complete list of targets of the source on every change until the context is canceled
+	Run(ctx context.Context, up chan<- config.Targets)
+}
+
+// TargetGroup Prometheus file_sd / http_sd compatible target group
+type TargetGroup struct {
+	Targets []string          `yaml:"targets" json:"targets"`
+	Labels  map[string]string `yaml:"labels" json:"labels"`
+}
+
+// SourceStats discovery statistics of a source
+type SourceStats struct {
+	Targets     int
+	Added       int
+	Removed     int
+	Dropped     int
+	Up          bool
+	LastSuccess time.Time
+	Failures    uint64
+	KeyErrors   []string
+}
+
+// healthReporter is implemented by the discoverers depending on a remote service
+type healthReporter interface {
+	// Health returns if the last refresh succeeded and the time of the last successful one
+	Health() (bool, time.Time)
+	// Failures returns the number of failed refreshes
+	Failures() uint64
+}
+
+// health tracks the refresh state of a discoverer
+type health struct {
+	up          bool
+	lastSuccess time.Time
+	failures    uint64
+	mtx         sync.RWMutex
+}
+
+// setHealth records the result of a refresh
+func (h *health) setHealth(err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.up = err == nil
+	if err == nil {
+		h.lastSuccess = time.Now()
+	} else {
+		h.failures++
+	}
+}
+
+// Health returns if the last refresh succeeded and the time of the last successful one
+func (h *health) Health() (bool, time.Time) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.up, h.lastSuccess
+}
+
+// Failures returns the number of failed refreshes
+func (h *health) Failures() uint64 {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.failures
+}
+
+// Manager runs the configured discoverers and merges their targets into the active configuration
+type Manager struct {
+	logger   *slog.Logger
+	sc       *config.SafeConfig
+	resolver *config.Resolver
+	notify   func()
+	cfg      config.Discovery
+	files    []string
+	cancel   context.CancelFunc
+	sources  []string
+	running  map[string]Discoverer
+	mtx      sync.Mutex
+	// Current target keys and statistics of each source
+	current  map[string]map[string]bool
+	stats    map[string]*SourceStats
+	statsMtx sync.RWMutex
+}
+
+// NewManager creates a discovery manager, notify is called every time the active targets changed
+func NewManager(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, notify func()) *Manager {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &Manager{
+		logger:   logger,
+		sc:       sc,
+		resolver: resolver,
+		notify:   notify,
+		running:  make(map[string]Discoverer),
+		current:  make(map[string]map[string]bool),
+		stats:    make(map[string]*SourceStats),
+	}
+}
+
+// ApplyConfig (re)starts the discoverers if the discovery configuration or the target files changed
+func (m *Manager) ApplyConfig(cfg config.Discovery, targetFiles []string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.cancel != nil && reflect.DeepEqual(m.cfg, cfg) && reflect.DeepEqual(m.files, targetFiles) {
+		return
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cfg = cfg
+	m.files = targetFiles
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.running = make(map[string]Discoverer)
+
+	sources := []string{}
+	if len(targetFiles) > 0 {
+		sources = append(sources, targetFilesSource)
+		m.run(ctx, targetFilesSource, NewTargetFilesSD(m.logger, targetFiles))
+	}
+	for i, c := range cfg.FileSD {
+		source := fmt.Sprintf("file_sd/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewFileSD(m.logger, c))
+	}
+	for i, c := range cfg.DNS {
+		source := fmt.Sprintf("dns/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewDNSSD(m.logger, m.resolver, c))
+	}
+	for i, c := range cfg.Consul {
+		source := fmt.Sprintf("consul/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewConsulSD(m.logger, c))
+	}
+	for i, c := range cfg.Kubernetes {
+		source := fmt.Sprintf("kubernetes/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewKubernetesSD(m.logger, c))
+	}
+	for i, c := range cfg.HTTP {
+		source := fmt.Sprintf("http/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewHTTPSD(m.logger, c))
+	}
+	for i, c := range cfg.NetBox {
+		source := fmt.Sprintf("netbox/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewNetBoxSD(m.logger, c))
+	}
+	for i, c := range cfg.KV {
+		source := fmt.Sprintf("kv/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewKVSD(m.logger, c))
+	}
+	for i, c := range cfg.EC2 {
+		source := fmt.Sprintf("ec2/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewEC2SD(m.logger, c))
+	}
+	for i, c := range cfg.Docker {
+		source := fmt.Sprintf("docker/%d", i)
+		sources = append(sources, source)
+		m.run(ctx, source, NewDockerSD(m.logger, c))
+	}
+
+	// Drop the targets of the sources that are no longer configured
+	removed := false
+	for _, source := range m.sources {
+		found := false
+		for _, s := range sources {
+			if s == source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.logger.Info("Removing discovery source", "type", "Discovery", "func", "ApplyConfig", "source", source)
+			if err := m.sc.SetDiscovered(m.logger, source, nil); err != nil {
+				m.logger.Error("Removing discovered targets", "type", "Discovery", "func", "ApplyConfig", "source", source, "err", err)
+			}
+			m.statsMtx.Lock()
+			delete(m.current, source)
+			delete(m.stats, source)
+			m.statsMtx.Unlock()
+			removed = true
+		}
+	}
+	m.sources = sources
+
+	if removed {
+		go m.notify()
+	}
+}
+
+// Stop halts all the running discoverers
+func (m *Manager) Stop() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+func (m *Manager) run(ctx context.Context, source string, d Discoverer) {
+	m.logger.Info("Starting discovery source", "type", "Discovery", "func", "run", "source", source)
+	m.running[source] = d
+
+	up := make(chan config.Targets)
+	go d.Run(ctx, up)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case targets := <-up:
+				m.update(source, targets)
+			}
+		}
+	}()
+}
+
+func (m *Manager) update(source string, targets config.Targets) {
+	m.logger.Debug("Discovered targets", "type", "Discovery", "func", "update", "source", source, "count", len(targets))
+
+	if err := m.sc.SetDiscovered(m.logger, source, targets); err != nil {
+		m.logger.Error("Updating discovered targets", "type", "Discovery", "func", "update", "source", source, "err", err)
+		return
+	}
+	m.updateStats(source, targets)
+	m.notify()
+}
+
+// updateStats diffs the new targets of a source against the previous ones
+func (m *Manager) updateStats(source string, targets config.Targets) {
+	m.statsMtx.Lock()
+	defer m.statsMtx.Unlock()
+
+	keys := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keys[string(t.Type)+"/"+t.Name] = true
+	}
+
+	stats, ok := m.stats[source]
+	if !ok {
+		stats = &SourceStats{}
+		m.stats[source] = stats
+	}
+	prev := m.current[source]
+	for k := range keys {
+		if !prev[k] {
+			stats.Added++
+		}
+	}
+	for k := range prev {
+		if !keys[k] {
+			stats.Removed++
+		}
+	}
+	stats.Targets = len(keys)
+	m.current[source] = keys
+
+	if stats.Added > 0 || stats.Removed > 0 {
+		m.logger.Debug("Discovery statistics", "type", "Discovery", "func", "updateStats", "source", source, "targets", stats.Targets, "added", stats.Added, "removed", stats.Removed)
+	}
+}
+
+// ExportStats returns the statistics of each discovery source
+func (m *Manager) ExportStats() map[string]SourceStats {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.statsMtx.RLock()
+	defer m.statsMtx.RUnlock()
+
+	dropped := m.sc.DroppedTargets()
+	s := make(map[string]SourceStats, len(m.running))
+	for source, d := range m.running {
+		stats := SourceStats{Up: true}
+		if v, ok := m.stats[source]; ok {
+			stats = *v
+			stats.Up = true
+		}
+		stats.Dropped = dropped[source]
+		if h, ok := d.(healthReporter); ok {
+			stats.Up, stats.LastSuccess = h.Health()
+			stats.Failures = h.Failures()
+		}
+		if r, ok := d.(keyErrorReporter); ok {
+			stats.KeyErrors = r.KeyErrors()
+		}
+		s[source] = stats
+	}
+	return s
+}
+
+// labelSetsToTargets converts the labels of the discovered items into target definitions
+// The special labels define the target and are not exported, all the other labels are added as extra labels
+func labelSetsToTargets(sets []map[string]string, defaultTypes []string) config.Targets {
+	targets := config.Targets{}
+	seen := map[string]bool{}
+	for _, set := range sets {
+		address := set[labelAddress]
+		if address == "" {
+			continue
+		}
+
+		types := defaultTypes
+		if v := set[labelCheckType]; v != "" {
+			types = []string{v}
+		}
+		// Invalid intervals fall back to the interval of the check type
+		interval, _ := time.ParseDuration(set[labelInterval])
+
+		kv := map[string]string{}
+		for k, v := range set {
+			if strings.HasPrefix(k, "__") {
+				continue
+			}
+			kv[k] = v
+		}
+
+		for _, v := range types {
+			// Matched case-insensitively like the config file types, the unknown ones are skipped by the config reload
+			checkType := config.TargetType(v)
+			if t, err := config.ParseTargetType(v); err == nil {
+				checkType = t
+			}
+			host := address
+			if port := set[labelPort]; port != "" && checkType != config.TypeHTTPGet {
+				if _, _, err := net.SplitHostPort(host); err != nil {
+					host = net.JoinHostPort(host, port)
+				}
+			}
+			if checkType != config.TypeTCP && checkType != config.TypeUDP && checkType != config.TypeDNS && checkType != config.TypeNTP && checkType != config.TypeSMTP && checkType != config.TypeHTTPGet {
+				// Only TCP, UDP, DNS, NTP and SMTP targets use ports
+				if h, _, err := net.SplitHostPort(host); err == nil {
+					host = h
+				}
+			}
+
+			name := set[labelName]
+			if name == "" {
+				name = host
+			}
+			if seen[string(checkType)+"/"+name] {
+				continue
+			}
+			seen[string(checkType)+"/"+name] = true
+
+			t := config.Target{Name: name, Host: host, Type: checkType}
+			if interval > 0 {
+				t.Interval.Set(interval)
+			}
+			if len(kv) > 0 {
+				t.Labels.Kv = kv
+			}
+			targets = append(targets, t)
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
+
+// groupsToLabelSets flattens target groups into the label set of each target
+func groupsToLabelSets(groups []TargetGroup) []map[string]string {
+	sets := []map[string]string{}
+	for _, g := range groups {
+		for _, host := range g.Targets {
+			set := make(map[string]string, len(g.Labels)+1)
+			for k, v := range g.Labels {
+				set[k] = v
+			}
+			set[labelAddress] = host
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
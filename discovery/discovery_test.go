@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+func TestLabelSetsToTargetsCheckType(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      map[string]string
+		defaults []string
+		kind     config.TargetType
+		host     string
+	}{
+		{name: "lowercase tcp", set: map[string]string{labelAddress: "192.168.0.1", labelPort: "443", labelCheckType: "tcp"}, kind: config.TypeTCP, host: "192.168.0.1:443"},
+		{name: "mixed case udp", set: map[string]string{labelAddress: "192.168.0.1", labelPort: "53", labelCheckType: "Udp"}, kind: config.TypeUDP, host: "192.168.0.1:53"},
+		{name: "lowercase http", set: map[string]string{labelAddress: "https://example.com", labelPort: "8443", labelCheckType: "httpget"}, kind: config.TypeHTTPGet, host: "https://example.com"},
+		{name: "lowercase icmp strips the port", set: map[string]string{labelAddress: "192.168.0.1:80", labelCheckType: "icmp+mtr"}, kind: config.TypeICMPMTR, host: "192.168.0.1"},
+		{name: "lowercase default type", set: map[string]string{labelAddress: "192.168.0.1", labelPort: "25"}, defaults: []string{"smtp"}, kind: config.TypeSMTP, host: "192.168.0.1:25"},
+		{name: "unknown type kept", set: map[string]string{labelAddress: "192.168.0.1", labelCheckType: "ftp"}, kind: "ftp", host: "192.168.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := tt.defaults
+			if defaults == nil {
+				defaults = []string{defaultType}
+			}
+			targets := labelSetsToTargets([]map[string]string{tt.set}, defaults)
+			if len(targets) != 1 {
+				t.Fatalf("targets %v, want 1", targets)
+			}
+			if targets[0].Type != tt.kind || targets[0].Host != tt.host {
+				t.Errorf("target %s %s, want %s %s", targets[0].Type, targets[0].Host, tt.kind, tt.host)
+			}
+		})
+	}
+}
+
+func TestLabelSetsToTargetsDuplicateTypes(t *testing.T) {
+	sets := []map[string]string{
+		{labelAddress: "192.168.0.1", labelPort: "443", labelCheckType: "tcp", "dc": "home"},
+		{labelAddress: "192.168.0.1", labelPort: "443", labelCheckType: "TCP", "dc": "lab"},
+	}
+	targets := labelSetsToTargets(sets, []string{defaultType})
+	if len(targets) != 1 {
+		t.Fatalf("targets %v, want the first one only", targets)
+	}
+	if want := map[string]string{"dc": "home"}; !maps.Equal(targets[0].Labels.Kv, want) {
+		t.Errorf("labels %v, want %v", targets[0].Labels.Kv, want)
+	}
+}
@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+// DNSSD discovers targets from A, AAAA, TXT or SRV records
+type DNSSD struct {
+	logger   *slog.Logger
+	resolver *config.Resolver
+	cfg      config.DNSSD
+	name     *template.Template
+}
+
+// dnsEntry values available to the name template
+type dnsEntry struct {
+	Target string
+	Host   string
+	Port   string
+	Query  string
+}
+
+// NewDNSSD creates a new DNS based discoverer
+func NewDNSSD(logger *slog.Logger, resolver *config.Resolver, cfg config.DNSSD) *DNSSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &DNSSD{
+		logger:   logger,
+		resolver: resolver,
+		cfg:      cfg,
+		// The template is validated when loading the config
+		name: template.Must(template.New("name").Parse(cfg.NameTemplate)),
+	}
+}
+
+// Run resolves the query on every refresh interval
+func (d *DNSSD) Run(ctx context.Context, up chan<- config.Targets) {
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, err := d.refresh(ctx)
+		if err != nil {
+			// Keep the previously discovered targets
+			d.logger.Error("Resolving discovery query", "type", "DNSSD", "func", "Run", "record", d.cfg.Record, "query", d.cfg.Query, "err", err)
+		} else {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *DNSSD) refresh(ctx context.Context) (config.Targets, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.resolver.Timeout)
+	defer cancel()
+
+	entries := []dnsEntry{}
+	r := d.resolver.Resolver
+
+	switch d.cfg.Record {
+	case "A", "AAAA":
+		network := "ip4"
+		if d.cfg.Record == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, d.cfg.Query)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			entries = append(entries, dnsEntry{Host: ip.String(), Port: d.cfg.Port})
+		}
+	case "TXT":
+		records, err := r.LookupTXT(ctx, d.cfg.Query)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			entries = append(entries, parseTXT(record, d.cfg.Port)...)
+		}
+	case "SRV":
+		_, records, err := r.LookupSRV(ctx, "", "", d.cfg.Query)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			entries = append(entries, dnsEntry{Host: strings.TrimSuffix(record.Target, "."), Port: strconv.Itoa(int(record.Port))})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type: %s", d.cfg.Record)
+	}
+
+	targets := config.Targets{}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		e.Query = d.cfg.Query
+		e.Target = e.Host
+		if e.Port != "" && (d.cfg.Type == "TCP" || d.cfg.Type == "UDP" || d.cfg.Type == "DNS" || d.cfg.Type == "NTP" || d.cfg.Type == "SMTP") {
+			e.Target = net.JoinHostPort(e.Host, e.Port)
+		}
+
+		var name bytes.Buffer
+		if err := d.name.Execute(&name, e); err != nil {
+			return nil, fmt.Errorf("rendering name template: %s", err)
+		}
+		if seen[name.String()] {
+			continue
+		}
+		seen[name.String()] = true
+
+		t := config.Target{Name: name.String(), Host: e.Target, Type: d.cfg.Type}
+		t.Labels.Kv = d.cfg.Labels.Kv
+		targets = append(targets, t)
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// parseTXT splits a TXT record with a comma/space separated list of host[:port]
+func parseTXT(record string, defaultPort string) []dnsEntry {
+	entries := []dnsEntry{}
+	fields := strings.FieldsFunc(record, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for _, f := range fields {
+		host, port, err := net.SplitHostPort(f)
+		if err != nil {
+			host = strings.Trim(f, "[]")
+			port = defaultPort
+		}
+		if host == "" {
+			continue
+		}
+		entries = append(entries, dnsEntry{Host: host, Port: port})
+	}
+	return entries
+}
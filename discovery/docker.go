@@ -0,0 +1,355 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+const (
+	dockerMinBackoff = time.Second
+	dockerMaxBackoff = 30 * time.Second
+	// Delay grouping the container events of a deployment into a single refresh
+	dockerEventDelay   = time.Second
+	dockerTimeout      = 30 * time.Second
+	dockerMetaPrefix   = "__meta_docker_"
+	dockerLabelsPrefix = dockerMetaPrefix + "container_label_"
+)
+
+// dockerSystemLabels namespaces of the container labels set by Docker, Compose and the image builders, they are not exported as target labels
+var dockerSystemLabels = []string{"com.docker.", "org.opencontainers."}
+
+// DockerSD discovers targets from the running containers of a Docker daemon
+type DockerSD struct {
+	health
+	logger *slog.Logger
+	cfg    config.DockerSD
+	rules  []relabelRule
+	client *http.Client
+	base   string
+}
+
+// dockerContainer container returned by the list API
+type dockerContainer struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Image      string            `json:"Image"`
+	State      string            `json:"State"`
+	Labels     map[string]string `json:"Labels"`
+	Ports      []dockerPort      `json:"Ports"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// dockerPort port of a container
+type dockerPort struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// dockerEvent container event of the event stream
+type dockerEvent struct {
+	Action string `json:"Action"`
+	Actor  struct {
+		ID string `json:"ID"`
+	} `json:"Actor"`
+}
+
+// NewDockerSD creates a new Docker based discoverer
+func NewDockerSD(logger *slog.Logger, cfg config.DockerSD) *DockerSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &DockerSD{
+		logger: logger,
+		cfg:    cfg,
+		rules:  newRelabelRules(cfg.RelabelConfigs),
+	}
+}
+
+// Run lists the containers on every refresh interval and as soon as the event stream reports a container change
+func (d *DockerSD) Run(ctx context.Context, up chan<- config.Targets) {
+	if err := d.setupClient(); err != nil {
+		d.setHealth(err)
+		d.logger.Error("Creating Docker client", "type", "DockerSD", "func", "Run", "host", d.cfg.Host, "err", err)
+		return
+	}
+
+	changed := make(chan struct{}, 1)
+	go d.watch(ctx, changed)
+
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, err := d.refresh(ctx)
+		d.setHealth(err)
+		if err != nil {
+			// Keep the previously discovered targets while the daemon is unavailable
+			d.logger.Error("Listing Docker containers", "type", "DockerSD", "func", "Run", "host", d.cfg.Host, "err", err)
+		} else {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(dockerEventDelay):
+			}
+		}
+	}
+}
+
+// setupClient creates the HTTP client of the daemon address (unix socket or TCP)
+func (d *DockerSD) setupClient() error {
+	u, err := url.Parse(d.cfg.Host)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := NewTLSConfig(d.cfg.TLS)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		}
+		d.base = "http://docker"
+	case "tcp":
+		scheme := "http"
+		if d.cfg.TLS.CAFile != "" || d.cfg.TLS.CertFile != "" {
+			scheme = "https"
+		}
+		d.base = scheme + "://" + u.Host
+	default:
+		d.base = strings.TrimSuffix(d.cfg.Host, "/")
+	}
+
+	// No client timeout as the event stream is long lived, the list requests use their own deadline
+	d.client = &http.Client{Transport: transport}
+	return nil
+}
+
+// watch follows the container events and reconnects with backoff, a refresh is requested after every event and (re)connection
+func (d *DockerSD) watch(ctx context.Context, changed chan<- struct{}) {
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	backoff := dockerMinBackoff
+	reconnect := false
+	for {
+		err := d.events(ctx, func() {
+			// Containers may have changed while the daemon was unreachable
+			if reconnect {
+				notify()
+			}
+			reconnect = true
+			backoff = dockerMinBackoff
+		}, notify)
+		if ctx.Err() != nil {
+			return
+		}
+
+		d.logger.Warn("Docker event stream closed", "type", "DockerSD", "func", "watch", "host", d.cfg.Host, "retry", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > dockerMaxBackoff {
+			backoff = dockerMaxBackoff
+		}
+	}
+}
+
+// events streams the container lifecycle events until the connection is closed
+func (d *DockerSD) events(ctx context.Context, connected func(), event func()) error {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "restart", "unpause", "pause", "die", "stop", "kill", "destroy", "rename"},
+	})
+	params := url.Values{"filters": {string(filters)}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+"/events?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(body)))
+	}
+	connected()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		e := dockerEvent{}
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("closed by the daemon")
+			}
+			return err
+		}
+		d.logger.Debug("Docker container event", "type", "DockerSD", "func", "events", "action", e.Action, "id", e.Actor.ID)
+		event()
+	}
+}
+
+// refresh lists the running containers matching the label selector
+func (d *DockerSD) refresh(ctx context.Context) (config.Targets, error) {
+	filters := map[string][]string{"status": {"running"}}
+	for _, l := range strings.Split(d.cfg.LabelSelector, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			filters["label"] = append(filters["label"], l)
+		}
+	}
+	f, _ := json.Marshal(filters)
+	params := url.Values{"filters": {string(f)}}
+
+	ctx, cancel := context.WithTimeout(ctx, dockerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+"/containers/json?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(body)))
+	}
+
+	containers := []dockerContainer{}
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("parsing containers: %s", err)
+	}
+
+	sets := []map[string]string{}
+	for _, c := range containers {
+		sets = append(sets, d.labelSets(c)...)
+	}
+	return labelSetsToTargets(relabelSets(sets, d.rules), nil), nil
+}
+
+// labelSets returns a TCP target per published port and optionally an ICMP target per user-defined network address
+func (d *DockerSD) labelSets(c dockerContainer) []map[string]string {
+	name := c.ID
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	common := map[string]string{
+		dockerMetaPrefix + "container_id":           c.ID,
+		dockerMetaPrefix + "container_name":         name,
+		dockerMetaPrefix + "container_image":        c.Image,
+		dockerMetaPrefix + "container_network_mode": c.HostConfig.NetworkMode,
+	}
+	for k, v := range c.Labels {
+		common[dockerLabelsPrefix+sanitizeLabel(k)] = v
+		if !isDockerSystemLabel(k) {
+			common[sanitizeLabel(k)] = v
+		}
+	}
+
+	sets := []map[string]string{}
+	seen := map[string]bool{}
+	for _, p := range c.Ports {
+		if p.PublicPort == 0 || p.Type != "tcp" {
+			continue
+		}
+		// The ports published on all the interfaces are listed for both IPv4 and IPv6
+		address := p.IP
+		if ip := net.ParseIP(address); ip == nil || ip.IsUnspecified() {
+			address = d.cfg.HostAddress
+		}
+		port := strconv.Itoa(p.PublicPort)
+		if seen[address+"/"+port] {
+			continue
+		}
+		seen[address+"/"+port] = true
+
+		set := copyLabels(common)
+		set[labelAddress] = address
+		set[labelPort] = port
+		set[labelName] = name + ":" + port
+		set[labelCheckType] = "TCP"
+		set[dockerMetaPrefix+"port_ip"] = p.IP
+		set[dockerMetaPrefix+"port_private"] = strconv.Itoa(p.PrivatePort)
+		set[dockerMetaPrefix+"port_public"] = port
+		sets = append(sets, set)
+	}
+
+	if d.cfg.NetworkICMP {
+		for network, n := range c.NetworkSettings.Networks {
+			// Only the user-defined networks, the default ones are not routable or shared with the host
+			if network == "bridge" || network == "host" || network == "none" || n.IPAddress == "" {
+				continue
+			}
+			set := copyLabels(common)
+			set[labelAddress] = n.IPAddress
+			set[labelName] = name + "/" + network
+			set[labelCheckType] = "ICMP"
+			set[dockerMetaPrefix+"network_name"] = network
+			set[dockerMetaPrefix+"network_ip"] = n.IPAddress
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+// isDockerSystemLabel reports if a container label is set by the Docker tooling
+func isDockerSystemLabel(key string) bool {
+	for _, p := range dockerSystemLabels {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
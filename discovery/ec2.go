@@ -0,0 +1,221 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/syepes/network_exporter/config"
+)
+
+const (
+	// Attempts of the throttled or failed EC2 requests, retried with an exponential backoff
+	ec2MaxAttempts = 10
+	ec2MaxBackoff  = 30 * time.Second
+	ec2MetaPrefix  = "__meta_ec2_"
+)
+
+// EC2SD discovers targets from the running EC2 instances of the configured regions
+type EC2SD struct {
+	health
+	logger  *slog.Logger
+	cfg     config.EC2SD
+	rules   []relabelRule
+	clients map[string]*ec2.Client
+	// Last successfully discovered label sets of each region
+	cache map[string][]map[string]string
+}
+
+// NewEC2SD creates a new EC2 based discoverer
+func NewEC2SD(logger *slog.Logger, cfg config.EC2SD) *EC2SD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &EC2SD{
+		logger:  logger,
+		cfg:     cfg,
+		rules:   newRelabelRules(cfg.RelabelConfigs),
+		clients: make(map[string]*ec2.Client),
+		cache:   make(map[string][]map[string]string),
+	}
+}
+
+// Run describes the instances on every refresh interval
+func (d *EC2SD) Run(ctx context.Context, up chan<- config.Targets) {
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, err := d.refresh(ctx)
+		d.setHealth(err)
+		if err != nil {
+			// Keep the previously discovered targets while the API is unavailable
+			d.logger.Error("Describing EC2 instances", "type", "EC2SD", "func", "Run", "regions", d.cfg.Regions, "err", err)
+		} else {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh describes the instances of every region, a failing region keeps its last results and only fails the refresh when all of them failed
+func (d *EC2SD) refresh(ctx context.Context) (config.Targets, error) {
+	var lastErr error
+	failed := 0
+	for _, region := range d.cfg.Regions {
+		sets, err := d.describe(ctx, region)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			d.logger.Warn("EC2 region failed, keeping its previous targets", "type", "EC2SD", "func", "refresh", "region", region, "err", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		d.cache[region] = sets
+	}
+	if failed == len(d.cfg.Regions) {
+		return nil, lastErr
+	}
+
+	sets := []map[string]string{}
+	for _, region := range d.cfg.Regions {
+		sets = append(sets, d.cache[region]...)
+	}
+	return labelSetsToTargets(relabelSets(sets, d.rules), d.cfg.Types), nil
+}
+
+// describe pages through the running instances of a region matching the filters
+func (d *EC2SD) describe(ctx context.Context, region string) ([]map[string]string, error) {
+	client, err := d.client(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the running instances are probed, the stopped and terminated ones are removed on the next refresh
+	filters := []ec2types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"running"}}}
+	for _, f := range d.cfg.Filters {
+		filters = append(filters, ec2types.Filter{Name: aws.String(f.Name), Values: f.Values})
+	}
+
+	sets := []map[string]string{}
+	p := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{Filters: filters})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Reservations {
+			for _, i := range r.Instances {
+				if set := d.labels(region, r, i); set != nil {
+					sets = append(sets, set)
+				}
+			}
+		}
+	}
+	return sets, nil
+}
+
+// labels returns the meta labels of an instance, nil when it does not have the configured address
+func (d *EC2SD) labels(region string, r ec2types.Reservation, i ec2types.Instance) map[string]string {
+	private := aws.ToString(i.PrivateIpAddress)
+	public := aws.ToString(i.PublicIpAddress)
+	address := private
+	if d.cfg.Address == "public" {
+		address = public
+	}
+	if address == "" {
+		return nil
+	}
+
+	set := map[string]string{
+		labelAddress:                       address,
+		labelName:                          aws.ToString(i.InstanceId),
+		ec2MetaPrefix + "region":           region,
+		ec2MetaPrefix + "instance_id":      aws.ToString(i.InstanceId),
+		ec2MetaPrefix + "instance_type":    string(i.InstanceType),
+		ec2MetaPrefix + "instance_state":   string(i.State.Name),
+		ec2MetaPrefix + "owner_id":         aws.ToString(r.OwnerId),
+		ec2MetaPrefix + "private_ip":       private,
+		ec2MetaPrefix + "private_dns_name": aws.ToString(i.PrivateDnsName),
+		ec2MetaPrefix + "vpc_id":           aws.ToString(i.VpcId),
+		ec2MetaPrefix + "subnet_id":        aws.ToString(i.SubnetId),
+	}
+	if public != "" {
+		set[ec2MetaPrefix+"public_ip"] = public
+		set[ec2MetaPrefix+"public_dns_name"] = aws.ToString(i.PublicDnsName)
+	}
+	if i.Placement != nil {
+		set[ec2MetaPrefix+"availability_zone"] = aws.ToString(i.Placement.AvailabilityZone)
+	}
+	for _, t := range i.Tags {
+		set[ec2MetaPrefix+"tag_"+sanitizeLabel(aws.ToString(t.Key))] = aws.ToString(t.Value)
+	}
+	return set
+}
+
+// client returns the API client of a region, the credentials come from the default chain (environment, shared config, instance profile)
+// or from the assumed role when configured
+func (d *EC2SD) client(ctx context.Context, region string) (*ec2.Client, error) {
+	if c, ok := d.clients[region]; ok {
+		return c, nil
+	}
+
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(region),
+		awsconfig.WithRetryer(func() aws.Retryer {
+			// No client side retry quota so that the throttled requests are always retried with backoff
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = ec2MaxAttempts
+				o.MaxBackoff = ec2MaxBackoff
+				o.RateLimiter = ratelimit.None
+			})
+		}),
+	}
+	if d.cfg.Profile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(d.cfg.Profile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %s", err)
+	}
+
+	if role := d.cfg.RoleCredentials; role.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = role.SessionName
+			if role.ExternalID != "" {
+				o.ExternalID = aws.String(role.ExternalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	c := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if d.cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(strings.TrimSuffix(d.cfg.Endpoint, "/"))
+		}
+	})
+	d.clients[region] = c
+	return c, nil
+}
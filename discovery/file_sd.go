@@ -0,0 +1,174 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/syepes/network_exporter/config"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FileSD reads the targets from Prometheus file_sd formatted files
+type FileSD struct {
+	logger   *slog.Logger
+	patterns []string
+	interval time.Duration
+	// read parses the targets of a file
+	read func(file string) (config.Targets, error)
+	// Last successfully parsed targets of each file
+	cache map[string]config.Targets
+}
+
+// NewFileSD creates a new file based discoverer
+func NewFileSD(logger *slog.Logger, cfg config.FileSD) *FileSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	rules := newRelabelRules(cfg.RelabelConfigs)
+	return &FileSD{
+		logger:   logger,
+		patterns: cfg.Files,
+		interval: cfg.RefreshInterval.Duration(),
+		read: func(file string) (config.Targets, error) {
+			return readTargetFile(file, rules)
+		},
+		cache: make(map[string]config.Targets),
+	}
+}
+
+// Run watches the files for changes and re-reads them on every interval
+func (d *FileSD) Run(ctx context.Context, up chan<- config.Targets) {
+	var events chan fsnotify.Event
+	var errors chan error
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		d.logger.Error("Creating file watcher, falling back to interval refresh", "type", "FileSD", "func", "Run", "err", err)
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+		errors = watcher.Errors
+
+		// Watch the directories so that created and atomically replaced files are detected
+		dirs := map[string]bool{}
+		for _, p := range d.patterns {
+			dirs[filepath.Dir(p)] = true
+		}
+		for dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				d.logger.Error("Watching directory", "type", "FileSD", "func", "Run", "dir", dir, "err", err)
+			}
+		}
+	}
+
+	d.refresh(ctx, up)
+
+	var tick <-chan time.Time
+	if d.interval > 0 {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if !d.matches(ev.Name) || (ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write)) {
+				continue
+			}
+			d.logger.Debug("File changed", "type", "FileSD", "func", "Run", "file", ev.Name, "op", ev.Op.String())
+			d.refresh(ctx, up)
+		case err := <-errors:
+			d.logger.Error("File watcher", "type", "FileSD", "func", "Run", "err", err)
+		case <-tick:
+			d.refresh(ctx, up)
+		}
+	}
+}
+
+// matches checks if a file is part of the configured patterns
+func (d *FileSD) matches(file string) bool {
+	for _, p := range d.patterns {
+		if ok, err := filepath.Match(p, file); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *FileSD) refresh(ctx context.Context, up chan<- config.Targets) {
+	files := []string{}
+	for _, p := range d.patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			d.logger.Error("Invalid file pattern", "type", "FileSD", "func", "refresh", "pattern", p, "err", err)
+			continue
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	present := map[string]bool{}
+	targets := config.Targets{}
+	for _, f := range files {
+		if present[f] {
+			continue
+		}
+		present[f] = true
+
+		t, err := d.read(f)
+		if err != nil {
+			// Keep the last good content of the file
+			d.logger.Error("Reading target file", "type", "FileSD", "func", "refresh", "file", f, "err", err)
+			t = d.cache[f]
+		} else {
+			d.cache[f] = t
+		}
+		targets = append(targets, t...)
+	}
+
+	for f := range d.cache {
+		if !present[f] {
+			delete(d.cache, f)
+		}
+	}
+
+	select {
+	case up <- targets:
+	case <-ctx.Done():
+	}
+}
+
+// readTargetFile parses and relabels a JSON or YAML list of target groups
+func readTargetFile(file string, rules []relabelRule) (config.Targets, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []TargetGroup
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, &groups)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &groups)
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing target groups: %s", err)
+	}
+
+	return labelSetsToTargets(relabelSets(groupsToLabelSets(groups), rules), []string{defaultType}), nil
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+// HTTPSD polls the targets from a Prometheus http_sd compatible endpoint or an endpoint listing targets in the config file format
+type HTTPSD struct {
+	health
+	logger *slog.Logger
+	cfg    config.HTTPSD
+	rules  []relabelRule
+	client *http.Client
+	// Validators of the last processed response
+	etag         string
+	lastModified string
+}
+
+// NewHTTPSD creates a new HTTP based discoverer
+func NewHTTPSD(logger *slog.Logger, cfg config.HTTPSD) *HTTPSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &HTTPSD{
+		logger: logger,
+		cfg:    cfg,
+		rules:  newRelabelRules(cfg.RelabelConfigs),
+	}
+}
+
+// Run fetches the target groups on every refresh interval
+func (d *HTTPSD) Run(ctx context.Context, up chan<- config.Targets) {
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, changed, err := d.refresh(ctx)
+		d.setHealth(err)
+		if err != nil {
+			// Keep the previously discovered targets
+			d.logger.Error("Fetching target groups", "type", "HTTPSD", "func", "Run", "url", d.cfg.URL, "err", err)
+		} else if changed {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh returns the targets of the endpoint and if they changed since the last call
+func (d *HTTPSD) refresh(ctx context.Context) (config.Targets, bool, error) {
+	if d.client == nil {
+		client, err := NewHTTPClient(d.cfg.TLS, d.cfg.Refresh.Duration())
+		if err != nil {
+			return nil, false, err
+		}
+		d.client = client
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.cfg.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "network_exporter")
+	if d.etag != "" {
+		req.Header.Set("If-None-Match", d.etag)
+	}
+	if d.lastModified != "" {
+		req.Header.Set("If-Modified-Since", d.lastModified)
+	}
+	if err := SetAuth(req, d.cfg.Auth); err != nil {
+		return nil, false, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		d.logger.Debug("Target groups not modified", "type", "HTTPSD", "func", "refresh", "url", d.cfg.URL)
+		return nil, false, nil
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, false, fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(body)))
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, false, fmt.Errorf("parsing target groups: %s", err)
+	}
+	targets, err := d.parse(items)
+	if err != nil {
+		return nil, false, err
+	}
+
+	d.etag = resp.Header.Get("ETag")
+	d.lastModified = resp.Header.Get("Last-Modified")
+	return targets, true, nil
+}
+
+// parse converts the items of the response, the http_sd target groups (holding targets) are relabeled and
+// the other items are targets in the config file format which must have a name, host and type
+func (d *HTTPSD) parse(items []json.RawMessage) (config.Targets, error) {
+	var groups []TargetGroup
+	targets := config.Targets{}
+	for i, item := range items {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(item, &keys); err != nil {
+			return nil, fmt.Errorf("parsing item %d: %s", i, err)
+		}
+		if _, ok := keys["targets"]; ok {
+			var g TargetGroup
+			if err := json.Unmarshal(item, &g); err != nil {
+				return nil, fmt.Errorf("parsing target group %d: %s", i, err)
+			}
+			groups = append(groups, g)
+			continue
+		}
+
+		var t config.Target
+		if err := json.Unmarshal(item, &t); err != nil {
+			return nil, fmt.Errorf("parsing target %d: %s", i, err)
+		}
+		if t.Name == "" || t.Host == "" || t.Type == "" {
+			return nil, fmt.Errorf("target %d: name, host and type are required", i)
+		}
+		targets = append(targets, t)
+	}
+	return append(labelSetsToTargets(relabelSets(groupsToLabelSets(groups), d.rules), []string{defaultType}), targets...), nil
+}
+
+// NewHTTPClient creates the client used to reach a discovery endpoint or a webhook receiver
+func NewHTTPClient(cfg config.TLSConfig, timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// NewTLSConfig loads the CA and client certificates of a TLS client
+func NewTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	return cfg.ClientConfig()
+}
+
+// SetAuth adds the configured credentials to a request
+func SetAuth(req *http.Request, auth config.HTTPAuth) error {
+	switch {
+	case auth.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+	case auth.BearerTokenFile != "":
+		b, err := os.ReadFile(auth.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("reading bearer token file: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(b)))
+	case auth.Username != "":
+		password, err := authPassword(auth)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(auth.Username, password)
+	}
+	return nil
+}
+
+// authPassword returns the configured password or the content of the password file
+func authPassword(auth config.HTTPAuth) (string, error) {
+	if auth.PasswordFile == "" {
+		return auth.Password, nil
+	}
+	b, err := os.ReadFile(auth.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("reading password file: %s", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,394 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	kubernetesMetaPrefix = "__meta_kubernetes_"
+	// Delay used to coalesce bursts of object changes
+	kubernetesSettle = time.Second
+	// Delay before reconnecting after a client error
+	kubernetesRetry = 30 * time.Second
+)
+
+// KubernetesSD discovers targets from the nodes, pods, services or endpoints of a Kubernetes cluster
+type KubernetesSD struct {
+	health
+	logger *slog.Logger
+	cfg    config.KubernetesSD
+	rules  []relabelRule
+}
+
+// NewKubernetesSD creates a new Kubernetes based discoverer
+func NewKubernetesSD(logger *slog.Logger, cfg config.KubernetesSD) *KubernetesSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &KubernetesSD{
+		logger: logger,
+		cfg:    cfg,
+		rules:  newRelabelRules(cfg.RelabelConfigs),
+	}
+}
+
+// Run watches the objects of the role with informers and sends the targets on every change
+func (d *KubernetesSD) Run(ctx context.Context, up chan<- config.Targets) {
+	for {
+		err := d.watch(ctx, up)
+		if ctx.Err() != nil {
+			return
+		}
+		// Keep the previously discovered targets until the watch is re-established
+		d.setHealth(err)
+		d.logger.Error("Watching Kubernetes objects", "type", "KubernetesSD", "func", "Run", "role", d.cfg.Role, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(kubernetesRetry):
+		}
+	}
+}
+
+// client uses the configured kubeconfig, the in-cluster service account or the default kubeconfig
+func (d *KubernetesSD) client() (kubernetes.Interface, error) {
+	var rc *rest.Config
+	var err error
+	if d.cfg.Kubeconfig != "" {
+		rc, err = clientcmd.BuildConfigFromFlags("", d.cfg.Kubeconfig)
+	} else if rc, err = rest.InClusterConfig(); err == rest.ErrNotInCluster {
+		rc, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading client config: %s", err)
+	}
+	rc.UserAgent = "network_exporter"
+	return kubernetes.NewForConfig(rc)
+}
+
+func (d *KubernetesSD) watch(ctx context.Context, up chan<- config.Targets) error {
+	client, err := d.client()
+	if err != nil {
+		return err
+	}
+
+	namespaces := d.cfg.Namespaces
+	if len(namespaces) == 0 || d.cfg.Role == "node" {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}
+	tweak := func(o *metav1.ListOptions) {
+		o.LabelSelector = d.cfg.LabelSelector
+		o.FieldSelector = d.cfg.FieldSelector
+	}
+
+	// The informers are stopped with the watch
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stores := []cache.Store{}
+	synced := []cache.InformerSynced{}
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, d.cfg.Resync.Duration(), informers.WithNamespace(ns), informers.WithTweakListOptions(tweak))
+
+		var informer cache.SharedIndexInformer
+		switch d.cfg.Role {
+		case "node":
+			informer = factory.Core().V1().Nodes().Informer()
+		case "pod":
+			informer = factory.Core().V1().Pods().Informer()
+		case "service":
+			informer = factory.Core().V1().Services().Informer()
+		case "endpoints":
+			informer = factory.Discovery().V1().EndpointSlices().Informer()
+		default:
+			return fmt.Errorf("unsupported role: %s", d.cfg.Role)
+		}
+
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return err
+		}
+		if err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+			d.setHealth(err)
+			d.logger.Error("Kubernetes watch", "type", "KubernetesSD", "func", "watch", "role", d.cfg.Role, "namespace", ns, "err", err)
+		}); err != nil {
+			return err
+		}
+
+		stores = append(stores, informer.GetStore())
+		synced = append(synced, informer.HasSynced)
+		factory.Start(ctx.Done())
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("waiting for the informer caches to sync")
+	}
+
+	for {
+		sets := []map[string]string{}
+		for _, s := range stores {
+			for _, obj := range s.List() {
+				for _, set := range kubernetesLabelSets(obj, corev1.NodeAddressType(d.cfg.NodeAddressType)) {
+					if d.cfg.TargetLabels {
+						addTargetLabels(set)
+					}
+					sets = append(sets, set)
+				}
+			}
+		}
+		d.setHealth(nil)
+
+		select {
+		case up <- labelSetsToTargets(relabelSets(sets, d.rules), d.cfg.Types):
+		case <-ctx.Done():
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(kubernetesSettle):
+		}
+		// The changes received while settling are part of this rebuild
+		select {
+		case <-changed:
+		default:
+		}
+	}
+}
+
+// kubernetesLabelSets meta labels of each target provided by an object, the nodes use the address of the given type when they have one
+func kubernetesLabelSets(obj interface{}, addressType corev1.NodeAddressType) []map[string]string {
+	switch o := obj.(type) {
+	case *corev1.Node:
+		return nodeLabelSets(o, addressType)
+	case *corev1.Pod:
+		return podLabelSets(o)
+	case *corev1.Service:
+		return serviceLabelSets(o)
+	case *discoveryv1.EndpointSlice:
+		return endpointSliceLabelSets(o)
+	}
+	return nil
+}
+
+func nodeLabelSets(n *corev1.Node, addressType corev1.NodeAddressType) []map[string]string {
+	set := map[string]string{
+		kubernetesMetaPrefix + "node_name": n.Name,
+	}
+	addObjectLabels(set, "node", n.ObjectMeta)
+	for _, l := range []string{corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone} {
+		if v := n.Labels[l]; v != "" {
+			set[kubernetesMetaPrefix+"node_zone"] = v
+			break
+		}
+	}
+
+	for _, a := range n.Status.Addresses {
+		key := kubernetesMetaPrefix + "node_address_" + sanitizeLabel(string(a.Type))
+		if _, ok := set[key]; !ok {
+			set[key] = a.Address
+		}
+	}
+
+	// Prefer the address of the configured type and then the internal one
+	for _, t := range []corev1.NodeAddressType{addressType, corev1.NodeInternalIP, corev1.NodeExternalIP, corev1.NodeHostName} {
+		if v := set[kubernetesMetaPrefix+"node_address_"+string(t)]; v != "" {
+			set[labelAddress] = v
+			break
+		}
+	}
+	if set[labelAddress] == "" && len(n.Status.Addresses) > 0 {
+		set[labelAddress] = n.Status.Addresses[0].Address
+	}
+	return []map[string]string{set}
+}
+
+func podLabelSets(p *corev1.Pod) []map[string]string {
+	// Terminating and completed pods are removed right away
+	if p.DeletionTimestamp != nil || p.Status.PodIP == "" || p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+		return nil
+	}
+
+	ready := "false"
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			ready = "true"
+		}
+	}
+
+	base := map[string]string{
+		labelAddress:                           p.Status.PodIP,
+		kubernetesMetaPrefix + "namespace":     p.Namespace,
+		kubernetesMetaPrefix + "pod_name":      p.Name,
+		kubernetesMetaPrefix + "pod_ip":        p.Status.PodIP,
+		kubernetesMetaPrefix + "pod_host_ip":   p.Status.HostIP,
+		kubernetesMetaPrefix + "pod_node_name": p.Spec.NodeName,
+		kubernetesMetaPrefix + "pod_phase":     string(p.Status.Phase),
+		kubernetesMetaPrefix + "pod_ready":     ready,
+		kubernetesMetaPrefix + "pod_uid":       string(p.UID),
+	}
+	addObjectLabels(base, "pod", p.ObjectMeta)
+
+	sets := []map[string]string{}
+	for _, c := range p.Spec.Containers {
+		for _, port := range c.Ports {
+			set := copyLabels(base)
+			set[labelPort] = strconv.Itoa(int(port.ContainerPort))
+			set[kubernetesMetaPrefix+"pod_container_name"] = c.Name
+			set[kubernetesMetaPrefix+"pod_container_port_name"] = port.Name
+			set[kubernetesMetaPrefix+"pod_container_port_number"] = strconv.Itoa(int(port.ContainerPort))
+			set[kubernetesMetaPrefix+"pod_container_port_protocol"] = string(port.Protocol)
+			sets = append(sets, set)
+		}
+	}
+	if len(sets) == 0 {
+		sets = append(sets, base)
+	}
+	return sets
+}
+
+func serviceLabelSets(s *corev1.Service) []map[string]string {
+	address := s.Name + "." + s.Namespace + ".svc"
+	if s.Spec.Type == corev1.ServiceTypeExternalName {
+		address = s.Spec.ExternalName
+	}
+
+	base := map[string]string{
+		labelAddress:                                address,
+		kubernetesMetaPrefix + "namespace":          s.Namespace,
+		kubernetesMetaPrefix + "service_name":       s.Name,
+		kubernetesMetaPrefix + "service_type":       string(s.Spec.Type),
+		kubernetesMetaPrefix + "service_cluster_ip": s.Spec.ClusterIP,
+	}
+	addObjectLabels(base, "service", s.ObjectMeta)
+
+	sets := []map[string]string{}
+	for _, port := range s.Spec.Ports {
+		set := copyLabels(base)
+		set[labelPort] = strconv.Itoa(int(port.Port))
+		set[kubernetesMetaPrefix+"service_port_name"] = port.Name
+		set[kubernetesMetaPrefix+"service_port_number"] = strconv.Itoa(int(port.Port))
+		set[kubernetesMetaPrefix+"service_port_protocol"] = string(port.Protocol)
+		sets = append(sets, set)
+	}
+	if len(sets) == 0 {
+		sets = append(sets, base)
+	}
+	return sets
+}
+
+func endpointSliceLabelSets(e *discoveryv1.EndpointSlice) []map[string]string {
+	base := map[string]string{
+		kubernetesMetaPrefix + "namespace":          e.Namespace,
+		kubernetesMetaPrefix + "endpointslice_name": e.Name,
+		kubernetesMetaPrefix + "endpoints_name":     e.Labels[discoveryv1.LabelServiceName],
+	}
+	addObjectLabels(base, "endpointslice", e.ObjectMeta)
+
+	sets := []map[string]string{}
+	for _, ep := range e.Endpoints {
+		// Only the endpoints receiving traffic are probed
+		if (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) || (ep.Conditions.Terminating != nil && *ep.Conditions.Terminating) {
+			continue
+		}
+
+		for _, address := range ep.Addresses {
+			set := copyLabels(base)
+			set[labelAddress] = address
+			if ep.NodeName != nil {
+				set[kubernetesMetaPrefix+"endpoint_node_name"] = *ep.NodeName
+			}
+			if ep.Hostname != nil {
+				set[kubernetesMetaPrefix+"endpoint_hostname"] = *ep.Hostname
+			}
+
+			if len(e.Ports) == 0 {
+				sets = append(sets, set)
+				continue
+			}
+			for _, port := range e.Ports {
+				if port.Port == nil {
+					continue
+				}
+				ps := copyLabels(set)
+				ps[labelPort] = strconv.Itoa(int(*port.Port))
+				if port.Name != nil {
+					ps[kubernetesMetaPrefix+"endpoint_port_name"] = *port.Name
+				}
+				if port.Protocol != nil {
+					ps[kubernetesMetaPrefix+"endpoint_port_protocol"] = string(*port.Protocol)
+				}
+				sets = append(sets, ps)
+			}
+		}
+	}
+	return sets
+}
+
+// addObjectLabels adds the labels and annotations of an object as meta labels
+// addTargetLabels exports the node name, zone and namespace meta labels as the node, zone and namespace target labels
+func addTargetLabels(set map[string]string) {
+	for label, meta := range map[string][]string{
+		"node":      {"node_name", "pod_node_name", "endpoint_node_name"},
+		"zone":      {"node_zone"},
+		"namespace": {"namespace"},
+	} {
+		for _, m := range meta {
+			if v := set[kubernetesMetaPrefix+m]; v != "" {
+				set[label] = v
+				break
+			}
+		}
+	}
+}
+
+func addObjectLabels(set map[string]string, kind string, meta metav1.ObjectMeta) {
+	for k, v := range meta.Labels {
+		set[kubernetesMetaPrefix+kind+"_label_"+sanitizeLabel(k)] = v
+	}
+	for k, v := range meta.Annotations {
+		set[kubernetesMetaPrefix+kind+"_annotation_"+sanitizeLabel(k)] = v
+	}
+}
+
+func copyLabels(set map[string]string) map[string]string {
+	c := make(map[string]string, len(set))
+	for k, v := range set {
+		c[k] = v
+	}
+	return c
+}
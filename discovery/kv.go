@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	kvMinBackoff = time.Second
+	kvMaxBackoff = 30 * time.Second
+)
+
+// errKVResync is returned by the backends when the watch needs to restart from a new snapshot
+var errKVResync = errors.New("resync")
+
+// kvBackend watches a key space
+type kvBackend interface {
+	// watch calls snapshot with all the keys under the prefix and then event for every change (nil value when deleted) until an error occurs
+	watch(ctx context.Context, snapshot func(map[string][]byte), event func(key string, value []byte)) error
+}
+
+// keyErrorReporter is implemented by the discoverers reporting the items that could not be parsed
+type keyErrorReporter interface {
+	// KeyErrors returns the keys currently holding an invalid target
+	KeyErrors() []string
+}
+
+// KVSD discovers targets from the values of an etcd or Redis key space
+type KVSD struct {
+	health
+	logger  *slog.Logger
+	cfg     config.KVSD
+	backend kvBackend
+	values  map[string][]byte
+	errors  map[string]bool
+	errMtx  sync.RWMutex
+}
+
+// NewKVSD creates a new key/value store based discoverer
+func NewKVSD(logger *slog.Logger, cfg config.KVSD) *KVSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	d := &KVSD{
+		logger: logger,
+		cfg:    cfg,
+		values: make(map[string][]byte),
+		errors: make(map[string]bool),
+	}
+	if cfg.Backend == "redis" {
+		d.backend = newRedisBackend(logger, cfg)
+	} else {
+		d.backend = newEtcdBackend(logger, cfg)
+	}
+	return d
+}
+
+// Run watches the key space and re-establishes the watch with a new snapshot on every resync interval or connection loss
+func (d *KVSD) Run(ctx context.Context, up chan<- config.Targets) {
+	backoff := kvMinBackoff
+	for {
+		synced := false
+		wctx, cancel := context.WithTimeout(ctx, d.cfg.Resync.Duration())
+		err := d.backend.watch(wctx,
+			func(kvs map[string][]byte) {
+				synced = true
+				d.values = kvs
+				d.setHealth(nil)
+				d.send(ctx, up)
+			},
+			func(key string, value []byte) {
+				if value == nil {
+					delete(d.values, key)
+				} else {
+					d.values[key] = value
+				}
+				d.send(ctx, up)
+			})
+		resync := wctx.Err() != nil
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if synced {
+			backoff = kvMinBackoff
+		}
+		if resync || err == errKVResync {
+			d.logger.Debug("Resyncing key space", "type", "KVSD", "func", "Run", "backend", d.cfg.Backend, "prefix", d.cfg.Prefix)
+			continue
+		}
+
+		// Keep the previously discovered targets until the watch is re-established
+		d.setHealth(err)
+		d.logger.Error("Watching key space", "type", "KVSD", "func", "Run", "backend", d.cfg.Backend, "prefix", d.cfg.Prefix, "retry", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > kvMaxBackoff {
+			backoff = kvMaxBackoff
+		}
+	}
+}
+
+// send parses the current values into targets
+func (d *KVSD) send(ctx context.Context, up chan<- config.Targets) {
+	keys := make([]string, 0, len(d.values))
+	for k := range d.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	targets := config.Targets{}
+	invalid := map[string]bool{}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		t, err := parseKVTarget(k, d.cfg.Prefix, d.values[k])
+		if err == nil && seen[string(t.Type)+"/"+t.Name] {
+			err = fmt.Errorf("duplicated target %s", t.Name)
+		}
+		if err != nil {
+			// Only log the keys that became invalid, the writes are done by this goroutine
+			if !d.errors[k] {
+				d.logger.Warn("Skipping invalid target", "type", "KVSD", "func", "send", "key", k, "err", err)
+			}
+			invalid[k] = true
+			continue
+		}
+		seen[string(t.Type)+"/"+t.Name] = true
+		targets = append(targets, t)
+	}
+
+	d.errMtx.Lock()
+	d.errors = invalid
+	d.errMtx.Unlock()
+
+	select {
+	case up <- targets:
+	case <-ctx.Done():
+	}
+}
+
+// KeyErrors returns the keys currently holding an invalid target
+func (d *KVSD) KeyErrors() []string {
+	d.errMtx.RLock()
+	defer d.errMtx.RUnlock()
+
+	keys := make([]string, 0, len(d.errors))
+	for k := range d.errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseKVTarget parses a YAML/JSON target document, the name defaults to the key without the prefix
+func parseKVTarget(key string, prefix string, value []byte) (config.Target, error) {
+	t := config.Target{}
+	if err := yaml.Unmarshal(value, &t); err != nil {
+		return t, fmt.Errorf("parsing target: %s", err)
+	}
+	if t.Host == "" {
+		return t, fmt.Errorf("host must be set")
+	}
+	if t.Name == "" {
+		t.Name = strings.Trim(strings.TrimPrefix(key, prefix), "/:")
+	}
+	if t.Name == "" {
+		return t, fmt.Errorf("name must be set")
+	}
+	if t.Type == "" {
+		t.Type = defaultType
+	}
+	return t, nil
+}
@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+const etcdRequestTimeout = 30 * time.Second
+
+// etcdBackend watches a prefix with the etcd v3 JSON gRPC gateway
+type etcdBackend struct {
+	logger *slog.Logger
+	cfg    config.KVSD
+	client *http.Client
+}
+
+type etcdKV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader `json:"header"`
+	KVs    []etcdKV   `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Header          etcdHeader `json:"header"`
+		Created         bool       `json:"created"`
+		Canceled        bool       `json:"canceled"`
+		CompactRevision string     `json:"compact_revision"`
+		CancelReason    string     `json:"cancel_reason"`
+		Events          []struct {
+			Type string `json:"type"`
+			KV   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newEtcdBackend(logger *slog.Logger, cfg config.KVSD) *etcdBackend {
+	return &etcdBackend{logger: logger, cfg: cfg}
+}
+
+// watch takes a snapshot of the prefix on the first reachable endpoint and watches the following revisions
+func (b *etcdBackend) watch(ctx context.Context, snapshot func(map[string][]byte), event func(string, []byte)) error {
+	if b.client == nil {
+		// The watch is a long running request, no client timeout
+		client, err := NewHTTPClient(b.cfg.TLS, 0)
+		if err != nil {
+			return err
+		}
+		b.client = client
+	}
+
+	var err error
+	for _, endpoint := range b.cfg.Endpoints {
+		endpoint = strings.TrimSuffix(endpoint, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+
+		var token string
+		if token, err = b.authenticate(ctx, endpoint); err != nil {
+			b.logger.Debug("Authenticating", "type", "KVSD", "func", "watch", "endpoint", endpoint, "err", err)
+			continue
+		}
+
+		var rev int64
+		if rev, err = b.snapshot(ctx, endpoint, token, snapshot); err != nil {
+			b.logger.Debug("Reading prefix", "type", "KVSD", "func", "watch", "endpoint", endpoint, "err", err)
+			continue
+		}
+		return b.watchFrom(ctx, endpoint, token, rev+1, event)
+	}
+	return err
+}
+
+// authenticate returns a token when a username is configured
+func (b *etcdBackend) authenticate(ctx context.Context, endpoint string) (string, error) {
+	if b.cfg.Auth.Username == "" {
+		return "", nil
+	}
+	password, err := authPassword(b.cfg.Auth)
+	if err != nil {
+		return "", err
+	}
+
+	resp := struct {
+		Token string `json:"token"`
+	}{}
+	if err := b.post(ctx, endpoint+"/v3/auth/authenticate", "", map[string]string{"name": b.cfg.Auth.Username, "password": password}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// snapshot reads all the keys of the prefix and returns the revision of the read
+func (b *etcdBackend) snapshot(ctx context.Context, endpoint string, token string, snapshot func(map[string][]byte)) (int64, error) {
+	resp := etcdRangeResponse{}
+	if err := b.post(ctx, endpoint+"/v3/kv/range", token, b.keyRange(), &resp); err != nil {
+		return 0, err
+	}
+	rev, err := strconv.ParseInt(resp.Header.Revision, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid revision: %s", resp.Header.Revision)
+	}
+
+	kvs := make(map[string][]byte, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		key, _ := base64.StdEncoding.DecodeString(kv.Key)
+		value, _ := base64.StdEncoding.DecodeString(kv.Value)
+		kvs[string(key)] = value
+	}
+	snapshot(kvs)
+	return rev, nil
+}
+
+// watchFrom streams the changes of the prefix starting at a revision
+func (b *etcdBackend) watchFrom(ctx context.Context, endpoint string, token string, rev int64, event func(string, []byte)) error {
+	create := b.keyRange()
+	create["start_revision"] = strconv.FormatInt(rev, 10)
+	body, _ := json.Marshal(map[string]interface{}{"create_request": create})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/watch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(msg)))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		w := etcdWatchResponse{}
+		if err := dec.Decode(&w); err != nil {
+			return fmt.Errorf("reading watch stream: %s", err)
+		}
+		if w.Error != nil {
+			return fmt.Errorf("watch: %s", w.Error.Message)
+		}
+		if w.Result == nil {
+			continue
+		}
+		if w.Result.Canceled {
+			// Compacted revisions are recovered from a new snapshot
+			if w.Result.CompactRevision != "" && w.Result.CompactRevision != "0" {
+				return errKVResync
+			}
+			return fmt.Errorf("watch canceled: %s", w.Result.CancelReason)
+		}
+
+		for _, ev := range w.Result.Events {
+			key, _ := base64.StdEncoding.DecodeString(ev.KV.Key)
+			if ev.Type == "DELETE" {
+				event(string(key), nil)
+				continue
+			}
+			value, _ := base64.StdEncoding.DecodeString(ev.KV.Value)
+			event(string(key), value)
+		}
+	}
+}
+
+// keyRange request fields selecting all the keys of the prefix
+func (b *etcdBackend) keyRange() map[string]interface{} {
+	key := []byte(b.cfg.Prefix)
+	end := prefixEnd(key)
+	if len(key) == 0 {
+		key = []byte{0}
+	}
+	return map[string]interface{}{
+		"key":       base64.StdEncoding.EncodeToString(key),
+		"range_end": base64.StdEncoding.EncodeToString(end),
+	}
+}
+
+// post sends a unary gateway request
+func (b *etcdBackend) post(ctx context.Context, u string, token string, in interface{}, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(in)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// prefixEnd returns the range end matching all the keys starting with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Every key
+	return []byte{0}
+}
@@ -0,0 +1,277 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+const redisDialTimeout = 10 * time.Second
+
+// redisBackend watches a prefix with the Redis keyspace notifications
+// The server needs to have them enabled, for example: notify-keyspace-events K$gx
+type redisBackend struct {
+	logger *slog.Logger
+	cfg    config.KVSD
+}
+
+// redisConn minimal RESP2 client connection
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisBackend(logger *slog.Logger, cfg config.KVSD) *redisBackend {
+	return &redisBackend{logger: logger, cfg: cfg}
+}
+
+// watch subscribes to the notifications of the prefix, takes a snapshot and then re-reads every notified key
+func (b *redisBackend) watch(ctx context.Context, snapshot func(map[string][]byte), event func(string, []byte)) error {
+	var err error
+	for _, endpoint := range b.cfg.Endpoints {
+		var cmd, sub *redisConn
+		if cmd, err = b.dial(ctx, endpoint); err != nil {
+			b.logger.Debug("Connecting", "type", "KVSD", "func", "watch", "endpoint", endpoint, "err", err)
+			continue
+		}
+		if sub, err = b.dial(ctx, endpoint); err != nil {
+			cmd.close()
+			b.logger.Debug("Connecting", "type", "KVSD", "func", "watch", "endpoint", endpoint, "err", err)
+			continue
+		}
+		err = b.run(ctx, cmd, sub, snapshot, event)
+		cmd.close()
+		sub.close()
+		return err
+	}
+	return err
+}
+
+func (b *redisBackend) run(ctx context.Context, cmd *redisConn, sub *redisConn, snapshot func(map[string][]byte), event func(string, []byte)) error {
+	// Unblock the reads when the watch is canceled
+	stop := context.AfterFunc(ctx, func() {
+		cmd.conn.Close()
+		sub.conn.Close()
+	})
+	defer stop()
+
+	// Subscribe before the snapshot so that no change is missed
+	channel := fmt.Sprintf("__keyspace@%d__:", b.cfg.DB)
+	if _, err := sub.do("PSUBSCRIBE", channel+redisGlobEscape(b.cfg.Prefix)+"*"); err != nil {
+		return err
+	}
+
+	kvs, err := b.scan(cmd)
+	if err != nil {
+		return err
+	}
+	snapshot(kvs)
+
+	for {
+		msg, err := sub.read()
+		if err != nil {
+			return err
+		}
+		// pmessage <pattern> <channel> <event>
+		m, ok := msg.([]interface{})
+		if !ok || len(m) != 4 || redisString(m[0]) != "pmessage" {
+			continue
+		}
+		key := strings.TrimPrefix(redisString(m[2]), channel)
+
+		value, err := cmd.do("GET", key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			event(key, nil)
+			continue
+		}
+		event(key, []byte(redisString(value)))
+	}
+}
+
+// scan reads all the keys of the prefix
+func (b *redisBackend) scan(c *redisConn) (map[string][]byte, error) {
+	kvs := map[string][]byte{}
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", redisGlobEscape(b.cfg.Prefix)+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		r, ok := reply.([]interface{})
+		if !ok || len(r) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply")
+		}
+		keys, _ := r[1].([]interface{})
+		for _, k := range keys {
+			key := redisString(k)
+			value, err := c.do("GET", key)
+			if err != nil {
+				// Not a string value
+				b.logger.Debug("Reading key", "type", "KVSD", "func", "scan", "key", key, "err", err)
+				kvs[key] = []byte{}
+				continue
+			}
+			if value != nil {
+				kvs[key] = []byte(redisString(value))
+			}
+		}
+
+		if cursor = redisString(r[0]); cursor == "0" {
+			return kvs, nil
+		}
+	}
+}
+
+// dial connects, authenticates and selects the database, the endpoints are host:port, redis://host:port or rediss://host:port (TLS)
+func (b *redisBackend) dial(ctx context.Context, endpoint string) (*redisConn, error) {
+	useTLS := strings.HasPrefix(endpoint, "rediss://")
+	addr := strings.TrimPrefix(strings.TrimPrefix(endpoint, "rediss://"), "redis://")
+	addr = strings.TrimSuffix(addr, "/")
+
+	d := &net.Dialer{Timeout: redisDialTimeout, KeepAlive: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		tlsConfig, terr := NewTLSConfig(b.cfg.TLS)
+		if terr != nil {
+			return nil, terr
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		conn, err = (&tls.Dialer{NetDialer: d, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if b.cfg.Auth.Username != "" || b.cfg.Auth.Password != "" || b.cfg.Auth.PasswordFile != "" {
+		password, err := authPassword(b.cfg.Auth)
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		args := []string{"AUTH", password}
+		if b.cfg.Auth.Username != "" {
+			args = []string{"AUTH", b.cfg.Auth.Username, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.close()
+			return nil, fmt.Errorf("AUTH: %s", err)
+		}
+	}
+	if b.cfg.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(b.cfg.DB)); err != nil {
+			c.close()
+			return nil, fmt.Errorf("SELECT: %s", err)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) close() {
+	c.conn.Close()
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read parses a RESP2 reply, the error replies are returned as errors
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("%s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply: %q", line)
+}
+
+func redisString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	}
+	return ""
+}
+
+// redisGlobEscape escapes the glob characters of a prefix
+func redisGlobEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
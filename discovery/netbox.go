@@ -0,0 +1,306 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+const (
+	// Retries of the rate limited or failed NetBox requests
+	netboxRetries     = 5
+	netboxMinBackoff  = time.Second
+	netboxMaxBackoff  = time.Minute
+	netboxMetaPrefix  = "__meta_netbox_"
+	netboxDefaultName = "name"
+)
+
+// NetBoxSD discovers targets from the objects returned by the NetBox REST API
+type NetBoxSD struct {
+	health
+	logger *slog.Logger
+	cfg    config.NetBoxSD
+	rules  []relabelRule
+	client *http.Client
+	// Last successfully discovered label sets of each query
+	cache map[int][]map[string]string
+}
+
+// netboxPage paginated NetBox API response
+type netboxPage struct {
+	Next    *string                  `json:"next"`
+	Results []map[string]interface{} `json:"results"`
+}
+
+// NewNetBoxSD creates a new NetBox based discoverer
+func NewNetBoxSD(logger *slog.Logger, cfg config.NetBoxSD) *NetBoxSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &NetBoxSD{
+		logger: logger,
+		cfg:    cfg,
+		rules:  newRelabelRules(cfg.RelabelConfigs),
+		cache:  make(map[int][]map[string]string),
+	}
+}
+
+// Run queries NetBox on every refresh interval
+func (d *NetBoxSD) Run(ctx context.Context, up chan<- config.Targets) {
+	ticker := time.NewTicker(d.cfg.Refresh.Duration())
+	defer ticker.Stop()
+
+	for {
+		targets, err := d.refresh(ctx)
+		d.setHealth(err)
+		if err != nil {
+			// Keep the previously discovered targets while NetBox is unavailable
+			d.logger.Error("Querying NetBox", "type", "NetBoxSD", "func", "Run", "url", d.cfg.URL, "err", err)
+		} else {
+			select {
+			case up <- targets:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh runs every query, a failing query keeps its last results and only fails the refresh when all of them failed
+func (d *NetBoxSD) refresh(ctx context.Context) (config.Targets, error) {
+	if d.client == nil {
+		client, err := NewHTTPClient(d.cfg.TLS, 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		d.client = client
+	}
+
+	token := ""
+	if d.cfg.TokenFile != "" {
+		b, err := os.ReadFile(d.cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token file: %s", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	var lastErr error
+	failed := 0
+	for i, q := range d.cfg.Queries {
+		sets, err := d.query(ctx, token, q)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			d.logger.Warn("NetBox query failed, keeping its previous targets", "type", "NetBoxSD", "func", "refresh", "endpoint", q.Endpoint, "filter", q.Filter, "err", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		d.cache[i] = sets
+	}
+	if failed == len(d.cfg.Queries) {
+		return nil, lastErr
+	}
+
+	sets := []map[string]string{}
+	for i := range d.cfg.Queries {
+		sets = append(sets, d.cache[i]...)
+	}
+	return labelSetsToTargets(relabelSets(sets, d.rules), d.cfg.Types), nil
+}
+
+// query pages through the objects of an endpoint
+func (d *NetBoxSD) query(ctx context.Context, token string, q config.NetBoxQuery) ([]map[string]string, error) {
+	params, _ := url.ParseQuery(q.Filter)
+	params.Set("limit", strconv.Itoa(d.cfg.PageSize))
+	next := strings.TrimSuffix(d.cfg.URL, "/") + "/api/" + strings.Trim(q.Endpoint, "/") + "/?" + params.Encode()
+
+	sets := []map[string]string{}
+	for next != "" {
+		page := netboxPage{}
+		if err := d.get(ctx, token, next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Results {
+			set, err := netboxLabels(obj, q)
+			if err != nil {
+				d.logger.Warn("Skipping NetBox object", "type", "NetBoxSD", "func", "query", "endpoint", q.Endpoint, "id", fmt.Sprint(obj["id"]), "err", err)
+				continue
+			}
+			sets = append(sets, set)
+		}
+
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+	return sets, nil
+}
+
+// get decodes a NetBox API response, retrying with backoff when rate limited or unavailable
+func (d *NetBoxSD) get(ctx context.Context, token string, u string, v interface{}) error {
+	backoff := netboxMinBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Token "+token)
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(v)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("parsing response: %s", err)
+			}
+			return nil
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		err = fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(body)))
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retry || attempt >= netboxRetries {
+			return err
+		}
+
+		wait := backoff
+		if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s > 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		d.logger.Debug("Retrying NetBox request", "type", "NetBoxSD", "func", "get", "url", u, "wait", wait, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > netboxMaxBackoff {
+			backoff = netboxMaxBackoff
+		}
+	}
+}
+
+// netboxLabels meta labels of a NetBox object
+func netboxLabels(obj map[string]interface{}, q config.NetBoxQuery) (map[string]string, error) {
+	address := netboxAddress(netboxField(obj, q.AddressField))
+	if address == "" {
+		return nil, fmt.Errorf("no address in field %s", q.AddressField)
+	}
+
+	nameField := q.NameField
+	if nameField == "" {
+		nameField = netboxDefaultName
+	}
+	name := netboxString(netboxField(obj, nameField))
+	if name == "" {
+		name = netboxString(obj["display"])
+	}
+
+	set := map[string]string{
+		labelAddress:                  address,
+		netboxMetaPrefix + "endpoint": q.Endpoint,
+		netboxMetaPrefix + "id":       netboxString(obj["id"]),
+		netboxMetaPrefix + "name":     name,
+		netboxMetaPrefix + "url":      netboxString(obj["url"]),
+	}
+	if name != "" {
+		set[labelName] = name
+	}
+	for _, f := range []string{"site", "role", "device_role", "tenant", "status", "platform", "provider"} {
+		if v := netboxString(netboxField(obj, f)); v != "" {
+			set[netboxMetaPrefix+f] = v
+		}
+	}
+
+	if cf, ok := obj["custom_fields"].(map[string]interface{}); ok {
+		for k, v := range cf {
+			if s := netboxString(v); s != "" {
+				set[netboxMetaPrefix+"custom_field_"+sanitizeLabel(k)] = s
+			}
+		}
+	}
+	// The selected custom fields are exported as target labels
+	for _, f := range q.CustomFields {
+		if v, ok := set[netboxMetaPrefix+"custom_field_"+sanitizeLabel(f)]; ok {
+			set[sanitizeLabel(f)] = v
+		}
+	}
+	return set, nil
+}
+
+// netboxField resolves a dotted field path of an object
+func netboxField(obj map[string]interface{}, path string) interface{} {
+	var v interface{} = obj
+	for _, p := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[p]
+	}
+	return v
+}
+
+// netboxAddress extracts the host of an IP address object or an address string (CIDR notation)
+func netboxAddress(v interface{}) string {
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m["address"]
+	}
+	s := netboxString(v)
+	if ip, _, err := net.ParseCIDR(s); err == nil {
+		return ip.String()
+	}
+	return s
+}
+
+// netboxString converts a field value into a label value, nested objects use their slug or name
+func netboxString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	case map[string]interface{}:
+		for _, k := range []string{"slug", "value", "name", "display"} {
+			if s, ok := t[k].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
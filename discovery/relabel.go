@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+// relabelRule compiled relabel configuration
+type relabelRule struct {
+	config.RelabelConfig
+	regex *regexp.Regexp
+}
+
+// newRelabelRules compiles the relabeling rules, the expressions are validated when loading the config
+func newRelabelRules(cfgs []config.RelabelConfig) []relabelRule {
+	rules := make([]relabelRule, 0, len(cfgs))
+	for _, c := range cfgs {
+		rules = append(rules, relabelRule{RelabelConfig: c, regex: regexp.MustCompile("^(?:" + c.Regex + ")$")})
+	}
+	return rules
+}
+
+// relabel applies the rules in order to a copy of the label set, it returns false when the target is dropped
+func relabel(set map[string]string, rules []relabelRule) (map[string]string, bool) {
+	if len(rules) == 0 {
+		return set, true
+	}
+
+	out := make(map[string]string, len(set))
+	for k, v := range set {
+		out[k] = v
+	}
+
+	for _, r := range rules {
+		values := make([]string, 0, len(r.SourceLabels))
+		for _, l := range r.SourceLabels {
+			values = append(values, out[l])
+		}
+		value := strings.Join(values, r.Separator)
+
+		switch r.Action {
+		case "keep":
+			if !r.regex.MatchString(value) {
+				return nil, false
+			}
+		case "drop":
+			if r.regex.MatchString(value) {
+				return nil, false
+			}
+		case "labelmap":
+			// Evaluated against the labels present before the rule
+			mapped := map[string]string{}
+			for k, v := range out {
+				if match := r.regex.FindStringSubmatchIndex(k); match != nil {
+					mapped[string(r.regex.ExpandString(nil, r.Replacement, k, match))] = v
+				}
+			}
+			for k, v := range mapped {
+				out[k] = v
+			}
+		default:
+			match := r.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			res := string(r.regex.ExpandString(nil, r.Replacement, value, match))
+			if res == "" {
+				delete(out, r.TargetLabel)
+				continue
+			}
+			out[r.TargetLabel] = res
+		}
+	}
+	return out, true
+}
+
+// relabelSets relabels the label sets and removes the dropped ones
+func relabelSets(sets []map[string]string, rules []relabelRule) []map[string]string {
+	if len(rules) == 0 {
+		return sets
+	}
+
+	out := make([]map[string]string, 0, len(sets))
+	for _, set := range sets {
+		if s, keep := relabel(set, rules); keep {
+			out = append(out, s)
+		}
+	}
+	return out
+}
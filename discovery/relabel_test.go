@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// loadRelabelConfigs loads the relabel_configs of a file_sd source through ReloadConfig, with its defaults and validation
+func loadRelabelConfigs(t *testing.T, rules string) ([]config.RelabelConfig, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "network_exporter.yml")
+	data := "discovery:\n  file_sd:\n    - files: [" + filepath.Join(t.TempDir(), "*.json") + "]\n      relabel_configs:\n" + rules
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc := &config.SafeConfig{Cfg: &config.Config{}}
+	if err := sc.ReloadConfig(testLogger, file, nil); err != nil {
+		return nil, err
+	}
+	return sc.Cfg.Discovery.FileSD[0].RelabelConfigs, nil
+}
+
+func TestRelabel(t *testing.T) {
+	set := map[string]string{labelAddress: "10.0.0.1", "env": "prod", "team": "net", "__meta_rack": "r1", "__meta_row": "b"}
+	tests := []struct {
+		name  string
+		rules string
+		keep  bool
+		want  map[string]string
+	}{
+		{
+			name:  "keep match",
+			rules: "        - source_labels: [env]\n          regex: prod\n          action: keep\n",
+			keep:  true,
+			want:  set,
+		},
+		{
+			name:  "keep no match",
+			rules: "        - source_labels: [env]\n          regex: dev\n          action: keep\n",
+		},
+		{
+			name:  "keep anchored",
+			rules: "        - source_labels: [env]\n          regex: pro\n          action: keep\n",
+		},
+		{
+			name:  "drop match",
+			rules: "        - source_labels: [env, team]\n          regex: prod;net\n          action: drop\n",
+		},
+		{
+			name:  "drop anchored",
+			rules: "        - source_labels: [env]\n          regex: rod\n          action: drop\n",
+			keep:  true,
+			want:  set,
+		},
+		{
+			name:  "replace defaults",
+			rules: "        - source_labels: [env, team]\n          target_label: group\n",
+			keep:  true,
+			want:  with(set, "group", "prod;net"),
+		},
+		{
+			name:  "replace separator",
+			rules: "        - source_labels: [env, team]\n          separator: \"-\"\n          target_label: group\n",
+			keep:  true,
+			want:  with(set, "group", "prod-net"),
+		},
+		{
+			name:  "replace groups",
+			rules: "        - source_labels: [__address]\n          regex: '(\\d+)\\.(\\d+)\\..*'\n          replacement: net-$1-$2\n          target_label: subnet\n",
+			keep:  true,
+			want:  with(set, "subnet", "net-10-0"),
+		},
+		{
+			name:  "replace anchored no match",
+			rules: "        - source_labels: [env]\n          regex: rod\n          target_label: stage\n",
+			keep:  true,
+			want:  set,
+		},
+		{
+			name:  "replace empty deletes",
+			rules: "        - source_labels: [missing]\n          target_label: env\n",
+			keep:  true,
+			want:  without(set, "env"),
+		},
+		{
+			name:  "missing source label",
+			rules: "        - source_labels: [missing, team]\n          target_label: group\n",
+			keep:  true,
+			want:  with(set, "group", ";net"),
+		},
+		{
+			name:  "missing source label keep",
+			rules: "        - source_labels: [missing]\n          regex: .+\n          action: keep\n",
+		},
+		{
+			name:  "labelmap",
+			rules: "        - regex: __meta_(.+)\n          action: labelmap\n",
+			keep:  true,
+			want:  with(with(set, "rack", "r1"), "row", "b"),
+		},
+		{
+			name:  "labelmap replacement",
+			rules: "        - regex: __meta_(r)(.+)\n          replacement: ${2}_$1\n          action: labelmap\n",
+			keep:  true,
+			want:  with(with(set, "ack_r", "r1"), "ow_r", "b"),
+		},
+		{
+			name:  "rules in order",
+			rules: "        - source_labels: [env]\n          target_label: stage\n        - source_labels: [stage]\n          regex: prod\n          action: drop\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgs, err := loadRelabelConfigs(t, tt.rules)
+			if err != nil {
+				t.Fatalf("ReloadConfig: %s", err)
+			}
+			got, keep := relabel(set, newRelabelRules(cfgs))
+			if keep != tt.keep {
+				t.Fatalf("keep %v, want %v", keep, tt.keep)
+			}
+			if keep && !maps.Equal(got, tt.want) {
+				t.Errorf("labels %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if set["group"] != "" || set["env"] != "prod" {
+		t.Errorf("relabel modified its input: %v", set)
+	}
+}
+
+func TestRelabelInvalidConfigs(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{name: "invalid regex", rules: "        - source_labels: [env]\n          regex: '(prod'\n          action: keep\n", err: "relabel_configs[0].regex"},
+		{name: "replace without target_label", rules: "        - source_labels: [env]\n", err: "relabel_configs[0].target_label must be set"},
+		{name: "unknown action", rules: "        - source_labels: [env]\n          action: hashmod\n", err: "relabel_configs[0].action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadRelabelConfigs(t, tt.rules)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ReloadConfig: %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRelabelSets(t *testing.T) {
+	cfgs, err := loadRelabelConfigs(t, "        - source_labels: [env]\n          regex: dev\n          action: drop\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := []map[string]string{{"env": "prod"}, {"env": "dev"}, {}}
+	got := relabelSets(sets, newRelabelRules(cfgs))
+	if len(got) != 2 || got[0]["env"] != "prod" || len(got[1]) != 0 {
+		t.Errorf("relabelSets: %v, want the prod and unlabeled sets", got)
+	}
+	if got := relabelSets(sets, nil); len(got) != len(sets) {
+		t.Errorf("relabelSets without rules: %v, want %v", got, sets)
+	}
+}
+
+// with returns a copy of set with the label added
+func with(set map[string]string, name string, value string) map[string]string {
+	out := maps.Clone(set)
+	out[name] = value
+	return out
+}
+
+// without returns a copy of set without the label
+func without(set map[string]string, name string) map[string]string {
+	out := maps.Clone(set)
+	delete(out, name)
+	return out
+}
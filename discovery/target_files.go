@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// targetFilesSource source identifier of the targets of conf target_files
+	targetFilesSource = "target_files"
+	// targetFilesRefresh interval re-reading the target files in addition to the file watcher
+	targetFilesRefresh = 5 * time.Minute
+)
+
+// NewTargetFilesSD creates a file based discoverer of the files holding a targets list in the config file format
+func NewTargetFilesSD(logger *slog.Logger, patterns []string) *FileSD {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &FileSD{
+		logger:   logger,
+		patterns: patterns,
+		interval: targetFilesRefresh,
+		read:     readTargetsList,
+		cache:    make(map[string]config.Targets),
+	}
+}
+
+// readTargetsList parses the targets list of a JSON or YAML file, the targets must have a name, host and type
+// An empty file is rejected as it is usually being rewritten, an empty `targets: []` list removes the targets of the file
+func readTargetsList(file string) (config.Targets, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	var list struct {
+		Targets config.Targets `yaml:"targets" json:"targets"`
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, &list)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &list)
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing targets: %s", err)
+	}
+
+	for i, t := range list.Targets {
+		if t.Name == "" || t.Host == "" || t.Type == "" {
+			return nil, fmt.Errorf("targets[%d]: name, host and type are required", i)
+		}
+	}
+	return list.Targets, nil
+}
@@ -0,0 +1,344 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/syepes/network_exporter/pkg/dns"
+	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/pkg/ntp"
+	"github.com/syepes/network_exporter/pkg/ping"
+	"github.com/syepes/network_exporter/pkg/smtp"
+	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/pkg/udp"
+	"github.com/syepes/network_exporter/results"
+
+	pkghttp "github.com/syepes/network_exporter/pkg/http"
+)
+
+// Resolutions of the history points
+const (
+	ResolutionRaw = "raw"
+	Resolution1m  = "1m"
+	Resolution10m = "10m"
+)
+
+// Retention of each tier, the points leaving a tier are aggregated into the next one
+const (
+	rawRetention = 15 * time.Minute
+	retention1m  = 3 * time.Hour
+	retention10m = 24 * time.Hour
+	// The history of the targets without results during the longest retention is forgotten
+	expiry = retention10m
+	// Smallest budget per target, keeps a few points in every tier
+	minBytesPerTarget = 1024
+)
+
+// Point history entry, the raw points hold a single result and the aggregated points every result of their period
+type Point struct {
+	Time       time.Time `json:"time"`
+	Resolution string    `json:"resolution"`
+	Count      int       `json:"count"`
+	// SuccessRatio successful probes ratio (0-1)
+	SuccessRatio float64 `json:"success_ratio"`
+	// Latency of the successful probes: avg rtt (ICMP), last hop avg rtt (MTR), connection time (TCP), total time (HTTPGet), query time (DNS), reply rtt (UDP), round trip delay (NTP) or banner time (SMTP)
+	LatencyAvg float64 `json:"latency_avg_seconds"`
+	LatencyMin float64 `json:"latency_min_seconds"`
+	LatencyMax float64 `json:"latency_max_seconds"`
+	// Loss average packet loss ratio (0-1), the failed TCP, HTTPGet, DNS, UDP, NTP and SMTP probes count as lost
+	Loss float64 `json:"loss"`
+}
+
+// Series history of a monitored target
+type Series struct {
+	Type   string  `json:"type"`
+	Name   string  `json:"name"`
+	IP     string  `json:"ip,omitempty"`
+	Points []Point `json:"points"`
+}
+
+// point compact history entry, kept small as it is the unit of the byte budget
+type point struct {
+	time    int64
+	count   uint32
+	success uint32
+	latMin  float32
+	latMax  float32
+	latSum  float32
+	lossSum float32
+}
+
+const pointSize = int(unsafe.Sizeof(point{}))
+
+// ring fixed capacity ring of points ordered by time
+type ring struct {
+	points []point
+	start  int
+	size   int
+}
+
+func newRing(capacity int) ring {
+	return ring{points: make([]point, capacity)}
+}
+
+func (r *ring) len() int { return r.size }
+
+// at returns the i-th oldest point
+func (r *ring) at(i int) *point { return &r.points[(r.start+i)%len(r.points)] }
+
+func (r *ring) newest() *point { return r.at(r.size - 1) }
+
+// push appends a point, returning the evicted oldest one when full
+func (r *ring) push(p point) (point, bool) {
+	if r.size < len(r.points) {
+		r.points[(r.start+r.size)%len(r.points)] = p
+		r.size++
+		return point{}, false
+	}
+	old := r.points[r.start]
+	r.points[r.start] = p
+	r.start = (r.start + 1) % len(r.points)
+	return old, true
+}
+
+// pop removes the oldest point
+func (r *ring) pop() point {
+	p := r.points[r.start]
+	r.start = (r.start + 1) % len(r.points)
+	r.size--
+	return p
+}
+
+// series tiered history of a target
+type series struct {
+	checkType string
+	name      string
+	ip        string
+	raw       ring
+	min1      ring
+	min10     ring
+	lastSeen  time.Time
+}
+
+// Store keeps the history of every target within a fixed byte budget per target
+type Store struct {
+	logger   *slog.Logger
+	hub      *results.Hub
+	capacity [3]int
+	series   map[string]*series
+	mtx      sync.Mutex
+}
+
+// NewStore creates the history of the probe results, bytesPerTarget bounds the memory of each target
+// A third of the budget holds the aggregated points (3h of 1m and 24h of 10m at most), the rest the raw points of the last 15 minutes
+func NewStore(logger *slog.Logger, hub *results.Hub, bytesPerTarget int) *Store {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if bytesPerTarget < minBytesPerTarget {
+		bytesPerTarget = minBytesPerTarget
+	}
+
+	n := bytesPerTarget / pointSize
+	c10 := min(int(retention10m/(10*time.Minute)), n/8)
+	c1 := min(int(retention1m/time.Minute), n/4)
+	return &Store{
+		logger:   logger,
+		hub:      hub,
+		capacity: [3]int{n - c1 - c10, c1, c10},
+		series:   make(map[string]*series),
+	}
+}
+
+// Run records the published results until the context is canceled
+func (s *Store) Run(ctx context.Context) {
+	sub := s.hub.Subscribe(results.DefaultBuffer)
+	defer sub.Close()
+
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-sub.C:
+			s.observe(r)
+		case <-ticker.C:
+			s.prune()
+		}
+	}
+}
+
+// Series returns the history of the targets of a check type and name (one per resolved IP), the oldest points first
+func (s *Store) Series(checkType string, name string) []Series {
+	now := time.Now()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := []Series{}
+	for _, h := range s.series {
+		if h.name != name || h.checkType != checkType {
+			continue
+		}
+		h.compact(now)
+
+		points := make([]Point, 0, h.min10.len()+h.min1.len()+h.raw.len())
+		for _, t := range []struct {
+			r          *ring
+			resolution string
+		}{{&h.min10, Resolution10m}, {&h.min1, Resolution1m}, {&h.raw, ResolutionRaw}} {
+			for i := 0; i < t.r.len(); i++ {
+				points = append(points, t.r.at(i).export(t.resolution))
+			}
+		}
+		out = append(out, Series{Type: h.checkType, Name: h.name, IP: h.ip, Points: points})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
+}
+
+// observe appends a result to the history of its target
+func (s *Store) observe(r results.Result) {
+	key := r.Type + "/" + r.Name + "/" + r.IP
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	h, ok := s.series[key]
+	if !ok {
+		h = &series{
+			checkType: r.Type,
+			name:      r.Name,
+			ip:        r.IP,
+			raw:       newRing(s.capacity[0]),
+			min1:      newRing(s.capacity[1]),
+			min10:     newRing(s.capacity[2]),
+		}
+		s.series[key] = h
+	}
+	h.lastSeen = time.Now()
+
+	if old, evicted := h.raw.push(newPoint(r)); evicted {
+		h.fold(&h.min1, time.Minute, old)
+	}
+	h.compact(r.Time)
+}
+
+// prune forgets the history of the removed targets
+func (s *Store) prune() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for key, h := range s.series {
+		if time.Since(h.lastSeen) > expiry {
+			delete(s.series, key)
+		}
+	}
+}
+
+// compact moves the points older than the retention of their tier into the next one
+func (h *series) compact(now time.Time) {
+	for h.raw.len() > 0 && now.Sub(time.Unix(0, h.raw.at(0).time)) > rawRetention {
+		h.fold(&h.min1, time.Minute, h.raw.pop())
+	}
+	for h.min1.len() > 0 && now.Sub(time.Unix(0, h.min1.at(0).time)) > retention1m {
+		h.fold(&h.min10, 10*time.Minute, h.min1.pop())
+	}
+	for h.min10.len() > 0 && now.Sub(time.Unix(0, h.min10.at(0).time)) > retention10m {
+		h.min10.pop()
+	}
+}
+
+// fold aggregates a point into the bucket of its period, the buckets evicted from the 1m tier are aggregated into the 10m tier
+func (h *series) fold(r *ring, period time.Duration, p point) {
+	p.time = time.Unix(0, p.time).Truncate(period).UnixNano()
+	if r.len() > 0 && r.newest().time == p.time {
+		r.newest().merge(p)
+		return
+	}
+	old, evicted := r.push(p)
+	if evicted && r == &h.min1 {
+		h.fold(&h.min10, 10*time.Minute, old)
+	}
+}
+
+// newPoint returns the raw point of a result
+func newPoint(r results.Result) point {
+	p := point{time: r.Time.UnixNano(), count: 1}
+
+	var latency time.Duration
+	loss := 1.0
+	switch d := r.Data.(type) {
+	case *ping.PingResult:
+		latency, loss = d.AvgTime, d.DropRate
+	case *mtr.MtrResult:
+		if len(d.Hops) > 0 {
+			latency, loss = d.Hops[len(d.Hops)-1].AvgTime, d.Hops[len(d.Hops)-1].Loss
+		}
+	case *tcp.TCPPortReturn:
+		latency = d.ConTime
+	case *pkghttp.HTTPReturn:
+		latency = d.Total
+	case *dns.DNSReturn:
+		latency = d.QueryTime
+	case *udp.UDPPortReturn:
+		latency = d.RTT
+	case *ntp.NTPReturn:
+		latency = d.RTT
+	case *smtp.SMTPReturn:
+		latency = d.BannerTime
+	}
+	if r.Success {
+		p.success = 1
+		p.latMin = float32(latency.Seconds())
+		p.latMax = p.latMin
+		p.latSum = p.latMin
+		switch r.Data.(type) {
+		case *tcp.TCPPortReturn, *pkghttp.HTTPReturn, *dns.DNSReturn, *udp.UDPPortReturn, *ntp.NTPReturn, *smtp.SMTPReturn:
+			loss = 0
+		}
+	}
+	p.lossSum = float32(loss)
+	return p
+}
+
+// merge aggregates another point of the same period
+func (p *point) merge(o point) {
+	if o.success > 0 {
+		if p.success == 0 || o.latMin < p.latMin {
+			p.latMin = o.latMin
+		}
+		if o.latMax > p.latMax {
+			p.latMax = o.latMax
+		}
+	}
+	p.count += o.count
+	p.success += o.success
+	p.latSum += o.latSum
+	p.lossSum += o.lossSum
+}
+
+// export returns the public point
+func (p *point) export(resolution string) Point {
+	e := Point{
+		Time:         time.Unix(0, p.time).UTC(),
+		Resolution:   resolution,
+		Count:        int(p.count),
+		SuccessRatio: float64(p.success) / float64(p.count),
+		Loss:         float64(p.lossSum) / float64(p.count),
+	}
+	if p.success > 0 {
+		e.LatencyAvg = float64(p.latSum) / float64(p.success)
+		e.LatencyMin = float64(p.latMin)
+		e.LatencyMax = float64(p.latMax)
+	}
+	return e
+}
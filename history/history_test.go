@@ -0,0 +1,138 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/results"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// feed observes a TCP result of every target on every interval from start to now, every tenth one failed
+func feed(s *Store, targets int, start time.Time, interval time.Duration) (sent int) {
+	now := time.Now()
+	for at := start; at.Before(now); at = at.Add(interval) {
+		for i := 0; i < targets; i++ {
+			success := sent%10 != 0
+			s.observe(results.Result{Type: "TCP", Name: fmt.Sprintf("t%d", i), IP: "127.0.0.1", Time: at, Success: success, Data: &tcp.TCPPortReturn{ConTime: 5 * time.Millisecond}})
+		}
+		sent++
+	}
+	return sent
+}
+
+// checkSeries checks the tiers of a series are ordered oldest first, with increasing times and the resolution of their tier
+func checkSeries(t *testing.T, s Series) {
+	t.Helper()
+	rank := map[string]int{Resolution10m: 0, Resolution1m: 1, ResolutionRaw: 2}
+	for i := 1; i < len(s.Points); i++ {
+		prev, p := s.Points[i-1], s.Points[i]
+		if rank[p.Resolution] < rank[prev.Resolution] {
+			t.Fatalf("%s: %s point after a %s one", s.Name, p.Resolution, prev.Resolution)
+		}
+		if !p.Time.After(prev.Time) {
+			t.Fatalf("%s: point %d at %s not after %s", s.Name, i, p.Time, prev.Time)
+		}
+	}
+	for _, p := range s.Points {
+		switch p.Resolution {
+		case Resolution1m:
+			if !p.Time.Equal(p.Time.Truncate(time.Minute)) {
+				t.Fatalf("%s: 1m point at %s", s.Name, p.Time)
+			}
+		case Resolution10m:
+			if !p.Time.Equal(p.Time.Truncate(10 * time.Minute)) {
+				t.Fatalf("%s: 10m point at %s", s.Name, p.Time)
+			}
+		}
+	}
+}
+
+func TestStoreSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const targets = 20
+	s := NewStore(testLogger, results.NewHub(), 64*1024)
+	start := time.Now().Add(-26 * time.Hour)
+	sent := feed(s, targets, start, 10*time.Second)
+
+	// 24h of 10s probes with the 64KB budget: nothing is evicted before its retention ends
+	kept := int((retention10m - 10*time.Minute) / (10 * time.Second))
+	for i := 0; i < targets; i++ {
+		series := s.Series("TCP", fmt.Sprintf("t%d", i))
+		if len(series) != 1 {
+			t.Fatalf("t%d: %d series, want 1", i, len(series))
+		}
+		checkSeries(t, series[0])
+
+		count := 0
+		successes := 0.0
+		resolutions := map[string]int{}
+		for _, p := range series[0].Points {
+			count += p.Count
+			successes += p.SuccessRatio * float64(p.Count)
+			resolutions[p.Resolution]++
+			// The failed TCP probes are the lost ones
+			if d := p.SuccessRatio + p.Loss - 1; d < -1e-6 || d > 1e-6 {
+				t.Fatalf("t%d: point %+v, want the loss of the failed probes", i, p)
+			}
+		}
+		if ratio := successes / float64(count); ratio < 0.89 || ratio > 0.91 {
+			t.Errorf("t%d: success ratio %f, want 0.9", i, ratio)
+		}
+		if count < kept || count > sent {
+			t.Errorf("t%d: %d results in the history, want between %d and %d", i, count, kept, sent)
+		}
+		for _, r := range []string{ResolutionRaw, Resolution1m, Resolution10m} {
+			if resolutions[r] == 0 {
+				t.Errorf("t%d: no %s points", i, r)
+			}
+		}
+		if n := resolutions[Resolution10m]; n > int(retention10m/(10*time.Minute))+1 {
+			t.Errorf("t%d: %d 10m points, more than 24h", i, n)
+		}
+	}
+}
+
+func TestStoreBudget(t *testing.T) {
+	for _, budget := range []int{0, minBytesPerTarget, 4 * 1024, 16 * 1024} {
+		t.Run(fmt.Sprint(budget), func(t *testing.T) {
+			s := NewStore(testLogger, results.NewHub(), budget)
+			feed(s, 3, time.Now().Add(-26*time.Hour), time.Minute)
+
+			points := max(budget, minBytesPerTarget) / pointSize
+			for i := 0; i < 3; i++ {
+				series := s.Series("TCP", fmt.Sprintf("t%d", i))
+				if len(series) != 1 {
+					t.Fatalf("t%d: %d series, want 1", i, len(series))
+				}
+				checkSeries(t, series[0])
+				if n := len(series[0].Points); n == 0 || n > points {
+					t.Errorf("t%d: %d points, want at most %d", i, n, points)
+				}
+			}
+		})
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	s := NewStore(testLogger, results.NewHub(), 0)
+	feed(s, 2, time.Now().Add(-time.Minute), 10*time.Second)
+	s.mtx.Lock()
+	s.series["TCP/t0/127.0.0.1"].lastSeen = time.Now().Add(-expiry - time.Minute)
+	s.mtx.Unlock()
+
+	s.prune()
+	if series := s.Series("TCP", "t0"); len(series) != 0 {
+		t.Errorf("expired target kept: %v", series)
+	}
+	if series := s.Series("TCP", "t1"); len(series) != 1 {
+		t.Errorf("active target pruned")
+	}
+}
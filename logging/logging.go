@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/prometheus/common/promslog"
+)
+
+// ComponentKey attribute identifying the component of a log record (ICMP, Config, API...)
+const ComponentKey = "type"
+
+// Formats allowed log formats, text is an alias of logfmt
+var Formats = []string{"logfmt", "json", "text"}
+
+// Levels default log level and the per-component overrides
+type Levels struct {
+	Default    slog.Level
+	Components map[string]slog.Level
+}
+
+// ParseLevels parses a level list (info,icmp=debug,config=warn), the default level is info when only overrides are given
+// The components match the type attribute of the records case-insensitively
+func ParseLevels(s string) (Levels, error) {
+	levels := Levels{Default: slog.LevelInfo, Components: map[string]slog.Level{}}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		component, value, override := strings.Cut(item, "=")
+		if !override {
+			value = component
+		}
+		l := promslog.NewLevel()
+		if err := l.Set(value); err != nil {
+			return levels, fmt.Errorf("%s, allowed (%s)", err, strings.Join(promslog.LevelFlagOptions, "|"))
+		}
+
+		if override {
+			component = strings.ToLower(strings.TrimSpace(component))
+			if component == "" {
+				return levels, fmt.Errorf("missing component name in %q", item)
+			}
+			levels.Components[component] = l.Level()
+		} else {
+			levels.Default = l.Level()
+		}
+	}
+	return levels, nil
+}
+
+// New creates the logger of the given format, the records are filtered by the level of their component
+func New(levels Levels, format string) (*slog.Logger, error) {
+	if format == "text" {
+		format = "logfmt"
+	}
+	f := promslog.NewFormat()
+	if err := f.Set(format); err != nil {
+		return nil, err
+	}
+
+	// The base handler accepts the lowest configured level, the component levels are applied by the wrapper
+	min := levels.Default
+	for _, l := range levels.Components {
+		if l < min {
+			min = l
+		}
+	}
+	l := promslog.NewLevel()
+	if err := l.Set(strings.ToLower(min.String())); err != nil {
+		return nil, err
+	}
+
+	base := promslog.New(&promslog.Config{Level: l, Format: f})
+	return slog.New(&componentHandler{next: base.Handler(), levels: levels, min: min}), nil
+}
+
+// componentHandler filters the records with the level of the component set by the type attribute
+type componentHandler struct {
+	next      slog.Handler
+	levels    Levels
+	min       slog.Level
+	component string
+}
+
+// Enabled reports if a record could be logged, the component is only known before Handle when set with With
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.component != "" {
+		return level >= h.level(h.component)
+	}
+	return level >= h.min && h.next.Enabled(ctx, level)
+}
+
+// Handle logs the record if its level reaches the level of its component
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	component := h.component
+	if component == "" {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == ComponentKey {
+				component = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if r.Level < h.level(component) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with the attributes, the type attribute sets the component
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key == ComponentKey {
+			c.component = a.Value.String()
+		}
+	}
+	return &c
+}
+
+// WithGroup returns a handler with the group
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.next = h.next.WithGroup(name)
+	return &c
+}
+
+// level returns the level of a component
+func (h *componentHandler) level(component string) slog.Level {
+	if l, ok := h.levels.Components[strings.ToLower(component)]; ok {
+		return l
+	}
+	return h.levels.Default
+}
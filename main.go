@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"expvar"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -17,13 +21,21 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/prometheus/common/promslog"
-	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/syepes/network_exporter/api"
 	"github.com/syepes/network_exporter/collector"
 	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/convert"
+	"github.com/syepes/network_exporter/discovery"
+	"github.com/syepes/network_exporter/history"
+	"github.com/syepes/network_exporter/logging"
 	"github.com/syepes/network_exporter/monitor"
+	"github.com/syepes/network_exporter/notify"
 	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/icmp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+	"github.com/syepes/network_exporter/target"
 )
 
 const version string = "1.8.0"
@@ -36,6 +48,18 @@ var (
 	WebConfigFile      = kingpin.Flag("web.config.file", "Path to the web configuration file").Default("").String()
 	configFile         = kingpin.Flag("config.file", "Exporter configuration file").Default("/app/cfg/network_exporter.yml").String()
 	configFileHeaders  = HTTPHeader(kingpin.Flag("config.file.header", "Headers for loading configuration file from URL"))
+	configFileTimeout  = kingpin.Flag("config.file.timeout", "Timeout of the configuration file download from URL").Default("30s").Duration()
+	configFileCA       = kingpin.Flag("config.file.ca-file", "CA bundle verifying the server of the configuration file URL").Default("").String()
+	configFileUser     = kingpin.Flag("config.file.username", "Basic auth username of the configuration file URL").Default("").String()
+	configFilePassword = kingpin.Flag("config.file.password", "Basic auth password of the configuration file URL").Envar("NETWORK_EXPORTER_CONFIG_PASSWORD").Default("").String()
+	configFilePassFile = kingpin.Flag("config.file.password-file", "File holding the basic auth password of the configuration file URL").Default("").String()
+	configFileToken    = kingpin.Flag("config.file.bearer-token", "Bearer token of the configuration file URL").Envar("NETWORK_EXPORTER_CONFIG_BEARER_TOKEN").Default("").String()
+	configFileTokFile  = kingpin.Flag("config.file.bearer-token-file", "File holding the bearer token of the configuration file URL").Default("").String()
+	configExpandEnv    = kingpin.Flag("config.expand-env", "Expand the ${VAR} and $VAR environment variable references of the configuration file ($$ is a literal $)").Default("false").Bool()
+	configUndefinedEnv = kingpin.Flag("config.expand-env.undefined", "Undefined environment variables fail the config reload (error) or expand to an empty string (empty)").Default("error").Enum("error", "empty")
+	configStrict       = kingpin.Flag("config.strict", "Fail the config reload on the unknown fields of the configuration file, and the bodies of the methods without body, instead of logging or sending them").Default("false").Bool()
+	probeName          = kingpin.Flag("probe.name", "Name matched against the probe lists of the targets (default: hostname)").Default("").String()
+	enableLifecycle    = kingpin.Flag("web.enable-lifecycle", "Enable the config reload via HTTP POST /-/reload").Default("false").Bool()
 	enableProfileing   = kingpin.Flag("profiling", "Enable Profiling (pprof + fgprof)").Default("false").Bool()
 	// SCALING: maxConcurrentJobs controls how many probe operations can run concurrently per target.
 	// Higher values increase throughput but consume more resources (memory, CPU, file descriptors).
@@ -45,6 +69,17 @@ var (
 	//   - Medium deployments (100-1000 targets): 2-3
 	//   - Large deployments (>1000 targets): 1-2
 	maxConcurrentJobs = kingpin.Flag("max-concurrent-jobs", "Maximum concurrent probe operations per target (affects memory and CPU usage)").Default("3").Int()
+	grpcListenAddress = kingpin.Flag("grpc.listen-address", "The address to listen on for the gRPC admin API (disabled when empty)").Default("").String()
+	grpcTLSCertFile   = kingpin.Flag("grpc.tls-cert-file", "TLS certificate of the gRPC admin API").Default("").String()
+	grpcTLSKeyFile    = kingpin.Flag("grpc.tls-key-file", "TLS key of the gRPC admin API").Default("").String()
+	grpcTLSClientCA   = kingpin.Flag("grpc.tls-client-ca-file", "CA verifying the client certificates of the gRPC admin API (enables mTLS)").Default("").String()
+	grpcWatchBuffer   = kingpin.Flag("grpc.watch-buffer", "Results buffered per WatchResults stream before they are dropped").Default("256").Int()
+	checkConfig       = kingpin.Flag("check-config", "Validate the configuration file, print the accepted and skipped targets and exit (1 when invalid)").Default("false").Bool()
+	checkConfigFormat = kingpin.Flag("check-config.format", "Output format of --check-config").Default("text").Enum("text", "json")
+	convertBlackbox   = kingpin.Flag("convert.blackbox", "Print the network_exporter config equivalent to the modules of a blackbox_exporter config file and exit").Default("").String()
+	logLevel          = kingpin.Flag("log.level", "Only log messages with the given severity or above, one of (debug|info|warn|error) optionally followed by per component levels (info,icmp=debug,config=warn)").Default("info").String()
+	logFormat         = kingpin.Flag("log.format", "Output format of the log messages").Default("logfmt").Enum(logging.Formats...)
+	historyBytes      = kingpin.Flag("history.bytes-per-target", "Memory budget of the result history of each target served by /api/v1/history (disabled when 0)").Default("32768").Int()
 	sc                = &config.SafeConfig{Cfg: &config.Config{}}
 	logger            *slog.Logger
 	// SCALING: icmpID is a shared counter across all PING and MTR targets (see pkg/common/type.go for limits)
@@ -53,6 +88,29 @@ var (
 	monitorMTR     *monitor.MTR
 	monitorTCP     *monitor.TCPPort
 	monitorHTTPGet *monitor.HTTPGet
+	monitorDNS     *monitor.DNS
+	monitorUDP     *monitor.UDPPort
+	monitorNTP     *monitor.NTP
+	monitorSMTP    *monitor.SMTP
+	// discoveryManager merges the dynamically discovered targets into the active configuration
+	discoveryManager *discovery.Manager
+	// resultsHub fans out the probe results to the admin API watchers
+	resultsHub *results.Hub
+	// resultHistory keeps the downsampled results of every target, nil when disabled
+	resultHistory *history.Store
+	// apiService manages the runtime targets and exposes the target inventory
+	apiService *api.Service
+	// notifier posts the target state changes to the webhook
+	notifier *notify.Webhook
+	// schedulers run the probes of each check type with conf.scheduler pool, empty with the goroutine per target scheduler
+	schedulers map[string]*scheduler.Scheduler
+	// resolution retries the targets that could not be resolved
+	resolution *monitor.Resolution
+	// resolver resolves the target hosts with conf.nameserver
+	resolver    *config.Resolver
+	reloadMutex sync.Mutex
+	// configMutex serializes the config reloads (signal, refresh, /-/reload and resolution retries)
+	configMutex sync.Mutex
 
 	indexHTML = `<!doctype html><html><head> <meta charset="UTF-8"><title>Network Exporter (Version ` + version + `)</title></head><body><h1>Network Exporter</h1><p><a href="%s">Metrics</a></p></body></html>`
 )
@@ -78,45 +136,245 @@ func HTTPHeader(s kingpin.Settings) (target *http.Header) {
 }
 
 func init() {
-	promslogConfig := &promslog.Config{}
-	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.Version(version)
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
-	logger = promslog.New(promslogConfig)
+
+	levels, err := logging.ParseLevels(*logLevel)
+	if err == nil {
+		logger, err = logging.New(levels, *logFormat)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuring logging: %s\n", err)
+		os.Exit(1)
+	}
 	icmpID = &common.IcmpID{}
 }
 
 func main() {
-	logger.Info("msg", "Starting network_exporter", "version", version)
+	if *convertBlackbox != "" {
+		os.Exit(convertBlackboxConfig(*convertBlackbox))
+	}
+	if *checkConfig {
+		os.Exit(checkConfigFile(*configFile, *checkConfigFormat))
+	}
+
+	logger.Info("Starting network_exporter", "type", "Main", "func", "main", "version", version)
 
-	logger.Info("msg", "Loading config")
+	sc.ExpandEnv = *configExpandEnv
+	sc.ExpandEnvStrict = *configUndefinedEnv == "error"
+	sc.Strict = *configStrict
+	sc.ProbeName = *probeName
+	if err := configureConfigFetch(sc); err != nil {
+		logger.Error("Configuring config file download", "type", "Main", "func", "main", "err", err)
+		os.Exit(1)
+	}
+
+	logger.Info("Loading config", "type", "Main", "func", "main")
 	if err := sc.ReloadConfig(logger, *configFile, *configFileHeaders); err != nil {
-		logger.Error("msg", "Loading config", "err", err)
+		logger.Error("Loading config", "type", "Main", "func", "main", "err", err)
 		os.Exit(1)
 	}
 
-	reloadSignal()
+	// The TCP and HTTPGet probes keep working without the ICMP privileges
+	if err := icmp.Check(*enableIpv6); err != nil {
+		logger.Error("ICMP and MTR probes are not permitted", "type", "Main", "func", "main", "err", err)
+	}
+	common.TOSFailures.SetLogger(logger)
+	common.SourceInterfaceFailures.SetLogger(logger)
+	target.SetProbeLimit(sc.Cfg.Conf.MaxConcurrentProbes)
+
+	resolver = getResolver()
+	resultsHub = results.NewHub()
+	notifier = notify.NewWebhook(logger, resultsHub)
+	applyNotifications()
+	go notifier.Run(context.Background())
 
-	resolver := getResolver()
+	schedulers = newSchedulers()
 
-	monitorPING = monitor.NewPing(logger, sc, resolver, icmpID, *enableIpv6, *maxConcurrentJobs)
+	monitorPING = monitor.NewPing(logger, sc, resolver, icmpID, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["ICMP"])
 	go monitorPING.AddTargets()
 
-	monitorMTR = monitor.NewMTR(logger, sc, resolver, icmpID, *enableIpv6, *maxConcurrentJobs)
+	monitorMTR = monitor.NewMTR(logger, sc, resolver, icmpID, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["MTR"])
 	go monitorMTR.AddTargets()
 
-	monitorTCP = monitor.NewTCPPort(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs)
+	monitorTCP = monitor.NewTCPPort(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["TCP"])
 	go monitorTCP.AddTargets()
 
-	monitorHTTPGet = monitor.NewHTTPGet(logger, sc, resolver, *maxConcurrentJobs)
+	monitorHTTPGet = monitor.NewHTTPGet(logger, sc, resolver, *maxConcurrentJobs, resultsHub, schedulers["HTTPGet"])
 	go monitorHTTPGet.AddTargets()
 
+	monitorDNS = monitor.NewDNS(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["DNS"])
+	go monitorDNS.AddTargets()
+
+	monitorUDP = monitor.NewUDPPort(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["UDP"])
+	go monitorUDP.AddTargets()
+
+	monitorNTP = monitor.NewNTP(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["NTP"])
+	go monitorNTP.AddTargets()
+
+	monitorSMTP = monitor.NewSMTP(logger, sc, resolver, *enableIpv6, *maxConcurrentJobs, resultsHub, schedulers["SMTP"])
+	go monitorSMTP.AddTargets()
+
+	discoveryManager = discovery.NewManager(logger, sc, resolver, reloadMonitors)
+	discoveryManager.ApplyConfig(sc.Cfg.Discovery, sc.Cfg.TargetFiles)
+
+	// A reload uses the monitors and the discovery manager, a SIGHUP is only handled once they all exist
+	reloadSignal()
+
+	// The targets of literal IPs start right away, the unresolved hosts are retried in the background
+	resolution = monitor.NewResolution(logger, sc, resolver, *enableIpv6, retryResolution, monitorPING, monitorMTR, monitorTCP, monitorDNS, monitorUDP, monitorNTP, monitorSMTP)
+	go resolution.Run(context.Background())
+
+	if *historyBytes > 0 {
+		resultHistory = history.NewStore(logger, resultsHub, *historyBytes)
+		go resultHistory.Run(context.Background())
+	}
+
+	apiService = api.NewService(logger, sc, resultsHub, resultHistory, *grpcWatchBuffer, reloadMonitors)
+	if *grpcListenAddress != "" {
+		go startGRPCServer()
+	}
+
 	go startConfigRefresh()
+	go startSRVRefresh()
 
 	startServer()
 }
 
+// newSchedulers creates the worker pools of the check types when the pool scheduler is configured
+func newSchedulers() map[string]*scheduler.Scheduler {
+	s := map[string]*scheduler.Scheduler{}
+	if sc.Cfg.Conf.Scheduler != "pool" {
+		return s
+	}
+
+	workers := sc.Cfg.Conf.SchedulerWorkers
+	for name, n := range map[string]int{"ICMP": workers.ICMP, "MTR": workers.MTR, "TCP": workers.TCP, "HTTPGet": workers.HTTPGet, "DNS": workers.DNS, "UDP": workers.UDP, "NTP": workers.NTP, "SMTP": workers.SMTP} {
+		s[name] = scheduler.New(logger, name, n)
+	}
+	logger.Info("Using the pool scheduler", "type", "Main", "func", "newSchedulers", "icmp", workers.ICMP, "mtr", workers.MTR, "tcp", workers.TCP, "http_get", workers.HTTPGet, "dns", workers.DNS, "udp", workers.UDP, "ntp", workers.NTP, "smtp", workers.SMTP)
+	return s
+}
+
+// configCheck result of --check-config
+type configCheck struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Targets accepted and skipped per check type, the SRV records count their members
+	Targets map[string]*configCheckCount `json:"targets"`
+	Skipped []config.SkippedTarget       `json:"skipped"`
+}
+
+type configCheckCount struct {
+	Accepted int `json:"accepted"`
+	Skipped  int `json:"skipped"`
+}
+
+// checkConfigFile loads the config file like a reload without starting the probes, prints the result and returns the exit code
+func checkConfigFile(file string, format string) int {
+	// The validation errors are reported in the result
+	check := &configCheck{File: file, Targets: map[string]*configCheckCount{}, Skipped: []config.SkippedTarget{}}
+	c := &config.SafeConfig{Cfg: &config.Config{}, ExpandEnv: *configExpandEnv, ExpandEnvStrict: *configUndefinedEnv == "error", Strict: *configStrict, ProbeName: *probeName}
+	if err := configureConfigFetch(c); err != nil {
+		check.Error = err.Error()
+	} else if err := c.ReloadConfig(slog.New(slog.NewTextHandler(io.Discard, nil)), file, *configFileHeaders); err != nil {
+		check.Error = err.Error()
+	} else {
+		check.Valid = true
+		count := func(checkType string) *configCheckCount {
+			if check.Targets[checkType] == nil {
+				check.Targets[checkType] = &configCheckCount{}
+			}
+			return check.Targets[checkType]
+		}
+		for _, t := range c.Cfg.Targets {
+			count(string(t.Type)).Accepted++
+		}
+		check.Skipped = c.Skipped()
+		for _, t := range check.Skipped {
+			count(t.Type).Skipped++
+		}
+	}
+
+	if format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(check); err != nil {
+			logger.Error("Encoding config check", "type", "Config", "func", "checkConfigFile", "err", err)
+			return 1
+		}
+	} else {
+		if check.Valid {
+			fmt.Printf("%s: valid\n", file)
+		} else {
+			fmt.Printf("%s: invalid: %s\n", file, check.Error)
+		}
+		types := make([]string, 0, len(check.Targets))
+		for t := range check.Targets {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			fmt.Printf("  %s: %d accepted, %d skipped\n", t, check.Targets[t].Accepted, check.Targets[t].Skipped)
+		}
+		for _, t := range check.Skipped {
+			fmt.Printf("  skipped %s %s (%s): %s\n", t.Type, t.Name, t.Host, t.Reason)
+		}
+	}
+
+	if !check.Valid {
+		return 1
+	}
+	return 0
+}
+
+// convertBlackboxConfig prints the converted blackbox_exporter modules and returns the exit code
+func convertBlackboxConfig(file string) int {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		logger.Error("Reading blackbox config", "type", "Convert", "func", "convertBlackboxConfig", "file", file, "err", err)
+		return 1
+	}
+
+	out, warnings, err := convert.Blackbox(data)
+	if err != nil {
+		logger.Error("Converting blackbox config", "type", "Convert", "func", "convertBlackboxConfig", "file", file, "err", err)
+		return 1
+	}
+	for _, w := range warnings {
+		logger.Warn("Conversion warning", "type", "Convert", "func", "convertBlackboxConfig", "detail", w)
+	}
+
+	b, err := out.Marshal(file, warnings)
+	if err == nil {
+		err = out.Validate(b)
+	}
+	if err != nil {
+		logger.Error("Converting blackbox config", "type", "Convert", "func", "convertBlackboxConfig", "file", file, "err", err)
+		return 1
+	}
+	fmt.Print(string(b))
+	return 0
+}
+
+// configureConfigFetch sets the client and credentials used to download the configuration file from URL
+func configureConfigFetch(c *config.SafeConfig) error {
+	client, err := discovery.NewHTTPClient(config.TLSConfig{CAFile: *configFileCA}, *configFileTimeout)
+	if err != nil {
+		return err
+	}
+	auth := config.HTTPAuth{
+		Username:        *configFileUser,
+		Password:        *configFilePassword,
+		PasswordFile:    *configFilePassFile,
+		BearerToken:     *configFileToken,
+		BearerTokenFile: *configFileTokFile,
+	}
+	c.HTTPClient = client
+	c.HTTPAuth = func(req *http.Request) error { return discovery.SetAuth(req, auth) }
+	return nil
+}
+
 func startConfigRefresh() {
 	interval := sc.Cfg.Conf.Refresh.Duration()
 	if interval <= 0 {
@@ -127,22 +385,108 @@ func startConfigRefresh() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		logger.Info("msg", "ReLoading config")
-		if err := sc.ReloadConfig(logger, *configFile, *configFileHeaders); err != nil {
-			logger.Error("msg", "Reloading config skipped", "err", err)
-			continue
+		logger.Info("Reloading config", "type", "Main", "func", "startConfigRefresh")
+		if err := applyConfigFile(); err != nil {
+			logger.Error("Reloading config skipped", "type", "Main", "func", "startConfigRefresh", "err", err)
+		}
+	}
+}
+
+// startSRVRefresh expands the SRV records again between the config reloads and applies the changed members to the monitors
+func startSRVRefresh() {
+	interval := sc.Cfg.Conf.SrvRefresh.Duration()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		logger.Debug("Refreshing SRV records", "type", "Main", "func", "startSRVRefresh")
+		configMutex.Lock()
+		changed, err := sc.RefreshSRV(logger)
+		if err != nil {
+			logger.Error("Refreshing SRV records skipped", "type", "Main", "func", "startSRVRefresh", "err", err)
+		} else if changed {
+			reloadMonitors()
+		}
+		configMutex.Unlock()
+	}
+}
+
+// applyConfigFile reloads the config file and applies it to the discovery, notifications and monitors
+// The reloads are serialized so that the monitors are never diffed against a half applied config
+func applyConfigFile() error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if err := sc.ReloadConfig(logger, *configFile, *configFileHeaders); err != nil {
+		return err
+	}
+	discoveryManager.ApplyConfig(sc.Cfg.Discovery, sc.Cfg.TargetFiles)
+	applyNotifications()
+	target.SetProbeLimit(sc.Cfg.Conf.MaxConcurrentProbes)
+	monitorMTR.ApplyGeoIP()
+	reloadMonitors()
+	return nil
+}
+
+// retryResolution adds the targets resolved after a failed resolution, the config is reloaded to expand its SRV records again
+func retryResolution(reloadConfig bool) {
+	if reloadConfig {
+		logger.Info("Reloading config", "type", "Main", "func", "retryResolution")
+		err := applyConfigFile()
+		if err == nil {
+			return
 		}
-		monitorPING.DelTargets()
-		_ = monitorPING.CheckActiveTargets()
-		monitorPING.AddTargets()
-		monitorMTR.DelTargets()
-		_ = monitorMTR.CheckActiveTargets()
-		monitorMTR.AddTargets()
-		monitorTCP.DelTargets()
-		_ = monitorTCP.CheckActiveTargets()
-		monitorTCP.AddTargets()
-		monitorHTTPGet.DelTargets()
-		monitorHTTPGet.AddTargets()
+		logger.Error("Reloading config skipped", "type", "Main", "func", "retryResolution", "err", err)
+	}
+	reloadMonitors()
+}
+
+// applyNotifications updates the webhook settings of the active configuration
+func applyNotifications() {
+	if err := notifier.ApplyConfig(sc.Cfg.Notifications.Webhook); err != nil {
+		logger.Error("Configuring webhook notifications", "type", "Notify", "func", "applyNotifications", "err", err)
+	}
+}
+
+// reloadMonitors adds/removes the monitored targets based on the active configuration
+func reloadMonitors() {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	monitorPING.DelTargets()
+	_ = monitorPING.CheckActiveTargets()
+	monitorPING.AddTargets()
+	monitorMTR.DelTargets()
+	_ = monitorMTR.CheckActiveTargets()
+	monitorMTR.AddTargets()
+	monitorTCP.DelTargets()
+	_ = monitorTCP.CheckActiveTargets()
+	monitorTCP.AddTargets()
+	monitorHTTPGet.DelTargets()
+	monitorHTTPGet.AddTargets()
+	monitorDNS.DelTargets()
+	_ = monitorDNS.CheckActiveTargets()
+	monitorDNS.AddTargets()
+	monitorUDP.DelTargets()
+	_ = monitorUDP.CheckActiveTargets()
+	monitorUDP.AddTargets()
+	monitorNTP.DelTargets()
+	_ = monitorNTP.CheckActiveTargets()
+	monitorNTP.AddTargets()
+	monitorSMTP.DelTargets()
+	_ = monitorSMTP.CheckActiveTargets()
+	monitorSMTP.AddTargets()
+}
+
+func startGRPCServer() {
+	server := api.NewGRPCServer(logger, apiService)
+	if err := server.ListenAndServe(*grpcListenAddress, *grpcTLSCertFile, *grpcTLSKeyFile, *grpcTLSClientCA); err != nil {
+		logger.Error("Could not start gRPC server", "type", "API", "func", "startGRPCServer", "err", err)
+		os.Exit(1)
 	}
 }
 
@@ -156,15 +500,69 @@ func startServer() {
 	reg.MustRegister(&collector.MTR{Monitor: monitorMTR})
 	reg.MustRegister(&collector.PING{Monitor: monitorPING})
 	reg.MustRegister(&collector.TCP{Monitor: monitorTCP})
-	reg.MustRegister(&collector.HTTPGet{Monitor: monitorHTTPGet})
+	httpCollector := &collector.HTTPGet{Monitor: monitorHTTPGet, SC: sc, Hub: resultsHub}
+	go httpCollector.Run(context.Background())
+	reg.MustRegister(httpCollector)
+	reg.MustRegister(&collector.DNSQuery{Monitor: monitorDNS})
+	reg.MustRegister(&collector.UDP{Monitor: monitorUDP})
+	reg.MustRegister(&collector.NTP{Monitor: monitorNTP})
+	reg.MustRegister(&collector.SMTP{Monitor: monitorSMTP})
+	reg.MustRegister(&collector.Discovery{Manager: discoveryManager})
+	reg.MustRegister(&collector.Results{Hub: resultsHub})
+	reg.MustRegister(&collector.Probe{Hub: resultsHub})
+	reg.MustRegister(&collector.Origin{SC: sc})
+	reg.MustRegister(&collector.SRV{SC: sc})
+	reg.MustRegister(&collector.Shard{SC: sc})
+	reg.MustRegister(&collector.Notify{Webhook: notifier})
+	reg.MustRegister(&collector.Scheduler{Schedulers: schedulers})
+	reg.MustRegister(&collector.Resolution{Resolution: resolution})
+	reg.MustRegister(&collector.DNS{Resolver: resolver})
+	reg.MustRegister(&collector.Panics{})
+	reg.MustRegister(&collector.Skipped{})
+	reg.MustRegister(&collector.Degraded{})
+	reg.MustRegister(&collector.RTT{})
+	reg.MustRegister(&collector.TOS{})
+	reg.MustRegister(&collector.SourceInterface{})
+	reg.MustRegister(&collector.IPVersion{PING: monitorPING, MTR: monitorMTR, TCP: monitorTCP, DNS: monitorDNS, UDP: monitorUDP, NTP: monitorNTP, SMTP: monitorSMTP})
 	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
 	mux.Handle(webMetricsPath, h)
+	mux.HandleFunc("/api/v1/targets", apiService.ServeTargets)
+	mux.HandleFunc("/api/v1/srv", apiService.ServeSRV)
+	mux.HandleFunc("/api/v1/history", apiService.ServeHistory)
+	mux.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Healthy\n")
+	})
+	// Ready once the unresolved targets are retried, not only when all of them resolved
+	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !resolution.Ready() {
+			http.Error(w, "Not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "Ready\n")
+	})
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, indexHTML, webMetricsPath)
 	})
+	mux.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		if !*enableLifecycle {
+			http.Error(w, "Lifecycle API is not enabled", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		logger.Info("Reloading config", "type", "Main", "func", "reload")
+		if err := applyConfigFile(); err != nil {
+			logger.Error("Reloading config skipped", "type", "Main", "func", "reload", "err", err)
+			http.Error(w, fmt.Sprintf("Reloading config: %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "Reloaded\n")
+	})
 
 	if *enableProfileing {
-		logger.Info("msg", "Profiling enabled")
+		logger.Info("Profiling enabled", "type", "Main", "func", "startServer")
 		mux.Handle("/debug/vars", http.HandlerFunc(expVars))
 		mux.HandleFunc("/debug/fgprof", fgprof.Handler().(http.HandlerFunc))
 		mux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -178,8 +576,7 @@ func startServer() {
 		Handler: mux,
 	}
 
-	logger.Info("msg", "Starting network_exporter", "version", version)
-	logger.Info("msg", fmt.Sprintf("Listening for %s on %s", webMetricsPath, *WebListenAddresses))
+	logger.Info("Listening for metrics", "type", "Main", "func", "startServer", "path", webMetricsPath, "address", strings.Join(*WebListenAddresses, ","))
 
 	serverFlags := web.FlagConfig{
 		WebConfigFile:      WebConfigFile,
@@ -187,22 +584,28 @@ func startServer() {
 		WebListenAddresses: WebListenAddresses,
 	}
 	if err := web.ListenAndServe(server, &serverFlags, logger); err != nil {
-		logger.Error("msg", "Could not start HTTP server", "err", err)
+		logger.Error("Could not start HTTP server", "type", "Main", "func", "startServer", "err", err)
 	}
 }
 
 func getResolver() *config.Resolver {
-	if sc.Cfg.Conf.Nameserver == "" {
-		logger.Info("msg", "Configured default DNS resolver")
+	if len(sc.Cfg.Conf.Nameserver) == 0 {
+		logger.Info("Configured default DNS resolver", "type", "Main", "func", "getResolver")
 		return &config.Resolver{Resolver: net.DefaultResolver, Timeout: sc.Cfg.Conf.NameserverTimeout.Duration()}
 	}
 
-	logger.Info("msg", "Configured custom DNS resolver")
-	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
-		d := net.Dialer{Timeout: sc.Cfg.Conf.NameserverTimeout.Duration()}
-		return d.DialContext(ctx, network, sc.Cfg.Conf.Nameserver)
+	logger.Info("Configured custom DNS resolver", "type", "Main", "func", "getResolver", "nameserver", strings.Join(sc.Cfg.Conf.Nameserver, ","))
+	tlsConfig, err := discovery.NewTLSConfig(sc.Cfg.Conf.NameserverTLS)
+	if err != nil {
+		logger.Error("Configuring DNS resolver", "type", "Main", "func", "getResolver", "err", err)
+		os.Exit(1)
+	}
+	resolver, err := config.NewResolver(logger, sc.Cfg.Conf.Nameserver, sc.Cfg.Conf.NameserverTimeout.Duration(), tlsConfig, sc.Cfg.Conf.NameserverFallback)
+	if err != nil {
+		logger.Error("Configuring DNS resolver", "type", "Main", "func", "getResolver", "err", err)
+		os.Exit(1)
 	}
-	return &config.Resolver{Resolver: &net.Resolver{PreferGo: true, Dial: dialer}, Timeout: sc.Cfg.Conf.NameserverTimeout.Duration()}
+	return resolver
 }
 
 func expVars(w http.ResponseWriter, r *http.Request) {
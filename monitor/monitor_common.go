@@ -1,18 +1,147 @@
 package monitor
 
 import (
-	"strings"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/target"
 )
 
 // countTargets Count the number of target by type
-func countTargets(sc *config.SafeConfig, target string) (count int) {
+func countTargets(sc *config.SafeConfig, target config.TargetType) (count int) {
 	count = 0
 	for _, v := range sc.Cfg.Targets {
-		if strings.Contains(strings.ToUpper(v.Type), strings.ToUpper(target)) {
+		if slices.Contains(v.Type.Monitors(), target) {
 			count++
 		}
 	}
 	return count
 }
+
+// probeInterval returns the interval and timeout of a target, the timeout is capped to a shorter interval override
+func probeInterval(interval time.Duration, timeout time.Duration, overrideInterval time.Duration, overrideTimeout time.Duration) (time.Duration, time.Duration) {
+	if overrideTimeout > 0 {
+		timeout = overrideTimeout
+	}
+	if overrideInterval <= 0 {
+		return interval, timeout
+	}
+	if timeout > overrideInterval {
+		timeout = overrideInterval
+	}
+	return overrideInterval, timeout
+}
+
+// adaptive returns the adaptive probing of a check type
+func adaptive(a config.Adaptive) target.Adaptive {
+	return target.Adaptive{DegradedInterval: a.DegradedInterval.Duration(), TriggerLoss: a.TriggerLoss, RecoveryRounds: a.RecoveryRounds}
+}
+
+// override returns the value of a target when set (>0), otherwise the one of the check type
+func override(value int, target int) int {
+	if target > 0 {
+		return target
+	}
+	return value
+}
+
+// timing interval and timeout of a running target
+type timing interface {
+	Interval() time.Duration
+	Timeout() time.Duration
+}
+
+// retimed reports if the interval or timeout of a running target differ from the ones of its configuration
+func retimed(running timing, interval time.Duration, timeout time.Duration, t config.Target) bool {
+	i, to := probeInterval(interval, timeout, t.Interval.Duration(), t.Timeout.Duration())
+	return running.Interval() != i || running.Timeout() != to
+}
+
+// labeled running target with labels
+type labeled interface {
+	Labels() map[string]string
+}
+
+// relabeled reports if the labels of a running target differ from the ones of its configuration
+func relabeled(running labeled, t config.Target) bool {
+	return !maps.Equal(running.Labels(), t.Labels.Kv)
+}
+
+// sourced running target with a source address or interface
+type sourced interface {
+	Source() (string, string)
+}
+
+// resourced reports if the source_ip or source_interface of a running target differ from the ones of its configuration, iface being conf.source_interface
+func resourced(running sourced, t config.Target, iface string) bool {
+	addr, srcInterface := running.Source()
+	return addr != t.SourceIp || srcInterface != t.Interface(iface)
+}
+
+// errUnresolved the host of a target could not be resolved
+var errUnresolved = errors.New("host not resolved")
+
+// unresolvedHosts hosts of the configured targets that could not be resolved by the last AddTargets
+type unresolvedHosts struct {
+	mtx   sync.Mutex
+	hosts []string
+}
+
+// set replaces the unresolved hosts
+func (u *unresolvedHosts) set(hosts map[string]struct{}) {
+	list := make([]string, 0, len(hosts))
+	for h := range hosts {
+		list = append(list, h)
+	}
+	sort.Strings(list)
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.hosts = list
+}
+
+// list returns the unresolved hosts
+func (u *unresolvedHosts) list() []string {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return u.hosts
+}
+
+// AddressFamily IP version of the address probed by a target, the IP is empty for a target whose host has no address of its ip_version
+type AddressFamily struct {
+	Name      string
+	Host      string
+	IP        string
+	IPVersion int
+}
+
+// missingFamilies targets whose host had no address of their ip_version in the last AddTargets
+type missingFamilies struct {
+	mtx     sync.Mutex
+	targets []AddressFamily
+}
+
+// set replaces the targets without an address of their ip_version
+func (m *missingFamilies) set(targets []AddressFamily) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.targets = targets
+}
+
+// list returns the targets without an address of their ip_version
+func (m *missingFamilies) list() []AddressFamily {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.targets
+}
+
+// familyMatches reports if an address is of the IP version (0 both)
+func familyMatches(ip string, ipVersion int) bool {
+	return ipVersion == 0 || common.IPVersion(ip) == ipVersion
+}
@@ -0,0 +1,333 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/dns"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+	"github.com/syepes/network_exporter/target"
+)
+
+// DNS manages the goroutines responsible for collecting DNS data
+type DNS struct {
+	logger            *slog.Logger
+	sc                *config.SafeConfig
+	resolver          *config.Resolver
+	interval          time.Duration
+	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
+	ipv6              bool
+	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	targets           map[string]*target.DNS
+	unresolved        unresolvedHosts
+	missing           missingFamilies
+	spread            spread
+	mtx               sync.RWMutex
+}
+
+// NewDNS creates and configures a new Monitoring DNS instance
+func NewDNS(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *DNS {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &DNS{
+		logger:            logger,
+		sc:                sc,
+		resolver:          resolver,
+		interval:          sc.Cfg.DNS.Interval.Duration(),
+		timeout:           sc.Cfg.DNS.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.DNS.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.DNS.Adaptive),
+		ipv6:              ipv6,
+		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		targets:           make(map[string]*target.DNS),
+	}
+}
+
+// dnsServer returns the host and port of the server of a DNS target, port 53 when the host has no port
+func dnsServer(host string) (string, string) {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		return h, port
+	}
+	return strings.Trim(host, "[]"), "53"
+}
+
+// dnsQuestion returns the question of a DNS target
+func dnsQuestion(t config.Target) dns.Question {
+	return dns.Question{Name: t.QueryName, Type: t.DNSQueryType(), Transport: t.DNSTransport(), ExpectedAnswers: t.ExpectedAnswers}
+}
+
+// Stop brings the monitoring gracefully to a halt
+func (p *DNS) Stop() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for id := range p.targets {
+		p.removeTarget(id)
+	}
+}
+
+// AddTargets adds newly added targets from the configuration
+func (p *DNS) AddTargets() {
+	p.logger.Debug("Current Targets", "type", "DNS", "func", "AddTargets", "count", len(p.targets), "configured", countTargets(p.sc, "DNS"))
+
+	targetActiveTmp := []string{}
+	for _, v := range p.targets {
+		targetActiveTmp = common.AppendIfMissing(targetActiveTmp, v.Name())
+	}
+
+	targetConfigTmp := []string{}
+	unresolved := map[string]struct{}{}
+	missing := []AddressFamily{}
+	// Resolve the server of every target once
+	addrs := map[string][]string{}
+	for _, v := range p.sc.Cfg.Targets {
+		if v.Type != "DNS" {
+			continue
+		}
+		host, _ := dnsServer(v.Host)
+		ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+		if errors.Is(err, common.ErrNoAddressFamily) {
+			p.logger.Error("Skipping target without an address of its ip_version", "type", "DNS", "func", "AddTargets", "name", v.Name, "host", v.Host, "err", err)
+			missing = append(missing, AddressFamily{Name: v.Name, Host: v.Host, IPVersion: v.IPFamily(p.sc.Cfg.IPVersion)})
+		} else if err != nil || len(ipAddrs) == 0 {
+			p.logger.Warn("Skipping resolve target", "type", "DNS", "func", "AddTargets", "host", v.Host, "err", err)
+			unresolved[host] = struct{}{}
+		}
+		addrs[v.Name] = ipAddrs
+		for _, ipAddr := range ipAddrs {
+			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+		}
+	}
+	p.unresolved.set(unresolved)
+	p.missing.set(missing)
+
+	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose host, port, labels, source address, interval, timeout or query changed are restarted
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			if v.Type != "DNS" || v.Name+" "+t.Ip() != key {
+				continue
+			}
+			host, port := dnsServer(v.Host)
+			q, running := dnsQuestion(v), t.Question()
+			if t.Host() != host || t.Port() != port || relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || q.Name != running.Name || q.Type != running.Type || q.Transport != running.Transport || !slices.Equal(q.ExpectedAnswers, running.ExpectedAnswers) {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
+	p.logger.Debug("Target names to add", "type", "DNS", "func", "AddTargets", "targets", targetAdd)
+
+	targetLookup := make(map[string]bool)
+	for _, t := range targetAdd {
+		targetLookup[t] = true
+	}
+
+	startups := []startup{}
+	for _, target := range p.sc.Cfg.Targets {
+		if target.Type != "DNS" {
+			continue
+		}
+		for _, ipAddr := range addrs[target.Name] {
+			if targetLookup[target.Name+" "+ipAddr] {
+				startups = append(startups, p.startup(target, ipAddr, "AddTargets"))
+			}
+		}
+	}
+	p.spread.start(p.logger, "DNS", p.sc.Cfg.SpreadProbes, startups)
+}
+
+// startup returns the startup of the target of an address
+func (p *DNS) startup(target config.Target, ipAddr string, caller string) startup {
+	interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+	return startup{key: target.Name + " " + ipAddr, interval: interval, add: func(startupDelay time.Duration) error {
+		host, port := dnsServer(target.Host)
+		err := p.AddTargetDelayed(target.Name+" "+ipAddr, host, ipAddr, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), port, dnsQuestion(target), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration())
+		if err != nil {
+			p.logger.Warn("Skipping target", "type", "DNS", "func", caller, "host", target.Host, "ip", ipAddr, "err", err)
+		}
+		return err
+	}}
+}
+
+// Unresolved returns the hosts of the configured targets that could not be resolved
+func (p *DNS) Unresolved() []string {
+	return p.unresolved.list()
+}
+
+// AddTarget adds a target to the monitored list
+func (p *DNS) AddTarget(name string, host string, ip string, srcAddr string, port string, question dns.Question, labels map[string]string) (err error) {
+	return p.AddTargetDelayed(name, host, ip, srcAddr, "", port, question, labels, 0, 0, 0)
+}
+
+// AddTargetDelayed is AddTarget with a startup delay, interval and timeout overrides (0 uses the ones of the check type)
+func (p *DNS) AddTargetDelayed(name string, host string, ip string, srcAddr string, srcInterface string, port string, question dns.Question, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration) (err error) {
+	p.logger.Info("Adding Target", "type", "DNS", "func", "AddTargetDelayed", "name", name, "host", host, "ip", ip, "port", port, "query_name", question.Name, "query_type", question.Type, "transport", question.Transport, "delay", startupDelay)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewDNS(p.logger, startupDelay, name, host, ip, srcAddr, srcInterface, port, question, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, labels, p.maxConcurrentJobs, p.hub, p.sched)
+	if err != nil {
+		return err
+	}
+	p.removeTarget(name)
+	p.targets[name] = target
+	return nil
+}
+
+// DelTargets deletes/stops the removed targets from the configuration
+func (p *DNS) DelTargets() {
+	p.logger.Debug("Current Targets", "type", "DNS", "func", "DelTargets", "count", len(p.targets), "configured", countTargets(p.sc, "DNS"))
+
+	targetActiveTmp := []string{}
+	for _, v := range p.targets {
+		if v != nil {
+			targetActiveTmp = common.AppendIfMissing(targetActiveTmp, v.Name())
+		}
+	}
+
+	targetConfigTmp := []string{}
+	for _, v := range p.sc.Cfg.Targets {
+		if v.Type == "DNS" {
+			host, _ := dnsServer(v.Host)
+			ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+			if err != nil || len(ipAddrs) == 0 {
+				p.logger.Warn("Skipping resolve target", "type", "DNS", "func", "DelTargets", "host", v.Host, "err", err)
+			}
+			for _, ipAddr := range ipAddrs {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+			}
+		}
+	}
+
+	targetDelete := common.CompareList(targetConfigTmp, targetActiveTmp)
+	for _, targetName := range targetDelete {
+		for _, t := range p.targets {
+			if t == nil {
+				continue
+			}
+			if t.Name() == targetName {
+				p.RemoveTarget(targetName)
+			}
+		}
+	}
+}
+
+// RemoveTarget removes a target from the monitoring list
+func (p *DNS) RemoveTarget(key string) {
+	p.logger.Info("Removing Target", "type", "DNS", "func", "RemoveTarget", "target", key)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.removeTarget(key)
+}
+
+// Stops monitoring a target and removes it from the list (if the list includes the target)
+func (p *DNS) removeTarget(key string) {
+	target, found := p.targets[key]
+	if !found {
+		return
+	}
+	target.Stop()
+	delete(p.targets, key)
+	p.spread.remove(key)
+}
+
+// Read target if IP was changed (DNS record)
+func (p *DNS) CheckActiveTargets() (err error) {
+	p.logger.Debug("Current Targets", "type", "DNS", "func", "CheckActiveTargets", "count", len(p.targets), "configured", countTargets(p.sc, "DNS"))
+
+	targetActiveTmp := make(map[string]string)
+	for _, v := range p.targets {
+		targetActiveTmp[v.Name()] = v.Ip()
+	}
+
+	for targetName, targetIp := range targetActiveTmp {
+		for _, target := range p.sc.Cfg.Targets {
+			if target.Type != "DNS" || targetName != target.Name+" "+targetIp {
+				continue
+			}
+			host, _ := dnsServer(target.Host)
+			ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
+			if err != nil || len(ipAddrs) == 0 {
+				return err
+			}
+
+			if !common.ContainsString(ipAddrs, targetIp) {
+				p.RemoveTarget(targetName)
+
+				startups := []startup{}
+				for _, ipAddr := range ipAddrs {
+					startups = append(startups, p.startup(target, ipAddr, "CheckActiveTargets"))
+				}
+				p.spread.start(p.logger, "DNS", p.sc.Cfg.SpreadProbes, startups)
+			}
+		}
+	}
+	return nil
+}
+
+// AddressFamilies returns the IP version of the address of every target and the targets without an address of their ip_version
+func (p *DNS) AddressFamilies() []AddressFamily {
+	p.mtx.RLock()
+	families := make([]AddressFamily, 0, len(p.targets))
+	for _, t := range p.targets {
+		ip := t.Ip()
+		families = append(families, AddressFamily{Name: strings.TrimSuffix(t.Name(), " "+ip), Host: t.Host(), IP: ip, IPVersion: common.IPVersion(ip)})
+	}
+	p.mtx.RUnlock()
+	return append(families, p.missing.list()...)
+}
+
+// ExportMetrics collects the metrics for each monitored target and returns it as a simple map
+func (p *DNS) ExportMetrics() map[string]*dns.DNSReturn {
+	m := make(map[string]*dns.DNSReturn)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, target := range p.targets {
+		name := target.Name()
+		metrics := target.Compute()
+
+		if metrics != nil {
+			m[name] = metrics
+		}
+	}
+	return m
+}
+
+// ExportLabels target labels
+func (p *DNS) ExportLabels() map[string]map[string]string {
+	l := make(map[string]map[string]string)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, target := range p.targets {
+		name := target.Name()
+		labels := target.Labels()
+
+		if labels != nil {
+			l[name] = labels
+		}
+	}
+	return l
+}
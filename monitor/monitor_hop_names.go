@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+)
+
+// hopNameLookups maximum number of concurrent reverse lookups of the hop addresses
+const hopNameLookups = 8
+
+// hopNameRetry time before a failed reverse lookup is retried, at most the TTL of the names
+const hopNameRetry = 5 * time.Minute
+
+// hopName cached reverse lookup of a hop address
+type hopName struct {
+	name    string
+	expires time.Time
+	pending bool
+}
+
+// hopNames caches the reverse lookups of the MTR hop addresses, the lookups run in the background so the collection never waits for them
+type hopNames struct {
+	logger   *slog.Logger
+	resolver *config.Resolver
+	ttl      time.Duration
+	sem      chan struct{}
+	names    map[string]*hopName
+	mtx      sync.Mutex
+}
+
+// newHopNames creates the cache of the reverse lookups of the hop addresses made with the resolver, the names are kept for the TTL
+func newHopNames(logger *slog.Logger, resolver *config.Resolver, ttl time.Duration) *hopNames {
+	return &hopNames{
+		logger:   logger,
+		resolver: resolver,
+		ttl:      ttl,
+		sem:      make(chan struct{}, hopNameLookups),
+		names:    make(map[string]*hopName),
+	}
+}
+
+// name returns the cached name of a hop address, the address itself while it is looked up or when it has no name
+// The addresses not cached or expired are looked up in the background, the expired names are returned until they are refreshed
+func (h *hopNames) name(ip string) string {
+	if net.ParseIP(ip) == nil {
+		return ip
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	n, found := h.names[ip]
+	if !found {
+		n = &hopName{}
+		h.names[ip] = n
+	}
+	if !n.pending && time.Now().After(n.expires) {
+		select {
+		case h.sem <- struct{}{}:
+			n.pending = true
+			go h.lookup(ip)
+		default:
+			// The lookups at the limit, the address is looked up by a later collection
+		}
+	}
+	if n.name == "" {
+		return ip
+	}
+	return n.name
+}
+
+// lookup resolves the name of a hop address within the resolver timeout, the failures are cached for hopNameRetry
+func (h *hopNames) lookup(ip string) {
+	defer func() { <-h.sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.resolver.Timeout)
+	defer cancel()
+	names, err := h.resolver.Resolver.LookupAddr(ctx, ip)
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	now := time.Now()
+	// The names not requested for a TTL are dropped
+	for addr, n := range h.names {
+		if !n.pending && now.Sub(n.expires) > h.ttl {
+			delete(h.names, addr)
+		}
+	}
+
+	n, found := h.names[ip]
+	if !found {
+		n = &hopName{}
+		h.names[ip] = n
+	}
+	n.pending = false
+	if err != nil || len(names) == 0 {
+		h.logger.Debug("Hop reverse lookup failed", "type", "MTR", "func", "lookup", "ip", ip, "err", err)
+		n.expires = now.Add(min(h.ttl, hopNameRetry))
+		return
+	}
+	n.name = strings.TrimSuffix(names[0], ".")
+	n.expires = now.Add(h.ttl)
+}
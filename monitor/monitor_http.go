@@ -1,16 +1,21 @@
 package monitor
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log/slog"
-	"math/rand"
+	"maps"
 	"net/url"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/syepes/network_exporter/config"
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/http"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 	"github.com/syepes/network_exporter/target"
 )
 
@@ -21,13 +26,20 @@ type HTTPGet struct {
 	resolver          *config.Resolver
 	interval          time.Duration
 	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
+	bodySizeLimit     int64
+	bodyReadLimit     int64
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
 	targets           map[string]*target.HTTPGet
+	spread            spread
 	mtx               sync.RWMutex
 }
 
 // NewHTTPGet creates and configures a new Monitoring HTTPGet instance
-func NewHTTPGet(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, maxConcurrentJobs int) *HTTPGet {
+func NewHTTPGet(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *HTTPGet {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
@@ -37,7 +49,13 @@ func NewHTTPGet(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Res
 		resolver:          resolver,
 		interval:          sc.Cfg.HTTPGet.Interval.Duration(),
 		timeout:           sc.Cfg.HTTPGet.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.HTTPGet.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.HTTPGet.Adaptive),
+		bodySizeLimit:     sc.Cfg.HTTPGet.BodySizeLimit,
+		bodyReadLimit:     sc.Cfg.HTTPGet.BodyReadLimit,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		targets:           make(map[string]*target.HTTPGet),
 	}
 }
@@ -63,49 +81,87 @@ func (p *HTTPGet) AddTargets() {
 
 	targetConfigTmp := []string{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "HTTPGet" {
+		if v.Type == config.TypeHTTPGet {
 			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
 		}
 	}
 
 	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose URL, labels, source address, interval, timeout, credentials, request, TLS config, connection mode, HTTP version, redirect policy, status codes, body patterns or body digest changed are restarted
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			if v.Type != config.TypeHTTPGet || v.Name != key {
+				continue
+			}
+			matches, notMatches := t.BodyPatterns()
+			body, contentType := t.RequestBody()
+			_, tlsID := v.TLSClientConfig()
+			_, proxyTLSID := v.ProxyTLSClientConfig()
+			follow, maxRedirects := t.Redirects()
+			configFollow, configMaxRedirects := v.Redirects()
+			if t.URL() != v.Host || relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || t.Proxy() != v.ProxyURL() || t.ProxyTLSID() != proxyTLSID || t.ProxyFromEnvironment() != v.ProxyFromEnvironment(p.sc.Cfg.Conf.ProxyFromEnvironment) || t.Authorization() != v.Authorization() || !maps.Equal(t.Headers(), v.Headers()) ||
+				t.Method() != v.HTTPMethod() || body != v.RequestBody() || contentType != v.ContentType || t.TLSID() != tlsID || t.ReuseConnection() != v.ReuseConnection() || t.HTTP3() != v.HTTP3() || follow != configFollow || maxRedirects != configMaxRedirects || !slices.Equal(t.ValidStatusCodes(), v.ValidStatusCodes) ||
+				!slices.Equal(matches, v.FailIfBodyMatches) || !slices.Equal(notMatches, v.FailIfBodyNotMatches) || t.BodyDigest() != v.BodyDigest() {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
 	p.logger.Debug("Target names to add", "type", "HTTPGet", "func", "AddTargets", "targets", targetAdd)
 
+	startups := []startup{}
 	for _, targetName := range targetAdd {
 		for _, target := range p.sc.Cfg.Targets {
 			if target.Name != targetName {
 				continue
 			}
-			if target.Type == "HTTPGet" {
-				// Add jitter to prevent thundering herd (0-10% of interval)
-				jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-				if target.Proxy != "" {
-					err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Proxy, target.Labels.Kv, jitter)
-					if err != nil {
-						p.logger.Warn("Skipping target", "type", "HTTPGet", "func", "AddTargets", "host", target.Host, "err", err)
-					}
-				} else {
-					err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, "", target.Labels.Kv, jitter)
-					if err != nil {
-						p.logger.Warn("Skipping target", "type", "HTTPGet", "func", "AddTargets", "host", target.Host, "err", err)
-					}
-				}
+			if target.Type == config.TypeHTTPGet {
+				startups = append(startups, p.startup(target))
 			}
 		}
 	}
+	p.spread.start(p.logger, "HTTPGet", p.sc.Cfg.SpreadProbes, startups)
+}
+
+// startup returns the startup of a target
+func (p *HTTPGet) startup(target config.Target) startup {
+	interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+	return startup{key: target.Name, interval: interval, add: func(startupDelay time.Duration) error {
+		var err error
+		tlsConfig, tlsID := target.TLSClientConfig()
+		proxyTLS, proxyTLSID := target.ProxyTLSClientConfig()
+		follow, maxRedirects := target.Redirects()
+		if target.Proxy != "" {
+			err = p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), target.ProxyURL(), proxyTLS, proxyTLSID, false, target.Authorization(), target.Headers(), target.HTTPMethod(), target.RequestBody(), target.ContentType, tlsConfig, tlsID, target.ReuseConnection(), target.HTTP3(), follow, maxRedirects, target.ValidStatusCodes, p.bodyMatch(target), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration())
+		} else {
+			err = p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), "", nil, "", target.ProxyFromEnvironment(p.sc.Cfg.Conf.ProxyFromEnvironment), target.Authorization(), target.Headers(), target.HTTPMethod(), target.RequestBody(), target.ContentType, tlsConfig, tlsID, target.ReuseConnection(), target.HTTP3(), follow, maxRedirects, target.ValidStatusCodes, p.bodyMatch(target), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration())
+		}
+		if err != nil {
+			p.logger.Warn("Skipping target", "type", "HTTPGet", "func", "AddTargets", "host", target.Host, "err", err)
+		}
+		return err
+	}}
+}
+
+// bodyMatch returns the body patterns and digest of a target, nil without them
+func (p *HTTPGet) bodyMatch(t config.Target) *http.BodyMatch {
+	matches, notMatches := t.BodyPatterns()
+	if len(matches) == 0 && len(notMatches) == 0 && t.BodyDigest() == "" {
+		return nil
+	}
+	return &http.BodyMatch{FailIfMatches: matches, FailIfNotMatches: notMatches, SizeLimit: p.bodySizeLimit, SHA256: t.BodyDigest()}
 }
 
 // AddTarget adds a target to the monitored list
 func (p *HTTPGet) AddTarget(name string, url string, srcAddr string, proxy string, labels map[string]string) (err error) {
-	return p.AddTargetDelayed(name, url, srcAddr, proxy, labels, 0)
+	return p.AddTargetDelayed(name, url, srcAddr, "", proxy, nil, "", false, "", nil, "GET", "", "", nil, "", false, false, true, 10, nil, nil, labels, 0, 0, 0)
 }
 
-// AddTargetDelayed is AddTarget with a startup delay
-func (p *HTTPGet) AddTargetDelayed(name string, urlStr string, srcAddr string, proxy string, labels map[string]string, startupDelay time.Duration) (err error) {
+// AddTargetDelayed is AddTarget with the TLS config of an https proxy, the proxy of the environment, an Authorization header, the request headers, method, body and TLS config, the connection reuse, HTTP/3, the redirect policy, the valid status codes and body patterns, a startup delay and interval and timeout overrides (0 uses the ones of the check type)
+func (p *HTTPGet) AddTargetDelayed(name string, urlStr string, srcAddr string, srcInterface string, proxy string, proxyTLS *tls.Config, proxyTLSID string, proxyFromEnv bool, authorization string, headers map[string]string, method string, body string, contentType string, tlsConfig *tls.Config, tlsID string, reuse bool, http3 bool, followRedirects bool, maxRedirects int, validStatusCodes []int, bodyMatch *http.BodyMatch, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration) (err error) {
 	if proxy != "" {
-		p.logger.Info("Adding Target", "type", "HTTPGet", "func", "AddTargetDelayed", "name", name, "url", urlStr, "proxy", proxy, "delay", startupDelay)
+		p.logger.Info("Adding Target", "type", "HTTPGet", "func", "AddTargetDelayed", "name", name, "method", method, "url", urlStr, "proxy", common.RedactURL(proxy), "delay", startupDelay)
 	} else {
-		p.logger.Info("Adding Target", "type", "HTTPGet", "func", "AddTargetDelayed", "name", name, "url", urlStr, "delay", startupDelay)
+		p.logger.Info("Adding Target", "type", "HTTPGet", "func", "AddTargetDelayed", "name", name, "method", method, "url", urlStr, "delay", startupDelay)
 	}
 
 	p.mtx.Lock()
@@ -121,11 +177,12 @@ func (p *HTTPGet) AddTargetDelayed(name string, urlStr string, srcAddr string, p
 	if proxy != "" {
 		_, err := url.ParseRequestURI(proxy)
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid proxy URL")
 		}
 	}
 
-	target, err := target.NewHTTPGet(p.logger, startupDelay, name, dURL.String(), srcAddr, proxy, p.interval, p.timeout, labels, p.maxConcurrentJobs)
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewHTTPGet(p.logger, startupDelay, name, dURL.String(), srcAddr, srcInterface, proxy, proxyTLS, proxyTLSID, proxyFromEnv, authorization, headers, method, body, contentType, tlsConfig, tlsID, reuse, http3, followRedirects, maxRedirects, validStatusCodes, bodyMatch, p.bodyReadLimit, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, labels, p.maxConcurrentJobs, p.hub, p.sched)
 	if err != nil {
 		return err
 	}
@@ -147,7 +204,7 @@ func (p *HTTPGet) DelTargets() {
 
 	targetConfigTmp := []string{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "HTTPGet" {
+		if v.Type == config.TypeHTTPGet {
 			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
 		}
 	}
@@ -181,6 +238,7 @@ func (p *HTTPGet) removeTarget(key string) {
 	}
 	target.Stop()
 	delete(p.targets, key)
+	p.spread.remove(key)
 }
 
 // Export collects the metrics for each monitored target and returns it as a simple map
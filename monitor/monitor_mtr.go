@@ -2,8 +2,10 @@ package monitor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"math/rand"
+	"net"
 	"os"
 	"strings"
 	"sync"
@@ -11,7 +13,10 @@ import (
 
 	"github.com/syepes/network_exporter/config"
 	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/geoip"
 	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 	"github.com/syepes/network_exporter/target"
 )
 
@@ -23,38 +28,91 @@ type MTR struct {
 	icmpID            *common.IcmpID
 	interval          time.Duration
 	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
 	maxHops           int
 	count             int
+	packetInterval    time.Duration
+	parallelHops      int
 	payloadSize       int
+	tos               int
 	protocol          string
 	tcpPort           string
+	udpPort           string
+	pathChangeRounds  int
 	ipv6              bool
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	hopNames          *hopNames
+	geoip             *geoip.DB
+	geoipMtx          sync.RWMutex
 	targets           map[string]*target.MTR
+	unresolved        unresolvedHosts
+	missing           missingFamilies
+	spread            spread
 	mtx               sync.RWMutex
 }
 
 // NewMTR creates and configures a new Monitoring MTR instance
-func NewMTR(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, icmpID *common.IcmpID, ipv6 bool, maxConcurrentJobs int) *MTR {
+func NewMTR(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, icmpID *common.IcmpID, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *MTR {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
-	return &MTR{
+	var names *hopNames
+	if sc.Cfg.MTR.LookupHopNames {
+		names = newHopNames(logger, resolver, sc.Cfg.MTR.HopNamesTTL.Duration())
+	}
+	p := &MTR{
 		logger:            logger,
 		sc:                sc,
 		resolver:          resolver,
 		icmpID:            icmpID,
 		interval:          sc.Cfg.MTR.Interval.Duration(),
 		timeout:           sc.Cfg.MTR.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.MTR.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.MTR.Adaptive),
 		maxHops:           sc.Cfg.MTR.MaxHops,
 		count:             sc.Cfg.MTR.Count,
+		packetInterval:    sc.Cfg.MTR.PacketInterval.Duration(),
+		parallelHops:      sc.Cfg.MTR.ParallelHops,
 		payloadSize:       sc.Cfg.MTR.PayloadSize,
+		tos:               sc.Cfg.MTR.Tos,
 		protocol:          sc.Cfg.MTR.Protocol,
 		tcpPort:           sc.Cfg.MTR.TcpPort,
+		udpPort:           sc.Cfg.MTR.UdpPort,
+		pathChangeRounds:  sc.Cfg.MTR.PathChangeRounds,
 		ipv6:              ipv6,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		hopNames:          names,
 		targets:           make(map[string]*target.MTR),
 	}
+	p.ApplyGeoIP()
+	return p
+}
+
+// ApplyGeoIP opens the mtr.geoip_asn_db and mtr.geoip_city_db databases again so their updates are picked up, the previous ones are kept when they can not be opened
+func (p *MTR) ApplyGeoIP() {
+	asnPath, cityPath := p.sc.Cfg.MTR.GeoIPASNDB, p.sc.Cfg.MTR.GeoIPCityDB
+	var db *geoip.DB
+	if asnPath != "" || cityPath != "" {
+		var err error
+		if db, err = geoip.Open(asnPath, cityPath); err != nil {
+			p.logger.Error("Opening GeoIP databases", "type", "MTR", "func", "ApplyGeoIP", "asn", asnPath, "city", cityPath, "err", err)
+			return
+		}
+		p.logger.Info("Opened GeoIP databases", "type", "MTR", "func", "ApplyGeoIP", "asn", asnPath, "city", cityPath)
+	}
+
+	p.geoipMtx.Lock()
+	previous := p.geoip
+	p.geoip = db
+	p.geoipMtx.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
 }
 
 // Stop brings the monitoring gracefully to a halt
@@ -77,49 +135,106 @@ func (p *MTR) AddTargets() {
 	}
 
 	targetConfigTmp := []string{}
+	unresolved := map[string]struct{}{}
+	missing := []AddressFamily{}
+	// The targets resolving all their addresses are monitored per address, keyed by name and IP like the ICMP targets
+	resolved := map[string]string{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "MTR" || v.Type == "ICMP+MTR" {
-			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
+		if v.Type.MTR() {
+			if v.Resolve != "all" {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
+				continue
+			}
+			ipAddrs, err := p.addresses(v.Host, v.IPFamily(p.sc.Cfg.IPVersion))
+			if errors.Is(err, common.ErrNoAddressFamily) {
+				p.logger.Error("Skipping target without an address of its ip_version", "type", "MTR", "func", "AddTargets", "name", v.Name, "host", v.Host, "err", err)
+				missing = append(missing, AddressFamily{Name: v.Name, Host: v.Host, IPVersion: v.IPFamily(p.sc.Cfg.IPVersion)})
+			} else if err != nil {
+				p.logger.Warn("Skipping resolve target", "type", "MTR", "func", "AddTargets", "host", v.Host, "err", err)
+				host, _ := p.splitHost(v.Host, "")
+				unresolved[host] = struct{}{}
+			}
+			for _, ipAddr := range ipAddrs {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+				resolved[v.Name+" "+ipAddr] = ipAddr
+			}
 		}
 	}
 
 	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose labels, source address, interval, timeout, max hops, count, payload size, tos, protocol or port changed, or whose address is not of their ip_version any more, are restarted
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			_, port := p.splitHost(v.Host, v.MTRProtocol(p.protocol))
+			if v.Type.MTR() && mtrKey(v, t.Host()) == key && (relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || t.MaxHops() != override(p.maxHops, v.MaxHops) || t.Count() != override(p.count, v.MtrCount) || t.PayloadSize() != v.Payload(p.payloadSize) || t.Tos() != v.TypeOfService(p.tos) || t.Protocol() != v.MTRProtocol(p.protocol) || t.Port() != port || !familyMatches(t.Host(), v.IPFamily(p.sc.Cfg.IPVersion))) {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
 	p.logger.Debug("Target names to add", "type", "MTR", "func", "AddTargets", "targets", targetAdd)
 
+	startups := []startup{}
 	for _, targetName := range targetAdd {
 		for _, target := range p.sc.Cfg.Targets {
-			if target.Name != targetName {
+			if !target.Type.MTR() {
 				continue
 			}
 
-			if target.Type == "MTR" || target.Type == "ICMP+MTR" {
-				// Add jitter to prevent thundering herd (0-10% of interval)
-				jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-				err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Labels.Kv, jitter)
-				if err != nil {
-					p.logger.Warn("Skipping target", "type", "MTR", "func", "AddTargets", "host", target.Host, "err", err)
+			interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+			if ipAddr, ok := resolved[targetName]; ok {
+				if target.Resolve != "all" || mtrKey(target, ipAddr) != targetName {
+					continue
 				}
+				startups = append(startups, startup{key: targetName, interval: interval, add: func(startupDelay time.Duration) error {
+					_, port := p.splitHost(target.Host, target.MTRProtocol(p.protocol))
+					err := p.addTarget(targetName, target.Host, ipAddr, port, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration(), target.MaxHops, target.MtrCount, target.Payload(p.payloadSize), target.TypeOfService(p.tos), target.MTRProtocol(p.protocol))
+					if err != nil {
+						p.logger.Warn("Skipping target", "type", "MTR", "func", "AddTargets", "host", target.Host, "ip", ipAddr, "err", err)
+					}
+					return err
+				}})
+				continue
 			}
+
+			if target.Name != targetName || target.Resolve == "all" {
+				continue
+			}
+			startups = append(startups, startup{key: target.Name, interval: interval, add: func(startupDelay time.Duration) error {
+				err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration(), target.MaxHops, target.MtrCount, target.Payload(p.payloadSize), target.TypeOfService(p.tos), target.MTRProtocol(p.protocol), target.IPFamily(p.sc.Cfg.IPVersion))
+				if errors.Is(err, common.ErrNoAddressFamily) {
+					p.logger.Error("Skipping target without an address of its ip_version", "type", "MTR", "func", "AddTargets", "name", target.Name, "host", target.Host, "err", err)
+					missing = append(missing, AddressFamily{Name: target.Name, Host: target.Host, IPVersion: target.IPFamily(p.sc.Cfg.IPVersion)})
+					// The target probing an address of the previous ip_version is stopped
+					p.RemoveTarget(target.Name)
+				} else if err != nil {
+					p.logger.Warn("Skipping target", "type", "MTR", "func", "AddTargets", "host", target.Host, "err", err)
+					if errors.Is(err, errUnresolved) {
+						host, _ := p.splitHost(target.Host, "")
+						unresolved[host] = struct{}{}
+					}
+				}
+				return err
+			}})
 		}
 	}
+	p.spread.start(p.logger, "MTR", p.sc.Cfg.SpreadProbes, startups)
+	p.unresolved.set(unresolved)
+	p.missing.set(missing)
 }
 
-// AddTarget adds a target to the monitored list
-func (p *MTR) AddTarget(name string, host string, srcAddr string, labels map[string]string) (err error) {
-	return p.AddTargetDelayed(name, host, srcAddr, labels, 0)
+// Unresolved returns the hosts of the configured targets that could not be resolved
+func (p *MTR) Unresolved() []string {
+	return p.unresolved.list()
 }
 
-// AddTargetDelayed is AddTarget with a startup delay
-func (p *MTR) AddTargetDelayed(name string, host string, srcAddr string, labels map[string]string, startupDelay time.Duration) (err error) {
-	p.logger.Info("Adding Target", "type", "MTR", "func", "AddTargetDelayed", "name", name, "host", host, "delay", startupDelay)
-
-	p.mtx.Lock()
-	defer p.mtx.Unlock()
-
-	// Parse port from host if specified (for TCP protocol)
+// splitHost returns the host to resolve and the port of the TCP probes or the base port of the UDP probes, the port is ignored by the ICMP probes
+func (p *MTR) splitHost(host string, protocol string) (string, string) {
 	targetHost := host
 	targetPort := p.tcpPort // Use default port from config
-	if p.protocol == "tcp" && strings.Contains(host, ":") {
+	if protocol == "udp" {
+		targetPort = p.udpPort
+	}
+	if strings.Contains(host, ":") {
 		// Extract port from host string (e.g., "example.com:443")
 		parts := strings.Split(host, ":")
 		if len(parts) == 2 {
@@ -127,14 +242,36 @@ func (p *MTR) AddTargetDelayed(name string, host string, srcAddr string, labels
 			targetPort = parts[1]
 		}
 	}
+	return targetHost, targetPort
+}
+
+// AddTarget adds a target to the monitored list
+func (p *MTR) AddTarget(name string, host string, srcAddr string, labels map[string]string) (err error) {
+	return p.AddTargetDelayed(name, host, srcAddr, "", labels, 0, 0, 0, 0, 0, p.payloadSize, p.tos, p.protocol, p.sc.Cfg.IPVersion)
+}
+
+// AddTargetDelayed is AddTarget with a startup delay, interval, timeout, max hops and count overrides (0 uses the ones of the check type), the payload size, tos, protocol and IP version of the address
+func (p *MTR) AddTargetDelayed(name string, host string, srcAddr string, srcInterface string, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration, maxHops int, count int, payloadSize int, tos int, protocol string, ipVersion int) (err error) {
+	// Parse port from host if specified (for the TCP and UDP protocols)
+	_, targetPort := p.splitHost(host, protocol)
 
 	// Resolve hostnames
-	ipAddrs, err := common.DestAddrs(context.Background(), targetHost, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
-	if err != nil || len(ipAddrs) == 0 {
+	ipAddrs, err := p.addresses(host, ipVersion)
+	if err != nil {
 		return err
 	}
+	return p.addTarget(name, host, ipAddrs[0], targetPort, srcAddr, srcInterface, labels, startupDelay, interval, timeout, maxHops, count, payloadSize, tos, protocol)
+}
+
+// addTarget starts monitoring a resolved address of a target
+func (p *MTR) addTarget(name string, host string, ip string, port string, srcAddr string, srcInterface string, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration, maxHops int, count int, payloadSize int, tos int, protocol string) (err error) {
+	p.logger.Info("Adding Target", "type", "MTR", "func", "AddTargetDelayed", "name", name, "host", host, "ip", ip, "protocol", protocol, "delay", startupDelay)
 
-	target, err := target.NewMTR(p.logger, p.icmpID, startupDelay, name, ipAddrs[0], srcAddr, p.interval, p.timeout, p.maxHops, p.count, p.payloadSize, p.protocol, targetPort, labels, p.ipv6, p.maxConcurrentJobs)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewMTR(p.logger, p.icmpID, startupDelay, name, ip, srcAddr, srcInterface, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, override(p.maxHops, maxHops), override(p.count, count), p.packetInterval, p.parallelHops, payloadSize, tos, protocol, port, p.pathChangeRounds, labels, common.IPVersion(ip) == 6, p.maxConcurrentJobs, p.hub, p.sched)
 	if err != nil {
 		return err
 	}
@@ -143,6 +280,30 @@ func (p *MTR) AddTargetDelayed(name string, host string, srcAddr string, labels
 	return nil
 }
 
+// addresses resolves the host of a target, keeping the addresses of the IP version (0 both)
+func (p *MTR) addresses(host string, ipVersion int) ([]string, error) {
+	targetHost, _ := p.splitHost(host, "")
+	ipAddrs, err := common.DestAddrs(context.Background(), targetHost, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, ipVersion)
+	if errors.Is(err, common.ErrNoAddressFamily) {
+		return nil, err
+	}
+	if err != nil || len(ipAddrs) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no usable address")
+		}
+		return nil, fmt.Errorf("%w: %s: %v", errUnresolved, targetHost, err)
+	}
+	return ipAddrs, nil
+}
+
+// mtrKey returns the monitor key of a target, suffixed with the address when all the addresses of the host are monitored
+func mtrKey(t config.Target, ip string) string {
+	if t.Resolve == "all" {
+		return t.Name + " " + ip
+	}
+	return t.Name
+}
+
 // DelTargets deletes/stops the removed targets from the configuration
 func (p *MTR) DelTargets() {
 	p.logger.Debug("Current Targets", "type", "MTR", "func", "DelTargets", "count", len(p.targets), "configured", countTargets(p.sc, "MTR"))
@@ -156,8 +317,18 @@ func (p *MTR) DelTargets() {
 
 	targetConfigTmp := []string{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "MTR" || v.Type == "ICMP+MTR" {
-			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
+		if v.Type.MTR() {
+			if v.Resolve != "all" {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name)
+				continue
+			}
+			ipAddrs, err := p.addresses(v.Host, v.IPFamily(p.sc.Cfg.IPVersion))
+			if err != nil {
+				p.logger.Warn("Skipping resolve target", "type", "MTR", "func", "DelTargets", "host", v.Host, "err", err)
+			}
+			for _, ipAddr := range ipAddrs {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+			}
 		}
 	}
 
@@ -190,6 +361,7 @@ func (p *MTR) removeTarget(key string) {
 	}
 	target.Stop()
 	delete(p.targets, key)
+	p.spread.remove(key)
 }
 
 // Read target if IP was changed (DNS record)
@@ -206,25 +378,40 @@ func (p *MTR) CheckActiveTargets() (err error) {
 			if target.Name != targetName {
 				continue
 			}
-			ipAddrs, err := common.DestAddrs(context.Background(), target.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+			host, _ := p.splitHost(target.Host, "")
+			ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
 			if err != nil || len(ipAddrs) == 0 {
 				return err
 			}
 
 			if !common.ContainsString(ipAddrs, targetIp) {
 				p.RemoveTarget(targetName)
-				// Add jitter to prevent thundering herd (0-10% of interval)
-				jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-				err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Labels.Kv, jitter)
-				if err != nil {
-					p.logger.Warn("Skipping target", "type", "MTR", "func", "CheckActiveTargets", "host", target.Host, "err", err)
-				}
+				interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+				p.spread.start(p.logger, "MTR", p.sc.Cfg.SpreadProbes, []startup{{key: target.Name, interval: interval, add: func(startupDelay time.Duration) error {
+					err := p.AddTargetDelayed(target.Name, target.Host, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration(), target.MaxHops, target.MtrCount, target.Payload(p.payloadSize), target.TypeOfService(p.tos), target.MTRProtocol(p.protocol), target.IPFamily(p.sc.Cfg.IPVersion))
+					if err != nil {
+						p.logger.Warn("Skipping target", "type", "MTR", "func", "CheckActiveTargets", "host", target.Host, "err", err)
+					}
+					return err
+				}}})
 			}
 		}
 	}
 	return nil
 }
 
+// AddressFamilies returns the IP version of the address of every target and the targets without an address of their ip_version
+func (p *MTR) AddressFamilies() []AddressFamily {
+	p.mtx.RLock()
+	families := make([]AddressFamily, 0, len(p.targets))
+	for _, t := range p.targets {
+		ip := t.Host()
+		families = append(families, AddressFamily{Name: strings.TrimSuffix(t.Name(), " "+ip), Host: t.Host(), IP: ip, IPVersion: common.IPVersion(ip)})
+	}
+	p.mtx.RUnlock()
+	return append(families, p.missing.list()...)
+}
+
 // ExportMetrics collects the metrics for each monitored target and returns it as a simple map
 func (p *MTR) ExportMetrics() map[string]*mtr.MtrResult {
 	m := make(map[string]*mtr.MtrResult)
@@ -244,6 +431,32 @@ func (p *MTR) ExportMetrics() map[string]*mtr.MtrResult {
 	return m
 }
 
+// HopInfo name, AS number and country of a hop address
+type HopInfo struct {
+	Name    string
+	ASN     string
+	Country string
+}
+
+// HopInfo returns the name (mtr.lookup_hop_names) and the AS number and country (mtr.geoip_asn_db, mtr.geoip_city_db) of a hop address, false when none of them is enabled or the hop did not answer (unknown)
+// The name is the address while it is looked up, without name or without lookups, the AS number and country are empty when unknown
+func (p *MTR) HopInfo(ip string) (HopInfo, bool) {
+	p.geoipMtx.RLock()
+	defer p.geoipMtx.RUnlock()
+
+	if net.ParseIP(ip) == nil || (p.hopNames == nil && p.geoip == nil) {
+		return HopInfo{}, false
+	}
+	info := HopInfo{Name: ip}
+	if p.hopNames != nil {
+		info.Name = p.hopNames.name(ip)
+	}
+	if p.geoip != nil {
+		info.ASN, info.Country = p.geoip.Lookup(ip)
+	}
+	return info, true
+}
+
 // ExportLabels target labels
 func (p *MTR) ExportLabels() map[string]map[string]string {
 	l := make(map[string]map[string]string)
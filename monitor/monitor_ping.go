@@ -2,8 +2,8 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"log/slog"
-	"math/rand"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +12,8 @@ import (
 	"github.com/syepes/network_exporter/config"
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/ping"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 	"github.com/syepes/network_exporter/target"
 )
 
@@ -23,16 +25,27 @@ type PING struct {
 	icmpID            *common.IcmpID
 	interval          time.Duration
 	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
 	count             int
+	packetInterval    time.Duration
 	payloadSize       int
+	tos               int
+	hopLimit          int
+	mode              string
 	ipv6              bool
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
 	targets           map[string]*target.PING
+	unresolved        unresolvedHosts
+	missing           missingFamilies
+	spread            spread
 	mtx               sync.RWMutex
 }
 
 // NewPing creates and configures a new Monitoring ICMP instance
-func NewPing(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, icmpID *common.IcmpID, ipv6 bool, maxConcurrentJobs int) *PING {
+func NewPing(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, icmpID *common.IcmpID, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *PING {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
@@ -43,10 +56,18 @@ func NewPing(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolv
 		icmpID:            icmpID,
 		interval:          sc.Cfg.ICMP.Interval.Duration(),
 		timeout:           sc.Cfg.ICMP.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.ICMP.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.ICMP.Adaptive),
 		count:             sc.Cfg.ICMP.Count,
+		packetInterval:    sc.Cfg.ICMP.PacketInterval.Duration(),
 		payloadSize:       sc.Cfg.ICMP.PayloadSize,
+		tos:               sc.Cfg.ICMP.Tos,
+		hopLimit:          sc.Cfg.ICMP.IPv6HopLimit,
+		mode:              sc.Cfg.ICMP.Mode,
 		ipv6:              ipv6,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		targets:           make(map[string]*target.PING),
 	}
 }
@@ -71,25 +92,42 @@ func (p *PING) AddTargets() {
 	}
 
 	targetConfigTmp := []string{}
+	unresolved := map[string]struct{}{}
+	missing := []AddressFamily{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "ICMP" || v.Type == "ICMP+MTR" {
-			ipAddrs, err := common.DestAddrs(context.Background(), v.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
-			if err != nil || len(ipAddrs) == 0 {
+		if v.Type.ICMP() {
+			ipAddrs, err := common.DestAddrs(context.Background(), v.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+			if errors.Is(err, common.ErrNoAddressFamily) {
+				p.logger.Error("Skipping target without an address of its ip_version", "type", "ICMP", "func", "AddTargets", "name", v.Name, "host", v.Host, "err", err)
+				missing = append(missing, AddressFamily{Name: v.Name, Host: v.Host, IPVersion: v.IPFamily(p.sc.Cfg.IPVersion)})
+			} else if err != nil || len(ipAddrs) == 0 {
 				p.logger.Warn("Skipping resolve target", "type", "ICMP", "func", "AddTargets", "host", v.Host, "err", err)
+				unresolved[v.Host] = struct{}{}
 			}
 			for _, ipAddr := range ipAddrs {
 				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
 			}
 		}
 	}
+	p.unresolved.set(unresolved)
+	p.missing.set(missing)
 
 	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose host, labels, source address, interval, timeout, count, payload size, tos, hop limit or mode changed are restarted, a new host resolved to the same address included
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			if v.Type.ICMP() && v.Name+" "+t.Ip() == key && (t.Host() != v.Host || relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || t.Count() != override(p.count, v.Count) || t.PayloadSize() != v.Payload(p.payloadSize) || t.Tos() != v.TypeOfService(p.tos) || t.HopLimit() != v.HopLimit(p.hopLimit) || t.Mode() != v.ICMPMode(p.mode)) {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
 	p.logger.Debug("Target names to add", "type", "ICMP", "func", "AddTargets", "targets", targetAdd)
 
+	startups := []startup{}
 	for _, targetName := range targetAdd {
 		for _, target := range p.sc.Cfg.Targets {
-			if target.Type == "ICMP" || target.Type == "ICMP+MTR" {
-				ipAddrs, err := common.DestAddrs(context.Background(), target.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+			if target.Type.ICMP() {
+				ipAddrs, err := common.DestAddrs(context.Background(), target.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
 				if err != nil || len(ipAddrs) == 0 {
 					p.logger.Warn("Skipping resolve target", "type", "ICMP", "func", "AddTargets", "host", target.Host, "err", err)
 				}
@@ -98,31 +136,45 @@ func (p *PING) AddTargets() {
 					if target.Name+" "+ipAddr != targetName {
 						continue
 					}
-					// Add jitter to prevent thundering herd (0-10% of interval)
-					jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-					err := p.AddTargetDelayed(target.Name+" "+ipAddr, target.Host, ipAddr, target.SourceIp, target.Labels.Kv, jitter)
-					if err != nil {
-						p.logger.Warn("Skipping target", "type", "ICMP", "func", "AddTargets", "host", target.Host, "ip", ipAddr, "err", err)
-					}
+					startups = append(startups, p.startup(target, ipAddr, "AddTargets"))
 				}
 			}
 		}
 	}
+	p.spread.start(p.logger, "ICMP", p.sc.Cfg.SpreadProbes, startups)
+}
+
+// startup returns the startup of the target of an address
+func (p *PING) startup(target config.Target, ipAddr string, caller string) startup {
+	interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+	return startup{key: target.Name + " " + ipAddr, interval: interval, add: func(startupDelay time.Duration) error {
+		err := p.AddTargetDelayed(target.Name+" "+ipAddr, target.Host, ipAddr, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration(), target.Count, target.Payload(p.payloadSize), target.TypeOfService(p.tos), target.HopLimit(p.hopLimit), target.ICMPMode(p.mode))
+		if err != nil {
+			p.logger.Warn("Skipping target", "type", "ICMP", "func", caller, "host", target.Host, "ip", ipAddr, "err", err)
+		}
+		return err
+	}}
+}
+
+// Unresolved returns the hosts of the configured targets that could not be resolved
+func (p *PING) Unresolved() []string {
+	return p.unresolved.list()
 }
 
 // AddTarget adds a target to the monitored list
 func (p *PING) AddTarget(name string, host string, ip string, srcAddr string, labels map[string]string) (err error) {
-	return p.AddTargetDelayed(name, host, ip, srcAddr, labels, 0)
+	return p.AddTargetDelayed(name, host, ip, srcAddr, "", labels, 0, 0, 0, 0, p.payloadSize, p.tos, p.hopLimit, p.mode)
 }
 
-// AddTargetDelayed is AddTarget with a startup delay
-func (p *PING) AddTargetDelayed(name string, host string, ip string, srcAddr string, labels map[string]string, startupDelay time.Duration) (err error) {
+// AddTargetDelayed is AddTarget with a startup delay, interval, timeout and count overrides (0 uses the ones of the check type), the payload size, tos, IPv6 hop limit and mode
+func (p *PING) AddTargetDelayed(name string, host string, ip string, srcAddr string, srcInterface string, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration, count int, payloadSize int, tos int, hopLimit int, mode string) (err error) {
 	p.logger.Info("Adding Target", "type", "ICMP", "func", "AddTargetDelayed", "name", name, "host", host, "ip", ip, "delay", startupDelay)
 
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	target, err := target.NewPing(p.logger, p.icmpID, startupDelay, name, host, ip, srcAddr, p.interval, p.timeout, p.count, p.payloadSize, labels, p.ipv6, p.maxConcurrentJobs)
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewPing(p.logger, p.icmpID, startupDelay, name, host, ip, srcAddr, srcInterface, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, override(p.count, count), p.packetInterval, payloadSize, tos, hopLimit, mode, labels, common.IPVersion(ip) == 6, p.maxConcurrentJobs, p.hub, p.sched)
 	if err != nil {
 		return err
 	}
@@ -144,8 +196,8 @@ func (p *PING) DelTargets() {
 
 	targetConfigTmp := []string{}
 	for _, v := range p.sc.Cfg.Targets {
-		if v.Type == "ICMP" || v.Type == "ICMP+MTR" {
-			ipAddrs, err := common.DestAddrs(context.Background(), v.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+		if v.Type.ICMP() {
+			ipAddrs, err := common.DestAddrs(context.Background(), v.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
 			if err != nil || len(ipAddrs) == 0 {
 				p.logger.Warn("Skipping resolve target", "type", "ICMP", "func", "DelTargets", "host", v.Host, "err", err)
 			}
@@ -184,6 +236,7 @@ func (p *PING) removeTarget(key string) {
 	}
 	target.Stop()
 	delete(p.targets, key)
+	p.spread.remove(key)
 }
 
 // Read target if IP was changed (DNS record)
@@ -197,13 +250,13 @@ func (p *PING) CheckActiveTargets() (err error) {
 
 	for targetName, targetIp := range targetActiveTmp {
 		for _, target := range p.sc.Cfg.Targets {
-			if target.Type != "ICMP" && target.Type != "ICMP+MTR" {
+			if !target.Type.ICMP() {
 				continue
 			}
 			if !strings.HasPrefix(targetName, target.Name+" ") {
 				continue
 			}
-			ipAddrs, err := common.DestAddrs(context.Background(), target.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+			ipAddrs, err := common.DestAddrs(context.Background(), target.Host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
 			if err != nil || len(ipAddrs) == 0 {
 				return err
 			}
@@ -211,20 +264,29 @@ func (p *PING) CheckActiveTargets() (err error) {
 			if !common.ContainsString(ipAddrs, targetIp) {
 				p.RemoveTarget(targetName)
 
+				startups := []startup{}
 				for _, ipAddr := range ipAddrs {
-					// Add jitter to prevent thundering herd (0-10% of interval)
-					jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-					err := p.AddTargetDelayed(target.Name+" "+ipAddr, target.Host, ipAddr, target.SourceIp, target.Labels.Kv, jitter)
-					if err != nil {
-						p.logger.Warn("Skipping target", "type", "ICMP", "func", "CheckActiveTargets", "host", target.Host, "ip", ipAddr, "err", err)
-					}
+					startups = append(startups, p.startup(target, ipAddr, "CheckActiveTargets"))
 				}
+				p.spread.start(p.logger, "ICMP", p.sc.Cfg.SpreadProbes, startups)
 			}
 		}
 	}
 	return nil
 }
 
+// AddressFamilies returns the IP version of the address of every target and the targets without an address of their ip_version
+func (p *PING) AddressFamilies() []AddressFamily {
+	p.mtx.RLock()
+	families := make([]AddressFamily, 0, len(p.targets))
+	for _, t := range p.targets {
+		ip := t.Ip()
+		families = append(families, AddressFamily{Name: strings.TrimSuffix(t.Name(), " "+ip), Host: t.Host(), IP: ip, IPVersion: common.IPVersion(ip)})
+	}
+	p.mtx.RUnlock()
+	return append(families, p.missing.list()...)
+}
+
 // ExportMetrics collects the metrics for each monitored target and returns it as a simple map
 func (p *PING) ExportMetrics() map[string]*ping.PingResult {
 	m := make(map[string]*ping.PingResult)
@@ -0,0 +1,324 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/target"
+	"golang.org/x/net/icmp"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testConfig loads a config file into a SafeConfig, reload replaces the file and reloads it
+type testConfig struct {
+	t    *testing.T
+	file string
+	sc   *config.SafeConfig
+}
+
+func newTestConfig(t *testing.T, data string) *testConfig {
+	t.Helper()
+	c := &testConfig{t: t, file: filepath.Join(t.TempDir(), "network_exporter.yml"), sc: &config.SafeConfig{Cfg: &config.Config{}, ProbeName: "probe-1"}}
+	c.reload(data)
+	return c
+}
+
+func (c *testConfig) reload(data string) {
+	c.t.Helper()
+	if err := os.WriteFile(c.file, []byte(data), 0o600); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.sc.ReloadConfig(testLogger, c.file, nil); err != nil {
+		c.t.Fatalf("ReloadConfig: %s", err)
+	}
+}
+
+func (c *testConfig) resolver() *config.Resolver {
+	return &config.Resolver{Resolver: net.DefaultResolver, Timeout: time.Second}
+}
+
+// waitFor polls cond until it is true or fails the test after the timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+const pingConfig = `
+icmp:
+  interval: 1s
+  timeout: 500ms
+  count: 2
+targets:
+  - name: loopback
+    host: %s
+    type: ICMP
+    labels:
+      dc: %s
+`
+
+func TestPingReloadKeepsUnchangedTargets(t *testing.T) {
+	// The echo requests need a raw ICMP socket
+	c, err := icmp.ListenPacket("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("raw ICMP socket not permitted: %s", err)
+	}
+	c.Close()
+
+	cfg := newTestConfig(t, fmt.Sprintf(pingConfig, "127.0.0.1", "home"))
+	p := NewPing(testLogger, cfg.sc, cfg.resolver(), &common.IcmpID{}, false, 3, results.NewHub(), nil)
+	defer p.Stop()
+	reconcile := func() {
+		p.DelTargets()
+		_ = p.CheckActiveTargets()
+		p.AddTargets()
+	}
+
+	const key = "loopback 127.0.0.1"
+	reconcile()
+	waitFor(t, 10*time.Second, "the first probe rounds", func() bool {
+		r := p.ExportMetrics()[key]
+		return r != nil && r.SntSummary >= 4
+	})
+	p.mtx.RLock()
+	running := p.targets[key]
+	p.mtx.RUnlock()
+	before := p.ExportMetrics()[key]
+
+	// An identical config keeps the running target and its counters
+	cfg.reload(fmt.Sprintf(pingConfig, "127.0.0.1", "home"))
+	reconcile()
+	p.mtx.RLock()
+	kept := p.targets[key]
+	p.mtx.RUnlock()
+	if kept != running {
+		t.Fatalf("identical reload restarted the target")
+	}
+	if after := p.ExportMetrics()[key]; after.SntSummary < before.SntSummary || after.SntFailSummary < before.SntFailSummary || after.SntTimeSummary < before.SntTimeSummary {
+		t.Errorf("identical reload reset the counters: %+v, before %+v", after, before)
+	}
+	waitFor(t, 10*time.Second, "the counters to keep growing", func() bool {
+		return p.ExportMetrics()[key].SntSummary > before.SntSummary
+	})
+
+	// A new host resolved to the same address restarts the target
+	cfg.reload(fmt.Sprintf(pingConfig, "localhost", "home"))
+	reconcile()
+	p.mtx.RLock()
+	renamed := p.targets[key]
+	p.mtx.RUnlock()
+	if renamed == nil || renamed == running || renamed.Host() != "localhost" {
+		t.Errorf("host change not applied: %v", renamed)
+	}
+
+	// New labels restart the target
+	cfg.reload(fmt.Sprintf(pingConfig, "localhost", "lab"))
+	reconcile()
+	p.mtx.RLock()
+	relabeledTarget := p.targets[key]
+	p.mtx.RUnlock()
+	if relabeledTarget == nil || relabeledTarget == renamed || relabeledTarget.Labels()["dc"] != "lab" {
+		t.Errorf("labels change not applied: %v", relabeledTarget)
+	}
+}
+
+const tcpConfig = `
+tcp:
+  interval: 1s
+  timeout: 500ms
+targets:
+  - name: listener
+    host: %s
+    type: TCP
+`
+
+func TestTCPReloadKeepsUnchangedTargets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	cfg := newTestConfig(t, fmt.Sprintf(tcpConfig, "127.0.0.1:"+port))
+	p := NewTCPPort(testLogger, cfg.sc, cfg.resolver(), false, 3, results.NewHub(), nil)
+	defer p.Stop()
+	reconcile := func() {
+		p.DelTargets()
+		_ = p.CheckActiveTargets()
+		p.AddTargets()
+	}
+
+	const key = "listener 127.0.0.1"
+	reconcile()
+	waitFor(t, 10*time.Second, "the first probe", func() bool {
+		r := p.ExportMetrics()[key]
+		return r != nil && r.Success
+	})
+	p.mtx.RLock()
+	running := p.targets[key]
+	p.mtx.RUnlock()
+
+	cfg.reload(fmt.Sprintf(tcpConfig, "127.0.0.1:"+port))
+	reconcile()
+	p.mtx.RLock()
+	kept := p.targets[key]
+	p.mtx.RUnlock()
+	if kept != running {
+		t.Fatalf("identical reload restarted the target")
+	}
+
+	// Same name and address, the host and port changed
+	cfg.reload(fmt.Sprintf(tcpConfig, "localhost:"+port))
+	reconcile()
+	p.mtx.RLock()
+	renamed := p.targets[key]
+	p.mtx.RUnlock()
+	if renamed == nil || renamed == running || renamed.Host() != "localhost" {
+		t.Errorf("host change not applied: %v", renamed)
+	}
+}
+
+const tcpSourceConfig = `
+tcp:
+  interval: 1s
+  timeout: 500ms
+targets:
+  - name: listener
+    host: 127.0.0.1:%s
+    type: TCP
+    %s
+`
+
+func TestTCPReloadRestartsOnSourceChange(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	cfg := newTestConfig(t, fmt.Sprintf(tcpSourceConfig, port, ""))
+	p := NewTCPPort(testLogger, cfg.sc, cfg.resolver(), false, 3, results.NewHub(), nil)
+	defer p.Stop()
+
+	const key = "listener 127.0.0.1"
+	running := func() *target.TCPPort {
+		p.DelTargets()
+		_ = p.CheckActiveTargets()
+		p.AddTargets()
+		p.mtx.RLock()
+		defer p.mtx.RUnlock()
+		return p.targets[key]
+	}
+
+	steps := []struct {
+		source  string
+		addr    string
+		iface   string
+		restart bool
+	}{
+		{source: "source_ip: 127.0.0.1", addr: "127.0.0.1", restart: true},
+		{source: "source_ip: 127.0.0.1", addr: "127.0.0.1", restart: false},
+		{source: "source_interface: lo", iface: "lo", restart: true},
+		{source: "source_interface: lo", iface: "lo", restart: false},
+		{source: "", restart: true},
+	}
+	previous := running()
+	if previous == nil {
+		t.Fatal("target not started")
+	}
+	for _, step := range steps {
+		cfg.reload(fmt.Sprintf(tcpSourceConfig, port, step.source))
+		current := running()
+		if current == nil {
+			t.Fatalf("%q: target removed", step.source)
+		}
+		if restarted := current != previous; restarted != step.restart {
+			t.Errorf("%q: restarted %v, want %v", step.source, restarted, step.restart)
+		}
+		if addr, iface := current.Source(); addr != step.addr || iface != step.iface {
+			t.Errorf("%q: source %q %q, want %q %q", step.source, addr, iface, step.addr, step.iface)
+		}
+		previous = current
+	}
+}
+
+const httpConfig = `
+http_get:
+  interval: 1s
+  timeout: 500ms
+targets:
+  - name: web
+    host: %s
+    type: HTTPGet
+`
+
+func TestHTTPGetReloadKeepsUnchangedTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := newTestConfig(t, fmt.Sprintf(httpConfig, srv.URL+"/a"))
+	p := NewHTTPGet(testLogger, cfg.sc, cfg.resolver(), 3, results.NewHub(), nil)
+	defer p.Stop()
+	reconcile := func() {
+		p.DelTargets()
+		p.AddTargets()
+	}
+
+	const key = "web"
+	reconcile()
+	waitFor(t, 10*time.Second, "the first probe", func() bool {
+		r := p.ExportMetrics()[key]
+		return r != nil && r.Success
+	})
+	p.mtx.RLock()
+	running := p.targets[key]
+	p.mtx.RUnlock()
+
+	cfg.reload(fmt.Sprintf(httpConfig, srv.URL+"/a"))
+	reconcile()
+	p.mtx.RLock()
+	kept := p.targets[key]
+	p.mtx.RUnlock()
+	if kept != running {
+		t.Fatalf("identical reload restarted the target")
+	}
+
+	cfg.reload(fmt.Sprintf(httpConfig, srv.URL+"/b"))
+	reconcile()
+	p.mtx.RLock()
+	moved := p.targets[key]
+	p.mtx.RUnlock()
+	if moved == nil || moved == running || moved.URL() != srv.URL+"/b" {
+		t.Errorf("URL change not applied: %v", moved)
+	}
+}
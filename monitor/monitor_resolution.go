@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+const (
+	resolveMinBackoff = time.Second
+	resolveMaxBackoff = 5 * time.Minute
+)
+
+// Unresolver monitor reporting the target hosts it could not resolve
+type Unresolver interface {
+	Unresolved() []string
+}
+
+// Resolution retries the target hosts and SRV records that could not be resolved, typically when the nameserver is not reachable at startup
+// The targets of the resolved hosts are added without waiting for the next config refresh
+type Resolution struct {
+	logger   *slog.Logger
+	sc       *config.SafeConfig
+	resolver *config.Resolver
+	ipv6     bool
+	retry    func(reloadConfig bool)
+	monitors []Unresolver
+	pending  atomic.Int64
+	ready    atomic.Bool
+}
+
+// NewResolution creates the resolution retries of the monitors, retry adds the resolved targets and reloads the config when its SRV records resolved
+func NewResolution(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, ipv6 bool, retry func(reloadConfig bool), monitors ...Unresolver) *Resolution {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &Resolution{
+		logger:   logger,
+		sc:       sc,
+		resolver: resolver,
+		ipv6:     ipv6,
+		retry:    retry,
+		monitors: monitors,
+	}
+}
+
+// Run retries the unresolved hosts with an exponential backoff until the context is canceled
+func (r *Resolution) Run(ctx context.Context) {
+	r.ready.Store(true)
+
+	backoff := resolveMinBackoff
+	wait := resolveMinBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		hosts, records := r.unresolved()
+		pending := len(hosts) + len(records)
+		r.pending.Store(int64(pending))
+		if pending == 0 {
+			backoff, wait = resolveMinBackoff, resolveMinBackoff
+			continue
+		}
+
+		resolved, reloadConfig := 0, false
+		for _, h := range hosts {
+			if ipAddrs, err := common.DestAddrs(ctx, h, r.resolver.Resolver, r.resolver.Timeout, r.ipv6, 0); err == nil && len(ipAddrs) > 0 {
+				resolved++
+			}
+		}
+		for _, record := range records {
+			if _, err := common.SrvRecordHosts(record); err == nil {
+				resolved++
+				reloadConfig = true
+			}
+		}
+
+		if resolved > 0 {
+			r.logger.Info("Pending targets resolved", "type", "Resolution", "func", "Run", "resolved", resolved, "pending", pending, "reload_config", reloadConfig)
+			r.retry(reloadConfig)
+			backoff = resolveMinBackoff
+		} else {
+			r.logger.Warn("Targets pending resolution", "type", "Resolution", "func", "Run", "hosts", len(hosts), "srv_records", len(records), "retry", backoff)
+			if backoff *= 2; backoff > resolveMaxBackoff {
+				backoff = resolveMaxBackoff
+			}
+		}
+		wait = backoff
+	}
+}
+
+// Pending returns the hosts and SRV records waiting for a successful resolution
+func (r *Resolution) Pending() int {
+	return int(r.pending.Load())
+}
+
+// Ready reports if the retries are running
+func (r *Resolution) Ready() bool {
+	return r.ready.Load()
+}
+
+// unresolved returns the hosts not resolved by the monitors and the SRV records of the config file that failed
+func (r *Resolution) unresolved() ([]string, []string) {
+	seen := map[string]bool{}
+	hosts := []string{}
+	for _, m := range r.monitors {
+		for _, h := range m.Unresolved() {
+			if !seen[h] {
+				seen[h] = true
+				hosts = append(hosts, h)
+			}
+		}
+	}
+
+	// The discovered SRV records are expanded again on the next refresh of their source
+	records := []string{}
+	for _, rec := range r.sc.SRVRecords() {
+		if rec.Source == "config" && rec.LastError != "" && !seen[rec.Record] {
+			seen[rec.Record] = true
+			records = append(records, rec.Record)
+		}
+	}
+	return hosts, records
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"log/slog"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// startup target waiting for its startup delay, add starts it
+type startup struct {
+	key      string
+	interval time.Duration
+	add      func(startupDelay time.Duration) error
+}
+
+// phase offset of the first probe of a target within its interval, counted from the Unix epoch so the offsets of the targets added at different times can be compared
+type phase struct {
+	interval time.Duration
+	offset   time.Duration
+}
+
+// spread places the first probe of the targets of a monitor within their interval
+type spread struct {
+	mtx    sync.Mutex
+	phases map[string]phase
+}
+
+// start adds the targets with their startup delay
+// With conf.spread_probes the targets of an interval without running targets are spread uniformly (i * interval / n) and the targets added later are placed in the middle of the largest gap between the running ones (the least loaded phase),
+// otherwise the delay is random within 10% of the interval to prevent a thundering herd
+func (s *spread) start(logger *slog.Logger, checkType string, enabled bool, targets []startup) {
+	now := time.Now()
+	delays := make([]time.Duration, len(targets))
+	if enabled {
+		delays = s.place(now, targets)
+	} else {
+		for i, t := range targets {
+			delays[i] = time.Duration(rand.Int63n(int64(t.interval / 10)))
+		}
+	}
+
+	for i, t := range targets {
+		p := phase{interval: t.interval, offset: offset(now.Add(delays[i]), t.interval)}
+		if enabled {
+			logger.Debug("Scheduling target", "type", checkType, "func", "AddTargets", "target", t.key, "interval", t.interval, "delay", delays[i], "offset", p.offset)
+		}
+		if err := t.add(delays[i]); err != nil {
+			continue
+		}
+		// The target is replaced when restarted, its previous phase is removed by add
+		s.mtx.Lock()
+		if s.phases == nil {
+			s.phases = make(map[string]phase)
+		}
+		s.phases[t.key] = p
+		s.mtx.Unlock()
+	}
+}
+
+// place returns the startup delays of the targets within their interval
+func (s *spread) place(now time.Time, targets []startup) []time.Duration {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	restarted := make(map[string]bool, len(targets))
+	batch := map[time.Duration]int{}
+	for _, t := range targets {
+		restarted[t.key] = true
+		batch[t.interval]++
+	}
+	running := map[time.Duration][]time.Duration{}
+	for key, p := range s.phases {
+		if !restarted[key] {
+			running[p.interval] = append(running[p.interval], p.offset)
+		}
+	}
+
+	delays := make([]time.Duration, len(targets))
+	placed := map[time.Duration]int{}
+	for i, t := range targets {
+		if len(running[t.interval]) == 0 {
+			delays[i] = time.Duration(int64(t.interval) * int64(placed[t.interval]) / int64(batch[t.interval]))
+			placed[t.interval]++
+			continue
+		}
+		o := largestGap(running[t.interval], t.interval)
+		running[t.interval] = append(running[t.interval], o)
+		delays[i] = (o - offset(now, t.interval) + t.interval) % t.interval
+	}
+	return delays
+}
+
+// remove forgets the phase of a stopped target
+func (s *spread) remove(key string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.phases, key)
+}
+
+// offset returns the offset of a time within the interval
+func offset(t time.Time, interval time.Duration) time.Duration {
+	return time.Duration(t.UnixNano() % int64(interval))
+}
+
+// largestGap returns the offset in the middle of the largest gap between the offsets of an interval
+func largestGap(offsets []time.Duration, interval time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// The gap wrapping around the end of the interval
+	start, gap := sorted[len(sorted)-1], sorted[0]+interval-sorted[len(sorted)-1]
+	for i := 1; i < len(sorted); i++ {
+		if g := sorted[i] - sorted[i-1]; g > gap {
+			start, gap = sorted[i-1], g
+		}
+	}
+	return (start + gap/2) % interval
+}
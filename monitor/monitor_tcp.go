@@ -1,9 +1,11 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"log/slog"
-	"math/rand"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +14,8 @@ import (
 	"github.com/syepes/network_exporter/config"
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 	"github.com/syepes/network_exporter/target"
 )
 
@@ -22,14 +26,22 @@ type TCPPort struct {
 	resolver          *config.Resolver
 	interval          time.Duration
 	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
+	tos               int
 	ipv6              bool
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
 	targets           map[string]*target.TCPPort
+	unresolved        unresolvedHosts
+	missing           missingFamilies
+	spread            spread
 	mtx               sync.RWMutex
 }
 
 // NewTCPPort creates and configures a new Monitoring TCP instance
-func NewTCPPort(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, ipv6 bool, maxConcurrentJobs int) *TCPPort {
+func NewTCPPort(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *TCPPort {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
@@ -39,8 +51,13 @@ func NewTCPPort(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Res
 		resolver:          resolver,
 		interval:          sc.Cfg.TCP.Interval.Duration(),
 		timeout:           sc.Cfg.TCP.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.TCP.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.TCP.Adaptive),
+		tos:               sc.Cfg.TCP.Tos,
 		ipv6:              ipv6,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		targets:           make(map[string]*target.TCPPort),
 	}
 }
@@ -65,6 +82,8 @@ func (p *TCPPort) AddTargets() {
 	}
 
 	targetConfigTmp := []string{}
+	unresolved := map[string]struct{}{}
+	missing := []AddressFamily{}
 	for _, v := range p.sc.Cfg.Targets {
 		if v.Type == "TCP" {
 			conn := strings.Split(v.Host, ":")
@@ -72,17 +91,31 @@ func (p *TCPPort) AddTargets() {
 				p.logger.Warn("Skipping target, could not identify host", "type", "TCP", "func", "AddTargets", "host", v.Host, "name", v.Name)
 				continue
 			}
-			ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
-			if err != nil || len(ipAddrs) == 0 {
+			ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+			if errors.Is(err, common.ErrNoAddressFamily) {
+				p.logger.Error("Skipping target without an address of its ip_version", "type", "TCP", "func", "AddTargets", "name", v.Name, "host", v.Host, "err", err)
+				missing = append(missing, AddressFamily{Name: v.Name, Host: v.Host, IPVersion: v.IPFamily(p.sc.Cfg.IPVersion)})
+			} else if err != nil || len(ipAddrs) == 0 {
 				p.logger.Warn("Skipping resolve target", "type", "TCP", "func", "AddTargets", "host", v.Host, "err", err)
+				unresolved[conn[0]] = struct{}{}
 			}
 			for _, ipAddr := range ipAddrs {
 				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
 			}
 		}
 	}
+	p.unresolved.set(unresolved)
+	p.missing.set(missing)
 
 	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose host, port, labels, source address, interval, timeout, tos, proxy, TLS config or banner check changed are restarted
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			if v.Type == "TCP" && v.Name+" "+t.Ip() == key && (t.Host()+":"+t.Port() != v.Host || relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || t.Tos() != v.TypeOfService(p.tos) || t.Proxy() != v.ProxyURL() || t.TLSID() != tlsID(v) || !sameBanner(t.Banner(), tcpBanner(v))) {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
 	p.logger.Debug("Target names to add", "type", "TCP", "func", "AddTargets", "targets", targetAdd)
 
 	// Build a lookup map to avoid O(n²) complexity
@@ -91,6 +124,7 @@ func (p *TCPPort) AddTargets() {
 		targetLookup[t] = true
 	}
 
+	startups := []startup{}
 	for _, target := range p.sc.Cfg.Targets {
 		if target.Type != "TCP" {
 			continue
@@ -104,7 +138,7 @@ func (p *TCPPort) AddTargets() {
 		}
 
 		// Resolve DNS once per target
-		ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+		ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
 		if err != nil || len(ipAddrs) == 0 {
 			p.logger.Warn("Skipping resolve target", "type", "TCP", "func", "AddTargets", "name", target.Name, "err", err)
 			continue
@@ -116,29 +150,66 @@ func (p *TCPPort) AddTargets() {
 			if !targetLookup[targetName] {
 				continue
 			}
-			// Add jitter to prevent thundering herd (0-10% of interval)
-			jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-			err := p.AddTargetDelayed(targetName, conn[0], ipAddr, target.SourceIp, conn[1], target.Labels.Kv, jitter)
-			if err != nil {
-				p.logger.Warn("Skipping target", "type", "TCP", "func", "AddTargets", "host", target.Host, "ip", ipAddr, "err", err)
-			}
+			startups = append(startups, p.startup(target, conn[0], conn[1], ipAddr, "AddTargets"))
 		}
 	}
+	p.spread.start(p.logger, "TCP", p.sc.Cfg.SpreadProbes, startups)
+}
+
+// startup returns the startup of the target of an address
+func (p *TCPPort) startup(target config.Target, host string, port string, ipAddr string, caller string) startup {
+	interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+	tlsConfig, tlsID := target.TLSClientConfig()
+	return startup{key: target.Name + " " + ipAddr, interval: interval, add: func(startupDelay time.Duration) error {
+		err := p.AddTargetDelayed(target.Name+" "+ipAddr, host, ipAddr, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), port, target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration(), target.TypeOfService(p.tos), target.ProxyURL(), tlsConfig, tlsID, tcpBanner(target))
+		if err != nil {
+			p.logger.Warn("Skipping target", "type", "TCP", "func", caller, "host", target.Host, "ip", ipAddr, "err", err)
+		}
+		return err
+	}}
+}
+
+// tlsID returns the fingerprint of the TLS config of a target, empty without tls
+func tlsID(t config.Target) string {
+	_, id := t.TLSClientConfig()
+	return id
+}
+
+// tcpBanner returns the banner exchange of a target, nil without expect
+func tcpBanner(t config.Target) *tcp.Banner {
+	if t.TCPExpect() == nil {
+		return nil
+	}
+	return &tcp.Banner{Send: t.TCPSend(), Expect: t.TCPExpect(), ReadLimit: t.ExpectBytes}
+}
+
+// sameBanner reports if two banner exchanges send the same bytes and expect the same regex
+func sameBanner(a *tcp.Banner, b *tcp.Banner) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.Send, b.Send) && a.Expect.String() == b.Expect.String() && a.ReadLimit == b.ReadLimit
+}
+
+// Unresolved returns the hosts of the configured targets that could not be resolved
+func (p *TCPPort) Unresolved() []string {
+	return p.unresolved.list()
 }
 
 // AddTarget adds a target to the monitored list
 func (p *TCPPort) AddTarget(name string, host string, ip string, srcAddr string, port string, labels map[string]string) (err error) {
-	return p.AddTargetDelayed(name, host, ip, srcAddr, port, labels, 0)
+	return p.AddTargetDelayed(name, host, ip, srcAddr, "", port, labels, 0, 0, 0, p.tos, "", nil, "", nil)
 }
 
-// AddTargetDelayed is AddTarget with a startup delay
-func (p *TCPPort) AddTargetDelayed(name string, host string, ip string, srcAddr string, port string, labels map[string]string, startupDelay time.Duration) (err error) {
+// AddTargetDelayed is AddTarget with a startup delay, interval and timeout overrides (0 uses the ones of the check type), the tos, the SOCKS5 proxy (empty without), the TLS config and the banner exchange (nil without)
+func (p *TCPPort) AddTargetDelayed(name string, host string, ip string, srcAddr string, srcInterface string, port string, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration, tos int, proxy string, tlsConfig *tls.Config, tlsID string, banner *tcp.Banner) (err error) {
 	p.logger.Info("Adding Target", "type", "TCP", "func", "AddTargetDelayed", "name", name, "host", host, "ip", ip, "port", port, "delay", startupDelay)
 
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	target, err := target.NewTCPPort(p.logger, startupDelay, name, host, ip, srcAddr, port, p.interval, p.timeout, labels, p.maxConcurrentJobs)
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewTCPPort(p.logger, startupDelay, name, host, ip, srcAddr, srcInterface, port, tos, proxy, tlsConfig, tlsID, banner, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, labels, p.maxConcurrentJobs, p.hub, p.sched)
 	if err != nil {
 		return err
 	}
@@ -166,7 +237,7 @@ func (p *TCPPort) DelTargets() {
 				p.logger.Warn("Skipping target, could not identify host", "type", "TCP", "func", "DelTargets", "host", v.Host, "name", v.Name)
 				continue
 			}
-			ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+			ipAddrs, err := common.DestAddrs(context.Background(), conn[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
 			if err != nil || len(ipAddrs) == 0 {
 				p.logger.Warn("Skipping resolve target", "type", "TCP", "func", "DelTargets", "host", v.Host, "err", err)
 			}
@@ -205,6 +276,7 @@ func (p *TCPPort) removeTarget(key string) {
 	}
 	target.Stop()
 	delete(p.targets, key)
+	p.spread.remove(key)
 }
 
 // Read target if IP was changed (DNS record)
@@ -221,7 +293,7 @@ func (p *TCPPort) CheckActiveTargets() (err error) {
 			if target.Name != targetName {
 				continue
 			}
-			ipAddrs, err := common.DestAddrs(context.Background(), strings.Split(target.Host, ":")[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6)
+			ipAddrs, err := common.DestAddrs(context.Background(), strings.Split(target.Host, ":")[0], p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
 			if err != nil || len(ipAddrs) == 0 {
 				return err
 			}
@@ -234,20 +306,29 @@ func (p *TCPPort) CheckActiveTargets() (err error) {
 					p.logger.Warn("Skipping target, could not identify host", "type", "TCP", "func", "CheckActiveTargets", "host", target.Host, "name", target.Name)
 					continue
 				}
+				startups := []startup{}
 				for _, ipAddr := range ipAddrs {
-					// Add jitter to prevent thundering herd (0-10% of interval)
-					jitter := time.Duration(rand.Int63n(int64(p.interval / 10)))
-					err := p.AddTargetDelayed(target.Name+" "+ipAddr, conn[0], ipAddr, target.SourceIp, conn[1], target.Labels.Kv, jitter)
-					if err != nil {
-						p.logger.Warn("Skipping target", "type", "TCP", "func", "CheckActiveTargets", "host", target.Host, "err", err)
-					}
+					startups = append(startups, p.startup(target, conn[0], conn[1], ipAddr, "CheckActiveTargets"))
 				}
+				p.spread.start(p.logger, "TCP", p.sc.Cfg.SpreadProbes, startups)
 			}
 		}
 	}
 	return nil
 }
 
+// AddressFamilies returns the IP version of the address of every target and the targets without an address of their ip_version
+func (p *TCPPort) AddressFamilies() []AddressFamily {
+	p.mtx.RLock()
+	families := make([]AddressFamily, 0, len(p.targets))
+	for _, t := range p.targets {
+		ip := t.Ip()
+		families = append(families, AddressFamily{Name: strings.TrimSuffix(t.Name(), " "+ip), Host: t.Host(), IP: ip, IPVersion: common.IPVersion(ip)})
+	}
+	p.mtx.RUnlock()
+	return append(families, p.missing.list()...)
+}
+
 // ExportMetrics collects the metrics for each monitored target and returns it as a simple map
 func (p *TCPPort) ExportMetrics() map[string]*tcp.TCPPortReturn {
 	m := make(map[string]*tcp.TCPPortReturn)
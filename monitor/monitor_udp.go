@@ -0,0 +1,322 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/udp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+	"github.com/syepes/network_exporter/target"
+)
+
+// UDPPort manages the goroutines responsible for collecting UDP data
+type UDPPort struct {
+	logger            *slog.Logger
+	sc                *config.SafeConfig
+	resolver          *config.Resolver
+	interval          time.Duration
+	timeout           time.Duration
+	intervalJitter    config.Jitter
+	adaptive          target.Adaptive
+	ipv6              bool
+	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	targets           map[string]*target.UDPPort
+	unresolved        unresolvedHosts
+	missing           missingFamilies
+	spread            spread
+	mtx               sync.RWMutex
+}
+
+// NewUDPPort creates and configures a new Monitoring UDP instance
+func NewUDPPort(logger *slog.Logger, sc *config.SafeConfig, resolver *config.Resolver, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) *UDPPort {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &UDPPort{
+		logger:            logger,
+		sc:                sc,
+		resolver:          resolver,
+		interval:          sc.Cfg.UDP.Interval.Duration(),
+		timeout:           sc.Cfg.UDP.Timeout.Duration(),
+		intervalJitter:    sc.Cfg.UDP.IntervalJitter,
+		adaptive:          adaptive(sc.Cfg.UDP.Adaptive),
+		ipv6:              ipv6,
+		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		targets:           make(map[string]*target.UDPPort),
+	}
+}
+
+// Stop brings the monitoring gracefully to a halt
+func (p *UDPPort) Stop() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for id := range p.targets {
+		p.removeTarget(id)
+	}
+}
+
+// AddTargets adds newly added targets from the configuration
+func (p *UDPPort) AddTargets() {
+	p.logger.Debug("Current Targets", "type", "UDP", "func", "AddTargets", "count", len(p.targets), "configured", countTargets(p.sc, "UDP"))
+
+	targetActiveTmp := []string{}
+	for _, v := range p.targets {
+		targetActiveTmp = common.AppendIfMissing(targetActiveTmp, v.Name())
+	}
+
+	targetConfigTmp := []string{}
+	unresolved := map[string]struct{}{}
+	missing := []AddressFamily{}
+	// Resolve every target once
+	addrs := map[string][]string{}
+	for _, v := range p.sc.Cfg.Targets {
+		if v.Type != "UDP" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(v.Host)
+		if err != nil {
+			p.logger.Warn("Skipping target, could not identify host", "type", "UDP", "func", "AddTargets", "host", v.Host, "name", v.Name)
+			continue
+		}
+		ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+		if errors.Is(err, common.ErrNoAddressFamily) {
+			p.logger.Error("Skipping target without an address of its ip_version", "type", "UDP", "func", "AddTargets", "name", v.Name, "host", v.Host, "err", err)
+			missing = append(missing, AddressFamily{Name: v.Name, Host: v.Host, IPVersion: v.IPFamily(p.sc.Cfg.IPVersion)})
+		} else if err != nil || len(ipAddrs) == 0 {
+			p.logger.Warn("Skipping resolve target", "type", "UDP", "func", "AddTargets", "host", v.Host, "err", err)
+			unresolved[host] = struct{}{}
+		}
+		addrs[v.Name] = ipAddrs
+		for _, ipAddr := range ipAddrs {
+			targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+		}
+	}
+	p.unresolved.set(unresolved)
+	p.missing.set(missing)
+
+	targetAdd := common.CompareList(targetActiveTmp, targetConfigTmp)
+	// The targets whose host, port, labels, source address, interval, timeout, payload or expect_reply changed are restarted
+	for key, t := range p.targets {
+		for _, v := range p.sc.Cfg.Targets {
+			if v.Type != "UDP" || v.Name+" "+t.Ip() != key {
+				continue
+			}
+			host, port, _ := net.SplitHostPort(v.Host)
+			if t.Host() != host || t.Port() != port || relabeled(t, v) || resourced(t, v, p.sc.Cfg.Conf.SourceInterface) || retimed(t, p.interval, p.timeout, v) || string(t.Payload()) != v.UDPPayload || t.ExpectReply() != v.ExpectReply {
+				targetAdd = common.AppendIfMissing(targetAdd, key)
+			}
+		}
+	}
+	p.logger.Debug("Target names to add", "type", "UDP", "func", "AddTargets", "targets", targetAdd)
+
+	targetLookup := make(map[string]bool)
+	for _, t := range targetAdd {
+		targetLookup[t] = true
+	}
+
+	startups := []startup{}
+	for _, target := range p.sc.Cfg.Targets {
+		if target.Type != "UDP" {
+			continue
+		}
+		for _, ipAddr := range addrs[target.Name] {
+			if targetLookup[target.Name+" "+ipAddr] {
+				startups = append(startups, p.startup(target, ipAddr, "AddTargets"))
+			}
+		}
+	}
+	p.spread.start(p.logger, "UDP", p.sc.Cfg.SpreadProbes, startups)
+}
+
+// startup returns the startup of the target of an address
+func (p *UDPPort) startup(target config.Target, ipAddr string, caller string) startup {
+	interval, _ := probeInterval(p.interval, p.timeout, target.Interval.Duration(), target.Timeout.Duration())
+	return startup{key: target.Name + " " + ipAddr, interval: interval, add: func(startupDelay time.Duration) error {
+		host, port, _ := net.SplitHostPort(target.Host)
+		err := p.AddTargetDelayed(target.Name+" "+ipAddr, host, ipAddr, target.SourceIp, target.Interface(p.sc.Cfg.Conf.SourceInterface), port, []byte(target.UDPPayload), target.ExpectReply, target.Labels.Kv, startupDelay, target.Interval.Duration(), target.Timeout.Duration())
+		if err != nil {
+			p.logger.Warn("Skipping target", "type", "UDP", "func", caller, "host", target.Host, "ip", ipAddr, "err", err)
+		}
+		return err
+	}}
+}
+
+// Unresolved returns the hosts of the configured targets that could not be resolved
+func (p *UDPPort) Unresolved() []string {
+	return p.unresolved.list()
+}
+
+// AddTarget adds a target to the monitored list
+func (p *UDPPort) AddTarget(name string, host string, ip string, srcAddr string, port string, payload []byte, expectReply bool, labels map[string]string) (err error) {
+	return p.AddTargetDelayed(name, host, ip, srcAddr, "", port, payload, expectReply, labels, 0, 0, 0)
+}
+
+// AddTargetDelayed is AddTarget with a startup delay, interval and timeout overrides (0 uses the ones of the check type)
+func (p *UDPPort) AddTargetDelayed(name string, host string, ip string, srcAddr string, srcInterface string, port string, payload []byte, expectReply bool, labels map[string]string, startupDelay time.Duration, interval time.Duration, timeout time.Duration) (err error) {
+	p.logger.Info("Adding Target", "type", "UDP", "func", "AddTargetDelayed", "name", name, "host", host, "ip", ip, "port", port, "expect_reply", expectReply, "delay", startupDelay)
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	interval, timeout = probeInterval(p.interval, p.timeout, interval, timeout)
+	target, err := target.NewUDPPort(p.logger, startupDelay, name, host, ip, srcAddr, srcInterface, port, payload, expectReply, interval, p.intervalJitter.Duration(interval), timeout, p.adaptive, labels, p.maxConcurrentJobs, p.hub, p.sched)
+	if err != nil {
+		return err
+	}
+	p.removeTarget(name)
+	p.targets[name] = target
+	return nil
+}
+
+// DelTargets deletes/stops the removed targets from the configuration
+func (p *UDPPort) DelTargets() {
+	p.logger.Debug("Current Targets", "type", "UDP", "func", "DelTargets", "count", len(p.targets), "configured", countTargets(p.sc, "UDP"))
+
+	targetActiveTmp := []string{}
+	for _, v := range p.targets {
+		if v != nil {
+			targetActiveTmp = common.AppendIfMissing(targetActiveTmp, v.Name())
+		}
+	}
+
+	targetConfigTmp := []string{}
+	for _, v := range p.sc.Cfg.Targets {
+		if v.Type == "UDP" {
+			host, _, _ := net.SplitHostPort(v.Host)
+			ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, v.IPFamily(p.sc.Cfg.IPVersion))
+			if err != nil || len(ipAddrs) == 0 {
+				p.logger.Warn("Skipping resolve target", "type", "UDP", "func", "DelTargets", "host", v.Host, "err", err)
+			}
+			for _, ipAddr := range ipAddrs {
+				targetConfigTmp = common.AppendIfMissing(targetConfigTmp, v.Name+" "+ipAddr)
+			}
+		}
+	}
+
+	targetDelete := common.CompareList(targetConfigTmp, targetActiveTmp)
+	for _, targetName := range targetDelete {
+		for _, t := range p.targets {
+			if t == nil {
+				continue
+			}
+			if t.Name() == targetName {
+				p.RemoveTarget(targetName)
+			}
+		}
+	}
+}
+
+// RemoveTarget removes a target from the monitoring list
+func (p *UDPPort) RemoveTarget(key string) {
+	p.logger.Info("Removing Target", "type", "UDP", "func", "RemoveTarget", "target", key)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.removeTarget(key)
+}
+
+// Stops monitoring a target and removes it from the list (if the list includes the target)
+func (p *UDPPort) removeTarget(key string) {
+	target, found := p.targets[key]
+	if !found {
+		return
+	}
+	target.Stop()
+	delete(p.targets, key)
+	p.spread.remove(key)
+}
+
+// Read target if IP was changed (DNS record)
+func (p *UDPPort) CheckActiveTargets() (err error) {
+	p.logger.Debug("Current Targets", "type", "UDP", "func", "CheckActiveTargets", "count", len(p.targets), "configured", countTargets(p.sc, "UDP"))
+
+	targetActiveTmp := make(map[string]string)
+	for _, v := range p.targets {
+		targetActiveTmp[v.Name()] = v.Ip()
+	}
+
+	for targetName, targetIp := range targetActiveTmp {
+		for _, target := range p.sc.Cfg.Targets {
+			if target.Type != "UDP" || targetName != target.Name+" "+targetIp {
+				continue
+			}
+			host, _, _ := net.SplitHostPort(target.Host)
+			ipAddrs, err := common.DestAddrs(context.Background(), host, p.resolver.Resolver, p.resolver.Timeout, p.ipv6, target.IPFamily(p.sc.Cfg.IPVersion))
+			if err != nil || len(ipAddrs) == 0 {
+				return err
+			}
+
+			if !common.ContainsString(ipAddrs, targetIp) {
+				p.RemoveTarget(targetName)
+
+				startups := []startup{}
+				for _, ipAddr := range ipAddrs {
+					startups = append(startups, p.startup(target, ipAddr, "CheckActiveTargets"))
+				}
+				p.spread.start(p.logger, "UDP", p.sc.Cfg.SpreadProbes, startups)
+			}
+		}
+	}
+	return nil
+}
+
+// AddressFamilies returns the IP version of the address of every target and the targets without an address of their ip_version
+func (p *UDPPort) AddressFamilies() []AddressFamily {
+	p.mtx.RLock()
+	families := make([]AddressFamily, 0, len(p.targets))
+	for _, t := range p.targets {
+		ip := t.Ip()
+		families = append(families, AddressFamily{Name: strings.TrimSuffix(t.Name(), " "+ip), Host: t.Host(), IP: ip, IPVersion: common.IPVersion(ip)})
+	}
+	p.mtx.RUnlock()
+	return append(families, p.missing.list()...)
+}
+
+// ExportMetrics collects the metrics for each monitored target and returns it as a simple map
+func (p *UDPPort) ExportMetrics() map[string]*udp.UDPPortReturn {
+	m := make(map[string]*udp.UDPPortReturn)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, target := range p.targets {
+		name := target.Name()
+		metrics := target.Compute()
+
+		if metrics != nil {
+			m[name] = metrics
+		}
+	}
+	return m
+}
+
+// ExportLabels target labels
+func (p *UDPPort) ExportLabels() map[string]map[string]string {
+	l := make(map[string]map[string]string)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for _, target := range p.targets {
+		name := target.Name()
+		labels := target.Labels()
+
+		if labels != nil {
+			l[name] = labels
+		}
+	}
+	return l
+}
@@ -0,0 +1,323 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/syepes/network_exporter/config"
+	"github.com/syepes/network_exporter/discovery"
+	"github.com/syepes/network_exporter/pkg/dns"
+	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/pkg/ntp"
+	"github.com/syepes/network_exporter/pkg/ping"
+	"github.com/syepes/network_exporter/pkg/smtp"
+	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/pkg/udp"
+	"github.com/syepes/network_exporter/results"
+
+	pkghttp "github.com/syepes/network_exporter/pkg/http"
+)
+
+const (
+	// Notifications waiting to be delivered before the new ones are dropped
+	queueSize  = 1024
+	minBackoff = time.Second
+	// The state of the targets without results during this period is forgotten
+	stateExpiry = time.Hour
+)
+
+// States of a target
+const (
+	StateUnknown = "unknown"
+	StateHealthy = "healthy"
+	StateFailing = "failing"
+)
+
+// Payload body posted to the webhook on a state change
+type Payload struct {
+	Target      string                 `json:"target"`
+	Type        string                 `json:"type"`
+	Host        string                 `json:"host"`
+	IP          string                 `json:"ip,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	OldState    string                 `json:"old_state"`
+	NewState    string                 `json:"new_state"`
+	Consecutive int                    `json:"consecutive"`
+	Time        time.Time              `json:"time"`
+	Result      map[string]interface{} `json:"result"`
+}
+
+// targetState debounced health of a target
+type targetState struct {
+	state    string
+	notified string
+	success  bool
+	streak   int
+	lastSent time.Time
+	lastSeen time.Time
+}
+
+// Webhook posts the target state changes derived from the probe results, the delivery never blocks the probes
+type Webhook struct {
+	logger  *slog.Logger
+	hub     *results.Hub
+	cfg     config.Webhook
+	client  *http.Client
+	cfgMtx  sync.RWMutex
+	states  map[string]*targetState
+	queue   chan Payload
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewWebhook creates the webhook notifier of the probe results
+func NewWebhook(logger *slog.Logger, hub *results.Hub) *Webhook {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return &Webhook{
+		logger: logger,
+		hub:    hub,
+		states: make(map[string]*targetState),
+		queue:  make(chan Payload, queueSize),
+	}
+}
+
+// ApplyConfig replaces the webhook settings, the notifications are disabled without url
+func (w *Webhook) ApplyConfig(cfg config.Webhook) error {
+	var client *http.Client
+	if cfg.URL != "" {
+		c, err := discovery.NewHTTPClient(cfg.TLS, cfg.Timeout.Duration())
+		if err != nil {
+			return err
+		}
+		client = c
+	}
+
+	w.cfgMtx.Lock()
+	w.cfg = cfg
+	w.client = client
+	w.cfgMtx.Unlock()
+	return nil
+}
+
+// Run tracks the state of the targets until the context is canceled
+func (w *Webhook) Run(ctx context.Context) {
+	sub := w.hub.Subscribe(results.DefaultBuffer)
+	defer sub.Close()
+
+	go w.deliver(ctx)
+
+	ticker := time.NewTicker(stateExpiry / 6)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-sub.C:
+			w.observe(r)
+		case <-ticker.C:
+			w.prune()
+		}
+	}
+}
+
+// Stats returns the notifications delivered, failed after all the retries and dropped on a full queue
+func (w *Webhook) Stats() (sent uint64, failed uint64, dropped uint64) {
+	return w.sent.Load(), w.failed.Load(), w.dropped.Load()
+}
+
+// observe updates the state of a target, the state changes after min_consecutive results and is notified at most once per cooldown
+func (w *Webhook) observe(r results.Result) {
+	w.cfgMtx.RLock()
+	cfg := w.cfg
+	w.cfgMtx.RUnlock()
+
+	key := r.Type + "/" + r.Name + "/" + r.IP
+	st, ok := w.states[key]
+	if !ok {
+		st = &targetState{state: StateUnknown, notified: StateUnknown}
+		w.states[key] = st
+	}
+	st.lastSeen = time.Now()
+
+	if st.streak > 0 && st.success == r.Success {
+		st.streak++
+	} else {
+		st.success = r.Success
+		st.streak = 1
+	}
+
+	state := StateFailing
+	if r.Success {
+		state = StateHealthy
+	}
+	if state != st.state && st.streak >= cfg.MinConsecutive {
+		w.logger.Debug("Target state changed", "type", "Webhook", "func", "observe", "target", key, "old_state", st.state, "new_state", state, "consecutive", st.streak)
+		// The initial healthy state is not notified
+		if st.state == StateUnknown && state == StateHealthy {
+			st.notified = state
+		}
+		st.state = state
+	}
+
+	// A change suppressed by the cooldown is notified once it expired if the state did not revert
+	if cfg.URL == "" || st.state == st.notified || time.Since(st.lastSent) < cfg.Cooldown.Duration() {
+		return
+	}
+
+	p := Payload{
+		Target:      r.Name,
+		Type:        r.Type,
+		Host:        r.Host,
+		IP:          r.IP,
+		Labels:      r.Labels,
+		OldState:    st.notified,
+		NewState:    st.state,
+		Consecutive: st.streak,
+		Time:        r.Time,
+		Result:      summary(r),
+	}
+	st.notified = st.state
+	st.lastSent = time.Now()
+
+	select {
+	case w.queue <- p:
+	default:
+		w.dropped.Add(1)
+		w.logger.Warn("Notification queue full, dropping", "type", "Webhook", "func", "observe", "target", key, "new_state", p.NewState)
+	}
+}
+
+// prune forgets the state of the removed targets
+func (w *Webhook) prune() {
+	for key, st := range w.states {
+		if time.Since(st.lastSeen) > stateExpiry {
+			delete(w.states, key)
+		}
+	}
+}
+
+// deliver posts the queued notifications in order
+func (w *Webhook) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-w.queue:
+			w.cfgMtx.RLock()
+			cfg, client := w.cfg, w.client
+			w.cfgMtx.RUnlock()
+			if client == nil {
+				continue
+			}
+
+			if err := w.post(ctx, cfg, client, p); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				w.failed.Add(1)
+				w.logger.Error("Delivering notification", "type", "Webhook", "func", "deliver", "url", cfg.URL, "target", p.Target, "new_state", p.NewState, "err", err)
+				continue
+			}
+			w.sent.Add(1)
+			w.logger.Info("Notification delivered", "type", "Webhook", "func", "deliver", "target", p.Target, "check_type", p.Type, "old_state", p.OldState, "new_state", p.NewState)
+		}
+	}
+}
+
+// post sends a notification, retrying with backoff on connection errors and non 2xx responses
+func (w *Webhook) post(ctx context.Context, cfg config.Webhook, client *http.Client, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		err = w.send(ctx, cfg, client, body)
+		if err == nil || attempt >= cfg.Retries {
+			return err
+		}
+		w.logger.Debug("Retrying notification", "type", "Webhook", "func", "post", "target", p.Target, "attempt", attempt+1, "retry", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *Webhook) send(ctx context.Context, cfg config.Webhook, client *http.Client, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "network_exporter")
+	if err := discovery.SetAuth(req, cfg.Auth); err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s", strings.TrimSpace(resp.Status+" "+string(b)))
+	}
+	return nil
+}
+
+// summary returns the main values of the last result
+func summary(r results.Result) map[string]interface{} {
+	s := map[string]interface{}{"success": r.Success}
+	switch d := r.Data.(type) {
+	case *ping.PingResult:
+		s["loss"] = d.DropRate
+		s["avg_seconds"] = d.AvgTime.Seconds()
+	case *mtr.MtrResult:
+		s["hops"] = len(d.Hops)
+		if len(d.Hops) > 0 {
+			s["loss"] = d.Hops[len(d.Hops)-1].Loss
+			s["avg_seconds"] = d.Hops[len(d.Hops)-1].AvgTime.Seconds()
+		}
+	case *tcp.TCPPortReturn:
+		s["connection_seconds"] = d.ConTime.Seconds()
+		if d.Banner {
+			s["banner_match"] = d.BannerMatch
+		}
+	case *pkghttp.HTTPReturn:
+		s["status"] = d.Status
+		s["total_seconds"] = d.Total.Seconds()
+	case *dns.DNSReturn:
+		s["rcode"] = d.Rcode
+		s["query_seconds"] = d.QueryTime.Seconds()
+	case *udp.UDPPortReturn:
+		s["outcome"] = d.Outcome
+		s["rtt_seconds"] = d.RTT.Seconds()
+	case *ntp.NTPReturn:
+		s["stratum"] = d.Stratum
+		s["offset_seconds"] = d.Offset.Seconds()
+	case *smtp.SMTPReturn:
+		s["code"] = d.Code
+		s["banner_seconds"] = d.BannerTime.Seconds()
+	}
+	return s
+}
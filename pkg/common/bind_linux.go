@@ -0,0 +1,18 @@
+//go:build linux
+
+package common
+
+import (
+	"syscall"
+)
+
+// BindToDevice binds a socket to a network interface with SO_BINDTODEVICE, it requires the CAP_NET_RAW capability
+func BindToDevice(c syscall.RawConn, device string) error {
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package common
+
+import (
+	"syscall"
+)
+
+// BindToDevice is not supported outside Linux, the probes are only bound to the address of the interface
+func BindToDevice(c syscall.RawConn, device string) error {
+	return nil
+}
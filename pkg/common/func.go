@@ -2,9 +2,11 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"net"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -20,7 +22,14 @@ func SrvRecordCheck(record string) bool {
 	return false
 }
 
-func SrvRecordHosts(record string) ([]string, error) {
+// SrvHost member of an SRV record with its priority and weight
+type SrvHost struct {
+	Host     string
+	Priority uint16
+	Weight   uint16
+}
+
+func SrvRecordHosts(record string) ([]SrvHost, error) {
 	record_split := strings.Split(record, ".")
 	if len(record_split) < 3 {
 		return nil, fmt.Errorf("invalid SRV record format: %s", record)
@@ -32,22 +41,23 @@ func SrvRecordHosts(record string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("resolving target: %v", err)
 	}
-	hosts := []string{}
-	if proto == "tcp" {
-		for _, host := range members {
-			hosts = append(hosts, fmt.Sprintf("%s:%d", host.Target[:len(host.Target)-1], host.Port))
-		}
-	} else {
-		for _, host := range members {
-			hosts = append(hosts, host.Target[:len(host.Target)-1])
+	hosts := []SrvHost{}
+	for _, host := range members {
+		h := SrvHost{Host: host.Target[:len(host.Target)-1], Priority: host.Priority, Weight: host.Weight}
+		if proto == "tcp" || proto == "udp" {
+			h.Host = fmt.Sprintf("%s:%d", h.Host, host.Port)
 		}
+		hosts = append(hosts, h)
 	}
 
 	return hosts, nil
 }
 
-// DestAddrs resolve the hostname to all it'ss IP's
-func DestAddrs(ctx context.Context, host string, resolver *net.Resolver, timeout time.Duration, enableIPv6 bool) ([]string, error) {
+// ErrNoAddressFamily the host has no address of the requested IP version
+var ErrNoAddressFamily = errors.New("no address of the requested IP version")
+
+// DestAddrs resolve the hostname to all it'ss IP's, an ipVersion of 4 or 6 keeps the addresses of that family only (0 both)
+func DestAddrs(ctx context.Context, host string, resolver *net.Resolver, timeout time.Duration, enableIPv6 bool, ipVersion int) ([]string, error) {
 	ipAddrs := make([]string, 0)
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -69,13 +79,34 @@ func DestAddrs(ctx context.Context, host string, resolver *net.Resolver, timeout
 		if !enableIPv6 && ipAddr.IP.To4() == nil {
 			continue
 		}
+		if ipVersion != 0 && IPVersion(ipAddr.IP.String()) != ipVersion {
+			continue
+		}
 
 		ipAddrs = append(ipAddrs, ipAddr.IP.String())
 	}
 
+	if len(ipAddrs) == 0 && ipVersion != 0 && len(addrs) > 0 {
+		if ipVersion == 6 && !enableIPv6 {
+			return nil, fmt.Errorf("%w: %s, IPv6 is disabled", ErrNoAddressFamily, host)
+		}
+		return nil, fmt.Errorf("%w: %s has no IPv%d address", ErrNoAddressFamily, host, ipVersion)
+	}
 	return ipAddrs, nil
 }
 
+// IPVersion returns the version (4 or 6) of an IP address, 0 when it is not an IP address
+func IPVersion(ip string) int {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return 0
+	case parsed.To4() != nil:
+		return 4
+	}
+	return 6
+}
+
 // IsEqualIP IP Comparison
 func IsEqualIP(ips1, ips2 string) bool {
 	ip1 := net.ParseIP(ips1)
@@ -95,6 +126,21 @@ func IsEqualIP(ips1, ips2 string) bool {
 	return true
 }
 
+// Pace waits d between two packets of a probe round, it returns the error of the context when it is canceled first
+func Pace(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // Time2Float Convert time to float32
 func Time2Float(t time.Duration) float32 {
 	return (float32)(t/time.Microsecond) / float32(1000)
@@ -159,6 +205,27 @@ func TimeCorrectedDeviation(values []time.Duration) float64 {
 	return math.Sqrt(sd / (float64(len(values)) - 1))
 }
 
+// TimeMeanAbsoluteDelta Calculates the mean absolute difference between the consecutive durations (RFC 3550 interarrival jitter without smoothing)
+func TimeMeanAbsoluteDelta(values []time.Duration) float64 {
+	if len(values) <= 1 {
+		return 0.0
+	}
+	sum := 0.0
+	for i := 1; i < len(values); i++ {
+		sum += math.Abs(float64(values[i] - values[i-1]))
+	}
+	return sum / float64(len(values)-1)
+}
+
+// TimeSmoothedJitter Calculates the RFC 3550 interarrival jitter of the consecutive durations, every difference moves the estimate by 1/16 of its deviation
+func TimeSmoothedJitter(values []time.Duration) float64 {
+	jitter := 0.0
+	for i := 1; i < len(values); i++ {
+		jitter += (math.Abs(float64(values[i]-values[i-1])) - jitter) / 16
+	}
+	return jitter
+}
+
 // CompareList Compare two lists and return a list with the difference
 // Returns elements in b that are not in a
 func CompareList(a, b []string) []string {
@@ -230,3 +297,15 @@ func HasListDuplicates(m []string) (string, error) {
 	}
 	return "", nil
 }
+
+// RedactURL hides the password of an URL
+func RedactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<invalid>"
+	}
+	return u.Redacted()
+}
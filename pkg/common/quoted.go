@@ -0,0 +1,27 @@
+package common
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// QuotedPacket returns the destination address and the ports of the packet quoted by an ICMP error (Time Exceeded, Destination Unreachable), the IP header and the first 8 bytes of its payload
+// Only the packets of the transport protocol (6 TCP, 17 UDP) without IPv6 extension headers are parsed
+func QuotedPacket(data []byte, v6 bool, protocol int) (dst net.IP, srcPort int, dstPort int, ok bool) {
+	var header int
+	if v6 {
+		if len(data) < 44 || int(data[6]) != protocol {
+			return nil, 0, 0, false
+		}
+		header, dst = 40, net.IP(data[24:40])
+	} else {
+		if len(data) < 20 || int(data[9]) != protocol {
+			return nil, 0, 0, false
+		}
+		header, dst = int(data[0]&0x0f)*4, net.IP(data[16:20])
+		if len(data) < header+4 {
+			return nil, 0, 0, false
+		}
+	}
+	return dst, int(binary.BigEndian.Uint16(data[header : header+2])), int(binary.BigEndian.Uint16(data[header+2 : header+4])), true
+}
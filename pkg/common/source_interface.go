@@ -0,0 +1,99 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// ErrNoInterfaceAddress the source interface of a probe has no usable address
+var ErrNoInterfaceAddress = errors.New("no usable address")
+
+// InterfaceAddr returns the current primary address of an interface of the IP version, 4 or 6 (0 the IPv4 address and then the IPv6 one)
+// The link-local addresses are not usable as a source without a zone and are skipped
+func InterfaceAddr(name string, version int) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return "", fmt.Errorf("interface %s is down: %w", name, ErrNoInterfaceAddress)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	versions := []int{version}
+	if version == 0 {
+		versions = []int{4, 6}
+	}
+	for _, v := range versions {
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() || IPVersion(ipNet.IP.String()) != v {
+				continue
+			}
+			return ipNet.IP.String(), nil
+		}
+	}
+	if version == 0 {
+		return "", fmt.Errorf("interface %s: %w", name, ErrNoInterfaceAddress)
+	}
+	return "", fmt.Errorf("interface %s: %w of IP version %d", name, ErrNoInterfaceAddress, version)
+}
+
+// SourceInterfaceFailures counts the probes failed as their source interface has no usable address
+var SourceInterfaceFailures = &SourceInterfaceCounter{failures: map[[2]string]uint64{}, logged: map[string]bool{}}
+
+// SourceInterfaceCounter counts the source interface failures by probe type and interface, the first failure of each error is logged
+type SourceInterfaceCounter struct {
+	mtx      sync.Mutex
+	logger   *slog.Logger
+	failures map[[2]string]uint64
+	logged   map[string]bool
+}
+
+// SetLogger sets the logger of the source interface failures
+func (c *SourceInterfaceCounter) SetLogger(logger *slog.Logger) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.logger = logger
+}
+
+// Fail counts a probe of the type failed without an address of the interface
+func (c *SourceInterfaceCounter) Fail(probe string, iface string, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.failures[[2]string{probe, iface}]++
+	c.log(probe, iface, "Source interface without usable address, the probe failed", err)
+}
+
+// BindFailed logs the first failure of each error binding a socket to the interface, the probes are then only bound to its address
+func (c *SourceInterfaceCounter) BindFailed(probe string, iface string, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.log(probe, iface, "Binding the socket to the source interface failed, the probes only use its address", err)
+}
+
+func (c *SourceInterfaceCounter) log(probe string, iface string, msg string, err error) {
+	if key := probe + iface + err.Error(); c.logger != nil && !c.logged[key] {
+		c.logged[key] = true
+		c.logger.Error(msg, "type", probe, "func", "SourceInterface", "interface", iface, "err", err)
+	}
+}
+
+// Failures returns the source interface failures by probe type and interface since the start
+func (c *SourceInterfaceCounter) Failures() map[[2]string]uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	f := make(map[[2]string]uint64, len(c.failures))
+	for k, v := range c.failures {
+		f[k] = v
+	}
+	return f
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+)
+
+// TLSVersion returns the name of the negotiated TLS version
+func TLSVersion(state *tls.ConnectionState) string {
+	switch state.Version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return "unknown"
+	}
+}
+
+// EarliestCertExpiry returns the earliest expiry of the certificates of the verified chains, of the peer certificates when the chain was not verified (insecure_skip_verify)
+func EarliestCertExpiry(state *tls.ConnectionState) time.Time {
+	if len(state.VerifiedChains) == 0 {
+		return EarliestExpiry(state.PeerCertificates)
+	}
+	earliest := time.Time{}
+	for _, chain := range state.VerifiedChains {
+		if expiry := EarliestExpiry(chain); earliest.IsZero() || (!expiry.IsZero() && expiry.Before(earliest)) {
+			earliest = expiry
+		}
+	}
+	return earliest
+}
+
+// EarliestExpiry returns the earliest expiry of the certificates
+func EarliestExpiry(certs []*x509.Certificate) time.Time {
+	earliest := time.Time{}
+	for _, cert := range certs {
+		if (earliest.IsZero() || cert.NotAfter.Before(earliest)) && !cert.NotAfter.IsZero() {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
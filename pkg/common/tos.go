@@ -0,0 +1,48 @@
+package common
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// TOSFailures counts the probes sent unmarked as the TOS / traffic class of their socket could not be set
+var TOSFailures = &TOSCounter{failures: map[string]uint64{}, logged: map[string]bool{}}
+
+// TOSCounter counts the TOS failures by socket type (ICMP or TCP), the first failure of each error is logged
+type TOSCounter struct {
+	mtx      sync.Mutex
+	logger   *slog.Logger
+	failures map[string]uint64
+	logged   map[string]bool
+}
+
+// SetLogger sets the logger of the TOS failures
+func (c *TOSCounter) SetLogger(logger *slog.Logger) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.logger = logger
+}
+
+// Fail counts a probe of the socket type sent without its TOS
+func (c *TOSCounter) Fail(socket string, tos int, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.failures[socket]++
+	if key := socket + err.Error(); c.logger != nil && !c.logged[key] {
+		c.logged[key] = true
+		c.logger.Error("Setting the TOS failed, the probes are sent unmarked", "type", socket, "func", "Fail", "tos", tos, "err", err)
+	}
+}
+
+// Failures returns the TOS failures by socket type since the start
+func (c *TOSCounter) Failures() map[string]uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	f := make(map[string]uint64, len(c.failures))
+	for k, v := range c.failures {
+		f[k] = v
+	}
+	return f
+}
@@ -44,6 +44,30 @@ type IcmpReturn struct {
 	Success bool
 	Addr    string
 	Elapsed time.Duration
+	// TTL IP TTL (IPv4) or hop limit (IPv6) of the received reply, 0 when the system does not report it
+	TTL int
+	// Others sequence numbers of the other echo replies of the identifier received while waiting for the reply, late or duplicate replies
+	Others []int
+}
+
+// RTTCounter clamps the invalid round trip times and counts them
+// The round trip times are measured with the monotonic clock, a negative one is never exported even if the clock misbehaves
+type RTTCounter struct {
+	invalid atomic.Uint64
+}
+
+// Clamp returns the round trip time or 0 when it is negative
+func (c *RTTCounter) Clamp(d time.Duration) time.Duration {
+	if d < 0 {
+		c.invalid.Add(1)
+		return 0
+	}
+	return d
+}
+
+// Invalid returns the clamped round trip times
+func (c *RTTCounter) Invalid() uint64 {
+	return c.invalid.Load()
 }
 
 // IcmpSummary ICMP HOP Summary
@@ -74,4 +98,7 @@ type IcmpHop struct {
 	CorrectedSDTime      time.Duration `json:"csd"`
 	RangeTime            time.Duration `json:"range"`
 	Loss                 float64       `json:"loss"`
+	// StdDevTime standard deviation of the RTTs of the hop and JitterTime their RFC 3550 smoothed jitter, nil with less than two replies
+	StdDevTime *time.Duration `json:"stddev,omitempty"`
+	JitterTime *time.Duration `json:"jitter,omitempty"`
 }
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRTTCounterClamp(t *testing.T) {
+	var c RTTCounter
+	tests := []struct {
+		rtt  time.Duration
+		want time.Duration
+	}{
+		{rtt: 5 * time.Millisecond, want: 5 * time.Millisecond},
+		{rtt: 0, want: 0},
+		{rtt: -time.Nanosecond, want: 0},
+		{rtt: -time.Hour, want: 0},
+	}
+	for _, tt := range tests {
+		if got := c.Clamp(tt.rtt); got != tt.want {
+			t.Errorf("Clamp(%s) = %s, want %s", tt.rtt, got, tt.want)
+		}
+	}
+	if n := c.Invalid(); n != 2 {
+		t.Errorf("%d invalid round trip times, want 2", n)
+	}
+}
+
+func TestRTTCounterClockStep(t *testing.T) {
+	var c RTTCounter
+	start := time.Now()
+
+	// A reply timestamped after the wall clock stepped back an hour, without the monotonic reading
+	stepped := start.Round(0).Add(-time.Hour)
+	if got := c.Clamp(stepped.Sub(start)); got != 0 {
+		t.Errorf("round trip time %s after a backwards clock step, want 0", got)
+	}
+	if n := c.Invalid(); n != 1 {
+		t.Errorf("%d invalid round trip times, want 1", n)
+	}
+
+	// The probes measure with the monotonic clock, the round trip times stay within the elapsed time
+	for i := 0; i < 1000; i++ {
+		sent := time.Now()
+		got := c.Clamp(time.Since(sent))
+		if got < 0 || got > time.Since(start) {
+			t.Fatalf("round trip time %s, want within [0, %s]", got, time.Since(start))
+		}
+	}
+	if n := c.Invalid(); n != 1 {
+		t.Errorf("%d invalid round trip times, want 1", n)
+	}
+}
+
+func TestRTTCounterConcurrent(t *testing.T) {
+	var c RTTCounter
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Clamp(-time.Millisecond)
+				c.Clamp(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := c.Invalid(); n != 8000 {
+		t.Errorf("%d invalid round trip times, want 8000", n)
+	}
+}
@@ -0,0 +1,285 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// queryTypes record types of the supported query types
+var queryTypes = map[string]dnsmessage.Type{
+	"A":    dnsmessage.TypeA,
+	"AAAA": dnsmessage.TypeAAAA,
+	"SRV":  dnsmessage.TypeSRV,
+	"MX":   dnsmessage.TypeMX,
+	"TXT":  dnsmessage.TypeTXT,
+}
+
+// rcodeReasons error reasons of the response codes, the other ones are reported as rcode
+var rcodeReasons = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeFormatError:    "formerr",
+	dnsmessage.RCodeServerFailure:  "servfail",
+	dnsmessage.RCodeNameError:      "nxdomain",
+	dnsmessage.RCodeNotImplemented: "notimp",
+	dnsmessage.RCodeRefused:        "refused",
+}
+
+// Query DNS Operation, sends the question to the server and checks the response code and the expected answers of the response
+// The query is aborted when the context is canceled, the socket is bound to the device when set
+func Query(ctx context.Context, destAddr string, ip string, srcAddr string, device string, port string, q Question, timeout time.Duration) (*DNSReturn, error) {
+	out := DNSReturn{DestAddr: destAddr, DestIp: ip, DestPort: port, SrcIp: "0.0.0.0", QueryName: q.Name, QueryType: q.Type, Transport: q.Transport, Rcode: -1}
+
+	qtype, ok := queryTypes[q.Type]
+	if !ok {
+		return &out, fmt.Errorf("unsupported query type %s", q.Type)
+	}
+	name, err := dnsmessage.NewName(dnsName(q.Name))
+	if err != nil {
+		return &out, fmt.Errorf("invalid query name %s: %s", q.Name, err)
+	}
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return &out, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return &out, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return &out, err
+	}
+
+	d := net.Dialer{}
+	if srcAddr != "" {
+		srcIp := net.ParseIP(srcAddr)
+		if srcIp == nil {
+			out.ErrorReason = "connect"
+			return &out, fmt.Errorf("source ip: %v is invalid, DNS target: %v", srcAddr, destAddr)
+		}
+		if q.Transport == "tcp" {
+			d.LocalAddr = &net.TCPAddr{IP: srcIp}
+		} else {
+			d.LocalAddr = &net.UDPAddr{IP: srcIp}
+		}
+	}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if device != "" {
+			if err := common.BindToDevice(c, device); err != nil {
+				common.SourceInterfaceFailures.BindFailed("DNS", device, err)
+			}
+		}
+		return nil
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := d.DialContext(dialCtx, q.Transport, net.JoinHostPort(ip, port))
+	if err != nil {
+		out.QueryTime = time.Since(start)
+		out.ErrorReason = errorReason(err)
+		return &out, err
+	}
+	defer conn.Close()
+	// Closing the connection aborts the exchange when the target is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		out.ErrorReason = "connect"
+		return &out, fmt.Errorf("error setting deadline timeout: %v", err)
+	}
+	out.SrcIp = localIP(conn.LocalAddr())
+
+	resp, err := exchange(conn, q.Transport, id, query)
+	out.QueryTime = time.Since(start)
+	if err != nil {
+		out.ErrorReason = errorReason(err)
+		return &out, err
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil || !h.Response || h.ID != id {
+		out.ErrorReason = "response"
+		return &out, fmt.Errorf("invalid response: %v", err)
+	}
+	out.Rcode = int(h.RCode)
+	out.Truncated = h.Truncated
+	if err := p.SkipAllQuestions(); err != nil {
+		out.ErrorReason = "response"
+		return &out, fmt.Errorf("invalid response: %v", err)
+	}
+	for {
+		ah, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			out.ErrorReason = "response"
+			return &out, fmt.Errorf("invalid response: %v", err)
+		}
+		out.Answers++
+		if ah.Type != qtype {
+			// CNAME records of the answer chain
+			if err := p.SkipAnswer(); err != nil {
+				out.ErrorReason = "response"
+				return &out, fmt.Errorf("invalid response: %v", err)
+			}
+			continue
+		}
+		value, err := answerValue(&p, qtype)
+		if err != nil {
+			out.ErrorReason = "response"
+			return &out, fmt.Errorf("invalid response: %v", err)
+		}
+		out.AnswerValues = append(out.AnswerValues, value)
+	}
+
+	if h.RCode != dnsmessage.RCodeSuccess {
+		reason, ok := rcodeReasons[h.RCode]
+		if !ok {
+			reason = "rcode"
+		}
+		out.ErrorReason = reason
+		return &out, nil
+	}
+	if len(missingAnswers(q.Type, q.ExpectedAnswers, out.AnswerValues)) > 0 {
+		out.ErrorReason = "answer"
+		return &out, nil
+	}
+	out.Success = true
+	return &out, nil
+}
+
+// exchange sends the query and reads its response, the TCP messages are prefixed with their length
+// The UDP responses of another query (late answers of a previous probe) are skipped
+func exchange(conn net.Conn, transport string, id uint16, query []byte) ([]byte, error) {
+	if transport == "tcp" {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(length))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// answerValue returns the text of an answer record: the address (A, AAAA), "preference host" (MX), "priority weight port target" (SRV) or the joined strings (TXT)
+func answerValue(p *dnsmessage.Parser, qtype dnsmessage.Type) (string, error) {
+	switch qtype {
+	case dnsmessage.TypeA:
+		r, err := p.AResource()
+		return netip.AddrFrom4(r.A).String(), err
+	case dnsmessage.TypeAAAA:
+		r, err := p.AAAAResource()
+		return netip.AddrFrom16(r.AAAA).String(), err
+	case dnsmessage.TypeMX:
+		r, err := p.MXResource()
+		return fmt.Sprintf("%d %s", r.Pref, r.MX.String()), err
+	case dnsmessage.TypeSRV:
+		r, err := p.SRVResource()
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target.String()), err
+	case dnsmessage.TypeTXT:
+		r, err := p.TXTResource()
+		return strings.Join(r.TXT, ""), err
+	}
+	return "", p.SkipAnswer()
+}
+
+// missingAnswers returns the expected answers not found in the answers
+func missingAnswers(qtype string, expected []string, answers []string) []string {
+	normalized := make([]string, 0, len(answers))
+	for _, a := range answers {
+		normalized = append(normalized, normalizeAnswer(qtype, a))
+	}
+	missing := []string{}
+	for _, e := range expected {
+		if !slices.Contains(normalized, normalizeAnswer(qtype, e)) {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+// normalizeAnswer returns the comparable form of an answer, the addresses in their canonical form and the names case-insensitive without their trailing dot
+func normalizeAnswer(qtype string, answer string) string {
+	answer = strings.TrimSpace(answer)
+	switch qtype {
+	case "TXT":
+		return answer
+	case "A", "AAAA":
+		if ip, err := netip.ParseAddr(answer); err == nil {
+			return ip.String()
+		}
+	}
+	return strings.TrimSuffix(strings.ToLower(answer), ".")
+}
+
+// dnsName returns the fully qualified name of the query
+func dnsName(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// errorReason returns the error reason of a failed exchange
+func errorReason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "response"
+	}
+	return "connect"
+}
+
+// localIP returns the source address of the connection
+func localIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	return "0.0.0.0"
+}
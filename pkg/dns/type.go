@@ -0,0 +1,35 @@
+package dns
+
+import "time"
+
+// Question query sent by a DNS probe
+type Question struct {
+	Name string
+	// Type A, AAAA, SRV, MX or TXT
+	Type string
+	// Transport udp or tcp
+	Transport string
+	// ExpectedAnswers answers that must all be in the answer section of the response, none checked when empty
+	ExpectedAnswers []string
+}
+
+// DNSReturn Calculated results
+type DNSReturn struct {
+	Success   bool   `json:"success"`
+	DestAddr  string `json:"dest_address"`
+	DestIp    string `json:"dest_ip"`
+	DestPort  string `json:"dest_port"`
+	SrcIp     string `json:"src_ip"`
+	QueryName string `json:"query_name"`
+	QueryType string `json:"query_type"`
+	Transport string `json:"transport"`
+	// Rcode response code of the response, -1 without response
+	Rcode int `json:"rcode"`
+	// Answers records of the answer section, the ones of the query type in AnswerValues
+	Answers      int      `json:"answers"`
+	AnswerValues []string `json:"answer_values"`
+	Truncated    bool     `json:"truncated"`
+	// ErrorReason phase of the failure: source_interface, connect, timeout, response, the rcode (formerr, servfail, nxdomain, notimp, refused or rcode) or answer
+	ErrorReason string        `json:"error_reason,omitempty"`
+	QueryTime   time.Duration `json:"query_time"`
+}
@@ -0,0 +1,90 @@
+package geoip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// cgnat shared address space of the carrier-grade NAT (RFC 6598)
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// asnRecord fields of the GeoLite2-ASN records
+type asnRecord struct {
+	Number uint `maxminddb:"autonomous_system_number"`
+}
+
+// cityRecord fields of the GeoLite2-City and GeoLite2-Country records
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// DB memory-mapped MaxMind ASN and City (or Country) databases, either one is optional
+type DB struct {
+	asn  *maxminddb.Reader
+	city *maxminddb.Reader
+}
+
+// Open maps the ASN and City databases of the paths, an empty path skips its database
+func Open(asnPath string, cityPath string) (*DB, error) {
+	d := &DB{}
+	var err error
+	if asnPath != "" {
+		if d.asn, err = maxminddb.Open(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	if cityPath != "" {
+		if d.city, err = maxminddb.Open(cityPath); err != nil {
+			d.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Lookup returns the AS number and the ISO country code of an address, empty when unknown
+// The private, loopback, link-local, multicast and CGNAT addresses are not looked up
+func (d *DB) Lookup(ip string) (string, string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !routable(addr.Unmap()) {
+		return "", ""
+	}
+
+	var asn, country string
+	if d.asn != nil {
+		var r asnRecord
+		if err := d.asn.Lookup(net.IP(addr.AsSlice()), &r); err == nil && r.Number > 0 {
+			asn = strconv.FormatUint(uint64(r.Number), 10)
+		}
+	}
+	if d.city != nil {
+		var r cityRecord
+		if err := d.city.Lookup(net.IP(addr.AsSlice()), &r); err == nil {
+			country = r.Country.ISOCode
+		}
+	}
+	return asn, country
+}
+
+// Close unmaps the databases
+func (d *DB) Close() error {
+	var errs []error
+	if d.asn != nil {
+		errs = append(errs, d.asn.Close())
+	}
+	if d.city != nil {
+		errs = append(errs, d.city.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// routable reports whether an address can be found in the databases
+func routable(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnat.Contains(addr)
+}
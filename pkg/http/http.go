@@ -1,15 +1,35 @@
 package http
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptrace"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// defaultMaxRedirects redirects followed by the probes without request
+const defaultMaxRedirects = 10
+
+var (
+	// errRedirected and errTooManyRedirects the probe was redirected without following the redirects or more than the maximum
+	errRedirected       = errors.New("redirect not followed")
+	errTooManyRedirects = errors.New("too many redirects")
 )
 
 var (
@@ -22,6 +42,9 @@ var (
 	// Transport cache for proxy transports
 	proxyTransports     = make(map[string]*http.Transport)
 	proxyTransportMutex sync.RWMutex
+	// Transport cache for the transports with a TLS config or the proxy of the environment, by source IP or proxy, TLS config fingerprint and proxy from environment
+	requestTransports     = make(map[string]*http.Transport)
+	requestTransportMutex sync.Mutex
 )
 
 // getDefaultTransport returns a singleton HTTP transport with connection pooling
@@ -37,10 +60,11 @@ func getDefaultTransport() *http.Transport {
 	return defaultTransport
 }
 
-// getSourceIPTransport returns or creates a transport for a specific source IP
-func getSourceIPTransport(srcAddr string) *http.Transport {
+// getSourceIPTransport returns or creates a transport for a specific source IP, its sockets are bound to the device when set
+func getSourceIPTransport(srcAddr string, device string) *http.Transport {
+	key := srcAddr + "%" + device
 	sourceIPTransportMutex.RLock()
-	transport, exists := sourceIPTransports[srcAddr]
+	transport, exists := sourceIPTransports[key]
 	sourceIPTransportMutex.RUnlock()
 
 	if exists {
@@ -52,31 +76,41 @@ func getSourceIPTransport(srcAddr string) *http.Transport {
 	defer sourceIPTransportMutex.Unlock()
 
 	// Double-check after acquiring write lock
-	if transport, exists := sourceIPTransports[srcAddr]; exists {
+	if transport, exists := sourceIPTransports[key]; exists {
 		return transport
 	}
 
-	srcIp := net.ParseIP(srcAddr)
+	dialer := &net.Dialer{}
+	if srcIp := net.ParseIP(srcAddr); srcIp != nil {
+		dialer.LocalAddr = &net.TCPAddr{
+			IP:   srcIp,
+			Port: 0,
+		}
+	}
+	if device != "" {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			if err := common.BindToDevice(c, device); err != nil {
+				common.SourceInterfaceFailures.BindFailed("HTTPGet", device, err)
+			}
+			return nil
+		}
+	}
 	transport = &http.Transport{
-		DialContext: (&net.Dialer{
-			LocalAddr: &net.TCPAddr{
-				IP:   srcIp,
-				Port: 0,
-			},
-		}).DialContext,
+		DialContext:         dialer.DialContext,
 		MaxIdleConns:        100,
 		MaxIdleConnsPerHost: 10,
 		IdleConnTimeout:     90 * time.Second,
 		MaxConnsPerHost:     0,
 	}
-	sourceIPTransports[srcAddr] = transport
+	sourceIPTransports[key] = transport
 	return transport
 }
 
-// getProxyTransport returns or creates a transport for a specific proxy URL
-func getProxyTransport(proxyURL string) (*http.Transport, error) {
+// getProxyTransport returns or creates a transport for a specific proxy URL, the https proxies are dialed with the TLS config of the proxy of the proxyTLSID
+func getProxyTransport(proxyURL string, proxyTLS *tls.Config, proxyTLSID string) (*http.Transport, error) {
+	key := proxyURL + "|" + proxyTLSID
 	proxyTransportMutex.RLock()
-	transport, exists := proxyTransports[proxyURL]
+	transport, exists := proxyTransports[key]
 	proxyTransportMutex.RUnlock()
 
 	if exists {
@@ -88,7 +122,7 @@ func getProxyTransport(proxyURL string) (*http.Transport, error) {
 	defer proxyTransportMutex.Unlock()
 
 	// Double-check after acquiring write lock
-	if transport, exists := proxyTransports[proxyURL]; exists {
+	if transport, exists := proxyTransports[key]; exists {
 		return transport, nil
 	}
 
@@ -98,21 +132,179 @@ func getProxyTransport(proxyURL string) (*http.Transport, error) {
 	}
 
 	transport = &http.Transport{
-		Proxy:               http.ProxyURL(pURL),
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		MaxConnsPerHost:     0,
-	}
-	proxyTransports[proxyURL] = transport
+		Proxy:                  http.ProxyURL(pURL),
+		OnProxyConnectResponse: proxyConnectResponse,
+		MaxIdleConns:           100,
+		MaxIdleConnsPerHost:    10,
+		IdleConnTimeout:        90 * time.Second,
+		MaxConnsPerHost:        0,
+	}
+	if pURL.Scheme == "https" {
+		// The TLS config of the request is only used for the origin, HTTP/2 is still negotiated with it
+		transport.DialTLSContext = dialProxyTLS(pURL.Hostname(), proxyTLS)
+		transport.ForceAttemptHTTP2 = true
+	}
+	proxyTransports[key] = transport
 	return transport, nil
 }
 
-// HTTPGet Http Get Trace Operation
-func HTTPGet(destURL string, srcAddr string, timeout time.Duration) (*HTTPReturn, error) {
+// transport returns the transport of the request, a copy of base with the TLS config of the request and the proxy of the environment when set
+func (r *Request) transport(base *http.Transport, key string, proxy bool) *http.Transport {
+	if r == nil || (r.TLS == nil && (!r.ProxyFromEnvironment || proxy)) {
+		return base
+	}
+	fromEnvironment := r.ProxyFromEnvironment && !proxy
+	key += "|" + r.TLSID + "|" + strconv.FormatBool(fromEnvironment)
+
+	requestTransportMutex.Lock()
+	defer requestTransportMutex.Unlock()
+	if transport, exists := requestTransports[key]; exists {
+		return transport
+	}
+	transport := base.Clone()
+	if r.TLS != nil {
+		transport.TLSClientConfig = r.TLS.Clone()
+	}
+	if fromEnvironment {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	requestTransports[key] = transport
+	return transport
+}
+
+// keepAlive returns the transport of a probe, the persistent one of the target when it reuses its connection
+func (r *Request) keepAlive(transport *http.Transport) *http.Transport {
+	if r == nil || r.KeepAlive == nil {
+		return transport
+	}
+	k := r.KeepAlive
+	k.Lock()
+	defer k.Unlock()
+	if k.transport == nil || k.base != transport {
+		if k.transport != nil {
+			k.transport.CloseIdleConnections()
+		}
+		k.base, k.transport = transport, transport.Clone()
+	}
+	return k.transport
+}
+
+// checkRedirect returns the redirect policy of a probe, it counts the redirects followed
+func (r *Request) checkRedirect(out *HTTPReturn) func(*http.Request, []*http.Request) error {
+	follow, maxRedirects := true, defaultMaxRedirects
+	if r != nil {
+		follow, maxRedirects = r.FollowRedirects, r.MaxRedirects
+	}
+	return func(_ *http.Request, via []*http.Request) error {
+		switch {
+		case !follow:
+			return errRedirected
+		case len(via) > maxRedirects:
+			return fmt.Errorf("stopped after %d redirects: %w", maxRedirects, errTooManyRedirects)
+		}
+		out.Redirects = len(via)
+		return nil
+	}
+}
+
+// setFinalURL sets the URL of the final response, the last redirect when the redirects were not followed
+func setFinalURL(out *HTTPReturn, resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	out.FinalScheme = resp.Request.URL.Scheme
+	out.FinalHost = resp.Request.URL.Host
+}
+
+// readLimit returns the bytes of the body read by the probe, 0 reads the whole body
+func (r *Request) readLimit() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.ReadLimit
+}
+
+// acceptGzip offers the gzip encoding like the transport, the body is decompressed by the probe so that its size on the wire is known
+func acceptGzip(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+}
+
+// reuse reports if the connection of the probe is kept open for the next one
+func (r *Request) reuse() bool {
+	return r != nil && r.KeepAlive != nil
+}
+
+// Close closes the connection kept open by the probes
+func (k *KeepAlive) Close() {
+	k.Lock()
+	defer k.Unlock()
+	if k.transport != nil {
+		k.transport.CloseIdleConnections()
+	}
+	if k.quic != nil {
+		k.quic.Close()
+	}
+}
+
+// EnvironmentProxy returns the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables used for an URL, nil when the URL is reached directly
+func EnvironmentProxy(destURL string) (*url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, destURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// new creates the request, GET without body when r is nil
+func (r *Request) new(ctx context.Context, destURL string) (*http.Request, error) {
+	if r == nil {
+		return http.NewRequestWithContext(ctx, http.MethodGet, destURL, nil)
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if r.Body != "" {
+		// A new reader on every request, the client rewinds it on the redirects
+		body = strings.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, destURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Host header is ignored by the client so it replaces the host of the request instead
+	for key, values := range r.Header {
+		if key == "Host" {
+			req.Host = r.Header.Get("Host")
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req, nil
+}
+
+// skipBody returns the body patterns of the request, the HEAD responses have no body to download nor match
+func (r *Request) skipBody(body *BodyMatch) *BodyMatch {
+	if r != nil && r.Method == http.MethodHead {
+		return nil
+	}
+	return body
+}
+
+// HTTPGet Http Get Trace Operation, the request is aborted when the context is canceled
+// The request is sent with the method, headers (Host overrides the host of the request) and body of r
+func HTTPGet(ctx context.Context, destURL string, srcAddr string, timeout time.Duration, r *Request, body *BodyMatch) (*HTTPReturn, error) {
 	var out HTTPReturn
 	var err error
 	out.DestAddr = destURL
+	out.HTTPVersion = r.Version()
 
 	dURL, err := url.Parse(destURL)
 	if err != nil {
@@ -122,72 +314,71 @@ func HTTPGet(destURL string, srcAddr string, timeout time.Duration) (*HTTPReturn
 
 	// Reuse transport for connection pooling
 	var transport *http.Transport
-	if srcAddr != "" {
-		srcIp := net.ParseIP(srcAddr)
-		if srcIp == nil {
-			out.Success = false
-			return &out, fmt.Errorf("source ip: %v is invalid, HTTP target: %v", srcAddr, destURL)
-		}
-		transport = getSourceIPTransport(srcAddr)
+	var device string
+	if r != nil {
+		device = r.Device
+	}
+	if srcAddr != "" && net.ParseIP(srcAddr) == nil {
+		out.Success = false
+		return &out, fmt.Errorf("source ip: %v is invalid, HTTP target: %v", srcAddr, destURL)
+	}
+	if r != nil && r.HTTP3 {
+		return httpGet3(ctx, &out, dURL, srcAddr, timeout, r, body)
+	}
+	if srcAddr != "" || device != "" {
+		transport = getSourceIPTransport(srcAddr, device)
 	} else {
 		transport = getDefaultTransport()
 	}
+	transport = r.keepAlive(r.transport(transport, srcAddr+"%"+device, false))
+	if !r.reuse() {
+		// The connections of the redirects are not closed by the request, the next probe dials again
+		defer transport.CloseIdleConnections()
+	}
 
 	client := &http.Client{
-		Timeout:   timeout,
-		Transport: transport,
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: r.checkRedirect(&out),
 	}
 
-	req, err := http.NewRequest("GET", dURL.String(), nil)
+	req, err := r.new(ctx, dURL.String())
 	if err != nil {
 		out.Success = false
 		return &out, err
 	}
 
 	trace, ht := NewClientTrace()
-	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	ctx = httptrace.WithClientTrace(req.Context(), trace)
 	req = req.WithContext(ctx)
-	req.Close = true
+	req.Close = !r.reuse()
+	acceptGzip(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
+		// The phases completed before the failure are kept
+		ht.Finish()
+		setStats(&out, ht.Stats())
 		out.Success = false
+		out.ErrorReason = ht.errorReason(err)
+		setCertificateError(&out, err)
+		// The redirect response is returned with the redirect policy error
+		if resp != nil {
+			out.Status = resp.StatusCode
+			setFinalURL(&out, resp)
+		}
 		return &out, err
 	}
-
-	// Read
-	defer resp.Body.Close()
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		out.Success = false
-		return &out, err
-	}
-
-	ht.Finish()
-	stats := ht.Stats()
-
-	out.Success = true
-	out.Status = resp.StatusCode
-	out.ContentLength = resp.ContentLength
-	out.DNSLookup = stats.DNSLookup
-	out.TCPConnection = stats.TCPConnection
-	out.TLSHandshake = stats.TLSHandshake
-	if resp.TLS != nil {
-		out.TLSVersion = getTLSVersion(resp.TLS)
-		out.TLSEarliestCertExpiry = getEarliestCertExpiry(resp.TLS)
-		out.TLSLastChainExpiry = getLastChainExpiry(resp.TLS)
-	}
-	out.ServerProcessing = stats.ServerProcessing
-	out.ContentTransfer = stats.ContentTransfer
-	out.Total = stats.Total
-
-	return &out, nil
+	return readResponse(&out, resp, ht, r.skipBody(body), r.readLimit())
 }
 
-// HTTPGetProxy Http Get Trace Operation with proxy
-func HTTPGetProxy(destURL string, timeout time.Duration, proxyURL string) (*HTTPReturn, error) {
+// HTTPGetProxy Http Get Trace Operation with proxy, the request is aborted when the context is canceled
+func HTTPGetProxy(ctx context.Context, destURL string, timeout time.Duration, proxyURL string, r *Request, body *BodyMatch) (*HTTPReturn, error) {
 	var out HTTPReturn
 	var err error
 	out.DestAddr = destURL
+	out.HTTPVersion = r.Version()
+	out.Proxy = true
 
 	dURL, err := url.Parse(destURL)
 	if err != nil {
@@ -196,60 +387,183 @@ func HTTPGetProxy(destURL string, timeout time.Duration, proxyURL string) (*HTTP
 	}
 
 	// Reuse transport for connection pooling
-	transport, err := getProxyTransport(proxyURL)
+	var proxyTLS *tls.Config
+	var proxyTLSID string
+	if r != nil {
+		proxyTLS, proxyTLSID = r.ProxyTLS, r.ProxyTLSID
+	}
+	transport, err := getProxyTransport(proxyURL, proxyTLS, proxyTLSID)
 	if err != nil {
 		out.Success = false
 		return &out, err
 	}
+	transport = r.keepAlive(r.transport(transport, proxyURL+"|"+proxyTLSID, true))
+	if !r.reuse() {
+		// The connections of the redirects are not closed by the request, the next probe dials again
+		defer transport.CloseIdleConnections()
+	}
 
 	client := &http.Client{
-		Transport: transport,
-		Timeout:   timeout,
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: r.checkRedirect(&out),
 	}
 
-	req, err := http.NewRequest("GET", dURL.String(), nil)
+	req, err := r.new(ctx, dURL.String())
 	if err != nil {
 		out.Success = false
 		return &out, err
 	}
 
 	trace, ht := NewClientTrace()
-	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	ctx = withProxyTrace(httptrace.WithClientTrace(req.Context(), trace), ht)
 	req = req.WithContext(ctx)
-	req.Close = true
+	req.Close = !r.reuse()
+	acceptGzip(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
+		// The phases completed before the failure are kept
+		ht.Finish()
+		setStats(&out, ht.Stats())
 		out.Success = false
+		out.ErrorReason = ht.errorReason(err)
+		setCertificateError(&out, err)
+		// The redirect response is returned with the redirect policy error
+		if resp != nil {
+			out.Status = resp.StatusCode
+			setFinalURL(&out, resp)
+		}
 		return &out, err
 	}
+	return readResponse(&out, resp, ht, r.skipBody(body), r.readLimit())
+}
 
-	// Read
+// readResponse reads the body of the final response, after the redirects, up to the read limit and sets its status, timings, sizes and body match
+func readResponse(out *HTTPReturn, resp *http.Response, ht *HTTPTrace, body *BodyMatch, readLimit int64) (*HTTPReturn, error) {
 	defer resp.Body.Close()
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		out.Success = false
-		return &out, err
+	out.Status = resp.StatusCode
+	out.ContentLength = resp.ContentLength
+	setFinalURL(out, resp)
+	if resp.TLS != nil {
+		out.TLSVersion = common.TLSVersion(resp.TLS)
+		out.TLSCipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+		out.TLSVerified = len(resp.TLS.VerifiedChains) > 0
+		out.TLSEarliestCertExpiry = common.EarliestCertExpiry(resp.TLS)
+		out.TLSLastChainExpiry = getLastChainExpiry(resp.TLS)
 	}
 
+	ht.RLock()
+	out.ConnectionReused = ht.Reused
+	ht.RUnlock()
+
+	out.ContentEncoding = strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	wire := &countingReader{r: resp.Body}
+	content, err := decodeBody(wire, out.ContentEncoding)
+	if err == nil {
+		read := &countingReader{r: content}
+		var r io.Reader = read
+		if readLimit > 0 {
+			r = io.LimitReader(read, readLimit)
+		}
+		// The digest is computed on the body read, up to the read limit
+		var digest hash.Hash
+		if body != nil && body.SHA256 != "" {
+			digest = sha256.New()
+			r = io.TeeReader(r, digest)
+		}
+		var n int64
+		var match bool
+		n, match, err = matchBody(r, body)
+		out.BodyMatch, out.BodyMatchedBytes = match, n
+		out.ContentMatch = true
+		if digest != nil {
+			out.ContentSHA256 = hex.EncodeToString(digest.Sum(nil))
+			out.ContentMatch = err == nil && out.ContentSHA256 == body.SHA256
+		}
+		// A body of the size of the limit is only truncated when there is more to read
+		if err == nil && readLimit > 0 && read.n == readLimit {
+			extra, _ := io.ReadFull(content, make([]byte, 1))
+			out.BodyTruncated = extra > 0
+		}
+		out.BodyBytes = read.n
+	}
+	out.WireBytes = wire.n
+	out.TransferTime = time.Since(ht.Start)
+	if err != nil {
+		out.ErrorReason = "transfer"
+	}
 	ht.Finish()
-	stats := ht.Stats()
+	setStats(out, ht.Stats())
+	out.Success = err == nil
+	return out, err
+}
 
-	out.Success = true
-	out.Status = resp.StatusCode
-	out.ContentLength = resp.ContentLength
+// matchBody evaluates the patterns against the first bytes of the body, up to the size limit, and discards the rest
+// It returns the number of bytes evaluated and if the body passed the patterns, always without patterns
+func matchBody(r io.Reader, body *BodyMatch) (int64, bool, error) {
+	if body == nil || (len(body.FailIfMatches) == 0 && len(body.FailIfNotMatches) == 0) {
+		_, err := io.Copy(io.Discard, r)
+		return 0, true, err
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r, body.SizeLimit))
+	if err == nil {
+		_, err = io.Copy(io.Discard, r)
+	}
+	if err != nil {
+		return int64(len(buf)), false, err
+	}
+
+	for _, re := range body.FailIfMatches {
+		if re.Match(buf) {
+			return int64(len(buf)), false, nil
+		}
+	}
+	for _, re := range body.FailIfNotMatches {
+		if !re.Match(buf) {
+			return int64(len(buf)), false, nil
+		}
+	}
+	return int64(len(buf)), true, nil
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody returns the decompressed body of the gzip responses, the bodies of the other encodings are read as they are
+func decodeBody(r io.Reader, encoding string) (io.Reader, error) {
+	if encoding != "gzip" {
+		return r, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err == io.EOF {
+		// Without body
+		return strings.NewReader(""), nil
+	}
+	return gz, err
+}
+
+// setStats sets the timings of the request phases
+func setStats(out *HTTPReturn, stats *HTTPTimelineStats) {
 	out.DNSLookup = stats.DNSLookup
 	out.TCPConnection = stats.TCPConnection
 	out.TLSHandshake = stats.TLSHandshake
-	if resp.TLS != nil {
-		out.TLSVersion = getTLSVersion(resp.TLS)
-		out.TLSEarliestCertExpiry = getEarliestCertExpiry(resp.TLS)
-		out.TLSLastChainExpiry = getLastChainExpiry(resp.TLS)
-	}
+	out.ProxyTLSHandshake = stats.ProxyTLSHandshake
+	out.ProxyConnect = stats.ProxyConnect
+	out.RequestTransfer = stats.RequestTransfer
 	out.ServerProcessing = stats.ServerProcessing
 	out.ContentTransfer = stats.ContentTransfer
 	out.Total = stats.Total
-
-	return &out, nil
 }
 
 // NewClientTrace http client trace
@@ -269,6 +583,7 @@ func NewClientTrace() (trace *httptrace.ClientTrace, ht *HTTPTrace) {
 		DNSDone: func(info httptrace.DNSDoneInfo) {
 			ht.Lock()
 			defer ht.Unlock()
+			ht.DNSError = info.Err
 			ht.Addrs = make([]string, len(info.Addrs))
 			for index, addr := range info.Addrs {
 				ht.Addrs[index] = addr.String()
@@ -283,9 +598,10 @@ func NewClientTrace() (trace *httptrace.ClientTrace, ht *HTTPTrace) {
 			ht.Addr = addr
 			ht.ConnectStart = time.Now()
 		},
-		ConnectDone: func(_, _ string, _ error) {
+		ConnectDone: func(_, _ string, err error) {
 			ht.Lock()
 			defer ht.Unlock()
+			ht.ConnectError = err
 			ht.ConnectDone = time.Now()
 		},
 		GotConn: func(info httptrace.GotConnInfo) {
@@ -301,13 +617,19 @@ func NewClientTrace() (trace *httptrace.ClientTrace, ht *HTTPTrace) {
 			defer ht.Unlock()
 			ht.TLSHandshakeStart = time.Now()
 		},
-		TLSHandshakeDone: func(info tls.ConnectionState, _ error) {
+		TLSHandshakeDone: func(info tls.ConnectionState, err error) {
 			ht.Lock()
 			defer ht.Unlock()
+			ht.TLSError = err
 			ht.TLSResume = info.DidResume
 			ht.Protocol = info.NegotiatedProtocol
 			ht.TLSHandshakeDone = time.Now()
 		},
+		WroteRequest: func(_ httptrace.WroteRequestInfo) {
+			ht.Lock()
+			defer ht.Unlock()
+			ht.WroteRequest = time.Now()
+		},
 		GotFirstResponseByte: func() {
 			ht.Lock()
 			defer ht.Unlock()
@@ -325,6 +647,45 @@ func (ht *HTTPTrace) Finish() {
 	ht.Done = time.Now()
 }
 
+// errorReason returns the phase of a failed request from the trace, a TLS handshake failure is told apart from a TCP connect failure
+func (ht *HTTPTrace) errorReason(err error) string {
+	ht.RLock()
+	defer ht.RUnlock()
+
+	switch {
+	case errors.Is(err, errRedirected):
+		return "redirect"
+	case errors.Is(err, errTooManyRedirects):
+		return "max_redirects"
+	case ht.DNSError != nil:
+		return "dns"
+	// The QUIC handshake is the connect and the TLS handshake of the HTTP/3 requests
+	case ht.Network == "udp" && (ht.ConnectError != nil || (!ht.ConnectStart.IsZero() && ht.ConnectDone.IsZero())):
+		return quicErrorReason(err)
+	case ht.ConnectError != nil:
+		return "connect"
+	// The failures of the proxy are told apart from the ones of the origin behind it
+	case ht.ProxyTLSError != nil || (!ht.ProxyTLSStart.IsZero() && ht.ProxyTLSDone.IsZero()):
+		return "proxy_tls"
+	case ht.ProxyStatus == http.StatusProxyAuthRequired:
+		return "proxy_auth"
+	case ht.ProxyStatus != 0 && ht.ProxyStatus != http.StatusOK:
+		return "proxy_connect"
+	case ht.TLSError != nil || (!ht.TLSHandshakeStart.IsZero() && ht.TLSHandshakeDone.IsZero()):
+		return "tls"
+	}
+	// The TLS 1.3 servers reject the client certificates with an alert after the handshake
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return "tls"
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "request"
+}
+
 // Stats get the stats of time line
 func (ht *HTTPTrace) Stats() (stats *HTTPTimelineStats) {
 	stats = &HTTPTimelineStats{}
@@ -342,8 +703,29 @@ func (ht *HTTPTrace) Stats() (stats *HTTPTimelineStats) {
 	if !ht.TLSHandshakeStart.IsZero() && !ht.TLSHandshakeDone.IsZero() {
 		stats.TLSHandshake = ht.TLSHandshakeDone.Sub(ht.TLSHandshakeStart)
 	}
+	if !ht.ProxyTLSStart.IsZero() && !ht.ProxyTLSDone.IsZero() {
+		stats.ProxyTLSHandshake = ht.ProxyTLSDone.Sub(ht.ProxyTLSStart)
+	}
+	// The CONNECT is sent once the connection, and the TLS handshake with an https proxy, is up
+	if !ht.ProxyConnectDone.IsZero() {
+		start := ht.ConnectDone
+		if !ht.ProxyTLSDone.IsZero() {
+			start = ht.ProxyTLSDone
+		}
+		if !start.IsZero() {
+			stats.ProxyConnect = ht.ProxyConnectDone.Sub(start)
+		}
+	}
+	// The request headers and body are written between the connection and the server processing
+	if !ht.GotConnect.IsZero() && !ht.WroteRequest.IsZero() {
+		stats.RequestTransfer = ht.WroteRequest.Sub(ht.GotConnect)
+	}
 	if !ht.GotConnect.IsZero() && !ht.GotFirstResponseByte.IsZero() {
-		stats.ServerProcessing = ht.GotFirstResponseByte.Sub(ht.GotConnect)
+		start := ht.GotConnect
+		if !ht.WroteRequest.IsZero() {
+			start = ht.WroteRequest
+		}
+		stats.ServerProcessing = ht.GotFirstResponseByte.Sub(start)
 	}
 	if ht.Done.IsZero() {
 		ht.Done = time.Now()
@@ -356,29 +738,13 @@ func (ht *HTTPTrace) Stats() (stats *HTTPTimelineStats) {
 	return
 }
 
-func getTLSVersion(state *tls.ConnectionState) string {
-	switch state.Version {
-	case tls.VersionTLS10:
-		return "TLS 1.0"
-	case tls.VersionTLS11:
-		return "TLS 1.1"
-	case tls.VersionTLS12:
-		return "TLS 1.2"
-	case tls.VersionTLS13:
-		return "TLS 1.3"
-	default:
-		return "unknown"
-	}
-}
-
-func getEarliestCertExpiry(state *tls.ConnectionState) time.Time {
-	earliest := time.Time{}
-	for _, cert := range state.PeerCertificates {
-		if (earliest.IsZero() || cert.NotAfter.Before(earliest)) && !cert.NotAfter.IsZero() {
-			earliest = cert.NotAfter
-		}
+// setCertificateError sets the expiry of the certificates rejected by the verification (self-signed, expired or of another name) of a failed request
+func setCertificateError(out *HTTPReturn, err error) {
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		out.TLSEarliestCertExpiry = common.EarliestExpiry(certErr.UnverifiedCertificates)
+		out.TLSVerified = false
 	}
-	return earliest
 }
 
 func getLastChainExpiry(state *tls.ConnectionState) time.Time {
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// Version returns the HTTP version of the probes: 3 over QUIC or 1.1 over TCP (HTTP/2 when the HTTPS server negotiates it)
+func (r *Request) Version() string {
+	if r != nil && r.HTTP3 {
+		return "3"
+	}
+	return "1.1"
+}
+
+// quicTransport returns the HTTP/3 transport of a probe, the persistent one of the target when it reuses its connection
+func (r *Request) quicTransport(srcAddr string, device string) *http3.Transport {
+	if r.KeepAlive != nil {
+		k := r.KeepAlive
+		k.Lock()
+		defer k.Unlock()
+		if k.quic == nil {
+			k.quic = newQuicTransport(r.TLS, srcAddr, device)
+		}
+		return k.quic
+	}
+	return newQuicTransport(r.TLS, srcAddr, device)
+}
+
+// newQuicTransport creates an HTTP/3 transport with the TLS config, its connections are dialed from the source address and device
+func newQuicTransport(tlsConfig *tls.Config, srcAddr string, device string) *http3.Transport {
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	}
+	return &http3.Transport{
+		TLSClientConfig: tlsConfig,
+		Dial:            dialQuic(srcAddr, device),
+	}
+}
+
+// dialQuic returns the dialer of the QUIC connections, the UDP socket of a connection is bound to the source address and device and closed with it
+// The lookup, connect and TLS handshake are reported to the trace of the request, the connect and the TLS handshake are the same QUIC handshake
+func dialQuic(srcAddr string, device string) func(context.Context, string, *tls.Config, *quic.Config) (*quic.Conn, error) {
+	return func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (*quic.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		portNum, err := net.LookupPort("udp", port)
+		if err != nil {
+			return nil, err
+		}
+		srcIp := net.ParseIP(srcAddr)
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ip, err := quicAddr(addrs, srcIp)
+		if err != nil {
+			return nil, err
+		}
+
+		lc := net.ListenConfig{}
+		if device != "" {
+			lc.Control = func(network, address string, c syscall.RawConn) error {
+				if err := common.BindToDevice(c, device); err != nil {
+					common.SourceInterfaceFailures.BindFailed("HTTPGet", device, err)
+				}
+				return nil
+			}
+		}
+		network, local := "udp4", ":0"
+		if ip.IP.To4() == nil {
+			network = "udp6"
+		}
+		if srcIp != nil {
+			local = net.JoinHostPort(srcAddr, "0")
+		}
+		pc, err := lc.ListenPacket(ctx, network, local)
+		if err != nil {
+			return nil, err
+		}
+
+		raddr := &net.UDPAddr{IP: ip.IP, Port: portNum, Zone: ip.Zone}
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.ConnectStart != nil {
+			trace.ConnectStart("udp", raddr.String())
+		}
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		tr := &quic.Transport{Conn: pc}
+		conn, err := tr.Dial(ctx, raddr, tlsConfig, quicConfig)
+		var state tls.ConnectionState
+		if conn != nil {
+			state = conn.ConnectionState().TLS
+		}
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(state, err)
+		}
+		if trace != nil && trace.ConnectDone != nil {
+			trace.ConnectDone("udp", raddr.String(), err)
+		}
+		if err != nil {
+			tr.Close()
+			pc.Close()
+			return nil, err
+		}
+		context.AfterFunc(conn.Context(), func() {
+			tr.Close()
+			pc.Close()
+		})
+		return conn, nil
+	}
+}
+
+// quicAddr returns the first address of the family of the source address, the first one without source address
+func quicAddr(addrs []net.IPAddr, srcIp net.IP) (net.IPAddr, error) {
+	for _, a := range addrs {
+		if srcIp == nil || (a.IP.To4() == nil) == (srcIp.To4() == nil) {
+			return a, nil
+		}
+	}
+	return net.IPAddr{}, fmt.Errorf("no address of the family of the source ip %s", srcIp)
+}
+
+// quicErrorReason returns the reason of a failed QUIC handshake, tls when the server certificate or the TLS handshake was rejected and quic when the handshake did not complete (UDP blocked)
+func quicErrorReason(err error) string {
+	var certErr *tls.CertificateVerificationError
+	var transportErr *quic.TransportError
+	if errors.As(err, &certErr) || (errors.As(err, &transportErr) && transportErr.ErrorCode.IsCryptoError()) {
+		return "tls"
+	}
+	return "quic"
+}
+
+// httpGet3 HTTP/3 Get Trace Operation of HTTPGet, the requests are never sent through a proxy
+func httpGet3(ctx context.Context, out *HTTPReturn, dURL *url.URL, srcAddr string, timeout time.Duration, r *Request, body *BodyMatch) (*HTTPReturn, error) {
+	transport := r.quicTransport(srcAddr, r.Device)
+	if !r.reuse() {
+		defer transport.Close()
+	}
+
+	client := &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: r.checkRedirect(out),
+	}
+
+	req, err := r.new(ctx, dURL.String())
+	if err != nil {
+		out.Success = false
+		return out, err
+	}
+
+	trace, ht := NewClientTrace()
+	ctx = httptrace.WithClientTrace(req.Context(), trace)
+	req = req.WithContext(ctx)
+	acceptGzip(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The phases completed before the failure are kept
+		ht.Finish()
+		setStats(out, ht.Stats())
+		out.Success = false
+		out.ErrorReason = ht.errorReason(err)
+		setCertificateError(out, err)
+		// The redirect response is returned with the redirect policy error
+		if resp != nil {
+			out.Status = resp.StatusCode
+			setFinalURL(out, resp)
+		}
+		return out, err
+	}
+	return readResponse(out, resp, ht, r.skipBody(body), r.readLimit())
+}
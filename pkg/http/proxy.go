@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// proxyTraceKey context key of the trace of a request sent through a proxy, the TLS handshake with the proxy and its CONNECT response are reported to it
+type proxyTraceKey struct{}
+
+// withProxyTrace returns the context of a request sent through a proxy reporting to the trace
+func withProxyTrace(ctx context.Context, ht *HTTPTrace) context.Context {
+	return context.WithValue(ctx, proxyTraceKey{}, ht)
+}
+
+// proxyTrace returns the trace of the request of the context, nil without proxy
+func proxyTrace(ctx context.Context) *HTTPTrace {
+	ht, _ := ctx.Value(proxyTraceKey{}).(*HTTPTrace)
+	return ht
+}
+
+// proxyConn connection to an https proxy, its TLS state is hidden from the transport so that it is not reported as the one of the origin
+type proxyConn struct {
+	net.Conn
+}
+
+// dialProxyTLS returns the dialer of the https proxies, the TLS handshake with the proxy uses its own config (the system CAs without one) and is timed apart from the one with the origin
+func dialProxyTLS(serverName string, config *tls.Config) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		d := net.Dialer{}
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig := &tls.Config{}
+		if config != nil {
+			tlsConfig = config.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = serverName
+		}
+		ht := proxyTrace(ctx)
+		if ht != nil {
+			ht.Lock()
+			ht.ProxyTLSStart = time.Now()
+			ht.Unlock()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if ht != nil {
+			ht.Lock()
+			ht.ProxyTLSError = err
+			ht.ProxyTLSDone = time.Now()
+			ht.Unlock()
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return proxyConn{tlsConn}, nil
+	}
+}
+
+// proxyConnectResponse records the status of the CONNECT response of the proxy, the transport fails the request when it is not 200
+func proxyConnectResponse(ctx context.Context, _ *url.URL, _ *http.Request, res *http.Response) error {
+	if ht := proxyTrace(ctx); ht != nil {
+		ht.Lock()
+		ht.ProxyStatus = res.StatusCode
+		ht.ProxyConnectDone = time.Now()
+		ht.Unlock()
+	}
+	return nil
+}
@@ -1,35 +1,125 @@
 package http
 
 import (
+	"crypto/tls"
+	"net/http"
+	"regexp"
 	"sync"
 	"time"
+
+	"github.com/quic-go/quic-go/http3"
 )
 
 // HTTPReturn Calculated results
 type HTTPReturn struct {
-	Success               bool          `json:"success"`
-	DestAddr              string        `json:"dest_address"`
-	Status                int           `json:"status,omitempty"`
-	ContentLength         int64         `json:"content_length,omitempty"`
-	DNSLookup             time.Duration `json:"dnsLookup,omitempty"`
-	TCPConnection         time.Duration `json:"tcpConnection,omitempty"`
-	TLSHandshake          time.Duration `json:"tlsHandshake,omitempty"`
-	TLSVersion            string        `json:"tlsVersion,omitempty"`
+	Success       bool          `json:"success"`
+	DestAddr      string        `json:"dest_address"`
+	Status        int           `json:"status,omitempty"`
+	ContentLength int64         `json:"content_length,omitempty"`
+	DNSLookup     time.Duration `json:"dnsLookup,omitempty"`
+	TCPConnection time.Duration `json:"tcpConnection,omitempty"`
+	TLSHandshake  time.Duration `json:"tlsHandshake,omitempty"`
+	TLSVersion    string        `json:"tlsVersion,omitempty"`
+	TLSCipher     string        `json:"tlsCipher,omitempty"`
+	// TLSVerified the certificate chain of the server was verified, false with insecure_skip_verify
+	TLSVerified           bool          `json:"tlsVerified,omitempty"`
 	TLSEarliestCertExpiry time.Time     `json:"tlsEarliestCertExpiry,omitempty"`
 	TLSLastChainExpiry    time.Time     `json:"tlsLastChainExpiry,omitempty"`
+	RequestTransfer       time.Duration `json:"requestTransfer,omitempty"`
 	ServerProcessing      time.Duration `json:"serverProcessing,omitempty"`
 	ContentTransfer       time.Duration `json:"contentTransfer,omitempty"`
 	Total                 time.Duration `json:"total,omitempty"`
+	// Proxy the request was sent through a proxy, ProxyTLSHandshake TLS handshake with an https proxy, ProxyConnect CONNECT exchange with the proxy of the https requests
+	Proxy             bool          `json:"proxy"`
+	ProxyTLSHandshake time.Duration `json:"proxyTlsHandshake,omitempty"`
+	ProxyConnect      time.Duration `json:"proxyConnect,omitempty"`
+	// ValidStatus the final status after the redirects is one of the valid status codes
+	ValidStatus bool `json:"valid_status"`
+	// BodyMatch the body passed the patterns of the target, BodyMatchedBytes bytes of the body evaluated by them
+	BodyMatch        bool  `json:"body_match"`
+	BodyMatchedBytes int64 `json:"body_matched_bytes,omitempty"`
+	// ContentMatch the body read has the expected SHA-256 digest, always without digest, ContentSHA256 its digest when one is expected
+	ContentMatch  bool   `json:"content_match"`
+	ContentSHA256 string `json:"content_sha256,omitempty"`
+	// BodyBytes bytes of the body read, decompressed, WireBytes their size on the wire with the ContentEncoding of the response
+	BodyBytes       int64  `json:"body_bytes"`
+	WireBytes       int64  `json:"wire_bytes"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	// BodyTruncated the body was larger than the read limit, TransferTime time from the start of the probe to the last byte of the body read
+	BodyTruncated bool          `json:"body_truncated,omitempty"`
+	TransferTime  time.Duration `json:"transfer_time,omitempty"`
+	// Redirects followed by the probe, FinalScheme and FinalHost of the URL of the final response
+	Redirects   int    `json:"redirects"`
+	FinalScheme string `json:"final_scheme,omitempty"`
+	FinalHost   string `json:"final_host,omitempty"`
+	// ConnectionReused the final request was sent on a connection kept open by a previous probe
+	ConnectionReused bool `json:"connection_reused"`
+	// HTTPVersion of the probe: 3 over QUIC or 1.1 over TCP
+	HTTPVersion string `json:"http_version"`
+	// ErrorReason phase of the failure: dns, connect, quic, tls, proxy_tls, proxy_auth, proxy_connect, timeout, redirect, max_redirects, request, transfer, status, body or content
+	ErrorReason string `json:"error_reason,omitempty"`
+}
+
+// Succeeded reports if the probe got a valid status and body
+func (r *HTTPReturn) Succeeded() bool {
+	return r.Success && r.ValidStatus && r.BodyMatch && r.ContentMatch
+}
+
+// Request method, headers and body of the probe requests
+type Request struct {
+	Method string
+	Header http.Header
+	Body   string
+	// TLS client config of the HTTPS requests, the transports are shared by the requests of the same TLSID
+	TLS   *tls.Config
+	TLSID string
+	// ProxyTLS TLS client config of the connections to an https proxy, the transports are shared by the requests of the same ProxyTLSID
+	ProxyTLS   *tls.Config
+	ProxyTLSID string
+	// ProxyFromEnvironment sends the requests without proxy URL through the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables
+	ProxyFromEnvironment bool
+	// Device the sockets of the requests without proxy are bound to, the interface of the source address
+	Device string
+	// KeepAlive persistent transport of a target reusing its connection between the probes, nil opens a new connection for every probe
+	KeepAlive *KeepAlive
+	// FollowRedirects follows up to MaxRedirects redirects, a redirect fails the probe when it is not set
+	FollowRedirects bool
+	MaxRedirects    int
+	// ReadLimit bytes of the body read by the probe, 0 reads the whole body
+	ReadLimit int64
+	// HTTP3 sends the requests over QUIC, without proxy
+	HTTP3 bool
+}
+
+// KeepAlive persistent transport of a target, a copy of the shared transport of its requests made by the first probe or its HTTP/3 transport
+type KeepAlive struct {
+	base      *http.Transport
+	transport *http.Transport
+	// quic HTTP/3 transport of the target
+	quic *http3.Transport
+	sync.Mutex
+}
+
+// BodyMatch patterns evaluated against the first SizeLimit bytes of the response body
+type BodyMatch struct {
+	FailIfMatches    []*regexp.Regexp
+	FailIfNotMatches []*regexp.Regexp
+	SizeLimit        int64
+	// SHA256 lower case hex digest of the body read, not checked when empty
+	SHA256 string
 }
 
 // HTTPTimelineStats http timeline stats
 type HTTPTimelineStats struct {
-	DNSLookup        time.Duration `json:"dnsLookup,omitempty"`
-	TCPConnection    time.Duration `json:"tcpConnection,omitempty"`
-	TLSHandshake     time.Duration `json:"tlsHandshake,omitempty"`
-	ServerProcessing time.Duration `json:"serverProcessing,omitempty"`
-	ContentTransfer  time.Duration `json:"contentTransfer,omitempty"`
-	Total            time.Duration `json:"total,omitempty"`
+	DNSLookup         time.Duration `json:"dnsLookup,omitempty"`
+	TCPConnection     time.Duration `json:"tcpConnection,omitempty"`
+	TLSHandshake      time.Duration `json:"tlsHandshake,omitempty"`
+	ProxyTLSHandshake time.Duration `json:"proxyTlsHandshake,omitempty"`
+	ProxyConnect      time.Duration `json:"proxyConnect,omitempty"`
+	RequestTransfer   time.Duration `json:"requestTransfer,omitempty"`
+	ServerProcessing  time.Duration `json:"serverProcessing,omitempty"`
+	ContentTransfer   time.Duration `json:"contentTransfer,omitempty"`
+	Total             time.Duration `json:"total,omitempty"`
 }
 
 // HTTPTrace http trace
@@ -50,9 +140,18 @@ type HTTPTrace struct {
 	ConnectStart         time.Time     `json:"connectStart,omitempty"`
 	ConnectDone          time.Time     `json:"connectDone,omitempty"`
 	GotConnect           time.Time     `json:"gotConnect,omitempty"`
+	WroteRequest         time.Time     `json:"wroteRequest,omitempty"`
 	GotFirstResponseByte time.Time     `json:"gotFirstResponseByte,omitempty"`
 	TLSHandshakeStart    time.Time     `json:"tlsHandshakeStart,omitempty"`
 	TLSHandshakeDone     time.Time     `json:"tlsHandshakeDone,omitempty"`
+	ProxyTLSStart        time.Time     `json:"proxyTlsStart,omitempty"`
+	ProxyTLSDone         time.Time     `json:"proxyTlsDone,omitempty"`
+	ProxyConnectDone     time.Time     `json:"proxyConnectDone,omitempty"`
+	ProxyStatus          int           `json:"proxyStatus,omitempty"`
 	Done                 time.Time     `json:"done,omitempty"`
+	DNSError             error         `json:"-"`
+	ConnectError         error         `json:"-"`
+	TLSError             error         `json:"-"`
+	ProxyTLSError        error         `json:"-"`
 	sync.RWMutex                       // Because the timeout setting may cause trace to read and write coexist, we need the lock
 }
@@ -1,15 +1,15 @@
 package icmp
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/syepes/network_exporter/pkg/common"
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
-	"golang.org/x/net/ipv6"
 )
 
 // https://hechao.li/2018/09/27/How-Is-Ping-Deduplexed/
@@ -18,8 +18,121 @@ const (
 	protocolIPv6ICMP = 58 // ICMP for IPv6
 )
 
-// Icmp Validate IP and check the version
-func Icmp(destAddr string, srcAddr string, ttl int, pid int, timeout time.Duration, seq int, payloadSize int, ipv6 bool) (hop common.IcmpReturn, err error) {
+const (
+	// Type, code, checksum, identifier and sequence number of an echo message
+	echoHeaderLen = 8
+	// Echo header followed by the originate, receive and transmit timestamps of a timestamp message
+	timestampLen = echoHeaderLen + 12
+	// ICMP types of the timestamp request and reply (RFC 792)
+	typeTimestamp      = 13
+	typeTimestampReply = 14
+	// Receive buffer size, grown by replyBufferSize for the payloads larger than the Ethernet MTU
+	readBufferSize = 1500
+	// Largest IPv4 header, with options
+	maxIPv4HeaderLen = 60
+)
+
+// ErrHopLimit the hop limit of the IPv6 echo requests could not be set on the socket
+var ErrHopLimit = errors.New("setting the hop limit failed")
+
+// ErrTimestampUnsupported the timestamp requests can not be sent to the destination, ICMPv6 has no timestamp message
+var ErrTimestampUnsupported = errors.New("timestamp requests are not supported")
+
+// TimestampReturn timestamp reply, the timestamps are milliseconds since midnight UT (RFC 792)
+// Originate and Returned are read from the clock of the prober, Receive and Transmit from the one of the destination
+type TimestampReturn struct {
+	Success   bool
+	Addr      string
+	Elapsed   time.Duration
+	Originate uint32
+	Receive   uint32
+	Transmit  uint32
+	Returned  uint32
+}
+
+// msSinceMidnight returns the milliseconds since midnight UT of t, the unit of the timestamp messages
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	return uint32(t.Sub(t.Truncate(24 * time.Hour)).Milliseconds())
+}
+
+// bufferPool send and receive buffers reused across the probes
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, readBufferSize)
+		return &b
+	},
+}
+
+// getBuffer returns a pooled buffer of the given size
+func getBuffer(size int) *[]byte {
+	bp := bufferPool.Get().(*[]byte)
+	if cap(*bp) < size {
+		*bp = make([]byte, size)
+	}
+	*bp = (*bp)[:size]
+	return bp
+}
+
+// replyBufferSize returns the receive buffer size holding the echo reply of a payload
+func replyBufferSize(payloadSize int) int {
+	return max(readBufferSize, maxIPv4HeaderLen+echoHeaderLen+payloadSize)
+}
+
+// marshalEcho writes an echo request in b, the payload is the sequence number (4 bytes little endian) followed by filler bytes
+// The result is identical to icmp.Message.Marshal without pseudo header
+func marshalEcho(b []byte, typ byte, id int, seq int, checksum bool) []byte {
+	b[0], b[1], b[2], b[3] = typ, 0, 0, 0
+	binary.BigEndian.PutUint16(b[4:6], uint16(id))
+	binary.BigEndian.PutUint16(b[6:8], uint16(seq))
+
+	var bs [4]byte
+	binary.LittleEndian.PutUint32(bs[:], uint32(seq))
+	payload := b[echoHeaderLen:]
+	for i := copy(payload, bs[:]); i < len(payload); i++ {
+		payload[i] = 'x'
+	}
+
+	if checksum {
+		s := internetChecksum(b)
+		b[2] ^= byte(s)
+		b[3] ^= byte(s >> 8)
+	}
+	return b
+}
+
+// marshalTimestamp writes a timestamp request in b with the originate timestamp, the receive and transmit ones are set by the destination
+func marshalTimestamp(b []byte, id int, seq int, originate uint32) []byte {
+	b[0], b[1], b[2], b[3] = typeTimestamp, 0, 0, 0
+	binary.BigEndian.PutUint16(b[4:6], uint16(id))
+	binary.BigEndian.PutUint16(b[6:8], uint16(seq))
+	binary.BigEndian.PutUint32(b[8:12], originate)
+	clear(b[12:timestampLen])
+
+	s := internetChecksum(b[:timestampLen])
+	b[2] ^= byte(s)
+	b[3] ^= byte(s >> 8)
+	return b[:timestampLen]
+}
+
+// internetChecksum RFC 1071 checksum as computed by golang.org/x/net/icmp
+func internetChecksum(b []byte) uint16 {
+	csumcv := len(b) - 1
+	s := uint32(0)
+	for i := 0; i < csumcv; i += 2 {
+		s += uint32(b[i+1])<<8 | uint32(b[i])
+	}
+	if csumcv&1 == 0 {
+		s += uint32(b[csumcv])
+	}
+	s = s>>16 + s&0xffff
+	s = s + s>>16
+	return ^uint16(s)
+}
+
+// Icmp Validate IP and check the version, the probe returns as soon as the context is canceled
+// A tos >0 marks the echo requests with the TOS (IPv4) or traffic class (IPv6), the requests are sent unmarked when it can not be set
+func Icmp(ctx context.Context, destAddr string, srcAddr string, ttl int, pid int, timeout time.Duration, seq int, payloadSize int, tos int, ipv6 bool) (hop common.IcmpReturn, err error) {
 	dstIp := net.ParseIP(destAddr)
 	if dstIp == nil {
 		return hop, fmt.Errorf("destination ip: %v is invalid", destAddr)
@@ -34,234 +147,42 @@ func Icmp(destAddr string, srcAddr string, ttl int, pid int, timeout time.Durati
 		}
 
 		if p4 := dstIp.To4(); len(p4) == net.IPv4len {
-			return icmpIpv4(srcAddr, &ipAddr, ttl, pid, timeout, seq, payloadSize)
+			return icmpIpv4(ctx, srcAddr, &ipAddr, ttl, pid, timeout, seq, payloadSize, tos)
 		}
 		if ipv6 {
-			return icmpIpv6(srcAddr, &ipAddr, ttl, pid, timeout, seq, payloadSize)
+			return icmpIpv6(ctx, srcAddr, &ipAddr, ttl, pid, timeout, seq, payloadSize, tos)
 		} else {
 			return hop, nil
 		}
 	}
 
 	if p4 := dstIp.To4(); len(p4) == net.IPv4len {
-		return icmpIpv4("0.0.0.0", &ipAddr, ttl, pid, timeout, seq, payloadSize)
+		return icmpIpv4(ctx, "0.0.0.0", &ipAddr, ttl, pid, timeout, seq, payloadSize, tos)
 	}
 	if ipv6 {
-		return icmpIpv6("::", &ipAddr, ttl, pid, timeout, seq, payloadSize)
+		return icmpIpv6(ctx, "::", &ipAddr, ttl, pid, timeout, seq, payloadSize, tos)
 	} else {
 		return hop, nil
 	}
 }
 
-func icmpIpv4(localAddr string, dst net.Addr, ttl int, pid int, timeout time.Duration, seq int, payloadSize int) (hop common.IcmpReturn, err error) {
-	hop.Success = false
-	start := time.Now()
-	c, err := icmp.ListenPacket("ip4:icmp", localAddr)
-	if err != nil {
-		return hop, err
-	}
-	defer c.Close()
-
-	if err = c.IPv4PacketConn().SetTTL(ttl); err != nil {
-		return hop, err
-	}
-
-	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return hop, err
-	}
-
-	// Create payload: 4-byte sequence number + (payloadSize - 4) filler bytes
-	bs := make([]byte, 4)
-	binary.LittleEndian.PutUint32(bs, uint32(seq))
-
-	// Generate dynamic payload with specified size
-	payload := make([]byte, payloadSize)
-	copy(payload, bs) // First 4 bytes are sequence number
-	for i := 4; i < payloadSize; i++ {
-		payload[i] = 'x' // Fill remaining bytes
-	}
-
-	wm := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   pid,
-			Seq:  seq,
-			Data: payload,
-		},
-	}
-
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		return hop, err
-	}
-
-	if _, err := c.WriteTo(wb, dst); err != nil {
-		return hop, err
-	}
-
-	peer, _, err := listenForSpecific4(c, payload, pid, seq, wb)
-	if err != nil {
-		return hop, err
-	}
-
-	elapsed := time.Since(start)
-	hop.Elapsed = elapsed
-	hop.Addr = peer
-	hop.Success = true
-	return hop, err
-}
-
-func icmpIpv6(localAddr string, dst net.Addr, ttl, pid int, timeout time.Duration, seq int, payloadSize int) (hop common.IcmpReturn, err error) {
-	hop.Success = false
-	start := time.Now()
-	c, err := icmp.ListenPacket("ip6:ipv6-icmp", localAddr)
-	if err != nil {
-		return hop, err
-	}
-	defer c.Close()
-
-	if err = c.IPv6PacketConn().SetHopLimit(ttl); err != nil {
-		return hop, err
-	}
-
-	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return hop, err
-	}
-
-	// Create payload: 4-byte sequence number + (payloadSize - 4) filler bytes
-	bs := make([]byte, 4)
-	binary.LittleEndian.PutUint32(bs, uint32(seq))
-
-	// Generate dynamic payload with specified size
-	payload := make([]byte, payloadSize)
-	copy(payload, bs) // First 4 bytes are sequence number
-	for i := 4; i < payloadSize; i++ {
-		payload[i] = 'x' // Fill remaining bytes
-	}
-
-	wm := icmp.Message{
-		Type: ipv6.ICMPTypeEchoRequest,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   pid,
-			Seq:  seq,
-			Data: payload,
-		},
-	}
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		return hop, err
-	}
-
-	if _, err := c.WriteTo(wb, dst); err != nil {
-		return hop, err
-	}
-
-	peer, _, err := listenForSpecific6(c, payload, pid, seq)
-	if err != nil {
-		return hop, err
+// Timestamp sends an ICMP timestamp request instead of an echo request, only IPv4 has timestamp messages
+// A tos >0 marks the request with the TOS, it is sent unmarked when it can not be set
+func Timestamp(ctx context.Context, destAddr string, srcAddr string, pid int, timeout time.Duration, seq int, tos int) (ts TimestampReturn, err error) {
+	dstIp := net.ParseIP(destAddr)
+	if dstIp == nil {
+		return ts, fmt.Errorf("destination ip: %v is invalid", destAddr)
 	}
-
-	elapsed := time.Since(start)
-	hop.Elapsed = elapsed
-	hop.Addr = peer
-	hop.Success = true
-	return hop, err
-}
-
-// Listen IPv4 icmp returned packet and verify the content
-func listenForSpecific4(conn *icmp.PacketConn, neededBody []byte, needID int, needSeq int, sent []byte) (string, []byte, error) {
-	for {
-		b := make([]byte, 1500)
-		n, peer, err := conn.ReadFrom(b)
-		if err != nil {
-			if neterr, ok := err.(*net.OpError); ok && neterr.Temporary() {
-				return "", []byte{}, neterr
-			}
-		}
-		if n == 0 {
-			continue
-		}
-
-		x, err := icmp.ParseMessage(protocolICMP, b[:n])
-		if err != nil {
-			continue
-		}
-
-		if x.Type.(ipv4.ICMPType) == ipv4.ICMPTypeTimeExceeded {
-			body := x.Body.(*icmp.TimeExceeded).Data
-			oh, err := ipv4.ParseHeader(body)
-			if err != nil {
-				continue
-			}
-			x, err := icmp.ParseMessage(protocolICMP, body[oh.Len:])
-			if err != nil {
-				continue
-			}
-
-			switch x.Body.(type) {
-			case *icmp.Echo:
-				msg := x.Body.(*icmp.Echo)
-				if msg.ID == needID && msg.Seq == needSeq {
-					return peer.String(), []byte{}, nil
-				}
-			default:
-			}
-		}
-
-		if x.Type.(ipv4.ICMPType) == ipv4.ICMPTypeEchoReply {
-			b, _ := x.Body.Marshal(protocolICMP)
-			if string(b[4:]) != string(neededBody) || x.Body.(*icmp.Echo).ID != needID {
-				continue
-			}
-
-			return peer.String(), b[4:], nil
-		}
+	if p4 := dstIp.To4(); len(p4) != net.IPv4len {
+		return ts, ErrTimestampUnsupported
 	}
-}
-
-// Listen IPv6 icmp returned packet and verify the content
-func listenForSpecific6(conn *icmp.PacketConn, neededBody []byte, needID int, needSeq int) (string, []byte, error) {
-	for {
-		b := make([]byte, 1500)
-		n, peer, err := conn.ReadFrom(b)
-		if err != nil {
-			if neterr, ok := err.(*net.OpError); ok && neterr.Temporary() {
-				return "", []byte{}, neterr
-			}
-		}
-		if n == 0 {
-			continue
-		}
-
-		x, err := icmp.ParseMessage(protocolIPv6ICMP, b[:n])
-		if err != nil {
-			continue
-		}
-
-		if x.Type.(ipv6.ICMPType) == ipv6.ICMPTypeTimeExceeded {
-			body := x.Body.(*icmp.TimeExceeded).Data
-			x, _ := icmp.ParseMessage(protocolIPv6ICMP, body[40:])
-			switch x.Body.(type) {
-			case *icmp.Echo:
-				// Verification
-				msg := x.Body.(*icmp.Echo)
-				if msg.ID == needID && msg.Seq == needSeq {
-					return peer.String(), []byte{}, nil
-				}
-			default:
-				// ignore
-			}
-		}
 
-		if x.Type.(ipv6.ICMPType) == ipv6.ICMPTypeEchoReply {
-			b, _ := x.Body.Marshal(protocolICMP)
-			if string(b[4:]) != string(neededBody) || x.Body.(*icmp.Echo).ID != needID {
-				continue
-			}
-
-			return peer.String(), b[4:], nil
+	localAddr := "0.0.0.0"
+	if srcAddr != "" {
+		if net.ParseIP(srcAddr) == nil {
+			return ts, fmt.Errorf("source ip: %v is invalid, target: %v", srcAddr, destAddr)
 		}
+		localAddr = srcAddr
 	}
+	return timestampIpv4(ctx, localAddr, &net.IPAddr{IP: dstIp}, pid, timeout, seq, tos)
 }
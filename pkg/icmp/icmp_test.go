@@ -0,0 +1,110 @@
+package icmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// echoData payload of the echo requests: the sequence number (4 bytes little endian) followed by filler bytes
+func echoData(seq int, payloadSize int) []byte {
+	data := bytes.Repeat([]byte{'x'}, payloadSize)
+	var bs [4]byte
+	binary.LittleEndian.PutUint32(bs[:], uint32(seq))
+	copy(data, bs[:])
+	return data
+}
+
+func TestMarshalEcho(t *testing.T) {
+	for _, payloadSize := range []int{0, 2, 4, 56, 1472} {
+		for _, seq := range []int{0, 1, 0x1234, 65535} {
+			for _, typ := range []icmp.Type{ipv4.ICMPTypeEcho, ipv6.ICMPTypeEchoRequest} {
+				msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: 0xbeef, Seq: seq, Data: echoData(seq, payloadSize)}}
+				want, err := msg.Marshal(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// A dirty pooled buffer
+				bp := getBuffer(echoHeaderLen + payloadSize)
+				for i := range *bp {
+					(*bp)[i] = 0xff
+				}
+				got := marshalEcho(*bp, byte(msgType(typ)), 0xbeef, seq, typ == ipv4.ICMPTypeEcho)
+				if !bytes.Equal(got, want) {
+					t.Errorf("%v payload %d seq %d: %x, want %x", typ, payloadSize, seq, got, want)
+				}
+				bufferPool.Put(bp)
+			}
+		}
+	}
+}
+
+// msgType ICMP type number of the message type
+func msgType(typ icmp.Type) int {
+	switch typ := typ.(type) {
+	case ipv4.ICMPType:
+		return int(typ)
+	case ipv6.ICMPType:
+		return int(typ)
+	}
+	return -1
+}
+
+func TestMarshalTimestamp(t *testing.T) {
+	bp := getBuffer(readBufferSize)
+	defer bufferPool.Put(bp)
+	for i := range *bp {
+		(*bp)[i] = 0xff
+	}
+	b := marshalTimestamp(*bp, 0xbeef, 7, 12345678)
+	if len(b) != timestampLen || b[0] != typeTimestamp {
+		t.Fatalf("timestamp request %x", b)
+	}
+	if binary.BigEndian.Uint32(b[8:12]) != 12345678 || binary.BigEndian.Uint64(b[12:20]) != 0 {
+		t.Errorf("timestamps %x, want the originate one only", b[8:])
+	}
+	// The checksum of a valid message including its checksum is 0
+	if s := internetChecksum(b); s != 0 {
+		t.Errorf("checksum %#x, want 0", s)
+	}
+}
+
+func TestGetBuffer(t *testing.T) {
+	for _, size := range []int{1, readBufferSize, 9000} {
+		bp := getBuffer(size)
+		if len(*bp) != size {
+			t.Errorf("buffer of %d bytes, want %d", len(*bp), size)
+		}
+		bufferPool.Put(bp)
+	}
+	if n := replyBufferSize(56); n != readBufferSize {
+		t.Errorf("reply buffer of %d bytes, want %d", n, readBufferSize)
+	}
+	if n := replyBufferSize(9000); n != maxIPv4HeaderLen+echoHeaderLen+9000 {
+		t.Errorf("reply buffer of %d bytes, want %d", n, maxIPv4HeaderLen+echoHeaderLen+9000)
+	}
+}
+
+func BenchmarkMarshalEcho(b *testing.B) {
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bp := getBuffer(echoHeaderLen + 56)
+			marshalEcho(*bp, byte(ipv4.ICMPTypeEcho), 0xbeef, i, true)
+			bufferPool.Put(bp)
+		}
+	})
+	b.Run("message", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 0xbeef, Seq: i, Data: echoData(i, 56)}}
+			if _, err := msg.Marshal(nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
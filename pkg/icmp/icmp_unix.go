@@ -0,0 +1,318 @@
+//go:build !windows
+
+package icmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Check verifies that the probes are allowed to open their raw sockets, the error explains how to grant the privilege
+// Only the permission errors are reported, the other failures (no IPv6 stack...) are reported by the probes
+func Check(ipv6 bool) error {
+	networks := [][2]string{{"ip4:icmp", "0.0.0.0"}}
+	if ipv6 {
+		networks = append(networks, [2]string{"ip6:ipv6-icmp", "::"})
+	}
+	for _, n := range networks {
+		c, err := icmp.ListenPacket(n[0], n[1])
+		if err != nil {
+			if errors.Is(err, os.ErrPermission) {
+				return fmt.Errorf("opening %s raw socket: %w (run as root or grant the CAP_NET_RAW capability: setcap cap_net_raw+ep network_exporter)", n[0], err)
+			}
+			continue
+		}
+		c.Close()
+	}
+	return nil
+}
+
+func icmpIpv4(ctx context.Context, localAddr string, dst net.Addr, ttl int, pid int, timeout time.Duration, seq int, payloadSize int, tos int) (hop common.IcmpReturn, err error) {
+	hop.Success = false
+	start := time.Now()
+	c, err := icmp.ListenPacket("ip4:icmp", localAddr)
+	if err != nil {
+		return hop, err
+	}
+	defer c.Close()
+	// Closing the socket unblocks the pending read
+	defer context.AfterFunc(ctx, func() { c.Close() })()
+
+	if err = c.IPv4PacketConn().SetTTL(ttl); err != nil {
+		return hop, err
+	}
+	// The TTL of the replies is not reported when the control message is not supported
+	_ = c.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	if tos > 0 {
+		if err := c.IPv4PacketConn().SetTOS(tos); err != nil {
+			common.TOSFailures.Fail("ICMP", tos, err)
+		}
+	}
+
+	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return hop, err
+	}
+
+	wbp := getBuffer(echoHeaderLen + payloadSize)
+	defer bufferPool.Put(wbp)
+	wb := marshalEcho(*wbp, byte(ipv4.ICMPTypeEcho), pid, seq, true)
+
+	if _, err := c.WriteTo(wb, dst); err != nil {
+		return hop, err
+	}
+
+	peer, replyTTL, _, err := listenForSpecific4(ctx, c, wb[echoHeaderLen:], pid, seq, wb, &hop.Others)
+	if err != nil {
+		return hop, err
+	}
+
+	elapsed := time.Since(start)
+	hop.Elapsed = elapsed
+	hop.Addr = peer
+	hop.TTL = replyTTL
+	hop.Success = true
+	return hop, err
+}
+
+func icmpIpv6(ctx context.Context, localAddr string, dst net.Addr, ttl, pid int, timeout time.Duration, seq int, payloadSize int, tos int) (hop common.IcmpReturn, err error) {
+	hop.Success = false
+	start := time.Now()
+	c, err := icmp.ListenPacket("ip6:ipv6-icmp", localAddr)
+	if err != nil {
+		return hop, err
+	}
+	defer c.Close()
+	// Closing the socket unblocks the pending read
+	defer context.AfterFunc(ctx, func() { c.Close() })()
+
+	// A ttl of 0 keeps the hop limit of the system
+	if ttl > 0 {
+		if err = c.IPv6PacketConn().SetHopLimit(ttl); err != nil {
+			return hop, fmt.Errorf("%w: %v", ErrHopLimit, err)
+		}
+	}
+	// The hop limit of the replies is not reported when the control message is not supported
+	_ = c.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	if tos > 0 {
+		if err := c.IPv6PacketConn().SetTrafficClass(tos); err != nil {
+			common.TOSFailures.Fail("ICMP", tos, err)
+		}
+	}
+
+	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return hop, err
+	}
+
+	// The ICMPv6 checksum covers a pseudo header and is computed by the kernel
+	wbp := getBuffer(echoHeaderLen + payloadSize)
+	defer bufferPool.Put(wbp)
+	wb := marshalEcho(*wbp, byte(ipv6.ICMPTypeEchoRequest), pid, seq, false)
+
+	if _, err := c.WriteTo(wb, dst); err != nil {
+		return hop, err
+	}
+
+	peer, replyTTL, _, err := listenForSpecific6(ctx, c, wb[echoHeaderLen:], pid, seq, &hop.Others)
+	if err != nil {
+		return hop, err
+	}
+
+	elapsed := time.Since(start)
+	hop.Elapsed = elapsed
+	hop.Addr = peer
+	hop.TTL = replyTTL
+	hop.Success = true
+	return hop, err
+}
+
+func timestampIpv4(ctx context.Context, localAddr string, dst net.Addr, pid int, timeout time.Duration, seq int, tos int) (ts TimestampReturn, err error) {
+	c, err := icmp.ListenPacket("ip4:icmp", localAddr)
+	if err != nil {
+		return ts, err
+	}
+	defer c.Close()
+	// Closing the socket unblocks the pending read
+	defer context.AfterFunc(ctx, func() { c.Close() })()
+
+	if tos > 0 {
+		if err := c.IPv4PacketConn().SetTOS(tos); err != nil {
+			common.TOSFailures.Fail("ICMP", tos, err)
+		}
+	}
+	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return ts, err
+	}
+
+	wbp := getBuffer(timestampLen)
+	defer bufferPool.Put(wbp)
+	start := time.Now()
+	ts.Originate = msSinceMidnight(start)
+	wb := marshalTimestamp(*wbp, pid, seq, ts.Originate)
+
+	if _, err := c.WriteTo(wb, dst); err != nil {
+		return ts, err
+	}
+
+	rbp := getBuffer(readBufferSize)
+	defer bufferPool.Put(rbp)
+	b := *rbp
+	for {
+		n, peer, err := c.ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ts, ctx.Err()
+			}
+			return ts, err
+		}
+		// The replies of the other probes and the other ICMP messages are skipped
+		if n < timestampLen || b[0] != typeTimestampReply || int(binary.BigEndian.Uint16(b[4:6])) != pid&0xffff || int(binary.BigEndian.Uint16(b[6:8])) != seq&0xffff || binary.BigEndian.Uint32(b[8:12]) != ts.Originate {
+			continue
+		}
+
+		returned := time.Now()
+		ts.Elapsed = returned.Sub(start)
+		ts.Returned = msSinceMidnight(returned)
+		ts.Receive = binary.BigEndian.Uint32(b[12:16])
+		ts.Transmit = binary.BigEndian.Uint32(b[16:20])
+		ts.Addr = peer.String()
+		ts.Success = true
+		return ts, nil
+	}
+}
+
+// Listen IPv4 icmp returned packet and verify the content, the TTL of the packet is read from its control message
+// The sequence numbers of the other echo replies of needID are appended to others
+func listenForSpecific4(ctx context.Context, conn *icmp.PacketConn, neededBody []byte, needID int, needSeq int, sent []byte, others *[]int) (string, int, []byte, error) {
+	rbp := getBuffer(replyBufferSize(len(neededBody)))
+	defer bufferPool.Put(rbp)
+	b := *rbp
+
+	for {
+		n, cm, peer, err := conn.IPv4PacketConn().ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", 0, []byte{}, ctx.Err()
+			}
+			if neterr, ok := err.(*net.OpError); ok && neterr.Temporary() {
+				return "", 0, []byte{}, neterr
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		replyTTL := 0
+		if cm != nil {
+			replyTTL = cm.TTL
+		}
+
+		x, err := icmp.ParseMessage(protocolICMP, b[:n])
+		if err != nil {
+			continue
+		}
+
+		if x.Type.(ipv4.ICMPType) == ipv4.ICMPTypeTimeExceeded {
+			body := x.Body.(*icmp.TimeExceeded).Data
+			oh, err := ipv4.ParseHeader(body)
+			if err != nil {
+				continue
+			}
+			x, err := icmp.ParseMessage(protocolICMP, body[oh.Len:])
+			if err != nil {
+				continue
+			}
+
+			switch x.Body.(type) {
+			case *icmp.Echo:
+				msg := x.Body.(*icmp.Echo)
+				if msg.ID == needID && msg.Seq == needSeq {
+					return peer.String(), replyTTL, []byte{}, nil
+				}
+			default:
+			}
+		}
+
+		if x.Type.(ipv4.ICMPType) == ipv4.ICMPTypeEchoReply {
+			msg := x.Body.(*icmp.Echo)
+			if !bytes.Equal(msg.Data, neededBody) || msg.ID != needID {
+				if msg.ID == needID && msg.Seq != needSeq&0xffff {
+					*others = append(*others, msg.Seq)
+				}
+				continue
+			}
+
+			// The message data points into the reused receive buffer
+			return peer.String(), replyTTL, append([]byte{}, msg.Data...), nil
+		}
+	}
+}
+
+// Listen IPv6 icmp returned packet and verify the content, the hop limit of the packet is read from its control message
+// The sequence numbers of the other echo replies of needID are appended to others
+func listenForSpecific6(ctx context.Context, conn *icmp.PacketConn, neededBody []byte, needID int, needSeq int, others *[]int) (string, int, []byte, error) {
+	rbp := getBuffer(replyBufferSize(len(neededBody)))
+	defer bufferPool.Put(rbp)
+	b := *rbp
+
+	for {
+		n, cm, peer, err := conn.IPv6PacketConn().ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", 0, []byte{}, ctx.Err()
+			}
+			if neterr, ok := err.(*net.OpError); ok && neterr.Temporary() {
+				return "", 0, []byte{}, neterr
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		replyTTL := 0
+		if cm != nil {
+			replyTTL = cm.HopLimit
+		}
+
+		x, err := icmp.ParseMessage(protocolIPv6ICMP, b[:n])
+		if err != nil {
+			continue
+		}
+
+		if x.Type.(ipv6.ICMPType) == ipv6.ICMPTypeTimeExceeded {
+			body := x.Body.(*icmp.TimeExceeded).Data
+			x, _ := icmp.ParseMessage(protocolIPv6ICMP, body[40:])
+			switch x.Body.(type) {
+			case *icmp.Echo:
+				// Verification
+				msg := x.Body.(*icmp.Echo)
+				if msg.ID == needID && msg.Seq == needSeq {
+					return peer.String(), replyTTL, []byte{}, nil
+				}
+			default:
+				// ignore
+			}
+		}
+
+		if x.Type.(ipv6.ICMPType) == ipv6.ICMPTypeEchoReply {
+			msg := x.Body.(*icmp.Echo)
+			if !bytes.Equal(msg.Data, neededBody) || msg.ID != needID {
+				if msg.ID == needID && msg.Seq != needSeq&0xffff {
+					*others = append(*others, msg.Seq)
+				}
+				continue
+			}
+
+			// The message data points into the reused receive buffer
+			return peer.String(), replyTTL, append([]byte{}, msg.Data...), nil
+		}
+	}
+}
@@ -0,0 +1,211 @@
+//go:build windows
+
+package icmp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// The probes use the ICMP helper API, it does not require the Administrator privilege of the raw sockets nor a firewall rule for the replies
+// The echo identifier and sequence are assigned and matched by the system
+var (
+	iphlpapi            = syscall.NewLazyDLL("iphlpapi.dll")
+	procIcmpCreateFile  = iphlpapi.NewProc("IcmpCreateFile")
+	procIcmp6CreateFile = iphlpapi.NewProc("Icmp6CreateFile")
+	procIcmpCloseHandle = iphlpapi.NewProc("IcmpCloseHandle")
+	procIcmpSendEcho2Ex = iphlpapi.NewProc("IcmpSendEcho2Ex")
+	procIcmp6SendEcho2  = iphlpapi.NewProc("Icmp6SendEcho2")
+)
+
+// IP_STATUS codes of the echo replies (ipexport.h)
+const (
+	ipSuccess           = 0
+	ipBufTooSmall       = 11001
+	ipDestNetUnreach    = 11002
+	ipDestHostUnreach   = 11003
+	ipDestProtUnreach   = 11004
+	ipDestPortUnreach   = 11005
+	ipReqTimedOut       = 11010
+	ipTTLExpiredTransit = 11013
+	ipBadDestination    = 11018
+	ipGeneralFailure    = 11050
+)
+
+const (
+	// Offsets of the address and status in ICMP_ECHO_REPLY (IPv4) and ICMPV6_ECHO_REPLY, the IPv6 address follows the port and flow info of IPV6_ADDRESS_EX
+	reply4AddrOffset   = 0
+	reply4StatusOffset = 4
+	reply6AddrOffset   = 6
+	reply6StatusOffset = 28
+	// Offset of the TTL of the reply options in ICMP_ECHO_REPLY, after the data pointer, ICMPV6_ECHO_REPLY has no hop limit
+	reply4TTLOffset = 16 + unsafe.Sizeof(uintptr(0))
+	// Room of the reply buffer for the reply structure, an ICMP error and the IO_STATUS_BLOCK of IcmpSendEcho2
+	replyOverhead = 64 + 8 + 16
+)
+
+// ipOptionInformation IP_OPTION_INFORMATION request options
+type ipOptionInformation struct {
+	TTL         uint8
+	Tos         uint8
+	Flags       uint8
+	OptionsSize uint8
+	OptionsData uintptr
+}
+
+// Check verifies that the ICMP helper API is available
+func Check(ipv6 bool) error {
+	procs := []*syscall.LazyProc{procIcmpCreateFile, procIcmpCloseHandle, procIcmpSendEcho2Ex}
+	if ipv6 {
+		procs = append(procs, procIcmp6CreateFile, procIcmp6SendEcho2)
+	}
+	for _, p := range procs {
+		if err := p.Find(); err != nil {
+			return fmt.Errorf("ICMP helper API unavailable: %w", err)
+		}
+	}
+
+	h, err := createFile(procIcmpCreateFile)
+	if err != nil {
+		return err
+	}
+	procIcmpCloseHandle.Call(uintptr(h))
+	return nil
+}
+
+func icmpIpv4(ctx context.Context, localAddr string, dst net.Addr, ttl int, pid int, timeout time.Duration, seq int, payloadSize int, tos int) (hop common.IcmpReturn, err error) {
+	src := net.ParseIP(localAddr).To4()
+	dstIP := dst.(*net.IPAddr).IP.To4()
+
+	return sendEcho(ctx, procIcmpCreateFile, ttl, tos, timeout, seq, payloadSize, func(h syscall.Handle, data []byte, opts *ipOptionInformation, reply []byte, ms uint32) (uintptr, error) {
+		n, _, err := procIcmpSendEcho2Ex.Call(uintptr(h), 0, 0, 0,
+			uintptr(binary.LittleEndian.Uint32(src)), uintptr(binary.LittleEndian.Uint32(dstIP)),
+			uintptr(unsafe.Pointer(unsafe.SliceData(data))), uintptr(len(data)), uintptr(unsafe.Pointer(opts)),
+			uintptr(unsafe.Pointer(&reply[0])), uintptr(len(reply)), uintptr(ms))
+		return n, err
+	}, func(reply []byte) (string, uint32, int) {
+		status := binary.LittleEndian.Uint32(reply[reply4StatusOffset:])
+		return net.IP(reply[reply4AddrOffset : reply4AddrOffset+net.IPv4len]).String(), status, int(reply[reply4TTLOffset])
+	})
+}
+
+func icmpIpv6(ctx context.Context, localAddr string, dst net.Addr, ttl, pid int, timeout time.Duration, seq int, payloadSize int, tos int) (hop common.IcmpReturn, err error) {
+	src := syscall.RawSockaddrInet6{Family: syscall.AF_INET6}
+	copy(src.Addr[:], net.ParseIP(localAddr).To16())
+	dstAddr := syscall.RawSockaddrInet6{Family: syscall.AF_INET6}
+	copy(dstAddr.Addr[:], dst.(*net.IPAddr).IP.To16())
+
+	return sendEcho(ctx, procIcmp6CreateFile, ttl, tos, timeout, seq, payloadSize, func(h syscall.Handle, data []byte, opts *ipOptionInformation, reply []byte, ms uint32) (uintptr, error) {
+		n, _, err := procIcmp6SendEcho2.Call(uintptr(h), 0, 0, 0,
+			uintptr(unsafe.Pointer(&src)), uintptr(unsafe.Pointer(&dstAddr)),
+			uintptr(unsafe.Pointer(unsafe.SliceData(data))), uintptr(len(data)), uintptr(unsafe.Pointer(opts)),
+			uintptr(unsafe.Pointer(&reply[0])), uintptr(len(reply)), uintptr(ms))
+		return n, err
+	}, func(reply []byte) (string, uint32, int) {
+		status := binary.LittleEndian.Uint32(reply[reply6StatusOffset:])
+		return net.IP(reply[reply6AddrOffset : reply6AddrOffset+net.IPv6len]).String(), status, 0
+	})
+}
+
+// timestampIpv4 the ICMP helper API only sends echo requests
+func timestampIpv4(ctx context.Context, localAddr string, dst net.Addr, pid int, timeout time.Duration, seq int, tos int) (ts TimestampReturn, err error) {
+	return ts, ErrTimestampUnsupported
+}
+
+// sendEcho sends an echo request with the given TTL and waits for its reply, a time exceeded reply is a successful hop like on the raw sockets
+// The helper API call can not be interrupted, it is left to complete in the background when the context is canceled
+// The TOS is passed in the IP options, the system may ignore it without error
+func sendEcho(ctx context.Context, create *syscall.LazyProc, ttl int, tos int, timeout time.Duration, seq int, payloadSize int,
+	send func(h syscall.Handle, data []byte, opts *ipOptionInformation, reply []byte, ms uint32) (uintptr, error),
+	parse func(reply []byte) (string, uint32, int)) (hop common.IcmpReturn, err error) {
+	hop.Success = false
+	start := time.Now()
+	h, err := createFile(create)
+	if err != nil {
+		return hop, err
+	}
+
+	// The buffers are not pooled as the call may outlive the probe
+	wb := marshalEcho(make([]byte, echoHeaderLen+payloadSize), 0, 0, seq, false)
+	data := wb[echoHeaderLen:]
+	reply := make([]byte, replyOverhead+len(data))
+	opts := &ipOptionInformation{TTL: uint8(min(ttl, 255)), Tos: uint8(tos)}
+	ms := uint32(max(timeout.Milliseconds(), 1))
+
+	type result struct {
+		n   uintptr
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer procIcmpCloseHandle.Call(uintptr(h))
+		n, err := send(h, data, opts, reply, ms)
+		done <- result{n, err}
+	}()
+
+	var r result
+	select {
+	case <-ctx.Done():
+		return hop, ctx.Err()
+	case r = <-done:
+	}
+
+	if r.n == 0 {
+		if errno, ok := r.err.(syscall.Errno); ok && errno != 0 {
+			return hop, fmt.Errorf("icmp echo: %s", statusString(uint32(errno)))
+		}
+		return hop, fmt.Errorf("icmp echo: no reply")
+	}
+
+	peer, status, replyTTL := parse(reply)
+	if status != ipSuccess && status != ipTTLExpiredTransit {
+		return hop, fmt.Errorf("icmp echo from %s: %s", peer, statusString(status))
+	}
+
+	elapsed := time.Since(start)
+	hop.Elapsed = elapsed
+	hop.Addr = peer
+	hop.TTL = replyTTL
+	hop.Success = true
+	return hop, nil
+}
+
+// createFile opens an ICMP helper handle
+func createFile(create *syscall.LazyProc) (syscall.Handle, error) {
+	h, _, err := create.Call()
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return syscall.InvalidHandle, fmt.Errorf("opening ICMP handle: %w", err)
+	}
+	return syscall.Handle(h), nil
+}
+
+// statusString describes an IP_STATUS code
+func statusString(status uint32) string {
+	switch status {
+	case ipBufTooSmall:
+		return "reply buffer too small"
+	case ipDestNetUnreach:
+		return "destination network unreachable"
+	case ipDestHostUnreach:
+		return "destination host unreachable"
+	case ipDestProtUnreach:
+		return "destination protocol unreachable"
+	case ipDestPortUnreach:
+		return "destination port unreachable"
+	case ipReqTimedOut:
+		return "i/o timeout"
+	case ipBadDestination:
+		return "bad destination"
+	case ipGeneralFailure:
+		return "general failure"
+	default:
+		return fmt.Sprintf("status %d", status)
+	}
+}
@@ -2,26 +2,42 @@ package mtr
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/icmp"
 	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/pkg/udp"
 )
 
-// Mtr Return traceroute object
-func Mtr(addr string, srcAddr string, maxHops int, count int, timeout time.Duration, icmpID int, payloadSize int, protocol string, port string, ipv6 bool) (*MtrResult, error) {
+// rtt clamps the round trip times of the hops
+var rtt common.RTTCounter
+
+// InvalidRTT returns the negative round trip times clamped to 0 since the start
+func InvalidRTT() uint64 {
+	return rtt.Invalid()
+}
+
+// Mtr Return traceroute object, the remaining probes are not sent once the context is canceled
+// A packetInterval >0 waits between the probes of the round, parallel TTLs are probed concurrently (0 all the TTLs of the round)
+func Mtr(ctx context.Context, addr string, srcAddr string, maxHops int, count int, packetInterval time.Duration, parallel int, timeout time.Duration, icmpID int, payloadSize int, tos int, protocol string, port string, ipv6 bool) (*MtrResult, error) {
 	var out MtrResult
 	var err error
 
 	options := MtrOptions{}
 	options.SetMaxHops(maxHops)
 	options.SetCount(count)
+	options.SetPacketInterval(packetInterval)
+	options.SetParallel(parallel)
 	options.SetTimeout(timeout)
 
-	out, err = runMtr(addr, srcAddr, icmpID, &options, payloadSize, protocol, port, ipv6)
+	out, err = runMtr(ctx, addr, srcAddr, icmpID, &options, payloadSize, tos, protocol, port, ipv6)
 
 	if err == nil {
 		if len(out.Hops) == 0 {
@@ -35,17 +51,19 @@ func Mtr(addr string, srcAddr string, maxHops int, count int, timeout time.Durat
 }
 
 // MtrString Console print traceroute operation
-func MtrString(addr string, srcAddr string, maxHops int, count int, timeout time.Duration, icmpID int, payloadSize int, protocol string, port string, ipv6 bool) (result string, err error) {
+func MtrString(ctx context.Context, addr string, srcAddr string, maxHops int, count int, packetInterval time.Duration, parallel int, timeout time.Duration, icmpID int, payloadSize int, tos int, protocol string, port string, ipv6 bool) (result string, err error) {
 	options := MtrOptions{}
 	options.SetMaxHops(maxHops)
 	options.SetCount(count)
+	options.SetPacketInterval(packetInterval)
+	options.SetParallel(parallel)
 	options.SetTimeout(timeout)
 
 	var out MtrResult
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("Start: %v, DestAddr: %v\n", time.Now().Format("2006-01-02 15:04:05"), addr))
 
-	out, err = runMtr(addr, srcAddr, icmpID, &options, payloadSize, protocol, port, ipv6)
+	out, err = runMtr(ctx, addr, srcAddr, icmpID, &options, payloadSize, tos, protocol, port, ipv6)
 
 	if err == nil {
 		if len(out.Hops) == 0 {
@@ -85,48 +103,63 @@ func MtrString(addr string, srcAddr string, maxHops int, count int, timeout time
 }
 
 // MTR
-func runMtr(destAddr string, srcAddr string, icmpID int, options *MtrOptions, payloadSize int, protocol string, port string, ipv6 bool) (result MtrResult, err error) {
+func runMtr(ctx context.Context, destAddr string, srcAddr string, icmpID int, options *MtrOptions, payloadSize int, tos int, protocol string, port string, ipv6 bool) (result MtrResult, err error) {
 	result.Hops = []common.IcmpHop{}
 	result.DestAddr = destAddr
+	result.Protocol = protocol
+	if protocol != "tcp" {
+		result.PayloadSize = payloadSize
+	}
+	result.PacketInterval = options.PacketInterval()
 
 	// Avoid collisions/interference caused by multiple coroutines initiating mtr
 	pid := icmpID
-	timeout := options.Timeout()
 	mtrReturns := make([]*MtrReturn, options.MaxHops()+1)
 
+	// Use TCP, UDP or ICMP based on protocol
+	probe := func(ctx context.Context, ttl int, seq int) (common.IcmpReturn, error) {
+		switch protocol {
+		case "tcp":
+			return tcp.Traceroute(ctx, destAddr, port, srcAddr, ttl, options.Timeout(), tos, ipv6)
+		case "udp":
+			return udp.Traceroute(ctx, destAddr, port, srcAddr, ttl, seq, options.Timeout(), payloadSize, tos, ipv6)
+		default:
+			return icmp.Icmp(ctx, destAddr, srcAddr, ttl, pid, options.Timeout(), seq, payloadSize, tos, ipv6)
+		}
+	}
+
 	// Verify data packets
 	seq := 0
 	for snt := 0; snt < options.Count(); snt++ {
-		for ttl := 1; ttl < options.MaxHops(); ttl++ {
-			if mtrReturns[ttl] == nil {
-				mtrReturns[ttl] = &MtrReturn{ttl: ttl, host: "unknown", succSum: 0, success: false, lastTime: time.Duration(0), sumTime: time.Duration(0), bestTime: time.Duration(0), worstTime: time.Duration(0), avgTime: time.Duration(0)}
-			}
-
-			var hopReturn common.IcmpReturn
-			var err error
+		hops, err := round(ctx, destAddr, protocol, options, &seq, probe)
+		if err != nil {
+			return result, err
+		}
 
-			// Use TCP or ICMP based on protocol
-			if protocol == "tcp" {
-				hopReturn, err = tcp.Traceroute(destAddr, port, srcAddr, ttl, timeout, ipv6)
-			} else {
-				hopReturn, err = icmp.Icmp(destAddr, srcAddr, ttl, pid, timeout, seq, payloadSize, ipv6)
+		for ttl := 1; ttl < len(hops); ttl++ {
+			hopReturn := hops[ttl]
+			if hopReturn == nil {
+				break
+			}
+			if mtrReturns[ttl] == nil {
+				mtrReturns[ttl] = &MtrReturn{ttl: ttl, host: "unknown", succSum: 0, success: false, lastTime: time.Duration(0), sumTime: time.Duration(0), bestTime: time.Duration(0), worstTime: time.Duration(0), avgTime: time.Duration(0), allTime: make([]time.Duration, 0, options.Count())}
 			}
-			seq++
-			if err != nil || !hopReturn.Success {
+			if !hopReturn.Success {
 				continue
 			}
 
+			elapsed := rtt.Clamp(hopReturn.Elapsed)
 			mtrReturns[ttl].host = hopReturn.Addr
-			mtrReturns[ttl].lastTime = hopReturn.Elapsed
-			mtrReturns[ttl].allTime = append(mtrReturns[ttl].allTime, hopReturn.Elapsed)
+			mtrReturns[ttl].lastTime = elapsed
+			mtrReturns[ttl].allTime = append(mtrReturns[ttl].allTime, elapsed)
 			mtrReturns[ttl].succSum = mtrReturns[ttl].succSum + 1
-			if mtrReturns[ttl].worstTime == time.Duration(0) || hopReturn.Elapsed > mtrReturns[ttl].worstTime {
-				mtrReturns[ttl].worstTime = hopReturn.Elapsed
+			if mtrReturns[ttl].worstTime == time.Duration(0) || elapsed > mtrReturns[ttl].worstTime {
+				mtrReturns[ttl].worstTime = elapsed
 			}
-			if mtrReturns[ttl].bestTime == time.Duration(0) || hopReturn.Elapsed < mtrReturns[ttl].bestTime {
-				mtrReturns[ttl].bestTime = hopReturn.Elapsed
+			if mtrReturns[ttl].bestTime == time.Duration(0) || elapsed < mtrReturns[ttl].bestTime {
+				mtrReturns[ttl].bestTime = elapsed
 			}
-			mtrReturns[ttl].sumTime += hopReturn.Elapsed
+			mtrReturns[ttl].sumTime += elapsed
 			mtrReturns[ttl].avgTime = mtrReturns[ttl].sumTime / time.Duration(mtrReturns[ttl].succSum)
 			mtrReturns[ttl].success = true
 
@@ -162,6 +195,11 @@ func runMtr(destAddr string, srcAddr string, icmpID int, options *MtrOptions, pa
 		hop.UncorrectedSDTime = time.Duration(common.TimeUncorrectedDeviation(mtrReturn.allTime))
 		hop.CorrectedSDTime = time.Duration(common.TimeCorrectedDeviation(mtrReturn.allTime))
 		hop.RangeTime = time.Duration(common.TimeRange(mtrReturn.allTime))
+		if len(mtrReturn.allTime) >= 2 {
+			stdDev := time.Duration(common.TimeUncorrectedDeviation(mtrReturn.allTime))
+			jitter := time.Duration(common.TimeSmoothedJitter(mtrReturn.allTime))
+			hop.StdDevTime, hop.JitterTime = &stdDev, &jitter
+		}
 
 		failSum := options.Count() - mtrReturn.succSum
 		hop.SntFail = failSum
@@ -178,3 +216,82 @@ func runMtr(destAddr string, srcAddr string, icmpID int, options *MtrOptions, pa
 	// fmt.Printf("Mtr.result %+v\n", result)
 	return result, nil
 }
+
+// round probes the TTLs of a round with probe, options.Parallel() of them concurrently, each probe is told apart by its sequence number (ICMP) or ports (TCP, UDP)
+// No TTL above the one answered by the destination is sent and the ones in flight are canceled, the round ends when the TTLs below it answered or timed out
+// The returned hops are indexed by TTL up to the destination (nil the ones not sent), the sequence is incremented for every probe sent
+func round(ctx context.Context, destAddr string, protocol string, options *MtrOptions, seq *int, probe func(ctx context.Context, ttl int, seq int) (common.IcmpReturn, error)) ([]*common.IcmpReturn, error) {
+	hops := make([]*common.IcmpReturn, options.MaxHops())
+	cancels := make([]context.CancelFunc, options.MaxHops())
+	slots := make(chan struct{}, options.Parallel())
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	var probeErr error
+	// destTTL lowest TTL answered by the destination, options.MaxHops() until then
+	destTTL := options.MaxHops()
+
+	for ttl := 1; ttl < options.MaxHops(); ttl++ {
+		if *seq > 0 {
+			if err := common.Pace(ctx, options.PacketInterval()); err != nil {
+				break
+			}
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		mtx.Lock()
+		done := ttl > destTTL || probeErr != nil || ctx.Err() != nil
+		var hopCtx context.Context
+		if !done {
+			hopCtx, cancels[ttl] = context.WithCancel(ctx)
+		}
+		mtx.Unlock()
+		if done {
+			break
+		}
+
+		wg.Add(1)
+		go func(ttl int, seq int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			hopReturn, err := probe(hopCtx, ttl, seq)
+			if err != nil {
+				hopReturn.Success = false
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			hops[ttl] = &hopReturn
+			// Without the raw socket privileges every hop would be reported as lost
+			if errors.Is(err, os.ErrPermission) && probeErr == nil {
+				probeErr = fmt.Errorf("opening the raw socket of the %s probes: %w (run as root or grant the CAP_NET_RAW capability: setcap cap_net_raw+ep network_exporter)", protocol, err)
+			}
+			if hopReturn.Success && common.IsEqualIP(hopReturn.Addr, destAddr) && ttl < destTTL {
+				destTTL = ttl
+				// The probes of the TTLs above the destination can only be answered by it
+				for t := ttl + 1; t < len(cancels); t++ {
+					if cancels[t] != nil {
+						cancels[t]()
+					}
+				}
+			}
+		}(ttl, *seq)
+		*seq++
+	}
+	wg.Wait()
+
+	for _, cancel := range cancels {
+		if cancel != nil {
+			cancel()
+		}
+	}
+	if probeErr != nil {
+		return nil, probeErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return hops[:min(destTTL+1, len(hops))], nil
+}
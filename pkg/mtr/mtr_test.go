@@ -0,0 +1,172 @@
+package mtr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// fakePath answers the probes of a path whose destination is at hop dest, hop ttl answers from 10.0.0.<ttl> after delay(ttl)
+// The probes of the TTLs above the destination are never answered, they end when their context is canceled
+type fakePath struct {
+	dest  int
+	delay func(ttl int) time.Duration
+
+	mtx      sync.Mutex
+	seqs     map[int]int
+	canceled map[int]bool
+	inFlight int
+	peak     int
+}
+
+func newFakePath(dest int, delay func(ttl int) time.Duration) *fakePath {
+	return &fakePath{dest: dest, delay: delay, seqs: map[int]int{}, canceled: map[int]bool{}}
+}
+
+func (f *fakePath) probe(ctx context.Context, ttl int, seq int) (common.IcmpReturn, error) {
+	f.mtx.Lock()
+	f.seqs[ttl] = seq
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mtx.Unlock()
+	defer func() {
+		f.mtx.Lock()
+		f.inFlight--
+		f.mtx.Unlock()
+	}()
+
+	if ttl > f.dest {
+		select {
+		case <-ctx.Done():
+			f.mtx.Lock()
+			f.canceled[ttl] = true
+			f.mtx.Unlock()
+			return common.IcmpReturn{}, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return common.IcmpReturn{}, fmt.Errorf("ttl %d not canceled", ttl)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return common.IcmpReturn{}, ctx.Err()
+	case <-time.After(f.delay(ttl)):
+	}
+	// The elapsed time tells the hop the reply was attributed to
+	return common.IcmpReturn{Success: true, Addr: fmt.Sprintf("10.0.0.%d", ttl), Elapsed: time.Duration(ttl) * time.Millisecond}, nil
+}
+
+func TestRoundParallelAttribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		parallel int
+		dest     int
+		// delay of the replies, the ones of the lower TTLs arrive last
+		delay func(ttl int) time.Duration
+	}{
+		{name: "all TTLs", parallel: 0, dest: 4, delay: func(ttl int) time.Duration { return time.Duration(50-10*ttl) * time.Millisecond }},
+		{name: "two TTLs", parallel: 2, dest: 5, delay: func(ttl int) time.Duration { return time.Duration(ttl%2*30) * time.Millisecond }},
+		{name: "one TTL", parallel: 1, dest: 3, delay: func(ttl int) time.Duration { return time.Millisecond }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &MtrOptions{}
+			options.SetMaxHops(12)
+			options.SetParallel(tt.parallel)
+			path := newFakePath(tt.dest, tt.delay)
+			destAddr := fmt.Sprintf("10.0.0.%d", tt.dest)
+
+			seq := 7
+			start := time.Now()
+			hops, err := round(context.Background(), destAddr, "icmp", options, &seq, path.probe)
+			if err != nil {
+				t.Fatalf("round: %s", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("round ended after %s, the TTLs above the destination were not canceled", elapsed)
+			}
+
+			if len(hops) != tt.dest+1 {
+				t.Fatalf("%d hops, want the %d up to the destination", len(hops)-1, tt.dest)
+			}
+			for ttl := 1; ttl <= tt.dest; ttl++ {
+				h := hops[ttl]
+				if h == nil || !h.Success || h.Addr != fmt.Sprintf("10.0.0.%d", ttl) || h.Elapsed != time.Duration(ttl)*time.Millisecond {
+					t.Errorf("hop %d: %+v, want the reply of 10.0.0.%d", ttl, h, ttl)
+				}
+			}
+
+			path.mtx.Lock()
+			defer path.mtx.Unlock()
+			if want := options.Parallel(); path.peak > want {
+				t.Errorf("%d probes in flight, want at most %d", path.peak, want)
+			}
+			// Every probe sent has its own sequence number
+			used := map[int]int{}
+			for ttl, s := range path.seqs {
+				if other, ok := used[s]; ok {
+					t.Errorf("TTLs %d and %d sent with the sequence %d", other, ttl, s)
+				}
+				used[s] = ttl
+			}
+			if seq != 7+len(path.seqs) {
+				t.Errorf("sequence %d after %d probes, want %d", seq, len(path.seqs), 7+len(path.seqs))
+			}
+			for ttl := range path.seqs {
+				if ttl > tt.dest && !path.canceled[ttl] {
+					t.Errorf("TTL %d above the destination not canceled", ttl)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundCancelsTTLsBeyondDestination(t *testing.T) {
+	options := &MtrOptions{}
+	options.SetMaxHops(16)
+	options.SetParallel(0)
+	// The destination answers after all the TTLs are in flight
+	path := newFakePath(3, func(ttl int) time.Duration { return 50 * time.Millisecond })
+
+	seq := 0
+	hops, err := round(context.Background(), "10.0.0.3", "icmp", options, &seq, path.probe)
+	if err != nil {
+		t.Fatalf("round: %s", err)
+	}
+	if len(hops) != 4 {
+		t.Fatalf("%d hops, want 3", len(hops)-1)
+	}
+
+	path.mtx.Lock()
+	defer path.mtx.Unlock()
+	for ttl := 4; ttl < options.MaxHops(); ttl++ {
+		if _, sent := path.seqs[ttl]; !sent {
+			t.Errorf("TTL %d not sent, want all the TTLs in flight", ttl)
+		} else if !path.canceled[ttl] {
+			t.Errorf("TTL %d above the destination not canceled", ttl)
+		}
+	}
+}
+
+func TestRoundCanceled(t *testing.T) {
+	options := &MtrOptions{}
+	options.SetMaxHops(8)
+	options.SetParallel(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	// The destination is never reached, the probes wait for the cancel
+	path := newFakePath(99, func(ttl int) time.Duration { return 5 * time.Second })
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	seq := 0
+	if _, err := round(ctx, "10.0.0.99", "icmp", options, &seq, path.probe); err == nil {
+		t.Errorf("canceled round without error")
+	}
+	path.mtx.Lock()
+	defer path.mtx.Unlock()
+	if len(path.seqs) != 2 {
+		t.Errorf("%d TTLs sent, want the 2 in flight when the round was canceled", len(path.seqs))
+	}
+}
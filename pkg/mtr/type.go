@@ -16,12 +16,19 @@ type MtrResult struct {
 	DestAddr      string                         `json:"dest_address"`
 	Hops          []common.IcmpHop               `json:"hops"`
 	HopSummaryMap map[string]*common.IcmpSummary `json:"hop_summary_map"`
+	// PayloadSize of the ICMP echo requests or UDP datagrams
+	PayloadSize int `json:"payload_size,omitempty"`
+	// Protocol of the probes, icmp or tcp
+	Protocol string `json:"protocol"`
+	// PacketInterval delay between the probes of the round
+	PacketInterval time.Duration `json:"packet_interval,omitempty"`
+	// PathID fingerprint of the hop addresses of the current path (0 unknown) and PathChanges changes of the path since the target was started
+	PathID      uint32 `json:"path_id,omitempty"`
+	PathChanges uint64 `json:"path_changes"`
 }
 
 // MtrReturn MTR Response
-// TODO: Add Jitter
-// RFC 1889 (http://www.ietf.org/rfc/rfc1889.txt) is the RFC for RTP, later superseded by RFC 3550.
-// How interarrival jitter is calculated RFC 3550 (http://www.ietf.org/rfc/rfc3550.txt)
+// The jitter of the hops is the interarrival jitter of RFC 3550 (http://www.ietf.org/rfc/rfc3550.txt) computed on the RTTs of allTime
 type MtrReturn struct {
 	success   bool
 	ttl       int
@@ -37,10 +44,12 @@ type MtrReturn struct {
 
 // MtrOptions MTR Options
 type MtrOptions struct {
-	maxHops    int
-	timeout    time.Duration
-	packetSize int
-	count      int
+	maxHops        int
+	timeout        time.Duration
+	packetSize     int
+	count          int
+	packetInterval time.Duration
+	parallel       int
 }
 
 // MaxHops Getter
@@ -94,3 +103,26 @@ func (options *MtrOptions) PacketSize() int {
 func (options *MtrOptions) SetPacketSize(packetSize int) {
 	options.packetSize = packetSize
 }
+
+// PacketInterval Getter
+func (options *MtrOptions) PacketInterval() time.Duration {
+	return options.packetInterval
+}
+
+// SetPacketInterval Setter
+func (options *MtrOptions) SetPacketInterval(packetInterval time.Duration) {
+	options.packetInterval = packetInterval
+}
+
+// Parallel Getter, the TTLs probed concurrently in a round (0 all of them)
+func (options *MtrOptions) Parallel() int {
+	if options.parallel <= 0 || options.parallel > options.MaxHops() {
+		return options.MaxHops()
+	}
+	return options.parallel
+}
+
+// SetParallel Setter
+func (options *MtrOptions) SetParallel(parallel int) {
+	options.parallel = parallel
+}
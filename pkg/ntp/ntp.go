@@ -0,0 +1,172 @@
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+const (
+	// packetSize size of the NTP packets without extension fields
+	packetSize = 48
+	// ntpEpoch seconds between the NTP epoch (1900) and the unix epoch
+	ntpEpoch = 2208988800
+	// version NTP version of the queries
+	version = 4
+	// modeClient and modeServer modes of the query and its response
+	modeClient = 3
+	modeServer = 4
+	// leapAlarm leap indicator of a server whose clock is not synchronized
+	leapAlarm = 3
+	// maxStratum stratum of a server whose clock is not synchronized
+	maxStratum = 16
+)
+
+// Query NTP Operation, sends a client mode SNTP query to the server and computes the offset and round trip delay of its response
+// The kiss-of-death responses, the stratum 0 and the unsynchronized servers fail the probe, the probe is aborted when the context is canceled
+func Query(ctx context.Context, destAddr string, ip string, srcAddr string, device string, port string, timeout time.Duration) (*NTPReturn, error) {
+	out := NTPReturn{DestAddr: destAddr, DestIp: ip, DestPort: port, SrcIp: "0.0.0.0", Stratum: -1, Leap: -1}
+
+	d := net.Dialer{}
+	if srcAddr != "" {
+		srcIp := net.ParseIP(srcAddr)
+		if srcIp == nil {
+			out.ErrorReason = "connect"
+			return &out, fmt.Errorf("source ip: %v is invalid, NTP target: %v", srcAddr, destAddr)
+		}
+		d.LocalAddr = &net.UDPAddr{IP: srcIp}
+	}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if device != "" {
+			if err := common.BindToDevice(c, device); err != nil {
+				common.SourceInterfaceFailures.BindFailed("NTP", device, err)
+			}
+		}
+		return nil
+	}
+
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip, port))
+	if err != nil {
+		out.ErrorReason = "connect"
+		return &out, err
+	}
+	defer conn.Close()
+	// Closing the connection aborts the query when the target is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if a, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		out.SrcIp = a.IP.String()
+	}
+
+	// The transmit timestamp of the query is the origin timestamp of its response
+	query := make([]byte, packetSize)
+	query[0] = version<<3 | modeClient
+	start := time.Now()
+	binary.BigEndian.PutUint64(query[40:], toTimestamp(start))
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		out.ErrorReason = "connect"
+		return &out, fmt.Errorf("error setting deadline timeout: %v", err)
+	}
+	if _, err := conn.Write(query); err != nil {
+		out.ErrorReason = errorReason(err)
+		return &out, err
+	}
+
+	resp := make([]byte, 1024)
+	var n int
+	for {
+		n, err = conn.Read(resp)
+		if err != nil {
+			out.ErrorReason = errorReason(err)
+			return &out, err
+		}
+		// The late responses of a previous query are skipped
+		if n >= packetSize && binary.BigEndian.Uint64(resp[24:]) == binary.BigEndian.Uint64(query[40:]) {
+			break
+		}
+	}
+	// The receive time is taken from the monotonic clock of the transmit time
+	elapsed := time.Since(start)
+	t1, t4 := start, start.Add(elapsed)
+
+	mode := resp[0] & 0x07
+	out.Leap = int(resp[0] >> 6)
+	out.Stratum = int(resp[1])
+	if mode != modeServer {
+		out.ErrorReason = "response"
+		return &out, fmt.Errorf("invalid response mode %d", mode)
+	}
+	if out.Stratum == 0 {
+		// The reference id of the kiss-of-death responses is their ASCII code
+		if code, ok := kissCode(resp[12:16]); ok {
+			out.KissCode = code
+			out.ErrorReason = "kiss_of_death"
+			return &out, nil
+		}
+		out.ErrorReason = "stratum"
+		return &out, nil
+	}
+
+	t2 := toTime(binary.BigEndian.Uint64(resp[32:]))
+	t3 := toTime(binary.BigEndian.Uint64(resp[40:]))
+	if binary.BigEndian.Uint64(resp[40:]) == 0 {
+		out.ErrorReason = "response"
+		return &out, fmt.Errorf("invalid response without transmit timestamp")
+	}
+	out.Offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	out.RTT = max(elapsed-t3.Sub(t2), 0)
+
+	if out.Leap == leapAlarm || out.Stratum >= maxStratum {
+		out.ErrorReason = "unsynchronized"
+		return &out, nil
+	}
+	out.Success = true
+	return &out, nil
+}
+
+// kissCode returns the code of a kiss-of-death reference id, its 4 characters are upper case letters
+func kissCode(refID []byte) (string, bool) {
+	n := 0
+	for _, c := range refID {
+		if c == 0 {
+			break
+		}
+		if c < 'A' || c > 'Z' {
+			return "", false
+		}
+		n++
+	}
+	return string(refID[:n]), n > 0
+}
+
+// toTimestamp returns the NTP timestamp of a time, seconds since 1900 and fraction of second
+func toTimestamp(t time.Time) uint64 {
+	secs := uint64(t.Unix()+ntpEpoch) & 0xffffffff
+	frac := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// toTime returns the time of an NTP timestamp, the seconds below 2^31 are of the era starting in 2036
+func toTime(ts uint64) time.Time {
+	secs := int64(ts >> 32)
+	if secs < 1<<31 {
+		secs += 1 << 32
+	}
+	nsec := (int64(ts&0xffffffff) * int64(time.Second)) >> 32
+	return time.Unix(secs-ntpEpoch, nsec)
+}
+
+// errorReason returns the error reason of a failed query
+func errorReason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "connect"
+}
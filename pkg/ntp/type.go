@@ -0,0 +1,22 @@
+package ntp
+
+import "time"
+
+// NTPReturn Calculated results
+type NTPReturn struct {
+	Success  bool   `json:"success"`
+	DestAddr string `json:"dest_address"`
+	DestIp   string `json:"dest_ip"`
+	DestPort string `json:"dest_port"`
+	SrcIp    string `json:"src_ip"`
+	// Stratum and Leap indicator of the response, -1 without response
+	Stratum int `json:"stratum"`
+	Leap    int `json:"leap"`
+	// KissCode code of a kiss-of-death response (RATE, DENY, RSTR...)
+	KissCode string `json:"kiss_code,omitempty"`
+	// Offset of the local clock to the server, RTT round trip delay without the processing time of the server
+	Offset time.Duration `json:"offset"`
+	RTT    time.Duration `json:"rtt"`
+	// ErrorReason phase of the failure: source_interface, connect, timeout, response, kiss_of_death, stratum or unsynchronized
+	ErrorReason string `json:"error_reason,omitempty"`
+}
@@ -2,6 +2,8 @@ package ping
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"time"
@@ -10,34 +12,47 @@ import (
 	"github.com/syepes/network_exporter/pkg/icmp"
 )
 
-// Ping ICMP Operation
-func Ping(addr string, ip string, srcAddr string, count int, timeout time.Duration, icmpID int, payloadSize int, ipv6 bool) (*PingResult, error) {
+// Ping ICMP Operation, the remaining packets are not sent once the context is canceled
+// A hopLimit >0 sends the IPv6 echo requests with the hop limit instead of the default one
+// A packetInterval >0 waits between the requests of the round
+// The timestamp mode sends timestamp requests to the IPv4 targets instead of echo requests, the round falls back to echo requests when none is answered
+func Ping(ctx context.Context, addr string, ip string, srcAddr string, count int, packetInterval time.Duration, timeout time.Duration, icmpID int, payloadSize int, tos int, hopLimit int, mode string, ipv6 bool) (*PingResult, error) {
 	var out PingResult
 
 	pingOptions := &PingOptions{}
 	pingOptions.SetCount(count)
+	pingOptions.SetPacketInterval(packetInterval)
 	pingOptions.SetTimeout(timeout)
 
-	out, err := runPing(addr, ip, srcAddr, icmpID, pingOptions, payloadSize, ipv6)
+	out, err := runPing(ctx, addr, ip, srcAddr, icmpID, pingOptions, payloadSize, tos, hopLimit, mode, ipv6)
 	if err != nil {
 		return &out, err
 	}
 	return &out, nil
 }
 
+// rtt clamps the round trip times of the echo replies
+var rtt common.RTTCounter
+
+// InvalidRTT returns the negative round trip times clamped to 0 since the start
+func InvalidRTT() uint64 {
+	return rtt.Invalid()
+}
+
 // PingString ICMP Operation
-func PingString(addr string, ip string, srcAddr string, count int, timeout time.Duration, icmpID int, payloadSize int, ipv6 bool) (result string, err error) {
+func PingString(ctx context.Context, addr string, ip string, srcAddr string, count int, packetInterval time.Duration, timeout time.Duration, icmpID int, payloadSize int, tos int, hopLimit int, mode string, ipv6 bool) (result string, err error) {
 	pingOptions := &PingOptions{}
 	pingOptions.SetCount(count)
+	pingOptions.SetPacketInterval(packetInterval)
 	pingOptions.SetTimeout(timeout)
 
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("Start %v, PING %v (%v)\n", time.Now().Format("2006-01-02 15:04:05"), addr, addr))
-	begin := time.Now().UnixNano() / 1e6
-	pingResult, err := runPing(addr, ip, srcAddr, icmpID, pingOptions, payloadSize, ipv6)
-	end := time.Now().UnixNano() / 1e6
+	begin := time.Now()
+	pingResult, err := runPing(ctx, addr, ip, srcAddr, icmpID, pingOptions, payloadSize, tos, hopLimit, mode, ipv6)
+	elapsed := time.Since(begin)
 
-	buffer.WriteString(fmt.Sprintf("%v packets transmitted, %v packet loss, time %vms\n", count, pingResult.DropRate, end-begin))
+	buffer.WriteString(fmt.Sprintf("%v packets transmitted, %v packet loss, time %vms\n", count, pingResult.DropRate, elapsed.Milliseconds()))
 	buffer.WriteString(fmt.Sprintf("rtt min/avg/max = %v/%v/%v ms\n", common.Time2Float(pingResult.WorstTime), common.Time2Float(pingResult.AvgTime), common.Time2Float(pingResult.BestTime)))
 
 	result = buffer.String()
@@ -49,38 +64,74 @@ func PingString(addr string, ip string, srcAddr string, count int, timeout time.
 	return result, nil
 }
 
-func runPing(ipAddr string, ip string, srcAddr string, icmpID int, option *PingOptions, payloadSize int, ipv6 bool) (pingResult PingResult, err error) {
+func runPing(ctx context.Context, ipAddr string, ip string, srcAddr string, icmpID int, option *PingOptions, payloadSize int, tos int, hopLimit int, mode string, ipv6 bool) (pingResult PingResult, err error) {
 	pingResult.DestAddr = ipAddr
 	pingResult.DestIp = ip
+	pingResult.PayloadSize = payloadSize
+	pingResult.PacketInterval = option.PacketInterval()
 
 	// Avoid collisions/interference caused by multiple coroutines initiating mtr
 	pid := icmpID
 	timeout := option.Timeout()
 	ttl := defaultTTL
-	pingReturn := PingReturn{}
-
-	seq := 0
-	for cnt := 0; cnt < option.Count(); cnt++ {
-		icmpReturn, err := icmp.Icmp(ip, srcAddr, ttl, pid, timeout, seq, payloadSize, ipv6)
+	if ipv6 && hopLimit > 0 {
+		ttl = hopLimit
+	}
+	// Sized for the whole cycle so that the probes do not grow it
+	pingReturn := PingReturn{allTime: make([]time.Duration, 0, option.Count())}
 
-		if err != nil || !icmpReturn.Success || !common.IsEqualIP(ip, icmpReturn.Addr) {
-			continue
+	echo := func(seq int) (common.IcmpReturn, error) {
+		icmpReturn, err := icmp.Icmp(ctx, ip, srcAddr, ttl, pid, timeout, seq, payloadSize, tos, ipv6)
+		// The requests are sent with the hop limit of the system when the configured one can not be set
+		if errors.Is(err, icmp.ErrHopLimit) && hopLimit > 0 && ttl > 0 {
+			pingResult.HopLimitFailed = true
+			ttl = 0
+			icmpReturn, err = icmp.Icmp(ctx, ip, srcAddr, ttl, pid, timeout, seq, payloadSize, tos, ipv6)
 		}
+		return icmpReturn, err
+	}
 
-		pingReturn.allTime = append(pingReturn.allTime, icmpReturn.Elapsed)
-
-		pingReturn.succSum++
-		if pingReturn.worstTime == time.Duration(0) || icmpReturn.Elapsed > pingReturn.worstTime {
-			pingReturn.worstTime = icmpReturn.Elapsed
+	unsupported := false
+	timestamp := func(seq int) (common.IcmpReturn, error) {
+		ts, err := icmp.Timestamp(ctx, ip, srcAddr, pid, timeout, seq, tos)
+		if errors.Is(err, icmp.ErrTimestampUnsupported) {
+			unsupported = true
 		}
-		if pingReturn.bestTime == time.Duration(0) || icmpReturn.Elapsed < pingReturn.bestTime {
-			pingReturn.bestTime = icmpReturn.Elapsed
+		if err != nil || !ts.Success || !common.IsEqualIP(ip, ts.Addr) {
+			return common.IcmpReturn{}, err
 		}
-		pingReturn.sumTime += icmpReturn.Elapsed
-		pingReturn.avgTime = pingReturn.sumTime / time.Duration(pingReturn.succSum)
-		pingReturn.success = true
+		// The delays of the replies whose timestamps are not standard can not be computed
+		if ts.Receive&nonStandardTimestamp == 0 && ts.Transmit&nonStandardTimestamp == 0 {
+			forward, back := oneWayDelays(ts)
+			pingReturn.owdCount++
+			pingReturn.owdForwardSum += forward
+			pingReturn.owdReturnSum += back
+		}
+		return common.IcmpReturn{Success: true, Addr: ts.Addr, Elapsed: ts.Elapsed}, nil
+	}
 
-		seq++
+	switch {
+	case mode != ModeTimestamp:
+		err = round(ctx, ip, option.Count(), option.PacketInterval(), &pingReturn, echo)
+	case ipv6:
+		pingResult.TimestampFallback = FallbackIPv6
+		err = round(ctx, ip, option.Count(), option.PacketInterval(), &pingReturn, echo)
+	default:
+		err = round(ctx, ip, option.Count(), option.PacketInterval(), &pingReturn, timestamp)
+		if err == nil && pingReturn.succSum == 0 {
+			// The round is sent again as echo requests so that the targets filtering the timestamp requests are still measured
+			pingResult.TimestampFallback = FallbackNoReply
+			if unsupported {
+				pingResult.TimestampFallback = FallbackUnsupported
+			}
+			pingReturn = PingReturn{allTime: pingReturn.allTime[:0]}
+			err = round(ctx, ip, option.Count(), option.PacketInterval(), &pingReturn, echo)
+		} else if pingReturn.owdCount == 0 {
+			pingResult.TimestampFallback = FallbackNonStandardClock
+		}
+	}
+	if err != nil {
+		return pingResult, err
 	}
 
 	pingResult.Success = pingReturn.success
@@ -93,9 +144,132 @@ func runPing(ipAddr string, ip string, srcAddr string, icmpID int, option *PingO
 	pingResult.UncorrectedSDTime = time.Duration(common.TimeUncorrectedDeviation(pingReturn.allTime))
 	pingResult.CorrectedSDTime = time.Duration(common.TimeCorrectedDeviation(pingReturn.allTime))
 	pingResult.RangeTime = time.Duration(common.TimeRange(pingReturn.allTime))
+	if len(pingReturn.allTime) >= 2 {
+		stdDev := time.Duration(common.TimeUncorrectedDeviation(pingReturn.allTime))
+		jitter := time.Duration(common.TimeMeanAbsoluteDelta(pingReturn.allTime))
+		pingResult.StdDevTime, pingResult.JitterTime = &stdDev, &jitter
+	}
 	pingResult.SntSummary = option.Count()
 	pingResult.SntFailSummary = option.Count() - pingReturn.succSum
 	pingResult.SntTimeSummary = time.Duration(common.TimeRange(pingReturn.allTime))
+	pingResult.ReplyTTL = pingReturn.ttl
+	pingResult.ReplyTTLMin = pingReturn.ttlMin
+	pingResult.ReplyTTLMax = pingReturn.ttlMax
+	pingResult.Duplicates = pingReturn.duplicates
+	pingResult.DuplicatesSummary = pingReturn.duplicates
+	pingResult.LateReplies = pingReturn.late
+	pingResult.LateRepliesSummary = pingReturn.late
+	pingResult.LateDepth = pingReturn.lateDepth
+	if pingReturn.owdCount > 0 {
+		forward := pingReturn.owdForwardSum / time.Duration(pingReturn.owdCount)
+		back := pingReturn.owdReturnSum / time.Duration(pingReturn.owdCount)
+		pingResult.OwdForwardTime, pingResult.OwdReturnTime = &forward, &back
+	}
 
 	return pingResult, nil
 }
+
+// round sends the count requests of a probe round with send waiting pace between them, the replies of the ip are accumulated in r
+// Every request has its own sequence number so that a late reply is not taken for the reply of a later request
+func round(ctx context.Context, ip string, count int, pace time.Duration, r *PingReturn, send func(seq int) (common.IcmpReturn, error)) error {
+	order := newArrivals(count)
+	defer func() {
+		r.duplicates, r.late, r.lateDepth = order.duplicates, order.late, order.depth
+	}()
+	for seq := 0; seq < count; seq++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if seq > 0 {
+			if err := common.Pace(ctx, pace); err != nil {
+				return err
+			}
+		}
+		icmpReturn, err := send(seq)
+		// The other replies arrived while waiting for the reply of seq, the late replies of the lost requests are not counted as replies
+		for _, other := range icmpReturn.Others {
+			order.reply(other, seq)
+		}
+		if err != nil || !icmpReturn.Success || !common.IsEqualIP(ip, icmpReturn.Addr) {
+			continue
+		}
+		order.reply(seq, seq)
+
+		elapsed := rtt.Clamp(icmpReturn.Elapsed)
+		r.allTime = append(r.allTime, elapsed)
+
+		r.succSum++
+		if r.worstTime == time.Duration(0) || elapsed > r.worstTime {
+			r.worstTime = elapsed
+		}
+		if r.bestTime == time.Duration(0) || elapsed < r.bestTime {
+			r.bestTime = elapsed
+		}
+		r.sumTime += elapsed
+		r.avgTime = r.sumTime / time.Duration(r.succSum)
+		r.success = true
+		if icmpReturn.TTL > 0 {
+			r.ttl = icmpReturn.TTL
+			if r.ttlMin == 0 || icmpReturn.TTL < r.ttlMin {
+				r.ttlMin = icmpReturn.TTL
+			}
+			if icmpReturn.TTL > r.ttlMax {
+				r.ttlMax = icmpReturn.TTL
+			}
+		}
+	}
+	return nil
+}
+
+// arrivals per sequence bookkeeping of the replies of a round in their order of arrival
+type arrivals struct {
+	answered   []bool
+	duplicates int
+	// late replies arrived after the timeout of their request while waiting for the reply of a later one, depth their largest distance in sequence numbers to it
+	late  int
+	depth int
+}
+
+func newArrivals(count int) *arrivals {
+	return &arrivals{answered: make([]bool, count)}
+}
+
+// reply records the arrival of a reply of seq while waiting for the reply of awaited
+// A reply of an answered sequence number is a duplicate and the reply of an earlier request is late
+func (a *arrivals) reply(seq int, awaited int) {
+	if seq < 0 || seq >= len(a.answered) {
+		return
+	}
+	if a.answered[seq] {
+		a.duplicates++
+		return
+	}
+	a.answered[seq] = true
+	if seq < awaited {
+		a.late++
+		a.depth = max(a.depth, awaited-seq)
+	}
+}
+
+// nonStandardTimestamp high order bit of the timestamps that are not milliseconds since midnight UT (RFC 792)
+const nonStandardTimestamp = 1 << 31
+
+// msPerDay wrap around of the timestamps at midnight UT
+const msPerDay = 24 * 60 * 60 * 1000
+
+// oneWayDelays returns the forward (originate to receive) and return (transmit to returned) delays of a timestamp reply
+// The timestamps wrap around at midnight, the differences are taken within ± half a day and have a resolution of a millisecond
+func oneWayDelays(ts icmp.TimestampReturn) (forward time.Duration, back time.Duration) {
+	return msDelta(ts.Receive, ts.Originate), msDelta(ts.Returned, ts.Transmit)
+}
+
+// msDelta returns to - from of two timestamps in milliseconds since midnight
+func msDelta(to uint32, from uint32) time.Duration {
+	d := (int64(to) - int64(from)) % msPerDay
+	if d > msPerDay/2 {
+		d -= msPerDay
+	} else if d < -msPerDay/2 {
+		d += msPerDay
+	}
+	return time.Duration(d) * time.Millisecond
+}
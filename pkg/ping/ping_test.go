@@ -0,0 +1,106 @@
+package ping
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+func TestArrivals(t *testing.T) {
+	// reply of seq received while waiting for the reply of awaited
+	type reply struct{ seq, awaited int }
+	tests := []struct {
+		name       string
+		count      int
+		replies    []reply
+		duplicates int
+		late       int
+		depth      int
+	}{
+		{name: "in order", count: 4, replies: []reply{{0, 0}, {1, 1}, {2, 2}, {3, 3}}},
+		{name: "gaps", count: 4, replies: []reply{{0, 0}, {3, 3}}},
+		{name: "late", count: 4, replies: []reply{{0, 1}, {1, 2}, {2, 3}}, late: 3, depth: 1},
+		{name: "late without a later reply", count: 4, replies: []reply{{1, 3}}, late: 1, depth: 2},
+		{name: "late after a later reply", count: 4, replies: []reply{{2, 2}, {0, 3}, {1, 3}}, late: 2, depth: 3},
+		{name: "duplicates", count: 3, replies: []reply{{0, 0}, {0, 1}, {1, 1}, {1, 2}, {1, 2}}, duplicates: 3},
+		{name: "duplicate of a late reply", count: 3, replies: []reply{{0, 2}, {0, 2}}, duplicates: 1, late: 1, depth: 2},
+		{name: "out of range", count: 2, replies: []reply{{-1, 0}, {0, 0}, {2, 1}, {1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newArrivals(tt.count)
+			for _, r := range tt.replies {
+				a.reply(r.seq, r.awaited)
+			}
+			if a.duplicates != tt.duplicates || a.late != tt.late || a.depth != tt.depth {
+				t.Errorf("duplicates %d late %d depth %d, want %d %d %d", a.duplicates, a.late, a.depth, tt.duplicates, tt.late, tt.depth)
+			}
+		})
+	}
+}
+
+func TestRoundLateAndDuplicateReplies(t *testing.T) {
+	const ip = "192.168.0.1"
+	// Replies of every request of the round, a lost request has no reply of its own
+	replies := []common.IcmpReturn{
+		{Success: true, Addr: ip, Elapsed: 10 * time.Millisecond},
+		// Lost, its reply arrives late while waiting for seq 3
+		{},
+		// The reply of seq 0 arrived a second time
+		{Success: true, Addr: ip, Elapsed: 20 * time.Millisecond, Others: []int{0}},
+		{Others: []int{1}},
+		// Reply of another address
+		{Success: true, Addr: "192.168.0.2", Elapsed: 5 * time.Millisecond},
+		// Late reply of the lost seq 3 followed by its duplicate
+		{Success: true, Addr: ip, Elapsed: 30 * time.Millisecond, Others: []int{3, 3}},
+	}
+	count := len(replies)
+
+	var r PingReturn
+	var sent []int
+	err := round(context.Background(), ip, count, 0, &r, func(seq int) (common.IcmpReturn, error) {
+		sent = append(sent, seq)
+		return replies[seq], nil
+	})
+	if err != nil {
+		t.Fatalf("round: %s", err)
+	}
+	if len(sent) != count {
+		t.Errorf("sent sequences %v, want %d", sent, count)
+	}
+
+	// The late replies of the lost requests are not taken for replies
+	if lost := count - r.succSum; lost != 3 {
+		t.Errorf("lost %d, want 3", lost)
+	}
+	if r.duplicates != 2 {
+		t.Errorf("duplicates %d, want 2", r.duplicates)
+	}
+	// seq 1 while waiting for seq 3 and seq 3 while waiting for seq 5
+	if r.late != 2 || r.lateDepth != 2 {
+		t.Errorf("late %d depth %d, want 2 2", r.late, r.lateDepth)
+	}
+	if r.bestTime != 10*time.Millisecond || r.worstTime != 30*time.Millisecond || r.avgTime != 20*time.Millisecond {
+		t.Errorf("best %s avg %s worst %s, want 10ms 20ms 30ms", r.bestTime, r.avgTime, r.worstTime)
+	}
+}
+
+func TestRoundCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var r PingReturn
+	sent := 0
+	err := round(ctx, "192.168.0.1", 4, 0, &r, func(seq int) (common.IcmpReturn, error) {
+		sent++
+		cancel()
+		return common.IcmpReturn{Success: true, Addr: "192.168.0.1", Others: []int{seq}}, nil
+	})
+	if err == nil || sent != 1 {
+		t.Errorf("round after cancel: %v with %d requests sent, want an error after 1", err, sent)
+	}
+	// The counts of the replies received before the cancel are kept
+	if r.duplicates != 1 {
+		t.Errorf("duplicates %d, want 1", r.duplicates)
+	}
+}
@@ -7,6 +7,24 @@ const defaultPackerSize = 56
 const defaultCount = 10
 const defaultTTL = 128
 
+// Modes of the probe rounds, echo requests or timestamp requests measuring the one-way delays as well
+const (
+	ModeEcho      = "echo"
+	ModeTimestamp = "timestamp"
+)
+
+// Reasons of a timestamp round without one-way delays
+const (
+	// FallbackIPv6 the target resolved to an IPv6 address, the echo requests are sent
+	FallbackIPv6 = "ipv6"
+	// FallbackUnsupported the platform can not send timestamp requests, the echo requests are sent
+	FallbackUnsupported = "unsupported"
+	// FallbackNoReply no timestamp request of the round was answered, the echo requests are sent
+	FallbackNoReply = "no_reply"
+	// FallbackNonStandardClock the timestamps of the replies are not milliseconds since midnight UT
+	FallbackNonStandardClock = "non_standard_clock"
+)
+
 // PingResult Calculated results
 type PingResult struct {
 	Success              bool          `json:"success"`
@@ -21,9 +39,35 @@ type PingResult struct {
 	UncorrectedSDTime    time.Duration `json:"usd"`
 	CorrectedSDTime      time.Duration `json:"csd"`
 	RangeTime            time.Duration `json:"range"`
-	SntSummary           int           `json:"snt_summary"`
-	SntFailSummary       int           `json:"snt_fail_summary"`
-	SntTimeSummary       time.Duration `json:"snt_time_summary"`
+	// StdDevTime standard deviation of the RTTs of the replies and JitterTime mean absolute difference between the RTTs of the consecutive replies, nil with less than two replies
+	StdDevTime     *time.Duration `json:"stddev,omitempty"`
+	JitterTime     *time.Duration `json:"jitter,omitempty"`
+	SntSummary     int            `json:"snt_summary"`
+	SntFailSummary int            `json:"snt_fail_summary"`
+	SntTimeSummary time.Duration  `json:"snt_time_summary"`
+	PayloadSize    int            `json:"payload_size"`
+	// ReplyTTL IP TTL (IPv4) or hop limit (IPv6) of the last echo reply and its range over the probe round, 0 when not reported
+	ReplyTTL    int `json:"reply_ttl"`
+	ReplyTTLMin int `json:"reply_ttl_min"`
+	ReplyTTLMax int `json:"reply_ttl_max"`
+	// Duplicates echo replies received for an already answered sequence number of the round, not counted as replies, and DuplicatesSummary since the start
+	Duplicates        int `json:"duplicates"`
+	DuplicatesSummary int `json:"duplicates_summary"`
+	// LateReplies echo replies of the round received after the timeout of their request, LateRepliesSummary since the start and LateDepth their largest distance in sequence numbers to the awaited request
+	// The requests are sent one at a time, a reply overtaken by the reply of a later request is always late
+	LateReplies        int `json:"late_replies"`
+	LateRepliesSummary int `json:"late_replies_summary"`
+	LateDepth          int `json:"late_depth"`
+	// PacketInterval delay between the requests of the round
+	PacketInterval time.Duration `json:"packet_interval"`
+	// OwdForwardTime and OwdReturnTime mean forward and return one-way delays of the timestamp replies of the round, nil without them
+	// They include the clock offset between the prober and the target
+	OwdForwardTime *time.Duration `json:"owd_forward,omitempty"`
+	OwdReturnTime  *time.Duration `json:"owd_return,omitempty"`
+	// TimestampFallback reason the timestamp round has no one-way delays, empty in echo mode or with one-way delays
+	TimestampFallback string `json:"timestamp_fallback,omitempty"`
+	// HopLimitFailed the configured hop limit could not be set, the requests were sent with the one of the system
+	HopLimitFailed bool `json:"hop_limit_failed,omitempty"`
 }
 
 // PingReturn ICMP Response
@@ -35,13 +79,25 @@ type PingReturn struct {
 	bestTime  time.Duration
 	avgTime   time.Duration
 	worstTime time.Duration
+	ttl       int
+	ttlMin    int
+	ttlMax    int
+	// duplicates replies received for an answered sequence number, late after the timeout of their request and lateDepth their largest distance to the awaited one
+	duplicates int
+	late       int
+	lateDepth  int
+	// owdCount timestamp replies with standard timestamps and the sums of their one-way delays
+	owdCount      int
+	owdForwardSum time.Duration
+	owdReturnSum  time.Duration
 }
 
 // PingOptions ICMP Options
 type PingOptions struct {
-	count      int
-	timeout    time.Duration
-	packetSize int
+	count          int
+	timeout        time.Duration
+	packetSize     int
+	packetInterval time.Duration
 }
 
 // Count Getter
@@ -82,3 +138,13 @@ func (options *PingOptions) PacketSize() int {
 func (options *PingOptions) SetPacketSize(packetSize int) {
 	options.packetSize = packetSize
 }
+
+// PacketInterval Getter
+func (options *PingOptions) PacketInterval() time.Duration {
+	return options.packetInterval
+}
+
+// SetPacketInterval Setter
+func (options *PingOptions) SetPacketInterval(packetInterval time.Duration) {
+	options.packetInterval = packetInterval
+}
@@ -0,0 +1,175 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// Probe SMTP Operation, connects to the server and waits for its 220 banner, then sends EHLO with the ehlo name (none when empty) and QUIT
+// With a TLS config STARTTLS is sent after EHLO and followed by the TLS handshake, the server name defaults to the host of the target
+// The exchange runs within the timeout from the start of the connection, the probe is aborted when the context is canceled
+func Probe(ctx context.Context, destAddr string, ip string, srcAddr string, device string, port string, timeout time.Duration, ehlo string, tlsConfig *tls.Config) (*SMTPReturn, error) {
+	out := SMTPReturn{DestAddr: destAddr, DestIp: ip, DestPort: port, SrcIp: "0.0.0.0"}
+
+	d := net.Dialer{Timeout: timeout}
+	if srcAddr != "" {
+		srcIp := net.ParseIP(srcAddr)
+		if srcIp == nil {
+			out.ErrorReason = "connect"
+			return &out, fmt.Errorf("source ip: %v is invalid, SMTP target: %v", srcAddr, destAddr)
+		}
+		d.LocalAddr = &net.TCPAddr{IP: srcIp}
+	}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if device != "" {
+			if err := common.BindToDevice(c, device); err != nil {
+				common.SourceInterfaceFailures.BindFailed("SMTP", device, err)
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+	out.ConTime = time.Since(start)
+	if err != nil {
+		out.ErrorReason = errorReason(err)
+		return &out, err
+	}
+	defer conn.Close()
+	out.SrcIp = conn.LocalAddr().(*net.TCPAddr).IP.String()
+
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		out.ErrorReason = "connect"
+		return &out, fmt.Errorf("error setting deadline timeout: %v", err)
+	}
+	// Closing the connection aborts the exchange when the target is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	text := textproto.NewConn(conn)
+	bannerStart := time.Now()
+	_, err = reply(&out, text, 220)
+	out.BannerTime = time.Since(bannerStart)
+	if err != nil {
+		out.ErrorReason = replyReason(err, "no_banner")
+		return &out, fmt.Errorf("banner: %v", err)
+	}
+
+	if ehlo != "" {
+		out.EHLO = true
+		ehloStart := time.Now()
+		msg, err := command(&out, text, 250, "EHLO %s", ehlo)
+		out.EHLOTime = time.Since(ehloStart)
+		if err != nil {
+			out.ErrorReason = replyReason(err, errorReason(err))
+			return &out, fmt.Errorf("EHLO: %v", err)
+		}
+
+		if tlsConfig != nil {
+			out.StartTLS = true
+			if !extension(msg, "STARTTLS") {
+				out.ErrorReason = "no_starttls"
+				return &out, fmt.Errorf("STARTTLS not offered by the server")
+			}
+			if _, err := command(&out, text, 220, "STARTTLS"); err != nil {
+				out.ErrorReason = replyReason(err, errorReason(err))
+				return &out, fmt.Errorf("STARTTLS: %v", err)
+			}
+			tlsConn, err := handshake(ctx, &out, conn, tlsConfig)
+			if err != nil {
+				out.ErrorReason = "tls"
+				return &out, fmt.Errorf("TLS handshake: %v", err)
+			}
+			text = textproto.NewConn(tlsConn)
+		}
+	}
+
+	out.Success = true
+	// The servers closing the connection without answering the QUIT do not fail the probe
+	if err := text.PrintfLine("QUIT"); err == nil {
+		_, _, _ = text.ReadResponse(221)
+	}
+	return &out, nil
+}
+
+// command sends a command and reads its reply
+func command(out *SMTPReturn, text *textproto.Conn, expectCode int, format string, args ...any) (string, error) {
+	if err := text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return reply(out, text, expectCode)
+}
+
+// reply reads a reply, multiline ones included, and fails when its code is not the expected one
+func reply(out *SMTPReturn, text *textproto.Conn, expectCode int) (string, error) {
+	code, msg, err := text.ReadResponse(expectCode)
+	if code != 0 {
+		out.Code = code
+	}
+	return msg, err
+}
+
+// extension reports if the EHLO reply lists an extension, its first line is the greeting of the server
+func extension(msg string, name string) bool {
+	lines := strings.Split(msg, "\n")
+	for _, l := range lines[1:] {
+		if f := strings.Fields(l); len(f) > 0 && strings.EqualFold(f[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// handshake runs the TLS handshake on the connection and sets its timing, version and certificates, the ones rejected by the verification included
+func handshake(ctx context.Context, out *SMTPReturn, conn net.Conn, tlsConfig *tls.Config) (*tls.Conn, error) {
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = out.DestAddr
+	}
+	tlsConn := tls.Client(conn, config)
+	start := time.Now()
+	err := tlsConn.HandshakeContext(ctx)
+	out.TLSTime = time.Since(start)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			out.TLSEarliestCertExpiry = common.EarliestExpiry(certErr.UnverifiedCertificates)
+		}
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	out.TLSVersion = common.TLSVersion(&state)
+	out.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+	out.TLSVerified = len(state.VerifiedChains) > 0
+	out.TLSEarliestCertExpiry = common.EarliestCertExpiry(&state)
+	return tlsConn, nil
+}
+
+// replyReason returns bad_code for the replies with another code than the expected one or malformed, the reason otherwise
+func replyReason(err error, reason string) string {
+	var codeErr *textproto.Error
+	var protoErr textproto.ProtocolError
+	if errors.As(err, &codeErr) || errors.As(err, &protoErr) {
+		return "bad_code"
+	}
+	return reason
+}
+
+// errorReason returns the error reason of a failed connection
+func errorReason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "connect"
+}
@@ -0,0 +1,28 @@
+package smtp
+
+import "time"
+
+// SMTPReturn Calculated results
+type SMTPReturn struct {
+	Success  bool          `json:"success"`
+	DestAddr string        `json:"dest_address"`
+	DestIp   string        `json:"dest_ip"`
+	DestPort string        `json:"dest_port"`
+	SrcIp    string        `json:"src_ip"`
+	ConTime  time.Duration `json:"connection_time"`
+	// BannerTime time from the connection to the end of the banner, Code last reply code of the server, 0 without reply
+	BannerTime time.Duration `json:"banner_time"`
+	Code       int           `json:"code"`
+	// EHLO the EHLO command was sent, EHLOTime its exchange
+	EHLO     bool          `json:"ehlo"`
+	EHLOTime time.Duration `json:"ehlo_time"`
+	// StartTLS the STARTTLS command was sent, TLSTime duration of the TLS handshake following it, TLSVerified the certificate chain of the server was verified (false with insecure_skip_verify)
+	StartTLS              bool          `json:"starttls"`
+	TLSTime               time.Duration `json:"tls_handshake_time"`
+	TLSVersion            string        `json:"tls_version,omitempty"`
+	TLSCipher             string        `json:"tls_cipher,omitempty"`
+	TLSVerified           bool          `json:"tls_verified"`
+	TLSEarliestCertExpiry time.Time     `json:"tls_earliest_cert_expiry,omitempty"`
+	// ErrorReason phase of the failure: source_interface, connect, timeout, no_banner, bad_code, no_starttls or tls
+	ErrorReason string `json:"error_reason,omitempty"`
+}
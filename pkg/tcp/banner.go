@@ -0,0 +1,43 @@
+package tcp
+
+import (
+	"net"
+	"regexp"
+)
+
+// defaultReadLimit bytes of the banner read at most when the limit is not set
+const defaultReadLimit = 4096
+
+// Banner exchange of a TCP probe after the connection, the Send bytes are written and the response is read until Expect matches it
+type Banner struct {
+	Send      []byte
+	Expect    *regexp.Regexp
+	ReadLimit int
+}
+
+// exchange writes the send bytes and reads the response until the expect regex matches, ReadLimit bytes were read, the server closed the connection or the deadline expired
+// Only the write errors are returned, the read ones end the banner that is then matched
+func (b *Banner) exchange(conn net.Conn) (bool, error) {
+	if len(b.Send) > 0 {
+		if _, err := conn.Write(b.Send); err != nil {
+			return false, err
+		}
+	}
+	limit := b.ReadLimit
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	buf := make([]byte, limit)
+	read := 0
+	for read < limit {
+		n, err := conn.Read(buf[read:])
+		read += n
+		if b.Expect.Match(buf[:read]) {
+			return true, nil
+		}
+		if err != nil {
+			return false, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,74 @@
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// targetReplies replies of a SOCKS5 proxy that reached but could not connect the target
+var targetReplies = []string{"network unreachable", "host unreachable", "connection refused", "TTL expired"}
+
+// proxyDialer dialer of the connection to the proxy, it records the connect time and whether the proxy was reached
+type proxyDialer struct {
+	*net.Dialer
+	conTime   time.Duration
+	connected bool
+}
+
+// DialContext connects to the proxy
+func (d *proxyDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	d.conTime = time.Since(start)
+	d.connected = err == nil
+	return conn, err
+}
+
+// dialProxy connects to the address through the SOCKS5 proxy within the timeout, the connection to the proxy is dialed from the source address and device of the dialer
+// The failures to reach or negotiate with the proxy are reported as proxy, the target refused or unreachable behind the proxy as connect
+func dialProxy(ctx context.Context, out *TCPPortReturn, d *net.Dialer, proxyURL *url.URL, address string, timeout time.Duration) (net.Conn, error) {
+	out.Proxy = true
+	forward := &proxyDialer{Dialer: d}
+	dialer, err := proxy.FromURL(proxyURL, forward)
+	if err != nil {
+		out.ErrorReason = "proxy"
+		return nil, err
+	}
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		out.ErrorReason = "proxy"
+		return nil, fmt.Errorf("proxy %s does not support contexts", proxyURL.Redacted())
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := contextDialer.DialContext(dialCtx, "tcp", address)
+	out.ProxyConTime = forward.conTime
+	if err != nil {
+		out.ErrorReason = proxyErrorReason(err, forward.connected)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// proxyErrorReason returns the error reason of a connection failed through the proxy
+func proxyErrorReason(err error, connected bool) string {
+	if !connected {
+		return "proxy"
+	}
+	for _, reply := range targetReplies {
+		if strings.HasSuffix(err.Error(), reply) {
+			return "connect"
+		}
+	}
+	if reason := errorReason(err); reason == "timeout" {
+		return reason
+	}
+	return "proxy"
+}
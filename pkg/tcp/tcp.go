@@ -1,13 +1,56 @@
 package tcp
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/url"
+	"syscall"
 	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
 )
 
-// Port TCP Operation
-func Port(destAddr string, ip string, srcAddr string, port string, timeout time.Duration) (*TCPPortReturn, error) {
+// setTOS sets the TOS (IPv4) or traffic class (IPv6) of a probe socket before the connection, the probe is sent unmarked when it can not be set
+func setTOS(network string, c syscall.RawConn, tos int) {
+	if tos <= 0 {
+		return
+	}
+	var err error
+	cerr := c.Control(func(fd uintptr) {
+		if network == "tcp6" {
+			err = setTOSv6(fd, tos)
+		} else {
+			err = setTOSv4(fd, tos)
+		}
+	})
+	if cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		common.TOSFailures.Fail("TCP", tos, err)
+	}
+}
+
+// bindDevice binds a probe socket to the device of its source interface, the probe only uses the address of the interface when it can not be bound
+func bindDevice(probe string, c syscall.RawConn, device string) {
+	if device == "" {
+		return
+	}
+	if err := common.BindToDevice(c, device); err != nil {
+		common.SourceInterfaceFailures.BindFailed(probe, device, err)
+	}
+}
+
+// Port TCP Operation, the connection attempt is aborted when the context is canceled
+// A tos >0 marks the packets of the connection with the TOS (IPv4) or traffic class (IPv6), the socket is bound to the device when set
+// With a SOCKS5 proxy the connection is established through it, ConTime is then the time to the target and ProxyConTime the connect time to the proxy
+// With a TLS config the TLS handshake follows the connection, the server name defaults to the host of the target
+// With a banner the connection exchanges it within the remaining timeout, the connection is closed when the exchange ends or is aborted
+// Without the IP of the target resolved by the monitor the host is resolved by the probe within the timeout, DNSTime is then the resolution time
+func Port(ctx context.Context, destAddr string, ip string, srcAddr string, device string, port string, timeout time.Duration, tos int, proxyURL *url.URL, tlsConfig *tls.Config, banner *Banner) (*TCPPortReturn, error) {
 	var out TCPPortReturn
 	var d net.Dialer
 	var err error
@@ -19,10 +62,15 @@ func Port(destAddr string, ip string, srcAddr string, port string, timeout time.
 	out.DestIp = ip
 	out.DestPort = port
 
+	// TotalTime covers all the phases of the probe, the failed ones included
+	probeStart := time.Now()
+	defer func() { out.TotalTime = time.Since(probeStart) }()
+
 	if srcAddr != "" {
 		srcIp := net.ParseIP(srcAddr)
 		if srcIp == nil {
 			out.Success = false
+			out.ErrorReason = "connect"
 			return &out, fmt.Errorf("source ip: %v is invalid, TCP target: %v", srcAddr, destAddr)
 		}
 		d = net.Dialer{
@@ -38,24 +86,135 @@ func Port(destAddr string, ip string, srcAddr string, port string, timeout time.
 		}
 	}
 
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		setTOS(network, c, tos)
+		bindDevice("TCP", c, device)
+		return nil
+	}
+
+	if net.ParseIP(ip) == nil {
+		out.DNS = true
+		if ip, err = resolve(ctx, &out, destAddr, tcpOptions.Timeout()); err != nil {
+			out.SrcIp = "0.0.0.0"
+			out.Success = false
+			out.ErrorReason = "dns"
+			return &out, err
+		}
+		out.DestIp = ip
+	}
+
 	start := time.Now()
-	conn, err := d.Dial("tcp", net.JoinHostPort(ip, port))
+	var conn net.Conn
+	if proxyURL != nil {
+		conn, err = dialProxy(ctx, &out, &d, proxyURL, net.JoinHostPort(ip, port), tcpOptions.Timeout())
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+	}
 	out.ConTime = time.Since(start)
 	if err != nil {
 		out.SrcIp = "0.0.0.0"
 		out.Success = false
+		if out.ErrorReason == "" {
+			out.ErrorReason = errorReason(err)
+		}
 		return &out, err
 	}
 
 	defer conn.Close()
 	out.SrcIp = conn.LocalAddr().(*net.TCPAddr).IP.String()
 
-	// Set Deadline timeout
-	if err := conn.SetDeadline(time.Now().Add(tcpOptions.Timeout())); err != nil {
+	// Set Deadline timeout, the TLS handshake and the banner are exchanged within the remaining timeout of the probe
+	deadline := time.Now().Add(tcpOptions.Timeout())
+	if tlsConfig != nil || banner != nil {
+		deadline = start.Add(tcpOptions.Timeout())
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
 		out.Success = false
+		out.ErrorReason = "connect"
 		return &out, fmt.Errorf("error setting deadline timeout: %v", err)
 	}
 
 	out.Success = true
+	if tlsConfig == nil && banner == nil {
+		return &out, nil
+	}
+
+	// Closing the connection aborts the exchange when the target is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if tlsConfig != nil {
+		out.TLS = true
+		tlsConn, err := handshake(ctx, &out, conn, tlsConfig)
+		if err != nil {
+			out.Success = false
+			out.ErrorReason = "tls"
+			return &out, fmt.Errorf("TLS handshake: %v", err)
+		}
+		conn = tlsConn
+	}
+
+	if banner != nil {
+		out.Banner = true
+		bannerStart := time.Now()
+		out.BannerMatch, err = banner.exchange(conn)
+		out.BannerTime = time.Since(bannerStart)
+		if !out.BannerMatch {
+			out.ErrorReason = "expect"
+		}
+		if err != nil {
+			return &out, fmt.Errorf("error sending the banner: %v", err)
+		}
+	}
 	return &out, nil
 }
+
+// resolve returns the first address of the host and sets the resolution time
+func resolve(ctx context.Context, out *TCPPortReturn, host string, timeout time.Duration) (string, error) {
+	resolveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(resolveCtx, host)
+	out.DNSTime = time.Since(start)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("resolving %s: no address", host)
+	}
+	return addrs[0].IP.String(), nil
+}
+
+// handshake runs the TLS handshake on the connection and sets its timing, version and certificates, the ones rejected by the verification included
+func handshake(ctx context.Context, out *TCPPortReturn, conn net.Conn, tlsConfig *tls.Config) (*tls.Conn, error) {
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = out.DestAddr
+	}
+	tlsConn := tls.Client(conn, config)
+	start := time.Now()
+	err := tlsConn.HandshakeContext(ctx)
+	out.TLSTime = time.Since(start)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			out.TLSEarliestCertExpiry = common.EarliestExpiry(certErr.UnverifiedCertificates)
+		}
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	out.TLSVersion = common.TLSVersion(&state)
+	out.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+	out.TLSVerified = len(state.VerifiedChains) > 0
+	out.TLSEarliestCertExpiry = common.EarliestCertExpiry(&state)
+	return tlsConn, nil
+}
+
+// errorReason returns the error reason of a failed connection
+func errorReason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "connect"
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package tcp
+
+import (
+	"syscall"
+)
+
+// setTOSv4 sets the IPv4 TOS socket option on Unix-like systems
+func setTOSv4(fd uintptr, tos int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+}
+
+// setTOSv6 sets the IPv6 traffic class socket option on Unix-like systems
+func setTOSv6(fd uintptr, tos int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package tcp
+
+import (
+	"syscall"
+)
+
+// ipv6TClass IPV6_TCLASS socket option (ws2ipdef.h), it is not defined by the syscall package
+const ipv6TClass = 39
+
+// setTOSv4 sets the IPv4 TOS socket option on Windows, the system may ignore it without error
+func setTOSv4(fd uintptr, tos int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+}
+
+// setTOSv6 sets the IPv6 traffic class socket option on Windows, the system may ignore it without error
+func setTOSv6(fd uintptr, tos int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IPV6, ipv6TClass, tos)
+}
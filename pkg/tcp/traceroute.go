@@ -1,8 +1,12 @@
 package tcp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,219 +19,174 @@ import (
 const (
 	protocolICMP     = 1  // Internet Control Message
 	protocolIPv6ICMP = 58 // ICMP for IPv6
+	protocolTCP      = 6
 )
 
 // Traceroute performs TCP-based traceroute by sending TCP SYN packets with incrementing TTL
-// and listening for ICMP Time Exceeded messages from intermediate routers
-func Traceroute(destAddr string, port string, srcAddr string, ttl int, timeout time.Duration, ipv6 bool) (hop common.IcmpReturn, err error) {
+// and listening for ICMP Time Exceeded messages from intermediate routers, it returns as soon as the context is canceled
+// The destination is reached when it answers the SYN with a SYN-ACK (open port) or a RST (closed port)
+// A tos >0 marks the SYN packets with the TOS (IPv4) or traffic class (IPv6)
+func Traceroute(ctx context.Context, destAddr string, port string, srcAddr string, ttl int, timeout time.Duration, tos int, ipv6 bool) (hop common.IcmpReturn, err error) {
 	dstIp := net.ParseIP(destAddr)
 	if dstIp == nil {
 		return hop, fmt.Errorf("destination ip: %v is invalid", destAddr)
 	}
 
 	if p4 := dstIp.To4(); len(p4) == net.IPv4len {
-		return tcpTracerouteIPv4(destAddr, port, srcAddr, ttl, timeout)
+		return tcpTraceroute(ctx, dstIp, port, srcAddr, ttl, timeout, tos, false)
 	}
 	if ipv6 {
-		return tcpTracerouteIPv6(destAddr, port, srcAddr, ttl, timeout)
+		return tcpTraceroute(ctx, dstIp, port, srcAddr, ttl, timeout, tos, true)
 	}
 	return hop, nil
 }
 
-func tcpTracerouteIPv4(destAddr string, port string, srcAddr string, ttl int, timeout time.Duration) (hop common.IcmpReturn, err error) {
+func tcpTraceroute(ctx context.Context, dstIp net.IP, port string, srcAddr string, ttl int, timeout time.Duration, tos int, v6 bool) (hop common.IcmpReturn, err error) {
 	hop.Success = false
 	start := time.Now()
+	deadline := start.Add(timeout)
+	dstPort, err := strconv.Atoi(port)
+	if err != nil {
+		return hop, fmt.Errorf("port: %v is invalid", port)
+	}
 
 	// Create ICMP listener to receive Time Exceeded messages
-	icmpConn, err := icmp.ListenPacket("ip4:icmp", srcAddr)
+	network := "ip4:icmp"
+	if v6 {
+		network = "ip6:ipv6-icmp"
+	}
+	icmpConn, err := icmp.ListenPacket(network, srcAddr)
 	if err != nil {
-		return hop, fmt.Errorf("failed to create ICMP listener: %v", err)
+		return hop, fmt.Errorf("failed to create ICMP listener: %w", err)
 	}
 	defer icmpConn.Close()
+	// Closing the socket unblocks the pending read
+	defer context.AfterFunc(ctx, func() { icmpConn.Close() })()
 
-	if err = icmpConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+	if err = icmpConn.SetDeadline(deadline); err != nil {
 		return hop, err
 	}
 
-	// Create TCP connection with custom TTL
+	// The socket is bound before the connection so that the Time Exceeded of its SYN is told apart from the ones of the other probes by the source port
+	var srcPort atomic.Int64
+	srcIp := net.ParseIP(srcAddr)
+
+	// Create TCP connection with custom TTL (hop limit for IPv6)
 	d := &net.Dialer{
-		Timeout: timeout,
+		Deadline: deadline,
 		Control: func(network, address string, c syscall.RawConn) error {
 			var syscallErr error
 			err := c.Control(func(fd uintptr) {
-				// Set TTL for IPv4 using platform-appropriate type
-				syscallErr = setTTLv4(fd, ttl)
+				// Set TTL using platform-appropriate type
+				if v6 {
+					syscallErr = setTTLv6(fd, ttl)
+				} else {
+					syscallErr = setTTLv4(fd, ttl)
+				}
+				if syscallErr != nil {
+					return
+				}
+				var port int
+				port, syscallErr = bindSource(fd, sourceSockaddr(srcIp, v6))
+				srcPort.Store(int64(port))
 			})
 			if err != nil {
 				return err
 			}
+			setTOS(network, c, tos)
 			return syscallErr
 		},
 	}
 
-	if srcAddr != "" {
-		srcIp := net.ParseIP(srcAddr)
-		if srcIp != nil {
-			d.LocalAddr = &net.TCPAddr{IP: srcIp, Port: 0}
-		}
-	}
-
 	// Start TCP connection attempt (this will send SYN packet with custom TTL)
 	connChan := make(chan error, 1)
 	go func() {
-		conn, err := d.Dial("tcp", net.JoinHostPort(destAddr, port))
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(dstIp.String(), port))
 		if conn != nil {
 			conn.Close()
 		}
 		connChan <- err
 	}()
 
-	// Listen for ICMP Time Exceeded or wait for TCP connection
-	for {
-		select {
-		case connErr := <-connChan:
-			// TCP connection completed or failed
-			elapsed := time.Since(start)
-			if connErr == nil {
-				// Successfully connected - we reached the destination
-				hop.Elapsed = elapsed
-				hop.Addr = destAddr
-				hop.Success = true
-				return hop, nil
-			}
-			// Connection failed but we might have gotten ICMP response
-			// Continue to check if we received ICMP message
-			time.Sleep(10 * time.Millisecond)
-			select {
-			case <-time.After(timeout - elapsed):
-				return hop, fmt.Errorf("timeout waiting for response")
-			default:
-				// Try to read any pending ICMP message
-			}
-
-		case <-time.After(timeout):
-			return hop, fmt.Errorf("timeout")
-		default:
-			// Try to read ICMP message
-			b := make([]byte, 1500)
+	// Read the ICMP Time Exceeded of the SYN packets sent to the destination, the socket receives the ones of all the probes
+	icmpChan := make(chan net.Addr, 1)
+	go func() {
+		b := make([]byte, 1500)
+		for {
 			n, peer, readErr := icmpConn.ReadFrom(b)
 			if readErr != nil {
-				// No ICMP message yet, continue waiting
-				time.Sleep(10 * time.Millisecond)
-				continue
+				return
 			}
-
-			if n > 0 {
-				x, err := icmp.ParseMessage(protocolICMP, b[:n])
-				if err != nil {
-					continue
-				}
-
-				// Check for Time Exceeded message
-				if x.Type == ipv4.ICMPTypeTimeExceeded {
-					elapsed := time.Since(start)
-					hop.Elapsed = elapsed
-					hop.Addr = peer.String()
-					hop.Success = true
-					return hop, nil
-				}
+			if timeExceeded(b[:n], v6, dstIp, int(srcPort.Load()), dstPort) {
+				icmpChan <- peer
+				return
 			}
 		}
+	}()
+
+	// Wait for ICMP Time Exceeded or for the answer of the destination
+	select {
+	case <-ctx.Done():
+		return hop, ctx.Err()
+	case connErr := <-connChan:
+		if connErr == nil || errors.Is(connErr, syscall.ECONNREFUSED) {
+			// SYN-ACK or RST - we reached the destination
+			hop.Elapsed = time.Since(start)
+			hop.Addr = dstIp.String()
+			hop.Success = true
+			return hop, nil
+		}
+		return hop, connErr
+	case peer := <-icmpChan:
+		hop.Elapsed = time.Since(start)
+		hop.Addr = peer.String()
+		hop.Success = true
+		return hop, nil
 	}
 }
 
-func tcpTracerouteIPv6(destAddr string, port string, srcAddr string, ttl int, timeout time.Duration) (hop common.IcmpReturn, err error) {
-	hop.Success = false
-	start := time.Now()
-
-	// Create ICMPv6 listener
-	icmpConn, err := icmp.ListenPacket("ip6:ipv6-icmp", srcAddr)
-	if err != nil {
-		return hop, fmt.Errorf("failed to create ICMPv6 listener: %v", err)
+// timeExceeded reports if an ICMP message is the Time Exceeded of a TCP packet sent from the source port to the destination address and port
+func timeExceeded(b []byte, v6 bool, dstIp net.IP, srcPort int, dstPort int) bool {
+	proto, timeExceededType := protocolICMP, icmp.Type(ipv4.ICMPTypeTimeExceeded)
+	if v6 {
+		proto, timeExceededType = protocolIPv6ICMP, ipv6.ICMPTypeTimeExceeded
 	}
-	defer icmpConn.Close()
-
-	if err = icmpConn.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return hop, err
+	x, err := icmp.ParseMessage(proto, b)
+	if err != nil || x.Type != timeExceededType {
+		return false
 	}
-
-	// Create TCP connection with custom hop limit (IPv6 equivalent of TTL)
-	d := &net.Dialer{
-		Timeout: timeout,
-		Control: func(network, address string, c syscall.RawConn) error {
-			var syscallErr error
-			err := c.Control(func(fd uintptr) {
-				// Set Hop Limit for IPv6 using platform-appropriate type
-				syscallErr = setTTLv6(fd, ttl)
-			})
-			if err != nil {
-				return err
-			}
-			return syscallErr
-		},
+	body, ok := x.Body.(*icmp.TimeExceeded)
+	if !ok {
+		return false
 	}
 
-	if srcAddr != "" {
-		srcIp := net.ParseIP(srcAddr)
-		if srcIp != nil {
-			d.LocalAddr = &net.TCPAddr{IP: srcIp, Port: 0}
-		}
-	}
+	dst, sport, dport, ok := common.QuotedPacket(body.Data, v6, protocolTCP)
+	return ok && dst.Equal(dstIp) && sport == srcPort && dport == dstPort
+}
 
-	// Start TCP connection attempt
-	connChan := make(chan error, 1)
-	go func() {
-		conn, err := d.Dial("tcp", net.JoinHostPort(destAddr, port))
-		if conn != nil {
-			conn.Close()
+// sourceSockaddr returns the socket address of the source IP with an ephemeral port, the unspecified address without source IP
+func sourceSockaddr(srcIp net.IP, v6 bool) syscall.Sockaddr {
+	if v6 {
+		sa := &syscall.SockaddrInet6{}
+		if ip := srcIp.To16(); ip != nil {
+			copy(sa.Addr[:], ip)
 		}
-		connChan <- err
-	}()
-
-	// Listen for ICMPv6 Time Exceeded or wait for TCP connection
-	for {
-		select {
-		case connErr := <-connChan:
-			elapsed := time.Since(start)
-			if connErr == nil {
-				// Successfully connected - we reached the destination
-				hop.Elapsed = elapsed
-				hop.Addr = destAddr
-				hop.Success = true
-				return hop, nil
-			}
-			time.Sleep(10 * time.Millisecond)
-			select {
-			case <-time.After(timeout - elapsed):
-				return hop, fmt.Errorf("timeout waiting for response")
-			default:
-			}
-
-		case <-time.After(timeout):
-			return hop, fmt.Errorf("timeout")
-		default:
-			b := make([]byte, 1500)
-			n, peer, readErr := icmpConn.ReadFrom(b)
-			if readErr != nil {
-				time.Sleep(10 * time.Millisecond)
-				continue
-			}
-
-			if n > 0 {
-				x, err := icmp.ParseMessage(protocolIPv6ICMP, b[:n])
-				if err != nil {
-					continue
-				}
+		return sa
+	}
+	sa := &syscall.SockaddrInet4{}
+	if ip := srcIp.To4(); ip != nil {
+		copy(sa.Addr[:], ip)
+	}
+	return sa
+}
 
-				// Check for Time Exceeded message
-				if x.Type == ipv6.ICMPTypeTimeExceeded {
-					elapsed := time.Since(start)
-					hop.Elapsed = elapsed
-					hop.Addr = peer.String()
-					hop.Success = true
-					return hop, nil
-				}
-			}
-		}
+// sockaddrPort returns the port of a socket address
+func sockaddrPort(sa syscall.Sockaddr) int {
+	switch a := sa.(type) {
+	case *syscall.SockaddrInet4:
+		return a.Port
+	case *syscall.SockaddrInet6:
+		return a.Port
 	}
+	return 0
 }
@@ -15,3 +15,15 @@ func setTTLv4(fd uintptr, ttl int) error {
 func setTTLv6(fd uintptr, ttl int) error {
 	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
 }
+
+// bindSource binds the socket to the source address before the connection and returns its port on Unix-like systems
+func bindSource(fd uintptr, sa syscall.Sockaddr) (int, error) {
+	if err := syscall.Bind(int(fd), sa); err != nil {
+		return 0, err
+	}
+	local, err := syscall.Getsockname(int(fd))
+	if err != nil {
+		return 0, err
+	}
+	return sockaddrPort(local), nil
+}
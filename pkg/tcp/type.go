@@ -12,6 +12,32 @@ type TCPPortReturn struct {
 	DestPort string        `json:"dest_port"`
 	SrcIp    string        `json:"src_ip"`
 	ConTime  time.Duration `json:"connection_time"`
+	// DNS the host was resolved by the probe instead of the monitor, DNSTime resolution time
+	DNS     bool          `json:"dns"`
+	DNSTime time.Duration `json:"dns_lookup_time"`
+	// TotalTime duration of all the phases of the probe: resolution, connection, TLS handshake and banner exchange
+	TotalTime time.Duration `json:"total_time"`
+	// Proxy the connection was established through a SOCKS5 proxy, ProxyConTime connect time to the proxy
+	Proxy        bool          `json:"proxy"`
+	ProxyConTime time.Duration `json:"proxy_connection_time"`
+	// TLSTime duration of the TLS handshake after the connection, TLSVerified the certificate chain of the server was verified (false with insecure_skip_verify)
+	TLS                   bool          `json:"tls"`
+	TLSTime               time.Duration `json:"tls_handshake_time"`
+	TLSVersion            string        `json:"tls_version,omitempty"`
+	TLSCipher             string        `json:"tls_cipher,omitempty"`
+	TLSVerified           bool          `json:"tls_verified"`
+	TLSEarliestCertExpiry time.Time     `json:"tls_earliest_cert_expiry,omitempty"`
+	// Banner the send/expect exchange ran after the connection, BannerMatch the expect regex matched the bytes read within BannerTime
+	Banner      bool          `json:"banner"`
+	BannerMatch bool          `json:"banner_match"`
+	BannerTime  time.Duration `json:"banner_time"`
+	// ErrorReason phase of the failure: source_interface, dns, proxy, connect, timeout, tls or expect
+	ErrorReason string `json:"error_reason,omitempty"`
+}
+
+// Succeeded reports if the connection was established and the expect regex matched the banner
+func (r *TCPPortReturn) Succeeded() bool {
+	return r.Success && (!r.Banner || r.BannerMatch)
 }
 
 // TCPPortOptions ICMP Options
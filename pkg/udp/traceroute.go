@@ -0,0 +1,187 @@
+package udp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1  // Internet Control Message
+	protocolIPv6ICMP = 58 // ICMP for IPv6
+	protocolUDP      = 17
+)
+
+// Traceroute performs UDP-based traceroute by sending UDP datagrams with incrementing TTL
+// and listening for ICMP Time Exceeded messages from intermediate routers, it returns as soon as the context is canceled
+// The destination port of every probe is the base port plus its sequence (33434, 33435, ...) so that the ICMP errors are matched to their probe
+// The destination is reached when it answers with an ICMP Port Unreachable (closed port) or a UDP datagram (open port)
+// A tos >0 marks the datagrams with the TOS (IPv4) or traffic class (IPv6)
+func Traceroute(ctx context.Context, destAddr string, basePort string, srcAddr string, ttl int, seq int, timeout time.Duration, payloadSize int, tos int, ipv6 bool) (hop common.IcmpReturn, err error) {
+	dstIp := net.ParseIP(destAddr)
+	if dstIp == nil {
+		return hop, fmt.Errorf("destination ip: %v is invalid", destAddr)
+	}
+	port, err := strconv.Atoi(basePort)
+	if err != nil || port < 1 || port > 65535 {
+		return hop, fmt.Errorf("port: %v is invalid", basePort)
+	}
+	// Wrap the sequence within the port range
+	dstPort := 1 + (port-1+seq)%65535
+
+	if p4 := dstIp.To4(); len(p4) == net.IPv4len {
+		return udpTraceroute(ctx, p4, dstPort, srcAddr, ttl, timeout, payloadSize, tos, false)
+	}
+	if ipv6 {
+		return udpTraceroute(ctx, dstIp, dstPort, srcAddr, ttl, timeout, payloadSize, tos, true)
+	}
+	return hop, nil
+}
+
+func udpTraceroute(ctx context.Context, dstIp net.IP, dstPort int, srcAddr string, ttl int, timeout time.Duration, payloadSize int, tos int, v6 bool) (hop common.IcmpReturn, err error) {
+	hop.Success = false
+	start := time.Now()
+	deadline := start.Add(timeout)
+
+	// Create ICMP listener to receive Time Exceeded and Destination Unreachable messages
+	network, udpNetwork := "ip4:icmp", "udp4"
+	if v6 {
+		network, udpNetwork = "ip6:ipv6-icmp", "udp6"
+	}
+	icmpConn, err := icmp.ListenPacket(network, srcAddr)
+	if err != nil {
+		return hop, fmt.Errorf("failed to create ICMP listener: %w", err)
+	}
+	defer icmpConn.Close()
+
+	// The unconnected socket receives the ICMP errors only through the raw socket
+	udpConn, err := net.ListenPacket(udpNetwork, net.JoinHostPort(srcAddr, "0"))
+	if err != nil {
+		return hop, fmt.Errorf("failed to create UDP socket: %w", err)
+	}
+	defer udpConn.Close()
+	// Closing the sockets unblocks the pending reads
+	defer context.AfterFunc(ctx, func() {
+		icmpConn.Close()
+		udpConn.Close()
+	})()
+
+	if err = icmpConn.SetDeadline(deadline); err != nil {
+		return hop, err
+	}
+	if err = udpConn.SetDeadline(deadline); err != nil {
+		return hop, err
+	}
+
+	// Set TTL (hop limit for IPv6) and TOS (traffic class for IPv6)
+	if v6 {
+		p := ipv6.NewPacketConn(udpConn)
+		if err = p.SetHopLimit(ttl); err != nil {
+			return hop, err
+		}
+		if tos > 0 {
+			if tosErr := p.SetTrafficClass(tos); tosErr != nil {
+				common.TOSFailures.Fail("MTR", tos, tosErr)
+			}
+		}
+	} else {
+		p := ipv4.NewPacketConn(udpConn)
+		if err = p.SetTTL(ttl); err != nil {
+			return hop, err
+		}
+		if tos > 0 {
+			if tosErr := p.SetTOS(tos); tosErr != nil {
+				common.TOSFailures.Fail("MTR", tos, tosErr)
+			}
+		}
+	}
+	srcPort := udpConn.LocalAddr().(*net.UDPAddr).Port
+
+	if _, err = udpConn.WriteTo(make([]byte, payloadSize), &net.UDPAddr{IP: dstIp, Port: dstPort}); err != nil {
+		return hop, err
+	}
+
+	// Read the ICMP errors of the datagram sent to the destination, the socket receives the ones of all the probes
+	icmpChan := make(chan net.Addr, 1)
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, peer, readErr := icmpConn.ReadFrom(b)
+			if readErr != nil {
+				return
+			}
+			if icmpError(b[:n], v6, dstIp, srcPort, dstPort) {
+				icmpChan <- peer
+				return
+			}
+		}
+	}()
+
+	// Read the answer of the destination when the port is open
+	replyChan := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			_, peer, readErr := udpConn.ReadFrom(b)
+			if readErr != nil {
+				replyChan <- readErr
+				return
+			}
+			if addr, ok := peer.(*net.UDPAddr); ok && addr.IP.Equal(dstIp) && addr.Port == dstPort {
+				replyChan <- nil
+				return
+			}
+		}
+	}()
+
+	// Wait for the ICMP error or for the answer of the destination
+	select {
+	case <-ctx.Done():
+		return hop, ctx.Err()
+	case replyErr := <-replyChan:
+		if replyErr == nil {
+			hop.Elapsed = time.Since(start)
+			hop.Addr = dstIp.String()
+			hop.Success = true
+			return hop, nil
+		}
+		return hop, replyErr
+	case peer := <-icmpChan:
+		hop.Elapsed = time.Since(start)
+		hop.Addr = peer.String()
+		hop.Success = true
+		return hop, nil
+	}
+}
+
+// icmpError reports if an ICMP message is the Time Exceeded or the Destination Unreachable of a UDP datagram sent from the source port to the destination address and port
+func icmpError(b []byte, v6 bool, dstIp net.IP, srcPort int, dstPort int) bool {
+	proto := protocolICMP
+	if v6 {
+		proto = protocolIPv6ICMP
+	}
+	x, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return false
+	}
+
+	var data []byte
+	switch body := x.Body.(type) {
+	case *icmp.TimeExceeded:
+		data = body.Data
+	case *icmp.DstUnreach:
+		data = body.Data
+	default:
+		return false
+	}
+
+	dst, sport, dport, ok := common.QuotedPacket(data, v6, protocolUDP)
+	return ok && dst.Equal(dstIp) && sport == srcPort && dport == dstPort
+}
@@ -0,0 +1,17 @@
+package udp
+
+import "time"
+
+// UDPPortReturn Calculated results
+type UDPPortReturn struct {
+	Success  bool   `json:"success"`
+	DestAddr string `json:"dest_address"`
+	DestIp   string `json:"dest_ip"`
+	DestPort string `json:"dest_port"`
+	SrcIp    string `json:"src_ip"`
+	// Outcome of the probe: reply, port_unreachable, timeout, error or source_interface
+	Outcome string `json:"outcome"`
+	// ReplyBytes size of the reply
+	ReplyBytes int           `json:"reply_bytes,omitempty"`
+	RTT        time.Duration `json:"rtt,omitempty"`
+}
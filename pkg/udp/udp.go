@@ -0,0 +1,76 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// Port UDP Operation, sends the payload to the port and waits for a reply or the ICMP port unreachable of a closed port until the timeout
+// Without expectReply the timeout counts as a success (open or filtered port), the probe is aborted when the context is canceled
+func Port(ctx context.Context, destAddr string, ip string, srcAddr string, device string, port string, payload []byte, expectReply bool, timeout time.Duration) (*UDPPortReturn, error) {
+	out := UDPPortReturn{DestAddr: destAddr, DestIp: ip, DestPort: port, SrcIp: "0.0.0.0", Outcome: "error"}
+
+	d := net.Dialer{}
+	if srcAddr != "" {
+		srcIp := net.ParseIP(srcAddr)
+		if srcIp == nil {
+			return &out, fmt.Errorf("source ip: %v is invalid, UDP target: %v", srcAddr, destAddr)
+		}
+		d.LocalAddr = &net.UDPAddr{IP: srcIp}
+	}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if device != "" {
+			if err := common.BindToDevice(c, device); err != nil {
+				common.SourceInterfaceFailures.BindFailed("UDP", device, err)
+			}
+		}
+		return nil
+	}
+
+	// The socket is connected so the ICMP errors of the destination are reported to it
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return &out, err
+	}
+	defer conn.Close()
+	// Closing the connection aborts the probe when the target is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if a, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		out.SrcIp = a.IP.String()
+	}
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return &out, fmt.Errorf("error setting deadline timeout: %v", err)
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return &out, err
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	var netErr net.Error
+	switch {
+	case err == nil:
+		out.RTT = time.Since(start)
+		out.ReplyBytes = n
+		out.Outcome = "reply"
+		out.Success = true
+	case errors.Is(err, syscall.ECONNREFUSED):
+		out.RTT = time.Since(start)
+		out.Outcome = "port_unreachable"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		out.Outcome = "timeout"
+		out.Success = !expectReply
+	default:
+		return &out, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,143 @@
+package results
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuffer results buffered per subscription before they are dropped
+const DefaultBuffer = 256
+
+// Result of a probe cycle
+type Result struct {
+	Type    string
+	Name    string
+	Host    string
+	IP      string
+	Labels  map[string]string
+	Time    time.Time
+	Success bool
+	// *ping.PingResult, *mtr.MtrResult, *tcp.TCPPortReturn, *http.HTTPReturn, *dns.DNSReturn, *udp.UDPPortReturn, *ntp.NTPReturn or *smtp.SMTPReturn
+	Data interface{}
+}
+
+// Hub fans out the probe results to the subscribers and keeps the last result of each target
+// Publishing never blocks, the results are dropped for the subscribers that do not keep up
+type Hub struct {
+	subs    map[*Subscription]struct{}
+	last    map[string]Result
+	dropped atomic.Uint64
+	mtx     sync.RWMutex
+}
+
+// Subscription receives the published results
+type Subscription struct {
+	C       <-chan Result
+	ch      chan Result
+	hub     *Hub
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// NewHub creates a results hub
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[*Subscription]struct{}),
+		last: make(map[string]Result),
+	}
+}
+
+// Publish records the result of a target, key is the unique monitor key of the target ("name" or "name ip")
+func (h *Hub) Publish(key string, r Result) {
+	if h == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.last[r.Type+"/"+key] = r
+	for s := range h.subs {
+		select {
+		case s.ch <- r:
+		default:
+			s.dropped.Add(1)
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// Forget removes the last result of a stopped target
+func (h *Hub) Forget(checkType string, key string) {
+	if h == nil {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	delete(h.last, checkType+"/"+key)
+}
+
+// Last returns the last results of a target (one per resolved IP)
+func (h *Hub) Last(checkType string, name string) []Result {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	rs := []Result{}
+	for _, r := range h.last {
+		if r.Name == name && (checkType == "" || r.Type == checkType) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// All returns the last results of all the targets
+func (h *Hub) All() []Result {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	rs := make([]Result, 0, len(h.last))
+	for _, r := range h.last {
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// Subscribe registers a new subscriber with a bounded buffer
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan Result, buffer)
+	s := &Subscription{C: ch, ch: ch, hub: h}
+
+	h.mtx.Lock()
+	h.subs[s] = struct{}{}
+	h.mtx.Unlock()
+	return s
+}
+
+// Stats returns the number of subscribers and the total of dropped results
+func (h *Hub) Stats() (subscribers int, dropped uint64) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.subs), h.dropped.Load()
+}
+
+// Dropped returns the results dropped on this subscription
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unregisters the subscription
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mtx.Lock()
+		delete(s.hub.subs, s)
+		s.hub.mtx.Unlock()
+	})
+}
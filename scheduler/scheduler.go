@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"container/heap"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs the probes of many targets with a fixed pool of workers
+// The next due probes are kept in a min-heap drained by a single dispatcher, replacing the ticker goroutine of every target
+type Scheduler struct {
+	logger  *slog.Logger
+	name    string
+	workers int
+	queue   jobQueue
+	mtx     sync.Mutex
+	wake    chan struct{}
+	jobs    chan *Job
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	skipped atomic.Uint64
+}
+
+// Job periodic probe of a target
+type Job struct {
+	next time.Time
+	// base nominal run the jitter is applied to, the ticks keep the interval on average
+	base     time.Time
+	interval time.Duration
+	jitter   time.Duration
+	probe    func()
+	index    int
+	running  bool
+	removed  bool
+}
+
+// New starts a scheduler with the given number of workers
+func New(logger *slog.Logger, name string, workers int) *Scheduler {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	s := &Scheduler{
+		logger:  logger,
+		name:    name,
+		workers: workers,
+		wake:    make(chan struct{}, 1),
+		jobs:    make(chan *Job),
+		stop:    make(chan struct{}),
+	}
+
+	s.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	go s.dispatch()
+	return s
+}
+
+// Add schedules a probe after the startup delay and then on every interval, shifted by a random offset within ± the jitter
+func (s *Scheduler) Add(startupDelay time.Duration, interval time.Duration, jitter time.Duration, probe func()) *Job {
+	start := time.Now().Add(startupDelay)
+	j := &Job{next: start, base: start, interval: interval, jitter: jitter, probe: probe}
+
+	s.mtx.Lock()
+	heap.Push(&s.queue, j)
+	s.mtx.Unlock()
+	s.notify()
+	return j
+}
+
+// Remove unschedules a job, a probe already running is not interrupted
+func (s *Scheduler) Remove(j *Job) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if j.index >= 0 {
+		heap.Remove(&s.queue, j.index)
+	}
+	j.removed = true
+}
+
+// Reschedule changes the interval and jitter of a job, its next probe is due after the new interval
+func (s *Scheduler) Reschedule(j *Job, interval time.Duration, jitter time.Duration) {
+	s.mtx.Lock()
+	if j.removed {
+		s.mtx.Unlock()
+		return
+	}
+	j.interval, j.jitter = interval, jitter
+	j.base = time.Now().Add(interval)
+	j.next = j.base.Add(offset(jitter))
+	if j.index >= 0 {
+		heap.Fix(&s.queue, j.index)
+	}
+	s.mtx.Unlock()
+	s.notify()
+}
+
+// Stop halts the dispatcher and waits for the running probes
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Stats returns the scheduled jobs and the probes skipped because the previous one was still running
+func (s *Scheduler) Stats() (jobs int, skipped uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.queue), s.skipped.Load()
+}
+
+// notify wakes the dispatcher up to look at the head of the queue again
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands the due jobs to the workers, blocking while all of them are busy
+func (s *Scheduler) dispatch() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		due, wait := s.due(time.Now())
+		if due != nil {
+			select {
+			case s.jobs <- due:
+			case <-s.stop:
+				return
+			}
+			continue
+		}
+
+		if wait < 0 {
+			wait = time.Hour
+		}
+		timer.Reset(wait)
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// due returns the next job to run, or the time until the head of the queue is due (-1 when empty)
+func (s *Scheduler) due(now time.Time) (*Job, time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for len(s.queue) > 0 {
+		j := s.queue[0]
+		if wait := j.next.Sub(now); wait > 0 {
+			return nil, wait
+		}
+
+		// Fixed rate like a ticker, the missed periods are dropped instead of bursting
+		for !j.next.After(now) {
+			j.base = j.base.Add(j.interval)
+			j.next = j.base.Add(offset(j.jitter))
+		}
+		heap.Fix(&s.queue, 0)
+
+		if j.running {
+			s.skipped.Add(1)
+			s.logger.Debug("Previous probe still running, skipping", "type", s.name, "func", "due", "next", j.next)
+			continue
+		}
+		j.running = true
+		return j, 0
+	}
+	return nil, -1
+}
+
+// work runs the dispatched probes
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case j := <-s.jobs:
+			s.mtx.Lock()
+			removed := j.removed
+			s.mtx.Unlock()
+
+			if !removed {
+				j.probe()
+			}
+
+			s.mtx.Lock()
+			j.running = false
+			s.mtx.Unlock()
+		}
+	}
+}
+
+// jobQueue min-heap of the jobs ordered by their next run
+type jobQueue []*Job
+
+func (q jobQueue) Len() int           { return len(q) }
+func (q jobQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	j := x.(*Job)
+	j.index = len(*q)
+	*q = append(*q, j)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	j.index = -1
+	*q = old[:n-1]
+	return j
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestSchedulerSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const (
+		jobs     = 2000
+		interval = 50 * time.Millisecond
+		jitter   = 10 * time.Millisecond
+		duration = 2 * time.Second
+	)
+	s := New(testLogger, "soak", 8)
+	defer s.Stop()
+
+	runs := make([]atomic.Int64, jobs)
+	start := time.Now()
+	for i := range runs {
+		s.Add(0, interval, jitter, func() { runs[i].Add(1) })
+	}
+	time.Sleep(duration)
+	elapsed := time.Since(start)
+
+	// Fixed rate: every job runs once per interval, none is starved and none drifts ahead with bursts
+	want := int64(elapsed / interval)
+	for i := range runs {
+		if n := runs[i].Load(); n < want*8/10 || n > want+2 {
+			t.Fatalf("job %d ran %d times in %s, want about %d", i, n, elapsed, want)
+		}
+	}
+	if n, skipped := s.Stats(); n != jobs || skipped != 0 {
+		t.Errorf("stats %d jobs %d skipped, want %d jobs 0 skipped", n, skipped, jobs)
+	}
+}
+
+func TestSchedulerRemove(t *testing.T) {
+	s := New(testLogger, "remove", 2)
+	defer s.Stop()
+
+	var kept, removed atomic.Int64
+	s.Add(0, 10*time.Millisecond, 0, func() { kept.Add(1) })
+	j := s.Add(0, 10*time.Millisecond, 0, func() { removed.Add(1) })
+	time.Sleep(50 * time.Millisecond)
+	s.Remove(j)
+	// A probe dispatched before the removal may still be running
+	time.Sleep(20 * time.Millisecond)
+	after := removed.Load()
+	time.Sleep(100 * time.Millisecond)
+
+	if n := removed.Load(); n != after {
+		t.Errorf("removed job ran %d times after its removal", n-after)
+	}
+	if kept.Load() < 10 {
+		t.Errorf("kept job ran %d times, want at least 10", kept.Load())
+	}
+	if n, _ := s.Stats(); n != 1 {
+		t.Errorf("%d jobs scheduled, want 1", n)
+	}
+	// Rescheduling a removed job does not queue it again
+	s.Reschedule(j, time.Millisecond, 0)
+	if n, _ := s.Stats(); n != 1 {
+		t.Errorf("%d jobs scheduled after rescheduling the removed job, want 1", n)
+	}
+}
+
+func TestSchedulerSkipsRunning(t *testing.T) {
+	s := New(testLogger, "skip", 4)
+	defer s.Stop()
+
+	var running, overlaps, runs atomic.Int64
+	s.Add(0, 10*time.Millisecond, 0, func() {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		runs.Add(1)
+		time.Sleep(35 * time.Millisecond)
+		running.Add(-1)
+	})
+	time.Sleep(200 * time.Millisecond)
+
+	if overlaps.Load() != 0 {
+		t.Errorf("%d probes of the same job overlapped", overlaps.Load())
+	}
+	if _, skipped := s.Stats(); skipped == 0 || runs.Load() == 0 {
+		t.Errorf("%d runs %d skipped, want the ticks during a probe skipped", runs.Load(), skipped)
+	}
+}
+
+func TestSchedulerReschedule(t *testing.T) {
+	s := New(testLogger, "reschedule", 1)
+	defer s.Stop()
+
+	var runs atomic.Int64
+	j := s.Add(time.Hour, time.Hour, 0, func() { runs.Add(1) })
+	s.Reschedule(j, 10*time.Millisecond, 0)
+	time.Sleep(105 * time.Millisecond)
+
+	if n := runs.Load(); n < 5 || n > 11 {
+		t.Errorf("rescheduled job ran %d times, want about 10", n)
+	}
+}
+
+// BenchmarkPool dispatches rounds of 10k jobs through the heap and the worker pool, the interval is short enough to keep them all due
+func BenchmarkPool(b *testing.B) {
+	const jobs = 10000
+	s := New(testLogger, "bench", 64)
+	defer s.Stop()
+
+	var runs atomic.Int64
+	for i := 0; i < jobs; i++ {
+		s.Add(0, time.Millisecond, 0, func() { runs.Add(1) })
+	}
+
+	b.ResetTimer()
+	start := runs.Load()
+	for i := 1; i <= b.N; i++ {
+		for runs.Load()-start < int64(i*jobs) {
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(runs.Load()-start)/b.Elapsed().Seconds(), "probes/s")
+}
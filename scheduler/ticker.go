@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Ticker delivers ticks on every interval shifted by a random offset within ± the jitter, a plain time.Ticker without jitter
+// The offsets are applied to the nominal ticks so the average rate stays the interval
+type Ticker struct {
+	C      <-chan time.Time
+	ticker *time.Ticker
+	timer  *time.Timer
+	c      chan time.Time
+	stop   chan struct{}
+}
+
+// NewTicker starts a ticker of the interval and jitter
+func NewTicker(interval time.Duration, jitter time.Duration) *Ticker {
+	if jitter <= 0 {
+		ticker := time.NewTicker(interval)
+		return &Ticker{C: ticker.C, ticker: ticker}
+	}
+
+	t := &Ticker{c: make(chan time.Time, 1), stop: make(chan struct{})}
+	t.C = t.c
+	base := time.Now().Add(interval)
+	t.timer = time.NewTimer(time.Until(base.Add(offset(jitter))))
+	go func() {
+		for {
+			select {
+			case <-t.stop:
+				return
+			case now := <-t.timer.C:
+				// Dropped like a time.Ticker when the receiver is too slow
+				select {
+				case t.c <- now:
+				default:
+				}
+				base = base.Add(interval)
+				for !base.Add(-jitter).After(now) {
+					base = base.Add(interval)
+				}
+				t.timer.Reset(time.Until(base.Add(offset(jitter))))
+			}
+		}
+	}()
+	return t
+}
+
+// Stop turns off the ticker
+func (t *Ticker) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+		return
+	}
+	close(t.stop)
+	t.timer.Stop()
+}
+
+// offset returns a random offset within ± the jitter
+func offset(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return rand.N(2*jitter+1) - jitter
+}
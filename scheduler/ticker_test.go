@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestOffset(t *testing.T) {
+	const jitter = 5 * time.Millisecond
+	var early, late bool
+	for i := 0; i < 10000; i++ {
+		o := offset(jitter)
+		if o < -jitter || o > jitter {
+			t.Fatalf("offset %s out of ±%s", o, jitter)
+		}
+		early = early || o < 0
+		late = late || o > 0
+	}
+	if !early || !late {
+		t.Errorf("offsets early %v late %v, want both", early, late)
+	}
+	for _, jitter := range []time.Duration{0, -time.Second} {
+		if o := offset(jitter); o != 0 {
+			t.Errorf("offset(%s) = %s, want 0", jitter, o)
+		}
+	}
+}
+
+func TestTickerJitter(t *testing.T) {
+	const (
+		interval = 20 * time.Millisecond
+		jitter   = 5 * time.Millisecond
+		ticks    = 25
+		// Delivery delay of the timers
+		late = 10 * time.Millisecond
+	)
+	start := time.Now()
+	tick := NewTicker(interval, jitter)
+	defer tick.Stop()
+
+	for i := 1; i <= ticks; i++ {
+		now := <-tick.C
+		// Within ± the jitter of the nominal tick, which does not drift with the offsets
+		nominal := start.Add(time.Duration(i) * interval)
+		if now.Before(nominal.Add(-jitter)) || now.After(nominal.Add(jitter+late)) {
+			t.Fatalf("tick %d at %s, want %s ± %s", i, now.Sub(start), nominal.Sub(start), jitter)
+		}
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	tick := NewTicker(time.Millisecond, time.Millisecond)
+	<-tick.C
+	tick.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Stop, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Without jitter a plain time.Ticker
+	plain := NewTicker(time.Millisecond, 0)
+	defer plain.Stop()
+	if plain.ticker == nil {
+		t.Errorf("ticker without jitter not a time.Ticker")
+	}
+}
@@ -21,29 +21,21 @@ func reloadSignal() {
 		for {
 			select {
 			case <-hup:
-				logger.Debug("msg", "Signal: HUP")
-				logger.Info("msg", "ReLoading config")
-				if err := sc.ReloadConfig(logger, *configFile, *configFileHeaders); err != nil {
-					logger.Error("msg", "Reloading config skipped", "err", err)
-					continue
+				logger.Debug("Signal received", "type", "Main", "func", "reloadSignal", "signal", "HUP")
+				logger.Info("Reloading config", "type", "Main", "func", "reloadSignal")
+				if err := applyConfigFile(); err != nil {
+					logger.Error("Reloading config skipped", "type", "Main", "func", "reloadSignal", "err", err)
 				}
-				monitorPING.DelTargets()
-				_ = monitorPING.CheckActiveTargets()
-				monitorPING.AddTargets()
-				monitorMTR.DelTargets()
-				_ = monitorMTR.CheckActiveTargets()
-				monitorMTR.AddTargets()
-				monitorTCP.DelTargets()
-				_ = monitorTCP.CheckActiveTargets()
-				monitorTCP.AddTargets()
-				monitorHTTPGet.DelTargets()
-				monitorHTTPGet.AddTargets()
 			case <-susr:
-				logger.Debug("msg", "Signal: USR1")
+				logger.Debug("Signal received", "type", "Main", "func", "reloadSignal", "signal", "USR1")
 				fmt.Printf("PING: %+v\n", monitorPING)
 				fmt.Printf("MTR: %+v\n", monitorMTR)
 				fmt.Printf("TCP: %+v\n", monitorTCP)
 				fmt.Printf("HTTPGet: %+v\n", monitorHTTPGet)
+				fmt.Printf("DNS: %+v\n", monitorDNS)
+				fmt.Printf("UDP: %+v\n", monitorUDP)
+				fmt.Printf("NTP: %+v\n", monitorNTP)
+				fmt.Printf("SMTP: %+v\n", monitorSMTP)
 			}
 		}
 	}()
@@ -18,23 +18,10 @@ func reloadSignal() {
 		for {
 			select {
 			case <-hup:
-				logger.Debug("msg", "Signal: HUP")
-				logger.Info("msg", "ReLoading config")
-				if err := sc.ReloadConfig(logger, *configFile, *configFileHeaders); err != nil {
-					logger.Error("msg", "Reloading config skipped", "err", err)
-					continue
-				} else {
-					monitorPING.DelTargets()
-					_ = monitorPING.CheckActiveTargets()
-					monitorPING.AddTargets()
-					monitorMTR.DelTargets()
-					_ = monitorMTR.CheckActiveTargets()
-					monitorMTR.AddTargets()
-					monitorTCP.DelTargets()
-					_ = monitorTCP.CheckActiveTargets()
-					monitorTCP.AddTargets()
-					monitorHTTPGet.DelTargets()
-					monitorHTTPGet.AddTargets()
+				logger.Debug("Signal received", "type", "Main", "func", "reloadSignal", "signal", "HUP")
+				logger.Info("Reloading config", "type", "Main", "func", "reloadSignal")
+				if err := applyConfigFile(); err != nil {
+					logger.Error("Reloading config skipped", "type", "Main", "func", "reloadSignal", "err", err)
 				}
 			}
 		}
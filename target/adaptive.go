@@ -0,0 +1,141 @@
+package target
+
+import (
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/scheduler"
+)
+
+// Adaptive faster probing of a failing target, disabled without a degraded interval shorter than the interval of the target
+type Adaptive struct {
+	// DegradedInterval interval of the probes while the target is degraded
+	DegradedInterval time.Duration
+	// TriggerLoss loss of a round (0-1) above which the target is degraded, a failed probe is a loss of 1
+	TriggerLoss float64
+	// RecoveryRounds rounds in a row not above the trigger loss before the target is back to its interval
+	RecoveryRounds int
+}
+
+// DegradedStat state of the adaptive probing of a target
+type DegradedStat struct {
+	// Type and Name of the monitored target (the name is suffixed with the resolved IP for ICMP and TCP)
+	Type     string
+	Name     string
+	Degraded bool
+}
+
+// adaptive switches the probes of a target between its interval and the degraded interval
+// The ticker of the run loop is reset with the intervals sent on retimed, the job of the scheduler is rescheduled
+type adaptive struct {
+	Adaptive
+	interval time.Duration
+	jitter   time.Duration
+	sched    *scheduler.Scheduler
+	job      *scheduler.Job
+	retimed  chan time.Duration
+	mtx      sync.Mutex
+	degraded bool
+	clean    int
+}
+
+// newAdaptive creates the adaptive probing of a target probed on the interval with the jitter
+func newAdaptive(a Adaptive, interval time.Duration, jitter time.Duration) *adaptive {
+	return &adaptive{Adaptive: a, interval: interval, jitter: jitter, retimed: make(chan time.Duration, 1)}
+}
+
+// enabled reports whether the degraded interval is shorter than the interval of the target
+func (a *adaptive) enabled() bool {
+	return a != nil && a.DegradedInterval > 0 && a.DegradedInterval < a.interval
+}
+
+// schedule sets the job of the target on the scheduler, the job is rescheduled when a first probe already degraded the target
+func (a *adaptive) schedule(sched *scheduler.Scheduler, job *scheduler.Job) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.sched, a.job = sched, job
+	if a.degraded {
+		a.retime(a.DegradedInterval)
+	}
+}
+
+// isDegraded reports whether the target is probed on the degraded interval
+func (a *adaptive) isDegraded() bool {
+	if a == nil {
+		return false
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.degraded
+}
+
+// observe records the loss of a round, the target is degraded by a round above the trigger loss and recovers after the recovery rounds
+func (a *adaptive) observe(loss float64) {
+	if !a.enabled() {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if loss > a.TriggerLoss {
+		a.clean = 0
+		if !a.degraded {
+			a.degraded = true
+			a.retime(a.DegradedInterval)
+		}
+		return
+	}
+	if !a.degraded {
+		return
+	}
+	a.clean++
+	if a.clean >= max(a.RecoveryRounds, 1) {
+		a.degraded, a.clean = false, 0
+		a.retime(a.interval)
+	}
+}
+
+// retime switches the probes to the interval, the jitter is scaled with it
+func (a *adaptive) retime(interval time.Duration) {
+	jitter := a.jitterOf(interval)
+	if a.job != nil {
+		a.sched.Reschedule(a.job, interval, jitter)
+		return
+	}
+	// Only the last interval matters to the run loop
+	select {
+	case <-a.retimed:
+	default:
+	}
+	a.retimed <- interval
+}
+
+// jitterOf returns the jitter of the probes on an interval, in proportion to the jitter of the interval of the target
+func (a *adaptive) jitterOf(interval time.Duration) time.Duration {
+	if a.interval <= 0 {
+		return 0
+	}
+	return time.Duration(float64(a.jitter) * float64(interval) / float64(a.interval))
+}
+
+// failed returns the loss of a probe succeeding or failing
+func failed(success bool) float64 {
+	if success {
+		return 0
+	}
+	return 1
+}
+
+// DegradedStats returns the state of the adaptive probing of the running targets using it
+func DegradedStats() []DegradedStat {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+
+	stats := make([]DegradedStat, 0, len(guards))
+	for g := range guards {
+		if g.adaptive.enabled() {
+			stats = append(stats, DegradedStat{Type: g.checkType, Name: g.name, Degraded: g.adaptive.isDegraded()})
+		}
+	}
+	return stats
+}
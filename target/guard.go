@@ -0,0 +1,158 @@
+package target
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// Panics of a target within panicWindow before it is quarantined
+	maxPanics   = 5
+	panicWindow = time.Hour
+	// Probes skipped after a panic, doubled on every panic of the window
+	panicBackoff = 10 * time.Second
+)
+
+// PanicStat panics recovered from the probes of a target
+type PanicStat struct {
+	// Type and Name of the monitored target (the name is suffixed with the resolved IP for ICMP and TCP)
+	Type string
+	Name string
+	// Target name of the configured target
+	Target      string
+	Panics      uint64
+	Quarantined bool
+}
+
+// guards of the running targets
+var (
+	guards    = make(map[*guard]struct{})
+	guardsMtx sync.Mutex
+)
+
+// guard isolates the panics of a target, the probes are skipped during a backoff after a panic and not run anymore once quarantined
+type guard struct {
+	logger      *slog.Logger
+	checkType   string
+	name        string
+	target      string
+	mtx         sync.Mutex
+	panics      uint64
+	recent      []time.Time
+	resume      time.Time
+	quarantined bool
+	// skipped probes because of the probe limit
+	skipped uint64
+	// adaptive probing of the target, nil without
+	adaptive *adaptive
+}
+
+// newGuard creates and registers the guard of a target and its adaptive probing
+func newGuard(logger *slog.Logger, checkType string, name string, target string, adaptive *adaptive) *guard {
+	g := &guard{logger: logger, checkType: checkType, name: name, target: target, adaptive: adaptive}
+
+	guardsMtx.Lock()
+	guards[g] = struct{}{}
+	guardsMtx.Unlock()
+	return g
+}
+
+// PanicStats returns the recovered panics of the running targets
+func PanicStats() []PanicStat {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+
+	stats := make([]PanicStat, 0, len(guards))
+	for g := range guards {
+		g.mtx.Lock()
+		stats = append(stats, PanicStat{Type: g.checkType, Name: g.name, Target: g.target, Panics: g.panics, Quarantined: g.quarantined})
+		g.mtx.Unlock()
+	}
+	return stats
+}
+
+// forget unregisters the guard of a stopped target
+func (g *guard) forget() {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+	delete(guards, g)
+}
+
+// probe runs a probe cycle unless the target is backing off or quarantined
+// The cycle is skipped instead of queued when the probes of all the targets use the probe limit
+func (g *guard) probe(probe func()) {
+	g.mtx.Lock()
+	skip := g.quarantined || time.Now().Before(g.resume)
+	g.mtx.Unlock()
+	if skip {
+		return
+	}
+
+	if !acquireProbe() {
+		g.mtx.Lock()
+		g.skipped++
+		g.mtx.Unlock()
+		g.logger.Debug("Probe limit reached, skipping", "type", g.checkType, "func", "probe", "name", g.name)
+		return
+	}
+	defer releaseProbe()
+	g.protect("probe", probe)
+}
+
+// loop runs the loop of a target and restarts it after a backoff when it panicked, until it returns or the target is stopped
+func (g *guard) loop(stop <-chan struct{}, run func()) {
+	for g.protect("loop", run) {
+		g.mtx.Lock()
+		quarantined, wait := g.quarantined, time.Until(g.resume)
+		g.mtx.Unlock()
+		if quarantined {
+			return
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// protect runs f and reports if it panicked
+func (g *guard) protect(origin string, f func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.recovered(origin, r)
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+// recovered records a panic, the target is quarantined after maxPanics within panicWindow
+func (g *guard) recovered(origin string, r interface{}) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	now := time.Now()
+	recent := g.recent[:0]
+	for _, t := range g.recent {
+		if now.Sub(t) < panicWindow {
+			recent = append(recent, t)
+		}
+	}
+	g.recent = append(recent, now)
+	g.panics++
+
+	backoff := panicBackoff << (len(g.recent) - 1)
+	g.resume = now.Add(backoff)
+	g.logger.Error("Probe panicked", "type", g.checkType, "func", "recovered", "name", g.name, "origin", origin, "panic", fmt.Sprint(r), "retry", backoff, "stack", string(debug.Stack()))
+
+	if len(g.recent) >= maxPanics && !g.quarantined {
+		g.quarantined = true
+		g.logger.Error("Target quarantined", "type", g.checkType, "func", "recovered", "name", g.name, "panics", len(g.recent), "window", panicWindow)
+	}
+}
@@ -0,0 +1,92 @@
+package target
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// panicStat returns the panic stats of the guard
+func panicStat(t *testing.T, g *guard) PanicStat {
+	t.Helper()
+	for _, s := range PanicStats() {
+		if s.Type == g.checkType && s.Name == g.name {
+			return s
+		}
+	}
+	t.Fatalf("guard %s not in PanicStats", g.name)
+	return PanicStat{}
+}
+
+func TestGuardQuarantine(t *testing.T) {
+	bad := newGuard(testLogger, "TCP", "bad 127.0.0.1", "bad", nil)
+	defer bad.forget()
+	good := newGuard(testLogger, "TCP", "good 127.0.0.1", "good", nil)
+	defer good.forget()
+
+	const rounds = 2 * maxPanics
+	var badRuns, goodRuns int
+	for i := 0; i < rounds; i++ {
+		bad.probe(func() {
+			badRuns++
+			panic("prober failure")
+		})
+		good.probe(func() { goodRuns++ })
+
+		// A probe within the backoff of the last panic is skipped
+		bad.probe(func() { badRuns++ })
+		// End of the backoff
+		bad.mtx.Lock()
+		bad.resume = time.Time{}
+		bad.mtx.Unlock()
+	}
+
+	if badRuns != maxPanics {
+		t.Errorf("panicking target probed %d times, want %d before its quarantine", badRuns, maxPanics)
+	}
+	if goodRuns != rounds {
+		t.Errorf("other target probed %d times, want %d", goodRuns, rounds)
+	}
+	if s := panicStat(t, bad); s.Panics != maxPanics || !s.Quarantined || s.Target != "bad" {
+		t.Errorf("panicking target stats %+v, want %d panics and quarantined", s, maxPanics)
+	}
+	if s := panicStat(t, good); s.Panics != 0 || s.Quarantined {
+		t.Errorf("other target stats %+v, want no panics", s)
+	}
+}
+
+func TestGuardBackoff(t *testing.T) {
+	g := newGuard(testLogger, "ICMP", "backoff 127.0.0.1", "backoff", nil)
+	defer g.forget()
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		if !g.protect("probe", func() { panic("prober failure") }) {
+			t.Fatal("panic not reported")
+		}
+		g.mtx.Lock()
+		backoff := g.resume.Sub(start)
+		g.resume = time.Time{}
+		g.mtx.Unlock()
+		// Doubled on every panic of the window
+		if want := panicBackoff << i; backoff < want || backoff > want+time.Second {
+			t.Errorf("panic %d: backoff %s, want %s", i+1, backoff, want)
+		}
+	}
+	if s := panicStat(t, g); s.Panics != 3 || s.Quarantined {
+		t.Errorf("stats %+v, want 3 panics and not quarantined", s)
+	}
+}
+
+func TestGuardForget(t *testing.T) {
+	g := newGuard(testLogger, "DNS", "forget 127.0.0.1", "forget", nil)
+	g.forget()
+	for _, s := range PanicStats() {
+		if s.Name == g.name {
+			t.Errorf("stopped target in PanicStats: %+v", s)
+		}
+	}
+}
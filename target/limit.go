@@ -0,0 +1,53 @@
+package target
+
+import (
+	"sync/atomic"
+)
+
+// Probes running across all the targets and the limit of conf.max_concurrent_probes (0 unlimited)
+var (
+	probesRunning atomic.Int64
+	probeLimit    atomic.Int64
+)
+
+// SkipStat probes of a target skipped because conf.max_concurrent_probes probes were already running
+type SkipStat struct {
+	// Type and Name of the monitored target (the name is suffixed with the resolved IP for ICMP and TCP)
+	Type    string
+	Name    string
+	Skipped uint64
+}
+
+// SetProbeLimit sets the probes allowed to run at once across all the targets, 0 is unlimited
+func SetProbeLimit(limit int) {
+	probeLimit.Store(int64(limit))
+}
+
+// acquireProbe takes a slot of the probe limit, false when all of them are taken
+func acquireProbe() bool {
+	limit := probeLimit.Load()
+	if n := probesRunning.Add(1); limit > 0 && n > limit {
+		probesRunning.Add(-1)
+		return false
+	}
+	return true
+}
+
+// releaseProbe frees the slot of a finished probe
+func releaseProbe() {
+	probesRunning.Add(-1)
+}
+
+// SkipStats returns the probes skipped by the running targets because of the probe limit
+func SkipStats() []SkipStat {
+	guardsMtx.Lock()
+	defer guardsMtx.Unlock()
+
+	stats := make([]SkipStat, 0, len(guards))
+	for g := range guards {
+		g.mtx.Lock()
+		stats = append(stats, SkipStat{Type: g.checkType, Name: g.name, Skipped: g.skipped})
+		g.mtx.Unlock()
+	}
+	return stats
+}
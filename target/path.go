@@ -0,0 +1,91 @@
+package target
+
+import (
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// lostHop address of the hops without reply in the paths
+const lostHop = "*"
+
+// pathTracker counts the changes of the ordered hop addresses of the MTR rounds
+// The hops without reply match any address and the lost tail of a path is ignored, so the packet loss is not counted as a change
+// A path completing the hops without reply or the tail of the current one replaces it (new id) without counting a change
+type pathTracker struct {
+	// rounds a new path has to be seen in a row before it is counted
+	rounds  int
+	current []string
+	id      uint32
+	changes uint64
+	pending []string
+	seen    int
+}
+
+// update compares the hops of a round with the current path, the path of the first round is not counted as a change
+func (p *pathTracker) update(hops []common.IcmpHop) {
+	path := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		if hop.Success {
+			path = append(path, hop.AddressTo)
+		} else {
+			path = append(path, lostHop)
+		}
+	}
+	for len(path) > 0 && path[len(path)-1] == lostHop {
+		path = path[:len(path)-1]
+	}
+	// The rounds without any reply say nothing about the path
+	if len(path) == 0 {
+		return
+	}
+	if p.current == nil {
+		p.set(path)
+		return
+	}
+	if merged, ok := merge(p.current, path); ok {
+		if !slices.Equal(merged, p.current) {
+			p.set(merged)
+		}
+		p.pending, p.seen = nil, 0
+		return
+	}
+
+	// The rounds of the new path are counted while they agree
+	if pending, ok := merge(p.pending, path); ok {
+		p.pending = pending
+	} else {
+		p.pending, p.seen = path, 0
+	}
+	p.seen++
+	if p.seen >= max(p.rounds, 1) {
+		p.set(p.pending)
+		p.changes++
+	}
+}
+
+// merge returns a path completed with the hops of another one, false when an address of both differs
+func merge(current []string, path []string) ([]string, bool) {
+	merged := slices.Clone(current)
+	for i, addr := range path {
+		switch {
+		case i >= len(merged):
+			merged = append(merged, addr)
+		case merged[i] == lostHop:
+			merged[i] = addr
+		case addr != lostHop && addr != merged[i]:
+			return nil, false
+		}
+	}
+	return merged, true
+}
+
+// set makes a path the current one
+func (p *pathTracker) set(path []string) {
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(path, ",")))
+	p.current, p.id = path, h.Sum32()
+	p.pending, p.seen = nil, 0
+}
@@ -0,0 +1,18 @@
+package target
+
+import (
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// sourceAddr returns the source address of a probe, the current address of the source interface of the IP version (0 any) when set
+func sourceAddr(probe string, srcAddr string, srcInterface string, version int) (string, error) {
+	if srcInterface == "" {
+		return srcAddr, nil
+	}
+	addr, err := common.InterfaceAddr(srcInterface, version)
+	if err != nil {
+		common.SourceInterfaceFailures.Fail(probe, srcInterface, err)
+		return "", err
+	}
+	return addr, nil
+}
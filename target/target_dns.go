@@ -0,0 +1,255 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/dns"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+)
+
+// DNS Object
+type DNS struct {
+	logger            *slog.Logger
+	name              string
+	host              string
+	ip                string
+	srcAddr           string
+	srcInterface      string
+	port              string
+	question          dns.Question
+	interval          time.Duration
+	jitter            time.Duration
+	timeout           time.Duration
+	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
+	labels            map[string]string
+	result            *dns.DNSReturn
+	ctx               context.Context
+	cancel            context.CancelFunc
+	stop              chan struct{}
+	wg                sync.WaitGroup
+	sync.RWMutex
+}
+
+// NewDNS starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewDNS(logger *slog.Logger, startupDelay time.Duration, name string, host string, ip string, srcAddr string, srcInterface string, port string, question dns.Question, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, labels map[string]string, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*DNS, error) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &DNS{
+		logger:            logger,
+		name:              name,
+		host:              host,
+		ip:                ip,
+		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
+		port:              port,
+		question:          question,
+		interval:          interval,
+		jitter:            jitter,
+		timeout:           timeout,
+		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
+		stop:              make(chan struct{}),
+	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "DNS", name, strings.TrimSuffix(name, " "+ip), t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.queryCheck) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
+	return t, nil
+}
+
+func (t *DNS) run(startupDelay time.Duration) {
+	if startupDelay > 0 {
+		select {
+		case <-time.After(startupDelay):
+		case <-t.stop:
+			return
+		}
+	}
+
+	waitChan := make(chan struct{}, t.maxConcurrentJobs)
+
+	// Execute first probe immediately (after jitter delay)
+	// This ensures targets start probing as quickly as possible
+	select {
+	case <-t.stop:
+		return
+	default:
+		waitChan <- struct{}{}
+		go func() {
+			t.guard.probe(t.queryCheck)
+			<-waitChan
+		}()
+	}
+
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
+		case <-tick.C:
+			waitChan <- struct{}{}
+			go func() {
+				t.guard.probe(t.queryCheck)
+				<-waitChan
+			}()
+		}
+	}
+}
+
+// Stop gracefully stops the monitoring
+func (t *DNS) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
+	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
+	t.wg.Wait()
+	t.guard.forget()
+	t.hub.Forget("DNS", t.name)
+}
+
+func (t *DNS) queryCheck() {
+	var data *dns.DNSReturn
+	srcAddr, err := sourceAddr("DNS", t.srcAddr, t.srcInterface, common.IPVersion(t.ip))
+	if err != nil {
+		data = &dns.DNSReturn{DestAddr: t.host, DestIp: t.ip, DestPort: t.port, SrcIp: "0.0.0.0", QueryName: t.question.Name, QueryType: t.question.Type, Transport: t.question.Transport, Rcode: -1, ErrorReason: "source_interface"}
+	} else {
+		data, err = dns.Query(t.ctx, t.host, t.ip, srcAddr, t.srcInterface, t.port, t.question, t.timeout)
+	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		t.logger.Error("DNS query failed", "type", "DNS", "func", "query", "err", err)
+	}
+
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(data)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "DNS", "func", "query", "err", err2)
+		}
+		t.logger.Debug("DNS query result", "type", "DNS", "func", "query", "result", string(bytes))
+	}
+
+	t.Lock()
+	t.result = data
+	t.Unlock()
+
+	select {
+	case <-t.stop:
+	default:
+		// The monitor key is suffixed with the resolved IP
+		r := results.Result{Type: "DNS", Name: strings.TrimSuffix(t.name, " "+t.ip), Host: t.host, IP: t.ip, Labels: t.labels, Success: data.Success, Data: data}
+		t.hub.Publish(t.name, r)
+		t.adaptive.observe(failed(data.Success))
+	}
+}
+
+// Compute returns the results of the DNS metrics
+func (t *DNS) Compute() *dns.DNSReturn {
+	t.RLock()
+	defer t.RUnlock()
+
+	if t.result == nil {
+		return nil
+	}
+	return t.result
+}
+
+// Name returns name
+func (t *DNS) Name() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.name
+}
+
+// Host returns host
+func (t *DNS) Host() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.host
+}
+
+// Ip returns ip
+func (t *DNS) Ip() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.ip
+}
+
+// Port returns port
+func (t *DNS) Port() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.port
+}
+
+// Question returns question
+func (t *DNS) Question() dns.Question {
+	t.RLock()
+	defer t.RUnlock()
+	return t.question
+}
+
+// Interval returns interval
+func (t *DNS) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *DNS) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *DNS) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
+// Labels returns labels
+func (t *DNS) Labels() map[string]string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.labels
+}
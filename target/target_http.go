@@ -1,13 +1,21 @@
 package target
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"log/slog"
+	nethttp "net/http"
+	neturl "net/url"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/http"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 )
 
 // HTTPGet Object
@@ -16,45 +24,130 @@ type HTTPGet struct {
 	name              string
 	url               string
 	srcAddr           string
+	srcInterface      string
+	srcVersion        int
 	proxy             string
+	proxyTLSID        string
+	proxyFromEnv      bool
+	authorization     string
+	headers           map[string]string
+	method            string
+	requestBody       string
+	contentType       string
+	tlsID             string
+	request           *http.Request
+	validStatusCodes  []int
+	body              *http.BodyMatch
 	interval          time.Duration
+	jitter            time.Duration
 	timeout           time.Duration
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
 	labels            map[string]string
 	result            *http.HTTPReturn
+	ctx               context.Context
+	cancel            context.CancelFunc
 	stop              chan struct{}
 	wg                sync.WaitGroup
 	sync.RWMutex
 }
 
-// NewHTTPGet starts a new monitoring goroutine
-func NewHTTPGet(logger *slog.Logger, startupDelay time.Duration, name string, url string, srcAddr string, proxy string, interval time.Duration, timeout time.Duration, labels map[string]string, maxConcurrentJobs int) (*HTTPGet, error) {
+// NewHTTPGet starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewHTTPGet(logger *slog.Logger, startupDelay time.Duration, name string, url string, srcAddr string, srcInterface string, proxy string, proxyTLS *tls.Config, proxyTLSID string, proxyFromEnv bool, authorization string, headers map[string]string, method string, requestBody string, contentType string, tlsConfig *tls.Config, tlsID string, reuse bool, http3 bool, followRedirects bool, maxRedirects int, validStatusCodes []int, body *http.BodyMatch, readLimit int64, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, labels map[string]string, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*HTTPGet, error) {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var keepAlive *http.KeepAlive
+	if reuse {
+		keepAlive = &http.KeepAlive{}
+	}
 	t := &HTTPGet{
 		logger:            logger,
 		name:              name,
 		url:               url,
 		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
+		srcVersion:        urlIPVersion(url),
 		proxy:             proxy,
+		proxyTLSID:        proxyTLSID,
+		proxyFromEnv:      proxyFromEnv,
+		authorization:     authorization,
+		headers:           headers,
+		method:            method,
+		requestBody:       requestBody,
+		contentType:       contentType,
+		tlsID:             tlsID,
+		request:           &http.Request{Method: method, Header: requestHeader(authorization, headers, contentType), Body: requestBody, TLS: tlsConfig, TLSID: tlsID, ProxyTLS: proxyTLS, ProxyTLSID: proxyTLSID, ProxyFromEnvironment: proxyFromEnv, Device: srcInterface, KeepAlive: keepAlive, FollowRedirects: followRedirects, MaxRedirects: maxRedirects, ReadLimit: readLimit, HTTP3: http3},
+		validStatusCodes:  validStatusCodes,
+		body:              body,
 		interval:          interval,
+		jitter:            jitter,
 		timeout:           timeout,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
 		stop:              make(chan struct{}),
 	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "HTTPGet", name, name, t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.httpGetCheck) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
 	t.wg.Add(1)
-	go t.run(startupDelay)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
 	return t, nil
 }
 
+// urlIPVersion returns the IP version of the host of an URL holding an IP address, 0 for a name
+func urlIPVersion(u string) int {
+	parsed, err := neturl.Parse(u)
+	if err != nil {
+		return 0
+	}
+	return common.IPVersion(parsed.Hostname())
+}
+
+// requestHeader returns the headers of the requests, the content_type and the Authorization built from the credentials file take precedence over the ones of the headers
+func requestHeader(authorization string, headers map[string]string, contentType string) nethttp.Header {
+	header := nethttp.Header{}
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if authorization != "" {
+		header.Set("Authorization", authorization)
+	}
+	return header
+}
+
+// validStatus reports if the status is one of the codes, any 2xx without codes
+func validStatus(status int, codes []int) bool {
+	if len(codes) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(codes, status)
+}
+
 func (t *HTTPGet) run(startupDelay time.Duration) {
 	if startupDelay > 0 {
 		select {
 		case <-time.After(startupDelay):
 		case <-t.stop:
-			t.wg.Done()
 			return
 		}
 	}
@@ -65,28 +158,29 @@ func (t *HTTPGet) run(startupDelay time.Duration) {
 	// This ensures targets start probing as quickly as possible
 	select {
 	case <-t.stop:
-		t.wg.Done()
 		return
 	default:
 		waitChan <- struct{}{}
 		go func() {
-			t.httpGetCheck()
+			t.guard.probe(t.httpGetCheck)
 			<-waitChan
 		}()
 	}
 
-	tick := time.NewTicker(t.interval)
-	defer tick.Stop()
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
 
 	for {
 		select {
 		case <-t.stop:
-			t.wg.Done()
 			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
 		case <-tick.C:
 			waitChan <- struct{}{}
 			go func() {
-				t.httpGetCheck()
+				t.guard.probe(t.httpGetCheck)
 				<-waitChan
 			}()
 		}
@@ -95,8 +189,18 @@ func (t *HTTPGet) run(startupDelay time.Duration) {
 
 // Stop gracefully stops the monitoring
 func (t *HTTPGet) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
 	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
 	t.wg.Wait()
+	if t.request.KeepAlive != nil {
+		t.request.KeepAlive.Close()
+	}
+	t.guard.forget()
+	t.hub.Forget("HTTPGet", t.name)
 }
 
 func (t *HTTPGet) httpGetCheck() {
@@ -104,27 +208,77 @@ func (t *HTTPGet) httpGetCheck() {
 	var err error
 
 	if t.proxy != "" {
-		data, err = http.HTTPGetProxy(t.url, t.timeout, t.proxy)
-		if err != nil {
+		data, err = http.HTTPGetProxy(t.ctx, t.url, t.timeout, t.proxy, t.request, t.body)
+		if err != nil && t.ctx.Err() == nil {
 			t.logger.Error("HTTP Get with proxy failed", "type", "HTTPGet", "func", "httpGetCheck", "err", err)
 		}
 
 	} else {
-		data, err = http.HTTPGet(t.url, t.srcAddr, t.timeout)
-		if err != nil {
+		if t.proxyFromEnv && t.logger.Enabled(context.Background(), slog.LevelDebug) {
+			t.logProxy()
+		}
+		var srcAddr string
+		if srcAddr, err = sourceAddr("HTTPGet", t.srcAddr, t.srcInterface, t.srcVersion); err != nil {
+			data = &http.HTTPReturn{DestAddr: t.url, HTTPVersion: t.request.Version(), ErrorReason: "source_interface"}
+		} else {
+			data, err = http.HTTPGet(t.ctx, t.url, srcAddr, t.timeout, t.request, t.body)
+		}
+		if err != nil && t.ctx.Err() == nil {
 			t.logger.Error("HTTP Get failed", "type", "HTTPGet", "func", "httpGetCheck", "err", err)
 		}
 	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
+	data.ValidStatus = data.Success && validStatus(data.Status, t.validStatusCodes)
+	switch {
+	// The proxy rejected the credentials of an http request
+	case data.Success && !data.ValidStatus && t.proxy != "" && data.Status == nethttp.StatusProxyAuthRequired:
+		data.ErrorReason = "proxy_auth"
+	case data.Success && !data.ValidStatus:
+		data.ErrorReason = "status"
+	case data.Success && !data.BodyMatch:
+		data.ErrorReason = "body"
+		t.logger.Debug("HTTP Get body does not match", "type", "HTTPGet", "func", "httpGetCheck", "name", t.name, "bytes", data.BodyMatchedBytes)
+	case data.Success && !data.ContentMatch:
+		data.ErrorReason = "content"
+		t.logger.Debug("HTTP Get body digest does not match", "type", "HTTPGet", "func", "httpGetCheck", "name", t.name, "expected", t.body.SHA256, "digest", data.ContentSHA256, "bytes", data.BodyBytes)
+	}
 
-	bytes, err2 := json.Marshal(data)
-	if err2 != nil {
-		t.logger.Error("Failed to marshal result", "type", "HTTPGet", "func", "httpGetCheck", "err", err2)
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(data)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "HTTPGet", "func", "httpGetCheck", "err", err2)
+		}
+		t.logger.Debug("HTTP Get result", "type", "HTTPGet", "func", "httpGetCheck", "result", string(bytes))
 	}
-	t.logger.Debug("HTTP Get result", "type", "HTTPGet", "func", "httpGetCheck", "result", string(bytes))
 
 	t.Lock()
-	defer t.Unlock()
 	t.result = data
+	t.Unlock()
+
+	select {
+	case <-t.stop:
+	default:
+		r := results.Result{Type: "HTTPGet", Name: t.name, Host: t.url, Labels: t.labels, Success: data.Succeeded(), Data: data}
+		t.hub.Publish(t.name, r)
+		t.adaptive.observe(failed(data.Succeeded()))
+	}
+}
+
+// logProxy logs the proxy of the environment used by the probe, or direct when NO_PROXY or the scheme bypasses it
+func (t *HTTPGet) logProxy() {
+	proxy, err := http.EnvironmentProxy(t.url)
+	switch {
+	case err != nil:
+		t.logger.Debug("HTTP Get proxy from environment invalid", "type", "HTTPGet", "func", "httpGetCheck", "name", t.name, "err", err)
+	case proxy == nil:
+		t.logger.Debug("HTTP Get proxy from environment", "type", "HTTPGet", "func", "httpGetCheck", "name", t.name, "proxy", "direct")
+	default:
+		t.logger.Debug("HTTP Get proxy from environment", "type", "HTTPGet", "func", "httpGetCheck", "name", t.name, "proxy", proxy.Redacted())
+	}
 }
 
 // Compute returns the results of the HTTP metrics
@@ -152,6 +306,131 @@ func (t *HTTPGet) URL() string {
 	return t.url
 }
 
+// Proxy returns the proxy URL
+func (t *HTTPGet) Proxy() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.proxy
+}
+
+// ProxyTLSID returns the fingerprint of the TLS config of the https proxy
+func (t *HTTPGet) ProxyTLSID() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.proxyTLSID
+}
+
+// ProxyFromEnvironment reports if the requests without proxy use the proxy of the environment
+func (t *HTTPGet) ProxyFromEnvironment() bool {
+	t.RLock()
+	defer t.RUnlock()
+	return t.proxyFromEnv
+}
+
+// Authorization returns the Authorization header
+func (t *HTTPGet) Authorization() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.authorization
+}
+
+// Method returns the method of the requests
+func (t *HTTPGet) Method() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.method
+}
+
+// RequestBody returns the body and content type of the requests
+func (t *HTTPGet) RequestBody() (body string, contentType string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.requestBody, t.contentType
+}
+
+// ReuseConnection reports if the probes keep the connection open between them
+func (t *HTTPGet) ReuseConnection() bool {
+	return t.request.KeepAlive != nil
+}
+
+// HTTP3 reports if the requests are sent over QUIC
+func (t *HTTPGet) HTTP3() bool {
+	return t.request.HTTP3
+}
+
+// Redirects returns if the probes follow the redirects and the maximum followed
+func (t *HTTPGet) Redirects() (bool, int) {
+	return t.request.FollowRedirects, t.request.MaxRedirects
+}
+
+// TLSID returns the fingerprint of the TLS config of the requests, empty without tls_config
+func (t *HTTPGet) TLSID() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.tlsID
+}
+
+// Headers returns the headers of the requests
+func (t *HTTPGet) Headers() map[string]string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.headers
+}
+
+// ValidStatusCodes returns the status codes of a successful probe
+func (t *HTTPGet) ValidStatusCodes() []int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.validStatusCodes
+}
+
+// BodyPatterns returns the fail_if_body_matches and fail_if_body_not_matches regexes
+func (t *HTTPGet) BodyPatterns() (matches []string, notMatches []string) {
+	t.RLock()
+	defer t.RUnlock()
+	if t.body == nil {
+		return nil, nil
+	}
+	for _, re := range t.body.FailIfMatches {
+		matches = append(matches, re.String())
+	}
+	for _, re := range t.body.FailIfNotMatches {
+		notMatches = append(notMatches, re.String())
+	}
+	return matches, notMatches
+}
+
+// BodyDigest returns the SHA-256 digest expected of the body, empty when it is not checked
+func (t *HTTPGet) BodyDigest() string {
+	t.RLock()
+	defer t.RUnlock()
+	if t.body == nil {
+		return ""
+	}
+	return t.body.SHA256
+}
+
+// Interval returns interval
+func (t *HTTPGet) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *HTTPGet) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *HTTPGet) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
 // Labels returns labels
 func (t *HTTPGet) Labels() map[string]string {
 	t.RLock()
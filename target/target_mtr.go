@@ -1,15 +1,19 @@
 package target
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/mtr"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 )
 
 // MTR Object
@@ -19,48 +23,82 @@ type MTR struct {
 	name              string
 	host              string
 	srcAddr           string
+	srcInterface      string
 	interval          time.Duration
+	jitter            time.Duration
 	timeout           time.Duration
 	maxHops           int
 	count             int
+	packetInterval    time.Duration
+	parallelHops      int
 	payloadSize       int
+	tos               int
 	protocol          string
 	port              string
 	ipv6              bool
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
+	path              pathTracker
 	labels            map[string]string
 	result            *mtr.MtrResult
+	ctx               context.Context
+	cancel            context.CancelFunc
 	stop              chan struct{}
 	wg                sync.WaitGroup
 	sync.RWMutex
 }
 
-// NewMTR starts a new monitoring goroutine
-func NewMTR(logger *slog.Logger, icmpID *common.IcmpID, startupDelay time.Duration, name string, host string, srcAddr string, interval time.Duration, timeout time.Duration, maxHops int, count int, payloadSize int, protocol string, port string, labels map[string]string, ipv6 bool, maxConcurrentJobs int) (*MTR, error) {
+// NewMTR starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewMTR(logger *slog.Logger, icmpID *common.IcmpID, startupDelay time.Duration, name string, host string, srcAddr string, srcInterface string, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, maxHops int, count int, packetInterval time.Duration, parallelHops int, payloadSize int, tos int, protocol string, port string, pathChangeRounds int, labels map[string]string, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*MTR, error) {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	t := &MTR{
 		logger:            logger,
 		icmpID:            icmpID,
 		name:              name,
 		host:              host,
 		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
 		interval:          interval,
+		jitter:            jitter,
 		timeout:           timeout,
 		maxHops:           maxHops,
 		count:             count,
+		packetInterval:    packetInterval,
+		parallelHops:      parallelHops,
 		payloadSize:       payloadSize,
+		tos:               tos,
 		protocol:          protocol,
 		port:              port,
 		ipv6:              ipv6,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		path:              pathTracker{rounds: pathChangeRounds},
 		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
 		stop:              make(chan struct{}),
 		result:            &mtr.MtrResult{HopSummaryMap: map[string]*common.IcmpSummary{}},
 	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "MTR", name, strings.TrimSuffix(name, " "+host), t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.mtr) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
 	t.wg.Add(1)
-	go t.run(startupDelay)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
 	return t, nil
 }
 
@@ -69,7 +107,6 @@ func (t *MTR) run(startupDelay time.Duration) {
 		select {
 		case <-time.After(startupDelay):
 		case <-t.stop:
-			t.wg.Done()
 			return
 		}
 	}
@@ -80,28 +117,29 @@ func (t *MTR) run(startupDelay time.Duration) {
 	// This ensures targets start probing as quickly as possible
 	select {
 	case <-t.stop:
-		t.wg.Done()
 		return
 	default:
 		waitChan <- struct{}{}
 		go func() {
-			t.mtr()
+			t.guard.probe(t.mtr)
 			<-waitChan
 		}()
 	}
 
-	tick := time.NewTicker(t.interval)
-	defer tick.Stop()
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
 
 	for {
 		select {
 		case <-t.stop:
-			t.wg.Done()
 			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
 		case <-tick.C:
 			waitChan <- struct{}{}
 			go func() {
-				t.mtr()
+				t.guard.probe(t.mtr)
 				<-waitChan
 			}()
 		}
@@ -110,13 +148,30 @@ func (t *MTR) run(startupDelay time.Duration) {
 
 // Stop gracefully stops the monitoring
 func (t *MTR) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
 	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
 	t.wg.Wait()
+	t.guard.forget()
+	t.hub.Forget("MTR", t.name)
 }
 
 func (t *MTR) mtr() {
 	icmpID := int(t.icmpID.Get())
-	data, err := mtr.Mtr(t.host, t.srcAddr, t.maxHops, t.count, t.timeout, icmpID, t.payloadSize, t.protocol, t.port, t.ipv6)
+	var data *mtr.MtrResult
+	srcAddr, err := sourceAddr("MTR", t.srcAddr, t.srcInterface, common.IPVersion(t.host))
+	if err != nil {
+		data = &mtr.MtrResult{DestAddr: t.host, Protocol: t.protocol, Hops: []common.IcmpHop{}, PacketInterval: t.packetInterval}
+	} else {
+		data, err = mtr.Mtr(t.ctx, t.host, srcAddr, t.maxHops, t.count, t.packetInterval, t.parallelHops, t.timeout, icmpID, t.payloadSize, t.tos, t.protocol, t.port, t.ipv6)
+	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		t.logger.Error("MTR failed", "type", "MTR", "func", "mtr", "err", err)
 	}
@@ -138,12 +193,33 @@ func (t *MTR) mtr() {
 		summary.SntFail += hop.SntFail
 	}
 	t.result.HopSummaryMap = summaryMap
+	t.path.update(data.Hops)
+	t.result.PathID = t.path.id
+	t.result.PathChanges = t.path.changes
 
-	bytes, err2 := json.Marshal(t.result)
-	if err2 != nil {
-		t.logger.Error("Failed to marshal result", "type", "MTR", "func", "mtr", "err", err2)
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(t.result)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "MTR", "func", "mtr", "err", err2)
+		}
+		t.logger.Debug("MTR result", "type", "MTR", "func", "mtr", "result", string(bytes))
+	}
+
+	select {
+	case <-t.stop:
+	default:
+		// The hop summaries are updated in place, only the hops of this cycle are published
+		r := &mtr.MtrResult{DestAddr: data.DestAddr, Protocol: data.Protocol, Hops: data.Hops, PathID: data.PathID, PathChanges: data.PathChanges}
+		success := len(data.Hops) > 0 && data.Hops[len(data.Hops)-1].Success
+		t.hub.Publish(t.name, results.Result{Type: "MTR", Name: strings.TrimSuffix(t.name, " "+t.host), Host: t.host, IP: data.DestAddr, Labels: t.labels, Success: success, Data: r})
+		// The loss of the round is the one of the destination
+		loss := 1.0
+		if success {
+			loss = data.Hops[len(data.Hops)-1].Loss
+		}
+		t.adaptive.observe(loss)
 	}
-	t.logger.Debug("MTR result", "type", "MTR", "func", "mtr", "result", string(bytes))
 }
 
 // Compute returns the results of the MTR metrics
@@ -171,6 +247,69 @@ func (t *MTR) Host() string {
 	return t.host
 }
 
+// Protocol returns protocol
+func (t *MTR) Protocol() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.protocol
+}
+
+// Port returns port
+func (t *MTR) Port() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.port
+}
+
+// Tos returns tos
+func (t *MTR) Tos() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.tos
+}
+
+// Interval returns interval
+func (t *MTR) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *MTR) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// MaxHops returns maxHops
+func (t *MTR) MaxHops() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.maxHops
+}
+
+// PayloadSize returns payload size
+func (t *MTR) PayloadSize() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.payloadSize
+}
+
+// Count returns count
+func (t *MTR) Count() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.count
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *MTR) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
 // Labels returns labels
 func (t *MTR) Labels() map[string]string {
 	t.RLock()
@@ -1,14 +1,18 @@
 package target
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/ping"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 )
 
 const MaxConcurrentJobs = 3 // DEPRECATED: Use maxConcurrentJobs parameter instead
@@ -21,24 +25,40 @@ type PING struct {
 	host              string
 	ip                string
 	srcAddr           string
+	srcInterface      string
 	interval          time.Duration
+	jitter            time.Duration
 	timeout           time.Duration
 	count             int
+	packetInterval    time.Duration
 	payloadSize       int
+	tos               int
+	hopLimit          int
+	hopLimitLogged    bool
+	mode              string
+	fallback          string
 	ipv6              bool
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
 	labels            map[string]string
 	result            *ping.PingResult
+	ctx               context.Context
+	cancel            context.CancelFunc
 	stop              chan struct{}
 	wg                sync.WaitGroup
 	sync.RWMutex
 }
 
-// NewPing starts a new monitoring goroutine
-func NewPing(logger *slog.Logger, icmpID *common.IcmpID, startupDelay time.Duration, name string, host string, ip string, srcAddr string, interval time.Duration, timeout time.Duration, count int, payloadSize int, labels map[string]string, ipv6 bool, maxConcurrentJobs int) (*PING, error) {
+// NewPing starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewPing(logger *slog.Logger, icmpID *common.IcmpID, startupDelay time.Duration, name string, host string, ip string, srcAddr string, srcInterface string, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, count int, packetInterval time.Duration, payloadSize int, tos int, hopLimit int, mode string, labels map[string]string, ipv6 bool, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*PING, error) {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	t := &PING{
 		logger:            logger,
 		icmpID:            icmpID,
@@ -46,18 +66,38 @@ func NewPing(logger *slog.Logger, icmpID *common.IcmpID, startupDelay time.Durat
 		host:              host,
 		ip:                ip,
 		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
 		interval:          interval,
+		jitter:            jitter,
 		timeout:           timeout,
 		count:             count,
+		packetInterval:    packetInterval,
 		payloadSize:       payloadSize,
+		tos:               tos,
+		hopLimit:          hopLimit,
+		mode:              mode,
 		ipv6:              ipv6,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
 		stop:              make(chan struct{}),
 		result:            &ping.PingResult{},
 	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "ICMP", name, strings.TrimSuffix(name, " "+ip), t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.ping) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
 	t.wg.Add(1)
-	go t.run(startupDelay)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
 	return t, nil
 }
 
@@ -66,7 +106,6 @@ func (t *PING) run(startupDelay time.Duration) {
 		select {
 		case <-time.After(startupDelay):
 		case <-t.stop:
-			t.wg.Done()
 			return
 		}
 	}
@@ -77,28 +116,29 @@ func (t *PING) run(startupDelay time.Duration) {
 	// This ensures targets start probing as quickly as possible
 	select {
 	case <-t.stop:
-		t.wg.Done()
 		return
 	default:
 		waitChan <- struct{}{}
 		go func() {
-			t.ping()
+			t.guard.probe(t.ping)
 			<-waitChan
 		}()
 	}
 
-	tick := time.NewTicker(t.interval)
-	defer tick.Stop()
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
 
 	for {
 		select {
 		case <-t.stop:
-			t.wg.Done()
 			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
 		case <-tick.C:
 			waitChan <- struct{}{}
 			go func() {
-				t.ping()
+				t.guard.probe(t.ping)
 				<-waitChan
 			}()
 		}
@@ -107,29 +147,76 @@ func (t *PING) run(startupDelay time.Duration) {
 
 // Stop gracefully stops the monitoring
 func (t *PING) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
 	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
 	t.wg.Wait()
+	t.guard.forget()
+	t.hub.Forget("ICMP", t.name)
 }
 
 func (t *PING) ping() {
 	icmpID := int(t.icmpID.Get())
-	data, err := ping.Ping(t.host, t.ip, t.srcAddr, t.count, t.timeout, icmpID, t.payloadSize, t.ipv6)
+	var data *ping.PingResult
+	srcAddr, err := sourceAddr("ICMP", t.srcAddr, t.srcInterface, common.IPVersion(t.ip))
+	if err != nil {
+		// All the echo requests of the round are lost
+		data = &ping.PingResult{DestAddr: t.host, DestIp: t.ip, DropRate: 1, SntSummary: t.count, SntFailSummary: t.count, PayloadSize: t.payloadSize, PacketInterval: t.packetInterval}
+	} else {
+		data, err = ping.Ping(t.ctx, t.host, t.ip, srcAddr, t.count, t.packetInterval, t.timeout, icmpID, t.payloadSize, t.tos, t.hopLimit, t.mode, t.ipv6)
+	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		t.logger.Error("Ping failed", "type", "ICMP", "func", "ping", "err", err)
 	}
 
 	t.Lock()
 	defer t.Unlock()
+	if data.HopLimitFailed && !t.hopLimitLogged {
+		t.hopLimitLogged = true
+		t.logger.Warn("Setting the hop limit failed, the echo requests are sent with the one of the system", "type", "ICMP", "func", "ping", "name", t.name, "hop_limit", t.hopLimit)
+	}
+	// Only the changes of the fallback reason of the timestamp rounds are logged
+	if data.TimestampFallback != t.fallback {
+		t.fallback = data.TimestampFallback
+		if t.fallback != "" {
+			t.logger.Warn("Timestamp round without one-way delays", "type", "ICMP", "func", "ping", "name", t.name, "reason", t.fallback)
+		}
+	}
 	data.SntSummary += t.result.SntSummary
 	data.SntFailSummary += t.result.SntFailSummary
 	data.SntTimeSummary += t.result.SntTimeSummary
+	data.DuplicatesSummary += t.result.DuplicatesSummary
+	data.LateRepliesSummary += t.result.LateRepliesSummary
 	t.result = data
 
-	bytes, err2 := json.Marshal(t.result)
-	if err2 != nil {
-		t.logger.Error("Failed to marshal result", "type", "ICMP", "func", "ping", "err", err2)
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(t.result)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "ICMP", "func", "ping", "err", err2)
+		}
+		t.logger.Debug("Ping result", "type", "ICMP", "func", "ping", "result", string(bytes))
+	}
+
+	select {
+	case <-t.stop:
+	default:
+		// The monitor key is suffixed with the resolved IP
+		r := *t.result
+		t.hub.Publish(t.name, results.Result{Type: "ICMP", Name: strings.TrimSuffix(t.name, " "+t.ip), Host: t.host, IP: t.ip, Labels: t.labels, Success: r.Success, Data: &r})
+		loss := r.DropRate
+		if !r.Success {
+			loss = 1
+		}
+		t.adaptive.observe(loss)
 	}
-	t.logger.Debug("Ping result", "type", "ICMP", "func", "ping", "result", string(bytes))
 }
 
 // Compute returns the results of the Ping metrics
@@ -164,6 +251,62 @@ func (t *PING) Ip() string {
 	return t.ip
 }
 
+// Tos returns tos
+func (t *PING) Tos() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.tos
+}
+
+// HopLimit returns the hop limit of the IPv6 echo requests, 0 the default one
+func (t *PING) HopLimit() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.hopLimit
+}
+
+// Mode returns the mode of the probe rounds, echo or timestamp
+func (t *PING) Mode() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.mode
+}
+
+// Interval returns interval
+func (t *PING) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *PING) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// PayloadSize returns payload size
+func (t *PING) PayloadSize() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.payloadSize
+}
+
+// Count returns count
+func (t *PING) Count() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.count
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *PING) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
 // Labels returns labels
 func (t *PING) Labels() map[string]string {
 	t.RLock()
@@ -1,13 +1,21 @@
 package target
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
 )
 
 // TCPPort Object
@@ -17,37 +25,83 @@ type TCPPort struct {
 	host              string
 	ip                string
 	srcAddr           string
+	srcInterface      string
 	port              string
+	tos               int
+	proxy             string
+	proxyURL          *url.URL
+	tlsConfig         *tls.Config
+	tlsID             string
+	banner            *tcp.Banner
 	interval          time.Duration
+	jitter            time.Duration
 	timeout           time.Duration
 	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
 	labels            map[string]string
 	result            *tcp.TCPPortReturn
+	ctx               context.Context
+	cancel            context.CancelFunc
 	stop              chan struct{}
 	wg                sync.WaitGroup
 	sync.RWMutex
 }
 
-// NewTCPPort starts a new monitoring goroutine
-func NewTCPPort(logger *slog.Logger, startupDelay time.Duration, name string, host string, ip string, srcAddr string, port string, interval time.Duration, timeout time.Duration, labels map[string]string, maxConcurrentJobs int) (*TCPPort, error) {
+// NewTCPPort starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewTCPPort(logger *slog.Logger, startupDelay time.Duration, name string, host string, ip string, srcAddr string, srcInterface string, port string, tos int, proxy string, tlsConfig *tls.Config, tlsID string, banner *tcp.Banner, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, labels map[string]string, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*TCPPort, error) {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
+	var proxyURL *url.URL
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL")
+		}
+		proxyURL = u
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	t := &TCPPort{
 		logger:            logger,
 		name:              name,
 		host:              host,
 		ip:                ip,
 		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
 		port:              port,
+		tos:               tos,
+		proxy:             proxy,
+		proxyURL:          proxyURL,
+		tlsConfig:         tlsConfig,
+		tlsID:             tlsID,
+		banner:            banner,
 		interval:          interval,
+		jitter:            jitter,
 		timeout:           timeout,
 		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
 		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
 		stop:              make(chan struct{}),
 	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "TCP", name, strings.TrimSuffix(name, " "+ip), t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.portCheck) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
 	t.wg.Add(1)
-	go t.run(startupDelay)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
 	return t, nil
 }
 
@@ -56,7 +110,6 @@ func (t *TCPPort) run(startupDelay time.Duration) {
 		select {
 		case <-time.After(startupDelay):
 		case <-t.stop:
-			t.wg.Done()
 			return
 		}
 	}
@@ -67,28 +120,29 @@ func (t *TCPPort) run(startupDelay time.Duration) {
 	// This ensures targets start probing as quickly as possible
 	select {
 	case <-t.stop:
-		t.wg.Done()
 		return
 	default:
 		waitChan <- struct{}{}
 		go func() {
-			t.portCheck()
+			t.guard.probe(t.portCheck)
 			<-waitChan
 		}()
 	}
 
-	tick := time.NewTicker(t.interval)
-	defer tick.Stop()
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
 
 	for {
 		select {
 		case <-t.stop:
-			t.wg.Done()
 			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
 		case <-tick.C:
 			waitChan <- struct{}{}
 			go func() {
-				t.portCheck()
+				t.guard.probe(t.portCheck)
 				<-waitChan
 			}()
 		}
@@ -97,25 +151,57 @@ func (t *TCPPort) run(startupDelay time.Duration) {
 
 // Stop gracefully stops the monitoring
 func (t *TCPPort) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
 	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
 	t.wg.Wait()
+	t.guard.forget()
+	t.hub.Forget("TCP", t.name)
 }
 
 func (t *TCPPort) portCheck() {
-	data, err := tcp.Port(t.host, t.ip, t.srcAddr, t.port, t.timeout)
+	var data *tcp.TCPPortReturn
+	srcAddr, err := sourceAddr("TCP", t.srcAddr, t.srcInterface, common.IPVersion(t.ip))
+	if err != nil {
+		data = &tcp.TCPPortReturn{DestAddr: t.host, DestIp: t.ip, DestPort: t.port, SrcIp: "0.0.0.0", ErrorReason: "source_interface"}
+	} else {
+		data, err = tcp.Port(t.ctx, t.host, t.ip, srcAddr, t.srcInterface, t.port, t.timeout, t.tos, t.proxyURL, t.tlsConfig, t.banner)
+	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		t.logger.Error("TCP Port check failed", "type", "TCP", "func", "port", "err", err)
 	}
+	if data.Success && data.Banner && !data.BannerMatch {
+		t.logger.Debug("TCP banner does not match", "type", "TCP", "func", "port", "name", t.name, "expect", t.banner.Expect.String())
+	}
 
-	bytes, err2 := json.Marshal(data)
-	if err2 != nil {
-		t.logger.Error("Failed to marshal result", "type", "TCP", "func", "port", "err", err2)
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(data)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "TCP", "func", "port", "err", err2)
+		}
+		t.logger.Debug("TCP Port result", "type", "TCP", "func", "port", "result", string(bytes))
 	}
-	t.logger.Debug("TCP Port result", "type", "TCP", "func", "port", "result", string(bytes))
 
 	t.Lock()
-	defer t.Unlock()
 	t.result = data
+	t.Unlock()
+
+	select {
+	case <-t.stop:
+	default:
+		// The monitor key is suffixed with the resolved IP
+		r := results.Result{Type: "TCP", Name: strings.TrimSuffix(t.name, " "+t.ip), Host: t.host, IP: t.ip, Labels: t.labels, Success: data.Succeeded(), Data: data}
+		t.hub.Publish(t.name, r)
+		t.adaptive.observe(failed(data.Succeeded()))
+	}
 }
 
 // Compute returns the results of the TCP metrics
@@ -150,6 +236,62 @@ func (t *TCPPort) Ip() string {
 	return t.ip
 }
 
+// Tos returns tos
+func (t *TCPPort) Tos() int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.tos
+}
+
+// Proxy returns the SOCKS5 proxy URL, empty without
+func (t *TCPPort) Proxy() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.proxy
+}
+
+// TLSID returns the fingerprint of the TLS config, empty without tls
+func (t *TCPPort) TLSID() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.tlsID
+}
+
+// Banner returns the banner exchange, nil without
+func (t *TCPPort) Banner() *tcp.Banner {
+	t.RLock()
+	defer t.RUnlock()
+	return t.banner
+}
+
+// Interval returns interval
+func (t *TCPPort) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *TCPPort) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// Port returns port
+func (t *TCPPort) Port() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.port
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *TCPPort) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
 // Labels returns labels
 func (t *TCPPort) Labels() map[string]string {
 	t.RLock()
@@ -0,0 +1,120 @@
+package target
+
+import (
+	"net"
+	"regexp"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/tcp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+)
+
+// holdListener accepts the connections and keeps them open without writing, the banner reads block until canceled
+func holdListener(t *testing.T) (port string, accepted func() int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mtx sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		ln.Close()
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mtx.Lock()
+			conns = append(conns, conn)
+			mtx.Unlock()
+		}
+	}()
+	_, port, _ = net.SplitHostPort(ln.Addr().String())
+	return port, func() int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(conns)
+	}
+}
+
+// waitGoroutines waits for the goroutines to be back to at most want
+func waitGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines left, want %d:\n%s", runtime.NumGoroutine(), want, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTCPPortStopLeaks(t *testing.T) {
+	const targets = 50
+	for _, pool := range []bool{false, true} {
+		name := "goroutines"
+		if pool {
+			name = "scheduler"
+		}
+		t.Run(name, func(t *testing.T) {
+			port, accepted := holdListener(t)
+			// The listener goroutine is running
+			time.Sleep(10 * time.Millisecond)
+			baseline := runtime.NumGoroutine()
+
+			var sched *scheduler.Scheduler
+			if pool {
+				sched = scheduler.New(testLogger, "TCP", 8)
+			}
+			// The probes block on a banner never sent, well past the test
+			banner := &tcp.Banner{Expect: regexp.MustCompile("never"), ReadLimit: 64}
+			hub := results.NewHub()
+			running := make([]*TCPPort, 0, targets)
+			for i := 0; i < targets; i++ {
+				tp, err := NewTCPPort(testLogger, 0, "leak 127.0.0.1", "127.0.0.1", "127.0.0.1", "", "", port, 0, "", nil, "", banner, time.Hour, 0, time.Minute, Adaptive{}, nil, 3, hub, sched)
+				if err != nil {
+					t.Fatal(err)
+				}
+				running = append(running, tp)
+			}
+
+			// Only the pool size of the scheduler probes are in flight
+			inFlight := targets
+			if pool {
+				inFlight = 8
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for accepted() < inFlight {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d probes in flight, want %d", accepted(), inFlight)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			start := time.Now()
+			for _, tp := range running {
+				tp.Stop()
+			}
+			if sched != nil {
+				sched.Stop()
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("stop took %s, the probes in flight were not canceled", elapsed)
+			}
+			waitGoroutines(t, baseline)
+		})
+	}
+}
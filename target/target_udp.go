@@ -0,0 +1,264 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/udp"
+	"github.com/syepes/network_exporter/results"
+	"github.com/syepes/network_exporter/scheduler"
+)
+
+// UDPPort Object
+type UDPPort struct {
+	logger            *slog.Logger
+	name              string
+	host              string
+	ip                string
+	srcAddr           string
+	srcInterface      string
+	port              string
+	payload           []byte
+	expectReply       bool
+	interval          time.Duration
+	jitter            time.Duration
+	timeout           time.Duration
+	maxConcurrentJobs int
+	hub               *results.Hub
+	sched             *scheduler.Scheduler
+	job               *scheduler.Job
+	guard             *guard
+	adaptive          *adaptive
+	labels            map[string]string
+	result            *udp.UDPPortReturn
+	ctx               context.Context
+	cancel            context.CancelFunc
+	stop              chan struct{}
+	wg                sync.WaitGroup
+	sync.RWMutex
+}
+
+// NewUDPPort starts a new monitoring goroutine, or schedules the probes on the shared pool when a scheduler is given
+func NewUDPPort(logger *slog.Logger, startupDelay time.Duration, name string, host string, ip string, srcAddr string, srcInterface string, port string, payload []byte, expectReply bool, interval time.Duration, jitter time.Duration, timeout time.Duration, adaptive Adaptive, labels map[string]string, maxConcurrentJobs int, hub *results.Hub, sched *scheduler.Scheduler) (*UDPPort, error) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &UDPPort{
+		logger:            logger,
+		name:              name,
+		host:              host,
+		ip:                ip,
+		srcAddr:           srcAddr,
+		srcInterface:      srcInterface,
+		port:              port,
+		payload:           payload,
+		expectReply:       expectReply,
+		interval:          interval,
+		jitter:            jitter,
+		timeout:           timeout,
+		maxConcurrentJobs: maxConcurrentJobs,
+		hub:               hub,
+		sched:             sched,
+		labels:            labels,
+		ctx:               ctx,
+		cancel:            cancel,
+		stop:              make(chan struct{}),
+	}
+	t.adaptive = newAdaptive(adaptive, interval, jitter)
+	t.guard = newGuard(logger, "UDP", name, strings.TrimSuffix(name, " "+ip), t.adaptive)
+	if sched != nil {
+		t.job = sched.Add(startupDelay, interval, jitter, func() { t.guard.probe(t.portCheck) })
+		t.adaptive.schedule(sched, t.job)
+		return t, nil
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.guard.loop(t.stop, func() { t.run(startupDelay) })
+	}()
+	return t, nil
+}
+
+func (t *UDPPort) run(startupDelay time.Duration) {
+	if startupDelay > 0 {
+		select {
+		case <-time.After(startupDelay):
+		case <-t.stop:
+			return
+		}
+	}
+
+	waitChan := make(chan struct{}, t.maxConcurrentJobs)
+
+	// Execute first probe immediately (after jitter delay)
+	// This ensures targets start probing as quickly as possible
+	select {
+	case <-t.stop:
+		return
+	default:
+		waitChan <- struct{}{}
+		go func() {
+			t.guard.probe(t.portCheck)
+			<-waitChan
+		}()
+	}
+
+	tick := scheduler.NewTicker(t.interval, t.jitter)
+	defer func() { tick.Stop() }()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case interval := <-t.adaptive.retimed:
+			tick.Stop()
+			tick = scheduler.NewTicker(interval, t.adaptive.jitterOf(interval))
+		case <-tick.C:
+			waitChan <- struct{}{}
+			go func() {
+				t.guard.probe(t.portCheck)
+				<-waitChan
+			}()
+		}
+	}
+}
+
+// Stop gracefully stops the monitoring
+func (t *UDPPort) Stop() {
+	// Aborts the probe in flight
+	t.cancel()
+	close(t.stop)
+	if t.job != nil {
+		t.sched.Remove(t.job)
+	}
+	t.wg.Wait()
+	t.guard.forget()
+	t.hub.Forget("UDP", t.name)
+}
+
+func (t *UDPPort) portCheck() {
+	var data *udp.UDPPortReturn
+	srcAddr, err := sourceAddr("UDP", t.srcAddr, t.srcInterface, common.IPVersion(t.ip))
+	if err != nil {
+		data = &udp.UDPPortReturn{DestAddr: t.host, DestIp: t.ip, DestPort: t.port, SrcIp: "0.0.0.0", Outcome: "source_interface"}
+	} else {
+		data, err = udp.Port(t.ctx, t.host, t.ip, srcAddr, t.srcInterface, t.port, t.payload, t.expectReply, t.timeout)
+	}
+	// The target was stopped during the probe
+	if t.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		t.logger.Error("UDP probe failed", "type", "UDP", "func", "port", "err", err)
+	}
+
+	// The result is only marshaled when the debug messages are logged
+	if t.logger.Enabled(context.Background(), slog.LevelDebug) {
+		bytes, err2 := json.Marshal(data)
+		if err2 != nil {
+			t.logger.Error("Failed to marshal result", "type", "UDP", "func", "port", "err", err2)
+		}
+		t.logger.Debug("UDP probe result", "type", "UDP", "func", "port", "result", string(bytes))
+	}
+
+	t.Lock()
+	t.result = data
+	t.Unlock()
+
+	select {
+	case <-t.stop:
+	default:
+		// The monitor key is suffixed with the resolved IP
+		r := results.Result{Type: "UDP", Name: strings.TrimSuffix(t.name, " "+t.ip), Host: t.host, IP: t.ip, Labels: t.labels, Success: data.Success, Data: data}
+		t.hub.Publish(t.name, r)
+		t.adaptive.observe(failed(data.Success))
+	}
+}
+
+// Compute returns the results of the UDP metrics
+func (t *UDPPort) Compute() *udp.UDPPortReturn {
+	t.RLock()
+	defer t.RUnlock()
+
+	if t.result == nil {
+		return nil
+	}
+	return t.result
+}
+
+// Name returns name
+func (t *UDPPort) Name() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.name
+}
+
+// Host returns host
+func (t *UDPPort) Host() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.host
+}
+
+// Ip returns ip
+func (t *UDPPort) Ip() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.ip
+}
+
+// Port returns port
+func (t *UDPPort) Port() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.port
+}
+
+// Payload returns payload
+func (t *UDPPort) Payload() []byte {
+	t.RLock()
+	defer t.RUnlock()
+	return t.payload
+}
+
+// ExpectReply returns expectReply
+func (t *UDPPort) ExpectReply() bool {
+	t.RLock()
+	defer t.RUnlock()
+	return t.expectReply
+}
+
+// Interval returns interval
+func (t *UDPPort) Interval() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.interval
+}
+
+// Timeout returns timeout
+func (t *UDPPort) Timeout() time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	return t.timeout
+}
+
+// Source returns the source address and interface, empty when not set
+func (t *UDPPort) Source() (string, string) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.srcAddr, t.srcInterface
+}
+
+// Labels returns labels
+func (t *UDPPort) Labels() map[string]string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.labels
+}